@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/celerix/depot"
+)
+
+// configFile holds the settings read from CONFIG_FILE: KEY=VALUE lines in
+// the same names as the environment variables, which they take
+// precedence over. Blank lines and lines starting with # are skipped.
+type configFile struct {
+	path string
+	// env is the environment before the file was applied, which settings
+	// dropped from the file fall back to
+	env map[string]string
+}
+
+// loadConfigFile reads the file and applies it to the environment, so
+// everything that reads the environment sees its settings.
+func loadConfigFile(path string) (*configFile, error) {
+	values, err := readConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f := &configFile{path: path, env: map[string]string{}}
+	for _, kv := range os.Environ() {
+		k, v, _ := strings.Cut(kv, "=")
+		f.env[k] = v
+	}
+	for k, v := range values {
+		os.Setenv(k, v)
+	}
+	return f, nil
+}
+
+func readConfigFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	values := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(strings.TrimPrefix(key, "export "))
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, n)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		values[key] = value
+	}
+	return values, scanner.Err()
+}
+
+// getenv re-reads the file and returns a lookup of its current settings
+// over the original environment.
+func (f *configFile) getenv() (func(string) string, error) {
+	values, err := readConfigFile(f.path)
+	if err != nil {
+		return nil, err
+	}
+	return func(key string) string {
+		if v, ok := values[key]; ok {
+			return v
+		}
+		return f.env[key]
+	}, nil
+}
+
+// reloadableConfig reads the settings that can change without a restart:
+// the upload policy, the admin webhook, the timeouts, the body size, rate
+// and transfer limits and the allowed CORS origins.
+func reloadableConfig(getenv func(string) string) (depot.Config, []string, error) {
+	cfg := depot.Config{
+		PolicyFile:      getenv("POLICY_FILE"),
+		AdminWebhookURL: getenv("ADMIN_WEBHOOK_URL"),
+	}
+	var err error
+	if v := getenv("REQUEST_TIMEOUT"); v != "" {
+		if cfg.RequestTimeout, err = time.ParseDuration(v); err != nil {
+			return cfg, nil, fmt.Errorf("REQUEST_TIMEOUT: %w", err)
+		}
+	}
+	if v := getenv("TRANSFER_TIMEOUT"); v != "" {
+		if cfg.TransferTimeout, err = time.ParseDuration(v); err != nil {
+			return cfg, nil, fmt.Errorf("TRANSFER_TIMEOUT: %w", err)
+		}
+	}
+	if cfg.EndpointTimeouts, err = parseEndpointTimeouts(getenv("ENDPOINT_TIMEOUTS")); err != nil {
+		return cfg, nil, fmt.Errorf("ENDPOINT_TIMEOUTS: %w", err)
+	}
+	if v := getenv("MAX_BODY_SIZE"); v != "" {
+		if cfg.MaxBodyBytes, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return cfg, nil, fmt.Errorf("MAX_BODY_SIZE: %w", err)
+		}
+	}
+	if v := getenv("RATE_LIMIT"); v != "" {
+		if cfg.RequestsPerMinute, err = strconv.Atoi(v); err != nil {
+			return cfg, nil, fmt.Errorf("RATE_LIMIT: %w", err)
+		}
+	}
+	if v := getenv("MAX_TRANSFERS"); v != "" {
+		if cfg.MaxTransfers, err = strconv.Atoi(v); err != nil {
+			return cfg, nil, fmt.Errorf("MAX_TRANSFERS: %w", err)
+		}
+	}
+	if v := getenv("SMALL_TRANSFER_SIZE"); v != "" {
+		if cfg.SmallTransferBytes, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return cfg, nil, fmt.Errorf("SMALL_TRANSFER_SIZE: %w", err)
+		}
+	}
+	origins := []string{"*"}
+	if v := getenv("CORS_ORIGINS"); v != "" {
+		origins = nil
+		for _, o := range strings.Split(v, ",") {
+			if o = strings.TrimSuffix(strings.TrimSpace(o), "/"); o != "" {
+				origins = append(origins, o)
+			}
+		}
+	}
+	return cfg, origins, nil
+}
+
+// corsOrigins are the origins browsers may call the API from; "*" allows
+// any. They are swapped as a whole on reload.
+type corsOrigins struct {
+	list atomic.Pointer[[]string]
+}
+
+func (c *corsOrigins) set(origins []string) {
+	c.list.Store(&origins)
+}
+
+// allow returns the Access-Control-Allow-Origin value for a request from
+// origin, or "" to leave it out.
+func (c *corsOrigins) allow(origin string) string {
+	list := *c.list.Load()
+	if slices.Contains(list, "*") {
+		return "*"
+	}
+	if origin != "" && slices.Contains(list, origin) {
+		return origin
+	}
+	return ""
+}
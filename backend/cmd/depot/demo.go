@@ -0,0 +1,41 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/celerix/depot"
+	"github.com/celerix/depot/internal/db"
+	"github.com/google/uuid"
+)
+
+// demoRecoveryCode is the fixed code of the seeded demo client, so a demo
+// can be picked up from any browser.
+const demoRecoveryCode = "DEMO0001"
+
+var demoFiles = []struct {
+	Name, Folder, Content string
+	Public                bool
+}{
+	{"welcome.md", "", "# Welcome to the depot\n\nThis is a demo instance. Everything you upload is kept in memory and temporary storage, and is gone when the server stops.\n", true},
+	{"release-notes.txt", "docs", "v1.0\n- First release\n", true},
+	{"meeting-notes.md", "docs/team", "## Weekly sync\n\n- Ship the demo\n- Write more docs\n", false},
+	{"config.json", "projects/example", "{\n  \"name\": \"example\",\n  \"enabled\": true\n}\n", false},
+}
+
+// seedDemo creates a demo client with a few files and logs how to use them.
+func seedDemo(h *depot.Handler, namespace uuid.UUID, adminSecret string) error {
+	id := uuid.NewSHA1(namespace, []byte(demoRecoveryCode)).String()
+	if err := db.UpsertClient(h.Store, id, "Demo User", demoRecoveryCode, time.Now().Unix()); err != nil {
+		return err
+	}
+	for _, f := range demoFiles {
+		if _, err := h.AddFile(strings.NewReader(f.Content), id, f.Name, f.Folder, f.Public); err != nil {
+			return err
+		}
+	}
+	log.Printf("Demo mode: data is kept in memory and discarded on exit")
+	log.Printf("Demo mode: recover the demo client with code %s; the admin secret is %q", demoRecoveryCode, adminSecret)
+	return nil
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+	"github.com/celerix/depot/internal/doctor"
+	"github.com/celerix/depot/internal/geoip"
+	"github.com/celerix/depot/internal/hooks"
+	"github.com/celerix/depot/internal/policy"
+	"github.com/celerix/depot/internal/storage"
+	"github.com/google/uuid"
+)
+
+// doctorTimeout bounds each check that talks to another service.
+const doctorTimeout = 5 * time.Second
+
+// runDoctor implements `depot doctor`: it checks the configuration and the
+// environment the server would run in and prints what to fix.
+func runDoctor(args []string) error {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the results as JSON")
+	fs.Parse(args)
+
+	results := checkConfig()
+	dataDir, storageDir := dataDirs()
+
+	// sdk.New quietly falls back to the embedded store when the remote one
+	// is down, so look first
+	storeOK := true
+	if addr := os.Getenv("CELERIX_STORE_ADDR"); addr != "" {
+		r := reachable("store", addr)
+		storeOK = r.Status == doctor.OK
+		results = append(results, r)
+	}
+	if storeOK {
+		store, err := sdk.New(dataDir)
+		if err != nil {
+			results = append(results, doctor.Result{Name: "store", Status: doctor.Fail, Detail: err.Error(),
+				Fix: fmt.Sprintf("check that %s exists and is readable, or set CELERIX_STORE_ADDR", dataDir)})
+		} else {
+			results = append(results, doctor.CheckSchema(store))
+		}
+	}
+
+	results = append(results,
+		doctor.CheckDir("storage directory", storageDir),
+		doctor.CheckDiskSpace("storage volume", storageDir, storage.VolumeUsage))
+	if dir := os.Getenv("TEMP_DIR"); dir != "" {
+		results = append(results,
+			doctor.CheckDir("temp directory", dir),
+			doctor.CheckDiskSpace("temp volume", dir, storage.VolumeUsage))
+	}
+	if dir := os.Getenv("INBOX_DIR"); dir != "" {
+		results = append(results, doctor.CheckDir("inbox directory", dir))
+	}
+
+	if endpoint := os.Getenv("S3_ENDPOINT"); endpoint != "" && os.Getenv("S3_BUCKET") != "" {
+		results = append(results, reachable("S3", endpoint))
+		ctx, cancel := context.WithTimeout(context.Background(), doctorTimeout)
+		results = append(results, doctor.CheckClock(ctx, http.DefaultClient, endpoint))
+		cancel()
+	}
+	for _, integration := range []struct{ name, env string }{
+		{"event bus", "EVENT_BUS_URL"},
+		{"command bus", "COMMAND_BUS_URL"},
+		{"mail relay", "MAIL_RELAY"},
+		{"admin webhook", "ADMIN_WEBHOOK_URL"},
+	} {
+		if addr := os.Getenv(integration.env); addr != "" {
+			results = append(results, reachable(integration.name, addr))
+		}
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			return err
+		}
+	} else {
+		printResults(results)
+	}
+	if doctor.Failed(results) {
+		return fmt.Errorf("some checks failed")
+	}
+	return nil
+}
+
+func reachable(name, addr string) doctor.Result {
+	ctx, cancel := context.WithTimeout(context.Background(), doctorTimeout)
+	defer cancel()
+	return doctor.CheckReachable(ctx, name, addr)
+}
+
+// checkConfig validates the environment the way the server reads it, but
+// reports every problem instead of stopping at the first.
+func checkConfig() []doctor.Result {
+	var results []doctor.Result
+	fail := func(format string, args ...any) {
+		results = append(results, doctor.Result{Name: "config", Status: doctor.Fail, Detail: fmt.Sprintf(format, args...)})
+	}
+	warn := func(detail, fix string) {
+		results = append(results, doctor.Result{Name: "config", Status: doctor.Warn, Detail: detail, Fix: fix})
+	}
+
+	if _, err := uuid.Parse(os.Getenv("CELERIX_NAMESPACE")); err != nil {
+		fail("CELERIX_NAMESPACE must be a UUID")
+	}
+	for _, name := range []string{"SCRUB_INTERVAL", "COMPACT_INTERVAL", "REQUEST_TIMEOUT", "TRANSFER_TIMEOUT", "INBOX_INTERVAL", "HOOK_TIMEOUT"} {
+		if v := os.Getenv(name); v != "" {
+			if _, err := time.ParseDuration(v); err != nil {
+				fail("%s: %v", name, err)
+			}
+		}
+	}
+	if v := os.Getenv("TORRENT_MIN_SIZE"); v != "" {
+		if _, err := strconv.ParseInt(v, 10, 64); err != nil {
+			fail("TORRENT_MIN_SIZE must be a number of bytes")
+		}
+	}
+	if _, err := parseEndpointTimeouts(os.Getenv("ENDPOINT_TIMEOUTS")); err != nil {
+		fail("ENDPOINT_TIMEOUTS: %v", err)
+	}
+	if os.Getenv("DEPOT_MODE") == "gateway" && os.Getenv("CELERIX_STORE_ADDR") == "" {
+		fail("CELERIX_STORE_ADDR is required in gateway mode")
+	}
+	if os.Getenv("MAIL_RELAY") != "" && os.Getenv("MAIL_FROM") == "" {
+		fail("MAIL_FROM is required with MAIL_RELAY")
+	}
+	if os.Getenv("S3_BUCKET") != "" && os.Getenv("S3_ENDPOINT") == "" {
+		fail("S3_ENDPOINT is required with S3_BUCKET")
+	}
+	if os.Getenv("COMMAND_SUBJECT") != "" && os.Getenv("COMMAND_BUS_URL") == "" && os.Getenv("EVENT_BUS_URL") == "" {
+		fail("COMMAND_SUBJECT needs COMMAND_BUS_URL or EVENT_BUS_URL")
+	}
+	if path := os.Getenv("POLICY_FILE"); path != "" {
+		if _, err := policy.Load(path); err != nil {
+			fail("POLICY_FILE: %v", err)
+		}
+	}
+	if path := os.Getenv("GEOIP_DB"); path != "" {
+		if _, err := geoip.Open(path); err != nil {
+			fail("GEOIP_DB: %v", err)
+		}
+	}
+	if dir := os.Getenv("HOOKS_DIR"); dir != "" {
+		if _, err := (&hooks.Registry{}).LoadDir(dir, time.Second); err != nil {
+			fail("HOOKS_DIR: %v", err)
+		}
+	}
+	for _, p := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		p = strings.TrimSpace(p)
+		if prefix, err := netip.ParsePrefix(p); err == nil && prefix.Bits() == 0 {
+			warn("TRUSTED_PROXIES trusts every address, so any client can pick the address it is logged and restricted by",
+				"list only the addresses of your reverse proxies")
+		} else if _, addrErr := netip.ParseAddr(p); err != nil && addrErr != nil && p != "" && p != "none" {
+			fail("TRUSTED_PROXIES: %q is not an address or CIDR range", p)
+		}
+	}
+	for _, name := range []string{"PUBLIC_URL", "SHORT_URL"} {
+		if v := os.Getenv(name); v != "" {
+			if u, err := url.Parse(v); err != nil || u.Scheme == "" || u.Host == "" {
+				fail("%s must be an absolute URL like https://files.example.com", name)
+			}
+		}
+	}
+	if os.Getenv("PUBLIC_URL") == "" {
+		warn("PUBLIC_URL is not set", "set it so links in notifications and previews point at the depot")
+	}
+	if os.Getenv("ADMIN_SECRET") == "" {
+		warn("ADMIN_SECRET is not set", "set it to be able to activate admin access")
+	}
+
+	// Failures name the variable, which is the fix
+	for i := range results {
+		if results[i].Status == doctor.Fail {
+			results[i].Fix = "correct the variable and run the check again"
+		}
+	}
+	if len(results) == 0 {
+		results = append(results, doctor.Result{Name: "config", Status: doctor.OK, Detail: "environment variables are valid"})
+	}
+	return results
+}
+
+func printResults(results []doctor.Result) {
+	labels := map[doctor.Status]string{doctor.OK: "ok", doctor.Warn: "WARN", doctor.Fail: "FAIL"}
+	for _, r := range results {
+		fmt.Printf("%-5s %s: %s\n", labels[r.Status], r.Name, r.Detail)
+		if r.Fix != "" {
+			fmt.Printf("%s fix: %s\n", strings.Repeat(" ", 5), r.Fix)
+		}
+	}
+}
+
+// startupChecks runs the checks worth stopping for before the server
+// opens the store; depot.New checks the directories and the schema.
+func startupChecks(storageDir string) {
+	if addr := os.Getenv("CELERIX_STORE_ADDR"); addr != "" {
+		if r := reachable("store", addr); r.Status != doctor.OK {
+			log.Fatalf("Cannot reach the store at CELERIX_STORE_ADDR (%s); %s. Run `depot doctor` for details", r.Detail, r.Fix)
+		}
+	}
+	if r := doctor.CheckDiskSpace("storage volume", storageDir, storage.VolumeUsage); r.Status != doctor.OK {
+		log.Printf("[WARN] Storage volume: %s", r.Detail)
+	}
+}
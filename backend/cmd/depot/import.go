@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/celerix/depot"
+	"github.com/celerix/depot/internal/api"
+	"github.com/google/uuid"
+)
+
+// importTree implements `depot import <path> --owner <id>`.
+func importTree(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	owner := fs.String("owner", "", "client ID that owns the imported files; system files when empty")
+	mode := fs.String("mode", api.ImportReference, "reference registers files where they are, copy copies them into storage, move copies and removes them")
+	// Accept the path before the flags as well as after them
+	var root string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		root, args = args[0], args[1:]
+	}
+	fs.Parse(args)
+	if root == "" {
+		root = fs.Arg(0)
+	}
+	if root == "" {
+		return errors.New("usage: depot import <path> [--owner <client id>] [--mode reference|copy|move]")
+	}
+
+	namespace, err := uuid.Parse(os.Getenv("CELERIX_NAMESPACE"))
+	if err != nil {
+		return errors.New("CELERIX_NAMESPACE must be set to the server's namespace UUID")
+	}
+	store, storageDir, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer flushStore(store)
+
+	// The same upload pipeline as the server's, so policies and hooks apply
+	d, err := depot.New(depot.Config{
+		Store:         store,
+		StorageDir:    storageDir,
+		TempDir:       os.Getenv("TEMP_DIR"),
+		Namespace:     namespace,
+		PolicyFile:    os.Getenv("POLICY_FILE"),
+		StripMetadata: os.Getenv("STRIP_METADATA") == "true",
+		ContentIndex:  os.Getenv("CONTENT_INDEX") != "false",
+		AutoTag:       os.Getenv("AUTO_TAG") == "true",
+		HooksDir:      os.Getenv("HOOKS_DIR"),
+	})
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	report, err := d.Handler.ImportTree(ctx, root, *owner, *mode)
+	if err != nil {
+		return err
+	}
+	for _, f := range report.Failed {
+		log.Printf("Skipped %s: %s", f.Path, f.Error)
+	}
+	log.Printf("Imported %d files (%d bytes) from %s, %d skipped", report.Imported, report.Bytes, root, len(report.Failed))
+	if len(report.Failed) > 0 {
+		return fmt.Errorf("%d files could not be imported", len(report.Failed))
+	}
+	return nil
+}
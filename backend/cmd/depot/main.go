@@ -2,16 +2,25 @@ package main
 
 import (
 	"embed"
+	"flag"
+	"fmt"
 	"io/fs"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	_ "github.com/celerix-dev/celerix-store/pkg/engine"
 	"github.com/celerix-dev/celerix-store/pkg/sdk"
-	"github.com/celerix/depot/internal/api"
+	"github.com/celerix/depot"
+	"github.com/celerix/depot/internal/memstore"
+	"github.com/celerix/depot/internal/service"
+	"github.com/celerix/depot/internal/static"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
@@ -23,25 +32,92 @@ var frontendDist embed.FS
 var versionFile []byte
 
 func main() {
+	if service.IsWindowsService() {
+		runWindowsService()
+		return
+	}
+	depotMain()
+}
+
+func depotMain() {
+	var file *configFile
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		var err error
+		if file, err = loadConfigFile(path); err != nil {
+			log.Fatalf("Failed to read CONFIG_FILE: %v", err)
+		}
+	}
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate-storage":
+			if err := migrateStorage(os.Args[2:]); err != nil {
+				log.Fatalf("Migration failed: %v", err)
+			}
+			return
+		case "import":
+			if err := importTree(os.Args[2:]); err != nil {
+				log.Fatalf("Import failed: %v", err)
+			}
+			return
+		case "doctor":
+			if err := runDoctor(os.Args[2:]); err != nil {
+				log.Fatalf("Doctor: %v", err)
+			}
+			return
+		case "install-service":
+			if err := runInstallService(os.Args[2:]); err != nil {
+				log.Fatalf("Failed to install the service: %v", err)
+			}
+			return
+		}
+	}
+
+	demo := flag.Bool("demo", false, "run with an in-memory store, temporary storage and sample data")
+	flag.Parse()
+
+	if path := os.Getenv("LOG_FILE"); path != "" {
+		setupLogFile(path)
+	}
+
 	dataDir := os.Getenv("DATA_DIR")
 	if dataDir == "" {
 		dataDir = "./data"
 	}
 
 	storageDir := os.Getenv("STORAGE_DIR")
-	if storageDir == "" {
+	if *demo {
+		// Nothing of a demo outlives the process
+		dir, err := os.MkdirTemp("", "depot-demo-*")
+		if err != nil {
+			log.Fatalf("Failed to create demo storage: %v", err)
+		}
+		storageDir = dir
+		defer os.RemoveAll(dir)
+	} else if storageDir == "" {
 		storageDir = filepath.Join(dataDir, "uploads")
 	}
 
 	// Ensure directories exist
-	if err := os.MkdirAll(dataDir, 0755); err != nil {
-		log.Fatalf("Failed to create data directory: %v", err)
+	if !*demo {
+		if err := os.MkdirAll(dataDir, 0755); err != nil {
+			log.Fatalf("Failed to create data directory: %v", err)
+		}
 	}
 	if err := os.MkdirAll(storageDir, 0755); err != nil {
 		log.Fatalf("Failed to create storage directory: %v", err)
 	}
+	if path := os.Getenv("PID_FILE"); path != "" {
+		if err := service.WritePIDFile(path); err != nil {
+			log.Fatalf("Failed to write PID_FILE: %v", err)
+		}
+		defer service.RemovePIDFile(path)
+	}
 
 	namespaceStr := os.Getenv("CELERIX_NAMESPACE")
+	if namespaceStr == "" && *demo {
+		namespaceStr = uuid.New().String()
+	}
 	if namespaceStr == "" {
 		log.Fatal("CELERIX_NAMESPACE environment variable is required")
 	}
@@ -50,26 +126,202 @@ func main() {
 		log.Fatalf("Failed to parse CELERIX_NAMESPACE as UUID: %v", err)
 	}
 
-	store, err := sdk.New(dataDir)
+	var torrentMinSize int64
+	if v := os.Getenv("TORRENT_MIN_SIZE"); v != "" {
+		torrentMinSize, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			log.Fatalf("Failed to parse TORRENT_MIN_SIZE: %v", err)
+		}
+	}
+	var torrentTrackers []string
+	for _, t := range strings.Split(os.Getenv("TORRENT_TRACKERS"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			torrentTrackers = append(torrentTrackers, t)
+		}
+	}
+
+	// The gateway serves downloads only and needs the live store shared with
+	// the main server; the embedded store would be a stale snapshot
+	gateway := os.Getenv("DEPOT_MODE") == "gateway"
+	if gateway && *demo {
+		log.Fatal("--demo cannot be used in gateway mode")
+	}
+	if gateway && os.Getenv("CELERIX_STORE_ADDR") == "" {
+		log.Fatal("CELERIX_STORE_ADDR is required in gateway mode")
+	}
+	cluster := os.Getenv("CLUSTER") == "true"
+	if cluster && (*demo || os.Getenv("CELERIX_STORE_ADDR") == "") {
+		log.Fatal("CLUSTER requires CELERIX_STORE_ADDR")
+	}
+
+	var store sdk.CelerixStore
+	if *demo {
+		store = memstore.New()
+	} else {
+		startupChecks(storageDir)
+		store, err = sdk.New(dataDir)
+		if err != nil {
+			log.Fatalf("Failed to initialize Celerix Store: %v", err)
+		}
+		defer flushStore(store)
+	}
+
+	cfg := depot.Config{
+		Store:           store,
+		StorageDir:      storageDir,
+		TempDir:         os.Getenv("TEMP_DIR"),
+		Cluster:         cluster,
+		RedisURL:        os.Getenv("REDIS_URL"),
+		Namespace:       celerixNamespace,
+		AdminSecret:     os.Getenv("ADMIN_SECRET"),
+		PublicURL:       os.Getenv("PUBLIC_URL"),
+		ShortURL:        os.Getenv("SHORT_URL"),
+		Version:         versionFile,
+		StripMetadata:   os.Getenv("STRIP_METADATA") == "true",
+		ContentIndex:    os.Getenv("CONTENT_INDEX") != "false",
+		AutoTag:         os.Getenv("AUTO_TAG") == "true",
+		TorrentMinSize:  torrentMinSize,
+		TorrentTrackers: torrentTrackers,
+		HooksDir:        os.Getenv("HOOKS_DIR"),
+		ScrubReplicaDir: os.Getenv("SCRUB_REPLICA_DIR"),
+		SMTPDomain:      os.Getenv("SMTP_DOMAIN"),
+		GeoIPFile:       os.Getenv("GEOIP_DB"),
+		GeoIPHeader:     os.Getenv("GEOIP_HEADER"),
+		Tenants:         !gateway,
+		DiagnosticsAddr: os.Getenv("DIAGNOSTICS_ADDR"),
+	}
+	cfg.ScrubRate, _ = strconv.ParseInt(os.Getenv("SCRUB_RATE"), 10, 64)
+	if v := os.Getenv("SCRUB_INTERVAL"); v != "" && !gateway {
+		cfg.ScrubInterval, err = time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("Failed to parse SCRUB_INTERVAL: %v", err)
+		}
+	}
+	if v := os.Getenv("COMPACT_INTERVAL"); v != "" && !gateway {
+		cfg.CompactInterval, err = time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("Failed to parse COMPACT_INTERVAL: %v", err)
+		}
+	}
+	if !gateway {
+		cfg.SMTPAddr = os.Getenv("SMTP_ADDR")
+		cfg.InboxDir = os.Getenv("INBOX_DIR")
+		cfg.InboxOwner = os.Getenv("INBOX_OWNER")
+		cfg.EventBusURL = os.Getenv("EVENT_BUS_URL")
+		cfg.EventTopic = os.Getenv("EVENT_TOPIC")
+		cfg.CommandBusURL = os.Getenv("COMMAND_BUS_URL")
+		cfg.CommandSubject = os.Getenv("COMMAND_SUBJECT")
+	}
+	if v := os.Getenv("TRUSTED_PROXIES"); v != "" {
+		cfg.TrustedProxies = []string{}
+		for _, p := range strings.Split(v, ",") {
+			if p = strings.TrimSpace(p); p != "" && p != "none" {
+				cfg.TrustedProxies = append(cfg.TrustedProxies, p)
+			}
+		}
+	}
+	reloadable, origins, err := reloadableConfig(os.Getenv)
 	if err != nil {
-		log.Fatalf("Failed to initialize Celerix Store: %v", err)
+		log.Fatalf("Failed to parse %v", err)
+	}
+	cfg.PolicyFile = reloadable.PolicyFile
+	cfg.AdminWebhookURL = reloadable.AdminWebhookURL
+	cfg.RequestTimeout = reloadable.RequestTimeout
+	cfg.TransferTimeout = reloadable.TransferTimeout
+	cfg.EndpointTimeouts = reloadable.EndpointTimeouts
+	cfg.MaxBodyBytes = reloadable.MaxBodyBytes
+	cfg.RequestsPerMinute = reloadable.RequestsPerMinute
+	cfg.MaxTransfers = reloadable.MaxTransfers
+	cfg.SmallTransferBytes = reloadable.SmallTransferBytes
+	var cors corsOrigins
+	cors.set(origins)
+	if v := os.Getenv("INBOX_INTERVAL"); v != "" {
+		cfg.InboxInterval, err = time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("Failed to parse INBOX_INTERVAL: %v", err)
+		}
+	}
+	if v := os.Getenv("HOOK_TIMEOUT"); v != "" {
+		cfg.HookTimeout, err = time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("Failed to parse HOOK_TIMEOUT: %v", err)
+		}
+	}
+	cfg.S3 = s3ConfigFromEnv()
+	if v := os.Getenv("MAIL_RELAY"); v != "" {
+		if os.Getenv("MAIL_FROM") == "" {
+			log.Fatal("MAIL_FROM is required with MAIL_RELAY")
+		}
+		cfg.Mailer = &depot.Mailer{
+			Addr:     v,
+			From:     os.Getenv("MAIL_FROM"),
+			Username: os.Getenv("MAIL_USERNAME"),
+			Password: os.Getenv("MAIL_PASSWORD"),
+		}
 	}
 
-	h := &api.Handler{
-		Store:            store,
-		StorageDir:       storageDir,
-		AdminSecret:      os.Getenv("ADMIN_SECRET"),
-		VersionConfig:    versionFile,
-		CelerixNamespace: celerixNamespace,
+	if *demo && cfg.AdminSecret == "" {
+		cfg.AdminSecret = "demo"
+	}
+
+	d, err := depot.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize depot: %v", err)
+	}
+	defer d.Close()
+
+	if *demo {
+		if err := seedDemo(d.Handler, celerixNamespace, cfg.AdminSecret); err != nil {
+			log.Fatalf("Failed to seed demo data: %v", err)
+		}
+	}
+
+	// Reloading re-reads CONFIG_FILE, or the unchanged environment without
+	// one, which still re-reads the policy file
+	reload := func() error {
+		getenv := os.Getenv
+		if file != nil {
+			var err error
+			if getenv, err = file.getenv(); err != nil {
+				return err
+			}
+		}
+		next, origins, err := reloadableConfig(getenv)
+		if err != nil {
+			return err
+		}
+		if err := d.Reload(next); err != nil {
+			return err
+		}
+		cors.set(origins)
+		return nil
 	}
+	d.Handler.ReloadConfig = reload
+	go func() {
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		for range hup {
+			if err := reload(); err != nil {
+				log.Printf("[ERROR] Failed to reload configuration: %v", err)
+				continue
+			}
+			log.Print("Configuration reloaded")
+		}
+	}()
 
 	r := gin.Default()
+	if err := r.SetTrustedProxies(d.TrustedProxies()); err != nil {
+		log.Fatalf("Failed to parse TRUSTED_PROXIES: %v", err)
+	}
 
 	// CORS middleware
 	r.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		if origin := cors.allow(c.GetHeader("Origin")); origin != "" {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+		c.Writer.Header().Add("Vary", "Origin")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-Client-ID, X-Admin-Secret")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-Client-ID, X-Admin-Secret, Idempotency-Key")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
@@ -79,23 +331,21 @@ func main() {
 
 		c.Next()
 	})
+	r.Use(d.ShortLinkMiddleware())
+	if gateway {
+		d.MountGateway(r)
+		r.NoRoute(func(c *gin.Context) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+		})
+		serve(r, "Gateway")
+		return
+	}
 
-	apiGroup := r.Group("/api")
-	{
-		apiGroup.GET("/version", h.GetVersion)
-		apiGroup.GET("/persona", h.GetPersona)
-		apiGroup.POST("/persona/name", h.UpdateClientName)
-		apiGroup.POST("/persona/recover", h.RecoverPersona)
-		apiGroup.POST("/persona/admin", h.ActivateAdmin)
-		apiGroup.POST("/upload", h.UploadFile)
-		apiGroup.GET("/files", h.ListFiles)
-		apiGroup.GET("/files/:id", h.GetFileMetadata)
-		apiGroup.PUT("/files/:id", h.UpdateFile)
-		apiGroup.DELETE("/files/:id", h.DeleteFile)
-		apiGroup.GET("/clients", h.ListClients)
-		apiGroup.PUT("/clients/:id", h.UpdateClient)
-		apiGroup.DELETE("/clients/:id", h.DeleteClient)
-		apiGroup.GET("/download/:id", h.DownloadFile)
+	r.Use(d.TenantMiddleware())
+
+	d.Mount(r)
+	if os.Getenv("OCI_REGISTRY") == "true" {
+		d.MountRegistry(r)
 	}
 
 	// Serve frontend static files
@@ -103,34 +353,57 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to sub embedded dist: %v", err)
 	}
+	frontend, err := static.New(distFS)
+	if err != nil {
+		log.Fatalf("Failed to load embedded dist: %v", err)
+	}
 
 	r.NoRoute(func(c *gin.Context) {
-		path := c.Request.URL.Path
 		// If it's an API request that reached here, return 404
-		if strings.HasPrefix(path, "/api") {
+		if strings.HasPrefix(c.Request.URL.Path, "/api") {
 			c.JSON(http.StatusNotFound, gin.H{"error": "API route not found"})
 			return
 		}
-
-		// Try to serve the file from the embedded filesystem
-		file, err := distFS.Open(strings.TrimPrefix(path, "/"))
-		if err == nil {
-			file.Close()
-			http.FileServer(http.FS(distFS)).ServeHTTP(c.Writer, c.Request)
-			return
-		}
-
-		// Fallback to index.html for SPA routing
-		c.FileFromFS("/", http.FS(distFS))
+		// Serves the file, or index.html for SPA routing
+		frontend.ServeHTTP(c.Writer, c.Request)
 	})
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	serve(r, "Server")
+}
+
+// parseEndpointTimeouts reads "POST /api/upload=2h,GET /api/files=10s".
+func parseEndpointTimeouts(v string) (map[string]time.Duration, error) {
+	if strings.TrimSpace(v) == "" {
+		return nil, nil
 	}
+	timeouts := make(map[string]time.Duration)
+	for _, entry := range strings.Split(v, ",") {
+		route, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		method, path, hasPath := strings.Cut(strings.TrimSpace(route), " ")
+		if !ok || !hasPath || !strings.HasPrefix(path, "/") {
+			return nil, fmt.Errorf("%q is not METHOD /route=duration", entry)
+		}
+		timeout, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return nil, err
+		}
+		timeouts[strings.ToUpper(method)+" "+path] = timeout
+	}
+	return timeouts, nil
+}
 
-	log.Printf("Server starting on port %s", port)
-	if err := r.Run(":" + port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+// s3ConfigFromEnv returns the S3 backend configured by S3_*, or nil.
+func s3ConfigFromEnv() *depot.S3Config {
+	bucket := os.Getenv("S3_BUCKET")
+	if bucket == "" {
+		return nil
+	}
+	return &depot.S3Config{
+		Endpoint:  os.Getenv("S3_ENDPOINT"),
+		Region:    os.Getenv("S3_REGION"),
+		Bucket:    bucket,
+		AccessKey: os.Getenv("S3_ACCESS_KEY"),
+		SecretKey: os.Getenv("S3_SECRET_KEY"),
+		PathStyle: os.Getenv("S3_PATH_STYLE") != "false",
 	}
 }
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+	"github.com/celerix/depot/internal/migrate"
+	"github.com/celerix/depot/internal/storage"
+)
+
+// openStore opens the store and storage directory configured for the server,
+// for commands that work on them directly. With CELERIX_STORE_ADDR they can
+// run next to a live server; without it the server has to be stopped, or it
+// would overwrite their changes with its own copy of the store.
+func openStore() (sdk.CelerixStore, string, error) {
+	dataDir, storageDir := dataDirs()
+	if os.Getenv("CELERIX_STORE_ADDR") == "" {
+		log.Print("CELERIX_STORE_ADDR is not set, using the embedded store; the server must not be running")
+	}
+	store, err := sdk.New(dataDir)
+	if err != nil {
+		return nil, "", fmt.Errorf("initialize Celerix Store: %w", err)
+	}
+	return store, storageDir, nil
+}
+
+// dataDirs returns the data and storage directories configured for the
+// server.
+func dataDirs() (dataDir, storageDir string) {
+	dataDir = os.Getenv("DATA_DIR")
+	if dataDir == "" {
+		dataDir = "./data"
+	}
+	storageDir = os.Getenv("STORAGE_DIR")
+	if storageDir == "" {
+		storageDir = filepath.Join(dataDir, "uploads")
+	}
+	return dataDir, storageDir
+}
+
+// flushStore waits for the embedded store to persist in the background.
+func flushStore(store sdk.CelerixStore) {
+	if w, ok := store.(interface{ Wait() }); ok {
+		w.Wait()
+	}
+}
+
+// migrateStorage implements `depot migrate-storage --from local --to s3`.
+func migrateStorage(args []string) error {
+	fs := flag.NewFlagSet("migrate-storage", flag.ExitOnError)
+	from := fs.String("from", "local", "backend to move content off: local or s3")
+	to := fs.String("to", "s3", "backend to move content to: local or s3")
+	deleteSource := fs.Bool("delete-source", false, "delete old copies nothing refers to any more")
+	dryRun := fs.Bool("dry-run", false, "only report what would be copied")
+	fs.Parse(args)
+
+	switch {
+	case *from == "local" && *to == "s3", *from == "s3" && *to == "local":
+	default:
+		return errors.New("--from and --to must be local and s3, in either order")
+	}
+
+	cfg := s3ConfigFromEnv()
+	if cfg == nil {
+		return errors.New("S3_BUCKET and the other S3_* variables are required")
+	}
+	s3Client, err := storage.NewS3Client(*cfg)
+	if err != nil {
+		return err
+	}
+
+	store, storageDir, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer flushStore(store)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	m := &migrate.Migrator{
+		Store:        store,
+		S3:           s3Client,
+		StorageDir:   storageDir,
+		ToS3:         *to == "s3",
+		DeleteSource: *deleteSource,
+		DryRun:       *dryRun,
+		Logf:         log.Printf,
+	}
+	report, err := m.Run(ctx)
+	if report != nil {
+		verb := "Copied"
+		if *dryRun {
+			verb = "Would copy"
+		}
+		log.Printf("%s %d stored copies (%d files, %d bytes) from %s to %s, %d failed, %d old copies deleted",
+			verb, report.Copied, report.Files, report.Bytes, *from, *to, len(report.Failed), report.Deleted)
+	}
+	if err != nil {
+		return err
+	}
+	if len(report.Failed) > 0 {
+		return fmt.Errorf("%d stored copies could not be moved; run again to retry them", len(report.Failed))
+	}
+	return nil
+}
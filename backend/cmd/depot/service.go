@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/celerix/depot/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// shutdownTimeout is how long requests in flight may take to finish once
+// the server is asked to stop.
+const shutdownTimeout = 30 * time.Second
+
+// shutdown is closed to stop the server when the Windows service manager
+// asks the service to stop.
+var (
+	shutdown    = make(chan struct{})
+	stopService = sync.OnceFunc(func() { close(shutdown) })
+)
+
+// runWindowsService runs the server under the Windows service manager.
+func runWindowsService() {
+	// Services start in the system directory, so relative paths like
+	// ./data would land there
+	if exe, err := os.Executable(); err == nil {
+		os.Chdir(filepath.Dir(exe))
+	}
+	if err := service.RunWindowsService("depot", depotMain, stopService); err != nil {
+		log.Fatalf("Failed to run as a service: %v", err)
+	}
+}
+
+// serve runs the server on the sockets passed by systemd, UNIX_SOCKET or
+// PORT until it is told to stop, and then lets requests in flight finish.
+func serve(r *gin.Engine, what string) {
+	lns, err := service.Listeners()
+	if err != nil {
+		log.Fatalf("Failed to use the sockets passed by systemd: %v", err)
+	}
+	if len(lns) > 0 {
+		for _, ln := range lns {
+			log.Printf("%s starting on %s (socket activation)", what, ln.Addr())
+		}
+	} else if path := os.Getenv("UNIX_SOCKET"); path != "" {
+		mode := uint64(0660)
+		if v := os.Getenv("UNIX_SOCKET_MODE"); v != "" {
+			if mode, err = strconv.ParseUint(v, 8, 32); err != nil {
+				log.Fatalf("Failed to parse UNIX_SOCKET_MODE: %v", err)
+			}
+		}
+		ln, err := service.ListenUnix(path, os.FileMode(mode))
+		if err != nil {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+		lns = append(lns, ln)
+		log.Printf("%s starting on %s", what, path)
+	} else {
+		port := os.Getenv("PORT")
+		if port == "" {
+			port = "8080"
+		}
+		ln, err := net.Listen("tcp", ":"+port)
+		if err != nil {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+		lns = append(lns, ln)
+		log.Printf("%s starting on port %s", what, port)
+	}
+
+	srv := &http.Server{Handler: r.Handler()}
+	errs := make(chan error, len(lns))
+	for _, ln := range lns {
+		go func() { errs <- srv.Serve(ln) }()
+	}
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	select {
+	case err := <-errs:
+		log.Fatalf("Failed to start server: %v", err)
+	case sig := <-stop:
+		log.Printf("Received %s, shutting down", sig)
+	case <-shutdown:
+		log.Print("Service stopping, shutting down")
+	}
+	signal.Stop(stop)
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("[WARN] Requests still running after %s were cut off: %v", shutdownTimeout, err)
+	}
+}
+
+// setupLogFile sends the log to LOG_FILE, reopening it on SIGUSR1 after
+// logrotate moved it away.
+func setupLogFile(path string) {
+	logFile, err := service.OpenLog(path)
+	if err != nil {
+		log.Fatalf("Failed to open LOG_FILE: %v", err)
+	}
+	log.SetOutput(logFile)
+	gin.DefaultWriter = logFile
+	gin.DefaultErrorWriter = logFile
+	if len(service.ReopenSignals) == 0 {
+		return
+	}
+	reopen := make(chan os.Signal, 1)
+	signal.Notify(reopen, service.ReopenSignals...)
+	go func() {
+		for range reopen {
+			if err := logFile.Reopen(); err != nil {
+				log.Printf("[ERROR] Failed to reopen LOG_FILE: %v", err)
+			}
+		}
+	}()
+}
+
+// runInstallService implements `depot install-service`: it writes systemd
+// units for this binary, or registers it with the Windows service manager.
+func runInstallService(args []string) error {
+	fs := flag.NewFlagSet("install-service", flag.ExitOnError)
+	name := fs.String("name", "depot", "service name")
+	config := fs.String("config", "", "CONFIG_FILE the service reads its settings from")
+	logFile := fs.String("log", "", "LOG_FILE for the service; on Windows it defaults to depot.log beside the binary")
+	user := fs.String("user", "", "user the service runs as (systemd)")
+	listen := fs.String("listen", "", "address for a systemd socket unit, e.g. 8080, which starts the depot on the first connection")
+	unitDir := fs.String("unit-dir", "/etc/systemd/system", "where to write the systemd units")
+	printOnly := fs.Bool("print", false, "print the systemd units instead of writing them")
+	fs.Parse(args)
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return err
+	}
+	var env []string
+	if *config != "" {
+		abs, err := filepath.Abs(*config)
+		if err != nil {
+			return err
+		}
+		env = append(env, "CONFIG_FILE="+abs)
+	}
+	if *logFile == "" && runtime.GOOS == "windows" {
+		*logFile = filepath.Join(filepath.Dir(exe), "depot.log")
+	}
+	if *logFile != "" {
+		abs, err := filepath.Abs(*logFile)
+		if err != nil {
+			return err
+		}
+		env = append(env, "LOG_FILE="+abs)
+	}
+
+	if runtime.GOOS == "windows" {
+		if err := service.InstallWindowsService(*name, exe, env); err != nil {
+			return err
+		}
+		fmt.Printf("Installed the %s service. Start it with: sc start %s\n", *name, *name)
+		return nil
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	unit := service.Unit{Name: *name, Exec: exe, Env: env, User: *user, WorkingDir: wd, Listen: *listen}
+	files := [][2]string{{*name + ".service", unit.Service()}}
+	if socket := unit.Socket(); socket != "" {
+		files = append(files, [2]string{*name + ".socket", socket})
+	}
+	if *printOnly {
+		for _, f := range files {
+			fmt.Printf("# %s\n%s\n", f[0], f[1])
+		}
+		return nil
+	}
+	for _, f := range files {
+		path, content := filepath.Join(*unitDir, f[0]), f[1]
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("%s already exists", path)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %s\n", path)
+	}
+	enable := *name
+	if *listen != "" {
+		enable += ".socket"
+	}
+	fmt.Printf("Enable it with: systemctl daemon-reload && systemctl enable --now %s\n", enable)
+	return nil
+}
@@ -0,0 +1,78 @@
+// Command depotctl works with a depot from the command line.
+//
+//	depotctl sync [-server URL] [-client ID] [-root FOLDER] [-interval D] <dir>
+//
+// The server and client ID default to $DEPOT_URL and $DEPOT_CLIENT_ID.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/celerix/depot/internal/syncagent"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: depotctl <command> [flags]\n\nCommands:\n  sync    keep a directory in sync with a folder of your files\n")
+	os.Exit(2)
+}
+
+func main() {
+	log.SetFlags(log.LstdFlags)
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "sync":
+		runSync(os.Args[2:])
+	default:
+		usage()
+	}
+}
+
+func runSync(args []string) {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	server := fs.String("server", os.Getenv("DEPOT_URL"), "depot URL, e.g. https://depot.example.com")
+	client := fs.String("client", os.Getenv("DEPOT_CLIENT_ID"), "your client ID")
+	root := fs.String("root", "sync", "folder of your files to sync with; empty syncs all of them")
+	interval := fs.Duration("interval", 0, "keep syncing at this interval instead of once")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: depotctl sync [flags] <dir>\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 || *server == "" || *client == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	dir := fs.Arg(0)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Fatalf("Failed to create %s: %v", dir, err)
+	}
+	agent := &syncagent.Agent{
+		Client: &syncagent.Client{
+			BaseURL:  strings.TrimSuffix(*server, "/"),
+			ClientID: *client,
+			Root:     strings.Trim(*root, "/"),
+		},
+		Dir: dir,
+	}
+
+	for {
+		if err := agent.Run(); err != nil {
+			if *interval <= 0 {
+				log.Fatalf("Sync failed: %v", err)
+			}
+			log.Printf("Sync failed: %v", err)
+		}
+		if *interval <= 0 {
+			return
+		}
+		time.Sleep(*interval)
+	}
+}
@@ -0,0 +1,773 @@
+// Package depot embeds a Celerix Depot into another Go service.
+//
+//	d, err := depot.New(depot.Config{Store: store, StorageDir: dir, Namespace: ns})
+//	if err != nil { ... }
+//	defer d.Close()
+//	d.Mount(router.Group("/depot"))
+//
+// The standalone server in cmd/depot is built on the same API.
+package depot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+	"github.com/celerix/depot/internal/api"
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/doctor"
+	"github.com/celerix/depot/internal/events"
+	"github.com/celerix/depot/internal/geoip"
+	"github.com/celerix/depot/internal/hooks"
+	"github.com/celerix/depot/internal/inbox"
+	"github.com/celerix/depot/internal/jobs"
+	"github.com/celerix/depot/internal/mail"
+	"github.com/celerix/depot/internal/notify"
+	"github.com/celerix/depot/internal/policy"
+	"github.com/celerix/depot/internal/redis"
+	"github.com/celerix/depot/internal/sanitize"
+	"github.com/celerix/depot/internal/scrub"
+	"github.com/celerix/depot/internal/storage"
+	"github.com/celerix/depot/internal/storecache"
+	"github.com/celerix/depot/internal/storestats"
+	"github.com/celerix/depot/internal/tenant"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Types from the internal packages that embedders need to name.
+type (
+	Handler   = api.Handler
+	S3Config  = storage.S3Config
+	Upload    = hooks.Upload
+	Download  = hooks.Download
+	Rejection = hooks.Rejection
+	Mailer    = notify.Mailer
+)
+
+// Config configures a depot. Store, StorageDir and Namespace are required;
+// everything else is optional.
+type Config struct {
+	Store      sdk.CelerixStore
+	StorageDir string
+	Namespace  uuid.UUID
+	// TempDir stages uploads until they are complete; it may be on another
+	// file system than StorageDir, e.g. local disk in front of NFS.
+	// Defaults to StorageDir
+	TempDir string
+
+	AdminSecret string
+	PublicURL   string
+	// ShortURL is a separate domain, e.g. https://dl.example.com, that hands
+	// out short links; see ShortLinkMiddleware
+	ShortURL string
+	// Version is served as-is from /api/version
+	Version []byte
+
+	PolicyFile    string
+	StripMetadata bool
+	ContentIndex  bool
+	AutoTag       bool
+	// CleanName sanitizes the names of uploaded files; defaults to
+	// sanitize.Filename
+	CleanName sanitize.FilenameFunc
+	// Workers sizes the background job pool; defaults to 2
+	Workers int
+
+	TorrentMinSize  int64
+	TorrentTrackers []string
+
+	// GeoIPFile is a CSV range database used to attribute downloads to
+	// countries; GeoIPHeader names a trusted proxy header to use instead
+	GeoIPFile   string
+	GeoIPHeader string
+
+	S3 *S3Config
+
+	// HooksDir holds hook scripts; Hooks are Go values implementing any of
+	// the hook interfaces (PreUpload, PostUpload, PreDownload)
+	HooksDir    string
+	HookTimeout time.Duration
+	Hooks       []any
+
+	// ChangeRetention is how long the change journal behind the sync API
+	// keeps entries; defaults to 30 days
+	ChangeRetention time.Duration
+
+	// UsageMonths is how many calendar months of per-client bandwidth
+	// usage are kept, the current one included; defaults to 24
+	UsageMonths int
+
+	// CompactInterval runs store compaction periodically; 0 leaves it to
+	// POST /api/admin/maintenance/compact
+	CompactInterval time.Duration
+
+	ScrubInterval   time.Duration
+	ScrubRate       int64
+	ScrubReplicaDir string
+	AdminWebhookURL string
+	// Mailer sends notification email to clients who asked for it
+	Mailer *Mailer
+
+	// EventBusURL enables publishing file lifecycle events, to NATS
+	// (nats://host:4222, tls://…) or to Kafka through a REST proxy
+	// (kafka+http://proxy:8082); EventTopic defaults to depot.events
+	EventBusURL string
+	EventTopic  string
+	// CommandSubject enables the command queue: commands are received on
+	// that NATS subject at CommandBusURL, which defaults to EventBusURL
+	CommandBusURL  string
+	CommandSubject string
+
+	// SMTPAddr enables the inbound mail gateway; attachments sent to
+	// <alias>@SMTPDomain become files of the client with that alias
+	SMTPAddr   string
+	SMTPDomain string
+
+	// RequestTimeout bounds API requests, TransferTimeout uploads and
+	// downloads; EndpointTimeouts overrides both per route, keyed like
+	// "POST /api/upload". Zero means no limit.
+	RequestTimeout   time.Duration
+	TransferTimeout  time.Duration
+	EndpointTimeouts map[string]time.Duration
+	// MaxBodyBytes bounds API request bodies other than file content;
+	// zero means 4 MiB
+	MaxBodyBytes int64
+	// MaxTransfers bounds the uploads and downloads running at once,
+	// across tenants; zero means no limit. Waiting transfers of admins and
+	// of files up to SmallTransferBytes (default 1 MiB) go first.
+	MaxTransfers       int
+	SmallTransferBytes int64
+
+	// RequestsPerMinute limits the API requests of each client; zero
+	// means no limit
+	RequestsPerMinute int
+
+	// RedisURL, when set, moves record locks, request claims, the owner
+	// name and persona caches and the rate limit counters to Redis, so
+	// they hold across replicas without going through the store
+	RedisURL string
+
+	// Cluster is for replicas behind a load balancer sharing a remote
+	// store and storage directory (TempDir included, for resumable
+	// uploads): record updates, overwrite and Idempotency-Key claims and
+	// scheduled jobs are coordinated through leases in the store.
+	Cluster bool
+
+	// InboxDir enables the inbox watcher: files dropped there are imported
+	// as files of InboxOwner, checked every InboxInterval (default 10s)
+	InboxDir      string
+	InboxOwner    string
+	InboxInterval time.Duration
+
+	// Tenants enables the tenant provisioning API and tenant routing
+	Tenants bool
+
+	// TrustedProxies are the addresses and CIDR ranges of the reverse
+	// proxies whose X-Forwarded-For and X-Real-IP headers name the client,
+	// for audit, analytics and address restrictions, and whose
+	// X-Forwarded-Proto gives the scheme of links. Nil trusts loopback
+	// only and an empty list none. It applies to the routers the depot
+	// builds itself; pass TrustedProxies to the SetTrustedProxies of the
+	// router it is mounted on.
+	TrustedProxies []string
+
+	// DiagnosticsAddr serves the admin diagnostics (pprof, goroutine
+	// dumps, runtime and store statistics) on their own listener instead
+	// of under /api/admin/debug
+	DiagnosticsAddr string
+}
+
+// Depot is a configured depot with its background workers running.
+type Depot struct {
+	Handler *Handler
+
+	jobs      *jobs.Queue
+	scheduler *jobs.Scheduler
+	tenants   *tenant.Registry
+	mail      *mail.Server
+	events    *events.Bus
+	// diagnostics serves the diagnostics routes when DiagnosticsAddr is set
+	diagnostics    *http.Server
+	trustedProxies []string
+	// stopCommands ends the command queue consumer, which closes
+	// commandsDone when it returns
+	stopCommands context.CancelFunc
+	commandsDone chan struct{}
+	// redis is the Redis server of locks, caches and counters, if any
+	redis *redis.Client
+
+	// reloadMu serializes reloads and guards tenantHandlers, the API
+	// handlers built for tenants, which reloads reach as well
+	reloadMu       sync.Mutex
+	tenantHandlers map[string]*api.Handler
+}
+
+// connectRedis checks that the Redis server at rawURL answers.
+func connectRedis(rawURL string) (*redis.Client, error) {
+	rdb, err := redis.New(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("depot: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := rdb.Ping(ctx); err != nil {
+		rdb.Close()
+		return nil, fmt.Errorf("depot: connect to redis: %w", err)
+	}
+	return rdb, nil
+}
+
+// runOnce lets one replica sharing s run a scheduled job per interval: the
+// first to take the job's lease runs it, and the lease lapses shortly
+// before the next run is due.
+func runOnce(s db.CelerixStore, name string, interval time.Duration) bool {
+	_, ok, err := db.TryLock(s, "job:"+name, interval-interval/10)
+	if err != nil {
+		log.Printf("[ERROR] Failed to take the lease of job %s: %v", name, err)
+	}
+	return ok
+}
+
+// defaultTrustedProxies are the proxies trusted when Config.TrustedProxies
+// is nil: those on the same host.
+var defaultTrustedProxies = []string{"127.0.0.1", "::1"}
+
+// commandRetention is how long commands that succeeded are remembered,
+// and so how long their IDs protect against running them twice.
+const commandRetention = 7 * 24 * time.Hour
+
+func New(cfg Config) (*Depot, error) {
+	if cfg.Store == nil {
+		return nil, errors.New("depot: Store is required")
+	}
+	if cfg.StorageDir == "" {
+		return nil, errors.New("depot: StorageDir is required")
+	}
+	if cfg.Namespace == uuid.Nil {
+		return nil, errors.New("depot: Namespace is required")
+	}
+	if cfg.TrustedProxies == nil {
+		cfg.TrustedProxies = defaultTrustedProxies
+	}
+	for _, p := range cfg.TrustedProxies {
+		if _, err := netip.ParsePrefix(p); err != nil {
+			if _, err := netip.ParseAddr(p); err != nil {
+				return nil, fmt.Errorf("depot: trusted proxy %q is not an address or CIDR range", p)
+			}
+		}
+	}
+	if err := os.MkdirAll(cfg.StorageDir, 0755); err != nil {
+		return nil, fmt.Errorf("depot: create storage directory: %w", err)
+	}
+	if err := doctor.Writable(cfg.StorageDir); err != nil {
+		return nil, fmt.Errorf("depot: storage directory is not writable: %w", err)
+	}
+	if cfg.TempDir != "" {
+		if err := os.MkdirAll(cfg.TempDir, 0755); err != nil {
+			return nil, fmt.Errorf("depot: create temp directory: %w", err)
+		}
+		if err := doctor.Writable(cfg.TempDir); err != nil {
+			return nil, fmt.Errorf("depot: temp directory is not writable: %w", err)
+		}
+	}
+	// Counted below the cache, so the statistics show what reaches the
+	// store. File lookups go through GetGlobal, which scans every persona.
+	storeStats := storestats.Wrap(cfg.Store)
+	var rdb *redis.Client
+	if cfg.RedisURL != "" {
+		client, err := connectRedis(cfg.RedisURL)
+		if err != nil {
+			return nil, err
+		}
+		rdb = client
+		cfg.Store = storecache.WrapShared(storeStats, redis.Cache{Client: rdb})
+	} else {
+		cfg.Store = storecache.Wrap(storeStats)
+	}
+	// Record locks, request claims and owner names are shared with the
+	// replicas through the store's Locker and Cache, which belong to this
+	// depot alone
+	switch {
+	case rdb != nil:
+		cfg.Store = &db.SharedStore{CelerixStore: cfg.Store, Locker: redis.Locker{Client: rdb}, Cache: redis.Cache{Client: rdb}}
+	case cfg.Cluster:
+		cfg.Store = &db.SharedStore{CelerixStore: cfg.Store, Locker: db.NewStoreLocker(cfg.Store)}
+	}
+
+	// An older build would misread records a newer one wrote
+	if v := db.GetSchemaVersion(cfg.Store); v > db.SchemaVersion {
+		return nil, fmt.Errorf("depot: the store has schema %d but this build supports up to %d; run a newer depot", v, db.SchemaVersion)
+	} else if v < db.SchemaVersion {
+		if err := db.SaveSchemaVersion(cfg.Store, db.SchemaVersion); err != nil {
+			return nil, fmt.Errorf("depot: record store schema: %w", err)
+		}
+	}
+
+	var uploadPolicy *policy.Engine
+	if cfg.PolicyFile != "" {
+		var err error
+		uploadPolicy, err = policy.Load(cfg.PolicyFile)
+		if err != nil {
+			return nil, fmt.Errorf("depot: load upload policy: %w", err)
+		}
+		log.Printf("Loaded %d upload policy rules from %s", len(uploadPolicy.Rules), cfg.PolicyFile)
+	}
+
+	var s3Client *storage.S3Client
+	if cfg.S3 != nil {
+		var err error
+		s3Client, err = storage.NewS3Client(*cfg.S3)
+		if err != nil {
+			return nil, fmt.Errorf("depot: configure S3 backend: %w", err)
+		}
+	}
+
+	hookRegistry := &hooks.Registry{}
+	if cfg.HooksDir != "" {
+		timeout := cfg.HookTimeout
+		if timeout <= 0 {
+			timeout = 10 * time.Second
+		}
+		n, err := hookRegistry.LoadDir(cfg.HooksDir, timeout)
+		if err != nil {
+			return nil, fmt.Errorf("depot: load hooks: %w", err)
+		}
+		log.Printf("Loaded %d hooks from %s", n, cfg.HooksDir)
+	}
+	for _, h := range cfg.Hooks {
+		if !hookRegistry.Register(h) {
+			return nil, fmt.Errorf("depot: %T implements no hook interface", h)
+		}
+	}
+
+	var geo *geoip.DB
+	if cfg.GeoIPFile != "" {
+		var err error
+		if geo, err = geoip.Open(cfg.GeoIPFile); err != nil {
+			return nil, fmt.Errorf("depot: load GeoIP database: %w", err)
+		}
+		log.Printf("Loaded %d GeoIP ranges from %s", geo.Len(), cfg.GeoIPFile)
+	}
+
+	scrubber := &scrub.Scrubber{
+		Store:          cfg.Store,
+		BytesPerSecond: cfg.ScrubRate,
+		ReplicaDir:     cfg.ScrubReplicaDir,
+	}
+
+	var bus *events.Bus
+	if cfg.EventBusURL != "" {
+		pub, err := events.Open(cfg.EventBusURL)
+		if err != nil {
+			return nil, fmt.Errorf("depot: configure event bus: %w", err)
+		}
+		topic := cfg.EventTopic
+		if topic == "" {
+			topic = "depot.events"
+		}
+		bus = events.NewBus(pub, topic, strings.TrimSuffix(cfg.PublicURL, "/"), 1024)
+		// The URL may carry credentials
+		if u, err := url.Parse(cfg.EventBusURL); err == nil {
+			log.Printf("Publishing events to %s on %s://%s", topic, u.Scheme, u.Host)
+		}
+	}
+
+	var commands *events.Consumer
+	if cfg.CommandSubject != "" {
+		busURL := cfg.CommandBusURL
+		if busURL == "" {
+			busURL = cfg.EventBusURL
+		}
+		var err error
+		// Replicas share the commands through the queue group
+		if commands, err = events.NewConsumer(busURL, cfg.CommandSubject, "depot"); err != nil {
+			bus.Close()
+			return nil, fmt.Errorf("depot: configure command queue: %w", err)
+		}
+	}
+
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = 2
+	}
+
+	d := &Depot{
+		jobs:           jobs.NewQueue(workers, 256),
+		scheduler:      jobs.NewScheduler(),
+		events:         bus,
+		trustedProxies: cfg.TrustedProxies,
+	}
+	d.Handler = &api.Handler{
+		Store:            cfg.Store,
+		StorageDir:       cfg.StorageDir,
+		TempDir:          cfg.TempDir,
+		AdminSecret:      cfg.AdminSecret,
+		VersionConfig:    cfg.Version,
+		CelerixNamespace: cfg.Namespace,
+		Policy:           uploadPolicy,
+		Sanitizers:       sanitize.Default(),
+		CleanName:        cfg.CleanName,
+		StripMetadata:    cfg.StripMetadata,
+		ContentIndex:     cfg.ContentIndex,
+		AutoTag:          cfg.AutoTag,
+		Jobs:             d.jobs,
+		Scrubber:         scrubber,
+		S3:               s3Client,
+		Hooks:            hookRegistry,
+		GeoIP:            geo,
+		GeoIPHeader:      cfg.GeoIPHeader,
+		TrustedProxies:   cfg.TrustedProxies,
+		PublicURL:        strings.TrimSuffix(cfg.PublicURL, "/"),
+		ShortURL:         strings.TrimSuffix(cfg.ShortURL, "/"),
+		TorrentMinSize:   cfg.TorrentMinSize,
+		TorrentTrackers:  cfg.TorrentTrackers,
+		AdminWebhookURL:  cfg.AdminWebhookURL,
+		Mailer:           cfg.Mailer,
+		Events:           bus,
+		StoreStats:       storeStats,
+		InboxOwner:       cfg.InboxOwner,
+		RequestTimeout:   cfg.RequestTimeout,
+		TransferTimeout:  cfg.TransferTimeout,
+		EndpointTimeouts: cfg.EndpointTimeouts,
+		MaxBodyBytes:     cfg.MaxBodyBytes,
+	}
+	// The lanes are there without a limit too, so Reload can set one
+	d.Handler.Transfers = &api.TransferLanes{Max: cfg.MaxTransfers, SmallBytes: cfg.SmallTransferBytes}
+	d.Handler.RequestsPerMinute = cfg.RequestsPerMinute
+	if rdb != nil {
+		d.Handler.RateCounters = redis.Counters{Client: rdb}
+		d.redis = rdb
+	}
+	if cfg.Cluster {
+		d.scheduler.Guard = func(name string, interval time.Duration) bool {
+			return runOnce(cfg.Store, name, interval)
+		}
+	}
+	scrubber.Notify = func(r scrub.Result) {
+		d.Handler.RaiseAlert(db.AlertRecord{
+			ID:      "scrub-" + r.Hash,
+			Kind:    "scrub.corrupted",
+			Message: fmt.Sprintf("Integrity scrub found corrupted blob %s (%s), recovered: %v", r.Hash, r.StoredPath, r.Recovered),
+		})
+	}
+
+	if cfg.ScrubInterval > 0 {
+		d.scheduler.Every("scrub", cfg.ScrubInterval, d.Handler.Scrub)
+	}
+
+	retention := cfg.ChangeRetention
+	if retention <= 0 {
+		retention = 30 * 24 * time.Hour
+	}
+	d.scheduler.Every("journal", 24*time.Hour, func(ctx context.Context) error {
+		_, err := db.PruneChanges(cfg.Store, time.Now().Add(-retention))
+		return err
+	})
+
+	d.scheduler.Every("alerts", 5*time.Minute, d.Handler.CheckAlerts)
+	d.scheduler.Every("expiry-notices", time.Hour, d.Handler.NotifyExpiring)
+	d.scheduler.Every("link-rotation", time.Hour, d.Handler.RotateLinks)
+	d.scheduler.Every("access-logs", 24*time.Hour, d.Handler.PruneAccessLogs)
+	d.scheduler.Every("storage-history", 24*time.Hour, d.Handler.SnapshotStorage)
+	d.scheduler.Every("idempotency-keys", time.Hour, d.Handler.PruneIdempotencyKeys)
+	d.scheduler.Every("mirror", 15*time.Minute, d.Handler.RetryMirrors)
+	d.scheduler.Every("upload-sessions", 24*time.Hour, d.Handler.PruneUploadSessions)
+	d.scheduler.Every("commands", 24*time.Hour, func(ctx context.Context) error {
+		_, err := db.PruneCommands(cfg.Store, time.Now().Add(-commandRetention))
+		return err
+	})
+	d.scheduler.Every("guest-tokens", 24*time.Hour, func(ctx context.Context) error {
+		_, err := db.PruneGuestTokens(cfg.Store, time.Now())
+		return err
+	})
+
+	usageMonths := cfg.UsageMonths
+	if usageMonths <= 0 {
+		usageMonths = 24
+	}
+	d.scheduler.Every("usage", 24*time.Hour, func(ctx context.Context) error {
+		now := time.Now().UTC()
+		oldest := time.Date(now.Year(), now.Month()-time.Month(usageMonths-1), 1, 0, 0, 0, 0, time.UTC)
+		_, err := db.PruneUsage(cfg.Store, db.UsageMonth(oldest))
+		return err
+	})
+
+	if cfg.CompactInterval > 0 {
+		d.scheduler.Every("compact", cfg.CompactInterval, func(ctx context.Context) error {
+			report, err := db.Compact(cfg.Store)
+			if err == nil && report.KeysRemoved > 0 {
+				log.Printf("Compaction removed %d records, reclaiming %d bytes", report.KeysRemoved, report.Reclaimed)
+			}
+			return err
+		})
+	}
+
+	if cfg.SMTPAddr != "" {
+		d.mail = &mail.Server{
+			Addr:    cfg.SMTPAddr,
+			Domain:  cfg.SMTPDomain,
+			Accept:  d.Handler.AcceptsMail,
+			Deliver: d.Handler.ReceiveMail,
+		}
+		ln, err := net.Listen("tcp", cfg.SMTPAddr)
+		if err != nil {
+			d.Close()
+			return nil, fmt.Errorf("depot: start mail gateway: %w", err)
+		}
+		go d.mail.Serve(ln)
+		log.Printf("Mail gateway listening on %s", cfg.SMTPAddr)
+	}
+
+	if cfg.DiagnosticsAddr != "" {
+		engine := d.newEngine()
+		registerDiagnosticsRoutes(engine.Group("/debug"), d.Handler)
+		d.diagnostics = &http.Server{Addr: cfg.DiagnosticsAddr, Handler: engine}
+		ln, err := net.Listen("tcp", cfg.DiagnosticsAddr)
+		if err != nil {
+			d.Close()
+			return nil, fmt.Errorf("depot: start diagnostics listener: %w", err)
+		}
+		go d.diagnostics.Serve(ln)
+		log.Printf("Diagnostics listening on %s", cfg.DiagnosticsAddr)
+	}
+
+	if cfg.InboxDir != "" {
+		if err := os.MkdirAll(cfg.InboxDir, 0755); err != nil {
+			d.Close()
+			return nil, fmt.Errorf("depot: create inbox: %w", err)
+		}
+		if cfg.InboxOwner != "" {
+			if _, err := db.GetClient(cfg.Store, cfg.InboxOwner); err != nil {
+				d.Close()
+				return nil, fmt.Errorf("depot: inbox owner %s: %w", cfg.InboxOwner, err)
+			}
+		}
+		interval := cfg.InboxInterval
+		if interval <= 0 {
+			interval = 10 * time.Second
+		}
+		watcher := &inbox.Watcher{Dir: cfg.InboxDir, Deliver: d.Handler.ImportInboxFile}
+		d.scheduler.Every("inbox", interval, watcher.Poll)
+		log.Printf("Watching inbox %s", cfg.InboxDir)
+	}
+
+	if commands != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		d.stopCommands, d.commandsDone = cancel, make(chan struct{})
+		go func() {
+			defer close(d.commandsDone)
+			commands.Run(ctx, func(m events.Message) []byte {
+				return d.Handler.HandleCommand(ctx, m)
+			})
+		}()
+		log.Printf("Receiving commands on %s", cfg.CommandSubject)
+	}
+
+	if cfg.Tenants {
+		d.tenants = &tenant.Registry{Store: cfg.Store, Build: d.buildTenant}
+		d.Handler.Tenants = d.tenants
+	}
+
+	return d, nil
+}
+
+// Reload switches the running depot, tenants included, to the upload
+// policy, admin webhook, timeouts, body size, rate and transfer limits of
+// cfg; the rest of cfg is ignored.
+// Requests in flight finish under the settings they started with. If the
+// policy file fails to load nothing changes. Set Handler.ReloadConfig to a
+// function that reads the configuration and calls Reload to offer
+// POST /api/admin/config/reload.
+func (d *Depot) Reload(cfg Config) error {
+	var uploadPolicy *policy.Engine
+	if cfg.PolicyFile != "" {
+		var err error
+		uploadPolicy, err = policy.Load(cfg.PolicyFile)
+		if err != nil {
+			return fmt.Errorf("depot: load upload policy: %w", err)
+		}
+	}
+
+	d.reloadMu.Lock()
+	defer d.reloadMu.Unlock()
+	r := api.Reloadable{
+		Policy:             uploadPolicy,
+		AdminWebhookURL:    cfg.AdminWebhookURL,
+		RequestTimeout:     cfg.RequestTimeout,
+		TransferTimeout:    cfg.TransferTimeout,
+		EndpointTimeouts:   cfg.EndpointTimeouts,
+		MaxBodyBytes:       cfg.MaxBodyBytes,
+		RequestsPerMinute:  cfg.RequestsPerMinute,
+		MaxTransfers:       cfg.MaxTransfers,
+		SmallTransferBytes: cfg.SmallTransferBytes,
+	}
+	d.Handler.Reload(r)
+	// Tenants don't report to the admin webhook
+	r.AdminWebhookURL = ""
+	for _, th := range d.tenantHandlers {
+		th.Reload(r)
+	}
+	if uploadPolicy != nil {
+		log.Printf("Reloaded %d upload policy rules from %s", len(uploadPolicy.Rules), cfg.PolicyFile)
+	}
+	return nil
+}
+
+// buildTenant creates the API for a tenant. Tenants share the root
+// configuration but get their own slice of the store, their own storage
+// directory and their own admin secret.
+func (d *Depot) buildTenant(t db.TenantRecord) (http.Handler, error) {
+	h := d.Handler
+	dir := filepath.Join(h.StorageDir, "tenants", t.ID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	// Hold off reloads until the tenant is in tenantHandlers, so it starts
+	// with the settings they would give it
+	d.reloadMu.Lock()
+	th := &api.Handler{
+		Store:             tenant.Scope(h.Store, t.ID),
+		StorageDir:        dir,
+		TempDir:           h.TempDir,
+		AdminSecret:       t.AdminSecret,
+		VersionConfig:     h.VersionConfig,
+		CelerixNamespace:  h.CelerixNamespace,
+		Policy:            h.Policy,
+		Sanitizers:        h.Sanitizers,
+		CleanName:         h.CleanName,
+		StripMetadata:     h.StripMetadata,
+		ContentIndex:      h.ContentIndex,
+		AutoTag:           h.AutoTag,
+		Jobs:              h.Jobs,
+		S3:                h.S3,
+		Hooks:             h.Hooks,
+		GeoIP:             h.GeoIP,
+		GeoIPHeader:       h.GeoIPHeader,
+		TrustedProxies:    h.TrustedProxies,
+		ObjectPrefix:      "tenants/" + t.ID + "/",
+		QuotaBytes:        t.QuotaBytes,
+		PublicURL:         h.PublicURL,
+		Mailer:            h.Mailer,
+		Events:            h.Events.ForTenant(t.ID),
+		TorrentMinSize:    h.TorrentMinSize,
+		TorrentTrackers:   h.TorrentTrackers,
+		RequestTimeout:    h.RequestTimeout,
+		TransferTimeout:   h.TransferTimeout,
+		EndpointTimeouts:  h.EndpointTimeouts,
+		MaxBodyBytes:      h.MaxBodyBytes,
+		Transfers:         h.Transfers,
+		RequestsPerMinute: h.RequestsPerMinute,
+		RateCounters:      h.RateCounters,
+	}
+	if d.tenantHandlers == nil {
+		d.tenantHandlers = make(map[string]*api.Handler)
+	}
+	d.tenantHandlers[t.ID] = th
+	d.reloadMu.Unlock()
+
+	engine := d.newEngine()
+	registerRoutes(engine.Group("/api"), th)
+	registerSiteRoutes(engine, th)
+	return engine, nil
+}
+
+// TrustedProxies returns the proxies the depot takes client addresses
+// from, for the router it is mounted on.
+func (d *Depot) TrustedProxies() []string {
+	return d.trustedProxies
+}
+
+// newEngine returns a router for the depot's own listeners and tenants,
+// which takes client addresses from the trusted proxies only.
+func (d *Depot) newEngine() *gin.Engine {
+	engine := gin.New()
+	engine.Use(gin.Recovery())
+	// Validated in New
+	engine.SetTrustedProxies(d.trustedProxies)
+	return engine
+}
+
+// Mount registers the depot API under r, at r's path plus /api, and the
+// published static sites at r's path plus /sites.
+func (d *Depot) Mount(r gin.IRouter) {
+	apiGroup := r.Group("/api")
+	registerRoutes(apiGroup, d.Handler)
+	registerSiteRoutes(r, d.Handler)
+	if d.tenants != nil {
+		apiGroup.GET("/admin/tenants", d.Handler.ListTenants)
+		apiGroup.POST("/admin/tenants", d.Handler.CreateTenant)
+		apiGroup.GET("/admin/tenants/:id", d.Handler.GetTenant)
+		apiGroup.PUT("/admin/tenants/:id", d.Handler.UpdateTenant)
+		apiGroup.DELETE("/admin/tenants/:id", d.Handler.DeleteTenant)
+	}
+	if d.diagnostics == nil {
+		registerDiagnosticsRoutes(apiGroup.Group("/admin/debug"), d.Handler)
+	}
+}
+
+// MountGateway registers only the public, read-only download routes, for a
+// gateway that keeps download traffic away from the rest of the API.
+func (d *Depot) MountGateway(r gin.IRouter) {
+	apiGroup := r.Group("/api")
+	registerGatewayRoutes(apiGroup, d.Handler)
+	registerSiteRoutes(r, d.Handler)
+}
+
+// MountRegistry registers the OCI distribution API. Container clients expect
+// it at /v2/ on the root of the host, so r should not be a subpath group.
+func (d *Depot) MountRegistry(r gin.IRouter) {
+	for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPatch, http.MethodPut, http.MethodDelete} {
+		r.Handle(method, "/v2/*path", d.Handler.Registry)
+	}
+}
+
+// TenantMiddleware routes requests to tenants by host name or /t/<tenant>
+// prefix. It expects the depot to be mounted at the root of the router. It
+// passes everything through when tenants are disabled.
+func (d *Depot) TenantMiddleware() gin.HandlerFunc {
+	if d.tenants == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return d.tenants.Middleware()
+}
+
+// ShortLinkMiddleware serves short links from the root of the ShortURL
+// host. It passes everything through when no short domain is configured.
+func (d *Depot) ShortLinkMiddleware() gin.HandlerFunc {
+	return d.Handler.ShortDomain()
+}
+
+// Close stops the mail gateway, the diagnostics listener and the
+// scheduler, and waits for queued background jobs.
+func (d *Depot) Close() {
+	if d.mail != nil {
+		d.mail.Close()
+	}
+	if d.diagnostics != nil {
+		d.diagnostics.Close()
+	}
+	if d.stopCommands != nil {
+		d.stopCommands()
+		<-d.commandsDone
+	}
+	d.scheduler.Stop()
+	d.jobs.Close()
+	if d.redis != nil {
+		d.redis.Close()
+	}
+	// Last, so the events of finished jobs still go out
+	d.events.Close()
+}
@@ -0,0 +1,226 @@
+package depot
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/celerix-dev/celerix-store/pkg/engine"
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type denyAll struct{}
+
+func (denyAll) PreDownload(ctx context.Context, d Download) error {
+	return &Rejection{Hook: "deny", Reason: "closed"}
+}
+
+func TestMountUnderSubpath(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	if _, err := New(Config{StorageDir: t.TempDir()}); err == nil {
+		t.Fatal("expected missing store to be rejected")
+	}
+	if _, err := New(Config{Store: engine.NewMemStore(nil, nil), StorageDir: t.TempDir(), Namespace: uuid.New(), Hooks: []any{42}}); err == nil {
+		t.Fatal("expected a value without hook methods to be rejected")
+	}
+
+	d, err := New(Config{
+		Store:      engine.NewMemStore(nil, nil),
+		StorageDir: t.TempDir(),
+		Namespace:  uuid.New(),
+		Version:    []byte(`{"version":"test"}`),
+		Hooks:      []any{denyAll{}},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer d.Close()
+
+	r := gin.New()
+	d.Mount(r.Group("/depot"))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/depot/api/version", nil))
+	if w.Code != http.StatusOK || w.Body.String() != `{"version":"test"}` {
+		t.Errorf("unexpected version response %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/depot/api/admin/tenants", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("tenant API should not be mounted when tenants are disabled, got %d", w.Code)
+	}
+}
+
+func TestRejectsNewerSchema(t *testing.T) {
+	store := engine.NewMemStore(nil, nil)
+	db.SaveSchemaVersion(store, db.SchemaVersion+1)
+	if _, err := New(Config{Store: store, StorageDir: t.TempDir(), Namespace: uuid.New()}); err == nil {
+		t.Fatal("expected a store from a newer build to be rejected")
+	}
+
+	store = engine.NewMemStore(nil, nil)
+	d, err := New(Config{Store: store, StorageDir: t.TempDir(), Namespace: uuid.New()})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	d.Close()
+	if v := db.GetSchemaVersion(store); v != db.SchemaVersion {
+		t.Errorf("expected schema %d to be recorded, got %d", db.SchemaVersion, v)
+	}
+}
+
+func TestReload(t *testing.T) {
+	store := engine.NewMemStore(nil, nil)
+	d, err := New(Config{Store: store, StorageDir: t.TempDir(), Namespace: uuid.New(), Tenants: true})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer d.Close()
+	db.SaveTenant(store, db.TenantRecord{ID: "acme", Name: "Acme"})
+	if _, ok := d.tenants.Handler("acme"); !ok {
+		t.Fatal("expected the tenant handler to be built")
+	}
+
+	dir := t.TempDir()
+	rules := filepath.Join(dir, "policy.json")
+	os.WriteFile(rules, []byte(`{"rules": [{"name": "no-exe", "extensions": [".exe"], "action": "reject"}]}`), 0644)
+	err = d.Reload(Config{
+		PolicyFile: rules, AdminWebhookURL: "http://hooks.example", RequestTimeout: time.Minute,
+		MaxBodyBytes: 1 << 10, RequestsPerMinute: 60, MaxTransfers: 4,
+	})
+	if err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	acme := d.tenantHandlers["acme"]
+	for name, h := range map[string]*Handler{"root": d.Handler, "tenant": acme} {
+		if h.Policy == nil || len(h.Policy.Rules) != 1 || h.RequestTimeout != time.Minute {
+			t.Errorf("%s: expected the new policy and timeout, got %+v %s", name, h.Policy, h.RequestTimeout)
+		}
+		if h.MaxBodyBytes != 1<<10 || h.RequestsPerMinute != 60 || h.Transfers.Stats().Max != 4 {
+			t.Errorf("%s: expected the new limits, got %d bytes, %d requests and %+v", name, h.MaxBodyBytes, h.RequestsPerMinute, h.Transfers.Stats())
+		}
+	}
+	if d.Handler.AdminWebhookURL != "http://hooks.example" || acme.AdminWebhookURL != "" {
+		t.Errorf("expected only the root depot to get the admin webhook, got %q and %q", d.Handler.AdminWebhookURL, acme.AdminWebhookURL)
+	}
+
+	os.WriteFile(rules, []byte(`{"rules": [`), 0644)
+	if err := d.Reload(Config{PolicyFile: rules}); err == nil {
+		t.Error("expected a broken policy file to fail the reload")
+	}
+	if d.Handler.Policy == nil || d.Handler.RequestTimeout != time.Minute {
+		t.Error("expected a failed reload to keep the running configuration")
+	}
+}
+
+// Depots embedded in one process lock through their own Locker, which
+// closing another depot leaves alone.
+func TestDepotsKeepTheirLocks(t *testing.T) {
+	clustered, err := New(Config{Store: engine.NewMemStore(nil, nil), StorageDir: t.TempDir(), Namespace: uuid.New(), Cluster: true})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer clustered.Close()
+	single, err := New(Config{Store: engine.NewMemStore(nil, nil), StorageDir: t.TempDir(), Namespace: uuid.New()})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	if _, ok, _ := db.TryLock(single.Handler.Store, "job:test", time.Hour); !ok {
+		t.Fatal("expected a lease without a Locker to be granted")
+	}
+	if _, ok, _ := db.TryLock(single.Handler.Store, "job:test", time.Hour); !ok {
+		t.Error("a depot without a Locker got the leases of another")
+	}
+	single.Close()
+
+	if _, ok, err := db.TryLock(clustered.Handler.Store, "job:test", time.Hour); !ok {
+		t.Fatalf("TryLock: %v %v", ok, err)
+	}
+	if _, ok, _ := db.TryLock(clustered.Handler.Store, "job:test", time.Hour); ok {
+		t.Error("a held lease was taken again after another depot closed")
+	}
+}
+
+func TestGatewayServesDownloadsOnly(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	d, err := New(Config{Store: engine.NewMemStore(nil, nil), StorageDir: t.TempDir(), Namespace: uuid.New()})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer d.Close()
+
+	r := gin.New()
+	d.MountGateway(r)
+
+	tests := []struct {
+		method, path string
+		status       int
+	}{
+		{http.MethodGet, "/api/download/missing", http.StatusNotFound},
+		{http.MethodPost, "/api/upload", http.StatusNotFound},
+		{http.MethodGet, "/api/files", http.StatusNotFound},
+		{http.MethodGet, "/api/clients", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if w.Code != tt.status {
+			t.Errorf("%s %s: got %d, want %d", tt.method, tt.path, w.Code, tt.status)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/download/missing", nil))
+	if w.Body.String() != `{"error":"File not found"}` {
+		t.Errorf("download route should be served by the handler, got %s", w.Body.String())
+	}
+}
+
+func TestTrustedProxies(t *testing.T) {
+	newDepot := func(proxies []string) (*Depot, error) {
+		return New(Config{Store: engine.NewMemStore(nil, nil), StorageDir: t.TempDir(), Namespace: uuid.New(), TrustedProxies: proxies})
+	}
+	if _, err := newDepot([]string{"nginx"}); err == nil {
+		t.Error("expected a proxy that is not an address to be rejected")
+	}
+
+	d, err := newDepot(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if got := d.TrustedProxies(); len(got) != 2 || got[0] != "127.0.0.1" {
+		t.Errorf("expected loopback to be trusted by default, got %v", got)
+	}
+
+	d2, err := newDepot([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d2.Close()
+	engine := d2.newEngine()
+	var clientIP string
+	engine.GET("/ip", func(c *gin.Context) { clientIP = c.ClientIP() })
+	for _, c := range []struct{ remote, want string }{
+		{"10.1.2.3:1234", "203.0.113.7"},
+		{"198.51.100.1:1234", "198.51.100.1"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+		req.RemoteAddr = c.remote
+		req.Header.Set("X-Forwarded-For", "203.0.113.7")
+		engine.ServeHTTP(httptest.NewRecorder(), req)
+		if clientIP != c.want {
+			t.Errorf("from %s: expected client %s, got %s", c.remote, c.want, clientIP)
+		}
+	}
+}
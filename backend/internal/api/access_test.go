@@ -0,0 +1,72 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestListFilesViews(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+	clock := time.Now()
+	h.Now = func() time.Time { return clock }
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.GET("/download/:id", h.DownloadFile)
+	router.GET("/files", h.ListFiles)
+
+	often := uploadTestFile(t, router, "client-a", "often.txt", []byte("often"))
+	once := uploadTestFile(t, router, "client-a", "once.txt", []byte("once"))
+	uploadTestFile(t, router, "client-a", "never.txt", []byte("never"))
+
+	download := func(clientID string, file map[string]interface{}) {
+		t.Helper()
+		clock = clock.Add(time.Minute)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/download/"+file["download_link"].(string), nil)
+		req.Header.Set("X-Client-ID", clientID)
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("download: %d %s", w.Code, w.Body.String())
+		}
+	}
+	download("client-a", often)
+	download("client-a", often)
+	download("client-a", once)
+	download("client-b", often)
+
+	list := func(view string) (int, []string) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/files?view="+view, nil)
+		req.Header.Set("X-Client-ID", "client-a")
+		router.ServeHTTP(w, req)
+		var resp db.FileListResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		var names []string
+		for _, f := range resp.Files {
+			names = append(names, f.OriginalName)
+		}
+		return w.Code, names
+	}
+
+	if code, names := list("recent"); code != http.StatusOK || len(names) != 2 || names[0] != "once.txt" {
+		t.Errorf("recent = %d %v, want once.txt, often.txt", code, names)
+	}
+	if code, names := list("frequent"); code != http.StatusOK || len(names) != 2 || names[0] != "often.txt" {
+		t.Errorf("frequent = %d %v, want often.txt, once.txt", code, names)
+	}
+	if code, _ := list("popular"); code != http.StatusBadRequest {
+		t.Errorf("unknown view: got %d, want 400", code)
+	}
+	if a := db.GetClientAccess(h.Store, "client-b"); a.Files[often["id"].(string)].Count != 1 || len(a.Files) != 1 {
+		t.Errorf("client-b's activity = %+v", a.Files)
+	}
+}
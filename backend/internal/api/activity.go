@@ -0,0 +1,160 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	activityRecentUploads = 10
+	activityTopFiles      = 5
+	// activityMonths is how many months of transfer usage the trend covers
+	activityMonths = 6
+	// activityExpiryWindow is how far ahead expiring links are listed
+	activityExpiryWindow = 7 * 24 * time.Hour
+)
+
+type activityFile struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	Size         int64  `json:"size"`
+	Folder       string `json:"folder,omitempty"`
+	UploadTime   int64  `json:"upload_time"`
+	DownloadLink string `json:"download_link"`
+	ExpiresAt    int64  `json:"expires_at,omitempty"`
+	Downloads    int    `json:"downloads,omitempty"`
+}
+
+type activityDay struct {
+	Start     int64 `json:"start"`
+	Downloads int   `json:"downloads"`
+}
+
+type activityResponse struct {
+	RecentUploads []activityFile `json:"recent_uploads"`
+	Downloads     struct {
+		Days  int            `json:"days"`
+		Total int            `json:"total"`
+		Daily []activityDay  `json:"daily"`
+		Top   []activityFile `json:"top_files"`
+	} `json:"downloads"`
+	Storage struct {
+		UsedBytes int64            `json:"used_bytes"`
+		Files     int              `json:"files"`
+		Monthly   []db.UsageRecord `json:"monthly"`
+	} `json:"storage"`
+	Expiring []activityFile `json:"expiring"`
+}
+
+func newActivityFile(r db.FileRecord) activityFile {
+	return activityFile{
+		ID:           r.ID,
+		Name:         r.OriginalName,
+		Size:         r.Size,
+		Folder:       r.Folder,
+		UploadTime:   r.UploadTime,
+		DownloadLink: r.DownloadLink,
+		ExpiresAt:    r.ExpiresAt,
+	}
+}
+
+// GetActivity summarizes the caller's recent activity for the home screen:
+// their latest uploads, downloads of their files over the last ?days=
+// (default 30), their storage and monthly transfer volume, and links that
+// expire within a week.
+func (h *Handler) GetActivity(c *gin.Context) {
+	ownerID := c.GetHeader("X-Client-ID")
+	if ownerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
+		return
+	}
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days < 1 || days > maxAnalyticsDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "days must be between 1 and 366"})
+		return
+	}
+
+	files, err := db.ListOwnerFiles(h.Store, ownerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list files"})
+		return
+	}
+	now := h.now()
+	resp := activityResponse{RecentUploads: []activityFile{}, Expiring: []activityFile{}}
+
+	ids := make(map[string]bool, len(files))
+	byID := make(map[string]db.FileRecord, len(files))
+	for _, f := range files {
+		ids[f.ID] = true
+		byID[f.ID] = f
+		resp.Storage.UsedBytes += f.Size
+		if f.ExpiresAt > now.Unix() && f.ExpiresAt <= now.Add(activityExpiryWindow).Unix() {
+			resp.Expiring = append(resp.Expiring, newActivityFile(f))
+		}
+	}
+	resp.Storage.Files = len(files)
+	sort.Slice(resp.Expiring, func(i, j int) bool { return resp.Expiring[i].ExpiresAt < resp.Expiring[j].ExpiresAt })
+
+	sort.Slice(files, func(i, j int) bool { return files[i].UploadTime > files[j].UploadTime })
+	for _, f := range files[:min(len(files), activityRecentUploads)] {
+		resp.RecentUploads = append(resp.RecentUploads, newActivityFile(f))
+	}
+
+	perFile, perDay, err := db.DownloadTotals(h.Store, ids, now.AddDate(0, 0, -days))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load analytics"})
+		return
+	}
+	resp.Downloads.Days = days
+	resp.Downloads.Daily = make([]activityDay, 0, len(perDay))
+	for start, n := range perDay {
+		resp.Downloads.Total += n
+		resp.Downloads.Daily = append(resp.Downloads.Daily, activityDay{Start: start, Downloads: n})
+	}
+	sort.Slice(resp.Downloads.Daily, func(i, j int) bool { return resp.Downloads.Daily[i].Start < resp.Downloads.Daily[j].Start })
+	resp.Downloads.Top = []activityFile{}
+	for id, n := range perFile {
+		f := newActivityFile(byID[id])
+		f.Downloads = n
+		resp.Downloads.Top = append(resp.Downloads.Top, f)
+	}
+	sort.Slice(resp.Downloads.Top, func(i, j int) bool {
+		a, b := resp.Downloads.Top[i], resp.Downloads.Top[j]
+		if a.Downloads != b.Downloads {
+			return a.Downloads > b.Downloads
+		}
+		return a.ID < b.ID
+	})
+	resp.Downloads.Top = resp.Downloads.Top[:min(len(resp.Downloads.Top), activityTopFiles)]
+
+	// Transfer volume of the last months, oldest first, with empty months
+	// filled in so the trend has no gaps
+	usage, err := db.ListUsage(h.Store, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list usage"})
+		return
+	}
+	byMonth := map[string]db.UsageRecord{}
+	for _, u := range usage {
+		if u.ClientID == ownerID {
+			byMonth[u.Month] = u
+		}
+	}
+	utc := now.UTC()
+	first := time.Date(utc.Year(), utc.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for i := activityMonths - 1; i >= 0; i-- {
+		month := db.UsageMonth(first.AddDate(0, -i, 0))
+		u, ok := byMonth[month]
+		if !ok {
+			u = db.UsageRecord{Month: month, ClientID: ownerID}
+		}
+		resp.Storage.Monthly = append(resp.Storage.Monthly, u)
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
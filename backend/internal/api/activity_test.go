@@ -0,0 +1,61 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetActivity(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.GET("/download/:id", h.DownloadFile)
+	router.GET("/persona/activity", h.GetActivity)
+
+	first := uploadTestFile(t, router, "client-a", "first.txt", []byte("first"))
+	second := uploadTestFile(t, router, "client-a", "second.txt", []byte("second file"))
+	uploadTestFile(t, router, "client-b", "other.txt", []byte("not mine"))
+	expiring, _ := db.GetFileRecord(h.Store, second["id"].(string))
+	expiring.ExpiresAt = time.Now().Add(48 * time.Hour).Unix()
+	db.SaveFileRecord(h.Store, *expiring)
+
+	for _, link := range []string{second["download_link"].(string), second["download_link"].(string), first["download_link"].(string)} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/download/"+link, nil)
+		router.ServeHTTP(w, req)
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/persona/activity", nil)
+	req.Header.Set("X-Client-ID", "client-a")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected activity, got %d %s", w.Code, w.Body.String())
+	}
+	var resp activityResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+
+	if len(resp.RecentUploads) != 2 || resp.Storage.Files != 2 || resp.Storage.UsedBytes != 16 {
+		t.Errorf("expected only the caller's two files, got %+v", resp.Storage)
+	}
+	if resp.Downloads.Total != 3 || len(resp.Downloads.Daily) != 1 {
+		t.Errorf("expected 3 downloads today, got %+v", resp.Downloads)
+	}
+	if len(resp.Downloads.Top) != 2 || resp.Downloads.Top[0].Name != "second.txt" || resp.Downloads.Top[0].Downloads != 2 {
+		t.Errorf("expected second.txt as top file, got %+v", resp.Downloads.Top)
+	}
+	if len(resp.Expiring) != 1 || resp.Expiring[0].Name != "second.txt" {
+		t.Errorf("expected second.txt to be expiring, got %+v", resp.Expiring)
+	}
+	if n := len(resp.Storage.Monthly); n != activityMonths || resp.Storage.Monthly[n-1].UploadBytes != 16 || resp.Storage.Monthly[n-1].DownloadBytes != 27 {
+		t.Errorf("expected this month's usage last, got %+v", resp.Storage.Monthly)
+	}
+}
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/notify"
+	"github.com/celerix/depot/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// volumeThresholds are the fill levels of the storage volume that raise an
+// alert, highest first.
+var volumeThresholds = []int{95, 90, 80}
+
+// quotaThreshold is the share of the depot's quota that raises an alert.
+const quotaThreshold = 90
+
+// resolvedAlertRetention is how long resolved alerts stay listed.
+const resolvedAlertRetention = 30 * 24 * time.Hour
+
+// RaiseAlert records the alert and tells the admin webhook when it is new
+// or has escalated, which it reports.
+func (h *Handler) RaiseAlert(alert db.AlertRecord) bool {
+	a, changed, err := db.RaiseAlert(h.Store, alert, h.now())
+	if err != nil {
+		log.Printf("[ERROR] Failed to record alert %s: %v", alert.ID, err)
+		return false
+	}
+	if !changed {
+		return false
+	}
+	if err := notify.Webhook(h.adminWebhookURL(), notify.Message{Event: a.Kind, Text: a.Message, Data: a}); err != nil {
+		log.Printf("[ERROR] Failed to notify admins: %v", err)
+	}
+	return true
+}
+
+// ClearAlert resolves the alert once its condition no longer holds.
+func (h *Handler) ClearAlert(id string) {
+	if _, err := db.ResolveAlert(h.Store, id, "", h.now()); err != nil {
+		log.Printf("[ERROR] Failed to resolve alert %s: %v", id, err)
+	}
+}
+
+// CheckAlerts raises or clears the threshold alerts: how full the storage
+// volume is and how much of the depot's quota is used. Alerts resolved long
+// ago are dropped.
+func (h *Handler) CheckAlerts(ctx context.Context) error {
+	if h.S3 == nil {
+		h.checkVolume()
+	}
+	h.checkQuota()
+	_, err := db.PruneAlerts(h.Store, h.now().Add(-resolvedAlertRetention))
+	return err
+}
+
+func (h *Handler) checkVolume() {
+	usage := h.volumeUsage
+	if usage == nil {
+		usage = storage.VolumeUsage
+	}
+	total, free, err := usage(h.StorageDir)
+	if err != nil || total == 0 {
+		return
+	}
+	used := int((total - free) * 100 / total)
+	for _, level := range volumeThresholds {
+		if used >= level {
+			h.RaiseAlert(db.AlertRecord{
+				ID:      "volume",
+				Kind:    "storage.volume",
+				Level:   level,
+				Message: fmt.Sprintf("The storage volume is %d%% full, %s left", used, humanSize(int64(free))),
+			})
+			return
+		}
+	}
+	h.ClearAlert("volume")
+}
+
+func (h *Handler) checkQuota() {
+	quota := h.QuotaBytes
+	if quota <= 0 {
+		quota = db.GetSettings(h.Store).QuotaBytes
+	}
+	if quota <= 0 {
+		h.ClearAlert("quota")
+		return
+	}
+	used := db.StorageUsed(h.Store)
+	if used*100 < quota*quotaThreshold {
+		h.ClearAlert("quota")
+		return
+	}
+	level := quotaThreshold
+	if used >= quota {
+		level = 100
+	}
+	message := fmt.Sprintf("The depot uses %d%% of its %s quota", used*100/quota, humanSize(quota))
+	raised := h.RaiseAlert(db.AlertRecord{
+		ID:      "quota",
+		Kind:    "storage.quota",
+		Level:   level,
+		Message: message,
+	})
+	// Clients hear about it too, since their uploads are what will fail
+	if raised {
+		h.notifyAll(db.EventQuotaWarning, message, gin.H{"level": level, "used_bytes": used, "quota_bytes": quota})
+	}
+}
+
+// AdminListAlerts lists the active alerts, or those with ?status=open,
+// acknowledged, resolved or all.
+func (h *Handler) AdminListAlerts(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	status := c.Query("status")
+	switch status {
+	case "", "all", db.AlertOpen, db.AlertAcknowledged, db.AlertResolved:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be open, acknowledged, resolved or all"})
+		return
+	}
+	alerts, err := db.ListAlerts(h.Store, status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list alerts"})
+		return
+	}
+	c.JSON(http.StatusOK, alerts)
+}
+
+func (h *Handler) AdminAcknowledgeAlert(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	alert, err := db.AcknowledgeAlert(h.Store, c.Param("id"), c.GetHeader("X-Client-ID"), h.now())
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert not found"})
+		return
+	}
+	c.JSON(http.StatusOK, alert)
+}
+
+// AdminResolveAlert closes an alert by hand. A threshold alert whose
+// condition still holds is raised again by the next check.
+func (h *Handler) AdminResolveAlert(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	id := c.Param("id")
+	resolved, err := db.ResolveAlert(h.Store, id, c.GetHeader("X-Client-ID"), h.now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve alert"})
+		return
+	}
+	if !resolved {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No active alert with this ID"})
+		return
+	}
+	alert, _ := db.GetAlert(h.Store, id)
+	c.JSON(http.StatusOK, alert)
+}
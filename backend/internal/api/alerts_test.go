@@ -0,0 +1,119 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/notify"
+	"github.com/gin-gonic/gin"
+)
+
+func TestAlerts(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	var mu sync.Mutex
+	var events []string
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg notify.Message
+		json.NewDecoder(r.Body).Decode(&msg)
+		mu.Lock()
+		events = append(events, msg.Event)
+		mu.Unlock()
+	}))
+	defer hook.Close()
+	h.AdminWebhookURL = hook.URL
+
+	var free uint64 = 50
+	h.volumeUsage = func(string) (uint64, uint64, error) { return 100, free, nil }
+
+	router := gin.Default()
+	router.GET("/admin/alerts", h.AdminListAlerts)
+	router.POST("/admin/alerts/:id/acknowledge", h.AdminAcknowledgeAlert)
+	router.POST("/admin/alerts/:id/resolve", h.AdminResolveAlert)
+	db.UpsertClient(h.Store, "admin", "Admin", "CODEA", 0)
+	db.UpdateClientAdminStatus(h.Store, "admin", true)
+
+	do := func(method, path string) (int, []byte) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		req.Header.Set("X-Client-ID", "admin")
+		router.ServeHTTP(w, req)
+		return w.Code, w.Body.Bytes()
+	}
+	active := func() []db.AlertRecord {
+		_, body := do("GET", "/admin/alerts")
+		var alerts []db.AlertRecord
+		json.Unmarshal(body, &alerts)
+		return alerts
+	}
+	check := func() {
+		if err := h.CheckAlerts(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	check()
+	if len(active()) != 0 {
+		t.Fatal("expected no alerts on a half-empty volume")
+	}
+
+	// Crossing thresholds raises once per level
+	free = 15
+	check()
+	check()
+	free = 8
+	check()
+	alerts := active()
+	if len(alerts) != 1 || alerts[0].ID != "volume" || alerts[0].Level != 90 {
+		t.Fatalf("expected one volume alert at 90%%, got %+v", alerts)
+	}
+	mu.Lock()
+	if len(events) != 2 || events[0] != "storage.volume" {
+		t.Errorf("expected a notification per level, got %v", events)
+	}
+	mu.Unlock()
+
+	if code, _ := do("POST", "/admin/alerts/volume/acknowledge"); code != http.StatusOK {
+		t.Fatalf("expected the acknowledge to succeed, got %d", code)
+	}
+	if a, _ := db.GetAlert(h.Store, "volume"); a.Status != db.AlertAcknowledged || a.AcknowledgedBy != "admin" {
+		t.Errorf("expected the alert to be acknowledged, got %+v", a)
+	}
+
+	// The alert clears itself when the condition goes away
+	free = 40
+	check()
+	if a, _ := db.GetAlert(h.Store, "volume"); a.Status != db.AlertResolved || a.ResolvedBy != "" {
+		t.Errorf("expected the alert to resolve by itself, got %+v", a)
+	}
+
+	// The depot quota
+	db.SaveSettings(h.Store, db.Settings{QuotaBytes: 100})
+	db.AcquireBlob(h.Store, "abc", 95, "/dev/null")
+	check()
+	if a, err := db.GetAlert(h.Store, "quota"); err != nil || a.Status != db.AlertOpen {
+		t.Fatalf("expected a quota alert, got %+v", a)
+	}
+	if code, _ := do("POST", "/admin/alerts/quota/resolve"); code != http.StatusOK {
+		t.Errorf("expected the resolve to succeed, got %d", code)
+	}
+	if code, _ := do("POST", "/admin/alerts/quota/resolve"); code != http.StatusNotFound {
+		t.Errorf("expected a second resolve to find nothing, got %d", code)
+	}
+
+	_, body := do("GET", "/admin/alerts?status=resolved")
+	var resolved []db.AlertRecord
+	json.Unmarshal(body, &resolved)
+	if len(resolved) != 2 {
+		t.Errorf("expected both alerts in the resolved list, got %+v", resolved)
+	}
+	if code, _ := do("GET", "/admin/alerts?status=bogus"); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown status, got %d", code)
+	}
+}
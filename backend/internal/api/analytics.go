@@ -0,0 +1,194 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+const maxAnalyticsDays = 366
+
+// agentClass reduces a user agent to a coarse class, which is all the
+// analytics keep.
+func agentClass(ua string) string {
+	ua = strings.ToLower(ua)
+	switch {
+	case ua == "":
+		return "unknown"
+	case strings.Contains(ua, "bot") || strings.Contains(ua, "crawler") || strings.Contains(ua, "spider") ||
+		strings.Contains(ua, "slurp") || strings.Contains(ua, "preview"):
+		return "bot"
+	case strings.HasPrefix(ua, "curl/") || strings.HasPrefix(ua, "wget/") || strings.Contains(ua, "httpie") ||
+		strings.HasPrefix(ua, "go-http-client") || strings.HasPrefix(ua, "python-") || strings.Contains(ua, "aria2"):
+		return "cli"
+	case strings.Contains(ua, "mobile") || strings.Contains(ua, "android") || strings.Contains(ua, "iphone"):
+		return "mobile"
+	case strings.HasPrefix(ua, "mozilla/"):
+		return "browser"
+	}
+	return "other"
+}
+
+// referrerHost keeps only the host of the referring page.
+func referrerHost(ref string) string {
+	if ref == "" {
+		return "direct"
+	}
+	u, err := url.Parse(ref)
+	if err != nil || u.Host == "" {
+		return "unknown"
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+func (h *Handler) clientCountry(c *gin.Context) string {
+	country := ""
+	if h.GeoIPHeader != "" {
+		country = strings.ToUpper(strings.TrimSpace(c.GetHeader(h.GeoIPHeader)))
+	}
+	if country == "" {
+		country = h.GeoIP.Country(c.ClientIP())
+	}
+	if len(country) != 2 {
+		return "unknown"
+	}
+	return country
+}
+
+// recordDownload counts a download for the analytics, the owner's
+// bandwidth usage and the downloading client's activity. Follow-up range
+// requests of the same transfer add to the bandwidth but are not counted
+// again as downloads.
+func (h *Handler) recordDownload(c *gin.Context, record *db.FileRecord) {
+	if err := db.RecordUsage(h.Store, record.OwnerID, h.now(), 0, rangeLength(c.GetHeader("Range"), record.Size)); err != nil {
+		log.Printf("[ERROR] Failed to record usage of %s: %v", record.ID, err)
+	}
+	if r := c.GetHeader("Range"); r != "" && !strings.HasPrefix(r, "bytes=0-") {
+		return
+	}
+	if clientID := c.GetHeader("X-Client-ID"); clientID != "" {
+		if err := db.RecordAccess(h.Store, clientID, record.ID, h.now().Unix()); err != nil {
+			log.Printf("[ERROR] Failed to record access to %s: %v", record.ID, err)
+		}
+	}
+	country := h.clientCountry(c)
+	err := db.RecordDownload(h.Store, db.DownloadEvent{
+		FileID:   record.ID,
+		Time:     time.Now(),
+		Country:  country,
+		Referrer: referrerHost(c.GetHeader("Referer")),
+		Agent:    agentClass(c.GetHeader("User-Agent")),
+		Address:  h.loggedAddress(c.ClientIP()),
+	})
+	if err != nil {
+		log.Printf("[ERROR] Failed to record download of %s: %v", record.ID, err)
+	}
+	h.notifyDownloaded(c, record, country)
+}
+
+type analyticsResponse struct {
+	FileID    string         `json:"file_id"`
+	Bucket    string         `json:"bucket"`
+	Downloads int            `json:"downloads"`
+	Countries map[string]int `json:"countries"`
+	Referrers map[string]int `json:"referrers"`
+	Agents    map[string]int `json:"agents"`
+	// Addresses is only filled when the settings keep client addresses
+	Addresses map[string]int       `json:"addresses,omitempty"`
+	Buckets   []db.AnalyticsBucket `json:"buckets"`
+}
+
+func addCounts(dst, src map[string]int) {
+	for k, v := range src {
+		dst[k] += v
+	}
+}
+
+// GetFileAnalytics returns the owner's download statistics for a file,
+// in hourly or daily buckets.
+func (h *Handler) GetFileAnalytics(c *gin.Context) {
+	record, err := db.GetFileRecord(h.Store, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	if record.OwnerID != c.GetHeader("X-Client-ID") && !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to view this file's analytics"})
+		return
+	}
+
+	bucket := c.DefaultQuery("bucket", "day")
+	if bucket != "day" && bucket != "hour" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bucket must be hour or day"})
+		return
+	}
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days < 1 || days > maxAnalyticsDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "days must be between 1 and 366"})
+		return
+	}
+
+	hourly, err := db.GetAnalytics(h.Store, record.ID, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load analytics"})
+		return
+	}
+
+	resp := analyticsResponse{
+		FileID:    record.ID,
+		Bucket:    bucket,
+		Countries: map[string]int{},
+		Referrers: map[string]int{},
+		Agents:    map[string]int{},
+		Buckets:   []db.AnalyticsBucket{},
+	}
+	for _, b := range hourly {
+		resp.Downloads += b.Downloads
+		addCounts(resp.Countries, b.Countries)
+		addCounts(resp.Referrers, b.Referrers)
+		addCounts(resp.Agents, b.Agents)
+		if len(b.Addresses) > 0 {
+			if resp.Addresses == nil {
+				resp.Addresses = map[string]int{}
+			}
+			addCounts(resp.Addresses, b.Addresses)
+		}
+
+		if bucket == "day" {
+			start := time.Unix(b.Start, 0).UTC().Truncate(24 * time.Hour).Unix()
+			if n := len(resp.Buckets); n > 0 && resp.Buckets[n-1].Start == start {
+				last := &resp.Buckets[n-1]
+				last.Downloads += b.Downloads
+				addCounts(last.Countries, b.Countries)
+				addCounts(last.Referrers, b.Referrers)
+				addCounts(last.Agents, b.Agents)
+				if len(b.Addresses) > 0 {
+					if last.Addresses == nil {
+						last.Addresses = map[string]int{}
+					}
+					addCounts(last.Addresses, b.Addresses)
+				}
+				continue
+			}
+			day := db.AnalyticsBucket{Start: start, Downloads: b.Downloads,
+				Countries: map[string]int{}, Referrers: map[string]int{}, Agents: map[string]int{}}
+			addCounts(day.Countries, b.Countries)
+			addCounts(day.Referrers, b.Referrers)
+			addCounts(day.Agents, b.Agents)
+			if len(b.Addresses) > 0 {
+				day.Addresses = map[string]int{}
+				addCounts(day.Addresses, b.Addresses)
+			}
+			b = day
+		}
+		resp.Buckets = append(resp.Buckets, b)
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
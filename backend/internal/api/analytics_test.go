@@ -0,0 +1,71 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/celerix/depot/internal/geoip"
+	"github.com/gin-gonic/gin"
+)
+
+func TestFileAnalytics(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+	h.GeoIP, _ = geoip.Load(strings.NewReader("192.0.2.0,192.0.2.255,DE\n"))
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.GET("/download/:id", h.DownloadFile)
+	router.GET("/files/:id/analytics", h.GetFileAnalytics)
+
+	rec := uploadTestFile(t, router, "client-a", "data.csv", []byte("a,b\n1,2\n"))
+	download := func(ua, referer, rangeHeader string) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/download/"+rec["download_link"].(string), nil)
+		req.RemoteAddr = "192.0.2.10:1234"
+		req.Header.Set("User-Agent", ua)
+		req.Header.Set("Referer", referer)
+		if rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+		router.ServeHTTP(w, req)
+	}
+	download("Mozilla/5.0 (X11; Linux x86_64)", "https://news.example.com/post/1?utm=x", "")
+	download("curl/8.5.0", "", "")
+	download("curl/8.5.0", "", "bytes=4-")
+
+	get := func(clientID, query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/files/"+rec["id"].(string)+"/analytics"+query, nil)
+		req.Header.Set("X-Client-ID", clientID)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := get("client-b", ""); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for another client, got %d", w.Code)
+	}
+
+	var resp analyticsResponse
+	w := get("client-a", "?bucket=hour")
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Downloads != 2 {
+		t.Fatalf("expected 2 downloads (range continuation not counted), got %s", w.Body.String())
+	}
+	if resp.Countries["DE"] != 2 || resp.Referrers["news.example.com"] != 1 || resp.Referrers["direct"] != 1 {
+		t.Errorf("unexpected breakdown: %s", w.Body.String())
+	}
+	if resp.Agents["browser"] != 1 || resp.Agents["cli"] != 1 || len(resp.Buckets) != 1 {
+		t.Errorf("unexpected agents or buckets: %s", w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "192.0.2.10") {
+		t.Errorf("expected client addresses not to be stored")
+	}
+
+	if w := get("client-a", "?bucket=week"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for unknown bucket, got %d", w.Code)
+	}
+}
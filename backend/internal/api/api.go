@@ -1,16 +1,35 @@
 package api
 
 import (
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"io"
 	"log"
+	"mime"
 	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/celerix-dev/celerix-store/pkg/sdk"
 	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/events"
+	"github.com/celerix/depot/internal/geoip"
+	"github.com/celerix/depot/internal/hooks"
+	"github.com/celerix/depot/internal/jobs"
+	"github.com/celerix/depot/internal/notify"
+	"github.com/celerix/depot/internal/policy"
+	"github.com/celerix/depot/internal/sanitize"
+	"github.com/celerix/depot/internal/scrub"
 	"github.com/celerix/depot/internal/storage"
+	"github.com/celerix/depot/internal/storestats"
+	"github.com/celerix/depot/internal/tenant"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
@@ -18,17 +37,143 @@ import (
 type CelerixStore = sdk.CelerixStore
 
 type Handler struct {
-	Store            CelerixStore
-	StorageDir       string
+	Store      CelerixStore
+	StorageDir string
+	// TempDir stages uploads before they move into StorageDir; empty
+	// stages in StorageDir
+	TempDir          string
 	AdminSecret      string
 	VersionConfig    []byte
 	CelerixNamespace uuid.UUID
+	Policy           *policy.Engine
+	Sanitizers       sanitize.Pipeline
+	// CleanName sanitizes the names of uploaded files; nil means
+	// sanitize.Filename
+	CleanName     sanitize.FilenameFunc
+	StripMetadata bool
+	ContentIndex  bool
+	AutoTag       bool
+	Jobs          *jobs.Queue
+	Scrubber      *scrub.Scrubber
+	S3            *storage.S3Client
+	// ObjectPrefix namespaces keys in the S3 bucket, e.g. per tenant
+	ObjectPrefix string
+	// QuotaBytes caps the total stored size; zero means unlimited
+	QuotaBytes int64
+	// Tenants is set on the root depot only and enables tenant provisioning
+	Tenants *tenant.Registry
+	Hooks   *hooks.Registry
+	GeoIP   *geoip.DB
+	// GeoIPHeader names a country header set by a trusted proxy or CDN,
+	// e.g. CF-IPCountry, which takes precedence over the GeoIP database
+	GeoIPHeader string
+	// TrustedProxies are the addresses and CIDR ranges of the reverse
+	// proxies whose X-Forwarded-Proto is believed
+	TrustedProxies []string
+	PublicURL      string
+	// ShortURL is the base of the short domain that serves short links
+	ShortURL string
+	// AdminWebhookURL receives alerts as JSON
+	AdminWebhookURL string
+	// Mailer delivers notification email; nil disables email
+	Mailer *notify.Mailer
+	// Events publishes file lifecycle events to a message bus; nil
+	// disables them
+	Events *events.Bus
+	// RequestTimeout bounds API requests and TransferTimeout those that
+	// move file content; EndpointTimeouts overrides both per route, keyed
+	// like "POST /api/upload". Zero means no limit.
+	RequestTimeout   time.Duration
+	TransferTimeout  time.Duration
+	EndpointTimeouts map[string]time.Duration
+	// MaxBodyBytes bounds request bodies other than file content; zero
+	// means 4 MiB
+	MaxBodyBytes int64
+	// Transfers bounds the uploads and downloads running at once and
+	// queues the rest by priority; nil or a Max of zero means no limit
+	Transfers *TransferLanes
+	// RequestsPerMinute limits the API requests of each client; zero
+	// means no limit
+	RequestsPerMinute int
+	// RateCounters shares the request counts between replicas; nil counts
+	// in the process
+	RateCounters RateCounters
+	// InboxOwner owns the files imported from the inbox directory; empty
+	// makes them system files
+	InboxOwner string
+	// Now is the clock for time-based link rules (expiry, embargo); nil
+	// means time.Now
+	Now             func() time.Time
+	TorrentMinSize  int64
+	TorrentTrackers []string
+	// StoreStats counts the store operations for the diagnostics; nil
+	// leaves them out
+	StoreStats *storestats.Store
+	// ReloadConfig applies the configuration anew for
+	// POST /api/admin/config/reload; nil disables reloading
+	ReloadConfig func() error
+
+	maintenance maintenanceGate
+	traffic     routeTraffic
+	rates       localCounters
+	idempotency claimSet
+	// names holds the file names claimed by uploads with an overwrite mode
+	// and by writes of the sync API
+	names claimSet
+	// configMu guards the fields Reload changes
+	configMu sync.RWMutex
+	// volumeUsage is storage.VolumeUsage when nil
+	volumeUsage func(dir string) (total, free uint64, err error)
+	// publishMu keeps two publishes of the same artifact version apart
+	publishMu sync.Mutex
+	// registryMu keeps the registry's blob references consistent
+	registryMu sync.Mutex
+	// remoteListings caches what remote depots list
+	remoteListings remoteListings
 }
 
 func (h *Handler) GetVersion(c *gin.Context) {
 	c.Data(http.StatusOK, "application/json", h.VersionConfig)
 }
 
+func (h *Handler) cleanName(name string) string {
+	if h.CleanName != nil {
+		return h.CleanName(name)
+	}
+	return sanitize.Filename(name)
+}
+
+// tempDir is where uploads are written until they are complete.
+func (h *Handler) tempDir() string {
+	if h.TempDir != "" {
+		return h.TempDir
+	}
+	return h.StorageDir
+}
+
+func (h *Handler) now() time.Time {
+	if h.Now != nil {
+		return h.Now()
+	}
+	return time.Now()
+}
+
+// parseTimestamp accepts a Unix timestamp or an RFC 3339 time for the named
+// field; empty means unset.
+func parseTimestamp(field, v string) (int64, error) {
+	if v == "" {
+		return 0, nil
+	}
+	if ts, err := strconv.ParseInt(v, 10, 64); err == nil && ts >= 0 {
+		return ts, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return 0, errors.New(field + " must be a Unix timestamp or an RFC 3339 time")
+	}
+	return t.Unix(), nil
+}
+
 func (h *Handler) isAdmin(c *gin.Context) bool {
 	ownerID := c.GetHeader("X-Client-ID")
 	if ownerID == "" {
@@ -184,56 +329,77 @@ func (h *Handler) UpdateClientName(c *gin.Context) {
 }
 
 func (h *Handler) UploadFile(c *gin.Context) {
+	record, ok := h.receiveFormUpload(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, record)
+}
+
+// receiveFormUpload ingests the multipart "file" field. It writes the error
+// response itself and returns false on failure.
+func (h *Handler) receiveFormUpload(c *gin.Context) (*db.FileRecord, bool) {
 	file, header, err := c.Request.FormFile("file")
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No file is received"})
-		return
+		return nil, false
 	}
 	defer file.Close()
 
 	ownerID := c.GetHeader("X-Client-ID")
 	if ownerID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
-		return
+		return nil, false
+	}
+
+	publishAt, err := parseTimestamp("publish_at", c.PostForm("publish_at"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if !validOverwrite(c.PostForm("overwrite")) {
+		respondIngestError(c, errInvalidOverwrite)
+		return nil, false
 	}
 
 	id := uuid.New().String()
 	storedName := id // We use the UUID as the filename on disk for safety
 
-	storedPath, size, err := storage.StoreFile(file, h.StorageDir, storedName)
+	storedPath, size, hash, err := storage.StoreFile(storage.ContextReader(c.Request.Context(), file), h.TempDir, h.StorageDir, storedName)
 	if err != nil {
+		if timedOut(c, err) {
+			return nil, false
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file: " + err.Error()})
-		return
+		return nil, false
 	}
 
-	// Generate public download link
-	downloadLink := uuid.New().String()
-
 	isPublic := false
 	if c.PostForm("is_public") == "true" {
 		isPublic = true
 	}
 
-	record := db.FileRecord{
-		ID:           id,
-		OriginalName: header.Filename,
-		StoredPath:   storedPath,
-		Size:         size,
-		UploadTime:   time.Now().Unix(),
-		OwnerID:      ownerID,
-		DownloadLink: downloadLink,
-		IsPublic:     isPublic,
-	}
-
-	log.Printf("[DEBUG] Saving record: ID=%s, Name=%s, OwnerID=%s", record.ID, record.OriginalName, record.OwnerID)
-	err = db.SaveFileRecord(h.Store, record)
+	record, err := h.ingest(c.Request.Context(), stagedFile{ID: id, Path: storedPath, Size: size, SHA256: hash}, ingestOptions{
+		OwnerID:       ownerID,
+		RemoteAddr:    c.ClientIP(),
+		Name:          header.Filename,
+		IsPublic:      isPublic,
+		IsAdmin:       h.isAdmin(c),
+		StripMetadata: h.stripMetadataOption(c),
+		Folder:        db.NormalizeFolder(c.PostForm("folder")),
+		Tags:          db.ParseTags(c.PostForm("tags")),
+		PublishAt:     publishAt,
+		Password:      c.PostForm("password"),
+		Preset:        c.PostForm("preset"),
+		Signature:     formSignature(c),
+		Overwrite:     c.PostForm("overwrite"),
+	})
 	if err != nil {
-		log.Printf("[DEBUG] Failed to save record: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save record: " + err.Error()})
-		return
+		respondIngestError(c, err)
+		return nil, false
 	}
-
-	c.JSON(http.StatusOK, record)
+	return record, true
 }
 
 func (h *Handler) ListFiles(c *gin.Context) {
@@ -255,8 +421,14 @@ func (h *Handler) ListFiles(c *gin.Context) {
 
 	opts := db.ListFilesOptions{
 		Search: search,
+		Tag:    c.Query("tag"),
+		Folder: db.NormalizeFolder(c.Query("folder")),
+		Type:   c.Query("type"),
+		Owner:  c.Query("owner"),
+		Size:   c.Query("size"),
 		Limit:  limit,
 		Offset: offset,
+		Facets: c.Query("facets") == "true",
 	}
 
 	if !isAdmin {
@@ -265,6 +437,21 @@ func (h *Handler) ListFiles(c *gin.Context) {
 			return
 		}
 		opts.OwnerID = ownerID
+		if group := db.GroupOf(h.Store, ownerID); group != nil {
+			opts.GroupID = group.ID
+		}
+	}
+	switch view := c.Query("view"); view {
+	case "":
+	case db.ViewRecent, db.ViewFrequent:
+		if ownerID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
+			return
+		}
+		opts.View, opts.ViewerID = view, ownerID
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "view must be recent or frequent"})
+		return
 	}
 
 	log.Printf("[DEBUG] ListFiles request: isAdmin=%v, X-Client-ID=%s, Search=%s, Page=%d, Limit=%d", isAdmin, ownerID, search, page, limit)
@@ -280,31 +467,209 @@ func (h *Handler) ListFiles(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-func (h *Handler) DownloadFile(c *gin.Context) {
-	idOrLink := c.Param("id")
+func (h *Handler) findDownloadRecord(idOrLink string) (*db.FileRecord, error) {
 	// Try finding by ID first
 	record, err := db.GetFileRecord(h.Store, idOrLink)
+	if err == nil {
+		return record, nil
+	}
+
+	// Try finding by download_link
+	if record, errLink := db.GetFileRecordByLink(h.Store, idOrLink); errLink == nil {
+		return record, nil
+	}
+	return nil, err
+}
+
+// linkAccessible applies the checks shared by everything served from a
+// download link, and responds itself when the link can't be used. The
+// owner and admins pass them all.
+func (h *Handler) linkAccessible(c *gin.Context, record *db.FileRecord) bool {
+	if record.OwnerID == c.GetHeader("X-Client-ID") || h.isAdmin(c) {
+		return true
+	}
+	if status, body := h.linkRefusal(c, record); status != 0 {
+		c.JSON(status, body)
+		return false
+	}
+	return true
+}
+
+// linkRefusal is the status and response of a request the link's rules
+// turn away, or 0 when they let it through.
+func (h *Handler) linkRefusal(c *gin.Context, record *db.FileRecord) (int, gin.H) {
+	now := h.now().Unix()
+	if record.PublishAt > now {
+		return http.StatusForbidden, gin.H{"error": "File is not published yet", "publish_at": record.PublishAt}
+	}
+	if record.ExpiresAt > 0 && now > record.ExpiresAt {
+		return http.StatusGone, gin.H{"error": "Download link has expired"}
+	}
+	if record.LimitReached() {
+		return http.StatusGone, gin.H{"error": "Download limit reached"}
+	}
+	if record.Restricted() && !h.locationAllowed(c, record) {
+		return http.StatusForbidden, gin.H{"error": "This link is not available from your location"}
+	}
+	// Password-protected snippets must not be readable as plain downloads
+	if snippet, err := db.GetSnippet(h.Store, record.ID); err == nil && !h.snippetUnlocked(c, snippet) {
+		return http.StatusUnauthorized, gin.H{"error": "Snippet password required"}
+	}
+	if record.PasswordProtected && !h.linkUnlocked(c, record) {
+		return http.StatusUnauthorized, gin.H{"error": "Password required"}
+	}
+	return 0, nil
+}
+
+// contentAccessible runs the checks of a download for the requests that
+// read a file's content some other way, such as its part checksums: the
+// link's restrictions, quarantine and the download hooks. It responds
+// itself when the answer is no.
+func (h *Handler) contentAccessible(c *gin.Context, record *db.FileRecord) bool {
+	if !h.linkAccessible(c, record) {
+		return false
+	}
+	if record.Quarantined && !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "File is quarantined"})
+		return false
+	}
+	return h.downloadAllowed(c, record)
+}
+
+func (h *Handler) DownloadFile(c *gin.Context) {
+	record, err := h.findDownloadRecord(c.Param("id"))
 	if err != nil {
-		// Try finding by download_link
-		// In Celerix Store, we'll list all and filter for now
-		allFiles, errList := db.GetAllFileRecords(h.Store)
-		if errList == nil {
-			for _, r := range allFiles {
-				if r.DownloadLink == idOrLink {
-					record = &r
-					err = nil
-					break
-				}
-			}
-		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	if !h.linkAccessible(c, record) {
+		return
+	}
+	if record.Quarantined && !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "File is quarantined"})
+		return
+	}
+	// Chat apps unfurling the link get a card instead of the file
+	if c.Query("confirm") == "" && isUnfurlAgent(c.GetHeader("User-Agent")) {
+		h.serveCard(c, record)
+		return
+	}
+	if c.Query("confirm") == "" && c.Query("view") == "" && h.landingEnabled(record) {
+		h.serveLanding(c, record)
+		return
+	}
+	if !h.downloadAllowed(c, record) {
+		return
+	}
 
-		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
-			return
+	if !h.chargeDownload(c, record) {
+		return
+	}
+	h.recordDownload(c, record)
+
+	if record.IsLink() {
+		c.Redirect(http.StatusFound, record.TargetURL)
+		return
+	}
+	if !storage.IsS3Path(record.StoredPath) && h.serveMarkdownView(c, record) {
+		return
+	}
+
+	// Advertise range support and a strong validator so download
+	// accelerators can safely resume and split transfers
+	if storage.IsS3Path(record.StoredPath) {
+		h.redirectToS3(c, record)
+		return
+	}
+
+	c.Header("Accept-Ranges", "bytes")
+	if record.SHA256 != "" {
+		c.Header("ETag", `"`+record.SHA256+`"`)
+	}
+	serveAttachment(c, record.StoredPath, record.OriginalName)
+}
+
+// HeadDownload answers HEAD on a download link from the file record alone:
+// size, type, checksum and disposition, without touching the content or
+// counting a download. Link files redirect like a download would.
+func (h *Handler) HeadDownload(c *gin.Context) {
+	record, err := h.findDownloadRecord(c.Param("id"))
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	if !h.linkAccessible(c, record) {
+		return
+	}
+	if record.Quarantined && !h.isAdmin(c) {
+		c.Status(http.StatusForbidden)
+		return
+	}
+	if record.IsLink() {
+		c.Redirect(http.StatusFound, record.TargetURL)
+		return
+	}
+
+	contentType := mime.TypeByExtension(filepath.Ext(record.OriginalName))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Length", strconv.FormatInt(record.Size, 10))
+	c.Header("Content-Disposition", contentDisposition("attachment", record.OriginalName))
+	c.Header("Last-Modified", time.Unix(record.UploadTime, 0).UTC().Format(http.TimeFormat))
+	c.Header("Accept-Ranges", "bytes")
+	if record.SHA256 != "" {
+		c.Header("ETag", `"`+record.SHA256+`"`)
+		c.Header("X-Checksum-Sha256", record.SHA256)
+		if sum, err := hex.DecodeString(record.SHA256); err == nil {
+			c.Header("Repr-Digest", "sha-256=:"+base64.StdEncoding.EncodeToString(sum)+":")
 		}
 	}
+	c.Status(http.StatusOK)
+}
+
+// serveAttachment serves a stored file for download like
+// gin.Context.FileAttachment, but stops reading it once the request's
+// context is done, so abandoned or timed out downloads let go of the file.
+func serveAttachment(c *gin.Context, path, name string) {
+	f, err := os.Open(path)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+		return
+	}
 
-	c.FileAttachment(record.StoredPath, record.OriginalName)
+	c.Header("Content-Disposition", contentDisposition("attachment", name))
+	http.ServeContent(c.Writer, c.Request, name, info.ModTime(), storage.ContextReadSeeker(c.Request.Context(), f))
+}
+
+// downloadAllowed runs the pre-download hooks, and responds itself when they
+// deny the download or can't be reached.
+func (h *Handler) downloadAllowed(c *gin.Context, record *db.FileRecord) bool {
+	if err := h.Hooks.PreDownload(c.Request.Context(), hooks.Download{
+		FileID:     record.ID,
+		OwnerID:    record.OwnerID,
+		Name:       record.OriginalName,
+		ClientID:   c.GetHeader("X-Client-ID"),
+		RemoteAddr: c.ClientIP(),
+		IsAdmin:    h.isAdmin(c),
+	}); err != nil {
+		var rej *hooks.Rejection
+		if errors.As(err, &rej) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Download denied: " + rej.Reason})
+			return false
+		}
+		log.Printf("[ERROR] Download hook failed: %v", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Download authorization is unavailable"})
+		return false
+	}
+	return true
 }
 
 func (h *Handler) GetFileMetadata(c *gin.Context) {
@@ -335,9 +700,33 @@ func (h *Handler) UpdateFile(c *gin.Context) {
 	}
 
 	var input struct {
-		OriginalName string `json:"original_name" binding:"required"`
-		OwnerID      string `json:"owner_id" binding:"required"`
-		IsPublic     bool   `json:"is_public"`
+		OriginalName string    `json:"original_name" binding:"required"`
+		OwnerID      string    `json:"owner_id" binding:"required"`
+		IsPublic     bool      `json:"is_public"`
+		Folder       *string   `json:"folder"`
+		Tags         *[]string `json:"tags"`
+		// LandingPage is a tri-state: absent keeps the setting, null resets
+		// the file to the instance default
+		LandingPage json.RawMessage `json:"landing_page"`
+		// PublishAt is a Unix timestamp; 0 lifts the embargo
+		PublishAt *int64 `json:"publish_at"`
+		// Caps replace both limits; 0 means unlimited
+		MaxDownloads  *int64 `json:"max_downloads"`
+		MaxBytes      *int64 `json:"max_bytes"`
+		ResetCounters bool   `json:"reset_counters"`
+		// AllowedNetworks and AllowedCountries replace the link's
+		// restrictions; empty lists lift them
+		AllowedNetworks  *[]string `json:"allowed_networks"`
+		AllowedCountries *[]string `json:"allowed_countries"`
+		// CreateOwner makes a placeholder client when owner_id doesn't
+		// exist, named OwnerName
+		CreateOwner bool   `json:"create_owner"`
+		OwnerName   string `json:"owner_name"`
+		// TargetURL repoints a link file
+		TargetURL *string `json:"target_url"`
+		// Extendable false keeps the link from being extended past its
+		// expiry
+		Extendable *bool `json:"extendable"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -345,19 +734,270 @@ func (h *Handler) UpdateFile(c *gin.Context) {
 		return
 	}
 
+	if input.TargetURL != nil {
+		if !record.IsLink() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only link files have a target URL"})
+			return
+		}
+		if err := validateTargetURL(*input.TargetURL); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// A retained file keeps its name, folder and target; link settings and
+	// tags can still change
+	renamed := input.OriginalName != record.OriginalName
+	moved := input.Folder != nil && db.NormalizeFolder(*input.Folder) != record.Folder
+	repointed := input.TargetURL != nil && *input.TargetURL != record.TargetURL
+	if (renamed || moved || repointed) && h.refuseRetained(c, record) {
+		return
+	}
+
 	// Only admin can change owner
 	finalOwnerID := input.OwnerID
 	if !isAdmin {
 		finalOwnerID = record.OwnerID
 	}
 
-	err = db.UpdateFileRecord(h.Store, id, input.OriginalName, finalOwnerID, input.IsPublic)
+	// Names kept verbatim, e.g. by the sync API, survive other updates
+	name := record.OriginalName
+	if input.OriginalName != name {
+		name = h.cleanName(input.OriginalName)
+	}
+	folder := record.Folder
+	if input.Folder != nil {
+		folder = db.NormalizeFolder(*input.Folder)
+	}
+	// A file is checked in its new name and place as an upload there would
+	// be. Link files have no content and are never checked.
+	var decision *policy.Decision
+	placementOwner := finalOwnerID
+	if placementOwner == db.SystemPersona {
+		placementOwner = ""
+	}
+	if (renamed || moved || placementOwner != record.OwnerID) && !record.IsLink() {
+		decision, err = h.checkPlacement(record, placementOwner, name, folder, isAdmin)
+		if err != nil {
+			respondIngestError(c, err)
+			return
+		}
+		if decision != nil {
+			name = decision.Name
+		}
+	}
+
+	// A file handed to a client that doesn't exist would be invisible to
+	// everyone but admins
+	response := gin.H{"status": "success"}
+	if finalOwnerID == db.SystemPersona {
+		finalOwnerID = ""
+	} else if finalOwnerID != record.OwnerID {
+		if _, err := db.GetClient(h.Store, finalOwnerID); err != nil {
+			if !input.CreateOwner {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Client " + finalOwnerID + " does not exist; set create_owner to create a placeholder"})
+				return
+			}
+			placeholder, err := h.createPlaceholderClient(input.OwnerName)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create the owner"})
+				return
+			}
+			finalOwnerID = placeholder.ID
+			response["owner_id"] = placeholder.ID
+			response["recovery_code"] = placeholder.RecoveryCode
+		}
+	}
+	if finalOwnerID != record.OwnerID {
+		if err := h.enforcePoolQuota(finalOwnerID, []db.FileRecord{*record}); err != nil {
+			respondIngestError(c, err)
+			return
+		}
+	}
+
+	err = db.UpdateFileRecord(h.Store, id, name, finalOwnerID, input.IsPublic)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update file"})
 		return
 	}
+	if decision != nil && decision.Action != policy.ActionAllow {
+		h.auditPolicy(decision, id, ingestOptions{OwnerID: finalOwnerID, Name: name, RemoteAddr: c.ClientIP()})
+		if decision.Action == policy.ActionQuarantine {
+			if err := db.QuarantineFile(h.Store, id); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update file"})
+				return
+			}
+		}
+	}
 
-	c.JSON(http.StatusOK, gin.H{"status": "success"})
+	// Folder and tags are only touched when sent, so older clients keep them
+	if input.Folder != nil || input.Tags != nil {
+		tags := record.Tags
+		if input.Tags != nil {
+			tags = db.ParseTags(strings.Join(*input.Tags, ","))
+		}
+		if err := db.SetFileTagsAndFolder(h.Store, id, tags, folder); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update file"})
+			return
+		}
+	}
+
+	if input.PublishAt != nil {
+		if *input.PublishAt < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "publish_at must not be negative"})
+			return
+		}
+		if err := db.SetFilePublishAt(h.Store, id, *input.PublishAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update file"})
+			return
+		}
+	}
+
+	if input.MaxDownloads != nil || input.MaxBytes != nil || input.ResetCounters {
+		maxDownloads, maxBytes := record.MaxDownloads, record.MaxBytes
+		if input.MaxDownloads != nil {
+			maxDownloads = *input.MaxDownloads
+		}
+		if input.MaxBytes != nil {
+			maxBytes = *input.MaxBytes
+		}
+		if maxDownloads < 0 || maxBytes < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "max_downloads and max_bytes must not be negative"})
+			return
+		}
+		if err := db.SetFileLimits(h.Store, id, maxDownloads, maxBytes, input.ResetCounters); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update file"})
+			return
+		}
+	}
+
+	if input.AllowedNetworks != nil || input.AllowedCountries != nil {
+		networks, countries := record.AllowedNetworks, record.AllowedCountries
+		var err error
+		if input.AllowedNetworks != nil {
+			if networks, err = parseNetworks(*input.AllowedNetworks); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "allowed_networks: " + err.Error()})
+				return
+			}
+		}
+		if input.AllowedCountries != nil {
+			if countries, err = parseCountries(*input.AllowedCountries); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "allowed_countries: " + err.Error()})
+				return
+			}
+		}
+		if err := db.SetFileRestrictions(h.Store, id, networks, countries); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update file"})
+			return
+		}
+	}
+
+	if input.Extendable != nil && *input.Extendable == record.ExtendDisabled {
+		if err := db.SetFileExtendDisabled(h.Store, id, !*input.Extendable); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update file"})
+			return
+		}
+	}
+
+	if input.TargetURL != nil && *input.TargetURL != record.TargetURL {
+		if err := db.SetFileTargetURL(h.Store, id, *input.TargetURL); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update file"})
+			return
+		}
+	}
+
+	if len(input.LandingPage) > 0 {
+		var landing *bool
+		if err := json.Unmarshal(input.LandingPage, &landing); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "landing_page must be true, false or null"})
+			return
+		}
+		if err := db.SetFileLandingPage(h.Store, id, landing); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update file"})
+			return
+		}
+	}
+
+	if input.IsPublic && !record.IsPublic {
+		if updated, err := db.GetFileRecord(h.Store, id); err == nil {
+			h.publishEvent(events.FileShared, updated, "")
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// createPlaceholderClient creates a client for files that are assigned
+// before their owner has used the depot. Client IDs derive from recovery
+// codes, so the placeholder gets a fresh code that its owner can later
+// recover the persona with.
+func (h *Handler) createPlaceholderClient(name string) (*db.ClientRecord, error) {
+	if name == "" {
+		name = "Placeholder"
+	}
+	recoveryCode := strings.ToUpper(uuid.New().String()[:8])
+	id := uuid.NewSHA1(h.CelerixNamespace, []byte(recoveryCode)).String()
+	if err := db.UpsertClient(h.Store, id, name, recoveryCode, 0); err != nil {
+		return nil, err
+	}
+	return db.GetClient(h.Store, id)
+}
+
+// TransferFile hands a file to another client in one step. Owners can give
+// their files away; admins can move any file, including to the system
+// persona.
+func (h *Handler) TransferFile(c *gin.Context) {
+	id := c.Param("id")
+	record, err := db.GetFileRecord(h.Store, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+
+	ownerID := c.GetHeader("X-Client-ID")
+	isAdmin := h.isAdmin(c)
+	if !isAdmin && record.OwnerID != ownerID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to transfer this file"})
+		return
+	}
+
+	var input struct {
+		OwnerID string `json:"owner_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	target := input.OwnerID
+	if target == db.SystemPersona {
+		if !isAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can transfer files to the system persona"})
+			return
+		}
+		target = ""
+	} else if group, err := db.GetGroup(h.Store, target); err == nil {
+		// A member's files already count towards the pool, so handing them
+		// to the group doesn't change its usage
+		if !isAdmin && !slices.Contains(group.Members, ownerID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only members can transfer files to group " + target})
+			return
+		}
+	} else if _, err := db.GetClient(h.Store, target); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Client " + target + " does not exist"})
+		return
+	}
+	if err := h.enforcePoolQuota(target, []db.FileRecord{*record}); err != nil {
+		respondIngestError(c, err)
+		return
+	}
+
+	record, err = db.TransferFile(h.Store, id, target)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to transfer file"})
+		return
+	}
+	c.JSON(http.StatusOK, record)
 }
 
 func (h *Handler) DeleteFile(c *gin.Context) {
@@ -375,16 +1015,16 @@ func (h *Handler) DeleteFile(c *gin.Context) {
 		return
 	}
 
-	// Delete from storage
-	err = storage.DeleteFile(record.StoredPath)
-	if err != nil {
-		log.Printf("[ERROR] Failed to delete file from storage: %v", err)
-		// We continue even if file is missing from storage to clean up DB
+	// Published artifacts are immutable; only an admin can withdraw one
+	if record.Metadata["artifact"] != "" && !h.isAdmin(c) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Published artifacts cannot be deleted"})
+		return
+	}
+	if h.refuseRetained(c, record) {
+		return
 	}
 
-	// Delete from DB
-	err = db.DeleteFileRecord(h.Store, id)
-	if err != nil {
+	if err := h.removeFile(record); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete file record"})
 		return
 	}
@@ -392,6 +1032,27 @@ func (h *Handler) DeleteFile(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "success"})
 }
 
+// removeFile deletes a file's content and record. Files under retention are
+// refused with errRetained.
+func (h *Handler) removeFile(record *db.FileRecord) error {
+	if h.retained(record) {
+		return errRetained
+	}
+	// Delete from storage (shared content is kept until its last reference goes)
+	err := h.releaseStoredFile(record)
+	if err != nil {
+		log.Printf("[ERROR] Failed to delete file from storage: %v", err)
+		// We continue even if file is missing from storage to clean up DB
+	}
+
+	// Delete from DB
+	if err := db.DeleteFileRecord(h.Store, record.ID); err != nil {
+		return err
+	}
+	h.publishEvent(events.FileDeleted, record, "")
+	return nil
+}
+
 func (h *Handler) ListClients(c *gin.Context) {
 	if !h.isAdmin(c) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
@@ -456,11 +1117,77 @@ func (h *Handler) DeleteClient(c *gin.Context) {
 		return
 	}
 
+	// Files decides what happens to the client's files: "system" (the
+	// default) moves them to the system persona, "transfer" to OwnerID,
+	// "delete" removes them and "keep" leaves them orphaned
+	var input struct {
+		Files   string `json:"files" binding:"omitempty,oneof=system transfer delete keep"`
+		OwnerID string `json:"owner_id"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if input.Files == "" {
+		input.Files = "system"
+	}
+
+	target := ""
+	if input.Files == "transfer" {
+		target = input.OwnerID
+		if target == "" || target == id {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "owner_id must name another client to transfer the files to"})
+			return
+		}
+		if _, err := db.GetClient(h.Store, target); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Client " + target + " does not exist"})
+			return
+		}
+	}
+
+	// Files are dealt with first, so a failure leaves the client in place
+	// to retry with
+	affected := 0
+	if input.Files != "keep" {
+		files, err := db.ListOwnerFiles(h.Store, id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list the client's files"})
+			return
+		}
+		if input.Files == "delete" {
+			for i := range files {
+				if h.refuseRetained(c, &files[i]) {
+					return
+				}
+			}
+		} else if err := h.enforcePoolQuota(target, files); err != nil {
+			respondIngestError(c, err)
+			return
+		}
+		for i := range files {
+			if input.Files == "delete" {
+				err = h.removeFile(&files[i])
+			} else {
+				_, err = db.TransferFile(h.Store, files[i].ID, target)
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + input.Files + " file " + files[i].ID, "files_done": affected})
+				return
+			}
+			affected++
+		}
+	}
+
 	err := db.DeleteClient(h.Store, id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete client"})
 		return
 	}
+	if err := db.DeleteNotificationPrefs(h.Store, id); err != nil {
+		log.Printf("[ERROR] Failed to delete notification settings of %s: %v", id, err)
+	}
 
-	c.JSON(http.StatusOK, gin.H{"status": "success"})
+	c.JSON(http.StatusOK, gin.H{"status": "success", "files": input.Files, "files_done": affected})
 }
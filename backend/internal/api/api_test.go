@@ -13,6 +13,7 @@ import (
 	_ "github.com/celerix-dev/celerix-store/pkg/engine"
 	"github.com/celerix-dev/celerix-store/pkg/sdk"
 	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/policy"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
@@ -369,3 +370,212 @@ func TestFileUploadAndList(t *testing.T) {
 		t.Errorf("expected file record NOT to be in OLD persona anymore")
 	}
 }
+
+func TestTransferFile(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.POST("/files/:id/transfer", h.TransferFile)
+
+	db.UpsertClient(h.Store, "client-a", "A", "CODEA", 0)
+	db.UpsertClient(h.Store, "client-b", "B", "CODEB", 0)
+	db.UpsertClient(h.Store, "admin", "Admin", "CODEC", 0)
+	db.UpdateClientAdminStatus(h.Store, "admin", true)
+
+	id := uploadTestFile(t, router, "client-a", "a.txt", []byte("hello"))["id"].(string)
+
+	transfer := func(clientID, owner string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/files/"+id+"/transfer", bytes.NewBufferString(`{"owner_id": "`+owner+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Client-ID", clientID)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := transfer("client-a", "nobody"); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for a missing client, got %d", w.Code)
+	}
+	if w := transfer("client-b", "client-b"); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for someone else's file, got %d", w.Code)
+	}
+	if w := transfer("client-a", db.SystemPersona); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a client giving a file to the system, got %d", w.Code)
+	}
+
+	if w := transfer("client-a", "client-b"); w.Code != http.StatusOK {
+		t.Fatalf("transfer failed: %s", w.Body.String())
+	}
+	record, err := db.GetFileRecord(h.Store, id)
+	if err != nil || record.OwnerID != "client-b" || record.OwnerName != "B" {
+		t.Fatalf("expected the file to belong to client-b, got %+v, %v", record, err)
+	}
+	if files, _ := db.GetFileRecordsByOwner(h.Store, "client-a"); len(files) != 0 {
+		t.Errorf("expected client-a to have no files left, got %d", len(files))
+	}
+
+	if w := transfer("admin", db.SystemPersona); w.Code != http.StatusOK {
+		t.Fatalf("admin transfer failed: %s", w.Body.String())
+	}
+	if record, _ := db.GetFileRecord(h.Store, id); record.OwnerID != "" {
+		t.Errorf("expected the file to belong to the system, got %q", record.OwnerID)
+	}
+}
+
+func TestUpdateFileOwnerMustExist(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.PUT("/files/:id", h.UpdateFile)
+
+	db.UpsertClient(h.Store, "admin", "Admin", "CODEA", 0)
+	db.UpdateClientAdminStatus(h.Store, "admin", true)
+	id := uploadTestFile(t, router, "admin", "a.txt", []byte("hello"))["id"].(string)
+
+	update := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/files/"+id, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Client-ID", "admin")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := update(`{"original_name": "a.txt", "owner_id": "ghost"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for a missing owner, got %d: %s", w.Code, w.Body.String())
+	}
+	if record, _ := db.GetFileRecord(h.Store, id); record.OwnerID != "admin" {
+		t.Errorf("expected the rejected update to leave the owner, got %q", record.OwnerID)
+	}
+
+	w := update(`{"original_name": "a.txt", "owner_id": "ghost", "create_owner": true, "owner_name": "New Hire"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected a placeholder owner, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp map[string]string
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	client, err := db.GetClient(h.Store, resp["owner_id"])
+	if err != nil || client.Name != "New Hire" || client.RecoveryCode != resp["recovery_code"] {
+		t.Fatalf("expected the placeholder client to exist, got %+v, %v", client, err)
+	}
+	if record, _ := db.GetFileRecord(h.Store, id); record.OwnerID != client.ID {
+		t.Errorf("expected the file to belong to the placeholder, got %q", record.OwnerID)
+	}
+}
+
+func TestUpdateFileChecksPlacement(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+	h.Policy = &policy.Engine{Rules: []policy.Rule{{Name: "no-exe", Extensions: []string{"exe"}, Action: policy.ActionReject}}}
+	db.SaveSettings(h.Store, db.Settings{AllowedExtensions: []string{".txt", ".md", ".exe"}})
+	db.SaveFolderPolicy(h.Store, db.FolderPolicy{OwnerID: "client-a", Folder: "notes", AllowedExtensions: []string{".md"}})
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.PUT("/files/:id", h.UpdateFile)
+	id := uploadTestFile(t, router, "client-a", "a.txt", []byte("hello"))["id"].(string)
+
+	update := func(body string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/files/"+id, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Client-ID", "client-a")
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := update(`{"original_name": "a.pdf", "owner_id": "client-a"}`); code != http.StatusUnsupportedMediaType {
+		t.Errorf("expected a type the depot refuses to be refused, got %d", code)
+	}
+	if code := update(`{"original_name": "a.exe", "owner_id": "client-a"}`); code != http.StatusUnprocessableEntity {
+		t.Errorf("expected a name the policy rejects to be refused, got %d", code)
+	}
+	if code := update(`{"original_name": "a.txt", "owner_id": "client-a", "folder": "notes"}`); code != http.StatusUnsupportedMediaType {
+		t.Errorf("expected a type the folder refuses to be refused, got %d", code)
+	}
+	if record, _ := db.GetFileRecord(h.Store, id); record.OriginalName != "a.txt" || record.Folder != "" {
+		t.Errorf("refused updates changed the file to %s in %q", record.OriginalName, record.Folder)
+	}
+	if code := update(`{"original_name": "a.md", "owner_id": "client-a", "folder": "notes"}`); code != http.StatusOK {
+		t.Errorf("expected an allowed name and folder to be taken, got %d", code)
+	}
+}
+
+func TestDeleteClientFiles(t *testing.T) {
+	h, storageDir, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.DELETE("/clients/:id", h.DeleteClient)
+
+	db.UpsertClient(h.Store, "admin", "Admin", "CODEA", 0)
+	db.UpdateClientAdminStatus(h.Store, "admin", true)
+
+	remove := func(id, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("DELETE", "/clients/"+id, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Client-ID", "admin")
+		router.ServeHTTP(w, req)
+		return w
+	}
+	owner := func(id string) string {
+		record, err := db.GetFileRecord(h.Store, id)
+		if err != nil {
+			return "<deleted>"
+		}
+		return record.OwnerID
+	}
+
+	for _, c := range []string{"a", "b", "c", "d", "heir"} {
+		db.UpsertClient(h.Store, c, c, "CODE"+c, 0)
+	}
+	a := uploadTestFile(t, router, "a", "a.txt", []byte("a"))["id"].(string)
+	b := uploadTestFile(t, router, "b", "b.txt", []byte("b"))["id"].(string)
+	c := uploadTestFile(t, router, "c", "c.txt", []byte("c"))["id"].(string)
+	d := uploadTestFile(t, router, "d", "d.txt", []byte("d"))["id"].(string)
+
+	if w := remove("a", `{"files": "transfer", "owner_id": "nobody"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for a missing heir, got %d", w.Code)
+	}
+	if _, err := db.GetClient(h.Store, "a"); err != nil {
+		t.Fatal("expected a rejected delete to keep the client")
+	}
+
+	if w := remove("a", ""); w.Code != http.StatusOK {
+		t.Fatalf("delete failed: %s", w.Body.String())
+	}
+	if got := owner(a); got != "" {
+		t.Errorf("expected the file to move to the system by default, got %q", got)
+	}
+
+	if w := remove("b", `{"files": "transfer", "owner_id": "heir"}`); w.Code != http.StatusOK {
+		t.Fatalf("delete failed: %s", w.Body.String())
+	}
+	if got := owner(b); got != "heir" {
+		t.Errorf("expected the file to move to heir, got %q", got)
+	}
+
+	stored, _ := db.GetFileRecord(h.Store, c)
+	if w := remove("c", `{"files": "delete"}`); w.Code != http.StatusOK {
+		t.Fatalf("delete failed: %s", w.Body.String())
+	}
+	if got := owner(c); got != "<deleted>" {
+		t.Errorf("expected the file to be deleted, got owner %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(storageDir, filepath.Base(stored.StoredPath))); !os.IsNotExist(err) {
+		t.Errorf("expected the stored content to be removed, got %v", err)
+	}
+
+	if w := remove("d", `{"files": "keep"}`); w.Code != http.StatusOK {
+		t.Fatalf("delete failed: %s", w.Body.String())
+	}
+	if got := owner(d); got != "d" {
+		t.Errorf("expected the file to be left alone, got %q", got)
+	}
+}
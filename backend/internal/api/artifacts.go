@@ -0,0 +1,187 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const checksumSuffix = ".sha256"
+
+var (
+	artifactName    = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,127}$`)
+	artifactVersion = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._+-]{0,127}$`)
+)
+
+// ListArtifacts lists every published artifact version, newest first per name.
+func (h *Handler) ListArtifacts(c *gin.Context) {
+	artifacts, err := db.ListArtifacts(h.Store, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list artifacts"})
+		return
+	}
+	c.JSON(http.StatusOK, artifacts)
+}
+
+// ListArtifactVersions lists the published versions of one artifact.
+func (h *Handler) ListArtifactVersions(c *gin.Context) {
+	name := c.Param("name")
+	artifacts, err := db.ListArtifacts(h.Store, name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list artifacts"})
+		return
+	}
+	if len(artifacts) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Artifact not found"})
+		return
+	}
+	c.JSON(http.StatusOK, artifacts)
+}
+
+// PublishArtifact stores the request body as a new artifact version.
+// Versions are immutable: publishing an existing one fails with 409. CI can
+// pass X-Checksum-Sha256 to have the content verified on arrival.
+func (h *Handler) PublishArtifact(c *gin.Context) {
+	ownerID := c.GetHeader("X-Client-ID")
+	if ownerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
+		return
+	}
+	name, version := c.Param("name"), c.Param("version")
+	if !artifactName.MatchString(name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid artifact name"})
+		return
+	}
+	if !artifactVersion.MatchString(version) || strings.HasSuffix(version, checksumSuffix) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid artifact version"})
+		return
+	}
+	if _, err := db.GetArtifact(h.Store, name, version); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Artifact version already exists"})
+		return
+	}
+
+	body := c.Request.Body
+	if limit := db.GetSettings(h.Store).MaxUploadBytes; limit > 0 {
+		body = http.MaxBytesReader(c.Writer, body, limit)
+	}
+
+	id := uuid.New().String()
+	storedPath, size, hash, err := storage.StoreFile(storage.ContextReader(c.Request.Context(), body), h.TempDir, h.StorageDir, id)
+	if err != nil {
+		if timedOut(c, err) {
+			return
+		}
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File exceeds the maximum upload size"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file: " + err.Error()})
+		return
+	}
+	if want := strings.ToLower(c.GetHeader("X-Checksum-Sha256")); want != "" && want != hash {
+		storage.DeleteFile(storedPath)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Checksum mismatch", "sha256": hash})
+		return
+	}
+
+	fileName := name + "-" + version
+	if c.GetHeader("X-Filename") != "" || c.GetHeader("Content-Disposition") != "" {
+		fileName = rawUploadName(c)
+	}
+
+	ref := db.ArtifactRef(name, version)
+	record, err := h.ingest(c.Request.Context(), stagedFile{ID: id, Path: storedPath, Size: size, SHA256: hash}, ingestOptions{
+		OwnerID:    ownerID,
+		RemoteAddr: c.ClientIP(),
+		Name:       fileName,
+		IsAdmin:    h.isAdmin(c),
+		Folder:     "artifacts/" + name,
+		Metadata:   map[string]string{"artifact": ref},
+		Signature:  headerSignature(c),
+	})
+	if err != nil {
+		respondIngestError(c, err)
+		return
+	}
+
+	artifact := db.ArtifactRecord{
+		Name:        name,
+		Version:     version,
+		FileID:      record.ID,
+		FileName:    record.OriginalName,
+		Size:        record.Size,
+		SHA256:      record.SHA256,
+		PublishedBy: ownerID,
+		PublishedAt: time.Now().Unix(),
+	}
+
+	h.publishMu.Lock()
+	defer h.publishMu.Unlock()
+	if _, err := db.GetArtifact(h.Store, name, version); err == nil {
+		// Lost a race with a concurrent publish of the same version
+		_ = h.removeFile(record)
+		c.JSON(http.StatusConflict, gin.H{"error": "Artifact version already exists"})
+		return
+	}
+	if err := db.SaveArtifact(h.Store, artifact); err != nil {
+		_ = h.removeFile(record)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save artifact"})
+		return
+	}
+
+	c.Header("Location", fmt.Sprintf("%s/api/artifacts/%s", h.baseURL(c), ref))
+	c.JSON(http.StatusCreated, artifact)
+}
+
+// GetArtifact downloads an artifact version. Appending .sha256 to the
+// version returns a checksum file in sha256sum format instead.
+func (h *Handler) GetArtifact(c *gin.Context) {
+	name, version := c.Param("name"), c.Param("version")
+	checksum := false
+	if v, ok := strings.CutSuffix(version, checksumSuffix); ok {
+		if _, err := db.GetArtifact(h.Store, name, version); err != nil {
+			version, checksum = v, true
+		}
+	}
+
+	artifact, err := db.GetArtifact(h.Store, name, version)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Artifact not found"})
+		return
+	}
+	if checksum {
+		c.Header("X-Content-Type-Options", "nosniff")
+		c.String(http.StatusOK, "%s  %s\n", artifact.SHA256, artifact.FileName)
+		return
+	}
+
+	record, err := db.GetFileRecord(h.Store, artifact.FileID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Artifact content is missing"})
+		return
+	}
+	if record.Quarantined && !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "File is quarantined"})
+		return
+	}
+	if storage.IsS3Path(record.StoredPath) {
+		h.redirectToS3(c, record)
+		return
+	}
+
+	// Published versions never change, so caches may keep them forever
+	c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	c.Header("Accept-Ranges", "bytes")
+	c.Header("ETag", `"`+artifact.SHA256+`"`)
+	serveAttachment(c, record.StoredPath, artifact.FileName)
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestArtifactPublishAndFetch(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	router := gin.Default()
+	router.GET("/artifacts/:name", h.ListArtifactVersions)
+	router.GET("/artifacts/:name/:version", h.GetArtifact)
+	router.PUT("/artifacts/:name/:version", h.PublishArtifact)
+	router.DELETE("/files/:id", h.DeleteFile)
+
+	publish := func(version string, content []byte, checksum string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/artifacts/tool/"+version, bytes.NewReader(content))
+		req.Header.Set("X-Client-ID", "ci")
+		req.Header.Set("X-Filename", "tool-linux-amd64.tar.gz")
+		if checksum != "" {
+			req.Header.Set("X-Checksum-Sha256", checksum)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	content := []byte("release bytes")
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+
+	if w := publish("1.0.0", content, "deadbeef"); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for checksum mismatch, got %d", w.Code)
+	}
+	w := publish("1.0.0", content, hash)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var artifact map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &artifact)
+
+	// Versions are immutable
+	if w := publish("1.0.0", []byte("other bytes"), ""); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 on republish, got %d", w.Code)
+	}
+	if w := publish("1.0.0.sha256", content, ""); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for reserved version suffix, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/artifacts/tool/1.0.0", nil)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != string(content) {
+		t.Fatalf("unexpected download: %d %q", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/artifacts/tool/1.0.0.sha256", nil)
+	router.ServeHTTP(w, req)
+	if want := hash + "  tool-linux-amd64.tar.gz\n"; w.Body.String() != want {
+		t.Errorf("expected checksum file %q, got %q", want, w.Body.String())
+	}
+
+	// The publisher cannot delete the underlying file
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/files/"+artifact["file_id"].(string), nil)
+	req.Header.Set("X-Client-ID", "ci")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected 409 deleting an artifact file, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/artifacts/tool", nil)
+	router.ServeHTTP(w, req)
+	var versions []map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &versions)
+	if len(versions) != 1 || versions[0]["version"] != "1.0.0" {
+		t.Errorf("unexpected versions: %s", w.Body.String())
+	}
+}
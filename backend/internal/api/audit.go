@@ -0,0 +1,25 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) ListAudit(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	entries, err := db.ListAudit(h.Store, c.Query("event"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list audit entries"})
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
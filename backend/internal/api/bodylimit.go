@@ -0,0 +1,142 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultMaxBodyBytes bounds request bodies when MaxBodyBytes is unset.
+// It leaves room for a 1 MiB snippet with its JSON escaping.
+const defaultMaxBodyBytes = 4 << 20
+
+// largeBodyRoutes take file content outside the transfer routes, and are
+// bounded by their own limits instead of MaxBodyBytes.
+var largeBodyRoutes = []string{
+	"POST /api/admin/clients/import",
+}
+
+func (h *Handler) maxBodyBytes(method, route string) int64 {
+	for _, routes := range [][]string{transferRoutes, largeBodyRoutes} {
+		for _, key := range routes {
+			if routeMatches(key, method, route) {
+				return 0
+			}
+		}
+	}
+	h.configMu.RLock()
+	defer h.configMu.RUnlock()
+	if h.MaxBodyBytes > 0 {
+		return h.MaxBodyBytes
+	}
+	return defaultMaxBodyBytes
+}
+
+// RouteTraffic totals the requests to one route and the bytes they moved.
+type RouteTraffic struct {
+	Route    string `json:"route"`
+	Requests int64  `json:"requests"`
+	// Rejected counts bodies refused for their size
+	Rejected int64 `json:"rejected"`
+	BytesIn  int64 `json:"bytes_in"`
+	BytesOut int64 `json:"bytes_out"`
+}
+
+// routeTraffic holds the traffic per route. The zero value is ready to
+// use.
+type routeTraffic struct {
+	mu     sync.Mutex
+	routes map[string]*RouteTraffic
+}
+
+func (t *routeTraffic) add(route string, in, out int64, rejected bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.routes == nil {
+		t.routes = make(map[string]*RouteTraffic)
+	}
+	r, ok := t.routes[route]
+	if !ok {
+		r = &RouteTraffic{Route: route}
+		t.routes[route] = r
+	}
+	r.Requests++
+	r.BytesIn += in
+	r.BytesOut += max(out, 0)
+	if rejected {
+		r.Rejected++
+	}
+}
+
+func (t *routeTraffic) list() []RouteTraffic {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	routes := make([]RouteTraffic, 0, len(t.routes))
+	for _, r := range t.routes {
+		routes = append(routes, *r)
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i].Route < routes[j].Route })
+	return routes
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// BodyLimit refuses request bodies over MaxBodyBytes with 413 before a
+// handler buffers them, except on the routes that take file content, and
+// counts the bytes in and out per route. A body of unknown length is read
+// up to the limit first, so it is refused the same way.
+func (h *Handler) BodyLimit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.Request.Method + " " + c.FullPath()
+		if c.Request.Body == nil {
+			c.Request.Body = http.NoBody
+		}
+		body := &countingReader{ReadCloser: c.Request.Body}
+		c.Request.Body = body
+		rejected := false
+		defer func() {
+			h.traffic.add(key, body.n, int64(c.Writer.Size()), rejected)
+		}()
+
+		limit := h.maxBodyBytes(c.Request.Method, c.FullPath())
+		if limit <= 0 {
+			c.Next()
+			return
+		}
+		if c.Request.ContentLength > limit {
+			rejected = true
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body is too large"})
+			return
+		}
+		if c.Request.ContentLength < 0 {
+			buf, err := io.ReadAll(io.LimitReader(body, limit+1))
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+				return
+			}
+			if int64(len(buf)) > limit {
+				rejected = true
+				c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body is too large"})
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(buf))
+		} else {
+			c.Request.Body = http.MaxBytesReader(c.Writer, body, limit)
+		}
+		c.Next()
+	}
+}
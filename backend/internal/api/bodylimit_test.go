@@ -0,0 +1,73 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestBodyLimit(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+	h.MaxBodyBytes = 16
+
+	echo := func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.String(http.StatusOK, string(body))
+	}
+	router := gin.Default()
+	api := router.Group("/api", h.BodyLimit())
+	api.POST("/persona/name", echo)
+	api.POST("/upload", echo)
+
+	post := func(path, body string, knownLength bool) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		var r io.Reader = strings.NewReader(body)
+		if !knownLength {
+			r = io.MultiReader(r)
+		}
+		req := httptest.NewRequest("POST", path, r)
+		if !knownLength {
+			req.ContentLength = -1
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := post("/api/persona/name", `{"name":"a"}`, true); w.Code != http.StatusOK || w.Body.String() != `{"name":"a"}` {
+		t.Errorf("expected a small body to pass, got %d %s", w.Code, w.Body.String())
+	}
+	if w := post("/api/persona/name", `{"name":"a"}`, false); w.Code != http.StatusOK || w.Body.String() != `{"name":"a"}` {
+		t.Errorf("expected a small body of unknown length to pass, got %d %s", w.Code, w.Body.String())
+	}
+	large := `{"name":"` + strings.Repeat("a", 64) + `"}`
+	if w := post("/api/persona/name", large, true); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for a large body, got %d", w.Code)
+	}
+	if w := post("/api/persona/name", large, false); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for a large body of unknown length, got %d", w.Code)
+	}
+	if w := post("/api/upload", large, true); w.Code != http.StatusOK {
+		t.Errorf("expected uploads to be exempt, got %d", w.Code)
+	}
+
+	traffic := map[string]RouteTraffic{}
+	for _, r := range h.traffic.list() {
+		traffic[r.Route] = r
+	}
+	name := traffic["POST /api/persona/name"]
+	if name.Requests != 4 || name.Rejected != 2 || name.BytesIn < 2*12 || name.BytesOut == 0 {
+		t.Errorf("unexpected traffic for the name route: %+v", name)
+	}
+	if upload := traffic["POST /api/upload"]; upload.BytesIn != int64(len(large)) || upload.BytesOut != int64(len(large)) {
+		t.Errorf("unexpected traffic for uploads: %+v", upload)
+	}
+}
@@ -0,0 +1,246 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// bootstrapDocument declares how a depot is configured. Sections left out
+// are left alone.
+type bootstrapDocument struct {
+	// Settings replace the instance settings as a whole, quotas included
+	Settings *db.Settings      `json:"settings"`
+	Clients  []bootstrapClient `json:"clients"`
+	Folders  []bootstrapFolder `json:"folders"`
+	// Prune removes the folder policies of the document's clients that it
+	// doesn't list. Clients themselves are never removed.
+	Prune bool `json:"prune"`
+}
+
+// bootstrapClient is a client, identified by its recovery code since the
+// client ID derives from it.
+type bootstrapClient struct {
+	Name         string `json:"name"`
+	RecoveryCode string `json:"recovery_code"`
+	IsAdmin      bool   `json:"is_admin"`
+}
+
+// bootstrapFolder is the policy of a client's folder. The client is named
+// by owner_id or by the recovery_code of one in the document.
+type bootstrapFolder struct {
+	OwnerID      string `json:"owner_id"`
+	RecoveryCode string `json:"recovery_code"`
+	db.FolderPolicy
+}
+
+// Bootstrap change actions.
+const (
+	bootstrapCreated   = "created"
+	bootstrapUpdated   = "updated"
+	bootstrapDeleted   = "deleted"
+	bootstrapUnchanged = "unchanged"
+)
+
+type bootstrapChange struct {
+	Kind   string `json:"kind"`
+	ID     string `json:"id,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Folder string `json:"folder,omitempty"`
+	Action string `json:"action"`
+}
+
+// AdminBootstrap brings the depot to the state in the document: it creates
+// and updates what differs and reports each item, so the same document can
+// be applied any number of times. With ?dry_run=true it only reports what
+// would change. The whole document is checked before anything is applied.
+func (h *Handler) AdminBootstrap(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	var doc bootstrapDocument
+	if err := c.ShouldBindJSON(&doc); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	dryRun := c.Query("dry_run") == "true"
+
+	if doc.Settings != nil {
+		if err := validateSettings(*doc.Settings); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "settings: " + err.Error()})
+			return
+		}
+	}
+	clientIDs := make(map[string]string, len(doc.Clients))
+	for i := range doc.Clients {
+		cl := &doc.Clients[i]
+		cl.Name = strings.TrimSpace(cl.Name)
+		cl.RecoveryCode = strings.ToUpper(strings.TrimSpace(cl.RecoveryCode))
+		if cl.Name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("clients[%d]: name is required", i)})
+			return
+		}
+		if !recoveryCodePattern.MatchString(cl.RecoveryCode) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("clients[%d]: recovery codes are 6 to 64 letters, digits or dashes", i)})
+			return
+		}
+		if _, dup := clientIDs[cl.RecoveryCode]; dup {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("clients[%d]: recovery code listed twice", i)})
+			return
+		}
+		clientIDs[cl.RecoveryCode] = uuid.NewSHA1(h.CelerixNamespace, []byte(cl.RecoveryCode)).String()
+	}
+	listed := make(map[string]bool, len(doc.Folders))
+	for i := range doc.Folders {
+		f := &doc.Folders[i]
+		if f.RecoveryCode != "" {
+			id, ok := clientIDs[strings.ToUpper(f.RecoveryCode)]
+			if !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("folders[%d]: no client in the document has that recovery code", i)})
+				return
+			}
+			f.OwnerID = id
+		} else if _, err := db.GetClient(h.Store, f.OwnerID); err != nil || f.OwnerID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("folders[%d]: client %q not found", i, f.OwnerID)})
+			return
+		}
+		f.FolderPolicy.OwnerID = f.OwnerID
+		f.Folder = db.NormalizeFolder(f.Folder)
+		if f.Folder == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("folders[%d]: a folder is required", i)})
+			return
+		}
+		if f.LinkExpirySeconds != nil && *f.LinkExpirySeconds < 0 || f.RetentionSeconds != nil && *f.RetentionSeconds < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("folders[%d]: durations must not be negative", i)})
+			return
+		}
+		key := f.OwnerID + ":" + f.Folder
+		if listed[key] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("folders[%d]: folder listed twice", i)})
+			return
+		}
+		listed[key] = true
+	}
+
+	changes := []bootstrapChange{}
+	changed := 0
+	record := func(ch bootstrapChange) {
+		if ch.Action != bootstrapUnchanged {
+			changed++
+		}
+		changes = append(changes, ch)
+	}
+	fail := func(what string, err error) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply " + what + ": " + err.Error(), "changes": changes})
+	}
+
+	if doc.Settings != nil {
+		desired := db.NormalizeSettings(*doc.Settings)
+		action := bootstrapUnchanged
+		if !sameSettings(desired, db.GetSettings(h.Store)) {
+			action = bootstrapUpdated
+			if !dryRun {
+				if err := db.SaveSettings(h.Store, desired); err != nil {
+					fail("settings", err)
+					return
+				}
+			}
+		}
+		record(bootstrapChange{Kind: "settings", Action: action})
+	}
+
+	for _, cl := range doc.Clients {
+		id := clientIDs[cl.RecoveryCode]
+		action := bootstrapUnchanged
+		var err error
+		existing, getErr := db.GetClient(h.Store, id)
+		switch {
+		case getErr != nil:
+			action = bootstrapCreated
+			if !dryRun {
+				err = db.UpsertClient(h.Store, id, cl.Name, cl.RecoveryCode, 0)
+				if err == nil && cl.IsAdmin {
+					err = db.UpdateClientAdminStatus(h.Store, id, true)
+				}
+			}
+		case existing.Name != cl.Name || existing.IsAdmin != cl.IsAdmin:
+			action = bootstrapUpdated
+			if !dryRun {
+				err = db.UpdateClientFull(h.Store, id, cl.Name, cl.RecoveryCode, cl.IsAdmin)
+			}
+		}
+		if err != nil {
+			fail("client "+cl.Name, err)
+			return
+		}
+		record(bootstrapChange{Kind: "client", ID: id, Name: cl.Name, Action: action})
+	}
+
+	now := h.now().Unix()
+	for _, f := range doc.Folders {
+		desired := db.NormalizeFolderPolicy(f.FolderPolicy)
+		action := bootstrapCreated
+		if existing, err := db.GetFolderPolicy(h.Store, f.OwnerID, f.Folder); err == nil {
+			action = bootstrapUpdated
+			if sameFolderPolicy(desired, *existing) {
+				action = bootstrapUnchanged
+			}
+		}
+		if action != bootstrapUnchanged && !dryRun {
+			desired.UpdatedAt = now
+			if err := db.SaveFolderPolicy(h.Store, desired); err != nil {
+				fail("folder "+f.Folder, err)
+				return
+			}
+		}
+		record(bootstrapChange{Kind: "folder", ID: f.OwnerID, Folder: f.Folder, Action: action})
+	}
+
+	if doc.Prune {
+		for _, cl := range doc.Clients {
+			id := clientIDs[cl.RecoveryCode]
+			policies, _ := db.ListFolderPolicies(h.Store, id)
+			for _, p := range policies {
+				if listed[id+":"+p.Folder] {
+					continue
+				}
+				if !dryRun {
+					if err := db.DeleteFolderPolicy(h.Store, id, p.Folder); err != nil {
+						fail("folder "+p.Folder, err)
+						return
+					}
+				}
+				record(bootstrapChange{Kind: "folder", ID: id, Folder: p.Folder, Action: bootstrapDeleted})
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"dry_run": dryRun, "changed": changed, "changes": changes})
+}
+
+func nilIfEmpty(s []string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	return s
+}
+
+func sameSettings(a, b db.Settings) bool {
+	a.AllowedExtensions, b.AllowedExtensions = nilIfEmpty(a.AllowedExtensions), nilIfEmpty(b.AllowedExtensions)
+	a.LinkRotationFolders, b.LinkRotationFolders = nilIfEmpty(a.LinkRotationFolders), nilIfEmpty(b.LinkRotationFolders)
+	return reflect.DeepEqual(a, b)
+}
+
+func sameFolderPolicy(a, b db.FolderPolicy) bool {
+	a.UpdatedAt, b.UpdatedAt = 0, 0
+	a.AllowedExtensions, b.AllowedExtensions = nilIfEmpty(a.AllowedExtensions), nilIfEmpty(b.AllowedExtensions)
+	a.Tags, b.Tags = nilIfEmpty(a.Tags), nilIfEmpty(b.Tags)
+	return reflect.DeepEqual(a, b)
+}
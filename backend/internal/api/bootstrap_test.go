@@ -0,0 +1,104 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestBootstrapIsIdempotent(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+	router := gin.New()
+	router.PUT("/admin/bootstrap", h.AdminBootstrap)
+	db.UpsertClient(h.Store, "admin", "Admin", "CODEA", 0)
+	db.UpdateClientAdminStatus(h.Store, "admin", true)
+
+	type result struct {
+		DryRun  bool              `json:"dry_run"`
+		Changed int               `json:"changed"`
+		Changes []bootstrapChange `json:"changes"`
+		Error   string            `json:"error"`
+	}
+	apply := func(query, doc string) (int, result) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/admin/bootstrap"+query, bytes.NewBufferString(doc))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Client-ID", "admin")
+		router.ServeHTTP(w, req)
+		var r result
+		json.Unmarshal(w.Body.Bytes(), &r)
+		return w.Code, r
+	}
+
+	doc := `{
+		"settings": {"title": "Team Depot", "quota_bytes": 1073741824, "allowed_extensions": ["ZIP", "tar"]},
+		"clients": [
+			{"name": "CI", "recovery_code": "ci-runner-01"},
+			{"name": "Ops", "recovery_code": "OPS-TEAM-01", "is_admin": true}
+		],
+		"folders": [
+			{"recovery_code": "CI-RUNNER-01", "folder": "/builds/", "tags": ["ci"], "retention_seconds": 86400}
+		]
+	}`
+
+	// A dry run reports the changes without making them
+	code, r := apply("?dry_run=true", doc)
+	if code != http.StatusOK || !r.DryRun || r.Changed != 4 {
+		t.Fatalf("unexpected plan %d %+v", code, r)
+	}
+	if db.GetSettings(h.Store).Title != "" {
+		t.Error("expected the dry run to leave the settings alone")
+	}
+
+	code, r = apply("", doc)
+	if code != http.StatusOK || r.Changed != 4 {
+		t.Fatalf("unexpected result %d %+v", code, r)
+	}
+	settings := db.GetSettings(h.Store)
+	if settings.Title != "Team Depot" || settings.QuotaBytes != 1<<30 || len(settings.AllowedExtensions) != 2 || settings.AllowedExtensions[0] != ".zip" {
+		t.Errorf("unexpected settings %+v", settings)
+	}
+	ci, ops := r.Changes[1].ID, r.Changes[2].ID
+	if client, err := db.GetClient(h.Store, ops); err != nil || !client.IsAdmin || client.RecoveryCode != "OPS-TEAM-01" {
+		t.Errorf("expected Ops to be an admin, got %+v", client)
+	}
+	if p, err := db.GetFolderPolicy(h.Store, ci, "builds"); err != nil || p.Retention() != 86400 || len(p.Tags) != 1 {
+		t.Errorf("unexpected folder policy %+v", p)
+	}
+
+	// Applied again, nothing changes
+	if _, r = apply("", doc); r.Changed != 0 || len(r.Changes) != 4 {
+		t.Errorf("expected no changes, got %+v", r)
+	}
+
+	// A changed client is updated and pruning drops unlisted folders
+	db.SaveFolderPolicy(h.Store, db.FolderPolicy{OwnerID: ci, Folder: "scratch"})
+	code, r = apply("", `{"clients": [{"name": "CI runner", "recovery_code": "CI-RUNNER-01"}], "prune": true}`)
+	if code != http.StatusOK || r.Changed != 3 {
+		t.Fatalf("unexpected result %d %+v", code, r)
+	}
+	if client, _ := db.GetClient(h.Store, ci); client.Name != "CI runner" {
+		t.Errorf("expected the client to be renamed, got %q", client.Name)
+	}
+	if policies, _ := db.ListFolderPolicies(h.Store, ci); len(policies) != 0 {
+		t.Errorf("expected the folder policies to be pruned, got %+v", policies)
+	}
+	if db.GetSettings(h.Store).Title != "Team Depot" {
+		t.Error("expected settings left out of the document to stay")
+	}
+
+	// Invalid documents change nothing
+	code, r = apply("", `{"clients": [{"name": "New", "recovery_code": "NEW-CLIENT-1"}], "folders": [{"owner_id": "ghost", "folder": "x"}]}`)
+	if code != http.StatusBadRequest || r.Error == "" {
+		t.Errorf("expected an unknown owner to be refused, got %d %+v", code, r)
+	}
+	if clients, _ := db.ListClients(h.Store); len(clients) != 3 {
+		t.Errorf("expected no client to be created, got %d clients", len(clients))
+	}
+}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/storage"
+	"github.com/celerix/depot/internal/tenant"
+	"github.com/gin-gonic/gin"
+)
+
+// capacityForecast projects when a store runs out of room. CapacityBytes
+// is zero when it is unlimited; ExhaustedAt and DaysLeft are only set when
+// it is limited and growing.
+type capacityForecast struct {
+	UsedBytes     int64 `json:"used_bytes"`
+	CapacityBytes int64 `json:"capacity_bytes"`
+	GrowthPerDay  int64 `json:"growth_bytes_per_day"`
+	Samples       int   `json:"samples"`
+	DaysLeft      *int  `json:"days_left,omitempty"`
+	ExhaustedAt   int64 `json:"exhausted_at,omitempty"`
+}
+
+type tenantForecast struct {
+	TenantID string `json:"tenant_id"`
+	Name     string `json:"name"`
+	capacityForecast
+}
+
+type capacityResponse struct {
+	Days    int               `json:"days"`
+	Quota   capacityForecast  `json:"quota"`
+	Volume  *capacityForecast `json:"volume,omitempty"`
+	Tenants []tenantForecast  `json:"tenants,omitempty"`
+}
+
+// AdminGetCapacity projects when storage will be exhausted from the growth
+// of the daily snapshots over the last ?days= (default 30): the depot's
+// quota, the storage volume, and each tenant's quota.
+func (h *Handler) AdminGetCapacity(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days < 2 || days > maxAnalyticsDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "days must be between 2 and 366"})
+		return
+	}
+
+	now := h.now()
+	since := db.StorageDay(now.AddDate(0, 0, -days+1))
+	growth, samples := storageGrowth(h.Store, since)
+	resp := capacityResponse{Days: days}
+
+	quota := h.QuotaBytes
+	if quota <= 0 {
+		quota = db.GetSettings(h.Store).QuotaBytes
+	}
+	resp.Quota = newCapacityForecast(db.StorageUsed(h.Store), quota, growth, samples, now)
+
+	// The volume is shared with whatever else lives on it, so only the
+	// depot's own growth is projected onto its free space
+	usage := h.volumeUsage
+	if usage == nil {
+		usage = storage.VolumeUsage
+	}
+	if total, free, err := usage(h.StorageDir); err == nil && total > 0 {
+		f := newCapacityForecast(int64(total-free), int64(total), growth, samples, now)
+		resp.Volume = &f
+	}
+
+	if h.Tenants != nil {
+		tenants, err := db.ListTenants(h.Store)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list tenants"})
+			return
+		}
+		resp.Tenants = make([]tenantForecast, 0, len(tenants))
+		for _, t := range tenants {
+			store := tenant.Scope(h.Store, t.ID)
+			quota := t.QuotaBytes
+			if quota <= 0 {
+				quota = db.GetSettings(store).QuotaBytes
+			}
+			growth, samples := storageGrowth(store, since)
+			resp.Tenants = append(resp.Tenants, tenantForecast{
+				TenantID:         t.ID,
+				Name:             t.Name,
+				capacityForecast: newCapacityForecast(db.StorageUsed(store), quota, growth, samples, now),
+			})
+		}
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func newCapacityForecast(used, capacity int64, growth float64, samples int, now time.Time) capacityForecast {
+	f := capacityForecast{UsedBytes: used, CapacityBytes: capacity, GrowthPerDay: int64(growth), Samples: samples}
+	if capacity <= 0 {
+		return f
+	}
+	if used >= capacity {
+		f.DaysLeft = new(int)
+		f.ExhaustedAt = now.Unix()
+		return f
+	}
+	if growth <= 0 {
+		return f
+	}
+	left := float64(capacity-used) / growth
+	days := int(left)
+	f.DaysLeft = &days
+	f.ExhaustedAt = now.Add(time.Duration(left * float64(24*time.Hour))).Unix()
+	return f
+}
+
+// storageGrowth fits a line through the depot's total snapshots since the
+// given day by least squares and returns its slope in bytes per day, with
+// the number of snapshots it is based on. Fewer than two give no growth.
+func storageGrowth(store db.CelerixStore, since string) (float64, int) {
+	snaps, err := db.ListStorageSnapshots(store, "", since)
+	if err != nil || len(snaps) < 2 {
+		return 0, len(snaps)
+	}
+	first, err := time.Parse("2006-01-02", snaps[0].Day)
+	if err != nil {
+		return 0, len(snaps)
+	}
+	var n, sumX, sumY, sumXY, sumXX float64
+	for _, snap := range snaps {
+		day, err := time.Parse("2006-01-02", snap.Day)
+		if err != nil {
+			continue
+		}
+		x := day.Sub(first).Hours() / 24
+		y := float64(snap.Bytes)
+		n++
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	denom := n*sumXX - sumX*sumX
+	if denom == 0 {
+		return 0, len(snaps)
+	}
+	return (n*sumXY - sumX*sumY) / denom, len(snaps)
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/tenant"
+	"github.com/gin-gonic/gin"
+)
+
+func TestAdminGetCapacity(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	h.Now = func() time.Time { return now }
+	h.QuotaBytes = 10000
+	h.volumeUsage = func(string) (uint64, uint64, error) { return 100000, 50000, nil }
+	h.Tenants = &tenant.Registry{Store: h.Store}
+
+	router := gin.Default()
+	router.GET("/admin/capacity", h.AdminGetCapacity)
+	db.UpsertClient(h.Store, "admin", "Admin", "CODEA", 0)
+	db.UpdateClientAdminStatus(h.Store, "admin", true)
+
+	// The depot grows by 1000 bytes a day; a snapshot outside the window
+	// would flatten the line
+	db.SaveStorageSnapshot(h.Store, db.StorageSnapshot{Day: "2026-08-01", Bytes: 5000})
+	for i, day := range []string{"2026-10-14", "2026-10-15", "2026-10-16"} {
+		db.SaveStorageSnapshot(h.Store, db.StorageSnapshot{Day: day, Bytes: int64(i+1) * 1000})
+	}
+	db.SaveTenant(h.Store, db.TenantRecord{ID: "acme", Name: "Acme", QuotaBytes: 5000})
+	acme := tenant.Scope(h.Store, "acme")
+	db.SaveStorageSnapshot(acme, db.StorageSnapshot{Day: "2026-10-10", Bytes: 0})
+	db.SaveStorageSnapshot(acme, db.StorageSnapshot{Day: "2026-10-16", Bytes: 3000})
+	db.SaveTenant(h.Store, db.TenantRecord{ID: "idle", Name: "Idle", QuotaBytes: 5000})
+
+	get := func(clientID, query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/admin/capacity"+query, nil)
+		req.Header.Set("X-Client-ID", clientID)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := get("client-a", ""); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a client, got %d", w.Code)
+	}
+	if w := get("admin", "?days=1"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a one-day window, got %d", w.Code)
+	}
+
+	w := get("admin", "")
+	var resp capacityResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("unexpected response %d %s", w.Code, w.Body.String())
+	}
+	q := resp.Quota
+	if q.GrowthPerDay != 1000 || q.Samples != 3 || q.DaysLeft == nil || *q.DaysLeft != 10 || q.ExhaustedAt != now.AddDate(0, 0, 10).Unix() {
+		t.Errorf("expected the quota to run out in 10 days, got %+v", q)
+	}
+	if v := resp.Volume; v == nil || v.UsedBytes != 50000 || v.DaysLeft == nil || *v.DaysLeft != 50 {
+		t.Errorf("expected the volume to run out in 50 days, got %+v", v)
+	}
+	if len(resp.Tenants) != 2 {
+		t.Fatalf("expected two tenants, got %+v", resp.Tenants)
+	}
+	for _, f := range resp.Tenants {
+		switch f.TenantID {
+		case "acme":
+			if f.GrowthPerDay != 500 || f.DaysLeft == nil || *f.DaysLeft != 10 {
+				t.Errorf("expected acme to run out in 10 days, got %+v", f)
+			}
+		case "idle":
+			if f.Samples != 0 || f.DaysLeft != nil {
+				t.Errorf("expected no projection without snapshots, got %+v", f)
+			}
+		}
+	}
+
+	// Without a quota there is nothing to run out of
+	h.QuotaBytes = 0
+	resp = capacityResponse{}
+	json.Unmarshal(get("admin", "").Body.Bytes(), &resp)
+	if resp.Quota.DaysLeft != nil || resp.Quota.GrowthPerDay != 1000 {
+		t.Errorf("expected growth but no projection, got %+v", resp.Quota)
+	}
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+const maxChanges = 1000
+
+// ListChanges returns the create, update and delete events of the caller's
+// files after the since cursor, oldest first, so integrations can follow
+// changes instead of re-listing everything. A file transferred to another
+// owner shows up as a delete for its previous owner. Admins can pass
+// all=true for every file.
+//
+// Start with since=0. A 410 means the journal no longer reaches back to the
+// cursor; re-list the files and continue from the cursor in the response.
+func (h *Handler) ListChanges(c *gin.Context) {
+	ownerID := c.GetHeader("X-Client-ID")
+	if ownerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
+		return
+	}
+	if h.isAdmin(c) && c.Query("all") == "true" {
+		ownerID = ""
+	}
+
+	since, err := strconv.ParseInt(c.DefaultQuery("since", "0"), 10, 64)
+	if err != nil || since < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "since must be a cursor returned by this endpoint, or 0"})
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if limit < 1 || limit > maxChanges {
+		limit = maxChanges
+	}
+
+	head, floor := db.ChangeCursor(h.Store)
+	if since+1 < floor {
+		c.JSON(http.StatusGone, gin.H{"error": "Cursor is older than the change journal", "cursor": head})
+		return
+	}
+
+	entries, err := db.ListChanges(h.Store, ownerID, since, limit+1)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list changes"})
+		return
+	}
+	hasMore := len(entries) > limit
+	if hasMore {
+		entries = entries[:limit]
+	}
+	for i := range entries {
+		if ownerID != "" && entries[i].OwnerID != ownerID {
+			entries[i].Op = db.ChangeDelete
+		}
+	}
+
+	next := max(since, head)
+	if hasMore {
+		next = entries[len(entries)-1].Seq
+	} else if len(entries) > 0 {
+		next = max(next, entries[len(entries)-1].Seq)
+	}
+	c.JSON(http.StatusOK, gin.H{"changes": entries, "cursor": next, "has_more": hasMore})
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestListChanges(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.GET("/changes", h.ListChanges)
+
+	type page struct {
+		Changes []db.ChangeEntry `json:"changes"`
+		Cursor  int64            `json:"cursor"`
+		HasMore bool             `json:"has_more"`
+	}
+	get := func(clientID, query string) (int, page) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/changes"+query, nil)
+		req.Header.Set("X-Client-ID", clientID)
+		router.ServeHTTP(w, req)
+		var p page
+		json.Unmarshal(w.Body.Bytes(), &p)
+		return w.Code, p
+	}
+
+	a := uploadTestFile(t, router, "client-a", "a.txt", []byte("one"))["id"].(string)
+	uploadTestFile(t, router, "client-b", "b.txt", []byte("two"))
+	if err := db.UpdateFileRecord(h.Store, a, "renamed.txt", "client-a", false); err != nil {
+		t.Fatal(err)
+	}
+
+	code, p := get("client-a", "?since=0")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if len(p.Changes) != 2 || p.Changes[0].Op != db.ChangeCreate || p.Changes[1].Op != db.ChangeUpdate || p.Changes[1].Name != "renamed.txt" {
+		t.Fatalf("expected create then update of the caller's file, got %+v", p.Changes)
+	}
+	cursor := p.Cursor
+
+	// Paging hands back a cursor to continue from
+	_, first := get("client-a", "?since=0&limit=1")
+	if !first.HasMore || len(first.Changes) != 1 {
+		t.Fatalf("expected one change and more to come, got %+v", first)
+	}
+	if _, rest := get("client-a", "?since="+strconv.FormatInt(first.Cursor, 10)); len(rest.Changes) != 1 || rest.Changes[0].Op != db.ChangeUpdate {
+		t.Errorf("expected the update on the next page, got %+v", rest.Changes)
+	}
+
+	// Handing the file to someone else is a delete for its previous owner
+	if err := db.UpdateFileRecord(h.Store, a, "renamed.txt", "client-b", false); err != nil {
+		t.Fatal(err)
+	}
+	_, p = get("client-a", "?since="+strconv.FormatInt(cursor, 10))
+	if len(p.Changes) != 1 || p.Changes[0].Op != db.ChangeDelete || p.Changes[0].FileID != a {
+		t.Errorf("expected a delete after the transfer, got %+v", p.Changes)
+	}
+	_, p = get("client-b", "?since="+strconv.FormatInt(cursor, 10))
+	if len(p.Changes) != 1 || p.Changes[0].Op != db.ChangeUpdate {
+		t.Errorf("expected the new owner to see the update, got %+v", p.Changes)
+	}
+
+	// Nothing new leaves the cursor where it was
+	_, p = get("client-a", "?since="+strconv.FormatInt(p.Cursor, 10))
+	if len(p.Changes) != 0 || p.HasMore {
+		t.Errorf("expected no changes, got %+v", p)
+	}
+
+	if code, _ := get("client-a", "?since=soon"); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a bad cursor, got %d", code)
+	}
+	if _, err := db.PruneChanges(h.Store, h.now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := get("client-a", "?since=0"); code != http.StatusGone {
+		t.Errorf("expected 410 for a pruned cursor, got %d", code)
+	}
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/notify"
+	"github.com/gin-gonic/gin"
+)
+
+type chatIntegrationInput struct {
+	Enabled    bool     `json:"enabled"`
+	WebhookURL string   `json:"webhook_url"`
+	Folders    []string `json:"folders"`
+	Tags       []string `json:"tags"`
+}
+
+func (in *chatIntegrationInput) validate() error {
+	if in.WebhookURL == "" {
+		if in.Enabled {
+			return fmt.Errorf("webhook_url is required")
+		}
+		return nil
+	}
+	u, err := url.Parse(in.WebhookURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("webhook_url must be an http(s) URL")
+	}
+	return nil
+}
+
+func (h *Handler) getChatIntegration(c *gin.Context, ownerID string) {
+	ci, err := db.GetChatIntegration(h.Store, ownerID)
+	if err != nil {
+		ci = &db.ChatIntegration{OwnerID: ownerID}
+	}
+	c.JSON(http.StatusOK, ci)
+}
+
+func (h *Handler) saveChatIntegration(c *gin.Context, ownerID string) {
+	var input chatIntegrationInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := input.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ci := db.ChatIntegration{
+		OwnerID:    ownerID,
+		Enabled:    input.Enabled,
+		WebhookURL: input.WebhookURL,
+		Folders:    input.Folders,
+		Tags:       input.Tags,
+	}
+	if err := db.SaveChatIntegration(h.Store, ci); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save integration"})
+		return
+	}
+	h.getChatIntegration(c, ownerID)
+}
+
+func (h *Handler) GetClientChatIntegration(c *gin.Context) {
+	ownerID := c.GetHeader("X-Client-ID")
+	if ownerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
+		return
+	}
+	h.getChatIntegration(c, ownerID)
+}
+
+func (h *Handler) UpdateClientChatIntegration(c *gin.Context) {
+	ownerID := c.GetHeader("X-Client-ID")
+	if ownerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
+		return
+	}
+	h.saveChatIntegration(c, ownerID)
+}
+
+func (h *Handler) AdminGetChatIntegration(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+	h.getChatIntegration(c, "")
+}
+
+func (h *Handler) AdminUpdateChatIntegration(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+	h.saveChatIntegration(c, "")
+}
+
+// announce posts the upload to the owner's and the instance-wide chat
+// integrations that match it.
+func (h *Handler) announce(record db.FileRecord) error {
+	owners := []string{""}
+	if record.OwnerID != "" {
+		owners = append(owners, record.OwnerID)
+	}
+	var targets []*db.ChatIntegration
+	for _, owner := range owners {
+		ci, err := db.GetChatIntegration(h.Store, owner)
+		if err == nil && ci.Matches(&record) {
+			targets = append(targets, ci)
+		}
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+
+	who := "Someone"
+	if client, err := db.GetClient(h.Store, record.OwnerID); err == nil && client.Name != "" {
+		who = client.Name
+	}
+	where := ""
+	if record.Folder != "" {
+		where = " in " + record.Folder
+	}
+	// Without a public URL the link is relative, which chat clients won't
+	// turn into a link, so PUBLIC_URL should be set when using this
+	link := fmt.Sprintf("%s/api/download/%s", h.PublicURL, record.DownloadLink)
+	text := fmt.Sprintf("%s shared %s (%s)%s: %s", who, record.OriginalName, humanSize(record.Size), where, link)
+
+	var firstErr error
+	sent := make(map[string]bool)
+	for _, ci := range targets {
+		if sent[ci.WebhookURL] {
+			continue
+		}
+		sent[ci.WebhookURL] = true
+		err := notify.Webhook(ci.WebhookURL, notify.Message{Event: "file.announced", Text: text})
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func humanSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
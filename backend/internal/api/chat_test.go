@@ -0,0 +1,89 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestChatAnnouncements(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+	h.PublicURL = "https://depot.example"
+
+	var mu sync.Mutex
+	var posted []string
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg struct {
+			Text string `json:"text"`
+		}
+		json.NewDecoder(r.Body).Decode(&msg)
+		mu.Lock()
+		posted = append(posted, msg.Text)
+		mu.Unlock()
+	}))
+	defer hook.Close()
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.PUT("/persona/integrations/chat", h.UpdateClientChatIntegration)
+
+	w := httptest.NewRecorder()
+	body := `{"enabled": true, "webhook_url": "` + hook.URL + `", "folders": ["/releases/"]}`
+	req, _ := http.NewRequest("PUT", "/persona/integrations/chat", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Client-ID", "client-a")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("saving integration failed: %s", w.Body.String())
+	}
+
+	upload := func(name, folder, tags string) {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("file", name)
+		part.Write([]byte(name))
+		writer.WriteField("folder", folder)
+		writer.WriteField("tags", tags)
+		writer.Close()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/upload", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		req.Header.Set("X-Client-ID", "client-a")
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("upload failed: %s", w.Body.String())
+		}
+	}
+
+	upload("quiet.txt", "scratch", "")
+	upload("v1.zip", "releases/v1", "")
+	upload("news.txt", "", "#Announce, misc")
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(posted) != 2 {
+		t.Fatalf("expected 2 announcements, got %v", posted)
+	}
+	if !strings.Contains(posted[0], "v1.zip") || !strings.Contains(posted[0], "in releases/v1") ||
+		!strings.Contains(posted[0], "https://depot.example/api/download/") {
+		t.Errorf("unexpected announcement %q", posted[0])
+	}
+	if !strings.Contains(posted[1], "news.txt") {
+		t.Errorf("expected tagged upload to be announced, got %q", posted[1])
+	}
+
+	files, _ := db.ListFiles(h.Store, db.ListFilesOptions{OwnerID: "client-a", Folder: "releases"})
+	if files.Total != 1 {
+		t.Errorf("expected folder filter to find 1 file, got %d", files.Total)
+	}
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+// maxChecksumSelection caps how many links one manifest request can name.
+const maxChecksumSelection = 1000
+
+type checksumEntry struct {
+	SHA256 string
+	Name   string
+}
+
+// sha256sumLine formats an entry the way sha256sum writes it, escaping
+// names with backslashes or newlines as GNU coreutils does.
+func sha256sumLine(e checksumEntry) string {
+	if !strings.ContainsAny(e.Name, "\\\n") {
+		return e.SHA256 + "  " + e.Name + "\n"
+	}
+	name := strings.NewReplacer("\\", "\\\\", "\n", "\\n").Replace(e.Name)
+	return "\\" + e.SHA256 + "  " + name + "\n"
+}
+
+// serveChecksums answers with the SHA256SUMS manifest of the entries or,
+// with ?signature=true, with its detached Ed25519 signature by the
+// instance key published at /api/signing-key. The manifest is sorted, so
+// both requests agree on its bytes as long as the files don't change.
+func (h *Handler) serveChecksums(c *gin.Context, entries []checksumEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Name != entries[j].Name {
+			return entries[i].Name < entries[j].Name
+		}
+		return entries[i].SHA256 < entries[j].SHA256
+	})
+	var manifest strings.Builder
+	for _, e := range entries {
+		manifest.WriteString(sha256sumLine(e))
+	}
+
+	c.Header("X-Content-Type-Options", "nosniff")
+	if c.Query("signature") != "true" {
+		c.Header("Content-Disposition", contentDisposition("attachment", "SHA256SUMS"))
+		c.String(http.StatusOK, "%s", manifest.String())
+		return
+	}
+	key, err := db.SigningKey(h.Store)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load the signing key"})
+		return
+	}
+	c.Header("Content-Disposition", contentDisposition("attachment", "SHA256SUMS.sig"))
+	c.String(http.StatusOK, "%s\n", base64.StdEncoding.EncodeToString(ed25519.Sign(key, []byte(manifest.String()))))
+}
+
+// GetFolderChecksums returns a manifest of the caller's files in ?folder=
+// and its subfolders, named by their path below it, so
+// `sha256sum -c SHA256SUMS` checks a downloaded copy of the folder.
+func (h *Handler) GetFolderChecksums(c *gin.Context) {
+	ownerID := c.GetHeader("X-Client-ID")
+	if ownerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
+		return
+	}
+	folder := db.NormalizeFolder(c.Query("folder"))
+	if folder == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A folder is required"})
+		return
+	}
+
+	files, err := db.ListOwnerFiles(h.Store, ownerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list files"})
+		return
+	}
+	entries := []checksumEntry{}
+	for _, f := range files {
+		// Link files have no content to check
+		if !f.InFolder(folder) || f.SHA256 == "" {
+			continue
+		}
+		below := strings.TrimPrefix(strings.TrimPrefix(f.Folder, folder), "/")
+		entries = append(entries, checksumEntry{f.SHA256, path.Join(below, f.OriginalName)})
+	}
+	if len(entries) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No files in this folder"})
+		return
+	}
+	h.serveChecksums(c, entries)
+}
+
+// GetSelectionChecksums returns a manifest of the files behind the
+// comma-separated download links in ?links=, for recipients of several
+// links. Every link has to be downloadable, so a manifest is never
+// silently incomplete.
+func (h *Handler) GetSelectionChecksums(c *gin.Context) {
+	var links []string
+	for _, link := range strings.Split(c.Query("links"), ",") {
+		if link = strings.TrimSpace(link); link != "" {
+			links = append(links, link)
+		}
+	}
+	if len(links) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "links is required"})
+		return
+	}
+	if len(links) > maxChecksumSelection {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Too many links"})
+		return
+	}
+
+	entries := make([]checksumEntry, 0, len(links))
+	for _, link := range links {
+		record, err := h.findDownloadRecord(link)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "File not found: " + link})
+			return
+		}
+		if !h.linkAccessible(c, record) {
+			return
+		}
+		if record.Quarantined && !h.isAdmin(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "File is quarantined"})
+			return
+		}
+		if record.SHA256 == "" {
+			continue
+		}
+		entries = append(entries, checksumEntry{record.SHA256, record.OriginalName})
+	}
+	h.serveChecksums(c, entries)
+}
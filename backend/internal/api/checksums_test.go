@@ -0,0 +1,110 @@
+package api
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestChecksumManifests(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.GET("/folders/checksums", h.GetFolderChecksums)
+	router.GET("/files/checksums", h.GetSelectionChecksums)
+
+	upload := func(name, folder, content string) db.FileRecord {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("file", name)
+		part.Write([]byte(content))
+		writer.WriteField("folder", folder)
+		writer.Close()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/upload", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		req.Header.Set("X-Client-ID", "user1")
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("upload failed: %s", w.Body.String())
+		}
+		var record db.FileRecord
+		json.Unmarshal(w.Body.Bytes(), &record)
+		return record
+	}
+	sum := func(content string) string {
+		s := sha256.Sum256([]byte(content))
+		return hex.EncodeToString(s[:])
+	}
+	get := func(url, clientID string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", url, nil)
+		if clientID != "" {
+			req.Header.Set("X-Client-ID", clientID)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	report := upload("report.pdf", "delivery", "report")
+	data := upload("data.csv", "delivery/raw", "data")
+	upload("other.txt", "elsewhere", "other")
+
+	w := get("/folders/checksums?folder=delivery", "user1")
+	if w.Code != http.StatusOK {
+		t.Fatalf("folder manifest failed: %s", w.Body.String())
+	}
+	want := sum("data") + "  raw/data.csv\n" + sum("report") + "  report.pdf\n"
+	if got := w.Body.String(); got != want {
+		t.Errorf("expected manifest %q, got %q", want, got)
+	}
+	if w := get("/folders/checksums?folder=delivery", "user2"); w.Code != http.StatusNotFound {
+		t.Errorf("expected another client's folder to be empty, got %d", w.Code)
+	}
+
+	// Recipients ask by download link, and can check the instance signature
+	links := report.DownloadLink + "," + data.DownloadLink
+	w = get("/files/checksums?links="+links, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("selection manifest failed: %s", w.Body.String())
+	}
+	manifest := w.Body.Bytes()
+	if !strings.Contains(string(manifest), sum("data")+"  data.csv\n") {
+		t.Errorf("expected the selection to use file names, got %q", manifest)
+	}
+	w = get("/files/checksums?signature=true&links="+links, "")
+	sig, _ := base64.StdEncoding.DecodeString(strings.TrimSpace(w.Body.String()))
+	key, _ := db.SigningKey(h.Store)
+	if !ed25519.Verify(key.Public().(ed25519.PublicKey), manifest, sig) {
+		t.Error("expected the signature to verify against the manifest")
+	}
+
+	if w := get("/files/checksums?links="+report.DownloadLink+",missing", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected an unknown link to fail the manifest, got %d", w.Code)
+	}
+	report.ExpiresAt = 1
+	db.SaveFileRecord(h.Store, report)
+	if w := get("/files/checksums?links="+links, ""); w.Code != http.StatusGone {
+		t.Errorf("expected an expired link to fail the manifest, got %d", w.Code)
+	}
+}
+
+func TestSHA256SumEscaping(t *testing.T) {
+	line := sha256sumLine(checksumEntry{"abc", "odd\\name\nhere"})
+	if line != "\\abc  odd\\\\name\\nhere\n" {
+		t.Errorf("unexpected line %q", line)
+	}
+}
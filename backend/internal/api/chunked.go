@@ -0,0 +1,360 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultChunkSize = 8 << 20
+	minChunkSize     = 1 << 20
+	maxChunkSize     = 64 << 20
+	// maxChunks bounds the chunks of a negotiated session; larger files
+	// get larger chunks
+	maxChunks = 10000
+	// chunkSeconds is how long a negotiated chunk takes at the client's
+	// measured upload throughput: long enough to amortize a request, short
+	// enough that a retry loses little
+	chunkSeconds = 5
+	// probeMaxAge is how long a probe result informs negotiation
+	probeMaxAge = 24 * time.Hour
+
+	defaultParallelism = 4
+	maxParallelism     = 8
+
+	// uploadSessionTTL is how long an unfinished resumable upload is kept
+	uploadSessionTTL = 7 * 24 * time.Hour
+)
+
+func (h *Handler) ownedSession(c *gin.Context) (*db.UploadSession, bool) {
+	session, err := db.GetUploadSession(h.Store, c.Param("id"))
+	if err != nil || session.OwnerID != c.GetHeader("X-Client-ID") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload session not found"})
+		return nil, false
+	}
+	return session, true
+}
+
+// negotiateChunkSize picks the chunk size of a session whose client left
+// it to the server: what the client's measured upload throughput (bytes
+// per second, zero if unknown) moves in chunkSeconds, in powers of two
+// between 1 and 64 MiB. Files too large for maxChunks of those get larger
+// chunks, and small files a single chunk.
+func negotiateChunkSize(size, uploadBPS int64) int64 {
+	chunkSize := int64(defaultChunkSize)
+	if uploadBPS > 0 {
+		chunkSize = minChunkSize
+		for chunkSize < maxChunkSize && chunkSize*2 <= uploadBPS*chunkSeconds {
+			chunkSize *= 2
+		}
+	}
+	for chunkSize < maxChunkSize && size > chunkSize*maxChunks {
+		chunkSize *= 2
+	}
+	for chunkSize > minChunkSize && chunkSize/2 >= size {
+		chunkSize /= 2
+	}
+	return chunkSize
+}
+
+// negotiateParallelism is how many chunks the client should send at once:
+// what it asked for, else more on fast links than on slow ones where
+// connections would only compete, never more than there are chunks.
+func negotiateParallelism(requested, totalChunks int, uploadBPS int64) int {
+	parallelism := requested
+	if parallelism <= 0 {
+		switch {
+		case uploadBPS <= 0:
+			parallelism = defaultParallelism
+		case uploadBPS < 1<<20:
+			parallelism = 2
+		case uploadBPS < 16<<20:
+			parallelism = 4
+		default:
+			parallelism = 6
+		}
+	}
+	return max(min(parallelism, maxParallelism, totalChunks), 1)
+}
+
+func (h *Handler) CreateUploadSession(c *gin.Context) {
+	ownerID := c.GetHeader("X-Client-ID")
+	if ownerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
+		return
+	}
+
+	var input struct {
+		Name        string `json:"name" binding:"required"`
+		Size        int64  `json:"size"`
+		ChunkSize   int64  `json:"chunk_size"`
+		Parallelism int    `json:"parallelism"`
+		IsPublic    bool   `json:"is_public"`
+		StripMeta   *bool  `json:"strip_metadata"`
+		Preset      string `json:"preset"`
+		Overwrite   string `json:"overwrite"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Size <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "size must be positive"})
+		return
+	}
+	if !validOverwrite(input.Overwrite) {
+		respondIngestError(c, errInvalidOverwrite)
+		return
+	}
+	// Refuse up front what the preset or an existing file would refuse
+	// after the transfer
+	folder := ""
+	if input.Preset != "" {
+		preset, err := db.GetPreset(h.Store, input.Preset)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown upload preset " + input.Preset})
+			return
+		}
+		if preset.MaxUploadBytes > 0 && input.Size > preset.MaxUploadBytes {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File exceeds the preset's maximum upload size"})
+			return
+		}
+		folder = preset.Folder
+	}
+	if name := h.cleanName(input.Name); input.Overwrite == overwriteRefuse && h.existingFile(ownerID, folder, name) != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "A file named " + name + " already exists"})
+		return
+	}
+
+	chunkSize := input.ChunkSize
+	negotiated := chunkSize <= 0
+	probe := db.GetProbeResult(h.Store, ownerID)
+	if h.now().Sub(time.Unix(probe.UploadedAt, 0)) > probeMaxAge {
+		probe.UploadBPS = 0
+	}
+	if input.Size > maxChunkSize*maxChunks {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File is too large for a chunked upload"})
+		return
+	}
+	if negotiated {
+		chunkSize = negotiateChunkSize(input.Size, probe.UploadBPS)
+	}
+	// A chosen chunk size is kept within bounds, and raised until the
+	// file fits in maxChunks
+	chunkSize = min(max(chunkSize, minChunkSize), maxChunkSize)
+	for chunkSize < maxChunkSize && input.Size > chunkSize*maxChunks {
+		chunkSize = min(chunkSize*2, maxChunkSize)
+	}
+	totalChunks := int((input.Size + chunkSize - 1) / chunkSize)
+
+	id := uuid.New().String()
+	session := db.UploadSession{
+		ID:          id,
+		OwnerID:     ownerID,
+		Name:        input.Name,
+		Size:        input.Size,
+		ChunkSize:   chunkSize,
+		TotalChunks: totalChunks,
+		Parallelism: negotiateParallelism(input.Parallelism, totalChunks, probe.UploadBPS),
+		Negotiated:  negotiated,
+		IsPublic:    input.IsPublic,
+		StripMeta:   h.StripMetadata,
+		Preset:      input.Preset,
+		Overwrite:   input.Overwrite,
+		CreatedAt:   time.Now().Unix(),
+		ChunkDir:    storage.ChunkDir(h.tempDir(), id),
+		Chunks:      make(map[int]string),
+	}
+	if input.StripMeta != nil {
+		session.StripMeta = *input.StripMeta
+	}
+	if err := db.SaveUploadSession(h.Store, session); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, session)
+}
+
+func (h *Handler) GetUploadSession(c *gin.Context) {
+	session, ok := h.ownedSession(c)
+	if !ok {
+		return
+	}
+
+	received := session.Received()
+	c.JSON(http.StatusOK, gin.H{
+		"id":           session.ID,
+		"name":         session.Name,
+		"size":         session.Size,
+		"chunk_size":   session.ChunkSize,
+		"total_chunks": session.TotalChunks,
+		"parallelism":  session.Parallelism,
+		"received":     received,
+	})
+}
+
+func (h *Handler) UploadChunk(c *gin.Context) {
+	session, ok := h.ownedSession(c)
+	if !ok {
+		return
+	}
+
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil || index < 0 || index >= session.TotalChunks {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chunk index"})
+		return
+	}
+
+	expected := session.ChunkLength(index)
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, expected)
+	// A chunk that fails the checks leaves the one received before
+	hash, err := storage.WriteChunk(storage.ContextReader(c.Request.Context(), body), session.ChunkDir, index, expected, c.GetHeader("X-Chunk-SHA256"))
+	switch {
+	case errors.Is(err, storage.ErrChunkSize):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Chunk size mismatch"})
+		return
+	case errors.Is(err, storage.ErrChunkChecksum):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Chunk checksum mismatch"})
+		return
+	case err != nil:
+		if timedOut(c, err) {
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to store chunk: " + err.Error()})
+		return
+	}
+
+	if err := db.SetUploadChunk(h.Store, session.ID, index, hash); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record chunk"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"index": index, "sha256": hash})
+}
+
+func (h *Handler) CompleteUploadSession(c *gin.Context) {
+	session, ok := h.ownedSession(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		SHA256    string `json:"sha256"`
+		Signature string `json:"signature"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if missing := session.TotalChunks - len(session.Chunks); missing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Upload is incomplete", "missing": missing})
+		return
+	}
+
+	id := uuid.New().String()
+	sums := make([]string, session.TotalChunks)
+	for i := range sums {
+		sums[i] = session.Chunks[i]
+	}
+	storedPath, size, hash, err := storage.AssembleChunks(session.ChunkDir, sums, h.StorageDir, id)
+	var corrupt *storage.ChunkError
+	if errors.As(err, &corrupt) {
+		// The chunk has to be sent again
+		storage.RemoveChunk(session.ChunkDir, corrupt.Index)
+		if err := db.DeleteUploadChunk(h.Store, session.ID, corrupt.Index); err != nil {
+			log.Printf("[ERROR] Failed to forget chunk %d of session %s: %v", corrupt.Index, session.ID, err)
+		}
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Chunk checksum mismatch", "chunk": corrupt.Index})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assemble file: " + err.Error()})
+		return
+	}
+	if input.SHA256 != "" && !strings.EqualFold(input.SHA256, hash) {
+		storage.DeleteFile(storedPath)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "File checksum mismatch"})
+		return
+	}
+
+	var signature []byte
+	if input.Signature != "" {
+		signature = []byte(input.Signature)
+	}
+	record, err := h.ingest(c.Request.Context(), stagedFile{ID: id, Path: storedPath, Size: size, SHA256: hash}, ingestOptions{
+		OwnerID:       session.OwnerID,
+		RemoteAddr:    c.ClientIP(),
+		Name:          session.Name,
+		IsPublic:      session.IsPublic,
+		IsAdmin:       h.isAdmin(c),
+		StripMetadata: session.StripMeta,
+		Preset:        session.Preset,
+		Signature:     signature,
+		Overwrite:     session.Overwrite,
+	})
+	if err != nil {
+		respondIngestError(c, err)
+		return
+	}
+	// Content changed by ingest no longer holds the chunks as sent
+	if record.SHA256 == hash {
+		h.saveChunkSources(session, hash)
+	}
+
+	h.removeUploadSession(session)
+
+	c.JSON(http.StatusOK, record)
+}
+
+func (h *Handler) AbortUploadSession(c *gin.Context) {
+	session, ok := h.ownedSession(c)
+	if !ok {
+		return
+	}
+
+	h.removeUploadSession(session)
+
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// removeUploadSession deletes a session and the chunks it received.
+func (h *Handler) removeUploadSession(session *db.UploadSession) {
+	if err := storage.RemoveChunks(session.ChunkDir); err != nil {
+		log.Printf("[ERROR] Failed to clean up chunks for session %s: %v", session.ID, err)
+	}
+	if err := db.DeleteUploadSession(h.Store, session.ID); err != nil {
+		log.Printf("[ERROR] Failed to delete upload session %s: %v", session.ID, err)
+	}
+}
+
+// PruneUploadSessions drops the resumable uploads started more than
+// uploadSessionTTL ago and never completed, with their chunks.
+func (h *Handler) PruneUploadSessions(ctx context.Context) error {
+	sessions, err := db.ListUploadSessions(h.Store)
+	if err != nil {
+		return err
+	}
+	cutoff := h.now().Add(-uploadSessionTTL).Unix()
+	for i := range sessions {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if sessions[i].CreatedAt < cutoff {
+			h.removeUploadSession(&sessions[i])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,295 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestChunkedUploadOutOfOrder(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	router := gin.Default()
+	router.POST("/uploads", h.CreateUploadSession)
+	router.GET("/uploads/:id", h.GetUploadSession)
+	router.PUT("/uploads/:id/chunks/:index", h.UploadChunk)
+	router.POST("/uploads/:id/complete", h.CompleteUploadSession)
+
+	// 4 chunks, the last one short
+	content := bytes.Repeat([]byte("0123456789"), 3*minChunkSize/10+10)
+	sum := sha256.Sum256(content)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/uploads", bytes.NewBufferString(fmt.Sprintf(`{"name": "big.bin", "size": %d, "chunk_size": %d}`, len(content), minChunkSize)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Client-ID", "chunk-client")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("CreateUploadSession failed: %v", w.Body.String())
+	}
+
+	var session map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &session)
+	sessionID := session["id"].(string)
+	if session["total_chunks"].(float64) != 4 {
+		t.Fatalf("expected 4 chunks, got %v", session["total_chunks"])
+	}
+
+	// Completing early is rejected
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/uploads/"+sessionID+"/complete", nil)
+	req.Header.Set("X-Client-ID", "chunk-client")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected 409 for incomplete upload, got %d", w.Code)
+	}
+
+	// A corrupted chunk is rejected by its checksum
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/uploads/"+sessionID+"/chunks/0", bytes.NewReader(content[:minChunkSize]))
+	req.Header.Set("X-Client-ID", "chunk-client")
+	req.Header.Set("X-Chunk-SHA256", "deadbeef")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for bad chunk checksum, got %d", w.Code)
+	}
+
+	// Send the chunks in reverse order, in parallel
+	var wg sync.WaitGroup
+	for i := 3; i >= 0; i-- {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			end := (i + 1) * minChunkSize
+			if end > len(content) {
+				end = len(content)
+			}
+			chunk := content[i*minChunkSize : end]
+			chunkSum := sha256.Sum256(chunk)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("PUT", fmt.Sprintf("/uploads/%s/chunks/%d", sessionID, i), bytes.NewReader(chunk))
+			req.Header.Set("X-Client-ID", "chunk-client")
+			req.Header.Set("X-Chunk-SHA256", hex.EncodeToString(chunkSum[:]))
+			router.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				t.Errorf("UploadChunk %d failed: %v", i, w.Body.String())
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// Failed retries leave the accepted chunk alone
+	chunk := func(i int) []byte { return content[i*minChunkSize : (i+1)*minChunkSize] }
+	put := func(i int, data []byte, sum string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", fmt.Sprintf("/uploads/%s/chunks/%d", sessionID, i), bytes.NewReader(data))
+		req.Header.Set("X-Client-ID", "chunk-client")
+		req.Header.Set("X-Chunk-SHA256", sum)
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	if code := put(1, bytes.Repeat([]byte("x"), minChunkSize), "deadbeef"); code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for a retry with a bad checksum, got %d", code)
+	}
+	if code := put(1, chunk(1)[:10], ""); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a short retry, got %d", code)
+	}
+
+	// A chunk that changed on disk is caught on completion and asked for again
+	stored, _ := db.GetUploadSession(h.Store, sessionID)
+	os.WriteFile(filepath.Join(stored.ChunkDir, "2"), bytes.Repeat([]byte("?"), minChunkSize), 0644)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/uploads/"+sessionID+"/complete", nil)
+	req.Header.Set("X-Client-ID", "chunk-client")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnprocessableEntity || !bytes.Contains(w.Body.Bytes(), []byte(`"chunk":2`)) {
+		t.Fatalf("expected the corrupt chunk to be refused, got %d %s", w.Code, w.Body.String())
+	}
+	if stored, _ := db.GetUploadSession(h.Store, sessionID); len(stored.Chunks) != 3 {
+		t.Errorf("expected the corrupt chunk to be forgotten, got %v", stored.Received())
+	}
+	chunkSum := sha256.Sum256(chunk(2))
+	if code := put(2, chunk(2), hex.EncodeToString(chunkSum[:])); code != http.StatusOK {
+		t.Fatalf("resending the chunk failed: %d", code)
+	}
+
+	w = httptest.NewRecorder()
+	body := fmt.Sprintf(`{"sha256": "%s"}`, hex.EncodeToString(sum[:]))
+	req, _ = http.NewRequest("POST", "/uploads/"+sessionID+"/complete", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Client-ID", "chunk-client")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("CompleteUploadSession failed: %v", w.Body.String())
+	}
+
+	var record map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &record)
+	assembled, err := os.ReadFile(record["stored_path"].(string))
+	if err != nil || !bytes.Equal(assembled, content) {
+		t.Errorf("assembled file does not match uploaded content")
+	}
+}
+
+func TestNegotiateChunks(t *testing.T) {
+	tests := []struct {
+		size, bps   int64
+		chunkSize   int64
+		parallelism int
+	}{
+		{100 << 20, 0, defaultChunkSize, defaultParallelism},
+		{100 << 20, 512 << 10, 2 << 20, 2},
+		{1 << 30, 10 << 20, 32 << 20, 4},
+		{1 << 30, 100 << 20, maxChunkSize, 6},
+		{1 << 40, 0, maxChunkSize, 4},
+		{3 << 20, 100 << 20, 4 << 20, 1},
+		{1000, 0, minChunkSize, 1},
+	}
+	for _, tt := range tests {
+		chunkSize := negotiateChunkSize(tt.size, tt.bps)
+		total := int((tt.size + chunkSize - 1) / chunkSize)
+		parallelism := negotiateParallelism(0, total, tt.bps)
+		if chunkSize != tt.chunkSize || parallelism != tt.parallelism {
+			t.Errorf("size %d at %d B/s: got %d bytes x %d, want %d x %d", tt.size, tt.bps, chunkSize, parallelism, tt.chunkSize, tt.parallelism)
+		}
+	}
+	if got := negotiateParallelism(20, 100, 0); got != maxParallelism {
+		t.Errorf("requested parallelism isn't capped: %d", got)
+	}
+}
+
+func TestUploadSessionNegotiation(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	router := gin.Default()
+	router.POST("/uploads", h.CreateUploadSession)
+
+	create := func(body string) db.UploadSession {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/uploads", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Client-ID", "chunk-client")
+		router.ServeHTTP(w, req)
+		var session db.UploadSession
+		if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &session) != nil {
+			t.Fatalf("CreateUploadSession: %d %s", w.Code, w.Body.String())
+		}
+		return session
+	}
+
+	if s := create(`{"name": "big.bin", "size": 1073741824}`); s.ChunkSize != defaultChunkSize || !s.Negotiated || s.Parallelism != defaultParallelism {
+		t.Errorf("without a probe: %d bytes x %d", s.ChunkSize, s.Parallelism)
+	}
+	db.RecordProbe(h.Store, "chunk-client", true, 100<<20, h.now().Unix())
+	if s := create(`{"name": "big.bin", "size": 1073741824}`); s.ChunkSize != maxChunkSize || s.Parallelism != 6 {
+		t.Errorf("after a fast probe: %d bytes x %d", s.ChunkSize, s.Parallelism)
+	}
+	if s := create(`{"name": "big.bin", "size": 1073741824, "chunk_size": 1048576, "parallelism": 3}`); s.ChunkSize != 1<<20 || s.Negotiated || s.Parallelism != 3 {
+		t.Errorf("the client's choice was overridden: %d bytes x %d", s.ChunkSize, s.Parallelism)
+	}
+
+	// A client's choice is kept within bounds
+	if s := create(`{"name": "big.bin", "size": 1073741824, "chunk_size": 1}`); s.ChunkSize != minChunkSize {
+		t.Errorf("a 1 byte chunk size became %d", s.ChunkSize)
+	}
+	if s := create(`{"name": "big.bin", "size": 1073741824, "chunk_size": 1073741824}`); s.ChunkSize != maxChunkSize {
+		t.Errorf("a 1 GiB chunk size became %d", s.ChunkSize)
+	}
+	if s := create(`{"name": "huge.bin", "size": 107374182400, "chunk_size": 1048576}`); s.TotalChunks > maxChunks {
+		t.Errorf("100 GiB in %d chunks of %d, more than %d", s.TotalChunks, s.ChunkSize, maxChunks)
+	}
+}
+
+func TestUploadSessionSurvivesRestart(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	routes := func(h *Handler) *gin.Engine {
+		router := gin.Default()
+		router.POST("/uploads", h.CreateUploadSession)
+		router.GET("/uploads/:id", h.GetUploadSession)
+		router.PUT("/uploads/:id/chunks/:index", h.UploadChunk)
+		router.POST("/uploads/:id/complete", h.CompleteUploadSession)
+		return router
+	}
+	request := func(router *gin.Engine, method, path string, body []byte) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Client-ID", "chunk-client")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	const chunk = minChunkSize
+	content := bytes.Repeat([]byte("abcdefghijklmnop"), 3*chunk/16) // 3 chunks
+	newSession := fmt.Sprintf(`{"name": "%%s", "size": %d, "chunk_size": %d}`, len(content), chunk)
+	router := routes(h)
+	w := request(router, "POST", "/uploads", []byte(fmt.Sprintf(newSession, "resume.bin")))
+	var session db.UploadSession
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &session) != nil {
+		t.Fatalf("CreateUploadSession: %d %s", w.Code, w.Body.String())
+	}
+	for i := 0; i < 2; i++ {
+		if w := request(router, "PUT", fmt.Sprintf("/uploads/%s/chunks/%d", session.ID, i), content[i*chunk:(i+1)*chunk]); w.Code != http.StatusOK {
+			t.Fatalf("UploadChunk %d: %d %s", i, w.Code, w.Body.String())
+		}
+	}
+
+	// A new handler on the same store picks the upload up where it stopped
+	restarted := &Handler{Store: h.Store, StorageDir: h.StorageDir, CelerixNamespace: h.CelerixNamespace}
+	router = routes(restarted)
+	w = request(router, "GET", "/uploads/"+session.ID, nil)
+	var status struct {
+		Received []int `json:"received"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &status)
+	if w.Code != http.StatusOK || len(status.Received) != 2 {
+		t.Fatalf("session after restart: %d %s", w.Code, w.Body.String())
+	}
+	if w := request(router, "PUT", "/uploads/"+session.ID+"/chunks/2", content[2*chunk:]); w.Code != http.StatusOK {
+		t.Fatalf("UploadChunk 2: %d %s", w.Code, w.Body.String())
+	}
+	if w := request(router, "POST", "/uploads/"+session.ID+"/complete", nil); w.Code != http.StatusOK {
+		t.Fatalf("CompleteUploadSession: %d %s", w.Code, w.Body.String())
+	}
+	if _, err := db.GetUploadSession(h.Store, session.ID); err == nil {
+		t.Error("completed session was kept")
+	}
+
+	// Abandoned sessions are pruned with their chunks
+	w = request(router, "POST", "/uploads", []byte(fmt.Sprintf(newSession, "left.bin")))
+	json.Unmarshal(w.Body.Bytes(), &session)
+	request(router, "PUT", "/uploads/"+session.ID+"/chunks/0", content[:chunk])
+	stale, _ := db.GetUploadSession(h.Store, session.ID)
+	restarted.Now = func() time.Time { return time.Now().Add(uploadSessionTTL + time.Hour) }
+	if err := restarted.PruneUploadSessions(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetUploadSession(h.Store, session.ID); err == nil {
+		t.Error("abandoned session was kept")
+	}
+	if _, err := os.Stat(stale.ChunkDir); !os.IsNotExist(err) {
+		t.Errorf("chunks of the abandoned session were kept: %v", err)
+	}
+	if _, err := h.Store.Get(db.SystemPersona, db.AppID, db.UploadChunkKeyPrefix+session.ID+":0"); err == nil {
+		t.Error("chunk records of the abandoned session were kept")
+	}
+}
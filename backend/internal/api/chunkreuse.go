@@ -0,0 +1,140 @@
+package api
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+func validSHA256(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// MatchUploadChunks takes the SHA-256 of each chunk of a session, in
+// order, and fills in the chunks that the client already uploaded as part
+// of an earlier file with the same chunk size, copying them from storage.
+// The response lists the chunks reused this way and those the client still
+// has to send, so re-uploading a slightly changed file only transfers what
+// changed. An empty hash skips a chunk.
+func (h *Handler) MatchUploadChunks(c *gin.Context) {
+	session, ok := h.ownedSession(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Chunks []string `json:"chunks" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(input.Chunks) > session.TotalChunks {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "More hashes than chunks"})
+		return
+	}
+
+	have := make(map[int]bool, len(session.Chunks))
+	for i := range session.Chunks {
+		have[i] = true
+	}
+
+	reused := []int{}
+	for i, hash := range input.Chunks {
+		hash = strings.ToLower(hash)
+		if hash == "" || have[i] {
+			continue
+		}
+		if !validSHA256(hash) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid SHA-256 for chunk " + strconv.Itoa(i)})
+			return
+		}
+		if h.reuseChunk(c.Request.Context(), session, i, hash) {
+			if err := db.SetUploadChunk(h.Store, session.ID, i, hash); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record chunk"})
+				return
+			}
+			have[i] = true
+			reused = append(reused, i)
+		}
+	}
+
+	missing := []int{}
+	for i := 0; i < session.TotalChunks; i++ {
+		if !have[i] {
+			missing = append(missing, i)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"reused": reused, "missing": missing})
+}
+
+// reuseChunk copies the chunk from where the owner's earlier upload left
+// it, and reports whether it did. A location whose content is gone or has
+// changed is forgotten.
+func (h *Handler) reuseChunk(ctx context.Context, session *db.UploadSession, index int, hash string) bool {
+	src, err := db.GetChunkSource(h.Store, session.OwnerID, session.ChunkSize, hash)
+	if err != nil || src.Size != session.ChunkLength(index) {
+		return false
+	}
+	forget := func() {
+		if err := db.DeleteChunkSource(h.Store, src.OwnerID, src.ChunkSize, src.SHA256); err != nil {
+			log.Printf("[ERROR] Failed to forget chunk %s: %v", src.SHA256, err)
+		}
+	}
+	blob, err := db.GetBlob(h.Store, src.BlobSHA256)
+	if err != nil || blob.Corrupted || blob.RefCount <= 0 || storage.IsS3Path(blob.StoredPath) {
+		forget()
+		return false
+	}
+	f, err := os.Open(blob.StoredPath)
+	if err != nil {
+		forget()
+		return false
+	}
+	defer f.Close()
+
+	chunkDir := session.ChunkDir
+	body := io.NewSectionReader(f, src.Offset, src.Size)
+	_, err = storage.WriteChunk(storage.ContextReader(ctx, body), chunkDir, index, src.Size, hash)
+	if errors.Is(err, storage.ErrChunkSize) || errors.Is(err, storage.ErrChunkChecksum) {
+		forget()
+	}
+	return err == nil
+}
+
+// saveChunkSources remembers where the chunks of a completed session
+// ended up, for MatchUploadChunks. Single-chunk uploads are left out:
+// POST /api/upload/check already finds whole files.
+func (h *Handler) saveChunkSources(session *db.UploadSession, blobHash string) {
+	if session.TotalChunks < 2 {
+		return
+	}
+	for i, hash := range session.Chunks {
+		err := db.SaveChunkSource(h.Store, db.ChunkSource{
+			OwnerID:    session.OwnerID,
+			ChunkSize:  session.ChunkSize,
+			SHA256:     hash,
+			BlobSHA256: blobHash,
+			Offset:     int64(i) * session.ChunkSize,
+			Size:       session.ChunkLength(i),
+		})
+		if err != nil {
+			log.Printf("[ERROR] Failed to record the chunks of session %s: %v", session.ID, err)
+			return
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestChunkReuse(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	const chunk = minChunkSize
+	router := gin.New()
+	router.POST("/uploads", h.CreateUploadSession)
+	router.POST("/uploads/:id/hashes", h.MatchUploadChunks)
+	router.PUT("/uploads/:id/chunks/:index", h.UploadChunk)
+	router.POST("/uploads/:id/complete", h.CompleteUploadSession)
+
+	do := func(client, method, path string, body []byte) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Client-ID", client)
+		router.ServeHTTP(w, req)
+		return w
+	}
+	// 4 chunks, the last one short
+	original := bytes.Repeat([]byte("0123456789"), 3*chunk/10+10)
+	hashes := func(content []byte) []byte {
+		var sums []string
+		for off := 0; off < len(content); off += chunk {
+			sum := sha256.Sum256(content[off:min(off+chunk, len(content))])
+			sums = append(sums, hex.EncodeToString(sum[:]))
+		}
+		body, _ := json.Marshal(gin.H{"chunks": sums})
+		return body
+	}
+	create := func(client, name string) string {
+		t.Helper()
+		w := do(client, "POST", "/uploads", []byte(fmt.Sprintf(`{"name": %q, "size": %d, "chunk_size": %d}`, name, len(original), chunk)))
+		var session struct{ ID string }
+		if err := json.Unmarshal(w.Body.Bytes(), &session); err != nil || session.ID == "" {
+			t.Fatalf("CreateUploadSession: %d %s", w.Code, w.Body.String())
+		}
+		return session.ID
+	}
+	match := func(client, id string, content []byte) (reused, missing []int) {
+		t.Helper()
+		w := do(client, "POST", "/uploads/"+id+"/hashes", hashes(content))
+		var resp struct{ Reused, Missing []int }
+		if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &resp) != nil {
+			t.Fatalf("MatchUploadChunks: %d %s", w.Code, w.Body.String())
+		}
+		return resp.Reused, resp.Missing
+	}
+	upload := func(client, id string, content []byte, indices []int) {
+		t.Helper()
+		for _, i := range indices {
+			part := content[i*chunk : min((i+1)*chunk, len(content))]
+			if w := do(client, "PUT", fmt.Sprintf("/uploads/%s/chunks/%d", id, i), part); w.Code != http.StatusOK {
+				t.Fatalf("UploadChunk %d: %s", i, w.Body.String())
+			}
+		}
+	}
+	complete := func(client, id string) string {
+		t.Helper()
+		w := do(client, "POST", "/uploads/"+id+"/complete", nil)
+		var record struct {
+			StoredPath string `json:"stored_path"`
+		}
+		if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &record) != nil {
+			t.Fatalf("CompleteUploadSession: %d %s", w.Code, w.Body.String())
+		}
+		return record.StoredPath
+	}
+
+	id := create("alice", "v1.bin")
+	if reused, missing := match("alice", id, original); len(reused) != 0 || len(missing) != 4 {
+		t.Fatalf("first upload reused %v, missing %v; want nothing reused", reused, missing)
+	}
+	upload("alice", id, original, []int{0, 1, 2, 3})
+	complete("alice", id)
+
+	// The second version differs in chunk 2 only
+	changed := bytes.Clone(original)
+	copy(changed[2*chunk+5:], "changed")
+	id = create("alice", "v2.bin")
+	reused, missing := match("alice", id, changed)
+	if fmt.Sprint(reused) != "[0 1 3]" || fmt.Sprint(missing) != "[2]" {
+		t.Fatalf("reused %v, missing %v; want [0 1 3] and [2]", reused, missing)
+	}
+	upload("alice", id, changed, missing)
+	stored, err := os.ReadFile(complete("alice", id))
+	if err != nil || !bytes.Equal(stored, changed) {
+		t.Errorf("file assembled from reused chunks differs from the upload")
+	}
+
+	// Another client's chunks are not reused
+	id = create("bob", "v1.bin")
+	if reused, _ := match("bob", id, original); len(reused) != 0 {
+		t.Errorf("bob reused alice's chunks %v", reused)
+	}
+
+	if w := do("bob", "POST", "/uploads/"+id+"/hashes", []byte(`{"chunks": ["nothex"]}`)); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid hash: %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// cloneFile gives the owner in opts a file of its own with the content of
+// source. The content isn't copied: the new record takes a reference to the
+// same blob, so the copy costs no storage and outlives the original. The
+// copy goes through the upload pipeline, so it is checked against the
+// owner's folder, quota, policy and hooks like an upload of the file. Link
+// files have no content and are copied as they are.
+func (h *Handler) cloneFile(ctx context.Context, source *db.FileRecord, opts ingestOptions) (*db.FileRecord, error) {
+	if source.IsLink() {
+		return h.cloneLink(source, opts.OwnerID, opts.Folder)
+	}
+	switch {
+	case source.External:
+		// Registered where it is, the depot never deletes it
+		opts.External = true
+	case source.SHA256 != "":
+		if _, err := db.RefBlob(h.Store, source.SHA256, source.Size); err != nil {
+			return nil, &ingestError{Status: http.StatusConflict, Message: "The file's content is not deduplicated and can't be copied"}
+		}
+		opts.Referenced = true
+	default:
+		return nil, &ingestError{Status: http.StatusConflict, Message: "The file predates content hashing and can't be copied"}
+	}
+
+	opts.Name = source.OriginalName
+	opts.KeepName = true
+	opts.Metadata = map[string]string{"copied_from": source.ID}
+	opts.CopyOf = source
+	return h.ingest(ctx, stagedFile{ID: uuid.New().String(), Path: source.StoredPath, Size: source.Size, SHA256: source.SHA256}, opts)
+}
+
+// cloneLink copies a link file, which like its original is created without
+// the upload checks.
+func (h *Handler) cloneLink(source *db.FileRecord, ownerID, folder string) (*db.FileRecord, error) {
+	record := db.FileRecord{
+		ID:           uuid.New().String(),
+		OriginalName: source.OriginalName,
+		RawName:      source.RawName,
+		Size:         source.Size,
+		UploadTime:   h.now().Unix(),
+		OwnerID:      ownerID,
+		DownloadLink: uuid.New().String(),
+		SystemTags:   source.SystemTags,
+		Folder:       db.NormalizeFolder(folder),
+		Metadata:     map[string]string{"copied_from": source.ID},
+		External:     source.External,
+		TargetURL:    source.TargetURL,
+	}
+	if err := db.SaveFileRecord(h.Store, record); err != nil {
+		return nil, err
+	}
+	h.postUpload(record)
+	return &record, nil
+}
+
+// linkFromURL takes the link out of a pasted download, raw or embed URL;
+// anything else is taken as the link itself.
+func linkFromURL(s string) string {
+	s = strings.TrimSpace(s)
+	u, err := url.Parse(s)
+	if err != nil || !strings.Contains(s, "/") {
+		return s
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i := 0; i+1 < len(parts); i++ {
+		switch parts[i] {
+		case "download", "raw", "embed":
+			return parts[i+1]
+		}
+	}
+	return parts[len(parts)-1]
+}
+
+// SaveSharedCopy saves a copy of a file shared with the client into its own
+// space. The client pastes the download link, or its URL; password-protected
+// links need the password as for a download, and limited links count it as
+// one.
+func (h *Handler) SaveSharedCopy(c *gin.Context) {
+	ownerID := c.GetHeader("X-Client-ID")
+	if ownerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
+		return
+	}
+	var input struct {
+		Link   string `json:"link" binding:"required"`
+		Folder string `json:"folder"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	source, err := h.findDownloadRecord(linkFromURL(input.Link))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	if !h.linkAccessible(c, source) {
+		return
+	}
+	if source.Quarantined {
+		c.JSON(http.StatusForbidden, gin.H{"error": "File is quarantined"})
+		return
+	}
+	if !h.downloadAllowed(c, source) || !h.chargeDownload(c, source) {
+		return
+	}
+
+	record, err := h.cloneFile(c.Request.Context(), source, ingestOptions{
+		OwnerID:    ownerID,
+		RemoteAddr: c.ClientIP(),
+		IsAdmin:    h.isAdmin(c),
+		Folder:     db.NormalizeFolder(input.Folder),
+	})
+	if err != nil {
+		respondIngestError(c, err)
+		return
+	}
+	h.recordDownload(c, source)
+	c.JSON(http.StatusCreated, record)
+}
+
+// AdminCloneFile copies a file into a client's space, see cloneFile.
+func (h *Handler) AdminCloneFile(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+	source, err := db.GetFileRecord(h.Store, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	var input struct {
+		Folder string `json:"folder"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	target := c.Param("clientId")
+	if target == db.SystemPersona {
+		target = ""
+	} else if _, err := db.GetClient(h.Store, target); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Client " + target + " does not exist"})
+		return
+	}
+
+	record, err := h.cloneFile(c.Request.Context(), source, ingestOptions{
+		OwnerID:    target,
+		RemoteAddr: c.ClientIP(),
+		IsAdmin:    true,
+		Folder:     db.NormalizeFolder(input.Folder),
+	})
+	if err != nil {
+		respondIngestError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, record)
+}
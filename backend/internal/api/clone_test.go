@@ -0,0 +1,98 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestSaveSharedCopy(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.POST("/files/save-copy", h.SaveSharedCopy)
+	router.POST("/files/:id/clone-to/:clientId", h.AdminCloneFile)
+	router.DELETE("/files/:id", h.DeleteFile)
+	db.UpsertClient(h.Store, "admin", "Admin", "CODEA", 0)
+	db.UpdateClientAdminStatus(h.Store, "admin", true)
+	db.UpsertClient(h.Store, "client-b", "B", "CODEB", 0)
+
+	request := func(method, path, clientID, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Client-ID", clientID)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	shared := uploadTestFile(t, router, "client-a", "report.pdf", []byte("quarterly numbers"))
+	link := shared["download_link"].(string)
+
+	w := request("POST", "/files/save-copy", "client-b", `{"link": "https://depot.example/api/download/`+link+`", "folder": "inbox"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected the copy to be saved, got %d %s", w.Code, w.Body.String())
+	}
+	var copied db.FileRecord
+	json.Unmarshal(w.Body.Bytes(), &copied)
+	if copied.OwnerID != "client-b" || copied.Folder != "inbox" || copied.StoredPath != shared["stored_path"] || copied.DownloadLink == link {
+		t.Errorf("unexpected copy %+v", copied)
+	}
+	if blob, _ := db.GetBlob(h.Store, copied.SHA256); blob == nil || blob.RefCount != 2 {
+		t.Errorf("expected the copy to share the stored content, got %+v", blob)
+	}
+
+	// The copy outlives the original
+	if w := request("DELETE", "/files/"+shared["id"].(string), "client-a", ""); w.Code != http.StatusOK {
+		t.Fatalf("deleting the original failed: %s", w.Body.String())
+	}
+	if blob, _ := db.GetBlob(h.Store, copied.SHA256); blob == nil || blob.RefCount != 1 {
+		t.Errorf("expected the copy to keep the content, got %+v", blob)
+	}
+	if w := request("POST", "/files/save-copy", "client-b", `{"link": "`+link+`"}`); w.Code != http.StatusNotFound {
+		t.Errorf("expected a deleted file to be gone, got %d", w.Code)
+	}
+
+	// Saving a copy uses up a limited link like a download
+	limited := uploadTestFile(t, router, "client-a", "once.txt", []byte("read once"))
+	record, _ := db.GetFileRecord(h.Store, limited["id"].(string))
+	record.MaxDownloads = 1
+	db.SaveFileRecord(h.Store, *record)
+	if w := request("POST", "/files/save-copy", "client-b", `{"link": "`+limited["download_link"].(string)+`"}`); w.Code != http.StatusCreated {
+		t.Errorf("expected the first copy to be saved, got %d", w.Code)
+	}
+	if w := request("POST", "/files/save-copy", "client-c", `{"link": "`+limited["download_link"].(string)+`"}`); w.Code != http.StatusGone {
+		t.Errorf("expected the used-up link to be refused, got %d", w.Code)
+	}
+
+	// Admins copy files into any client's space
+	if w := request("POST", "/files/"+copied.ID+"/clone-to/client-b", "client-b", ""); w.Code != http.StatusForbidden {
+		t.Errorf("expected clients to be refused, got %d", w.Code)
+	}
+	if w := request("POST", "/files/"+copied.ID+"/clone-to/nobody", "admin", ""); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected an unknown client to be refused, got %d", w.Code)
+	}
+	w = request("POST", "/files/"+copied.ID+"/clone-to/admin", "admin", `{"folder": "archive"}`)
+	var cloned db.FileRecord
+	json.Unmarshal(w.Body.Bytes(), &cloned)
+	if w.Code != http.StatusCreated || cloned.OwnerID != "admin" || cloned.Metadata["copied_from"] != copied.ID {
+		t.Errorf("expected the admin's copy, got %d %s", w.Code, w.Body.String())
+	}
+
+	// Copies are checked like uploads into the folder
+	db.SaveFolderPolicy(h.Store, db.FolderPolicy{OwnerID: "client-b", Folder: "notes", AllowedExtensions: []string{".txt"}})
+	before, _ := db.GetBlob(h.Store, copied.SHA256)
+	if w := request("POST", "/files/save-copy", "client-b", `{"link": "`+copied.DownloadLink+`", "folder": "notes"}`); w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("expected a pdf to be refused in a text folder, got %d %s", w.Code, w.Body.String())
+	}
+	if after, _ := db.GetBlob(h.Store, copied.SHA256); after == nil || after.RefCount != before.RefCount {
+		t.Errorf("a refused copy kept a reference: %+v", after)
+	}
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+)
+
+func TestClaimsAcrossReplicas(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+	plain := h.Store
+	locker := db.NewStoreLocker(plain)
+	locker.Settle = time.Millisecond
+	h.Store = &db.SharedStore{CelerixStore: plain, Locker: locker}
+
+	// A second server on the same store
+	replica := &Handler{Store: &db.SharedStore{CelerixStore: plain, Locker: locker}, StorageDir: h.StorageDir}
+	// and one that doesn't coordinate, embedded in the same process
+	standalone := &Handler{Store: plain, StorageDir: h.StorageDir}
+
+	if !h.idempotency.start(h.Store, "client-a:key-1") {
+		t.Fatal("first claim was refused")
+	}
+	if replica.idempotency.start(replica.Store, "client-a:key-1") {
+		t.Error("the replica took a key claimed by another server")
+	}
+	if !replica.idempotency.start(replica.Store, "client-a:key-2") {
+		t.Error("the replica was refused a key nobody holds")
+	}
+	if !standalone.idempotency.start(standalone.Store, "client-a:key-1") {
+		t.Error("a server without a Locker was refused a key leased by another")
+	}
+	h.idempotency.done("client-a:key-1")
+	if !replica.idempotency.start(replica.Store, "client-a:key-1") {
+		t.Error("the replica was refused a released key")
+	}
+
+	unlock, ok, err := locker.TryLock("job:test", time.Hour)
+	if err != nil || !ok {
+		t.Fatalf("TryLock: %v %v", ok, err)
+	}
+	if _, ok, _ := locker.TryLock("job:test", time.Hour); ok {
+		t.Error("a held lease was taken again")
+	}
+	unlock()
+	if _, ok, _ := locker.TryLock("job:test", -time.Second); !ok {
+		t.Error("a released lease could not be taken")
+	}
+	if _, ok, _ := locker.TryLock("job:test", time.Hour); !ok {
+		t.Error("an expired lease could not be taken")
+	}
+}
@@ -0,0 +1,292 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/events"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Commands accepted from the command queue.
+const (
+	// CommandRegisterBlob adds a file on the server's disk or an object
+	// in the S3 bucket as a depot file
+	CommandRegisterBlob = "register_blob"
+	// CommandExpireLink makes a download link expire now
+	CommandExpireLink = "expire_link"
+	CommandDeleteFile = "delete_file"
+)
+
+// command is a message on the command queue. ID is its idempotency key:
+// a command that succeeded once is not carried out again.
+type command struct {
+	ID      string          `json:"id"`
+	Command string          `json:"command"`
+	Args    json.RawMessage `json:"args"`
+}
+
+type registerBlobArgs struct {
+	// Path is a file on the server, Key an object in the bucket; exactly
+	// one is set
+	Path string `json:"path"`
+	Key  string `json:"key"`
+	// SHA256 is required for objects, which aren't read
+	SHA256   string   `json:"sha256"`
+	Name     string   `json:"name"`
+	OwnerID  string   `json:"owner_id"`
+	Folder   string   `json:"folder"`
+	Tags     []string `json:"tags"`
+	IsPublic bool     `json:"is_public"`
+	// Mode is how files on disk are imported, see ImportTree; defaults to
+	// reference
+	Mode string `json:"mode"`
+}
+
+type fileArgs struct {
+	FileID string `json:"file_id"`
+	// Link finds the file by its download link instead
+	Link string `json:"link"`
+}
+
+// commandReply is sent to the reply subject of a command.
+type commandReply struct {
+	ID     string          `json:"id"`
+	State  string          `json:"state"`
+	Error  string          `json:"error,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+}
+
+// HandleCommand carries out a message from the command queue and returns
+// the reply for its sender. Every outcome is recorded; failures become dead
+// letters, and a command whose ID already succeeded is answered from the
+// record without running again.
+func (h *Handler) HandleCommand(ctx context.Context, m events.Message) []byte {
+	record := h.runCommand(ctx, m.Body, nil)
+	reply, _ := json.Marshal(commandReply{ID: record.ID, State: record.State, Error: record.Error, Result: record.Result})
+	return reply
+}
+
+// runCommand runs the command in payload, continuing prev when it is a
+// retry of a recorded one.
+func (h *Handler) runCommand(ctx context.Context, payload []byte, prev *db.CommandRecord) *db.CommandRecord {
+	var cmd command
+	decodeErr := json.Unmarshal(payload, &cmd)
+	if decodeErr == nil && cmd.ID == "" {
+		decodeErr = errors.New("id is required")
+	}
+
+	record := prev
+	if record == nil && decodeErr == nil {
+		if existing, err := db.GetCommand(h.Store, cmd.ID); err == nil {
+			if existing.State == db.CommandDone {
+				return existing
+			}
+			record = existing
+		}
+	}
+	if record == nil {
+		record = &db.CommandRecord{ID: cmd.ID, ReceivedAt: h.now().Unix()}
+		if decodeErr != nil {
+			// Undecodable messages are kept under an ID of their own
+			record.ID = uuid.New().String()
+		}
+	}
+	record.Command, record.Payload = cmd.Command, string(payload)
+	record.Attempts++
+
+	var result any
+	err := decodeErr
+	if err == nil {
+		result, err = h.execCommand(ctx, cmd)
+	}
+	record.ProcessedAt = h.now().Unix()
+	record.Result = nil
+	if err != nil {
+		record.State, record.Error = db.CommandFailed, err.Error()
+		log.Printf("[WARN] Command %s (%s) failed: %v", record.ID, record.Command, err)
+	} else {
+		record.State, record.Error = db.CommandDone, ""
+		record.Result, _ = json.Marshal(result)
+	}
+	if err := db.SaveCommand(h.Store, *record); err != nil {
+		log.Printf("[ERROR] Failed to record command %s: %v", record.ID, err)
+	}
+	return record
+}
+
+func (h *Handler) execCommand(ctx context.Context, cmd command) (any, error) {
+	switch cmd.Command {
+	case CommandRegisterBlob:
+		var args registerBlobArgs
+		if err := json.Unmarshal(cmd.Args, &args); err != nil {
+			return nil, fmt.Errorf("invalid args: %w", err)
+		}
+		return h.registerBlob(ctx, args)
+	case CommandExpireLink:
+		record, err := h.commandFile(cmd.Args)
+		if err != nil {
+			return nil, err
+		}
+		updated, err := db.SetFileExpiresAt(h.Store, record.ID, h.now().Unix())
+		if err != nil {
+			return nil, err
+		}
+		return gin.H{"file_id": updated.ID, "expires_at": updated.ExpiresAt}, nil
+	case CommandDeleteFile:
+		record, err := h.commandFile(cmd.Args)
+		if err != nil {
+			return nil, err
+		}
+		if err := h.removeFile(record); err != nil {
+			return nil, err
+		}
+		return gin.H{"file_id": record.ID}, nil
+	case "":
+		return nil, errors.New("command is required")
+	}
+	return nil, fmt.Errorf("unknown command %q", cmd.Command)
+}
+
+func (h *Handler) commandFile(raw json.RawMessage) (*db.FileRecord, error) {
+	var args fileArgs
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, fmt.Errorf("invalid args: %w", err)
+	}
+	var record *db.FileRecord
+	var err error
+	switch {
+	case args.FileID != "":
+		record, err = db.GetFileRecord(h.Store, args.FileID)
+	case args.Link != "":
+		record, err = h.findDownloadRecord(args.Link)
+	default:
+		return nil, errors.New("file_id or link is required")
+	}
+	if err != nil {
+		return nil, errors.New("file not found")
+	}
+	return record, nil
+}
+
+func (h *Handler) registerBlob(ctx context.Context, args registerBlobArgs) (*db.FileRecord, error) {
+	if (args.Path == "") == (args.Key == "") {
+		return nil, errors.New("exactly one of path and key is required")
+	}
+	opts := ingestOptions{
+		OwnerID:  args.OwnerID,
+		Name:     args.Name,
+		IsPublic: args.IsPublic,
+		Folder:   db.NormalizeFolder(args.Folder),
+		Tags:     db.ParseTags(strings.Join(args.Tags, ",")),
+	}
+	if args.OwnerID != "" {
+		client, err := db.GetClient(h.Store, args.OwnerID)
+		if err != nil {
+			return nil, fmt.Errorf("client %s not found", args.OwnerID)
+		}
+		opts.IsAdmin = client.IsAdmin
+	}
+
+	if args.Key != "" {
+		if h.S3 == nil {
+			return nil, errors.New("objects can only be registered with the S3 backend")
+		}
+		if len(args.SHA256) != 64 {
+			return nil, errors.New("sha256 of the object is required")
+		}
+		size, err := h.S3.Head(ctx, args.Key)
+		if err != nil {
+			return nil, fmt.Errorf("object %s not found", args.Key)
+		}
+		if opts.Name == "" {
+			opts.Name = args.Key[strings.LastIndex(args.Key, "/")+1:]
+		}
+		staged := stagedFile{ID: uuid.New().String(), Path: h.S3.Path(args.Key), Size: size, SHA256: strings.ToLower(args.SHA256)}
+		return h.ingest(ctx, staged, opts)
+	}
+
+	mode := args.Mode
+	switch mode {
+	case "":
+		mode = ImportReference
+	case ImportReference, ImportCopy, ImportMove:
+	default:
+		return nil, fmt.Errorf("mode must be %s, %s or %s", ImportReference, ImportCopy, ImportMove)
+	}
+	p, err := filepath.Abs(args.Path)
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range []string{h.StorageDir, h.tempDir()} {
+		dir, _ = filepath.Abs(dir)
+		if rel, err := filepath.Rel(dir, p); err == nil && !strings.HasPrefix(rel, "..") {
+			return nil, errors.New("the depot's own storage cannot be registered")
+		}
+	}
+	if info, err := os.Stat(p); err != nil {
+		return nil, fmt.Errorf("%s not found", args.Path)
+	} else if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", args.Path)
+	}
+	if opts.Name == "" {
+		opts.Name = filepath.Base(p)
+	}
+	return h.importFile(ctx, p, opts, mode)
+}
+
+// AdminListDeadLetters lists the commands that failed, newest first.
+func (h *Handler) AdminListDeadLetters(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+	commands, err := db.ListCommands(h.Store, db.CommandFailed)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list dead letters"})
+		return
+	}
+	c.JSON(http.StatusOK, commands)
+}
+
+// AdminRetryDeadLetter runs a failed command again, e.g. after the file it
+// registers was put in place.
+func (h *Handler) AdminRetryDeadLetter(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+	record, err := db.GetCommand(h.Store, c.Param("id"))
+	if err != nil || record.State != db.CommandFailed {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dead letter not found"})
+		return
+	}
+	c.JSON(http.StatusOK, h.runCommand(c.Request.Context(), []byte(record.Payload), record))
+}
+
+// AdminDismissDeadLetter forgets a failed command.
+func (h *Handler) AdminDismissDeadLetter(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+	record, err := db.GetCommand(h.Store, c.Param("id"))
+	if err != nil || record.State != db.CommandFailed {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dead letter not found"})
+		return
+	}
+	if err := db.DeleteCommand(h.Store, record.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to dismiss dead letter"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/events"
+	"github.com/gin-gonic/gin"
+)
+
+func TestCommandQueue(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+	router := gin.New()
+	router.GET("/admin/commands/dead-letters", h.AdminListDeadLetters)
+	router.POST("/admin/commands/dead-letters/:id/retry", h.AdminRetryDeadLetter)
+	router.DELETE("/admin/commands/dead-letters/:id", h.AdminDismissDeadLetter)
+	db.UpsertClient(h.Store, "admin", "Admin", "CODEA", 0)
+	db.UpdateClientAdminStatus(h.Store, "admin", true)
+	db.UpsertClient(h.Store, "batch", "Batch", "CODEB", 0)
+
+	request := func(method, url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, url, nil)
+		req.Header.Set("X-Client-ID", "admin")
+		router.ServeHTTP(w, req)
+		return w
+	}
+	send := func(body string) commandReply {
+		var reply commandReply
+		raw := h.HandleCommand(context.Background(), events.Message{Body: []byte(body)})
+		if err := json.Unmarshal(raw, &reply); err != nil {
+			t.Fatalf("bad reply %q: %v", raw, err)
+		}
+		return reply
+	}
+
+	blob := filepath.Join(t.TempDir(), "nightly.tar")
+	os.WriteFile(blob, []byte("nightly build"), 0644)
+	register := `{"id": "reg-1", "command": "register_blob", "args": {"path": "` + blob + `", "owner_id": "batch", "folder": "builds", "tags": ["nightly"]}}`
+	reply := send(register)
+	if reply.State != db.CommandDone {
+		t.Fatalf("expected the blob to be registered, got %+v", reply)
+	}
+	var file db.FileRecord
+	json.Unmarshal(reply.Result, &file)
+	if file.OwnerID != "batch" || file.OriginalName != "nightly.tar" || file.Folder != "builds" || !file.External {
+		t.Errorf("unexpected file %+v", file)
+	}
+
+	// Delivered again, the command isn't carried out twice
+	again := send(register)
+	var recorded db.FileRecord
+	json.Unmarshal(again.Result, &recorded)
+	if again.State != db.CommandDone || recorded.ID != file.ID {
+		t.Errorf("expected the recorded outcome, got %+v", again)
+	}
+	if files, _ := db.GetFileRecordsByOwner(h.Store, "batch"); len(files) != 1 {
+		t.Errorf("expected one registered file, got %d", len(files))
+	}
+
+	if r := send(`{"id": "exp-1", "command": "expire_link", "args": {"link": "` + file.DownloadLink + `"}}`); r.State != db.CommandDone {
+		t.Errorf("expected the link to expire, got %+v", r)
+	}
+	if updated, _ := db.GetFileRecord(h.Store, file.ID); updated.ExpiresAt == 0 || updated.ExpiresAt > h.now().Unix() {
+		t.Errorf("expected the link to have expired, got %d", updated.ExpiresAt)
+	}
+
+	// Failures end up as dead letters
+	missing := filepath.Join(t.TempDir(), "later.tar")
+	if r := send(`{"id": "reg-2", "command": "register_blob", "args": {"path": "` + missing + `"}}`); r.State != db.CommandFailed || r.Error == "" {
+		t.Errorf("expected a missing file to fail, got %+v", r)
+	}
+	send(`{"id": "x-1", "command": "reboot"}`)
+	send(`not json`)
+
+	var letters []db.CommandRecord
+	json.Unmarshal(request("GET", "/admin/commands/dead-letters").Body.Bytes(), &letters)
+	if len(letters) != 3 {
+		t.Fatalf("expected three dead letters, got %+v", letters)
+	}
+
+	// Once the file is there, the dead letter can be retried
+	os.WriteFile(missing, []byte("late build"), 0644)
+	w := request("POST", "/admin/commands/dead-letters/reg-2/retry")
+	var retried db.CommandRecord
+	json.Unmarshal(w.Body.Bytes(), &retried)
+	if w.Code != http.StatusOK || retried.State != db.CommandDone || retried.Attempts != 2 {
+		t.Errorf("expected the retry to succeed, got %d %+v", w.Code, retried)
+	}
+	if w := request("DELETE", "/admin/commands/dead-letters/x-1"); w.Code != http.StatusOK {
+		t.Errorf("expected the dead letter to be dismissed, got %d", w.Code)
+	}
+	if w := request("DELETE", "/admin/commands/dead-letters/reg-1"); w.Code != http.StatusNotFound {
+		t.Errorf("expected done commands not to be dead letters, got %d", w.Code)
+	}
+	letters = nil
+	json.Unmarshal(request("GET", "/admin/commands/dead-letters").Body.Bytes(), &letters)
+	if len(letters) != 1 || letters[0].Payload != "not json" {
+		t.Errorf("expected only the undecodable message left, got %+v", letters)
+	}
+}
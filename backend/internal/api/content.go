@@ -0,0 +1,149 @@
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/celerix/depot/internal/classify"
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/events"
+	"github.com/celerix/depot/internal/extract"
+	"github.com/celerix/depot/internal/hooks"
+	"github.com/celerix/depot/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// runJob hands work to the background queue, or runs it inline when no
+// queue is configured (as in tests).
+func (h *Handler) runJob(name string, fn func() error) {
+	if h.Jobs != nil {
+		h.Jobs.Submit(name, fn)
+		return
+	}
+	if err := fn(); err != nil {
+		log.Printf("[ERROR] Job %s failed: %v", name, err)
+	}
+}
+
+// publishEvent puts a lifecycle event about the file on the event bus.
+// scan is the outcome of a file.scanned event.
+func (h *Handler) publishEvent(typ string, record *db.FileRecord, scan string) {
+	tags := record.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	h.Events.Publish(events.Event{
+		Type: typ,
+		Time: h.now().Unix(),
+		File: events.File{
+			ID:       record.ID,
+			Name:     record.OriginalName,
+			OwnerID:  record.OwnerID,
+			Size:     record.Size,
+			SHA256:   record.SHA256,
+			Folder:   record.Folder,
+			Tags:     tags,
+			IsPublic: record.IsPublic,
+		},
+		Scan: scan,
+	})
+}
+
+// postUpload schedules asynchronous processing of a freshly stored file.
+func (h *Handler) postUpload(record db.FileRecord) {
+	if h.Hooks.HasPostUpload() {
+		h.runJob("hooks:"+record.ID, func() error {
+			h.Hooks.PostUpload(context.Background(), hooks.Upload{
+				FileID:   record.ID,
+				OwnerID:  record.OwnerID,
+				Name:     record.OriginalName,
+				Size:     record.Size,
+				SHA256:   record.SHA256,
+				Path:     record.StoredPath,
+				IsPublic: record.IsPublic,
+			})
+			return nil
+		})
+	}
+	h.publishEvent(events.FileUploaded, &record, "")
+	switch {
+	case record.Quarantined:
+		h.publishEvent(events.FileScanned, &record, events.ScanQuarantined)
+	case record.ScannedAt > 0:
+		h.publishEvent(events.FileScanned, &record, events.ScanPassed)
+	}
+	h.runJob("announce:"+record.ID, func() error {
+		return h.announce(record)
+	})
+	h.mirrorUpload(record)
+	// Content processing needs local access to the bytes
+	if storage.IsS3Path(record.StoredPath) {
+		return
+	}
+	if h.AutoTag {
+		h.runJob("classify:"+record.ID, func() error {
+			tags := classify.Classify(record.OriginalName, readHead(record.StoredPath))
+			if len(tags) == 0 {
+				return nil
+			}
+			return db.SetFileSystemTags(h.Store, record.ID, tags)
+		})
+	}
+	if h.ContentIndex && !h.contentIndexOptedOut(record.OwnerID) {
+		h.runJob("extract:"+record.ID, func() error {
+			return h.indexContent(record)
+		})
+	}
+}
+
+func (h *Handler) contentIndexOptedOut(ownerID string) bool {
+	if ownerID == "" {
+		return false
+	}
+	client, err := db.GetClient(h.Store, ownerID)
+	return err == nil && client.ContentIndexOptOut
+}
+
+func (h *Handler) indexContent(record db.FileRecord) error {
+	text, err := extract.Text(record.StoredPath, record.OriginalName)
+	if err != nil || text == "" {
+		return err
+	}
+	return db.SaveFileText(h.Store, record.ID, text)
+}
+
+func (h *Handler) UpdateContentIndexPreference(c *gin.Context) {
+	ownerID := c.GetHeader("X-Client-ID")
+	if ownerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
+		return
+	}
+
+	var input struct {
+		Enabled bool `json:"enabled"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := db.SetClientContentIndexOptOut(h.Store, ownerID, !input.Enabled); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+		return
+	}
+
+	// Opting out also forgets whatever was already extracted
+	if !input.Enabled {
+		files, err := db.GetFileRecordsByOwner(h.Store, ownerID)
+		if err == nil {
+			for _, f := range files {
+				if f.OwnerID == ownerID {
+					_ = db.DeleteFileText(h.Store, f.ID)
+				}
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "enabled": input.Enabled})
+}
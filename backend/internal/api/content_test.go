@@ -0,0 +1,36 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestContentSearch(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+	h.ContentIndex = true
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.GET("/files", h.ListFiles)
+
+	uploadTestFile(t, router, "search-client", "notes.txt", []byte("Quarterly revenue figures"))
+	uploadTestFile(t, router, "search-client", "other.txt", []byte("nothing to see"))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/files?search=REVENUE", nil)
+	req.Header.Set("X-Client-ID", "search-client")
+	router.ServeHTTP(w, req)
+
+	var listResp struct {
+		Files []map[string]interface{} `json:"files"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &listResp)
+	if len(listResp.Files) != 1 || listResp.Files[0]["original_name"] != "notes.txt" {
+		t.Errorf("expected content search to match notes.txt, got %v", w.Body.String())
+	}
+}
@@ -0,0 +1,257 @@
+package api
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// dedupBlob registers freshly stored content in the blob index. If identical
+// content is already stored, the new copy is removed and the path of the
+// existing blob is returned instead.
+func (h *Handler) dedupBlob(ctx context.Context, storedPath string, size int64, hash string) (string, error) {
+	blob, existed, err := db.AcquireBlob(h.Store, hash, size, storedPath)
+	if err != nil {
+		return "", err
+	}
+	if existed && blob.StoredPath != storedPath {
+		if h.storedExists(ctx, blob.StoredPath) {
+			if err := h.deleteStored(storedPath); err != nil {
+				log.Printf("[ERROR] Failed to remove duplicate upload %s: %v", storedPath, err)
+			}
+			return blob.StoredPath, nil
+		}
+		// The blob went missing on disk; adopt the new copy
+		blob.StoredPath = storedPath
+		if err := h.Store.Set(db.SystemPersona, db.AppID, db.BlobKeyPrefix+hash, *blob); err != nil {
+			return "", err
+		}
+	}
+	return storedPath, nil
+}
+
+// releaseStoredFile drops the record's reference to its content and deletes
+// the file from storage once nothing else points at it.
+func (h *Handler) releaseStoredFile(record *db.FileRecord) error {
+	if record.External {
+		return nil
+	}
+	if record.SHA256 == "" {
+		return h.deleteStored(record.StoredPath)
+	}
+	blob, last, err := db.ReleaseBlob(h.Store, record.SHA256)
+	if err != nil {
+		// Unknown blob, fall back to treating the file as unshared
+		return h.deleteStored(record.StoredPath)
+	}
+	if !last {
+		return nil
+	}
+	return h.deleteStored(blob.StoredPath)
+}
+
+// storedExists and deleteStored work on both local paths and S3 objects.
+func (h *Handler) storedExists(ctx context.Context, path string) bool {
+	if storage.IsS3Path(path) {
+		if h.S3 == nil {
+			return false
+		}
+		_, err := h.S3.Head(ctx, h.S3.KeyFromPath(path))
+		return err == nil
+	}
+	return storage.Exists(path)
+}
+
+// deleteStored is cleanup that has to finish, so it doesn't follow the
+// request's context.
+func (h *Handler) deleteStored(path string) error {
+	if storage.IsS3Path(path) {
+		if h.S3 == nil {
+			return fmt.Errorf("S3 storage is not configured")
+		}
+		return h.S3.Delete(context.Background(), h.S3.KeyFromPath(path))
+	}
+	return storage.DeleteFile(path)
+}
+
+// openStored opens stored content, a local file or an S3 object.
+func (h *Handler) openStored(ctx context.Context, path string) (io.ReadCloser, error) {
+	if storage.IsS3Path(path) {
+		if h.S3 == nil {
+			return nil, fmt.Errorf("S3 storage is not configured")
+		}
+		return h.S3.Get(ctx, h.S3.KeyFromPath(path))
+	}
+	return os.Open(path)
+}
+
+const (
+	// possessionBytes is how much of the content an instant upload hashes
+	// to prove it has it
+	possessionBytes = 64 << 10
+	// challengeTTL is how long a possession challenge can be answered
+	challengeTTL = 10 * time.Minute
+)
+
+// possessionChallenge asks for the SHA-256 of the nonce followed by Length
+// bytes of the content from Offset. Hashes of files are no secret (file
+// metadata shows them), so an instant upload has to show it holds the
+// content before it gets a reference to it.
+type possessionChallenge struct {
+	Offset int64  `json:"offset"`
+	Length int64  `json:"length"`
+	Nonce  string `json:"nonce"`
+}
+
+// challengeMAC binds a challenge to the client and content it was issued
+// for. The key is derived from the instance's signing key.
+func (h *Handler) challengeMAC(clientID, hash string, expires, offset, length int64) (string, error) {
+	signingKey, err := db.SigningKey(h.Store)
+	if err != nil {
+		return "", err
+	}
+	key := sha256.Sum256(append(signingKey.Seed(), "upload-check"...))
+	mac := hmac.New(sha256.New, key[:])
+	fmt.Fprintf(mac, "%s\n%s\n%d\n%d\n%d", clientID, hash, expires, offset, length)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// newChallenge picks a random range of the blob and signs it, so answers
+// need no state on the server.
+func (h *Handler) newChallenge(clientID string, blob *db.BlobRecord) (*possessionChallenge, error) {
+	length := min(blob.Size, possessionBytes)
+	var offset int64
+	if blob.Size > length {
+		n, err := rand.Int(rand.Reader, big.NewInt(blob.Size-length+1))
+		if err != nil {
+			return nil, err
+		}
+		offset = n.Int64()
+	}
+	expires := h.now().Add(challengeTTL).Unix()
+	mac, err := h.challengeMAC(clientID, blob.Hash, expires, offset, length)
+	if err != nil {
+		return nil, err
+	}
+	return &possessionChallenge{
+		Offset: offset,
+		Length: length,
+		Nonce:  fmt.Sprintf("%d.%d.%d.%s", expires, offset, length, mac),
+	}, nil
+}
+
+// provesPossession checks the answer to a challenge newChallenge issued to
+// the client for the blob.
+func (h *Handler) provesPossession(ctx context.Context, clientID string, blob *db.BlobRecord, nonce, proof string) bool {
+	var expires, offset, length int64
+	var mac string
+	if n, _ := fmt.Sscanf(strings.ReplaceAll(nonce, ".", " "), "%d %d %d %s", &expires, &offset, &length, &mac); n != 4 {
+		return false
+	}
+	want, err := h.challengeMAC(clientID, blob.Hash, expires, offset, length)
+	if err != nil || !hmac.Equal([]byte(mac), []byte(want)) || h.now().Unix() > expires {
+		return false
+	}
+
+	content, err := h.openStored(ctx, blob.StoredPath)
+	if err != nil {
+		return false
+	}
+	defer content.Close()
+	if seeker, ok := content.(io.Seeker); ok {
+		_, err = seeker.Seek(offset, io.SeekStart)
+	} else {
+		_, err = io.CopyN(io.Discard, content, offset)
+	}
+	if err != nil {
+		return false
+	}
+	sum := sha256.New()
+	sum.Write([]byte(nonce))
+	if _, err := io.CopyN(sum, content, length); err != nil {
+		return false
+	}
+	return hmac.Equal([]byte(strings.ToLower(proof)), []byte(hex.EncodeToString(sum.Sum(nil))))
+}
+
+// UploadCheck creates a file from content the depot already has, so the
+// client can skip sending it. A known hash is answered with a challenge;
+// the file is created when the check is repeated with the nonce and the
+// proof. It then goes through the upload pipeline like any upload.
+func (h *Handler) UploadCheck(c *gin.Context) {
+	ownerID := c.GetHeader("X-Client-ID")
+	if ownerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
+		return
+	}
+
+	var input struct {
+		SHA256   string `json:"sha256" binding:"required"`
+		Size     int64  `json:"size"`
+		Name     string `json:"name" binding:"required"`
+		IsPublic bool   `json:"is_public"`
+		Folder   string `json:"folder"`
+		Nonce    string `json:"nonce"`
+		Proof    string `json:"proof"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	hash := strings.ToLower(input.SHA256)
+	ctx := c.Request.Context()
+
+	blob, err := db.GetBlob(h.Store, hash)
+	if err != nil || blob.Size != input.Size || !h.storedExists(ctx, blob.StoredPath) {
+		c.JSON(http.StatusOK, gin.H{"exists": false})
+		return
+	}
+	if input.Proof == "" {
+		challenge, err := h.newChallenge(ownerID, blob)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create a challenge"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"exists": true, "challenge": challenge})
+		return
+	}
+	if !h.provesPossession(ctx, ownerID, blob, input.Nonce, input.Proof) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "The proof does not match the content"})
+		return
+	}
+
+	blob, err = db.RefBlob(h.Store, hash, input.Size)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"exists": false})
+		return
+	}
+	record, err := h.ingest(ctx, stagedFile{ID: uuid.New().String(), Path: blob.StoredPath, Size: blob.Size, SHA256: hash}, ingestOptions{
+		OwnerID:    ownerID,
+		RemoteAddr: c.ClientIP(),
+		Name:       input.Name,
+		IsPublic:   input.IsPublic,
+		IsAdmin:    h.isAdmin(c),
+		Folder:     db.NormalizeFolder(input.Folder),
+		Referenced: true,
+	})
+	if err != nil {
+		respondIngestError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"exists": true, "file": record})
+}
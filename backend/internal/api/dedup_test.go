@@ -0,0 +1,151 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func uploadTestFile(t *testing.T, router *gin.Engine, clientID, name string, content []byte) map[string]interface{} {
+	t.Helper()
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", name)
+	part.Write(content)
+	writer.Close()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("X-Client-ID", clientID)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Upload failed: %v", w.Body.String())
+	}
+
+	var resp map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return resp
+}
+
+func TestUploadDeduplication(t *testing.T) {
+	h, storageDir, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.POST("/upload/check", h.UploadCheck)
+	router.DELETE("/files/:id", h.DeleteFile)
+
+	content := []byte("the same bytes twice")
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+
+	// 1. Unknown hash reports missing
+	w := httptest.NewRecorder()
+	checkBody := fmt.Sprintf(`{"sha256": "%s", "size": %d, "name": "copy.txt"}`, hash, len(content))
+	req, _ := http.NewRequest("POST", "/upload/check", bytes.NewBufferString(checkBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Client-ID", "client-a")
+	router.ServeHTTP(w, req)
+
+	var checkResp map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &checkResp)
+	if checkResp["exists"] != false {
+		t.Fatalf("expected unknown hash to be reported missing, got %v", w.Body.String())
+	}
+
+	// 2. Two uploads of the same content share one file on disk
+	first := uploadTestFile(t, router, "client-a", "one.txt", content)
+	second := uploadTestFile(t, router, "client-b", "two.txt", content)
+	if first["sha256"] != hash {
+		t.Errorf("expected sha256 %s, got %v", hash, first["sha256"])
+	}
+	if first["stored_path"] != second["stored_path"] {
+		t.Errorf("expected duplicate uploads to share stored path")
+	}
+	entries, _ := os.ReadDir(storageDir)
+	if len(entries) != 1 {
+		t.Errorf("expected 1 file in storage, got %d", len(entries))
+	}
+
+	// 3. Instant upload by hash answers with a challenge, and a wrong proof
+	// gets no file
+	checkResp = instantUpload(t, router, "client-c", checkBody, func(challenge map[string]interface{}) string {
+		return "00"
+	})
+	if checkResp["file"] != nil {
+		t.Fatalf("expected a wrong proof to be refused, got %v", checkResp)
+	}
+
+	// 4. The right proof creates the record
+	checkResp = instantUpload(t, router, "client-c", checkBody, func(challenge map[string]interface{}) string {
+		offset, length := int(challenge["offset"].(float64)), int(challenge["length"].(float64))
+		proof := sha256.Sum256(append([]byte(challenge["nonce"].(string)), content[offset:offset+length]...))
+		return hex.EncodeToString(proof[:])
+	})
+	if checkResp["exists"] != true || checkResp["file"] == nil {
+		t.Fatalf("expected known hash to create record, got %v", checkResp)
+	}
+
+	// 5. Content survives until the last reference is deleted
+	for _, rec := range []map[string]interface{}{first, second} {
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("DELETE", "/files/"+rec["id"].(string), nil)
+		req.Header.Set("X-Client-ID", rec["owner_id"].(string))
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Delete failed: %v", w.Body.String())
+		}
+	}
+	if _, err := os.Stat(first["stored_path"].(string)); err != nil {
+		t.Errorf("expected shared content to remain while referenced: %v", err)
+	}
+
+	instant := checkResp["file"].(map[string]interface{})
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/files/"+instant["id"].(string), nil)
+	req.Header.Set("X-Client-ID", "client-c")
+	router.ServeHTTP(w, req)
+
+	if _, err := os.Stat(first["stored_path"].(string)); !os.IsNotExist(err) {
+		t.Errorf("expected content to be removed after last reference")
+	}
+}
+
+// instantUpload asks for the challenge of an instant upload and answers it
+// with prove.
+func instantUpload(t *testing.T, router *gin.Engine, clientID, checkBody string, prove func(map[string]interface{}) string) map[string]interface{} {
+	t.Helper()
+	post := func(body string) map[string]interface{} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/upload/check", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Client-ID", clientID)
+		router.ServeHTTP(w, req)
+		var resp map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp
+	}
+
+	resp := post(checkBody)
+	challenge, ok := resp["challenge"].(map[string]interface{})
+	if resp["exists"] != true || !ok {
+		t.Fatalf("expected a challenge for a known hash, got %v", resp)
+	}
+	var input map[string]interface{}
+	json.Unmarshal([]byte(checkBody), &input)
+	input["nonce"] = challenge["nonce"]
+	input["proof"] = prove(challenge)
+	body, _ := json.Marshal(input)
+	return post(string(body))
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/storestats"
+	"github.com/gin-gonic/gin"
+)
+
+// started is when the process started, for the uptime in diagnostics.
+var started = time.Now()
+
+// AdminOnly rejects requests from anyone but admins, for route groups
+// whose handlers don't check themselves.
+func (h *Handler) AdminOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !h.isAdmin(c) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// AdminPprof serves the runtime profiles under …/pprof/: the index, the
+// named profiles (goroutine?debug=2 dumps every goroutine's stack) and the
+// CPU profile and execution trace, which run for ?seconds=.
+func (h *Handler) AdminPprof(c *gin.Context) {
+	name := strings.TrimPrefix(c.Param("profile"), "/")
+	switch name {
+	case "":
+		pprof.Index(c.Writer, c.Request)
+	case "cmdline":
+		pprof.Cmdline(c.Writer, c.Request)
+	case "profile":
+		pprof.Profile(c.Writer, c.Request)
+	case "symbol":
+		pprof.Symbol(c.Writer, c.Request)
+	case "trace":
+		pprof.Trace(c.Writer, c.Request)
+	default:
+		pprof.Handler(name).ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+type runtimeDiagnostics struct {
+	GoVersion     string  `json:"go_version"`
+	UptimeSeconds int64   `json:"uptime_seconds"`
+	CPUs          int     `json:"cpus"`
+	MaxProcs      int     `json:"max_procs"`
+	Goroutines    int     `json:"goroutines"`
+	HeapAlloc     uint64  `json:"heap_alloc_bytes"`
+	HeapInuse     uint64  `json:"heap_inuse_bytes"`
+	HeapObjects   uint64  `json:"heap_objects"`
+	Sys           uint64  `json:"sys_bytes"`
+	NumGC         uint32  `json:"num_gc"`
+	GCPauseTotal  uint64  `json:"gc_pause_total_ns"`
+	GCCPUFraction float64 `json:"gc_cpu_fraction"`
+	// UploadSessions are chunked uploads in progress
+	UploadSessions int `json:"upload_sessions"`
+	// Transfers is left out without a transfer limit
+	Transfers *TransferLaneStats `json:"transfers,omitempty"`
+	StoreOps  []storestats.Op    `json:"store_ops"`
+	Routes    []RouteTraffic     `json:"routes"`
+}
+
+// AdminGetRuntime reports memory, goroutines, the store operations and
+// the traffic per route since the start, for a first look before reaching
+// for a profile.
+func (h *Handler) AdminGetRuntime(c *gin.Context) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	d := runtimeDiagnostics{
+		GoVersion:      runtime.Version(),
+		UptimeSeconds:  int64(time.Since(started).Seconds()),
+		CPUs:           runtime.NumCPU(),
+		MaxProcs:       runtime.GOMAXPROCS(0),
+		Goroutines:     runtime.NumGoroutine(),
+		HeapAlloc:      mem.HeapAlloc,
+		HeapInuse:      mem.HeapInuse,
+		HeapObjects:    mem.HeapObjects,
+		Sys:            mem.Sys,
+		NumGC:          mem.NumGC,
+		GCPauseTotal:   mem.PauseTotalNs,
+		GCCPUFraction:  mem.GCCPUFraction,
+		UploadSessions: h.uploadSessionCount(),
+		StoreOps:       []storestats.Op{},
+		Routes:         h.traffic.list(),
+	}
+	if h.StoreStats != nil {
+		d.StoreOps = h.StoreStats.Stats()
+	}
+	if h.Transfers != nil {
+		if stats := h.Transfers.Stats(); stats.Max > 0 {
+			d.Transfers = &stats
+		}
+	}
+	c.JSON(http.StatusOK, d)
+}
+
+func (h *Handler) uploadSessionCount() int {
+	sessions, err := db.ListUploadSessions(h.Store)
+	if err != nil {
+		return 0
+	}
+	return len(sessions)
+}
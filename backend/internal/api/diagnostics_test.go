@@ -0,0 +1,71 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/storestats"
+	"github.com/gin-gonic/gin"
+)
+
+func TestDiagnostics(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+	h.StoreStats = storestats.Wrap(h.Store)
+	h.Store = h.StoreStats
+
+	router := gin.Default()
+	debug := router.Group("/debug", h.AdminOnly())
+	debug.GET("/runtime", h.AdminGetRuntime)
+	debug.GET("/pprof/*profile", h.AdminPprof)
+	db.UpsertClient(h.Store, "admin", "Admin", "CODEA", 0)
+	db.UpdateClientAdminStatus(h.Store, "admin", true)
+
+	get := func(clientID, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		req.Header.Set("X-Client-ID", clientID)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, path := range []string{"/debug/runtime", "/debug/pprof/", "/debug/pprof/heap"} {
+		if w := get("client-a", path); w.Code != http.StatusForbidden {
+			t.Errorf("%s: expected 403 for a client, got %d", path, w.Code)
+		}
+	}
+
+	w := get("admin", "/debug/runtime")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", w.Code, w.Body.String())
+	}
+	var diag runtimeDiagnostics
+	json.Unmarshal(w.Body.Bytes(), &diag)
+	if diag.Goroutines == 0 || diag.HeapAlloc == 0 {
+		t.Errorf("expected runtime statistics, got %+v", diag)
+	}
+	var gets int64
+	for _, op := range diag.StoreOps {
+		if op.Name == "get" {
+			gets = op.Calls
+		}
+	}
+	if gets == 0 {
+		t.Errorf("expected the admin lookups to be counted, got %+v", diag.StoreOps)
+	}
+
+	if w := get("admin", "/debug/pprof/"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "goroutine") {
+		t.Errorf("expected the profile index, got %d", w.Code)
+	}
+	w = get("admin", "/debug/pprof/goroutine?debug=2")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "goroutine ") {
+		t.Errorf("expected a goroutine dump, got %d", w.Code)
+	}
+	if w := get("admin", "/debug/pprof/nonexistent"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown profile, got %d", w.Code)
+	}
+}
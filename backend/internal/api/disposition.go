@@ -0,0 +1,66 @@
+package api
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// contentDisposition builds a Content-Disposition header for every download
+// path. Names that aren't plain ASCII get an RFC 5987 filename* for current
+// browsers and an ASCII filename for older ones, as RFC 6266 recommends.
+func contentDisposition(dispType, name string) string {
+	fallback := asciiFilename(name)
+	header := dispType + `; filename="` + fallback + `"`
+	if fallback != name {
+		header += "; filename*=UTF-8''" + encodeRFC5987(name)
+	}
+	return header
+}
+
+// asciiFilename approximates name in printable ASCII for the quoted
+// filename parameter: accents are dropped ("é" becomes "e"), anything else
+// outside ASCII becomes "_", and quotes and backslashes are replaced rather
+// than escaped, since not every client unescapes them.
+func asciiFilename(name string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(name) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+		case r == '"', r == '\\':
+			b.WriteByte('_')
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// encodeRFC5987 percent-encodes the UTF-8 bytes of s outside RFC 5987's
+// attr-char set. Unlike url.QueryEscape it encodes spaces as %20.
+func encodeRFC5987(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if isAttrChar(c) {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&15])
+	}
+	return b.String()
+}
+
+func isAttrChar(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", c) >= 0
+}
@@ -0,0 +1,30 @@
+package api
+
+import (
+	"mime"
+	"testing"
+)
+
+func TestContentDisposition(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"report.pdf", `attachment; filename="report.pdf"`},
+		{"résumé.pdf", `attachment; filename="resume.pdf"; filename*=UTF-8''r%C3%A9sum%C3%A9.pdf`},
+		{"отчёт 2026.pdf", `attachment; filename="_____ 2026.pdf"; filename*=UTF-8''%D0%BE%D1%82%D1%87%D1%91%D1%82%202026.pdf`},
+		{`say "hi".txt`, `attachment; filename="say _hi_.txt"; filename*=UTF-8''say%20%22hi%22.txt`},
+	}
+	for _, tt := range tests {
+		if got := contentDisposition("attachment", tt.name); got != tt.want {
+			t.Errorf("%q: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	// Clients that understand filename* get the name back exactly
+	for _, name := range []string{"報告書.docx", "party \U0001F389.jpg", "a;b=c.txt"} {
+		_, params, err := mime.ParseMediaType(contentDisposition("attachment", name))
+		if err != nil || params["filename"] != name {
+			t.Errorf("%q: parsed back as %q (%v)", name, params["filename"], err)
+		}
+	}
+}
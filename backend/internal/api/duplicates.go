@@ -0,0 +1,142 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) AdminListDuplicates(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+	h.listDuplicates(c, "")
+}
+
+func (h *Handler) ListDuplicates(c *gin.Context) {
+	ownerID := c.GetHeader("X-Client-ID")
+	if ownerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
+		return
+	}
+	h.listDuplicates(c, ownerID)
+}
+
+func (h *Handler) listDuplicates(c *gin.Context, ownerID string) {
+	report, err := db.FindDuplicates(h.Store, ownerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build duplicate report"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+func (h *Handler) AdminCollapseDuplicates(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+	h.collapseDuplicates(c, "")
+}
+
+func (h *Handler) CollapseDuplicates(c *gin.Context) {
+	ownerID := c.GetHeader("X-Client-ID")
+	if ownerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
+		return
+	}
+	h.collapseDuplicates(c, ownerID)
+}
+
+// collapseDuplicates either makes every copy of a hash share the kept file's
+// content ("dedup") or removes all copies but the kept one ("delete").
+func (h *Handler) collapseDuplicates(c *gin.Context, ownerID string) {
+	var input struct {
+		SHA256 string `json:"sha256" binding:"required"`
+		Action string `json:"action" binding:"required,oneof=dedup delete"`
+		KeepID string `json:"keep_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := db.FindDuplicates(h.Store, ownerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build duplicate report"})
+		return
+	}
+
+	var group *db.DuplicateGroup
+	for i := range report.Groups {
+		if report.Groups[i].SHA256 == input.SHA256 {
+			group = &report.Groups[i]
+			break
+		}
+	}
+	if group == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No duplicates found for this hash"})
+		return
+	}
+
+	var keep *db.FileRecord
+	for i := range group.Files {
+		if group.Files[i].ID == input.KeepID {
+			keep = &group.Files[i]
+		}
+	}
+	if keep == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "keep_id is not part of this duplicate group"})
+		return
+	}
+
+	affected := 0
+	switch input.Action {
+	case "dedup":
+		if !h.storedExists(c.Request.Context(), keep.StoredPath) {
+			c.JSON(http.StatusConflict, gin.H{"error": "The content of keep_id is missing from storage"})
+			return
+		}
+		// Every file of the hash moves to the kept content, not only those
+		// in the group, so the blob counts exactly the files sharing it
+		repointed, obsolete, err := db.RepointBlob(h.Store, input.SHA256, keep.StoredPath)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update blob index"})
+			return
+		}
+		inGroup := make(map[string]bool)
+		for _, f := range group.Files {
+			inGroup[f.ID] = true
+		}
+		for _, id := range repointed {
+			if inGroup[id] {
+				affected++
+			}
+		}
+		for _, path := range obsolete {
+			if err := h.deleteStored(path); err != nil {
+				log.Printf("[ERROR] Failed to delete duplicate content %s: %v", path, err)
+			}
+		}
+	case "delete":
+		for i := range group.Files {
+			f := &group.Files[i]
+			if f.ID == keep.ID || h.retained(f) {
+				continue
+			}
+			if err := h.releaseStoredFile(f); err != nil {
+				log.Printf("[ERROR] Failed to release content of %s: %v", f.ID, err)
+			}
+			if err := db.DeleteFileRecord(h.Store, f.ID); err != nil {
+				log.Printf("[ERROR] Failed to delete duplicate %s: %v", f.ID, err)
+				continue
+			}
+			affected++
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "affected": affected})
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestCollapseDuplicates(t *testing.T) {
+	h, storageDir, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.POST("/files/duplicates/collapse", h.CollapseDuplicates)
+	router.DELETE("/files/:id", h.DeleteFile)
+
+	request := func(method, path, clientID, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Client-ID", clientID)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	content := []byte("the same content")
+	kept := uploadTestFile(t, router, "client-a", "kept.txt", content)
+	hash, keptPath := kept["sha256"].(string), kept["stored_path"].(string)
+
+	// A copy stored on its own, as before deduplication, shared by a file
+	// of client-a and one of client-b
+	copyPath := filepath.Join(storageDir, "legacy-copy")
+	os.WriteFile(copyPath, content, 0644)
+	for _, r := range []db.FileRecord{
+		{ID: "legacy-a", OwnerID: "client-a", OriginalName: "copy.txt", StoredPath: copyPath, SHA256: hash, Size: int64(len(content))},
+		{ID: "legacy-b", OwnerID: "client-b", OriginalName: "theirs.txt", StoredPath: copyPath, SHA256: hash, Size: int64(len(content))},
+	} {
+		db.SaveFileRecord(h.Store, r)
+	}
+
+	w := request("POST", "/files/duplicates/collapse", "client-a", `{"sha256": "`+hash+`", "action": "dedup", "keep_id": "`+kept["id"].(string)+`"}`)
+	var resp struct{ Affected int }
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &resp) != nil {
+		t.Fatalf("collapse failed: %d %s", w.Code, w.Body.String())
+	}
+	// Only the caller's own files are reported
+	if resp.Affected != 1 {
+		t.Errorf("expected 1 file of client-a to be affected, got %d", resp.Affected)
+	}
+	if _, err := os.Stat(copyPath); !os.IsNotExist(err) {
+		t.Errorf("expected the duplicate content to be deleted")
+	}
+	blob, err := db.GetBlob(h.Store, hash)
+	if err != nil || blob.StoredPath != keptPath || blob.RefCount != 3 {
+		t.Fatalf("expected the blob to count all 3 files, got %+v", blob)
+	}
+
+	// The content stays until the last of the files goes
+	for _, f := range []struct{ id, owner string }{{"legacy-a", "client-a"}, {"legacy-b", "client-b"}, {kept["id"].(string), "client-a"}} {
+		if _, err := os.Stat(keptPath); err != nil {
+			t.Fatalf("content was deleted while %s still used it", f.id)
+		}
+		if w := request("DELETE", "/files/"+f.id, f.owner, ""); w.Code != http.StatusOK {
+			t.Fatalf("deleting %s failed: %s", f.id, w.Body.String())
+		}
+	}
+	if _, err := os.Stat(keptPath); !os.IsNotExist(err) {
+		t.Errorf("expected the content to be deleted with the last file")
+	}
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestEmbargoedDownload(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	clock := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	h.Now = func() time.Time { return clock }
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.PUT("/files/:id", h.UpdateFile)
+	router.GET("/download/:id", h.DownloadFile)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "release-notes.txt")
+	part.Write([]byte("v2 is out"))
+	writer.WriteField("publish_at", "2026-03-02T09:00:00Z")
+	writer.Close()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("X-Client-ID", "client-a")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("upload failed: %s", w.Body.String())
+	}
+	var rec map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &rec)
+
+	download := func(clientID string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/download/"+rec["download_link"].(string), nil)
+		if clientID != "" {
+			req.Header.Set("X-Client-ID", clientID)
+		}
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := download(""); code != http.StatusForbidden {
+		t.Errorf("expected 403 before publish time, got %d", code)
+	}
+	if code := download("client-a"); code != http.StatusOK {
+		t.Errorf("expected the owner to download during the embargo, got %d", code)
+	}
+
+	clock = time.Date(2026, 3, 2, 9, 0, 1, 0, time.UTC)
+	if code := download(""); code != http.StatusOK {
+		t.Errorf("expected 200 after publish time, got %d", code)
+	}
+
+	// Re-embargo, then lift it with 0
+	for _, publishAt := range []int64{clock.Add(time.Hour).Unix(), 0} {
+		w = httptest.NewRecorder()
+		update, _ := json.Marshal(map[string]interface{}{"original_name": "release-notes.txt", "owner_id": "client-a", "publish_at": publishAt})
+		req, _ = http.NewRequest("PUT", "/files/"+rec["id"].(string), bytes.NewReader(update))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Client-ID", "client-a")
+		router.ServeHTTP(w, req)
+
+		want := http.StatusOK
+		if publishAt != 0 {
+			want = http.StatusForbidden
+		}
+		if code := download(""); code != want {
+			t.Errorf("publish_at %d: expected %d, got %d", publishAt, want, code)
+		}
+	}
+}
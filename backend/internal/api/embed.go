@@ -0,0 +1,173 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+// maxEmbedOrigins bounds the sites a client can allow to embed its files.
+const maxEmbedOrigins = 50
+
+// embedScript tells the embedding page how tall the card is, so it can size
+// the iframe: it posts {type: "depot-embed", height} to the parent.
+const embedScript = `function r(){parent.postMessage({type:"depot-embed",height:document.documentElement.scrollHeight},"*")}addEventListener("load",r);addEventListener("resize",r);`
+
+// embedScriptHash allows the script, and nothing else, in the card's CSP.
+var embedScriptHash = func() string {
+	sum := sha256.Sum256([]byte(embedScript))
+	return "'sha256-" + base64.StdEncoding.EncodeToString(sum[:]) + "'"
+}()
+
+var embedPage = template.Must(template.New("embed").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{.Name}}</title>
+<style>
+html, body { margin: 0; background: transparent; }
+body { font: 14px/1.4 system-ui, sans-serif; color: #1f2328; }
+.card { display: flex; gap: .75rem; align-items: center; border: 1px solid #d0d7de; border-radius: 8px; padding: .75rem; background: #fff; }
+.card img { width: 4rem; height: 4rem; object-fit: cover; border-radius: 4px; flex: none; }
+.info { flex: 1; min-width: 0; }
+.name { font-weight: 600; overflow: hidden; text-overflow: ellipsis; white-space: nowrap; }
+.size { color: #59636e; }
+a.button { flex: none; background: #1f883d; color: #fff; padding: .375rem 1rem; border-radius: 6px; text-decoration: none; }
+</style>
+</head>
+<body>
+<div class="card">
+{{if .Preview}}<img src="{{.Preview}}" alt="">{{end}}
+<div class="info">
+<div class="name" title="{{.Name}}">{{.Name}}</div>
+<div class="size">{{.HumanSize}}</div>
+</div>
+<a class="button" href="{{.DownloadURL}}" target="_blank" rel="noopener">Download</a>
+</div>
+<script>` + embedScript + `</script>
+</body>
+</html>
+`))
+
+// normalizeOrigin reduces an allowed origin to scheme://host[:port].
+func normalizeOrigin(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+		u.User != nil || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("%q is not an origin like https://example.com", raw)
+	}
+	return strings.ToLower(u.Scheme + "://" + u.Host), nil
+}
+
+// embedAncestors is the frame-ancestors source list for the owner's files.
+func (h *Handler) embedAncestors(ownerID string) string {
+	p, err := db.GetEmbedPolicy(h.Store, ownerID)
+	if err != nil || len(p.AllowedOrigins) == 0 {
+		return "*"
+	}
+	return strings.Join(p.AllowedOrigins, " ")
+}
+
+// EmbedFile serves /embed/<link>: a small card with the file's name, size,
+// a download button and, for images, a preview, for other sites to show in
+// an iframe. Only the sites the owner allowed may frame it. Showing the
+// card doesn't count as a download.
+func (h *Handler) EmbedFile(c *gin.Context) {
+	record, err := h.findDownloadRecord(c.Param("link"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	if !h.linkAccessible(c, record) {
+		return
+	}
+	if record.Quarantined {
+		c.JSON(http.StatusForbidden, gin.H{"error": "File is quarantined"})
+		return
+	}
+
+	info := h.landingInfo(c, record)
+	preview := h.cardImage(c, record)
+	imgSrc := "'none'"
+	if u, err := url.Parse(preview); err == nil && preview != "" {
+		imgSrc = u.Scheme + "://" + u.Host
+	}
+	c.Header("Content-Security-Policy", "default-src 'none'; img-src "+imgSrc+"; style-src 'unsafe-inline'; script-src "+
+		embedScriptHash+"; frame-ancestors "+h.embedAncestors(record.OwnerID))
+	c.Header("Cache-Control", "private, no-cache")
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Status(http.StatusOK)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	err = embedPage.Execute(c.Writer, struct {
+		Name, HumanSize, DownloadURL, Preview string
+	}{
+		Name:        info.Name,
+		HumanSize:   humanSize(info.Size),
+		DownloadURL: info.DownloadURL,
+		Preview:     preview,
+	})
+	if err != nil {
+		c.Error(err)
+	}
+}
+
+func (h *Handler) GetEmbedPolicy(c *gin.Context) {
+	ownerID := c.GetHeader("X-Client-ID")
+	if ownerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
+		return
+	}
+	p, err := db.GetEmbedPolicy(h.Store, ownerID)
+	if err != nil {
+		p = &db.EmbedPolicy{OwnerID: ownerID, AllowedOrigins: []string{}}
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+// UpdateEmbedPolicy sets the sites allowed to embed the client's files.
+func (h *Handler) UpdateEmbedPolicy(c *gin.Context) {
+	ownerID := c.GetHeader("X-Client-ID")
+	if ownerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
+		return
+	}
+	var input struct {
+		AllowedOrigins []string `json:"allowed_origins"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(input.AllowedOrigins) > maxEmbedOrigins {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d origins can be allowed", maxEmbedOrigins)})
+		return
+	}
+
+	p := db.EmbedPolicy{OwnerID: ownerID, AllowedOrigins: []string{}}
+	seen := make(map[string]bool, len(input.AllowedOrigins))
+	for _, raw := range input.AllowedOrigins {
+		origin, err := normalizeOrigin(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !seen[origin] {
+			seen[origin] = true
+			p.AllowedOrigins = append(p.AllowedOrigins, origin)
+		}
+	}
+	if err := db.SaveEmbedPolicy(h.Store, p); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save embed settings"})
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestEmbedFile(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+	h.PublicURL = "https://depot.example"
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.GET("/embed/:link", h.EmbedFile)
+	router.GET("/persona/embed", h.GetEmbedPolicy)
+	router.PUT("/persona/embed", h.UpdateEmbedPolicy)
+
+	png := uploadTestFile(t, router, "client-a", "diagram.png", []byte("\x89PNG fake"))
+	doc := uploadTestFile(t, router, "client-a", "notes.txt", []byte("some notes"))
+
+	request := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Client-ID", "client-a")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("GET", "/embed/"+png["download_link"].(string), "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected the card, got %d %s", w.Code, w.Body.String())
+	}
+	page := w.Body.String()
+	if !strings.Contains(page, "diagram.png") || !strings.Contains(page, "/raw/"+png["download_link"].(string)+"/diagram.png") {
+		t.Errorf("expected the name and an image preview, got %s", page)
+	}
+	if !strings.Contains(page, "https://depot.example/api/download/"+png["download_link"].(string)) {
+		t.Errorf("expected a download button, got %s", page)
+	}
+	csp := w.Header().Get("Content-Security-Policy")
+	if !strings.Contains(csp, "frame-ancestors *") || !strings.Contains(csp, embedScriptHash) {
+		t.Errorf("expected any site to be allowed by default, got %q", csp)
+	}
+
+	if page := request("GET", "/embed/"+doc["download_link"].(string), "").Body.String(); strings.Contains(page, "<img") {
+		t.Errorf("expected no preview for a text file, got %s", page)
+	}
+	if w := request("GET", "/embed/nope", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown link, got %d", w.Code)
+	}
+
+	// Restricting origins
+	if w := request("PUT", "/persona/embed", `{"allowed_origins": ["https://wiki.example/"]}`); w.Code != http.StatusOK {
+		t.Fatalf("saving embed settings failed: %s", w.Body.String())
+	}
+	if w := request("PUT", "/persona/embed", `{"allowed_origins": ["https://wiki.example/page"]}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected a URL with a path to be rejected, got %d", w.Code)
+	}
+	if body := request("GET", "/persona/embed", "").Body.String(); !strings.Contains(body, `"https://wiki.example"`) {
+		t.Errorf("expected the normalized origin, got %s", body)
+	}
+	csp = request("GET", "/embed/"+png["download_link"].(string), "").Header().Get("Content-Security-Policy")
+	if !strings.Contains(csp, "frame-ancestors https://wiki.example") {
+		t.Errorf("expected only the allowed site, got %q", csp)
+	}
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/events"
+	"github.com/celerix/depot/internal/hooks"
+	"github.com/gin-gonic/gin"
+)
+
+type recordingPublisher struct {
+	mu     sync.Mutex
+	events []events.Event
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, topic, key string, body []byte) error {
+	var e events.Event
+	if err := json.Unmarshal(body, &e); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, e)
+	return nil
+}
+
+func (p *recordingPublisher) Close() error { return nil }
+
+type allowAll struct{}
+
+func (allowAll) PreUpload(ctx context.Context, u hooks.Upload) error { return nil }
+
+func TestLifecycleEvents(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+	pub := &recordingPublisher{}
+	h.Events = events.NewBus(pub, "depot.events", "", 16)
+	h.Hooks = &hooks.Registry{}
+	h.Hooks.Register(allowAll{})
+
+	router := gin.New()
+	router.POST("/upload", h.UploadFile)
+	router.PUT("/files/:id", h.UpdateFile)
+	router.DELETE("/files/:id", h.DeleteFile)
+
+	resp := uploadTestFile(t, router, "user1", "report.pdf", []byte("quarterly numbers"))
+	id := resp["id"].(string)
+
+	request := func(method, url, body string) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Client-ID", "user1")
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s %s failed: %s", method, url, w.Body.String())
+		}
+	}
+	update := `{"original_name": "report.pdf", "owner_id": "user1", "is_public": true}`
+	request("PUT", "/files/"+id, update)
+	// Already public, so nothing is shared again
+	request("PUT", "/files/"+id, update)
+	request("DELETE", "/files/"+id, "")
+	h.Events.Close()
+
+	want := []string{events.FileUploaded, events.FileScanned, events.FileShared, events.FileDeleted}
+	if len(pub.events) != len(want) {
+		t.Fatalf("expected %v, got %+v", want, pub.events)
+	}
+	for i, e := range pub.events {
+		if e.Type != want[i] || e.File.ID != id || e.File.Name != "report.pdf" || e.File.OwnerID != "user1" {
+			t.Errorf("event %d: expected %s about the upload, got %+v", i, want[i], e)
+		}
+	}
+	if pub.events[1].Scan != events.ScanPassed {
+		t.Errorf("expected the scan to pass, got %q", pub.events[1].Scan)
+	}
+	if !pub.events[2].File.IsPublic {
+		t.Error("expected the shared file to be public")
+	}
+}
+
+// ownerGate rejects every upload of one client.
+type ownerGate struct {
+	blocked string
+	seen    []string
+}
+
+func (g *ownerGate) PreUpload(ctx context.Context, u hooks.Upload) error {
+	g.seen = append(g.seen, u.OwnerID+":"+u.Name)
+	if u.OwnerID == g.blocked {
+		return &hooks.Rejection{Hook: "owner-gate", Reason: "not today"}
+	}
+	return nil
+}
+
+// Copies and instant uploads create files without a transfer, and run the
+// pre-upload hooks like any upload.
+func TestPreUploadHooksCoverCopies(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+	gate := &ownerGate{blocked: "client-b"}
+	h.Hooks = &hooks.Registry{}
+	h.Hooks.Register(gate)
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.POST("/upload/check", h.UploadCheck)
+	router.POST("/files/save-copy", h.SaveSharedCopy)
+
+	content := []byte("hooked content")
+	shared := uploadTestFile(t, router, "client-a", "shared.txt", content)
+	hash := shared["sha256"].(string)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/files/save-copy", bytes.NewBufferString(`{"link": "`+shared["download_link"].(string)+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Client-ID", "client-b")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected the hook to reject the copy, got %d %s", w.Code, w.Body.String())
+	}
+
+	checkBody := fmt.Sprintf(`{"sha256": "%s", "size": %d, "name": "instant.txt"}`, hash, len(content))
+	resp := instantUpload(t, router, "client-b", checkBody, func(challenge map[string]interface{}) string {
+		proof := sha256.Sum256(append([]byte(challenge["nonce"].(string)), content...))
+		return hex.EncodeToString(proof[:])
+	})
+	if resp["file"] != nil || resp["error"] == nil {
+		t.Errorf("expected the hook to reject the instant upload, got %v", resp)
+	}
+
+	want := []string{"client-a:shared.txt", "client-b:shared.txt", "client-b:instant.txt"}
+	if fmt.Sprint(gate.seen) != fmt.Sprint(want) {
+		t.Errorf("expected the hook to see %v, saw %v", want, gate.seen)
+	}
+	if blob, _ := db.GetBlob(h.Store, hash); blob == nil || blob.RefCount != 1 {
+		t.Errorf("rejected copies kept references: %+v", blob)
+	}
+}
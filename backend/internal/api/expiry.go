@@ -0,0 +1,239 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultExpiryReminder is how long before a link expires its owner is
+	// reminded, unless the settings say otherwise
+	defaultExpiryReminder = 24 * time.Hour
+	// defaultExtension is what an extension adds when neither an extension
+	// nor a link expiry is configured
+	defaultExtension = 7 * 24 * time.Hour
+)
+
+var extendPage = template.Must(template.New("extend").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Extend {{.Name}}</title>
+<style>
+body { display: flex; min-height: 100vh; margin: 0; align-items: center; justify-content: center; font: 16px/1.5 system-ui, sans-serif; background: #f6f8fa; color: #1f2328; }
+main { background: #fff; border: 1px solid #d0d7de; border-radius: 8px; padding: 2rem; max-width: 32rem; width: 100%; }
+h1 { font-size: 1.25rem; margin: 0 0 1rem; word-break: break-all; }
+button { background: #1f883d; color: #fff; border: 0; padding: .5rem 1.25rem; border-radius: 6px; font: inherit; cursor: pointer; }
+.warning { color: #cf222e; }
+</style>
+</head>
+<body>
+<main>
+<h1>{{.Name}}</h1>
+{{if .Error}}<p class="warning">{{.Error}}</p>
+{{else if .Done}}<p>The link now expires on {{.Expires}}.</p>
+{{else}}<p>The link expires on {{.Expires}}.</p>
+<form method="post"><input type="hidden" name="token" value="{{.Token}}"><button type="submit">Keep it for another {{.Extension}}</button></form>
+{{end}}</main>
+</body>
+</html>
+`))
+
+type extendPageData struct {
+	Name      string
+	Expires   string
+	Extension string
+	Token     string
+	Done      bool
+	Error     string
+}
+
+func formatExpiry(ts int64) string {
+	return time.Unix(ts, 0).UTC().Format("Jan 2, 2006 15:04 MST")
+}
+
+func formatDays(d time.Duration) string {
+	if days := int(d.Hours() / 24); days >= 1 && d%(24*time.Hour) == 0 {
+		if days == 1 {
+			return "day"
+		}
+		return fmt.Sprintf("%d days", days)
+	}
+	return d.String()
+}
+
+// extension is how much extending a link adds.
+func extension(settings db.Settings) time.Duration {
+	switch {
+	case settings.ExtendSeconds > 0:
+		return time.Duration(settings.ExtendSeconds) * time.Second
+	case settings.LinkExpirySeconds > 0:
+		return time.Duration(settings.LinkExpirySeconds) * time.Second
+	}
+	return defaultExtension
+}
+
+// extendRefusal says why the link can't be extended, or is empty when it
+// can. Admins are only bound by the link having an expiry.
+func extendRefusal(record *db.FileRecord, settings db.Settings, admin bool) (int, string) {
+	switch {
+	case record.ExpiresAt == 0:
+		return http.StatusConflict, "The link does not expire"
+	case admin:
+		return 0, ""
+	case record.ExtendDisabled:
+		return http.StatusForbidden, "This link cannot be extended"
+	case settings.MaxExtensions > 0 && record.Extensions >= settings.MaxExtensions:
+		return http.StatusForbidden, fmt.Sprintf("The link was already extended %d times", record.Extensions)
+	}
+	return 0, ""
+}
+
+// extendAuthorized reports whether the caller may extend the file: its
+// owner, an admin, or whoever holds the token from the reminder.
+func (h *Handler) extendAuthorized(c *gin.Context, record *db.FileRecord, token string) (ok, admin bool) {
+	if h.isAdmin(c) {
+		return true, true
+	}
+	if id := c.GetHeader("X-Client-ID"); id != "" && id == record.OwnerID {
+		return true, false
+	}
+	return db.CheckExtendToken(h.Store, record.ID, token), false
+}
+
+// ExtendPage shows the reminder's one-click link as a page with a button,
+// so mail scanners following the link don't extend it.
+func (h *Handler) ExtendPage(c *gin.Context) {
+	record, err := db.GetFileRecord(h.Store, c.Param("id"))
+	token := c.Query("token")
+	if err != nil || !db.CheckExtendToken(h.Store, c.Param("id"), token) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	settings := db.GetSettings(h.Store)
+	data := extendPageData{
+		Name:      record.OriginalName,
+		Expires:   formatExpiry(record.ExpiresAt),
+		Extension: formatDays(extension(settings)),
+		Token:     token,
+	}
+	status, refusal := extendRefusal(record, settings, false)
+	if refusal != "" {
+		data.Error = refusal
+	} else {
+		status = http.StatusOK
+	}
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(status)
+	extendPage.Execute(c.Writer, data)
+}
+
+// ExtendFile pushes the link's expiry back by the configured extension,
+// counted from now for a link that already expired. The token from a
+// reminder works once; the page form posts it as a form field.
+func (h *Handler) ExtendFile(c *gin.Context) {
+	record, err := db.GetFileRecord(h.Store, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	token := c.Query("token")
+	if token == "" {
+		token = c.PostForm("token")
+	}
+	fromPage := c.ContentType() == "application/x-www-form-urlencoded"
+	ok, admin := h.extendAuthorized(c, record, token)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to extend this file"})
+		return
+	}
+
+	settings := db.GetSettings(h.Store)
+	page := extendPageData{Name: record.OriginalName, Expires: formatExpiry(record.ExpiresAt)}
+	if status, refusal := extendRefusal(record, settings, admin); refusal != "" {
+		if fromPage {
+			page.Error = refusal
+			c.Header("Content-Type", "text/html; charset=utf-8")
+			c.Status(status)
+			extendPage.Execute(c.Writer, page)
+			return
+		}
+		c.JSON(status, gin.H{"error": refusal})
+		return
+	}
+
+	from := max(record.ExpiresAt, h.now().Unix())
+	updated, err := db.ExtendFileExpiry(h.Store, record.ID, from+int64(extension(settings).Seconds()))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to extend the link"})
+		return
+	}
+
+	if fromPage {
+		page.Expires, page.Done = formatExpiry(updated.ExpiresAt), true
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		c.Status(http.StatusOK)
+		extendPage.Execute(c.Writer, page)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"expires_at": updated.ExpiresAt, "extensions": updated.Extensions})
+}
+
+// NotifyExpiring reminds owners of their links that are about to expire,
+// once per expiry. Reminders of links that can be extended carry a
+// one-click link to extend them.
+func (h *Handler) NotifyExpiring(ctx context.Context) error {
+	files, err := db.GetAllFileRecords(h.Store)
+	if err != nil {
+		return err
+	}
+	settings := db.GetSettings(h.Store)
+	window := defaultExpiryReminder
+	if settings.ExpiryReminderSeconds > 0 {
+		window = time.Duration(settings.ExpiryReminderSeconds) * time.Second
+	}
+	now := h.now()
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if f.ExpiresAt <= now.Unix() || f.ExpiresAt > now.Add(window).Unix() || f.ExpiryNotifiedAt != 0 {
+			continue
+		}
+		if p, err := db.GetNotificationPrefs(h.Store, f.OwnerID); err != nil || !p.Wants(db.EventFileExpiring) {
+			continue
+		}
+
+		text := fmt.Sprintf("The link to %s expires on %s\n%s/api/download/%s", f.OriginalName, formatExpiry(f.ExpiresAt), h.PublicURL, f.DownloadLink)
+		data := gin.H{
+			"file_id":    f.ID,
+			"name":       f.OriginalName,
+			"expires_at": f.ExpiresAt,
+		}
+		if _, refusal := extendRefusal(&f, settings, false); refusal == "" {
+			token, err := db.CreateExtendToken(h.Store, f.ID)
+			if err != nil {
+				log.Printf("[ERROR] Failed to create extend token of %s: %v", f.ID, err)
+			} else {
+				extendURL := fmt.Sprintf("%s/api/files/%s/extend?token=%s", h.PublicURL, url.PathEscape(f.ID), token)
+				text += fmt.Sprintf("\n\nKeep it for another %s: %s", formatDays(extension(settings)), extendURL)
+				data["extend_url"] = extendURL
+			}
+		}
+		h.notifyClient(f.OwnerID, db.EventFileExpiring, text, data)
+		if err := db.SetFileExpiryNotified(h.Store, f.ID, now.Unix()); err != nil {
+			log.Printf("[ERROR] Failed to record expiry reminder of %s: %v", f.ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/notify"
+	"github.com/gin-gonic/gin"
+)
+
+func TestExpiryReminders(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	var mu sync.Mutex
+	var messages []notify.Message
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg notify.Message
+		json.NewDecoder(r.Body).Decode(&msg)
+		mu.Lock()
+		messages = append(messages, msg)
+		mu.Unlock()
+	}))
+	defer hook.Close()
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.PUT("/files/:id", h.UpdateFile)
+	router.GET("/files/:id/extend", h.ExtendPage)
+	router.POST("/files/:id/extend", h.ExtendFile)
+	db.UpsertClient(h.Store, "admin", "Admin", "CODEA", 0)
+	db.UpdateClientAdminStatus(h.Store, "admin", true)
+	db.SaveNotificationPrefs(h.Store, db.NotificationPrefs{OwnerID: "owner", WebhookURL: hook.URL, Events: []string{db.EventFileExpiring}})
+	db.SaveSettings(h.Store, db.Settings{ExpiryReminderSeconds: 3 * 24 * 3600, ExtendSeconds: 7 * 24 * 3600, MaxExtensions: 2})
+
+	uploaded := uploadTestFile(t, router, "owner", "handover.zip", []byte("handover"))
+	id := uploaded["id"].(string)
+	expiresAt := time.Now().Add(48 * time.Hour).Unix()
+	record, _ := db.GetFileRecord(h.Store, id)
+	record.ExpiresAt = expiresAt
+	db.SaveFileRecord(h.Store, *record)
+
+	if err := h.NotifyExpiring(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	if len(messages) != 1 {
+		t.Fatalf("expected a reminder within the configured window, got %d", len(messages))
+	}
+	data, _ := messages[0].Data.(map[string]any)
+	mu.Unlock()
+	extendURL, _ := data["extend_url"].(string)
+	u, err := url.Parse(extendURL)
+	if err != nil || u.Query().Get("token") == "" {
+		t.Fatalf("expected an extend link in the reminder, got %v", data)
+	}
+	target := "/files/" + id + "/extend?" + u.RawQuery
+
+	// The link opens a page; only its form extends
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", target, nil)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "another 7 days") {
+		t.Fatalf("expected the extend page, got %d %s", w.Code, w.Body.String())
+	}
+	if r, _ := db.GetFileRecord(h.Store, id); r.ExpiresAt != expiresAt {
+		t.Fatal("opening the link must not extend it")
+	}
+
+	submit := func() int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", target, strings.NewReader(url.Values{"token": {u.Query().Get("token")}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	if code := submit(); code != http.StatusOK {
+		t.Fatalf("expected the form to extend the link, got %d", code)
+	}
+	r, _ := db.GetFileRecord(h.Store, id)
+	if r.ExpiresAt != expiresAt+7*24*3600 || r.Extensions != 1 || r.ExpiryNotifiedAt != 0 {
+		t.Fatalf("expected a week more and a fresh reminder, got %+v", r)
+	}
+	if code := submit(); code != http.StatusForbidden {
+		t.Fatalf("expected the token to work once, got %d", code)
+	}
+
+	extend := func(clientID string) (int, map[string]any) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/files/"+id+"/extend", nil)
+		req.Header.Set("X-Client-ID", clientID)
+		router.ServeHTTP(w, req)
+		var resp map[string]any
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp
+	}
+	if code, _ := extend("stranger"); code != http.StatusForbidden {
+		t.Fatalf("expected strangers to be refused, got %d", code)
+	}
+	if code, resp := extend("owner"); code != http.StatusOK || resp["extensions"] != float64(2) {
+		t.Fatalf("expected the owner to extend, got %d %v", code, resp)
+	}
+	// The global policy caps extensions, except for admins
+	if code, _ := extend("owner"); code != http.StatusForbidden {
+		t.Fatalf("expected the cap to apply, got %d", code)
+	}
+	if code, _ := extend("admin"); code != http.StatusOK {
+		t.Fatalf("expected admins to extend past the cap, got %d", code)
+	}
+
+	// So does the per-file policy
+	db.SaveSettings(h.Store, db.Settings{})
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/files/"+id, strings.NewReader(`{"original_name":"handover.zip","owner_id":"owner","extendable":false}`))
+	req.Header.Set("X-Client-ID", "owner")
+	router.ServeHTTP(w, req)
+	if code, _ := extend("owner"); code != http.StatusForbidden {
+		t.Fatalf("expected a non-extendable link to be refused, got %d", code)
+	}
+
+	forever := uploadTestFile(t, router, "owner", "forever.txt", []byte("forever"))
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/files/"+forever["id"].(string)+"/extend", nil)
+	req.Header.Set("X-Client-ID", "owner")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected links without expiry to be refused, got %d", w.Code)
+	}
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+// exportColumns are the CSV columns of an export, in order.
+var exportColumns = []string{
+	"id", "name", "size", "sha256", "owner_id", "owner_name", "folder", "tags",
+	"is_public", "uploaded", "expires", "download_link", "downloads", "bytes_served",
+}
+
+func exportRow(r *db.FileRecord) []string {
+	formatTime := func(ts int64) string {
+		if ts == 0 {
+			return ""
+		}
+		return time.Unix(ts, 0).UTC().Format(time.RFC3339)
+	}
+	return []string{
+		r.ID,
+		r.OriginalName,
+		strconv.FormatInt(r.Size, 10),
+		r.SHA256,
+		r.OwnerID,
+		r.OwnerName,
+		r.Folder,
+		strings.Join(r.Tags, " "),
+		strconv.FormatBool(r.IsPublic),
+		formatTime(r.UploadTime),
+		formatTime(r.ExpiresAt),
+		r.DownloadLink,
+		strconv.FormatInt(r.Downloads, 10),
+		strconv.FormatInt(r.BytesServed, 10),
+	}
+}
+
+// ExportFiles streams every file the caller can list, as NDJSON (one file
+// record per line) or CSV. It takes the search, tag and folder filters of
+// ListFiles; records are written as they are read, in no particular order.
+func (h *Handler) ExportFiles(c *gin.Context) {
+	format := c.DefaultQuery("format", "ndjson")
+	if format != "ndjson" && format != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be ndjson or csv"})
+		return
+	}
+
+	opts := db.ListFilesOptions{
+		Search: c.Query("search"),
+		Tag:    c.Query("tag"),
+		Folder: db.NormalizeFolder(c.Query("folder")),
+		Type:   c.Query("type"),
+		Owner:  c.Query("owner"),
+		Size:   c.Query("size"),
+	}
+	if !h.isAdmin(c) {
+		ownerID := c.GetHeader("X-Client-ID")
+		if ownerID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
+			return
+		}
+		opts.OwnerID = ownerID
+		if group := db.GroupOf(h.Store, ownerID); group != nil {
+			opts.GroupID = group.ID
+		}
+	}
+
+	filename := "files-" + h.now().UTC().Format("20060102-150405") + "." + format
+	c.Header("Content-Disposition", contentDisposition("attachment", filename))
+	c.Header("Cache-Control", "no-store")
+
+	var write func(r *db.FileRecord) error
+	var flush func() error
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		w := csv.NewWriter(c.Writer)
+		write = func(r *db.FileRecord) error { return w.Write(exportRow(r)) }
+		flush = func() error { w.Flush(); return w.Error() }
+		w.Write(exportColumns)
+	} else {
+		c.Header("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(c.Writer)
+		write = func(r *db.FileRecord) error { return enc.Encode(r) }
+		flush = func() error { return nil }
+	}
+	c.Status(http.StatusOK)
+
+	// The status is out once the first record is, so a failure can only cut
+	// the export short
+	n := 0
+	err := db.EachFile(h.Store, opts, func(r db.FileRecord) error {
+		if err := write(&r); err != nil {
+			return err
+		}
+		if n++; n%100 == 0 {
+			if err := flush(); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		log.Printf("[ERROR] File export stopped after %d records: %v", n, err)
+	}
+}
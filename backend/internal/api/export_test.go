@@ -0,0 +1,89 @@
+package api
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestExportFiles(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.GET("/files/export", h.ExportFiles)
+
+	db.UpsertClient(h.Store, "admin", "Admin", "CODEA", 0)
+	db.UpdateClientAdminStatus(h.Store, "admin", true)
+	db.UpsertClient(h.Store, "client-a", "Alice", "CODEB", 0)
+
+	for i, name := range []string{"report.pdf", "notes.txt", "photo.jpg"} {
+		uploadTestFile(t, router, "client-a", name, []byte{byte(i)})
+	}
+	uploadTestFile(t, router, "client-b", "private.txt", []byte("secret"))
+
+	export := func(clientID, query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/files/export"+query, nil)
+		req.Header.Set("X-Client-ID", clientID)
+		router.ServeHTTP(w, req)
+		return w
+	}
+	ndjson := func(w *httptest.ResponseRecorder) []db.FileRecord {
+		var records []db.FileRecord
+		scanner := bufio.NewScanner(w.Body)
+		for scanner.Scan() {
+			var r db.FileRecord
+			if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+				t.Fatalf("bad line %q: %v", scanner.Text(), err)
+			}
+			records = append(records, r)
+		}
+		return records
+	}
+
+	w := export("client-a", "")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("unexpected response %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	records := ndjson(w)
+	if len(records) != 3 {
+		t.Fatalf("expected the client's 3 files, got %d", len(records))
+	}
+	for _, r := range records {
+		if r.OwnerID != "client-a" || r.OwnerName != "Alice" {
+			t.Errorf("unexpected record %+v", r)
+		}
+	}
+
+	if records := ndjson(export("admin", "")); len(records) != 4 {
+		t.Errorf("expected admins to export all 4 files, got %d", len(records))
+	}
+	if records := ndjson(export("client-a", "?search=NOTES")); len(records) != 1 || records[0].OriginalName != "notes.txt" {
+		t.Errorf("expected the search to apply, got %+v", records)
+	}
+
+	w = export("client-a", "?format=csv")
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") {
+		t.Errorf("expected CSV, got %s", w.Header().Get("Content-Type"))
+	}
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 4 || rows[0][0] != "id" || rows[0][1] != "name" {
+		t.Errorf("expected a header and 3 rows, got %v", rows)
+	}
+
+	if w := export("client-a", "?format=xml"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown format, got %d", w.Code)
+	}
+}
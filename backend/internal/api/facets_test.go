@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestListFilesFacets(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.GET("/files", h.ListFiles)
+
+	db.UpsertClient(h.Store, "client-a", "Alice", "CODEA", 0)
+	for i, name := range []string{"report.pdf", "notes.txt", "photo.jpg", "holiday.jpg"} {
+		uploadTestFile(t, router, "client-a", name, []byte{byte(i)})
+	}
+	uploadTestFile(t, router, "client-b", "private.txt", []byte("secret"))
+
+	list := func(query string) db.FileListResponse {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/files"+query, nil)
+		req.Header.Set("X-Client-ID", "client-a")
+		router.ServeHTTP(w, req)
+		var resp db.FileListResponse
+		if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &resp) != nil {
+			t.Fatalf("ListFiles%s: %d %s", query, w.Code, w.Body.String())
+		}
+		return resp
+	}
+
+	if resp := list(""); resp.Facets != nil {
+		t.Errorf("facets returned without ?facets=true")
+	}
+
+	resp := list("?facets=true&limit=1")
+	if len(resp.Files) != 1 || resp.Facets == nil {
+		t.Fatalf("got %d files and facets %v", len(resp.Files), resp.Facets)
+	}
+	f := resp.Facets
+	if len(f.Types) == 0 || f.Types[0].Value != "image" || f.Types[0].Count != 2 {
+		t.Errorf("types = %+v, want the 2 images first", f.Types)
+	}
+	if len(f.Owners) != 1 || f.Owners[0].Value != "client-a" || f.Owners[0].Name != "Alice" || f.Owners[0].Count != 4 {
+		t.Errorf("owners = %+v, want Alice's 4 files only", f.Owners)
+	}
+	if len(f.Sizes) != len(db.SizeBuckets) || f.Sizes[0].Value != "small" || f.Sizes[0].Count != 4 {
+		t.Errorf("sizes = %+v, want every bucket with 4 small files", f.Sizes)
+	}
+
+	// The facet values filter the listing
+	if resp := list("?type=image"); resp.Total != 2 {
+		t.Errorf("type=image listed %d files, want 2", resp.Total)
+	}
+	if resp := list("?size=large"); resp.Total != 0 {
+		t.Errorf("size=large listed %d files, want 0", resp.Total)
+	}
+	resp = list("?type=image&facets=true")
+	if len(resp.Facets.Types) != 1 || resp.Facets.Types[0].Count != 2 {
+		t.Errorf("facets don't follow the filters: %+v", resp.Facets.Types)
+	}
+}
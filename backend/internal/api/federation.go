@@ -0,0 +1,287 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/remote"
+	"github.com/gin-gonic/gin"
+)
+
+var remoteDepotName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// remoteListingTTL is how long a page of a remote depot's files is served
+// from memory before it is fetched again.
+const remoteListingTTL = time.Minute
+
+// maxCachedListings bounds the listing cache; it starts over when full.
+const maxCachedListings = 256
+
+// maxFederationPage caps the page size of the public listing.
+const maxFederationPage = 200
+
+type cachedListing struct {
+	listing   *remote.Listing
+	fetchedAt time.Time
+}
+
+// remoteListings caches pages of remote depots' files, keyed by remote and
+// query.
+type remoteListings struct {
+	mu      sync.Mutex
+	entries map[string]cachedListing
+}
+
+func (r *remoteListings) get(key string, now time.Time) (cachedListing, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.entries[key]
+	if !ok || now.Sub(e.fetchedAt) > remoteListingTTL {
+		return cachedListing{}, false
+	}
+	return e, true
+}
+
+func (r *remoteListings) put(key string, e cachedListing) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.entries == nil || len(r.entries) >= maxCachedListings {
+		r.entries = make(map[string]cachedListing)
+	}
+	r.entries[key] = e
+}
+
+// forget drops the cached pages of a remote that changed or went away.
+func (r *remoteListings) forget(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key := range r.entries {
+		if strings.HasPrefix(key, name+"?") {
+			delete(r.entries, key)
+		}
+	}
+}
+
+// federated reports whether a file is listed for other depots: public and
+// downloadable by anyone right now.
+func (h *Handler) federated(r *db.FileRecord) bool {
+	now := h.now().Unix()
+	return r.IsPublic && !r.Quarantined && !r.PasswordProtected && !r.IsLink() && !r.Restricted() &&
+		r.PublishAt <= now && (r.ExpiresAt == 0 || r.ExpiresAt > now) && !r.LimitReached()
+}
+
+// FederationFiles lists the public files for other depots, newest first,
+// when the admins enabled federation. It takes folder, tag, search, page
+// and limit like the file list. Owners aren't disclosed.
+func (h *Handler) FederationFiles(c *gin.Context) {
+	if !db.GetSettings(h.Store).Federation {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Federation is not enabled"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	page = max(page, 1)
+	if limit < 1 || limit > maxFederationPage {
+		limit = 50
+	}
+	search := strings.ToLower(c.Query("search"))
+	tag := c.Query("tag")
+	folder := db.NormalizeFolder(c.Query("folder"))
+
+	records, err := db.GetAllFileRecords(h.Store)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list files"})
+		return
+	}
+	var matched []db.FileRecord
+	for i := range records {
+		r := &records[i]
+		if !h.federated(r) || search != "" && !strings.Contains(strings.ToLower(r.OriginalName), search) ||
+			tag != "" && !r.HasTag(tag) || folder != "" && !r.InFolder(folder) {
+			continue
+		}
+		matched = append(matched, *r)
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].UploadTime > matched[j].UploadTime })
+
+	listing := remote.Listing{Files: []remote.PublicFile{}, Total: len(matched)}
+	start := min((page-1)*limit, len(matched))
+	for _, r := range matched[start:min(start+limit, len(matched))] {
+		listing.Files = append(listing.Files, remote.PublicFile{
+			Name:         r.OriginalName,
+			Size:         r.Size,
+			SHA256:       r.SHA256,
+			Folder:       r.Folder,
+			Tags:         r.Tags,
+			UploadTime:   r.UploadTime,
+			ExpiresAt:    r.ExpiresAt,
+			DownloadLink: r.DownloadLink,
+			SignedBy:     r.SignedBy,
+		})
+	}
+	c.JSON(http.StatusOK, listing)
+}
+
+// ListRemotes lists the remote depots clients can browse.
+func (h *Handler) ListRemotes(c *gin.Context) {
+	remotes, err := db.ListRemoteDepots(h.Store)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list remote depots"})
+		return
+	}
+	c.JSON(http.StatusOK, remotes)
+}
+
+// AdminPutRemote adds or replaces a remote depot. It has to have enabled
+// federation for its files to show up.
+func (h *Handler) AdminPutRemote(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	name := c.Param("name")
+	if !remoteDepotName.MatchString(name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Remote names use lowercase letters, digits, '-' and '_'"})
+		return
+	}
+	var input struct {
+		URL         string `json:"url" binding:"required"`
+		Description string `json:"description"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	u, err := url.Parse(input.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "url must be an http(s) URL"})
+		return
+	}
+
+	r := db.RemoteDepot{
+		Name:        name,
+		URL:         input.URL,
+		Description: input.Description,
+		UpdatedAt:   h.now().Unix(),
+	}
+	if err := db.SaveRemoteDepot(h.Store, r); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save remote depot"})
+		return
+	}
+	h.remoteListings.forget(name)
+	c.JSON(http.StatusOK, r)
+}
+
+func (h *Handler) AdminDeleteRemote(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	name := c.Param("name")
+	if _, err := db.GetRemoteDepot(h.Store, name); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Remote depot not found"})
+		return
+	}
+	if err := db.DeleteRemoteDepot(h.Store, name); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete remote depot"})
+		return
+	}
+	h.remoteListings.forget(name)
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// BrowseRemote lists a page of a remote depot's public files, taking the
+// same filters as its listing. Pages are cached briefly, and each file
+// comes with a download URL through this depot.
+func (h *Handler) BrowseRemote(c *gin.Context) {
+	r, err := db.GetRemoteDepot(h.Store, c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Remote depot not found"})
+		return
+	}
+
+	query := url.Values{}
+	for _, key := range []string{"folder", "tag", "search", "page", "limit"} {
+		if v := c.Query(key); v != "" {
+			query.Set(key, v)
+		}
+	}
+	key := r.Name + "?" + query.Encode()
+	now := h.now()
+	cached, ok := h.remoteListings.get(key, now)
+	if !ok {
+		client := &remote.Client{BaseURL: r.URL}
+		listing, err := client.ListPublic(c.Request.Context(), query)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to reach the remote depot: " + err.Error()})
+			return
+		}
+		cached = cachedListing{listing: listing, fetchedAt: now}
+		h.remoteListings.put(key, cached)
+	}
+
+	type remoteFile struct {
+		remote.PublicFile
+		DownloadURL string `json:"download_url"`
+	}
+	files := make([]remoteFile, 0, len(cached.listing.Files))
+	for _, f := range cached.listing.Files {
+		files = append(files, remoteFile{f, h.baseURL(c) + "/api/remotes/" + r.Name + "/download/" + url.PathEscape(f.DownloadLink)})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"remote":     r,
+		"files":      files,
+		"total":      cached.listing.Total,
+		"fetched_at": cached.fetchedAt.Unix(),
+	})
+}
+
+// DownloadRemote streams a file of a remote depot through this one, for
+// clients that can only reach this depot. Ranges and revalidation pass
+// through; the remote applies its own link rules.
+func (h *Handler) DownloadRemote(c *gin.Context) {
+	r, err := db.GetRemoteDepot(h.Store, c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Remote depot not found"})
+		return
+	}
+
+	header := http.Header{}
+	for _, name := range []string{"Range", "If-Range", "If-None-Match", "If-Modified-Since"} {
+		if v := c.GetHeader(name); v != "" {
+			header.Set(name, v)
+		}
+	}
+	client := &remote.Client{BaseURL: r.URL}
+	resp, err := client.Download(c.Request.Context(), c.Param("link"), header)
+	if err != nil {
+		if timedOut(c, err) {
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to reach the remote depot: " + err.Error()})
+		return
+	}
+	defer resp.Body.Close()
+
+	for _, name := range []string{"Content-Type", "Content-Length", "Content-Disposition", "Content-Range", "Accept-Ranges", "ETag", "Last-Modified", "Cache-Control"} {
+		if v := resp.Header.Get(name); v != "" {
+			c.Header(name, v)
+		}
+	}
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Status(resp.StatusCode)
+	if _, err := io.Copy(c.Writer, resp.Body); err != nil {
+		c.Error(err)
+	}
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestFederationBrowseRemote(t *testing.T) {
+	// The team depot sharing its public files
+	team, _, cleanupTeam := setupTestHandler(t)
+	defer cleanupTeam()
+	teamRouter := gin.New()
+	teamRouter.POST("/upload", team.UploadFile)
+	teamRouter.GET("/api/federation/files", team.FederationFiles)
+	teamRouter.GET("/api/download/:id", team.DownloadFile)
+	srv := httptest.NewServer(teamRouter)
+	defer srv.Close()
+
+	shared := uploadTestFile(t, teamRouter, "alice", "release.zip", []byte("public release"))
+	uploadTestFile(t, teamRouter, "alice", "notes.txt", []byte("private notes"))
+	sharedID := shared["id"].(string)
+	if err := db.UpdateFileRecord(team.Store, sharedID, "release.zip", "alice", true); err != nil {
+		t.Fatal(err)
+	}
+
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+	router := gin.New()
+	router.GET("/remotes/:name/files", h.BrowseRemote)
+	router.GET("/remotes/:name/download/:link", h.DownloadRemote)
+	router.PUT("/admin/remotes/:name", h.AdminPutRemote)
+	db.UpsertClient(h.Store, "admin", "Admin", "CODEA", 0)
+	db.UpdateClientAdminStatus(h.Store, "admin", true)
+
+	request := func(method, url, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Client-ID", "admin")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := request("PUT", "/admin/remotes/Team", `{"url": "`+srv.URL+`"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected an invalid remote name to be refused, got %d", w.Code)
+	}
+	if w := request("PUT", "/admin/remotes/team", `{"url": "`+srv.URL+`"}`); w.Code != http.StatusOK {
+		t.Fatalf("failed to add remote: %s", w.Body.String())
+	}
+
+	// Federation is off on the team depot
+	if w := request("GET", "/remotes/team/files", ""); w.Code != http.StatusBadGateway {
+		t.Errorf("expected a depot without federation to fail, got %d", w.Code)
+	}
+
+	db.SaveSettings(team.Store, db.Settings{Federation: true})
+	var listing struct {
+		Files []struct {
+			Name         string `json:"name"`
+			DownloadLink string `json:"download_link"`
+			DownloadURL  string `json:"download_url"`
+		} `json:"files"`
+		Total int `json:"total"`
+	}
+	w := request("GET", "/remotes/team/files", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("failed to browse remote: %s", w.Body.String())
+	}
+	json.Unmarshal(w.Body.Bytes(), &listing)
+	if listing.Total != 1 || len(listing.Files) != 1 || listing.Files[0].Name != "release.zip" {
+		t.Fatalf("expected only the public file, got %+v", listing)
+	}
+	if listing.Files[0].DownloadURL == "" {
+		t.Error("expected a download URL through this depot")
+	}
+
+	// Pages are served from the cache for a while
+	db.SaveSettings(team.Store, db.Settings{})
+	if w := request("GET", "/remotes/team/files", ""); w.Code != http.StatusOK {
+		t.Errorf("expected a cached page, got %d", w.Code)
+	}
+	if w := request("GET", "/remotes/team/files?search=release", ""); w.Code != http.StatusBadGateway {
+		t.Errorf("expected a new query to reach the remote, got %d", w.Code)
+	}
+
+	w = request("GET", "/remotes/team/download/"+listing.Files[0].DownloadLink, "")
+	if w.Code != http.StatusOK || w.Body.String() != "public release" {
+		t.Errorf("expected the remote file's content, got %d %q", w.Code, w.Body.String())
+	}
+	if w := request("GET", "/remotes/other/files", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected an unknown remote to be missing, got %d", w.Code)
+	}
+}
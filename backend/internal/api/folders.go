@@ -0,0 +1,159 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+// linkUnlocked checks the password sent in the X-Link-Password header or
+// the password query parameter against the file's link password.
+func (h *Handler) linkUnlocked(c *gin.Context, record *db.FileRecord) bool {
+	lp, err := db.GetLinkPassword(h.Store, record.ID)
+	if err != nil {
+		return false
+	}
+	password := c.GetHeader("X-Link-Password")
+	if password == "" {
+		password = c.Query("password")
+	}
+	salt, err := hex.DecodeString(lp.PasswordSalt)
+	if err != nil || password == "" {
+		return false
+	}
+	got := hashPassword(password, salt)
+	return subtle.ConstantTimeCompare([]byte(got), []byte(lp.PasswordHash)) == 1
+}
+
+func (h *Handler) ListFolderPolicies(c *gin.Context) {
+	ownerID := c.GetHeader("X-Client-ID")
+	if ownerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
+		return
+	}
+
+	policies, err := db.ListFolderPolicies(h.Store, ownerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list folder policies"})
+		return
+	}
+	c.JSON(http.StatusOK, policies)
+}
+
+// GetFolderPolicy returns the folder's own policy, if any, and the effective
+// one after inheritance from parent folders.
+func (h *Handler) GetFolderPolicy(c *gin.Context) {
+	ownerID := c.GetHeader("X-Client-ID")
+	if ownerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
+		return
+	}
+	folder := db.NormalizeFolder(c.Query("folder"))
+	if folder == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A folder is required"})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.folderPolicyResponse(ownerID, folder))
+}
+
+func (h *Handler) folderPolicyResponse(ownerID, folder string) gin.H {
+	own, err := db.GetFolderPolicy(h.Store, ownerID, folder)
+	if err != nil {
+		own = nil
+	}
+	return gin.H{"policy": own, "effective": db.ResolveFolderPolicy(h.Store, ownerID, folder)}
+}
+
+// PutFolderPolicy replaces the policy of one of the client's folders. Fields
+// left out are inherited from parent folders.
+func (h *Handler) PutFolderPolicy(c *gin.Context) {
+	ownerID := c.GetHeader("X-Client-ID")
+	if ownerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
+		return
+	}
+
+	var input struct {
+		Folder            string   `json:"folder" binding:"required"`
+		LinkExpirySeconds *int64   `json:"link_expiry_seconds"`
+		RequirePassword   *bool    `json:"require_password"`
+		AllowedExtensions []string `json:"allowed_extensions"`
+		Tags              []string `json:"tags"`
+		RetentionSeconds  *int64   `json:"retention_seconds"`
+		RequireSignature  *bool    `json:"require_signature"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	folder := db.NormalizeFolder(input.Folder)
+	if folder == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A folder is required"})
+		return
+	}
+	if input.LinkExpirySeconds != nil && *input.LinkExpirySeconds < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "link_expiry_seconds must not be negative"})
+		return
+	}
+	if input.RetentionSeconds != nil && *input.RetentionSeconds < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "retention_seconds must not be negative"})
+		return
+	}
+	// A write-once folder stays one: only admins can shorten its retention
+	if existing, err := db.GetFolderPolicy(h.Store, ownerID, folder); err == nil && !h.isAdmin(c) {
+		retention := int64(0)
+		if input.RetentionSeconds != nil {
+			retention = *input.RetentionSeconds
+		}
+		if retention < existing.Retention() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "The retention of a write-once folder can only be lengthened"})
+			return
+		}
+	}
+
+	policy := db.FolderPolicy{
+		OwnerID:           ownerID,
+		Folder:            folder,
+		LinkExpirySeconds: input.LinkExpirySeconds,
+		RequirePassword:   input.RequirePassword,
+		AllowedExtensions: input.AllowedExtensions,
+		Tags:              input.Tags,
+		RetentionSeconds:  input.RetentionSeconds,
+		RequireSignature:  input.RequireSignature,
+		UpdatedAt:         time.Now().Unix(),
+	}
+	if err := db.SaveFolderPolicy(h.Store, policy); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save folder policy"})
+		return
+	}
+	c.JSON(http.StatusOK, h.folderPolicyResponse(ownerID, folder))
+}
+
+func (h *Handler) DeleteFolderPolicy(c *gin.Context) {
+	ownerID := c.GetHeader("X-Client-ID")
+	if ownerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
+		return
+	}
+	folder := db.NormalizeFolder(c.Query("folder"))
+	existing, err := db.GetFolderPolicy(h.Store, ownerID, folder)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Folder policy not found"})
+		return
+	}
+	if existing.Retention() > 0 && !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can remove the policy of a write-once folder"})
+		return
+	}
+
+	if err := db.DeleteFolderPolicy(h.Store, ownerID, folder); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete folder policy"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
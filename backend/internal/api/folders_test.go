@@ -0,0 +1,115 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestFolderPolicies(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	clock := time.Date(2026, 6, 1, 8, 0, 0, 0, time.UTC)
+	h.Now = func() time.Time { return clock }
+	db.SaveSettings(h.Store, db.Settings{LinkExpirySeconds: 3600})
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.PUT("/persona/folder-policy", h.PutFolderPolicy)
+	router.GET("/persona/folder-policy", h.GetFolderPolicy)
+	router.GET("/download/:id", h.DownloadFile)
+
+	putPolicy := func(body string) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/persona/folder-policy", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Client-ID", "client-a")
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("saving policy failed: %s", w.Body.String())
+		}
+	}
+	putPolicy(`{"folder": "clients", "require_password": true, "allowed_extensions": ["PDF"], "tags": ["client"]}`)
+	putPolicy(`{"folder": "clients/acme", "link_expiry_seconds": 86400, "tags": ["#acme"]}`)
+
+	upload := func(name, password string) *httptest.ResponseRecorder {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("file", name)
+		part.Write([]byte("%PDF-1.4 " + name))
+		writer.WriteField("folder", "clients/acme/2026")
+		writer.WriteField("tags", "invoice")
+		if password != "" {
+			writer.WriteField("password", password)
+		}
+		writer.Close()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/upload", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		req.Header.Set("X-Client-ID", "client-a")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := upload("invoice.pdf", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without a password, got %d", w.Code)
+	}
+	if w := upload("setup.exe", "s3cret"); w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("expected 415 for a disallowed type, got %d", w.Code)
+	}
+	w := upload("invoice.pdf", "s3cret")
+	if w.Code != http.StatusOK {
+		t.Fatalf("upload failed: %s", w.Body.String())
+	}
+	var record db.FileRecord
+	json.Unmarshal(w.Body.Bytes(), &record)
+
+	// The nearest policy's expiry wins; tags add up along the path
+	if want := clock.Unix() + 86400; record.ExpiresAt != want {
+		t.Errorf("expected expiry %d, got %d", want, record.ExpiresAt)
+	}
+	if len(record.Tags) != 3 || !record.HasTag("invoice") || !record.HasTag("client") || !record.HasTag("acme") {
+		t.Errorf("unexpected tags %v", record.Tags)
+	}
+	if !record.PasswordProtected {
+		t.Error("expected the link to be password protected")
+	}
+
+	download := func(query string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/download/"+record.DownloadLink+query, nil)
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	if code := download(""); code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without the password, got %d", code)
+	}
+	if code := download("?password=wrong"); code != http.StatusUnauthorized {
+		t.Errorf("expected 401 with a wrong password, got %d", code)
+	}
+	if code := download("?password=s3cret"); code != http.StatusOK {
+		t.Errorf("expected download with the password, got %d", code)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/persona/folder-policy?folder=clients/acme/2026", nil)
+	req.Header.Set("X-Client-ID", "client-a")
+	router.ServeHTTP(w, req)
+	var resp struct {
+		Policy    *db.FolderPolicy `json:"policy"`
+		Effective db.FolderPolicy  `json:"effective"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Policy != nil || !resp.Effective.PasswordRequired() || resp.Effective.AllowedExtensions[0] != ".pdf" {
+		t.Errorf("unexpected policy response %s", w.Body.String())
+	}
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+type groupResponse struct {
+	db.ClientGroup
+	UsedBytes int64 `json:"used_bytes"`
+	Files     int   `json:"files"`
+}
+
+func (h *Handler) describeGroup(g db.ClientGroup) groupResponse {
+	used, files := db.GroupUsage(h.Store, g)
+	return groupResponse{ClientGroup: g, UsedBytes: used, Files: files}
+}
+
+// AdminListGroups returns the client groups with their pool usage.
+func (h *Handler) AdminListGroups(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	groups, err := db.ListGroups(h.Store)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list groups"})
+		return
+	}
+	resp := make([]groupResponse, 0, len(groups))
+	for _, g := range groups {
+		resp = append(resp, h.describeGroup(g))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// AdminPutGroup creates or replaces a client group and its quota pool.
+// Members must be existing clients outside any other group.
+func (h *Handler) AdminPutGroup(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	id := c.Param("id")
+	if !presetName.MatchString(id) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Group IDs use lowercase letters, digits, '-' and '_'"})
+		return
+	}
+	if _, err := db.GetClient(h.Store, id); err == nil || id == db.SystemPersona {
+		c.JSON(http.StatusConflict, gin.H{"error": "A client already uses the ID " + id})
+		return
+	}
+	var input struct {
+		Name       string   `json:"name"`
+		Members    []string `json:"members"`
+		QuotaBytes int64    `json:"quota_bytes"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.QuotaBytes < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "quota_bytes must not be negative"})
+		return
+	}
+	for _, member := range input.Members {
+		if _, err := db.GetClient(h.Store, member); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Client " + member + " does not exist"})
+			return
+		}
+		if other := db.GroupOf(h.Store, member); other != nil && other.ID != id {
+			c.JSON(http.StatusConflict, gin.H{"error": "Client " + member + " is already in group " + other.ID})
+			return
+		}
+	}
+
+	group := db.ClientGroup{
+		ID:         id,
+		Name:       input.Name,
+		Members:    input.Members,
+		QuotaBytes: input.QuotaBytes,
+		UpdatedAt:  h.now().Unix(),
+	}
+	if group.Name == "" {
+		group.Name = id
+	}
+	if group.Members == nil {
+		group.Members = []string{}
+	}
+	if err := db.SaveGroup(h.Store, group); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save group"})
+		return
+	}
+	saved, _ := db.GetGroup(h.Store, id)
+	c.JSON(http.StatusOK, h.describeGroup(*saved))
+}
+
+// AdminDeleteGroup dissolves a group. A group that still owns files can't
+// be deleted; transfer them first.
+func (h *Handler) AdminDeleteGroup(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	id := c.Param("id")
+	if _, err := db.GetGroup(h.Store, id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+		return
+	}
+	if files, _ := db.ListOwnerFiles(h.Store, id); len(files) > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "The group still owns files", "files": len(files)})
+		return
+	}
+	if err := db.DeleteGroup(h.Store, id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete group"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// GetClientGroup returns the caller's group and how much of its pool is
+// used.
+func (h *Handler) GetClientGroup(c *gin.Context) {
+	clientID := c.GetHeader("X-Client-ID")
+	if clientID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
+		return
+	}
+	group := db.GroupOf(h.Store, clientID)
+	if group == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "You are not in a group"})
+		return
+	}
+	c.JSON(http.StatusOK, h.describeGroup(*group))
+}
@@ -0,0 +1,210 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestGroupQuotaPool(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	db.UpsertClient(h.Store, "admin", "Admin", "CODEA", 0)
+	db.UpdateClientAdminStatus(h.Store, "admin", true)
+	for _, id := range []string{"client-a", "client-b", "client-c"} {
+		db.UpsertClient(h.Store, id, id, "CODE-"+id, 0)
+	}
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.GET("/files", h.ListFiles)
+	router.POST("/files/:id/transfer", h.TransferFile)
+	router.PUT("/admin/groups/:id", h.AdminPutGroup)
+	router.DELETE("/admin/groups/:id", h.AdminDeleteGroup)
+	router.GET("/persona/group", h.GetClientGroup)
+
+	request := func(method, path, clientID, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Client-ID", clientID)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := request("PUT", "/admin/groups/team", "client-a", `{}`); w.Code != http.StatusForbidden {
+		t.Errorf("client created a group: %d", w.Code)
+	}
+	if w := request("PUT", "/admin/groups/team", "admin", `{"members": ["client-a", "nobody"]}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("unknown member: got %d, want 422", w.Code)
+	}
+	if w := request("PUT", "/admin/groups/team", "admin", `{"name": "Team", "members": ["client-a", "client-b"], "quota_bytes": 10}`); w.Code != http.StatusOK {
+		t.Fatalf("creating group failed: %d %s", w.Code, w.Body.String())
+	}
+	if w := request("PUT", "/admin/groups/other", "admin", `{"members": ["client-b"]}`); w.Code != http.StatusConflict {
+		t.Errorf("client joined two groups: %d", w.Code)
+	}
+
+	// Members fill one pool between them
+	shared := uploadTestFile(t, router, "client-a", "a.txt", []byte("123456"))
+	uploadTestFile(t, router, "client-b", "b.txt", []byte("7890"))
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "c.txt")
+	part.Write([]byte("x"))
+	writer.Close()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("X-Client-ID", "client-b")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("upload over the pool: %d %s", w.Code, w.Body.String())
+	}
+	outside := uploadTestFile(t, router, "client-c", "c.txt", []byte("outside the group"))
+	if w := request("POST", "/files/"+outside["id"].(string)+"/transfer", "client-c", `{"owner_id": "client-a"}`); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("transfer into the full pool: %d %s", w.Code, w.Body.String())
+	}
+
+	w = request("GET", "/persona/group", "client-b", "")
+	var group groupResponse
+	json.Unmarshal(w.Body.Bytes(), &group)
+	if w.Code != http.StatusOK || group.UsedBytes != 10 || group.Files != 2 {
+		t.Errorf("pool usage = %d %+v, want 10 bytes in 2 files", w.Code, group)
+	}
+	if w := request("GET", "/persona/group", "client-c", ""); w.Code != http.StatusNotFound {
+		t.Errorf("client outside groups: got %d, want 404", w.Code)
+	}
+
+	// Files handed to the group stay in the pool and are listed to members
+	id := shared["id"].(string)
+	if w := request("POST", "/files/"+id+"/transfer", "client-a", `{"owner_id": "team"}`); w.Code != http.StatusOK {
+		t.Fatalf("transfer to group failed: %d %s", w.Code, w.Body.String())
+	}
+	if used, _ := db.GroupUsage(h.Store, group.ClientGroup); used != 10 {
+		t.Errorf("pool usage after the transfer = %d, want 10", used)
+	}
+	w = request("GET", "/files?search=a.txt", "client-b", "")
+	var list db.FileListResponse
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if list.Total != 1 || list.Files[0].OwnerName != "Team" {
+		t.Errorf("members don't see group files: %+v", list)
+	}
+
+	if w := request("DELETE", "/admin/groups/team", "admin", ""); w.Code != http.StatusConflict {
+		t.Errorf("deleted a group that owns files: %d", w.Code)
+	}
+	db.DeleteClient(h.Store, "client-b")
+	if g, _ := db.GetGroup(h.Store, "team"); len(g.Members) != 1 {
+		t.Errorf("deleted client still in group: %v", g.Members)
+	}
+}
+
+// Copies and instant uploads add to the pool like uploads, even though
+// they store nothing new.
+func TestGroupQuotaCoversCopies(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+	for _, id := range []string{"client-a", "client-c"} {
+		db.UpsertClient(h.Store, id, id, "CODE-"+id, 0)
+	}
+	db.SaveGroup(h.Store, db.ClientGroup{ID: "team", Name: "Team", Members: []string{"client-a"}, QuotaBytes: 20})
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.POST("/upload/check", h.UploadCheck)
+	router.POST("/files/save-copy", h.SaveSharedCopy)
+
+	content := []byte("sixteen bytes!!!")
+	outside := uploadTestFile(t, router, "client-c", "big.txt", content)
+	uploadTestFile(t, router, "client-a", "small.txt", []byte("12345"))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/files/save-copy", bytes.NewBufferString(`{"link": "`+outside["download_link"].(string)+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Client-ID", "client-a")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("copy over the pool: %d %s", w.Code, w.Body.String())
+	}
+
+	checkBody := fmt.Sprintf(`{"sha256": "%s", "size": %d, "name": "instant.txt"}`, outside["sha256"], len(content))
+	resp := instantUpload(t, router, "client-a", checkBody, func(challenge map[string]interface{}) string {
+		proof := sha256.Sum256(append([]byte(challenge["nonce"].(string)), content...))
+		return hex.EncodeToString(proof[:])
+	})
+	if resp["file"] != nil || resp["error"] != "Group storage quota exceeded" {
+		t.Errorf("instant upload over the pool: %v", resp)
+	}
+
+	if files, _ := db.ListOwnerFiles(h.Store, "client-a"); len(files) != 1 {
+		t.Errorf("expected only the upload within the pool, got %d files", len(files))
+	}
+	if blob, _ := db.GetBlob(h.Store, outside["sha256"].(string)); blob == nil || blob.RefCount != 1 {
+		t.Errorf("refused copies kept references: %+v", blob)
+	}
+}
+
+// Handing files to a member is held to the pool on every path admins have
+// for it, not only transfers.
+func TestGroupQuotaCoversReassignment(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+	for _, id := range []string{"admin", "client-a", "client-c", "gone"} {
+		db.UpsertClient(h.Store, id, id, "CODE-"+id, 0)
+	}
+	db.UpdateClientAdminStatus(h.Store, "admin", true)
+	db.SaveGroup(h.Store, db.ClientGroup{ID: "team", Name: "Team", Members: []string{"client-a"}, QuotaBytes: 20})
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.PUT("/files/:id", h.UpdateFile)
+	router.DELETE("/clients/:id", h.DeleteClient)
+	router.POST("/admin/orphans/reassign", h.AdminReassignOrphans)
+
+	uploadTestFile(t, router, "client-a", "small.txt", []byte("12345"))
+	big := uploadTestFile(t, router, "client-c", "big.txt", []byte("sixteen bytes!!!"))
+	uploadTestFile(t, router, "gone", "orphan.txt", []byte("sixteen bytes..."))
+	db.DeleteClient(h.Store, "gone")
+
+	request := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Client-ID", "admin")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := request("PUT", "/files/"+big["id"].(string), `{"original_name": "big.txt", "owner_id": "client-a"}`); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("owner change over the pool: %d %s", w.Code, w.Body.String())
+	}
+	if w := request("POST", "/admin/orphans/reassign", `{"owner_id": "client-a"}`); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("orphans reassigned over the pool: %d %s", w.Code, w.Body.String())
+	}
+	if w := request("DELETE", "/clients/client-c", `{"files": "transfer", "owner_id": "client-a"}`); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("files of a deleted client moved over the pool: %d %s", w.Code, w.Body.String())
+	}
+	if _, err := db.GetClient(h.Store, "client-c"); err != nil {
+		t.Error("the client was deleted although its files couldn't move")
+	}
+	if files, _ := db.ListOwnerFiles(h.Store, "client-a"); len(files) != 1 {
+		t.Errorf("expected only the member's own file, got %d files", len(files))
+	}
+
+	// Within the pool they still move
+	db.SaveGroup(h.Store, db.ClientGroup{ID: "team", Name: "Team", Members: []string{"client-a"}, QuotaBytes: 40})
+	if w := request("PUT", "/files/"+big["id"].(string), `{"original_name": "big.txt", "owner_id": "client-a"}`); w.Code != http.StatusOK {
+		t.Errorf("owner change within the pool: %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,324 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// guestTokenLifetime is how long a token lasts when no expiry is given
+	guestTokenLifetime = 7 * 24 * time.Hour
+	// guestTokenMaxLifetime bounds the expiry an owner can choose
+	guestTokenMaxLifetime = 90 * 24 * time.Hour
+)
+
+// guestKey holds the validated token in the context of guest routes.
+const guestKey = "guest_token"
+
+type guestFile struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Size        int64  `json:"size"`
+	UploadTime  int64  `json:"upload_time"`
+	SHA256      string `json:"sha256"`
+	Folder      string `json:"folder,omitempty"`
+	DownloadURL string `json:"download_url"`
+}
+
+// ListGuestTokens lists the guest tokens the client minted.
+func (h *Handler) ListGuestTokens(c *gin.Context) {
+	ownerID := c.GetHeader("X-Client-ID")
+	if ownerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
+		return
+	}
+	tokens, err := db.ListGuestTokens(h.Store, ownerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list guest tokens"})
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+// CreateGuestToken mints a token that gives someone without a persona
+// read access to some of the client's files or a folder, or lets them
+// upload into a folder, until it expires.
+func (h *Handler) CreateGuestToken(c *gin.Context) {
+	ownerID := c.GetHeader("X-Client-ID")
+	if ownerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
+		return
+	}
+
+	var input struct {
+		Name    string   `json:"name"`
+		Access  string   `json:"access" binding:"required"`
+		FileIDs []string `json:"file_ids"`
+		Folder  string   `json:"folder"`
+		// ExpiresAt is a Unix timestamp or an RFC 3339 time
+		ExpiresAt string `json:"expires_at"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Access != db.GuestRead && input.Access != db.GuestUpload {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "access must be read or upload"})
+		return
+	}
+	folder := db.NormalizeFolder(input.Folder)
+	if (folder == "") == (len(input.FileIDs) == 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Exactly one of file_ids and folder is required"})
+		return
+	}
+	if input.Access == db.GuestUpload && folder == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload tokens need a folder"})
+		return
+	}
+	for _, id := range input.FileIDs {
+		if record, err := db.GetFileRecord(h.Store, id); err != nil || record.OwnerID != ownerID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "File " + id + " not found"})
+			return
+		}
+	}
+
+	now := h.now()
+	expiresAt, err := parseTimestamp("expires_at", input.ExpiresAt)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if expiresAt == 0 {
+		expiresAt = now.Add(guestTokenLifetime).Unix()
+	}
+	if expiresAt <= now.Unix() || expiresAt > now.Add(guestTokenMaxLifetime).Unix() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the next 90 days"})
+		return
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create guest token"})
+		return
+	}
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		name = "Guest"
+	}
+	g := db.GuestToken{
+		ID:        hex.EncodeToString(secret),
+		OwnerID:   ownerID,
+		Name:      truncate(name, 100),
+		Access:    input.Access,
+		FileIDs:   input.FileIDs,
+		Folder:    folder,
+		ExpiresAt: expiresAt,
+		CreatedAt: now.Unix(),
+	}
+	if err := db.SaveGuestToken(h.Store, g); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create guest token"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"token": g, "url": h.baseURL(c) + "/api/guest?token=" + g.ID})
+}
+
+// DeleteGuestToken revokes a token before it expires.
+func (h *Handler) DeleteGuestToken(c *gin.Context) {
+	g, err := db.GetGuestToken(h.Store, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Guest token not found"})
+		return
+	}
+	if g.OwnerID != c.GetHeader("X-Client-ID") && !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to revoke this guest token"})
+		return
+	}
+	if err := db.DeleteGuestToken(h.Store, g.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke guest token"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// GuestAuth admits requests carrying a valid guest token, in the
+// X-Guest-Token header or the token query parameter, and hands the token
+// to the guest routes.
+func (h *Handler) GuestAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader("X-Guest-Token")
+		if id == "" {
+			id = c.Query("token")
+		}
+		if id == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Guest token required"})
+			return
+		}
+		g, err := db.GetGuestToken(h.Store, id)
+		if err != nil || g.ExpiresAt <= h.now().Unix() {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Guest token is invalid or has expired"})
+			return
+		}
+		c.Set(guestKey, g)
+		c.Next()
+	}
+}
+
+// guestAccess returns the request's token if it grants the access, any
+// when access is empty, and responds itself otherwise.
+func guestAccess(c *gin.Context, access string) (*db.GuestToken, bool) {
+	v, _ := c.Get(guestKey)
+	g, ok := v.(*db.GuestToken)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Guest token required"})
+		return nil, false
+	}
+	if access != "" && g.Access != access {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This guest token is " + g.Access + "-only"})
+		return nil, false
+	}
+	return g, true
+}
+
+// GetGuestScope tells a guest what the token grants and, for read tokens,
+// lists the files it covers.
+func (h *Handler) GetGuestScope(c *gin.Context) {
+	g, ok := guestAccess(c, "")
+	if !ok {
+		return
+	}
+	owner := "Unknown"
+	if client, err := db.GetClient(h.Store, g.OwnerID); err == nil {
+		owner = client.Name
+	}
+	scope := gin.H{"name": g.Name, "access": g.Access, "folder": g.Folder, "expires_at": g.ExpiresAt, "owner": owner}
+	if g.Access != db.GuestRead {
+		c.JSON(http.StatusOK, scope)
+		return
+	}
+
+	var records []db.FileRecord
+	if g.Folder != "" {
+		records, _ = db.GetFileRecordsByOwner(h.Store, g.OwnerID)
+	} else {
+		for _, id := range g.FileIDs {
+			if record, err := db.GetFileRecord(h.Store, id); err == nil {
+				records = append(records, *record)
+			}
+		}
+	}
+	files := []guestFile{}
+	for i := range records {
+		record := &records[i]
+		if !g.Covers(record) || record.Quarantined {
+			continue
+		}
+		// Files the link's rules refuse are left out; those behind a
+		// password are listed, since the guest can unlock them
+		if status, _ := h.linkRefusal(c, record); status != 0 && status != http.StatusUnauthorized {
+			continue
+		}
+		files = append(files, guestFile{
+			ID:          record.ID,
+			Name:        record.OriginalName,
+			Size:        record.Size,
+			UploadTime:  record.UploadTime,
+			SHA256:      record.SHA256,
+			Folder:      record.Folder,
+			DownloadURL: h.baseURL(c) + "/api/guest/files/" + record.ID + "?token=" + url.QueryEscape(g.ID),
+		})
+	}
+	scope["files"] = files
+	c.JSON(http.StatusOK, scope)
+}
+
+// GuestDownload serves a file the read token covers, under the rules and
+// caps of its download link. The token stands in for the guest, not for
+// the owner, so the owner's exemptions don't apply.
+func (h *Handler) GuestDownload(c *gin.Context) {
+	g, ok := guestAccess(c, db.GuestRead)
+	if !ok {
+		return
+	}
+	record, err := db.GetFileRecord(h.Store, c.Param("id"))
+	if err != nil || !g.Covers(record) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	if status, body := h.linkRefusal(c, record); status != 0 {
+		c.JSON(status, body)
+		return
+	}
+	if record.Quarantined {
+		c.JSON(http.StatusForbidden, gin.H{"error": "File is quarantined"})
+		return
+	}
+	if !h.downloadAllowed(c, record) {
+		return
+	}
+	if !h.charge(c, record) {
+		return
+	}
+	h.recordDownload(c, record)
+
+	if record.IsLink() {
+		c.Redirect(http.StatusFound, record.TargetURL)
+		return
+	}
+	if storage.IsS3Path(record.StoredPath) {
+		h.redirectToS3(c, record)
+		return
+	}
+	c.Header("Accept-Ranges", "bytes")
+	if record.SHA256 != "" {
+		c.Header("ETag", `"`+record.SHA256+`"`)
+	}
+	serveAttachment(c, record.StoredPath, record.OriginalName)
+}
+
+// GuestUpload takes an upload into the folder of an upload token. The file
+// belongs to the token's owner; the guest gets no link to it.
+func (h *Handler) GuestUpload(c *gin.Context) {
+	g, ok := guestAccess(c, db.GuestUpload)
+	if !ok {
+		return
+	}
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file is received"})
+		return
+	}
+	defer file.Close()
+
+	id := uuid.New().String()
+	storedPath, size, hash, err := storage.StoreFile(storage.ContextReader(c.Request.Context(), file), h.TempDir, h.StorageDir, id)
+	if err != nil {
+		if timedOut(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file: " + err.Error()})
+		return
+	}
+
+	record, err := h.ingest(c.Request.Context(), stagedFile{ID: id, Path: storedPath, Size: size, SHA256: hash}, ingestOptions{
+		OwnerID:       g.OwnerID,
+		RemoteAddr:    c.ClientIP(),
+		Name:          header.Filename,
+		StripMetadata: h.StripMetadata,
+		Folder:        g.Folder,
+		Metadata:      map[string]string{"guest": g.Name},
+	})
+	if err != nil {
+		respondIngestError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success", "name": record.OriginalName, "size": record.Size})
+}
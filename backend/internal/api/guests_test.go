@@ -0,0 +1,201 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestGuestTokens(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+	db.UpsertClient(h.Store, "client-a", "Alice", "CODEA", 0)
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.POST("/persona/guest-tokens", h.CreateGuestToken)
+	router.GET("/persona/guest-tokens", h.ListGuestTokens)
+	router.DELETE("/persona/guest-tokens/:id", h.DeleteGuestToken)
+	guest := router.Group("/guest", h.GuestAuth())
+	guest.GET("", h.GetGuestScope)
+	guest.GET("/files/:id", h.GuestDownload)
+	guest.POST("/upload", h.GuestUpload)
+
+	shared := uploadTestFile(t, router, "client-a", "plan.txt", []byte("the plan"))
+	private := uploadTestFile(t, router, "client-a", "diary.txt", []byte("dear diary"))
+
+	request := func(method, path, clientID, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if clientID != "" {
+			req.Header.Set("X-Client-ID", clientID)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+	mint := func(body string) string {
+		w := request("POST", "/persona/guest-tokens", "client-a", body)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("minting failed: %d %s", w.Code, w.Body.String())
+		}
+		var resp struct {
+			Token db.GuestToken `json:"token"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp.Token.ID
+	}
+
+	if w := request("POST", "/persona/guest-tokens", "client-a", `{"access": "read", "file_ids": ["`+private["id"].(string)+`"], "folder": "x"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected files and a folder together to be rejected, got %d", w.Code)
+	}
+	if w := request("POST", "/persona/guest-tokens", "client-b", `{"access": "read", "file_ids": ["`+private["id"].(string)+`"]}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected someone else's file to be rejected, got %d", w.Code)
+	}
+
+	read := mint(`{"name": "Bob", "access": "read", "file_ids": ["` + shared["id"].(string) + `"]}`)
+	w := request("GET", "/guest?token="+read, "", "")
+	var scope struct {
+		Owner string      `json:"owner"`
+		Files []guestFile `json:"files"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &scope)
+	if w.Code != http.StatusOK || scope.Owner != "Alice" || len(scope.Files) != 1 || scope.Files[0].ID != shared["id"] {
+		t.Fatalf("expected the shared file only, got %d %s", w.Code, w.Body.String())
+	}
+	if w := request("GET", "/guest/files/"+shared["id"].(string)+"?token="+read, "", ""); w.Code != http.StatusOK || w.Body.String() != "the plan" {
+		t.Errorf("expected the shared file, got %d %q", w.Code, w.Body.String())
+	}
+	if w := request("GET", "/guest/files/"+private["id"].(string)+"?token="+read, "", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected files outside the scope to be hidden, got %d", w.Code)
+	}
+	if w := request("POST", "/guest/upload?token="+read, "", ""); w.Code != http.StatusForbidden {
+		t.Errorf("expected a read token not to upload, got %d", w.Code)
+	}
+	if w := request("GET", "/guest?token=nope", "", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected an unknown token to be refused, got %d", w.Code)
+	}
+
+	// Upload tokens drop files into the owner's folder
+	upload := mint(`{"access": "upload", "folder": "incoming"}`)
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "contract.pdf")
+	part.Write([]byte("signed"))
+	writer.Close()
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/guest/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("X-Guest-Token", upload)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("guest upload failed: %d %s", w.Code, w.Body.String())
+	}
+	files, _ := db.GetFileRecordsByOwner(h.Store, "client-a")
+	found := false
+	for _, f := range files {
+		if f.OriginalName == "contract.pdf" {
+			found = f.Folder == "incoming" && f.Metadata["guest"] == "Guest"
+		}
+	}
+	if !found {
+		t.Errorf("expected the upload in the owner's folder, got %+v", files)
+	}
+	if w := request("GET", "/guest/files/"+shared["id"].(string)+"?token="+upload, "", ""); w.Code != http.StatusForbidden {
+		t.Errorf("expected an upload token not to read, got %d", w.Code)
+	}
+
+	// Expired and revoked tokens stop working
+	expiring := mint(`{"access": "read", "folder": "incoming", "expires_at": "` + strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10) + `"}`)
+	if w := request("GET", "/guest?token="+expiring, "", ""); w.Code != http.StatusOK || !bytes.Contains(w.Body.Bytes(), []byte("contract.pdf")) {
+		t.Errorf("expected the folder's files, got %d %s", w.Code, w.Body.String())
+	}
+	h.Now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	if w := request("GET", "/guest?token="+expiring, "", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected an expired token to be refused, got %d", w.Code)
+	}
+	h.Now = nil
+	if w := request("DELETE", "/persona/guest-tokens/"+read, "client-b", ""); w.Code != http.StatusForbidden {
+		t.Errorf("expected others not to revoke the token, got %d", w.Code)
+	}
+	if w := request("DELETE", "/persona/guest-tokens/"+read, "client-a", ""); w.Code != http.StatusOK {
+		t.Errorf("expected the owner to revoke the token, got %d", w.Code)
+	}
+	if w := request("GET", "/guest?token="+read, "", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected a revoked token to be refused, got %d", w.Code)
+	}
+}
+
+// Guests are held to the link's rules and caps like anyone else with the
+// link; the token doesn't make them the owner.
+func TestGuestDownloadFollowsLinkRules(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+	db.UpsertClient(h.Store, "client-a", "Alice", "CODEA", 0)
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.POST("/persona/guest-tokens", h.CreateGuestToken)
+	guest := router.Group("/guest", h.GuestAuth())
+	guest.GET("", h.GetGuestScope)
+	guest.GET("/files/:id", h.GuestDownload)
+
+	once := uploadTestFile(t, router, "client-a", "once.txt", []byte("just once"))
+	later := uploadTestFile(t, router, "client-a", "later.txt", []byte("not yet"))
+	db.SetFileLimits(h.Store, once["id"].(string), 1, 0, false)
+	db.SetFilePublishAt(h.Store, later["id"].(string), time.Now().Add(time.Hour).Unix())
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/persona/guest-tokens", bytes.NewBufferString(`{"access": "read", "file_ids": ["`+once["id"].(string)+`", "`+later["id"].(string)+`"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Client-ID", "client-a")
+	router.ServeHTTP(w, req)
+	var minted struct {
+		Token db.GuestToken `json:"token"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &minted)
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path+"?token="+minted.Token.ID, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+	listed := func() map[string]bool {
+		var scope struct {
+			Files []guestFile `json:"files"`
+		}
+		json.Unmarshal(get("/guest").Body.Bytes(), &scope)
+		ids := map[string]bool{}
+		for _, f := range scope.Files {
+			ids[f.ID] = true
+		}
+		return ids
+	}
+
+	if ids := listed(); !ids[once["id"].(string)] || ids[later["id"].(string)] {
+		t.Errorf("expected the published file only, got %v", ids)
+	}
+	if w := get("/guest/files/" + later["id"].(string)); w.Code != http.StatusForbidden {
+		t.Errorf("expected an embargoed file to be refused, got %d", w.Code)
+	}
+	if w := get("/guest/files/" + once["id"].(string)); w.Code != http.StatusOK || w.Body.String() != "just once" {
+		t.Fatalf("expected the file, got %d %q", w.Code, w.Body.String())
+	}
+	if record, _ := db.GetFileRecord(h.Store, once["id"].(string)); record.Downloads != 1 {
+		t.Errorf("expected the guest's download to be counted, got %d", record.Downloads)
+	}
+	if w := get("/guest/files/" + once["id"].(string)); w.Code != http.StatusGone {
+		t.Errorf("expected the limit to hold for guests, got %d", w.Code)
+	}
+	if ids := listed(); ids[once["id"].(string)] {
+		t.Errorf("expected a spent file to leave the listing, got %v", ids)
+	}
+}
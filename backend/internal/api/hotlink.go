@@ -0,0 +1,137 @@
+package api
+
+import (
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// hotlinkPolicy keeps anything opened directly from a raw link from running
+// scripts on the depot's origin; embedded images are unaffected.
+const hotlinkPolicy = "default-src 'none'; img-src 'self' data:; style-src 'unsafe-inline'; sandbox"
+
+// hotlinkMaxAge is a year: the content behind a download link never changes,
+// updates get a new link.
+const hotlinkMaxAge = 365 * 24 * time.Hour
+
+// inlineContentType is the type a raw link is served as. Markup that
+// browsers would render as a page is served as text.
+func inlineContentType(name string) string {
+	contentType := mime.TypeByExtension(filepath.Ext(name))
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case contentType == "":
+		return "application/octet-stream"
+	case mediaType == "text/html", mediaType == "application/xhtml+xml":
+		return "text/plain; charset=utf-8"
+	}
+	return contentType
+}
+
+// hotlinkCacheControl lets caches keep a raw link for long unless something
+// about the link can change its answer: expiry, an embargo, limits, a
+// password or where it is used from.
+func (h *Handler) hotlinkCacheControl(record *db.FileRecord) string {
+	if record.ExpiresAt > 0 || record.PublishAt > h.now().Unix() || record.MaxDownloads > 0 ||
+		record.MaxBytes > 0 || record.PasswordProtected || record.Restricted() {
+		return "private, no-cache"
+	}
+	return "public, max-age=" + strconv.Itoa(int(hotlinkMaxAge.Seconds())) + ", immutable"
+}
+
+// ServeRawFile serves /raw/<link>/<filename> inline, so images and other
+// media render when embedded in wikis and issue trackers. The filename is
+// only there for the embedding site; a wrong one redirects to the real one.
+func (h *Handler) ServeRawFile(c *gin.Context) {
+	record, err := h.findDownloadRecord(c.Param("link"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	if c.Param("filename") != record.OriginalName {
+		// Relative, so it also works under a tenant prefix
+		c.Header("Location", url.PathEscape(record.OriginalName))
+		c.Status(http.StatusFound)
+		return
+	}
+	if !h.linkAccessible(c, record) {
+		return
+	}
+	if record.Quarantined && !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "File is quarantined"})
+		return
+	}
+	if !h.downloadAllowed(c, record) {
+		return
+	}
+
+	// The target of a link file can change, so its redirect isn't cached
+	if record.IsLink() {
+		if c.Request.Method != http.MethodHead {
+			if !h.chargeDownload(c, record) {
+				return
+			}
+			h.recordDownload(c, record)
+		}
+		c.Redirect(http.StatusFound, record.TargetURL)
+		return
+	}
+
+	contentType := inlineContentType(record.OriginalName)
+	c.Header("Content-Security-Policy", hotlinkPolicy)
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Cache-Control", h.hotlinkCacheControl(record))
+
+	// Revalidations are answered without counting a download
+	if record.SHA256 != "" && c.GetHeader("If-None-Match") == `"`+record.SHA256+`"` {
+		c.Header("ETag", `"`+record.SHA256+`"`)
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	if c.Request.Method != http.MethodHead {
+		if !h.chargeDownload(c, record) {
+			return
+		}
+		h.recordDownload(c, record)
+	}
+
+	if storage.IsS3Path(record.StoredPath) {
+		if h.S3 == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "S3 storage is not configured"})
+			return
+		}
+		q := url.Values{}
+		q.Set("response-content-type", contentType)
+		q.Set("response-content-disposition", contentDisposition("inline", record.OriginalName))
+		target, err := h.S3.PresignGet(h.S3.KeyFromPath(record.StoredPath), q, downloadURLExpiry)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign download URL"})
+			return
+		}
+		c.Redirect(http.StatusFound, target)
+		return
+	}
+
+	f, err := os.Open(record.StoredPath)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	defer f.Close()
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", contentDisposition("inline", record.OriginalName))
+	if record.SHA256 != "" {
+		c.Header("ETag", `"`+record.SHA256+`"`)
+	}
+	http.ServeContent(c.Writer, c.Request, record.OriginalName, time.Unix(record.UploadTime, 0), storage.ContextReadSeeker(c.Request.Context(), f))
+}
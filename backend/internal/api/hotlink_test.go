@@ -0,0 +1,84 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestServeRawFile(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.GET("/raw/:link/:filename", h.ServeRawFile)
+	router.HEAD("/raw/:link/:filename", h.ServeRawFile)
+
+	png := uploadTestFile(t, router, "client-a", "diagram.png", []byte("\x89PNG fake"))
+	page := uploadTestFile(t, router, "client-a", "page.html", []byte("<script>alert(1)</script>"))
+	link := png["download_link"].(string)
+
+	get := func(method, path string, header ...string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("GET", "/raw/"+link+"/diagram.png")
+	if w.Code != http.StatusOK || w.Body.String() != "\x89PNG fake" {
+		t.Fatalf("expected the file, got %d %q", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "image/png" {
+		t.Errorf("expected image/png, got %q", ct)
+	}
+	if cd := w.Header().Get("Content-Disposition"); !strings.HasPrefix(cd, "inline") {
+		t.Errorf("expected an inline disposition, got %q", cd)
+	}
+	if cc := w.Header().Get("Cache-Control"); !strings.Contains(cc, "max-age=31536000") {
+		t.Errorf("expected a long cache lifetime, got %q", cc)
+	}
+
+	// A wrong name points at the real one
+	w = get("GET", "/raw/"+link+"/other.png")
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "diagram.png" {
+		t.Errorf("expected a redirect to the real name, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	if w := get("GET", "/raw/missing/diagram.png"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown link, got %d", w.Code)
+	}
+
+	// Revalidation and HEAD don't count as downloads
+	if w := get("GET", "/raw/"+link+"/diagram.png", "If-None-Match", `"`+png["sha256"].(string)+`"`); w.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for a matching ETag, got %d", w.Code)
+	}
+	get("HEAD", "/raw/"+link+"/diagram.png")
+	record, _ := db.GetFileRecord(h.Store, png["id"].(string))
+	buckets, _ := db.GetAnalytics(h.Store, record.ID, time.Now().Add(-time.Hour))
+	if len(buckets) != 1 || buckets[0].Downloads != 1 {
+		t.Errorf("expected one recorded download, got %+v", buckets)
+	}
+
+	// Pages are shown as text, not rendered
+	w = get("GET", "/raw/"+page["download_link"].(string)+"/page.html")
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("expected HTML to be served as text, got %q", ct)
+	}
+
+	// Links whose answer can change aren't cached for long
+	record.MaxDownloads = 10
+	db.SaveFileRecord(h.Store, *record)
+	w = get("GET", "/raw/"+link+"/diagram.png")
+	if cc := w.Header().Get("Cache-Control"); cc != "private, no-cache" {
+		t.Errorf("expected a limited link not to be cached, got %q", cc)
+	}
+}
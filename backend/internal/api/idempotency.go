@@ -0,0 +1,257 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/tenant"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// idempotencyRetention is how long a response is replayed for its key
+	idempotencyRetention = 24 * time.Hour
+	// maxIdempotencyKey is the longest Idempotency-Key accepted
+	maxIdempotencyKey = 255
+	// maxReplayBody is the largest response kept for replay; larger ones
+	// are answered once and not remembered
+	maxReplayBody = 1 << 20
+)
+
+// claimSet holds the keys claimed by requests in progress. On a
+// db.SharedStore each claim is also a lease, so replicas turn away a key
+// that another one is working on. The zero value is ready to use.
+type claimSet struct {
+	mu   sync.Mutex
+	keys map[string]func()
+}
+
+// claimTTL bounds how long a claim of a replica that died stays taken.
+const claimTTL = time.Hour
+
+// start claims a key for requests to s, and reports false if a request
+// holds it already.
+func (r *claimSet) start(s db.CelerixStore, key string) bool {
+	r.mu.Lock()
+	if _, ok := r.keys[key]; ok {
+		r.mu.Unlock()
+		return false
+	}
+	if r.keys == nil {
+		r.keys = make(map[string]func())
+	}
+	r.keys[key] = nil
+	r.mu.Unlock()
+
+	// Keys may hold anything, and lease names can't hold spaces
+	sum := sha256.Sum256([]byte(key))
+	unlock, ok, err := db.TryLock(s, "claim:"+hex.EncodeToString(sum[:16]), claimTTL)
+	if err != nil {
+		log.Printf("[ERROR] Failed to claim %q across replicas: %v", key, err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !ok {
+		delete(r.keys, key)
+		return false
+	}
+	r.keys[key] = unlock
+	return true
+}
+
+func (r *claimSet) done(key string) {
+	r.mu.Lock()
+	unlock := r.keys[key]
+	delete(r.keys, key)
+	r.mu.Unlock()
+	if unlock != nil {
+		unlock()
+	}
+}
+
+// recordingWriter keeps a copy of the response for replay, until it grows
+// past maxReplayBody.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	w.keep(p)
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.keep([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *recordingWriter) keep(p []byte) {
+	if w.overflow {
+		return
+	}
+	if w.body.Len()+len(p) > maxReplayBody {
+		w.overflow = true
+		w.body.Reset()
+		return
+	}
+	w.body.Write(p)
+}
+
+// hashingBody hashes a request body as the handler reads it, so transfers
+// are hashed while they stream.
+type hashingBody struct {
+	io.ReadCloser
+	hash hash.Hash
+	eof  bool
+}
+
+// newHashingBody hashes the query of r and then its body.
+func newHashingBody(r *http.Request) *hashingBody {
+	body := r.Body
+	if body == nil {
+		body = http.NoBody
+	}
+	b := &hashingBody{ReadCloser: body, hash: sha256.New()}
+	b.hash.Write([]byte(r.URL.RawQuery + "\n"))
+	return b
+}
+
+func (b *hashingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.hash.Write(p[:n])
+	if err == io.EOF {
+		b.eof = true
+	}
+	return n, err
+}
+
+// finish reads what is left of the body, up to limit bytes, and reports
+// whether all of it was hashed.
+func (b *hashingBody) finish(limit int64) bool {
+	if !b.eof {
+		io.Copy(io.Discard, io.LimitReader(b, limit))
+	}
+	return b.eof
+}
+
+// fingerprint is what an Idempotency-Key is bound to: the method and path
+// of the request, and the SHA-256 of its query and body.
+func (b *hashingBody) fingerprint(r *http.Request) string {
+	return r.Method + " " + r.URL.Path + " " + hex.EncodeToString(b.hash.Sum(nil))
+}
+
+// replayable reports whether a retry should get the same answer. Server
+// errors, timeouts, conflicts and rate limits may not recur, so the retry
+// runs again.
+func replayable(status int) bool {
+	switch {
+	case status >= 500:
+		return false
+	case status == http.StatusRequestTimeout, status == http.StatusConflict, status == http.StatusTooManyRequests:
+		return false
+	}
+	return status >= 200
+}
+
+// Idempotency makes writes sent with an Idempotency-Key header safe to
+// retry: the first response for a client's key is recorded for
+// idempotencyRetention, and requests with the same key get it again,
+// marked with Idempotent-Replayed, instead of running twice. Reusing a key
+// for a different request, down to its query and body, is refused with
+// 422, as is a retry while the first attempt is still running. Requests
+// without a client ID are not tracked, nor are responses to a request
+// whose body the handler left mostly unread.
+func (h *Handler) Idempotency() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		clientID := c.GetHeader("X-Client-ID")
+		if key == "" || clientID == "" || isReadRequest(c) {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKey {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+			return
+		}
+
+		claim := clientID + ":" + key
+		if !h.idempotency.start(h.Store, claim) {
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is in progress"})
+			return
+		}
+		defer h.idempotency.done(claim)
+
+		body := newHashingBody(c.Request)
+		c.Request.Body = body
+		if r, err := db.GetIdempotency(h.Store, clientID, key); err == nil && h.now().Unix()-r.CreatedAt < int64(idempotencyRetention.Seconds()) {
+			// Bodies are capped by BodyLimit except on transfers, which
+			// the client sends again in full either way
+			if _, err := io.Copy(io.Discard, body); err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+				return
+			}
+			if r.Request != body.fingerprint(c.Request) {
+				c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was used for a different request"})
+				return
+			}
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(r.Status, r.ContentType, r.Body)
+			c.Abort()
+			return
+		}
+
+		w := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		if w.overflow || !replayable(w.Status()) || !body.finish(maxReplayBody) {
+			return
+		}
+		err := db.SaveIdempotency(h.Store, db.IdempotencyRecord{
+			ClientID:    clientID,
+			Key:         key,
+			Request:     body.fingerprint(c.Request),
+			Status:      w.Status(),
+			ContentType: w.Header().Get("Content-Type"),
+			Body:        w.body.Bytes(),
+			CreatedAt:   h.now().Unix(),
+		})
+		if err != nil {
+			log.Printf("Failed to record the response for an idempotency key: %v", err)
+		}
+	}
+}
+
+// PruneIdempotencyKeys forgets the responses older than
+// idempotencyRetention, the root depot's and every tenant's.
+func (h *Handler) PruneIdempotencyKeys(ctx context.Context) error {
+	before := h.now().Add(-idempotencyRetention)
+	_, err := db.PruneIdempotency(h.Store, before)
+	if err != nil || h.Tenants == nil {
+		return err
+	}
+	tenants, err := db.ListTenants(h.Store)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, t := range tenants {
+		if _, err := db.PruneIdempotency(tenant.Scope(h.Store, t.ID), before); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
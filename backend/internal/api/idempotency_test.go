@@ -0,0 +1,107 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestIdempotency(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+	now := time.Now()
+	h.Now = func() time.Time { return now }
+
+	created, status := 0, http.StatusCreated
+	router := gin.Default()
+	api := router.Group("/api", h.Idempotency())
+	api.POST("/upload", func(c *gin.Context) {
+		created++
+		c.JSON(status, gin.H{"id": fmt.Sprintf("file-%d", created)})
+	})
+	api.POST("/snippets", func(c *gin.Context) { c.Status(http.StatusCreated) })
+	api.POST("/notes", func(c *gin.Context) {
+		created++
+		body, _ := io.ReadAll(c.Request.Body)
+		c.JSON(http.StatusCreated, gin.H{"id": fmt.Sprintf("note-%d", created), "text": string(body)})
+	})
+
+	post := func(path, clientID, key string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, nil)
+		req.Header.Set("X-Client-ID", clientID)
+		req.Header.Set("Idempotency-Key", key)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	first := post("/api/upload", "client-a", "k1")
+	retry := post("/api/upload", "client-a", "k1")
+	if created != 1 || retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() {
+		t.Fatalf("expected the retry to replay the first response, created %d, got %d %s", created, retry.Code, retry.Body.String())
+	}
+	if retry.Header().Get("Idempotent-Replayed") != "true" || retry.Header().Get("Content-Type") != first.Header().Get("Content-Type") {
+		t.Errorf("unexpected replay headers %v", retry.Header())
+	}
+	if post("/api/upload", "client-b", "k1"); created != 2 {
+		t.Error("expected keys to be scoped to the client")
+	}
+	if post("/api/upload", "client-a", ""); created != 3 {
+		t.Error("expected a request without a key to run")
+	}
+	if w := post("/api/snippets", "client-a", "k1"); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for a key reused on another route, got %d", w.Code)
+	}
+
+	// The key is bound to the query and body too
+	note := func(query, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/notes"+query, bytes.NewBufferString(body))
+		req.Header.Set("X-Client-ID", "client-a")
+		req.Header.Set("Idempotency-Key", "k4")
+		router.ServeHTTP(w, req)
+		return w
+	}
+	first = note("?folder=a", "hello")
+	if w := note("?folder=a", "hello"); created != 4 || w.Body.String() != first.Body.String() {
+		t.Errorf("expected the same note to be replayed, created %d, got %s", created, w.Body.String())
+	}
+	if w := note("?folder=a", "goodbye"); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for a key reused with another body, got %d", w.Code)
+	}
+	if w := note("?folder=b", "hello"); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for a key reused with another query, got %d", w.Code)
+	}
+
+	status = http.StatusInternalServerError
+	post("/api/upload", "client-a", "k2")
+	status = http.StatusCreated
+	if w := post("/api/upload", "client-a", "k2"); created != 6 || w.Code != http.StatusCreated {
+		t.Errorf("expected a server error not to be replayed, created %d, got %d", created, w.Code)
+	}
+
+	claim := "client-a:k3"
+	h.idempotency.start(h.Store, claim)
+	if w := post("/api/upload", "client-a", "k3"); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 while the first attempt runs, got %d", w.Code)
+	}
+	h.idempotency.done(claim)
+
+	now = now.Add(idempotencyRetention + time.Minute)
+	if err := h.PruneIdempotencyKeys(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetIdempotency(h.Store, "client-a", "k1"); err == nil {
+		t.Error("expected expired responses to be pruned")
+	}
+	if post("/api/upload", "client-a", "k1"); created != 7 {
+		t.Error("expected an expired key to run again")
+	}
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"context"
+	"os"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/storage"
+	"github.com/google/uuid"
+)
+
+// ImportInboxFile copies a file dropped into the inbox directory into the
+// depot as a file of the InboxOwner, or a system file when there is none.
+// Subdirectories of the inbox become folders.
+func (h *Handler) ImportInboxFile(path, name, folder string) error {
+	isAdmin := false
+	if h.InboxOwner != "" {
+		client, err := db.GetClient(h.Store, h.InboxOwner)
+		if err != nil {
+			return err
+		}
+		isAdmin = client.IsAdmin
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	id := uuid.New().String()
+	storedPath, size, hash, err := storage.StoreFile(f, h.TempDir, h.StorageDir, id)
+	if err != nil {
+		return err
+	}
+	_, err = h.ingest(context.Background(), stagedFile{ID: id, Path: storedPath, Size: size, SHA256: hash}, ingestOptions{
+		OwnerID:       h.InboxOwner,
+		Name:          name,
+		IsAdmin:       isAdmin,
+		StripMetadata: h.StripMetadata,
+		Folder:        db.NormalizeFolder(folder),
+		Metadata:      map[string]string{"source": "inbox"},
+	})
+	return err
+}
@@ -0,0 +1,528 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/hooks"
+	"github.com/celerix/depot/internal/policy"
+	"github.com/celerix/depot/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// stagedFile is content already written to storage that has not been
+// recorded yet.
+type stagedFile struct {
+	ID     string
+	Path   string
+	Size   int64
+	SHA256 string
+}
+
+type ingestOptions struct {
+	OwnerID  string
+	Name     string
+	IsPublic bool
+	IsAdmin  bool
+	// StripMetadata runs the sanitizer pipeline before the content is stored
+	StripMetadata bool
+	Folder        string
+	Tags          []string
+	Metadata      map[string]string
+	PublishAt     int64
+	// Password protects the download link
+	Password string
+	// External registers the staged file where it is instead of taking it
+	// into storage: it is never modified, deduplicated or deleted
+	External bool
+	// KeepName stores Name as sent instead of sanitizing it
+	KeepName bool
+	// Preset names the upload preset whose settings apply
+	Preset string
+	// RemoteAddr is the uploader's address, for the audit log
+	RemoteAddr string
+	// Signature is a detached signature of the content to verify against
+	// the trusted keys
+	Signature []byte
+	// Overwrite is the overwrite mode for a file of the same name: empty,
+	// "false", "replace" or "version"
+	Overwrite string
+	// Referenced is set when the staged file is stored content the caller
+	// took a blob reference for: it is used as is, and the reference is
+	// dropped on failure
+	Referenced bool
+	// CopyOf is the file a copy is made of. Its signature, sanitizing and
+	// system tags carry over to the copy, as the content is the same.
+	CopyOf *db.FileRecord
+}
+
+// ingestError carries the HTTP status an ingest failure should map to.
+type ingestError struct {
+	Status  int
+	Message string
+}
+
+func (e *ingestError) Error() string {
+	return e.Message
+}
+
+// hookError maps a hook failure to a response. Rejections carry a reason meant
+// for the client; anything else is an operator problem and stays in the log.
+func hookError(err error) *ingestError {
+	var rej *hooks.Rejection
+	if errors.As(err, &rej) {
+		return &ingestError{Status: http.StatusUnprocessableEntity, Message: "Upload rejected: " + rej.Reason}
+	}
+	log.Printf("[ERROR] Upload hook failed: %v", err)
+	return &ingestError{Status: http.StatusServiceUnavailable, Message: "Upload validation is unavailable"}
+}
+
+func respondIngestError(c *gin.Context, err error) {
+	if timedOut(c, err) {
+		return
+	}
+	var ie *ingestError
+	if errors.As(err, &ie) {
+		c.JSON(ie.Status, gin.H{"error": ie.Message})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// AddFile stores r as a file of ownerID through the regular upload
+// pipeline. It is meant for seeding and imports outside of a request.
+func (h *Handler) AddFile(r io.Reader, ownerID, name, folder string, public bool) (*db.FileRecord, error) {
+	id := uuid.New().String()
+	storedPath, size, hash, err := storage.StoreFile(r, h.TempDir, h.StorageDir, id)
+	if err != nil {
+		return nil, err
+	}
+	return h.ingest(context.Background(), stagedFile{ID: id, Path: storedPath, Size: size, SHA256: hash}, ingestOptions{
+		OwnerID:  ownerID,
+		Name:     name,
+		IsPublic: public,
+		Folder:   db.NormalizeFolder(folder),
+	})
+}
+
+// ingest runs a staged file through the upload pipeline (policy checks,
+// deduplication) and saves the resulting record. The staged file is owned by
+// ingest from here on and is cleaned up on failure.
+func (h *Handler) ingest(ctx context.Context, staged stagedFile, opts ingestOptions) (*db.FileRecord, error) {
+	discard := func() {
+		switch {
+		case opts.Referenced:
+			h.releaseStoredFile(&db.FileRecord{StoredPath: staged.Path, SHA256: staged.SHA256})
+		case !opts.External:
+			h.deleteStored(staged.Path)
+		}
+	}
+	rawName := opts.Name
+	if !opts.KeepName {
+		opts.Name = h.cleanName(opts.Name)
+	}
+	var preset *db.UploadPreset
+	if opts.Preset != "" {
+		var err error
+		preset, err = db.GetPreset(h.Store, opts.Preset)
+		if err != nil {
+			discard()
+			return nil, &ingestError{Status: http.StatusBadRequest, Message: "Unknown upload preset " + opts.Preset}
+		}
+		if opts.Folder == "" {
+			opts.Folder = preset.Folder
+		}
+		opts.Tags = db.ParseTags(strings.Join(append(append([]string{}, opts.Tags...), preset.Tags...), ","))
+	}
+	settings := db.GetSettings(h.Store)
+	if !settings.AllowsName(opts.Name) {
+		discard()
+		return nil, &ingestError{Status: http.StatusUnsupportedMediaType, Message: "File type is not allowed"}
+	}
+	folderPolicy := db.ResolveFolderPolicy(h.Store, opts.OwnerID, opts.Folder)
+	if !folderPolicy.AllowsName(opts.Name) {
+		discard()
+		return nil, &ingestError{Status: http.StatusUnsupportedMediaType, Message: "File type is not allowed in this folder"}
+	}
+	if folderPolicy.PasswordRequired() && opts.Password == "" {
+		discard()
+		return nil, &ingestError{Status: http.StatusBadRequest, Message: "Files in this folder need a download password"}
+	}
+	if preset != nil && preset.RequirePassword && opts.Password == "" {
+		discard()
+		return nil, &ingestError{Status: http.StatusBadRequest, Message: "Uploads with this preset need a download password"}
+	}
+	claim, replaced, err := h.claimName(&opts)
+	if err != nil {
+		discard()
+		return nil, err
+	}
+	if claim != "" {
+		defer h.names.done(claim)
+	}
+	if settings.MaxUploadBytes > 0 && staged.Size > settings.MaxUploadBytes {
+		discard()
+		return nil, &ingestError{Status: http.StatusRequestEntityTooLarge, Message: "File exceeds the maximum upload size"}
+	}
+	if preset != nil && preset.MaxUploadBytes > 0 && staged.Size > preset.MaxUploadBytes {
+		discard()
+		return nil, &ingestError{Status: http.StatusRequestEntityTooLarge, Message: "File exceeds the preset's maximum upload size"}
+	}
+	if err := h.enforceQuota(opts.OwnerID, staged, settings); err != nil {
+		discard()
+		return nil, err
+	}
+	var signedBy string
+	if opts.Signature != nil {
+		key, err := h.verifySignature(staged.Path, opts.Signature)
+		if err != nil {
+			discard()
+			return nil, err
+		}
+		signedBy = key.Name
+	} else if opts.CopyOf != nil && opts.CopyOf.SignedBy != "" {
+		signedBy = opts.CopyOf.SignedBy
+	} else if folderPolicy.SignatureRequired() {
+		discard()
+		return nil, &ingestError{Status: http.StatusUnprocessableEntity, Message: "Files in this folder must be signed by a trusted key"}
+	}
+
+	// An upload abandoned by now isn't worth the hooks and the processing
+	if err := ctx.Err(); err != nil {
+		discard()
+		return nil, err
+	}
+	if err := h.Hooks.PreUpload(ctx, hooks.Upload{
+		FileID:   staged.ID,
+		OwnerID:  opts.OwnerID,
+		Name:     opts.Name,
+		Size:     staged.Size,
+		SHA256:   staged.SHA256,
+		Path:     staged.Path,
+		IsPublic: opts.IsPublic,
+		IsAdmin:  opts.IsAdmin,
+	}); err != nil {
+		discard()
+		return nil, hookError(err)
+	}
+	var scannedAt int64
+	if h.Hooks.HasPreUpload() {
+		scannedAt = time.Now().Unix()
+	} else if opts.CopyOf != nil {
+		scannedAt = opts.CopyOf.ScannedAt
+	}
+
+	role := "client"
+	if opts.IsAdmin {
+		role = "admin"
+	}
+
+	name := opts.Name
+	quarantined := false
+	decision := h.policy().Evaluate(policy.Subject{
+		Name: opts.Name,
+		Size: staged.Size,
+		Head: readHead(staged.Path),
+		Role: role,
+	})
+	if decision != nil {
+		switch decision.Action {
+		case policy.ActionReject:
+			h.auditPolicy(decision, "", opts)
+			discard()
+			return nil, &ingestError{Status: http.StatusUnprocessableEntity, Message: "Upload rejected by policy: " + decision.Rule}
+		case policy.ActionQuarantine:
+			quarantined = true
+		}
+		name = decision.Name
+	}
+
+	// A signed file is kept as signed, stripping would void the signature
+	var sanitized []string
+	if opts.CopyOf != nil {
+		sanitized = opts.CopyOf.Sanitized
+	}
+	if opts.StripMetadata && signedBy == "" && len(h.Sanitizers) > 0 && !storage.IsS3Path(staged.Path) && !opts.External && !opts.Referenced {
+		applied, err := h.Sanitizers.Apply(staged.Path, name)
+		if err != nil {
+			storage.DeleteFile(staged.Path)
+			return nil, &ingestError{Status: http.StatusUnprocessableEntity, Message: "Failed to strip metadata: " + err.Error()}
+		}
+		if len(applied) > 0 {
+			sanitized = applied
+			// The content changed, so it needs a new identity
+			staged.Size, staged.SHA256, err = storage.HashFile(staged.Path)
+			if err != nil {
+				storage.DeleteFile(staged.Path)
+				return nil, errors.New("Failed to hash file: " + err.Error())
+			}
+		}
+	}
+
+	storedPath := staged.Path
+	if !opts.External && !opts.Referenced {
+		var err error
+		storedPath, err = h.dedupBlob(ctx, staged.Path, staged.Size, staged.SHA256)
+		if err != nil {
+			discard()
+			return nil, errors.New("Failed to store file: " + err.Error())
+		}
+	}
+
+	now := h.now()
+	linkExpiry := settings.LinkExpirySeconds
+	if folderPolicy.LinkExpirySeconds != nil {
+		linkExpiry = *folderPolicy.LinkExpirySeconds
+	}
+	if preset != nil && preset.LinkExpirySeconds != nil {
+		linkExpiry = *preset.LinkExpirySeconds
+	}
+	var expiresAt int64
+	if linkExpiry > 0 {
+		// An embargoed link's lifetime starts when it is published
+		expiresAt = max(now.Unix(), opts.PublishAt) + linkExpiry
+	}
+	var retainUntil int64
+	if retention := folderPolicy.Retention(); retention > 0 {
+		retainUntil = now.Unix() + retention
+	}
+	tags := opts.Tags
+	if len(folderPolicy.Tags) > 0 {
+		tags = db.ParseTags(strings.Join(append(append([]string{}, tags...), folderPolicy.Tags...), ","))
+	}
+
+	record := db.FileRecord{
+		ID:           staged.ID,
+		OriginalName: name,
+		StoredPath:   storedPath,
+		Size:         staged.Size,
+		UploadTime:   now.Unix(),
+		OwnerID:      opts.OwnerID,
+		DownloadLink: uuid.New().String(),
+		IsPublic:     opts.IsPublic,
+		SHA256:       staged.SHA256,
+		Quarantined:  quarantined,
+		Sanitized:    sanitized,
+		ExpiresAt:    expiresAt,
+		Folder:       opts.Folder,
+		Tags:         tags,
+		Metadata:     opts.Metadata,
+		ScannedAt:    scannedAt,
+		PublishAt:    opts.PublishAt,
+		External:     opts.External,
+		RetainUntil:  retainUntil,
+		SignedBy:     signedBy,
+	}
+	if rawName != opts.Name {
+		record.RawName = rawName
+	}
+	if opts.CopyOf != nil {
+		record.RawName = opts.CopyOf.RawName
+		record.SystemTags = opts.CopyOf.SystemTags
+	}
+
+	var linkPassword *db.LinkPassword
+	if opts.Password != "" {
+		salt := make([]byte, 16)
+		rand.Read(salt)
+		linkPassword = &db.LinkPassword{
+			FileID:       record.ID,
+			PasswordSalt: hex.EncodeToString(salt),
+			PasswordHash: hashPassword(opts.Password, salt),
+		}
+		record.PasswordProtected = true
+	}
+
+	log.Printf("[DEBUG] Saving record: ID=%s, Name=%s, OwnerID=%s", record.ID, record.OriginalName, record.OwnerID)
+	if err := db.SaveFileRecord(h.Store, record); err != nil {
+		log.Printf("[DEBUG] Failed to save record: %v", err)
+		_ = h.releaseStoredFile(&record)
+		return nil, errors.New("Failed to save record: " + err.Error())
+	}
+	if linkPassword != nil {
+		if err := db.SaveLinkPassword(h.Store, *linkPassword); err != nil {
+			_ = db.DeleteFileRecord(h.Store, record.ID)
+			_ = h.releaseStoredFile(&record)
+			return nil, errors.New("Failed to save link password: " + err.Error())
+		}
+	}
+
+	if decision != nil && decision.Action != policy.ActionAllow {
+		h.auditPolicy(decision, record.ID, opts)
+	}
+	if err := db.RecordUsage(h.Store, record.OwnerID, now, record.Size, 0); err != nil {
+		log.Printf("[ERROR] Failed to record usage of %s: %v", record.ID, err)
+	}
+	if replaced != nil {
+		if err := h.removeFile(replaced); err != nil {
+			log.Printf("[ERROR] Failed to remove %s, replaced by %s: %v", replaced.ID, record.ID, err)
+		}
+	}
+	h.postUpload(record)
+
+	return &record, nil
+}
+
+// stripMetadataOption resolves the strip_metadata form field against the
+// depot-wide default.
+func (h *Handler) stripMetadataOption(c *gin.Context) bool {
+	switch c.PostForm("strip_metadata") {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	return h.StripMetadata
+}
+
+// enforceQuota refuses giving ownerID a file of the staged content when that
+// would exceed the depot's quota or the pool of the owner's group. Every
+// path that creates a file record runs it; those handing files to another
+// owner run enforcePoolQuota.
+func (h *Handler) enforceQuota(ownerID string, staged stagedFile, settings db.Settings) error {
+	if h.overQuota(staged, settings) {
+		return &ingestError{Status: http.StatusRequestEntityTooLarge, Message: "Storage quota exceeded"}
+	}
+	if h.overGroupQuota(ownerID, staged.Size) {
+		return &ingestError{Status: http.StatusRequestEntityTooLarge, Message: "Group storage quota exceeded"}
+	}
+	return nil
+}
+
+// enforcePoolQuota refuses handing the files to ownerID when they would
+// overflow the pool they move into. Their content is already stored, so
+// only the pool can run out; files already in it are free.
+func (h *Handler) enforcePoolQuota(ownerID string, records []db.FileRecord) error {
+	pool := h.quotaGroup(ownerID)
+	if pool == nil {
+		return nil
+	}
+	var size int64
+	for i := range records {
+		if from := h.quotaGroup(records[i].OwnerID); from == nil || from.ID != pool.ID {
+			size += records[i].Size
+		}
+	}
+	if size > 0 && h.overGroupQuota(ownerID, size) {
+		return &ingestError{Status: http.StatusRequestEntityTooLarge, Message: "Group storage quota exceeded"}
+	}
+	return nil
+}
+
+// overQuota reports whether storing the file would exceed the depot's quota.
+// A quota set by the operator wins over the one from the settings. Content
+// that is already stored is free since it will be deduplicated.
+func (h *Handler) overQuota(staged stagedFile, settings db.Settings) bool {
+	quota := h.QuotaBytes
+	if quota <= 0 {
+		quota = settings.QuotaBytes
+	}
+	if quota <= 0 {
+		return false
+	}
+	if _, err := db.GetBlob(h.Store, staged.SHA256); err == nil {
+		return false
+	}
+	return db.StorageUsed(h.Store)+staged.Size > quota
+}
+
+// overGroupQuota reports whether storing size more bytes would exceed the
+// quota pool of the owner's group. Pools count every file in full, shared
+// content included, as each member's storage does.
+func (h *Handler) overGroupQuota(ownerID string, size int64) bool {
+	group := h.quotaGroup(ownerID)
+	if group == nil || group.QuotaBytes <= 0 {
+		return false
+	}
+	used, _ := db.GroupUsage(h.Store, *group)
+	return used+size > group.QuotaBytes
+}
+
+// quotaGroup is the group whose pool the files of ownerID count towards:
+// the owner's group, or the owner itself when it is a group.
+func (h *Handler) quotaGroup(ownerID string) *db.ClientGroup {
+	if ownerID == "" {
+		return nil
+	}
+	if group := db.GroupOf(h.Store, ownerID); group != nil {
+		return group
+	}
+	if group, err := db.GetGroup(h.Store, ownerID); err == nil {
+		return group
+	}
+	return nil
+}
+
+// checkPlacement runs the checks of ingest that depend on a file's name,
+// owner and folder, for a stored file that is renamed or moved. A policy
+// rule that rejects the name is an error; any other decision is returned
+// for the caller to apply.
+func (h *Handler) checkPlacement(record *db.FileRecord, ownerID, name, folder string, isAdmin bool) (*policy.Decision, error) {
+	if !db.GetSettings(h.Store).AllowsName(name) {
+		return nil, &ingestError{Status: http.StatusUnsupportedMediaType, Message: "File type is not allowed"}
+	}
+	folderPolicy := db.ResolveFolderPolicy(h.Store, ownerID, folder)
+	if !folderPolicy.AllowsName(name) {
+		return nil, &ingestError{Status: http.StatusUnsupportedMediaType, Message: "File type is not allowed in this folder"}
+	}
+	if folderPolicy.PasswordRequired() && !record.PasswordProtected {
+		return nil, &ingestError{Status: http.StatusBadRequest, Message: "Files in this folder need a download password"}
+	}
+	if folderPolicy.SignatureRequired() && record.SignedBy == "" {
+		return nil, &ingestError{Status: http.StatusUnprocessableEntity, Message: "Files in this folder must be signed by a trusted key"}
+	}
+
+	role := "client"
+	if isAdmin {
+		role = "admin"
+	}
+	decision := h.policy().Evaluate(policy.Subject{
+		Name: name,
+		Size: record.Size,
+		Head: readHead(record.StoredPath),
+		Role: role,
+	})
+	if decision != nil && decision.Action == policy.ActionReject {
+		h.auditPolicy(decision, record.ID, ingestOptions{OwnerID: ownerID, Name: name})
+		return nil, &ingestError{Status: http.StatusUnprocessableEntity, Message: "Rejected by policy: " + decision.Rule}
+	}
+	return decision, nil
+}
+
+func (h *Handler) auditPolicy(d *policy.Decision, fileID string, opts ingestOptions) {
+	err := db.AddAudit(h.Store, db.AuditEntry{
+		Event:   "policy",
+		Rule:    d.Rule,
+		Action:  d.Action,
+		FileID:  fileID,
+		OwnerID: opts.OwnerID,
+		Name:    opts.Name,
+		Detail:  d.Name,
+		IP:      h.loggedAddress(opts.RemoteAddr),
+	})
+	if err != nil {
+		log.Printf("[ERROR] Failed to write policy audit entry: %v", err)
+	}
+}
+
+func readHead(path string) []byte {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	return head[:n]
+}
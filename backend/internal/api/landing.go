@@ -0,0 +1,154 @@
+package api
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	scanQuarantined = "quarantined"
+	scanPassed      = "scanned"
+	scanNone        = "not_scanned"
+)
+
+type landingInfo struct {
+	Name       string `json:"name"`
+	Size       int64  `json:"size"`
+	Uploader   string `json:"uploader"`
+	UploadTime int64  `json:"upload_time"`
+	ExpiresAt  int64  `json:"expires_at,omitempty"`
+	SHA256     string `json:"sha256"`
+	ScanStatus string `json:"scan_status"`
+	SignedBy   string `json:"signed_by,omitempty"`
+	// DownloadURL is empty when the file can't be downloaded
+	DownloadURL string `json:"download_url,omitempty"`
+}
+
+var landingPage = template.Must(template.New("landing").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{.Name}}</title>
+<style>
+body { display: flex; min-height: 100vh; margin: 0; align-items: center; justify-content: center; font: 16px/1.5 system-ui, sans-serif; background: #f6f8fa; color: #1f2328; }
+main { background: #fff; border: 1px solid #d0d7de; border-radius: 8px; padding: 2rem; max-width: 32rem; width: 100%; }
+h1 { font-size: 1.25rem; margin: 0 0 1rem; word-break: break-all; }
+dl { display: grid; grid-template-columns: auto 1fr; gap: .25rem 1rem; margin: 0 0 1.5rem; }
+dt { color: #59636e; }
+dd { margin: 0; word-break: break-all; }
+a.button { display: inline-block; background: #1f883d; color: #fff; padding: .5rem 1.25rem; border-radius: 6px; text-decoration: none; }
+.warning { color: #cf222e; }
+</style>
+</head>
+<body>
+<main>
+<h1>{{.Name}}</h1>
+<dl>
+<dt>Size</dt><dd>{{.HumanSize}}</dd>
+<dt>Uploaded by</dt><dd>{{.Uploader}}</dd>
+<dt>SHA-256</dt><dd><code>{{.SHA256}}</code></dd>
+{{if .SignedBy}}<dt>Signed by</dt><dd>{{.SignedBy}}</dd>{{end}}
+<dt>Scan</dt><dd>{{if eq .ScanStatus "quarantined"}}<span class="warning">Quarantined</span>{{else if eq .ScanStatus "scanned"}}Checked on upload{{else}}Not scanned{{end}}</dd>
+</dl>
+{{if .DownloadURL}}<a class="button" href="{{.DownloadURL}}">Download</a>{{else}}<p class="warning">This file is not available for download.</p>{{end}}
+</main>
+</body>
+</html>
+`))
+
+// landingEnabled reports whether the download link should show a landing
+// page first. A per-file choice wins over the instance default.
+func (h *Handler) landingEnabled(record *db.FileRecord) bool {
+	if record.LandingPage != nil {
+		return *record.LandingPage
+	}
+	return db.GetSettings(h.Store).LandingPage
+}
+
+func scanStatus(record *db.FileRecord) string {
+	switch {
+	case record.Quarantined:
+		return scanQuarantined
+	case record.ScannedAt > 0:
+		return scanPassed
+	}
+	return scanNone
+}
+
+func (h *Handler) uploaderName(record *db.FileRecord) string {
+	if record.OwnerID == "" {
+		return "Admin"
+	}
+	if client, err := db.GetClient(h.Store, record.OwnerID); err == nil {
+		return client.Name
+	}
+	return "Unknown"
+}
+
+func (h *Handler) landingInfo(c *gin.Context, record *db.FileRecord) landingInfo {
+	info := landingInfo{
+		Name:       record.OriginalName,
+		Size:       record.Size,
+		Uploader:   h.uploaderName(record),
+		UploadTime: record.UploadTime,
+		ExpiresAt:  record.ExpiresAt,
+		SHA256:     record.SHA256,
+		ScanStatus: scanStatus(record),
+		SignedBy:   record.SignedBy,
+	}
+	if !record.Quarantined {
+		q := url.Values{"confirm": {"1"}}
+		// Keep a snippet password so the confirmed download still works
+		if password := c.Query("password"); password != "" {
+			q.Set("password", password)
+		}
+		link := record.DownloadLink
+		if link == "" {
+			link = record.ID
+		}
+		info.DownloadURL = fmt.Sprintf("%s/api/download/%s?%s", h.baseURL(c), link, q.Encode())
+	}
+	return info
+}
+
+// serveLanding answers a download link with the file's details instead of
+// its content, as HTML for browsers and JSON for everything else.
+func (h *Handler) serveLanding(c *gin.Context, record *db.FileRecord) {
+	info := h.landingInfo(c, record)
+	if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) != gin.MIMEHTML {
+		c.JSON(http.StatusOK, info)
+		return
+	}
+
+	c.Header("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+	c.Status(http.StatusOK)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	err := landingPage.Execute(c.Writer, struct {
+		landingInfo
+		HumanSize string
+	}{info, humanSize(info.Size)})
+	if err != nil {
+		c.Error(err)
+	}
+}
+
+// GetDownloadInfo returns the landing details of a download link as JSON,
+// whether or not the link is configured to show a landing page.
+func (h *Handler) GetDownloadInfo(c *gin.Context) {
+	record, err := h.findDownloadRecord(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	if !h.linkAccessible(c, record) {
+		return
+	}
+	c.JSON(http.StatusOK, h.landingInfo(c, record))
+}
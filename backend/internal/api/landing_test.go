@@ -0,0 +1,72 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestDownloadLandingPage(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+	db.UpsertClient(h.Store, "client-a", "Alice", "AAAA1111", 0)
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.PUT("/files/:id", h.UpdateFile)
+	router.GET("/download/:id", h.DownloadFile)
+
+	rec := uploadTestFile(t, router, "client-a", "release.zip", []byte("zip bytes"))
+	link := "/download/" + rec["download_link"].(string)
+
+	get := func(url, accept string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", url, nil)
+		req.Header.Set("Accept", accept)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Off by default
+	if w := get(link, "text/html"); w.Body.String() != "zip bytes" {
+		t.Fatalf("expected direct download, got %q", w.Body.String())
+	}
+
+	// Enabled globally
+	db.SaveSettings(h.Store, db.Settings{LandingPage: true})
+	w := get(link, "application/json")
+	var info landingInfo
+	json.Unmarshal(w.Body.Bytes(), &info)
+	if info.Name != "release.zip" || info.Uploader != "Alice" || info.ScanStatus != scanNone {
+		t.Errorf("unexpected landing info: %s", w.Body.String())
+	}
+	if !strings.HasSuffix(info.DownloadURL, link+"?confirm=1") {
+		t.Errorf("unexpected confirm URL %q", info.DownloadURL)
+	}
+	if w := get(link, "text/html"); !strings.Contains(w.Body.String(), "Uploaded by") {
+		t.Errorf("expected HTML landing page, got %q", w.Body.String())
+	}
+	if w := get(link+"?confirm=1", "text/html"); w.Body.String() != "zip bytes" {
+		t.Errorf("expected confirmed download, got %q", w.Body.String())
+	}
+
+	// Disabled for this file
+	w = httptest.NewRecorder()
+	body := `{"original_name": "release.zip", "owner_id": "client-a", "landing_page": false}`
+	req, _ := http.NewRequest("PUT", "/files/"+rec["id"].(string), bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Client-ID", "client-a")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("update failed: %s", w.Body.String())
+	}
+	if w := get(link, "text/html"); w.Body.String() != "zip bytes" {
+		t.Errorf("expected per-file override to skip the landing page, got %q", w.Body.String())
+	}
+}
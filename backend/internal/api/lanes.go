@@ -0,0 +1,230 @@
+package api
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Transfer lanes, in order of priority.
+const (
+	lanePriority = iota
+	laneBulk
+	laneCount
+)
+
+var laneNames = [laneCount]string{"priority", "bulk"}
+
+// defaultSmallTransferBytes is the size up to which transfers take the
+// priority lane when SmallBytes is unset.
+const defaultSmallTransferBytes = 1 << 20
+
+// TransferLanes bounds how many uploads and downloads run at once. While
+// all slots are taken, transfers queue in two lanes: admin transfers and
+// small files in the priority lane, everything else in the bulk lane. A
+// freed slot goes to the first transfer waiting in the priority lane, and
+// only when that is empty to the bulk lane. Tenants share the lanes of the
+// root depot, since they share its machine.
+type TransferLanes struct {
+	// Max is the number of transfers that run at once; zero or less lets
+	// them all run. Change it and SmallBytes with Resize once in use.
+	Max int
+	// SmallBytes is the size up to which a transfer takes the priority
+	// lane; zero means 1 MiB
+	SmallBytes int64
+
+	mu      sync.Mutex
+	active  int
+	waiting [laneCount][]chan struct{}
+	queued  [laneCount]int64
+}
+
+// TransferLaneStats is how busy the lanes are.
+type TransferLaneStats struct {
+	Max    int `json:"max"`
+	Active int `json:"active"`
+	// Waiting is the transfers queued now and Queued those that ever had
+	// to wait, per lane
+	Waiting map[string]int   `json:"waiting"`
+	Queued  map[string]int64 `json:"queued"`
+}
+
+func (l *TransferLanes) smallBytes() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.SmallBytes > 0 {
+		return l.SmallBytes
+	}
+	return defaultSmallTransferBytes
+}
+
+// limited reports whether transfers go through the lanes at all.
+func (l *TransferLanes) limited() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.Max > 0
+}
+
+// Resize changes the limits of lanes in use. Transfers waiting for the new
+// slots start at once; with fewer slots, the running ones finish and the
+// slots they free stay empty until the rest are within the limit.
+// Without a limit all waiting transfers start.
+func (l *TransferLanes) Resize(maxTransfers int, smallBytes int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.Max = maxTransfers
+	l.SmallBytes = smallBytes
+	for lane := range l.waiting {
+		for len(l.waiting[lane]) > 0 && (l.Max <= 0 || l.active < l.Max) {
+			close(l.waiting[lane][0])
+			l.waiting[lane] = l.waiting[lane][1:]
+			l.active++
+		}
+	}
+}
+
+// tryAcquire takes a slot if one is free.
+func (l *TransferLanes) tryAcquire() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.Max <= 0 || l.active < l.Max {
+		l.active++
+		return true
+	}
+	return false
+}
+
+// acquire takes a slot, waiting in the lane for one to be handed over. It
+// reports false when ctx ends first.
+func (l *TransferLanes) acquire(ctx context.Context, lane int) bool {
+	l.mu.Lock()
+	if l.Max <= 0 || l.active < l.Max {
+		l.active++
+		l.mu.Unlock()
+		return true
+	}
+	ready := make(chan struct{})
+	l.waiting[lane] = append(l.waiting[lane], ready)
+	l.queued[lane]++
+	l.mu.Unlock()
+
+	select {
+	case <-ready:
+		return true
+	case <-ctx.Done():
+	}
+	l.mu.Lock()
+	i := slices.Index(l.waiting[lane], ready)
+	if i >= 0 {
+		l.waiting[lane] = slices.Delete(l.waiting[lane], i, i+1)
+	}
+	l.mu.Unlock()
+	if i < 0 {
+		// The slot was handed over as ctx ended
+		l.release()
+	}
+	return false
+}
+
+// release hands the slot to the next waiting transfer, or frees it. A
+// slot over the limit after Resize is freed.
+func (l *TransferLanes) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.Max > 0 && l.active > l.Max {
+		l.active--
+		return
+	}
+	for lane := range l.waiting {
+		if len(l.waiting[lane]) > 0 {
+			next := l.waiting[lane][0]
+			l.waiting[lane] = l.waiting[lane][1:]
+			close(next)
+			return
+		}
+	}
+	l.active--
+}
+
+// Stats reports the slots in use and the transfers waiting.
+func (l *TransferLanes) Stats() TransferLaneStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s := TransferLaneStats{Max: l.Max, Active: l.active, Waiting: map[string]int{}, Queued: map[string]int64{}}
+	for lane, name := range laneNames {
+		s.Waiting[name] = len(l.waiting[lane])
+		s.Queued[name] = l.queued[lane]
+	}
+	return s
+}
+
+// isTransfer reports whether the request moves file content.
+func isTransfer(c *gin.Context) bool {
+	for _, key := range transferRoutes {
+		if routeMatches(key, c.Request.Method, c.FullPath()) {
+			return true
+		}
+	}
+	return false
+}
+
+// transferSize is how many bytes the request moves, or -1 if that can't
+// be told before handling it: the body of an upload, the range or the file
+// of a download.
+func (h *Handler) transferSize(c *gin.Context) int64 {
+	if c.Request.ContentLength > 0 {
+		return c.Request.ContentLength
+	}
+	if r := c.GetHeader("Range"); r != "" {
+		if n := rangeLength(r, math.MaxInt64); n < math.MaxInt64 {
+			return n
+		}
+	}
+	link := c.Param("id")
+	if link == "" {
+		link = c.Param("link")
+	}
+	if link != "" && isReadRequest(c) {
+		if record, err := h.findDownloadRecord(link); err == nil {
+			return record.Size
+		}
+	}
+	return -1
+}
+
+// transferLane picks the lane of a transfer that has to wait.
+func (h *Handler) transferLane(c *gin.Context) int {
+	if h.isAdmin(c) {
+		return lanePriority
+	}
+	if size := h.transferSize(c); size >= 0 && size <= h.Transfers.smallBytes() {
+		return lanePriority
+	}
+	return laneBulk
+}
+
+// TransferLanes runs uploads and downloads through the transfer lanes
+// when Transfers is set; other requests pass straight through. Transfers
+// are only classified when they have to wait, so a depot with free slots
+// pays nothing for the lanes. A transfer whose deadline passes while it
+// waits gets 503.
+func (h *Handler) TransferLanes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lanes := h.Transfers
+		if lanes == nil || !lanes.limited() || !isTransfer(c) {
+			c.Next()
+			return
+		}
+		if !lanes.tryAcquire() && !lanes.acquire(c.Request.Context(), h.transferLane(c)) {
+			c.Header("Retry-After", "5")
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Too many transfers in progress, try again later"})
+			return
+		}
+		defer lanes.release()
+		c.Next()
+	}
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTransferLanesOrder(t *testing.T) {
+	lanes := &TransferLanes{Max: 1}
+	if !lanes.tryAcquire() || lanes.tryAcquire() {
+		t.Fatal("expected exactly one slot")
+	}
+
+	order := make(chan string, 3)
+	wait := func(name string, lane int) {
+		waiting := lanes.Stats().Waiting[laneNames[lane]]
+		go func() {
+			if lanes.acquire(context.Background(), lane) {
+				order <- name
+				lanes.release()
+			}
+		}()
+		// Queue in a known order
+		for deadline := time.Now().Add(time.Second); lanes.Stats().Waiting[laneNames[lane]] == waiting; time.Sleep(time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatalf("%s never queued", name)
+			}
+		}
+	}
+	wait("bulk-1", laneBulk)
+	wait("bulk-2", laneBulk)
+	wait("priority", lanePriority)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if lanes.acquire(ctx, laneBulk) {
+		t.Error("acquired a slot after the context ended")
+	}
+
+	lanes.release()
+	for _, want := range []string{"priority", "bulk-1", "bulk-2"} {
+		select {
+		case got := <-order:
+			if got != want {
+				t.Errorf("got %s, want %s", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%s never got a slot", want)
+		}
+	}
+	if s := lanes.Stats(); s.Active != 0 || s.Queued["bulk"] != 3 || s.Queued["priority"] != 1 {
+		t.Errorf("stats after the queue drained: %+v", s)
+	}
+}
+
+func TestTransferLanesResize(t *testing.T) {
+	lanes := &TransferLanes{Max: 1}
+	lanes.tryAcquire()
+	started := make(chan struct{})
+	go func() {
+		if lanes.acquire(context.Background(), laneBulk) {
+			close(started)
+		}
+	}()
+	for deadline := time.Now().Add(time.Second); lanes.Stats().Waiting["bulk"] == 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("the transfer never queued")
+		}
+	}
+
+	// More slots start the waiting transfers
+	lanes.Resize(2, 0)
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("the waiting transfer didn't start after the resize")
+	}
+
+	// Fewer slots let the running transfers finish
+	lanes.Resize(1, 0)
+	lanes.release()
+	if lanes.tryAcquire() {
+		t.Error("expected no slot while a transfer still runs at the new limit")
+	}
+	lanes.release()
+	if !lanes.tryAcquire() {
+		t.Error("expected the slot back once within the limit")
+	}
+
+	// No limit lets everything run
+	lanes.Resize(0, 0)
+	if !lanes.tryAcquire() || !lanes.tryAcquire() {
+		t.Error("expected transfers to run without a limit")
+	}
+}
+
+func TestTransferLanesMiddleware(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+	h.Transfers = &TransferLanes{Max: 1}
+	h.TransferTimeout = 50 * time.Millisecond
+
+	started, finish := make(chan struct{}), make(chan struct{})
+	router := gin.New()
+	router.Use(h.Deadline(), h.TransferLanes())
+	router.POST("/api/upload", func(c *gin.Context) {
+		started <- struct{}{}
+		<-finish
+		c.Status(http.StatusOK)
+	})
+	router.GET("/api/files", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	go func() {
+		req, _ := http.NewRequest("POST", "/api/upload", nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+	<-started
+
+	// Other requests don't take a slot
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/files", nil)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("listing was held up: %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/api/upload", nil)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Errorf("waiting upload past its deadline: got %d, want 503 with Retry-After", w.Code)
+	}
+	close(finish)
+}
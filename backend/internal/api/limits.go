@@ -0,0 +1,83 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+// chargeDownload counts the request against the link's download and byte
+// caps, and responds with 410 once they are used up. Owners and admins are
+// not counted. A request counts as a download when it reaches the end of
+// the file, however it starts; ranges that stop short only count their
+// bytes.
+func (h *Handler) chargeDownload(c *gin.Context, record *db.FileRecord) bool {
+	if record.MaxDownloads == 0 && record.MaxBytes == 0 {
+		return true
+	}
+	if record.OwnerID == c.GetHeader("X-Client-ID") || h.isAdmin(c) {
+		return true
+	}
+	return h.charge(c, record)
+}
+
+// charge counts the request against the link's caps for everyone.
+func (h *Handler) charge(c *gin.Context, record *db.FileRecord) bool {
+	if record.MaxDownloads == 0 && record.MaxBytes == 0 {
+		return true
+	}
+	start, end := byteRange(c.GetHeader("Range"), record.Size)
+	ok, err := db.ChargeDownload(h.Store, record.ID, end-start+1, end >= record.Size-1)
+	if err != nil {
+		log.Printf("[ERROR] Failed to count download of %s: %v", record.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count download"})
+		return false
+	}
+	if !ok {
+		c.JSON(http.StatusGone, gin.H{"error": "Download limit reached"})
+		return false
+	}
+	return true
+}
+
+// rangeLength returns how many bytes a Range header asks for.
+func rangeLength(header string, size int64) int64 {
+	start, end := byteRange(header, size)
+	return end - start + 1
+}
+
+// byteRange returns the first and last byte a Range header asks for.
+// Anything it can't parse, including multiple ranges, is the whole file.
+func byteRange(header string, size int64) (int64, int64) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return 0, size - 1
+	}
+	startStr, endStr, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return 0, size - 1
+	}
+	if startStr == "" {
+		n, err := strconv.ParseInt(endStr, 10, 64)
+		if err != nil || n < 0 {
+			return 0, size - 1
+		}
+		return size - min(n, size), size - 1
+	}
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return 0, size - 1
+	}
+	end := size - 1
+	if endStr != "" {
+		if end, err = strconv.ParseInt(endStr, 10, 64); err != nil || end < start {
+			return 0, size - 1
+		}
+		end = min(end, size-1)
+	}
+	return start, end
+}
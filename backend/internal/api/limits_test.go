@@ -0,0 +1,169 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestDownloadLimits(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.PUT("/files/:id", h.UpdateFile)
+	router.GET("/download/:id", h.DownloadFile)
+
+	uploaded := uploadTestFile(t, router, "client-a", "build.log", []byte("0123456789"))
+	id := uploaded["id"].(string)
+	record, _ := db.GetFileRecord(h.Store, id)
+
+	update := func(body string) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/files/"+id, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Client-ID", "client-a")
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("update failed: %s", w.Body.String())
+		}
+	}
+	download := func(clientID, rangeHeader string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/download/"+record.DownloadLink, nil)
+		if clientID != "" {
+			req.Header.Set("X-Client-ID", clientID)
+		}
+		if rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	update(`{"original_name":"build.log","owner_id":"client-a","max_downloads":2}`)
+	if code := download("", ""); code != http.StatusOK {
+		t.Fatalf("expected first download to succeed, got %d", code)
+	}
+	// A range that stops short of the end is only part of a download
+	if code := download("", "bytes=0-4"); code != http.StatusPartialContent {
+		t.Fatalf("expected range request to succeed, got %d", code)
+	}
+	// One that reaches it is a download, wherever it starts
+	if code := download("", "bytes=1-"); code != http.StatusPartialContent {
+		t.Fatalf("expected second download to succeed, got %d", code)
+	}
+	if code := download("", ""); code != http.StatusGone {
+		t.Errorf("expected 410 once the cap is reached, got %d", code)
+	}
+	if code := download("", "bytes=-10"); code != http.StatusGone {
+		t.Errorf("expected suffix ranges to be held to the cap, got %d", code)
+	}
+	if code := download("client-a", ""); code != http.StatusOK {
+		t.Errorf("expected the owner to bypass the cap, got %d", code)
+	}
+
+	record, _ = db.GetFileRecord(h.Store, id)
+	if record.Downloads != 2 || record.BytesServed != 24 {
+		t.Errorf("expected 2 downloads and 24 bytes, got %d and %d", record.Downloads, record.BytesServed)
+	}
+
+	// A byte cap refuses transfers that would go over it
+	update(`{"original_name":"build.log","owner_id":"client-a","max_downloads":0,"max_bytes":15,"reset_counters":true}`)
+	if code := download("", ""); code != http.StatusOK {
+		t.Fatalf("expected download after reset to succeed, got %d", code)
+	}
+	if code := download("", ""); code != http.StatusGone {
+		t.Errorf("expected 410 when the byte cap would be exceeded, got %d", code)
+	}
+	if code := download("", "bytes=0-4"); code != http.StatusPartialContent {
+		t.Errorf("expected a range within the byte cap to succeed, got %d", code)
+	}
+
+	var meta map[string]interface{}
+	w := httptest.NewRecorder()
+	router.GET("/files/:id", h.GetFileMetadata)
+	req, _ := http.NewRequest("GET", "/files/"+id, nil)
+	router.ServeHTTP(w, req)
+	json.Unmarshal(w.Body.Bytes(), &meta)
+	if meta["bytes_served"] != float64(15) || meta["max_bytes"] != float64(15) {
+		t.Errorf("expected counters in metadata, got %v", meta)
+	}
+}
+
+func TestByteRange(t *testing.T) {
+	cases := map[string][2]int64{
+		"":             {0, 99},
+		"bytes=0-":     {0, 99},
+		"bytes=10-19":  {10, 19},
+		"bytes=90-200": {90, 99},
+		"bytes=-30":    {70, 99},
+		"bytes=-300":   {0, 99},
+		"bytes=0-1,5-": {0, 99},
+		"bytes=x-":     {0, 99},
+	}
+	for header, want := range cases {
+		if start, end := byteRange(header, 100); start != want[0] || end != want[1] {
+			t.Errorf("byteRange(%q) = %d-%d, want %d-%d", header, start, end, want[0], want[1])
+		}
+	}
+}
+
+func TestHeadDownload(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.PUT("/files/:id", h.UpdateFile)
+	router.GET("/download/:id", h.DownloadFile)
+	router.HEAD("/download/:id", h.HeadDownload)
+
+	uploaded := uploadTestFile(t, router, "client-a", "Bericht März.pdf", []byte("%PDF-1.7 content"))
+	id := uploaded["id"].(string)
+	record, _ := db.GetFileRecord(h.Store, id)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/files/"+id, bytes.NewBufferString(`{"original_name":"Bericht März.pdf","owner_id":"client-a","max_downloads":1}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Client-ID", "client-a")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("update failed: %s", w.Body.String())
+	}
+
+	// Previews don't use up the link
+	for i := 0; i < 2; i++ {
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("HEAD", "/download/"+record.DownloadLink, nil)
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK || w.Body.Len() != 0 {
+			t.Fatalf("expected headers only, got %d with %d bytes", w.Code, w.Body.Len())
+		}
+	}
+	headers := map[string]string{
+		"Content-Length":      "16",
+		"Content-Type":        "application/pdf",
+		"Content-Disposition": contentDisposition("attachment", "Bericht März.pdf"),
+		"ETag":                `"` + record.SHA256 + `"`,
+		"X-Checksum-Sha256":   record.SHA256,
+	}
+	for name, want := range headers {
+		if got := w.Header().Get(name); got != want {
+			t.Errorf("%s: got %q, want %q", name, got, want)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/download/"+record.DownloadLink, nil)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != "%PDF-1.7 content" {
+		t.Fatalf("expected the download to still work, got %d", w.Code)
+	}
+}
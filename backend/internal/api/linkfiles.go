@@ -0,0 +1,96 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const maxTargetURLLength = 2048
+
+// validateTargetURL accepts absolute http and https URLs, the only ones a
+// browser can safely be redirected to.
+func validateTargetURL(raw string) error {
+	if len(raw) > maxTargetURLLength {
+		return errors.New("url is too long")
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	return nil
+}
+
+// linkFileName names a link file after the last segment of its URL, or its
+// host when the path is empty.
+func linkFileName(target string) string {
+	u, err := url.Parse(target)
+	if err != nil {
+		return "link"
+	}
+	if name := path.Base(u.Path); name != "/" && name != "." {
+		return name
+	}
+	return u.Host
+}
+
+// CreateLinkFile catalogs an external artifact as a link file. It is listed,
+// tagged and shared like an uploaded file, and its download link redirects
+// to the URL.
+func (h *Handler) CreateLinkFile(c *gin.Context) {
+	ownerID := c.GetHeader("X-Client-ID")
+	if ownerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
+		return
+	}
+	if _, err := db.GetClient(h.Store, ownerID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+		return
+	}
+
+	var input struct {
+		URL      string   `json:"url" binding:"required"`
+		Name     string   `json:"name"`
+		Folder   string   `json:"folder"`
+		Tags     []string `json:"tags"`
+		IsPublic bool     `json:"is_public"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	input.URL = strings.TrimSpace(input.URL)
+	if err := validateTargetURL(input.URL); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		name = linkFileName(input.URL)
+	}
+	name = h.cleanName(name)
+
+	record := db.FileRecord{
+		ID:           uuid.New().String(),
+		OriginalName: name,
+		UploadTime:   h.now().Unix(),
+		OwnerID:      ownerID,
+		DownloadLink: uuid.New().String(),
+		IsPublic:     input.IsPublic,
+		Folder:       db.NormalizeFolder(input.Folder),
+		Tags:         db.ParseTags(strings.Join(input.Tags, ",")),
+		External:     true,
+		TargetURL:    input.URL,
+	}
+	if err := db.SaveFileRecord(h.Store, record); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save link file"})
+		return
+	}
+	c.JSON(http.StatusCreated, record)
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestLinkFiles(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+	db.UpsertClient(h.Store, "client-a", "Client A", "CODEA", 0)
+
+	router := gin.Default()
+	router.POST("/files/link", h.CreateLinkFile)
+	router.PUT("/files/:id", h.UpdateFile)
+	router.DELETE("/files/:id", h.DeleteFile)
+	router.GET("/download/:id", h.DownloadFile)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Client-ID", "client-a")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("POST", "/files/link", `{"url": "javascript:alert(1)"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected a non-http URL to be rejected, got %d", w.Code)
+	}
+
+	w := do("POST", "/files/link", `{"url": "https://releases.example.com/tool/v1.2/tool.tar.gz", "folder": "vendor", "tags": ["tools"]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Create failed: %s", w.Body.String())
+	}
+	var record db.FileRecord
+	json.Unmarshal(w.Body.Bytes(), &record)
+	if record.OriginalName != "tool.tar.gz" || record.Folder != "vendor" || !record.HasTag("tools") || !record.IsLink() {
+		t.Fatalf("expected a tagged link file named after the URL, got %+v", record)
+	}
+	if files, _ := db.GetFileRecordsByOwner(h.Store, "client-a"); len(files) != 1 {
+		t.Errorf("expected the link file to be listed with the client's files, got %d", len(files))
+	}
+
+	w = do("GET", "/download/"+record.DownloadLink, "")
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "https://releases.example.com/tool/v1.2/tool.tar.gz" {
+		t.Fatalf("expected the download link to redirect to the target, got %d %s", w.Code, w.Header().Get("Location"))
+	}
+
+	w = do("PUT", "/files/"+record.ID, `{"original_name": "tool.tar.gz", "owner_id": "client-a", "target_url": "https://releases.example.com/tool/v1.3/tool.tar.gz"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Update failed: %s", w.Body.String())
+	}
+	w = do("GET", "/download/"+record.DownloadLink, "")
+	if w.Header().Get("Location") != "https://releases.example.com/tool/v1.3/tool.tar.gz" {
+		t.Errorf("expected the link to follow the new target, got %s", w.Header().Get("Location"))
+	}
+
+	if w := do("DELETE", "/files/"+record.ID, ""); w.Code != http.StatusOK {
+		t.Errorf("Delete failed: %s", w.Body.String())
+	}
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"regexp"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/mail"
+	"github.com/celerix/depot/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var emailAlias = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,63}$`)
+
+func (h *Handler) UpdateEmailAlias(c *gin.Context) {
+	ownerID := c.GetHeader("X-Client-ID")
+	if ownerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
+		return
+	}
+	if _, err := db.GetClient(h.Store, ownerID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+		return
+	}
+
+	var input struct {
+		Alias string `json:"alias"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Alias != "" && !emailAlias.MatchString(input.Alias) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Alias must be lowercase letters, digits, dots, dashes or underscores"})
+		return
+	}
+	if other, err := db.GetClientByEmailAlias(h.Store, input.Alias); err == nil && other.ID != ownerID {
+		c.JSON(http.StatusConflict, gin.H{"error": "Alias is already taken"})
+		return
+	}
+
+	if err := db.SetClientEmailAlias(h.Store, ownerID, input.Alias); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update alias"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success", "alias": input.Alias})
+}
+
+// AcceptsMail reports whether a recipient address belongs to a client.
+func (h *Handler) AcceptsMail(rcpt string) bool {
+	_, err := db.GetClientByEmailAlias(h.Store, mail.LocalPart(rcpt))
+	return err == nil
+}
+
+// ReceiveMail stores the attachments of a message for every recipient.
+// Attachments refused by the upload pipeline are logged and skipped, since
+// failing the delivery would only make the sending server retry.
+func (h *Handler) ReceiveMail(msg *mail.Message) error {
+	for _, rcpt := range msg.To {
+		client, err := db.GetClientByEmailAlias(h.Store, mail.LocalPart(rcpt))
+		if err != nil {
+			continue
+		}
+		for _, a := range msg.Attachments {
+			id := uuid.New().String()
+			storedPath, size, hash, err := storage.StoreFile(bytes.NewReader(a.Data), h.TempDir, h.StorageDir, id)
+			if err != nil {
+				return err
+			}
+			_, err = h.ingest(context.Background(), stagedFile{ID: id, Path: storedPath, Size: size, SHA256: hash}, ingestOptions{
+				OwnerID:       client.ID,
+				Name:          a.Name,
+				IsAdmin:       client.IsAdmin,
+				StripMetadata: h.StripMetadata,
+				Metadata: map[string]string{
+					"email_from":    msg.From,
+					"email_subject": msg.Subject,
+				},
+			})
+			if err != nil {
+				log.Printf("[WARN] Dropped attachment %s from %s: %v", a.Name, msg.From, err)
+			}
+		}
+	}
+	return nil
+}
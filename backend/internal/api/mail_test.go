@@ -0,0 +1,69 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/mail"
+	"github.com/gin-gonic/gin"
+)
+
+func TestReceiveMailStoresAttachments(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	router := gin.Default()
+	router.PUT("/persona/email-alias", h.UpdateEmailAlias)
+
+	db.UpsertClient(h.Store, "client-a", "Alice", "AAAA1111", 0)
+	db.UpsertClient(h.Store, "client-b", "Bob", "BBBB2222", 0)
+
+	setAlias := func(clientID, body string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/persona/email-alias", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Client-ID", clientID)
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := setAlias("client-a", `{"alias": "Not Valid"}`); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid alias, got %d", code)
+	}
+	if code := setAlias("client-a", `{"alias": "alice"}`); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if code := setAlias("client-b", `{"alias": "alice"}`); code != http.StatusConflict {
+		t.Errorf("expected 409 for taken alias, got %d", code)
+	}
+
+	if !h.AcceptsMail("Alice@depot.example") {
+		t.Error("expected alias to accept mail")
+	}
+	if h.AcceptsMail("nobody@depot.example") {
+		t.Error("expected unknown alias to be refused")
+	}
+
+	err := h.ReceiveMail(&mail.Message{
+		From:    "sender@example.com",
+		To:      []string{"alice@depot.example"},
+		Subject: "Scans",
+		Attachments: []mail.Attachment{
+			{Name: "scan.pdf", ContentType: "application/pdf", Data: []byte("%PDF-1.4")},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ReceiveMail failed: %v", err)
+	}
+
+	list, err := db.ListFiles(h.Store, db.ListFilesOptions{OwnerID: "client-a"})
+	if err != nil || len(list.Files) != 1 {
+		t.Fatalf("expected 1 file for client-a, got %v (%v)", list, err)
+	}
+	if list.Files[0].OriginalName != "scan.pdf" || list.Files[0].Metadata["email_from"] != "sender@example.com" {
+		t.Errorf("unexpected record: %+v", list.Files[0])
+	}
+}
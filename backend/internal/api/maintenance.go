@@ -0,0 +1,194 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminCompact removes records left behind by deleted files and reports the
+// space that freed in the store.
+func (h *Handler) AdminCompact(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+	report, err := db.Compact(h.Store)
+	if err != nil {
+		log.Printf("[ERROR] Compaction failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compact the store"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// maintenanceCacheTTL bounds how long a server keeps enforcing a mode that
+// another server sharing the store has changed.
+const maintenanceCacheTTL = 5 * time.Second
+
+const (
+	defaultDrain = 30 * time.Second
+	maxDrain     = 5 * time.Minute
+)
+
+// maintenanceExempt are the endpoints that work in every mode, so the
+// frontend can show the banner and admins can sign in to lift the mode.
+var maintenanceExemptRoutes = []string{"/api/version", "/api/settings", "/api/maintenance", "/api/persona", "/api/persona/admin", "/api/persona/recover"}
+
+var maintenanceMessages = map[string]string{
+	db.MaintenanceReadOnly: "The depot is read-only for maintenance",
+	db.MaintenanceClosed:   "The depot is closed for maintenance",
+}
+
+type maintenanceGate struct {
+	mu       sync.Mutex
+	current  db.Maintenance
+	loadedAt time.Time
+	// requests and writes count the requests past the gate, for draining
+	requests atomic.Int64
+	writes   atomic.Int64
+}
+
+func (h *Handler) maintenanceMode() db.Maintenance {
+	g := &h.maintenance
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.loadedAt.IsZero() || time.Since(g.loadedAt) > maintenanceCacheTTL {
+		g.current = db.GetMaintenance(h.Store)
+		g.loadedAt = time.Now()
+	}
+	return g.current
+}
+
+func isReadRequest(c *gin.Context) bool {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// MaintenanceGate turns requests away while the depot is in maintenance:
+// writes in read-only mode, everything in closed mode. Admins pass, so they
+// can check on things and lift the mode.
+func (h *Handler) MaintenanceGate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		read := isReadRequest(c)
+		m := h.maintenanceMode()
+		if m.Mode != db.MaintenanceOff && (m.Mode == db.MaintenanceClosed || !read) && !maintenanceExempt(c) && !h.isAdmin(c) {
+			msg := m.Message
+			if msg == "" {
+				msg = maintenanceMessages[m.Mode]
+			}
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": msg, "maintenance": m.Mode})
+			return
+		}
+
+		h.maintenance.requests.Add(1)
+		defer h.maintenance.requests.Add(-1)
+		if !read {
+			h.maintenance.writes.Add(1)
+			defer h.maintenance.writes.Add(-1)
+		}
+		c.Next()
+	}
+}
+
+// maintenanceExempt matches by route rather than path, so the depot can be
+// mounted under a prefix.
+func maintenanceExempt(c *gin.Context) bool {
+	route := c.FullPath()
+	for _, suffix := range maintenanceExemptRoutes {
+		if strings.HasSuffix(route, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetMaintenance tells the frontend whether to show a maintenance banner.
+func (h *Handler) GetMaintenance(c *gin.Context) {
+	m := h.maintenanceMode()
+	c.JSON(http.StatusOK, gin.H{"mode": m.Mode, "message": m.Message, "since": m.Since})
+}
+
+func (h *Handler) AdminGetMaintenance(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"maintenance": db.GetMaintenance(h.Store),
+		"in_flight":   h.maintenance.requests.Load(),
+		"writes":      h.maintenance.writes.Load(),
+	})
+}
+
+// AdminSetMaintenance switches the maintenance mode (off, read_only or
+// closed) and then waits up to drain_seconds (default 30) for the requests
+// the new mode blocks to finish. The response says whether they did.
+func (h *Handler) AdminSetMaintenance(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	var input struct {
+		Mode         string `json:"mode" binding:"required"`
+		Message      string `json:"message"`
+		DrainSeconds *int   `json:"drain_seconds"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, known := maintenanceMessages[input.Mode]; !known && input.Mode != db.MaintenanceOff {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be off, read_only or closed"})
+		return
+	}
+	drain := defaultDrain
+	if input.DrainSeconds != nil {
+		drain = min(time.Duration(max(*input.DrainSeconds, 0))*time.Second, maxDrain)
+	}
+
+	m := db.Maintenance{Mode: db.MaintenanceOff}
+	if input.Mode != db.MaintenanceOff {
+		m = db.Maintenance{
+			Mode:    input.Mode,
+			Message: input.Message,
+			Since:   h.now().Unix(),
+			By:      c.GetHeader("X-Client-ID"),
+		}
+	}
+	if err := db.SaveMaintenance(h.Store, m); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save maintenance mode"})
+		return
+	}
+	h.maintenance.mu.Lock()
+	h.maintenance.current, h.maintenance.loadedAt = m, time.Now()
+	h.maintenance.mu.Unlock()
+
+	// This request went through the gate too and is a write
+	pending := func() int64 {
+		switch m.Mode {
+		case db.MaintenanceClosed:
+			return max(h.maintenance.requests.Load()-1, 0)
+		case db.MaintenanceReadOnly:
+			return max(h.maintenance.writes.Load()-1, 0)
+		}
+		return 0
+	}
+	deadline := time.Now().Add(drain)
+	for pending() > 0 && time.Now().Before(deadline) && c.Request.Context().Err() == nil {
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	left := pending()
+	c.JSON(http.StatusOK, gin.H{"maintenance": m, "in_flight": left, "drained": left == 0})
+}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestAdminCompact(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.POST("/admin/maintenance/compact", h.AdminCompact)
+
+	db.UpsertClient(h.Store, "admin", "Admin", "CODEA", 0)
+	db.UpdateClientAdminStatus(h.Store, "admin", true)
+
+	kept := uploadTestFile(t, router, "admin", "kept.txt", []byte("kept"))["id"].(string)
+	db.SaveFileText(h.Store, kept, "kept text")
+	db.RecordDownload(h.Store, db.DownloadEvent{FileID: kept, Time: time.Now()})
+
+	// Leftovers of a file that is gone, as a late content extraction or
+	// download would leave them
+	db.SaveFileText(h.Store, "gone", "some long extracted text")
+	db.RecordDownload(h.Store, db.DownloadEvent{FileID: "gone", Time: time.Now()})
+
+	compact := func(clientID string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/admin/maintenance/compact", nil)
+		req.Header.Set("X-Client-ID", clientID)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := compact("someone"); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a client, got %d", w.Code)
+	}
+	w := compact("admin")
+	if w.Code != http.StatusOK {
+		t.Fatalf("compaction failed: %s", w.Body.String())
+	}
+	var report db.CompactReport
+	json.Unmarshal(w.Body.Bytes(), &report)
+	if report.KeysRemoved != 2 || report.Removed["text"] != 1 || report.Removed["analytics"] != 1 {
+		t.Errorf("expected the two leftovers to be removed, got %+v", report)
+	}
+	if report.Reclaimed <= 0 || report.BytesAfter >= report.BytesBefore {
+		t.Errorf("expected space to be reclaimed, got %+v", report)
+	}
+	if _, err := h.Store.Get(db.SystemPersona, db.AppID, db.ContentKeyPrefix+kept); err != nil {
+		t.Errorf("expected the existing file's text to be kept: %v", err)
+	}
+
+	json.Unmarshal(compact("admin").Body.Bytes(), &report)
+	if report.KeysRemoved != 0 {
+		t.Errorf("expected nothing left to remove, got %+v", report)
+	}
+}
+
+func TestMaintenanceMode(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	router := gin.New()
+	api := router.Group("/api")
+	api.Use(h.MaintenanceGate())
+	api.GET("/maintenance", h.GetMaintenance)
+	api.GET("/files", h.ListFiles)
+	api.POST("/upload", h.UploadFile)
+	api.POST("/admin/maintenance", h.AdminSetMaintenance)
+	api.PUT("/slow", func(c *gin.Context) {
+		close(started)
+		<-release
+		c.Status(http.StatusNoContent)
+	})
+
+	db.UpsertClient(h.Store, "admin", "Admin", "CODEA", 0)
+	db.UpdateClientAdminStatus(h.Store, "admin", true)
+
+	do := func(method, path, clientID, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Client-ID", clientID)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// A write in flight holds up the switch until it finishes
+	done := make(chan struct{})
+	go func() {
+		do("PUT", "/api/slow", "client-a", "")
+		close(done)
+	}()
+	<-started
+	time.AfterFunc(200*time.Millisecond, func() { close(release) })
+	w := do("POST", "/api/admin/maintenance", "admin", `{"mode":"read_only","message":"Moving disks"}`)
+	var resp struct {
+		Drained bool `json:"drained"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || !resp.Drained {
+		t.Fatalf("expected the switch to drain, got %d %s", w.Code, w.Body.String())
+	}
+	<-done
+
+	if w := do("GET", "/api/files", "client-a", ""); w.Code != http.StatusOK {
+		t.Errorf("expected reads to work in read-only mode, got %d", w.Code)
+	}
+	w = do("POST", "/api/upload", "client-a", "")
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "Moving disks") {
+		t.Errorf("expected writes to be refused with the message, got %d %s", w.Code, w.Body.String())
+	}
+	if w := do("GET", "/api/maintenance", "", ""); !strings.Contains(w.Body.String(), `"read_only"`) {
+		t.Errorf("expected the mode to be public, got %s", w.Body.String())
+	}
+
+	// The mode is kept in the store, so it survives a restart
+	if m := db.GetMaintenance(h.Store); m.Mode != db.MaintenanceReadOnly || m.By != "admin" {
+		t.Errorf("expected the mode to be persisted, got %+v", m)
+	}
+
+	do("POST", "/api/admin/maintenance", "admin", `{"mode":"closed","drain_seconds":0}`)
+	if w := do("GET", "/api/files", "client-a", ""); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected reads to be refused when closed, got %d", w.Code)
+	}
+	if w := do("GET", "/api/files", "admin", ""); w.Code != http.StatusOK {
+		t.Errorf("expected admins to get through, got %d", w.Code)
+	}
+	if w := do("GET", "/api/maintenance", "", ""); w.Code != http.StatusOK {
+		t.Errorf("expected the status to stay reachable, got %d", w.Code)
+	}
+
+	if w := do("POST", "/api/admin/maintenance", "admin", `{"mode":"sideways"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown mode, got %d", w.Code)
+	}
+	do("POST", "/api/admin/maintenance", "admin", `{"mode":"off"}`)
+	if w := do("GET", "/api/files", "client-a", ""); w.Code != http.StatusOK {
+		t.Errorf("expected the depot to reopen, got %d", w.Code)
+	}
+}
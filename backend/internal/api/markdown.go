@@ -0,0 +1,96 @@
+package api
+
+import (
+	"html/template"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/markdown"
+	"github.com/gin-gonic/gin"
+)
+
+// maxRenderSize caps the Markdown files rendered to HTML; larger ones are
+// downloaded as usual.
+const maxRenderSize = 2 << 20
+
+// markdownPolicy allows the page's own styles and remote images, and nothing
+// that could run script.
+const markdownPolicy = "default-src 'none'; style-src 'unsafe-inline'; img-src https: data:; sandbox allow-popups allow-popups-to-escape-sandbox"
+
+var markdownPage = template.Must(template.New("markdown").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { max-width: 50rem; margin: 0 auto; padding: 1rem 1.5rem 3rem; font: 16px/1.6 system-ui, sans-serif; color: #1f2328; }
+nav { display: flex; justify-content: space-between; gap: 1rem; padding: .5rem 0; border-bottom: 1px solid #d0d7de; margin-bottom: 1.5rem; font-size: .9rem; }
+nav a { color: #0969da; text-decoration: none; margin-left: 1rem; }
+pre { background: #f6f8fa; padding: 1rem; overflow: auto; border-radius: 6px; }
+code { background: #f6f8fa; padding: .1em .3em; border-radius: 4px; font-size: .9em; }
+pre code { padding: 0; }
+blockquote { margin: 0; padding: 0 1em; color: #59636e; border-left: .25em solid #d0d7de; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #d0d7de; padding: .3rem .8rem; }
+img { max-width: 100%; }
+@media (prefers-color-scheme: dark) {
+  body { background: #0d1117; color: #e6edf3; }
+  pre, code { background: #161b22; }
+  nav a { color: #4493f8; }
+}
+</style>
+</head>
+<body>
+<nav><strong>{{.Title}}</strong><span><a href="?view=raw">Raw</a><a href="?">Download</a></span></nav>
+<main>{{.Body}}</main>
+</body>
+</html>
+`))
+
+func isMarkdown(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".md", ".markdown":
+		return true
+	}
+	return false
+}
+
+// serveMarkdownView serves a Markdown file rendered to HTML (view=html) or as
+// plain text (view=raw). It reports false when the file should be downloaded
+// normally instead.
+func (h *Handler) serveMarkdownView(c *gin.Context, record *db.FileRecord) bool {
+	view := c.Query("view")
+	if (view != "html" && view != "raw") || !isMarkdown(record.OriginalName) || record.Size > maxRenderSize {
+		return false
+	}
+	content, err := os.ReadFile(record.StoredPath)
+	if err != nil {
+		return false
+	}
+
+	c.Header("X-Content-Type-Options", "nosniff")
+	if view == "raw" {
+		c.Header("Content-Disposition", "inline")
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", content)
+		return true
+	}
+
+	c.Header("Content-Security-Policy", markdownPolicy)
+	c.Status(http.StatusOK)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	err = markdownPage.Execute(c.Writer, struct {
+		Title string
+		Body  template.HTML
+	}{
+		Title: record.OriginalName,
+		Body:  template.HTML(markdown.Render(content)),
+	})
+	if err != nil {
+		c.Error(err)
+	}
+	return true
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDownloadRendersMarkdown(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.GET("/download/:id", h.DownloadFile)
+
+	rec := uploadTestFile(t, router, "client-a", "design.md", []byte("# Plan\n\n<script>alert(1)</script>"))
+	link := "/download/" + rec["download_link"].(string)
+
+	get := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", url, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get(link + "?view=html")
+	body := w.Body.String()
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") || !strings.Contains(body, "<h1>Plan</h1>") {
+		t.Fatalf("expected rendered HTML, got %q: %s", w.Header().Get("Content-Type"), body)
+	}
+	if strings.Contains(body, "<script>") {
+		t.Errorf("expected raw HTML to be escaped")
+	}
+	if w.Header().Get("Content-Security-Policy") == "" {
+		t.Errorf("expected a content security policy")
+	}
+
+	w = get(link + "?view=raw")
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") || !strings.HasPrefix(w.Body.String(), "# Plan") {
+		t.Errorf("expected raw markdown, got %q", w.Body.String())
+	}
+
+	w = get(link)
+	if !strings.Contains(w.Header().Get("Content-Disposition"), "attachment") {
+		t.Errorf("expected plain download without view, got %q", w.Header().Get("Content-Disposition"))
+	}
+}
@@ -0,0 +1,267 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/remote"
+	"github.com/celerix/depot/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// mirrorMaxAttempts is how often the retry job tries a failed file before
+// leaving it to an admin.
+const mirrorMaxAttempts = 5
+
+// mirrorStalePending is how long a push may stay pending before the retry
+// job assumes it was lost, e.g. to a restart.
+const mirrorStalePending = time.Hour
+
+type mirrorInput struct {
+	Enabled bool   `json:"enabled"`
+	URL     string `json:"url"`
+	// APIKey keeps the stored key when left out
+	APIKey  *string  `json:"api_key"`
+	Folders []string `json:"folders"`
+	Tags    []string `json:"tags"`
+}
+
+// mirrorResponse never includes the API key, only whether one is set.
+type mirrorResponse struct {
+	db.MirrorTarget
+	APIKey    string `json:"api_key,omitempty"`
+	APIKeySet bool   `json:"api_key_set"`
+}
+
+func (h *Handler) mirrorResponse() mirrorResponse {
+	m, err := db.GetMirrorTarget(h.Store)
+	if err != nil {
+		m = &db.MirrorTarget{}
+	}
+	return mirrorResponse{MirrorTarget: *m, APIKeySet: m.APIKey != ""}
+}
+
+func (h *Handler) AdminGetMirror(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+	c.JSON(http.StatusOK, h.mirrorResponse())
+}
+
+// AdminPutMirror configures the mirror target. Changing it only affects
+// later uploads; files already mirrored stay where they are.
+func (h *Handler) AdminPutMirror(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	var input mirrorInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.URL != "" {
+		u, err := url.Parse(input.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "url must be an http(s) URL"})
+			return
+		}
+	}
+	target := db.MirrorTarget{
+		Enabled:   input.Enabled,
+		URL:       input.URL,
+		Folders:   input.Folders,
+		Tags:      input.Tags,
+		UpdatedAt: h.now().Unix(),
+	}
+	if input.APIKey != nil {
+		target.APIKey = *input.APIKey
+	} else if existing, err := db.GetMirrorTarget(h.Store); err == nil {
+		target.APIKey = existing.APIKey
+	}
+	if target.Enabled && (target.URL == "" || target.APIKey == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "An enabled mirror needs a url and an api_key"})
+		return
+	}
+	if err := db.SaveMirrorTarget(h.Store, target); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save mirror"})
+		return
+	}
+	c.JSON(http.StatusOK, h.mirrorResponse())
+}
+
+// AdminListMirrorStatus lists the files tracked for mirroring, or those in
+// ?state=pending, mirrored or failed.
+func (h *Handler) AdminListMirrorStatus(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	state := c.Query("state")
+	switch state {
+	case "", db.MirrorPending, db.MirrorMirrored, db.MirrorFailed:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "state must be pending, mirrored or failed"})
+		return
+	}
+	statuses, err := db.ListMirrorStatus(h.Store, state)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list mirror status"})
+		return
+	}
+	c.JSON(http.StatusOK, statuses)
+}
+
+// AdminMirrorFile pushes a file to the mirror now, whether or not it
+// matches the mirrored folders and tags, e.g. to copy files uploaded
+// before the mirror was set up or to retry one that gave up.
+func (h *Handler) AdminMirrorFile(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	target, err := db.GetMirrorTarget(h.Store)
+	if err != nil || !target.Enabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "No mirror is enabled"})
+		return
+	}
+	record, err := db.GetFileRecord(h.Store, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	if record.IsLink() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Link files have no content to mirror"})
+		return
+	}
+	status := h.queueMirror(*record)
+	c.JSON(http.StatusAccepted, status)
+}
+
+// mirrorUpload queues a fresh upload for the mirror when it matches.
+func (h *Handler) mirrorUpload(record db.FileRecord) {
+	target, err := db.GetMirrorTarget(h.Store)
+	if err != nil || !target.Matches(&record) {
+		return
+	}
+	h.queueMirror(record)
+}
+
+// queueMirror marks the file pending and pushes it in the background. It
+// starts over the count of attempts.
+func (h *Handler) queueMirror(record db.FileRecord) db.MirrorStatus {
+	status := db.MirrorStatus{
+		FileID:    record.ID,
+		FileName:  record.OriginalName,
+		State:     db.MirrorPending,
+		UpdatedAt: h.now().Unix(),
+	}
+	if err := db.SaveMirrorStatus(h.Store, status); err != nil {
+		log.Printf("[ERROR] Failed to track mirroring of %s: %v", record.ID, err)
+	}
+	h.runJob("mirror:"+record.ID, func() error {
+		return h.pushToMirror(context.Background(), record.ID)
+	})
+	return status
+}
+
+// pushToMirror copies the file to the mirror and records the outcome.
+func (h *Handler) pushToMirror(ctx context.Context, fileID string) error {
+	status, err := db.GetMirrorStatus(h.Store, fileID)
+	if err != nil {
+		status = &db.MirrorStatus{FileID: fileID}
+	}
+	record, err := db.GetFileRecord(h.Store, fileID)
+	if err != nil {
+		// Deleted before it was pushed
+		return db.DeleteMirrorStatus(h.Store, fileID)
+	}
+	target, err := db.GetMirrorTarget(h.Store)
+	if err != nil || !target.Enabled {
+		// Stays pending until the mirror is enabled again
+		return nil
+	}
+
+	status.FileName = record.OriginalName
+	status.Attempts++
+	pushed, err := h.push(ctx, target, record)
+	status.UpdatedAt = h.now().Unix()
+	if err != nil {
+		status.State, status.Error = db.MirrorFailed, err.Error()
+		if saveErr := db.SaveMirrorStatus(h.Store, *status); saveErr != nil {
+			log.Printf("[ERROR] Failed to track mirroring of %s: %v", fileID, saveErr)
+		}
+		return fmt.Errorf("mirroring %s: %w", fileID, err)
+	}
+	status.State, status.Error = db.MirrorMirrored, ""
+	status.RemoteID, status.RemoteLink = pushed.ID, pushed.DownloadLink
+	status.MirroredAt = status.UpdatedAt
+	return db.SaveMirrorStatus(h.Store, *status)
+}
+
+func (h *Handler) push(ctx context.Context, target *db.MirrorTarget, record *db.FileRecord) (*remote.File, error) {
+	var content io.ReadCloser
+	var err error
+	if storage.IsS3Path(record.StoredPath) {
+		if h.S3 == nil {
+			return nil, errors.New("S3 storage is not configured")
+		}
+		content, err = h.S3.Get(ctx, h.S3.KeyFromPath(record.StoredPath))
+	} else {
+		content, err = os.Open(record.StoredPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+
+	client := &remote.Client{BaseURL: target.URL, ClientID: target.APIKey}
+	return client.Push(ctx, remote.Upload{
+		Name:   record.OriginalName,
+		Folder: record.Folder,
+		Tags:   record.Tags,
+		Size:   record.Size,
+		SHA256: record.SHA256,
+	}, content)
+}
+
+// RetryMirrors pushes the files whose last attempt failed, up to
+// mirrorMaxAttempts, and those left pending for too long.
+func (h *Handler) RetryMirrors(ctx context.Context) error {
+	target, err := db.GetMirrorTarget(h.Store)
+	if err != nil || !target.Enabled {
+		return nil
+	}
+	statuses, err := db.ListMirrorStatus(h.Store, "")
+	if err != nil {
+		return err
+	}
+	stale := h.now().Add(-mirrorStalePending).Unix()
+	var errs []error
+	for _, s := range statuses {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		retry := s.State == db.MirrorFailed && s.Attempts < mirrorMaxAttempts ||
+			s.State == db.MirrorPending && s.UpdatedAt < stale
+		if !retry {
+			continue
+		}
+		if err := h.pushToMirror(ctx, s.FileID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
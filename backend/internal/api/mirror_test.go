@@ -0,0 +1,116 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestMirrorPush(t *testing.T) {
+	// The off-site depot
+	offsite, _, cleanupOffsite := setupTestHandler(t)
+	defer cleanupOffsite()
+	offsiteRouter := gin.New()
+	offsiteRouter.PUT("/api/upload/raw", offsite.UploadRaw)
+	srv := httptest.NewServer(offsiteRouter)
+	defer srv.Close()
+
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.GET("/admin/mirror", h.AdminGetMirror)
+	router.PUT("/admin/mirror", h.AdminPutMirror)
+	router.GET("/admin/mirror/files", h.AdminListMirrorStatus)
+	router.POST("/admin/mirror/files/:id", h.AdminMirrorFile)
+	db.UpsertClient(h.Store, "admin", "Admin", "CODEA", 0)
+	db.UpdateClientAdminStatus(h.Store, "admin", true)
+
+	request := func(method, url, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Client-ID", "admin")
+		router.ServeHTTP(w, req)
+		return w
+	}
+	upload := func(name, folder string) db.FileRecord {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("file", name)
+		part.Write([]byte("content of " + name))
+		writer.WriteField("folder", folder)
+		writer.Close()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/upload", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		req.Header.Set("X-Client-ID", "user1")
+		router.ServeHTTP(w, req)
+		var record db.FileRecord
+		json.Unmarshal(w.Body.Bytes(), &record)
+		return record
+	}
+
+	if w := request("PUT", "/admin/mirror", `{"enabled": true, "url": "`+srv.URL+`"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected a mirror without an API key to be refused, got %d", w.Code)
+	}
+	w := request("PUT", "/admin/mirror", `{"enabled": true, "url": "`+srv.URL+`", "api_key": "main-depot", "folders": ["offsite"]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("failed to configure mirror: %s", w.Body.String())
+	}
+	var config map[string]any
+	json.Unmarshal(request("GET", "/admin/mirror", "").Body.Bytes(), &config)
+	if _, leaked := config["api_key"]; leaked || config["api_key_set"] != true {
+		t.Errorf("expected the API key to be hidden, got %v", config)
+	}
+
+	mirrored := upload("backup.tar", "offsite/nightly")
+	local := upload("scratch.txt", "scratch")
+
+	status, err := db.GetMirrorStatus(h.Store, mirrored.ID)
+	if err != nil || status.State != db.MirrorMirrored || status.RemoteID == "" {
+		t.Fatalf("expected the upload to be mirrored, got %+v", status)
+	}
+	remoteCopy, err := db.GetFileRecord(offsite.Store, status.RemoteID)
+	if err != nil || remoteCopy.OwnerID != "main-depot" || remoteCopy.Folder != "offsite/nightly" || remoteCopy.SHA256 != mirrored.SHA256 {
+		t.Errorf("expected an identical copy in the same folder on the mirror, got %+v", remoteCopy)
+	}
+	if _, err := db.GetMirrorStatus(h.Store, local.ID); err == nil {
+		t.Error("expected files outside the mirrored folders to stay local")
+	}
+
+	// An unreachable mirror fails the push, and the retry job catches up
+	srv.Close()
+	failed := upload("second.tar", "offsite")
+	status, _ = db.GetMirrorStatus(h.Store, failed.ID)
+	if status.State != db.MirrorFailed || status.Attempts != 1 || status.Error == "" {
+		t.Errorf("expected the push to fail, got %+v", status)
+	}
+	srv = httptest.NewServer(offsiteRouter)
+	defer srv.Close()
+	request("PUT", "/admin/mirror", `{"enabled": true, "url": "`+srv.URL+`", "folders": ["offsite"]}`)
+	if err := h.RetryMirrors(context.Background()); err != nil {
+		t.Fatalf("retry failed: %v", err)
+	}
+	status, _ = db.GetMirrorStatus(h.Store, failed.ID)
+	if status.State != db.MirrorMirrored || status.Attempts != 2 {
+		t.Errorf("expected the retry to mirror the file with the kept API key, got %+v", status)
+	}
+
+	// Admins can push any file by hand
+	if w := request("POST", "/admin/mirror/files/"+local.ID, ""); w.Code != http.StatusAccepted {
+		t.Errorf("expected a manual push to be accepted, got %d", w.Code)
+	}
+	var statuses []db.MirrorStatus
+	json.Unmarshal(request("GET", "/admin/mirror/files?state=mirrored", "").Body.Bytes(), &statuses)
+	if len(statuses) != 3 {
+		t.Errorf("expected three mirrored files, got %d", len(statuses))
+	}
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"slices"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/notify"
+	"github.com/gin-gonic/gin"
+)
+
+type notificationInput struct {
+	Email      string `json:"email"`
+	WebhookURL string `json:"webhook_url"`
+	// Events defaults to every event
+	Events []string `json:"events"`
+}
+
+func (in *notificationInput) validate() error {
+	if in.Email != "" {
+		addr, err := mail.ParseAddress(in.Email)
+		if err != nil || addr.Name != "" {
+			return fmt.Errorf("email must be a plain email address")
+		}
+	}
+	if in.WebhookURL != "" {
+		u, err := url.Parse(in.WebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhook_url must be an http(s) URL")
+		}
+	}
+	for _, e := range in.Events {
+		if !slices.Contains(db.NotificationEvents, e) {
+			return fmt.Errorf("unknown event %q", e)
+		}
+	}
+	return nil
+}
+
+type notificationResponse struct {
+	db.NotificationPrefs
+	// EmailEnabled tells whether the depot can send email at all
+	EmailEnabled bool     `json:"email_enabled"`
+	Available    []string `json:"available_events"`
+}
+
+func (h *Handler) notificationResponse(p *db.NotificationPrefs) notificationResponse {
+	if p.Events == nil {
+		p.Events = []string{}
+	}
+	return notificationResponse{*p, h.Mailer != nil, db.NotificationEvents}
+}
+
+// GetNotifications returns where and about what the caller is notified.
+func (h *Handler) GetNotifications(c *gin.Context) {
+	ownerID := c.GetHeader("X-Client-ID")
+	if ownerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
+		return
+	}
+	p, err := db.GetNotificationPrefs(h.Store, ownerID)
+	if err != nil {
+		p = &db.NotificationPrefs{OwnerID: ownerID}
+	}
+	c.JSON(http.StatusOK, h.notificationResponse(p))
+}
+
+// PutNotifications replaces the caller's notification settings. Leaving out
+// both the email address and the webhook turns notifications off.
+func (h *Handler) PutNotifications(c *gin.Context) {
+	ownerID := c.GetHeader("X-Client-ID")
+	if ownerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
+		return
+	}
+	var input notificationInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := input.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	events := input.Events
+	if events == nil {
+		events = db.NotificationEvents
+	}
+
+	p := db.NotificationPrefs{
+		OwnerID:    ownerID,
+		Email:      input.Email,
+		WebhookURL: input.WebhookURL,
+		Events:     slices.Compact(slices.Sorted(slices.Values(events))),
+		UpdatedAt:  h.now().Unix(),
+	}
+	if err := db.SaveNotificationPrefs(h.Store, p); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save notification settings"})
+		return
+	}
+	c.JSON(http.StatusOK, h.notificationResponse(&p))
+}
+
+// notifyClient tells the client about the event if they asked to hear
+// about it. Delivery runs as a background job.
+func (h *Handler) notifyClient(ownerID, event, text string, data any) bool {
+	if ownerID == "" || ownerID == db.SystemPersona {
+		return false
+	}
+	p, err := db.GetNotificationPrefs(h.Store, ownerID)
+	if err != nil || !p.Wants(event) {
+		return false
+	}
+	h.deliverNotification(*p, notify.Message{Event: event, Text: text, Data: data, Time: h.now().Unix()})
+	return true
+}
+
+// notifyAll tells every client who asked to hear about the event.
+func (h *Handler) notifyAll(event, text string, data any) {
+	subscribers, _ := db.ListNotificationSubscribers(h.Store, event)
+	for _, p := range subscribers {
+		h.deliverNotification(p, notify.Message{Event: event, Text: text, Data: data, Time: h.now().Unix()})
+	}
+}
+
+func (h *Handler) deliverNotification(p db.NotificationPrefs, msg notify.Message) {
+	h.runJob("notify:"+msg.Event+":"+p.OwnerID, func() error {
+		var errs []error
+		if err := notify.Webhook(p.WebhookURL, msg); err != nil {
+			errs = append(errs, fmt.Errorf("webhook: %w", err))
+		}
+		if p.Email != "" && h.Mailer != nil {
+			if err := h.Mailer.Send(p.Email, msg); err != nil {
+				errs = append(errs, fmt.Errorf("email: %w", err))
+			}
+		}
+		return errors.Join(errs...)
+	})
+}
+
+// notifyDownloaded tells the owner that someone else downloaded their file.
+func (h *Handler) notifyDownloaded(c *gin.Context, record *db.FileRecord, country string) {
+	if record.OwnerID == "" || c.GetHeader("X-Client-ID") == record.OwnerID {
+		return
+	}
+	text := fmt.Sprintf("%s was downloaded\n%s/api/download/%s", record.OriginalName, h.baseURL(c), record.DownloadLink)
+	h.notifyClient(record.OwnerID, db.EventShareDownloaded, text, gin.H{
+		"file_id": record.ID,
+		"name":    record.OriginalName,
+		"country": country,
+	})
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/notify"
+	"github.com/gin-gonic/gin"
+)
+
+func TestNotifications(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	var mu sync.Mutex
+	var events []string
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg notify.Message
+		json.NewDecoder(r.Body).Decode(&msg)
+		mu.Lock()
+		events = append(events, msg.Event)
+		mu.Unlock()
+	}))
+	defer hook.Close()
+	received := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), events...)
+	}
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.GET("/download/:id", h.DownloadFile)
+	router.GET("/persona/notifications", h.GetNotifications)
+	router.PUT("/persona/notifications", h.PutNotifications)
+
+	put := func(body any) (int, notificationResponse) {
+		data, _ := json.Marshal(body)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/persona/notifications", bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Client-ID", "owner")
+		router.ServeHTTP(w, req)
+		var resp notificationResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp
+	}
+
+	for _, bad := range []gin.H{
+		{"email": "Someone <someone@example.com>"},
+		{"webhook_url": "ftp://example.com/hook"},
+		{"webhook_url": hook.URL, "events": []string{"file.deleted"}},
+	} {
+		if code, _ := put(bad); code != http.StatusBadRequest {
+			t.Errorf("expected %v to be rejected, got %d", bad, code)
+		}
+	}
+
+	// Events default to all of them
+	code, resp := put(gin.H{"webhook_url": hook.URL})
+	if code != http.StatusOK || len(resp.Events) != len(db.NotificationEvents) || resp.EmailEnabled {
+		t.Fatalf("expected every event over the webhook, got %d %+v", code, resp)
+	}
+	if code, resp = put(gin.H{"webhook_url": hook.URL, "events": []string{db.EventShareDownloaded, db.EventFileExpiring}}); code != http.StatusOK {
+		t.Fatalf("failed to save settings: %d", code)
+	}
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/persona/notifications", nil)
+	req.Header.Set("X-Client-ID", "owner")
+	router.ServeHTTP(w, req)
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.WebhookURL != hook.URL || len(resp.Events) != 2 {
+		t.Fatalf("expected the saved settings, got %s", w.Body.String())
+	}
+
+	shared := uploadTestFile(t, router, "owner", "report.txt", []byte("quarterly report"))
+	download := func(clientID string) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/download/"+shared["download_link"].(string), nil)
+		if clientID != "" {
+			req.Header.Set("X-Client-ID", clientID)
+		}
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("download failed: %d", w.Code)
+		}
+	}
+
+	// The owner's own downloads don't notify them
+	download("owner")
+	download("")
+	if got := received(); len(got) != 1 || got[0] != db.EventShareDownloaded {
+		t.Fatalf("expected one download notification, got %v", got)
+	}
+
+	// An expiring link is announced once
+	record, _ := db.GetFileRecord(h.Store, shared["id"].(string))
+	record.ExpiresAt = time.Now().Add(2 * time.Hour).Unix()
+	db.SaveFileRecord(h.Store, *record)
+	for range 2 {
+		if err := h.NotifyExpiring(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := received(); len(got) != 2 || got[1] != db.EventFileExpiring {
+		t.Fatalf("expected one expiry notification, got %v", got)
+	}
+
+	// Quota warnings go to those who asked for them, once per level
+	db.SaveNotificationPrefs(h.Store, db.NotificationPrefs{OwnerID: "watcher", WebhookURL: hook.URL, Events: []string{db.EventQuotaWarning}})
+	h.QuotaBytes = 10
+	h.checkQuota()
+	h.checkQuota()
+	if got := received(); len(got) != 3 || got[2] != db.EventQuotaWarning {
+		t.Fatalf("expected one quota warning, got %v", got)
+	}
+}
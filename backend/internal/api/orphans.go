@@ -0,0 +1,89 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminListOrphans reports the files whose owner no longer exists. Nobody
+// but admins can see them, so they would otherwise go unnoticed.
+func (h *Handler) AdminListOrphans(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+	files, err := db.ListOrphanedFiles(h.Store)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list orphaned files"})
+		return
+	}
+	owners := make(map[string]int)
+	var size int64
+	for _, f := range files {
+		owners[f.OwnerID]++
+		size += f.Size
+	}
+	c.JSON(http.StatusOK, gin.H{"files": files, "total": len(files), "total_size": size, "owners": owners})
+}
+
+// AdminReassignOrphans hands orphaned files to a client or, with owner_id
+// "_system", to the system persona. Without file_ids every orphan moves;
+// listed files that aren't orphaned are skipped.
+func (h *Handler) AdminReassignOrphans(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+	var input struct {
+		OwnerID string   `json:"owner_id" binding:"required"`
+		FileIDs []string `json:"file_ids"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	target := input.OwnerID
+	if target == db.SystemPersona {
+		target = ""
+	} else if _, err := db.GetClient(h.Store, target); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Client " + target + " does not exist"})
+		return
+	}
+
+	orphans, err := db.ListOrphanedFiles(h.Store)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list orphaned files"})
+		return
+	}
+	var selected map[string]bool
+	if len(input.FileIDs) > 0 {
+		selected = make(map[string]bool, len(input.FileIDs))
+		for _, id := range input.FileIDs {
+			selected[id] = true
+		}
+	}
+
+	chosen := []db.FileRecord{}
+	for _, f := range orphans {
+		if selected == nil || selected[f.ID] {
+			chosen = append(chosen, f)
+		}
+	}
+	if err := h.enforcePoolQuota(target, chosen); err != nil {
+		respondIngestError(c, err)
+		return
+	}
+
+	reassigned := []string{}
+	for _, f := range chosen {
+		if _, err := db.TransferFile(h.Store, f.ID, target); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reassign " + f.ID, "reassigned": reassigned})
+			return
+		}
+		reassigned = append(reassigned, f.ID)
+	}
+	c.JSON(http.StatusOK, gin.H{"reassigned": reassigned})
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestOrphans(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.GET("/admin/orphans", h.AdminListOrphans)
+	router.POST("/admin/orphans/reassign", h.AdminReassignOrphans)
+
+	db.UpsertClient(h.Store, "admin", "Admin", "CODEA", 0)
+	db.UpdateClientAdminStatus(h.Store, "admin", true)
+	db.UpsertClient(h.Store, "gone", "Gone", "CODEB", 0)
+	db.UpsertClient(h.Store, "heir", "Heir", "CODEC", 0)
+
+	first := uploadTestFile(t, router, "gone", "a.txt", []byte("one"))["id"].(string)
+	second := uploadTestFile(t, router, "gone", "b.txt", []byte("two"))["id"].(string)
+	uploadTestFile(t, router, "heir", "c.txt", []byte("three"))
+	db.DeleteClient(h.Store, "gone")
+
+	do := func(clientID, method, url, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Client-ID", clientID)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("heir", "GET", "/admin/orphans", ""); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a client, got %d", w.Code)
+	}
+	w := do("admin", "GET", "/admin/orphans", "")
+	var report struct {
+		Total  int            `json:"total"`
+		Owners map[string]int `json:"owners"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &report)
+	if report.Total != 2 || report.Owners["gone"] != 2 {
+		t.Fatalf("expected two orphans of the deleted client, got %s", w.Body.String())
+	}
+
+	if w := do("admin", "POST", "/admin/orphans/reassign", `{"owner_id": "nobody"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for a missing target, got %d", w.Code)
+	}
+	if w := do("admin", "POST", "/admin/orphans/reassign", `{"owner_id": "heir", "file_ids": ["`+first+`"]}`); w.Code != http.StatusOK {
+		t.Fatalf("reassign failed: %s", w.Body.String())
+	}
+	if record, _ := db.GetFileRecord(h.Store, first); record.OwnerID != "heir" {
+		t.Errorf("expected the file to belong to heir, got %q", record.OwnerID)
+	}
+
+	if w := do("admin", "POST", "/admin/orphans/reassign", `{"owner_id": "_system"}`); w.Code != http.StatusOK {
+		t.Fatalf("reassign failed: %s", w.Body.String())
+	}
+	if record, _ := db.GetFileRecord(h.Store, second); record.OwnerID != "" {
+		t.Errorf("expected the file to belong to the system, got %q", record.OwnerID)
+	}
+	if orphans, _ := db.ListOrphanedFiles(h.Store); len(orphans) != 0 {
+		t.Errorf("expected no orphans left, got %d", len(orphans))
+	}
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/celerix/depot/internal/db"
+)
+
+// Overwrite modes decide what an upload does when its owner already has a
+// file of the same name in the target folder. Without one, both are kept
+// under the same name.
+const (
+	// overwriteRefuse fails the upload
+	overwriteRefuse = "false"
+	// overwriteReplace uploads the new content and removes the old file
+	overwriteReplace = "replace"
+	// overwriteVersion keeps both, numbering the new name: "a (2).txt"
+	overwriteVersion = "version"
+)
+
+// maxNameVersions bounds the search for a free numbered name.
+const maxNameVersions = 10000
+
+func validOverwrite(mode string) bool {
+	switch mode {
+	case "", overwriteRefuse, overwriteReplace, overwriteVersion:
+		return true
+	}
+	return false
+}
+
+var errInvalidOverwrite = &ingestError{Status: http.StatusBadRequest, Message: "overwrite must be false, replace or version"}
+
+// versionedName numbers a name before its extension.
+func versionedName(name string, n int) string {
+	ext := path.Ext(name)
+	if ext == name {
+		ext = ""
+	}
+	return fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(name, ext), n, ext)
+}
+
+func nameClaim(ownerID, folder, name string) string {
+	return ownerID + "\x00" + folder + "\x00" + name
+}
+
+// existingFile finds the owner's file of that name in the folder.
+func (h *Handler) existingFile(ownerID, folder, name string) *db.FileRecord {
+	record, err := db.FindFolderFile(h.Store, ownerID, folder, name)
+	if err != nil {
+		return nil
+	}
+	return record
+}
+
+// claimName applies opts.Overwrite before an upload is recorded: it claims
+// the name so concurrent uploads don't both take it, numbers it in version
+// mode, and returns the file that replace mode removes. The caller releases
+// the claim once the record is saved.
+func (h *Handler) claimName(opts *ingestOptions) (claim string, replaced *db.FileRecord, err error) {
+	if !validOverwrite(opts.Overwrite) {
+		return "", nil, errInvalidOverwrite
+	}
+	conflict := &ingestError{Status: http.StatusConflict, Message: "A file named " + opts.Name + " already exists"}
+	switch opts.Overwrite {
+	case overwriteRefuse:
+		claim = nameClaim(opts.OwnerID, opts.Folder, opts.Name)
+		if !h.names.start(h.Store, claim) {
+			return "", nil, conflict
+		}
+		if h.existingFile(opts.OwnerID, opts.Folder, opts.Name) != nil {
+			h.names.done(claim)
+			return "", nil, conflict
+		}
+	case overwriteReplace:
+		claim = nameClaim(opts.OwnerID, opts.Folder, opts.Name)
+		if !h.names.start(h.Store, claim) {
+			return "", nil, &ingestError{Status: http.StatusConflict, Message: "Another upload of " + opts.Name + " is in progress"}
+		}
+		replaced = h.existingFile(opts.OwnerID, opts.Folder, opts.Name)
+		if replaced != nil && replaced.Metadata["artifact"] != "" {
+			h.names.done(claim)
+			return "", nil, &ingestError{Status: http.StatusConflict, Message: "Published artifacts cannot be replaced"}
+		}
+		if replaced != nil && h.retained(replaced) {
+			h.names.done(claim)
+			return "", nil, &ingestError{Status: http.StatusConflict, Message: "File is retained and cannot be replaced"}
+		}
+	case overwriteVersion:
+		name := opts.Name
+		for n := 2; ; n++ {
+			claim = nameClaim(opts.OwnerID, opts.Folder, name)
+			if h.names.start(h.Store, claim) {
+				if h.existingFile(opts.OwnerID, opts.Folder, name) == nil {
+					break
+				}
+				h.names.done(claim)
+			}
+			if n > maxNameVersions {
+				return "", nil, conflict
+			}
+			name = versionedName(opts.Name, n)
+		}
+		opts.Name = name
+	}
+	return claim, replaced, nil
+}
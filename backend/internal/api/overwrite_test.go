@@ -0,0 +1,85 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestUploadOverwrite(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.POST("/uploads", h.CreateUploadSession)
+
+	upload := func(name, content, overwrite string) (*httptest.ResponseRecorder, db.FileRecord) {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("file", name)
+		part.Write([]byte(content))
+		writer.WriteField("overwrite", overwrite)
+		writer.Close()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/upload", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		req.Header.Set("X-Client-ID", "client-a")
+		router.ServeHTTP(w, req)
+		var record db.FileRecord
+		json.Unmarshal(w.Body.Bytes(), &record)
+		return w, record
+	}
+
+	_, first := upload("report.pdf", "one", "")
+	if w, _ := upload("report.pdf", "two", "false"); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 for an existing name, got %d", w.Code)
+	}
+	if w, _ := upload("report.pdf", "two", "always"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown mode, got %d", w.Code)
+	}
+
+	w, second := upload("report.pdf", "two", "version")
+	if w.Code != http.StatusOK || second.OriginalName != "report (2).pdf" {
+		t.Fatalf("expected a numbered name, got %d %s", w.Code, second.OriginalName)
+	}
+	if _, third := upload("report.pdf", "three", "version"); third.OriginalName != "report (3).pdf" {
+		t.Errorf("expected the next free number, got %s", third.OriginalName)
+	}
+
+	w, replaced := upload("report.pdf", "four", "replace")
+	if w.Code != http.StatusOK || replaced.OriginalName != "report.pdf" {
+		t.Fatalf("expected the replacement to keep the name, got %d %s", w.Code, replaced.OriginalName)
+	}
+	if _, err := db.GetFileRecord(h.Store, first.ID); err == nil {
+		t.Error("expected the replaced file to be removed")
+	}
+	if _, err := db.GetFileRecord(h.Store, second.ID); err != nil {
+		t.Error("expected other versions to be kept")
+	}
+	if w, _ := upload("new.pdf", "five", "false"); w.Code != http.StatusOK {
+		t.Errorf("expected a new name to pass, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/uploads", bytes.NewBufferString(`{"name": "report.pdf", "size": 10, "overwrite": "false"}`))
+	req.Header.Set("X-Client-ID", "client-a")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected a chunked upload of an existing name to be refused up front, got %d", w.Code)
+	}
+
+	if got := versionedName("archive", 2); got != "archive (2)" {
+		t.Errorf("versionedName without an extension = %q", got)
+	}
+	if got := versionedName(".env", 2); got != ".env (2)" {
+		t.Errorf("versionedName of a dotfile = %q", got)
+	}
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+const defaultPartSize = 8 << 20
+
+// partSizes are the part sizes offered, so the checksums cached per blob
+// stay few.
+var partSizes = []int64{1 << 20, 4 << 20, defaultPartSize, 16 << 20, 64 << 20}
+
+// partSize is the smallest offered part size of at least requested, or the
+// largest.
+func partSize(requested int64) int64 {
+	for _, size := range partSizes {
+		if size >= requested {
+			return size
+		}
+	}
+	return partSizes[len(partSizes)-1]
+}
+
+// GetDownloadParts returns SHA-256 checksums for fixed-size parts of a file so
+// multi-connection clients can verify each range they fetch independently.
+func (h *Handler) GetDownloadParts(c *gin.Context) {
+	record, err := h.findDownloadRecord(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+
+	if !h.contentAccessible(c, record) {
+		return
+	}
+	if record.IsLink() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Link files have no content"})
+		return
+	}
+
+	requested, _ := strconv.ParseInt(c.DefaultQuery("part_size", strconv.Itoa(defaultPartSize)), 10, 64)
+	partSize := partSize(requested)
+
+	// Content is addressed by hash, so part checksums can be cached per blob
+	cacheKey := ""
+	if record.SHA256 != "" {
+		cacheKey = fmt.Sprintf("%s%s:%d", db.PartsKeyPrefix, record.SHA256, partSize)
+		if parts, err := sdk.Get[[]storage.PartChecksum](h.Store, db.SystemPersona, db.AppID, cacheKey); err == nil {
+			h.respondParts(c, record, partSize, parts)
+			return
+		}
+	}
+
+	content, err := h.openStored(c.Request.Context(), record.StoredPath)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+		return
+	}
+	defer content.Close()
+	parts, err := storage.HashParts(content, partSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to checksum file"})
+		return
+	}
+	if cacheKey != "" {
+		_ = h.Store.Set(db.SystemPersona, db.AppID, cacheKey, parts)
+	}
+
+	h.respondParts(c, record, partSize, parts)
+}
+
+func (h *Handler) respondParts(c *gin.Context, record *db.FileRecord, partSize int64, parts []storage.PartChecksum) {
+	c.JSON(http.StatusOK, gin.H{
+		"id":        record.ID,
+		"size":      record.Size,
+		"sha256":    record.SHA256,
+		"part_size": partSize,
+		"parts":     parts,
+	})
+}
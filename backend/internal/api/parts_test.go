@@ -0,0 +1,81 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetDownloadParts(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.GET("/download/:id/parts", h.GetDownloadParts)
+	router.DELETE("/files/:id", h.DeleteFile)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	content := bytes.Repeat([]byte("part"), 700<<10) // 2.8 MiB
+	uploaded := uploadTestFile(t, router, "client-a", "parts.bin", content)
+	id, link, hash := uploaded["id"].(string), uploaded["download_link"].(string), uploaded["sha256"].(string)
+
+	// Part sizes snap to the offered ones
+	w := get("/download/" + link + "/parts?part_size=100")
+	var resp struct {
+		PartSize int64 `json:"part_size"`
+		Parts    []struct {
+			Size int64 `json:"size"`
+		} `json:"parts"`
+	}
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &resp) != nil {
+		t.Fatalf("GetDownloadParts: %d %s", w.Code, w.Body.String())
+	}
+	if resp.PartSize != 1<<20 || len(resp.Parts) != 3 || resp.Parts[2].Size != int64(len(content))-2<<20 {
+		t.Errorf("expected 3 parts of 1 MiB, got %d parts of %d", len(resp.Parts), resp.PartSize)
+	}
+	if w := get("/download/" + link + "/parts?part_size=1073741824"); !bytes.Contains(w.Body.Bytes(), []byte(`"part_size":67108864`)) {
+		t.Errorf("expected a huge part size to become 64 MiB, got %s", w.Body.String())
+	}
+
+	// The part checksums are refused like the download
+	record, _ := db.GetFileRecord(h.Store, id)
+	record.Quarantined = true
+	db.SaveFileRecord(h.Store, *record)
+	if w := get("/download/" + link + "/parts"); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a quarantined file, got %d", w.Code)
+	}
+	record.Quarantined = false
+	record.PasswordProtected = true
+	db.SaveFileRecord(h.Store, *record)
+	if w := get("/download/" + link + "/parts"); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for a password-protected file, got %d", w.Code)
+	}
+
+	// The cached checksums go with the content
+	cacheKey := db.PartsKeyPrefix + hash + ":1048576"
+	if _, err := h.Store.Get(db.SystemPersona, db.AppID, cacheKey); err != nil {
+		t.Fatalf("expected the part checksums to be cached: %v", err)
+	}
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/files/"+id, nil)
+	req.Header.Set("X-Client-ID", "client-a")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("DeleteFile: %d %s", w.Code, w.Body.String())
+	}
+	if _, err := h.Store.Get(db.SystemPersona, db.AppID, cacheKey); err == nil {
+		t.Error("the part checksums outlived the content")
+	}
+}
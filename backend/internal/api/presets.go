@@ -0,0 +1,91 @@
+package api
+
+import (
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+var presetName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// ListPresets returns the upload presets clients can choose from.
+func (h *Handler) ListPresets(c *gin.Context) {
+	presets, err := db.ListPresets(h.Store)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list presets"})
+		return
+	}
+	c.JSON(http.StatusOK, presets)
+}
+
+// AdminPutPreset creates or replaces an upload preset.
+func (h *Handler) AdminPutPreset(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	name := c.Param("name")
+	if !presetName.MatchString(name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Preset names use lowercase letters, digits, '-' and '_'"})
+		return
+	}
+	var input struct {
+		Description       string   `json:"description"`
+		LinkExpirySeconds *int64   `json:"link_expiry_seconds"`
+		RequirePassword   bool     `json:"require_password"`
+		Folder            string   `json:"folder"`
+		Tags              []string `json:"tags"`
+		MaxUploadBytes    int64    `json:"max_upload_bytes"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.LinkExpirySeconds != nil && *input.LinkExpirySeconds < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "link_expiry_seconds must not be negative"})
+		return
+	}
+	if input.MaxUploadBytes < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "max_upload_bytes must not be negative"})
+		return
+	}
+
+	preset := db.UploadPreset{
+		Name:              name,
+		Description:       input.Description,
+		LinkExpirySeconds: input.LinkExpirySeconds,
+		RequirePassword:   input.RequirePassword,
+		Folder:            input.Folder,
+		Tags:              input.Tags,
+		MaxUploadBytes:    input.MaxUploadBytes,
+		UpdatedAt:         time.Now().Unix(),
+	}
+	if err := db.SavePreset(h.Store, preset); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save preset"})
+		return
+	}
+	saved, _ := db.GetPreset(h.Store, name)
+	c.JSON(http.StatusOK, saved)
+}
+
+func (h *Handler) AdminDeletePreset(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	name := c.Param("name")
+	if _, err := db.GetPreset(h.Store, name); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Preset not found"})
+		return
+	}
+	if err := db.DeletePreset(h.Store, name); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete preset"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
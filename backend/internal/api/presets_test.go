@@ -0,0 +1,108 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestUploadPresets(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	clock := time.Date(2026, 6, 1, 8, 0, 0, 0, time.UTC)
+	h.Now = func() time.Time { return clock }
+	db.UpsertClient(h.Store, "admin", "Admin", "CODEA", 0)
+	db.UpdateClientAdminStatus(h.Store, "admin", true)
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.GET("/presets", h.ListPresets)
+	router.PUT("/admin/presets/:name", h.AdminPutPreset)
+	router.DELETE("/admin/presets/:name", h.AdminDeletePreset)
+
+	put := func(clientID, name, body string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/admin/presets/"+name, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Client-ID", clientID)
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	preset := `{"description": "Deliveries to customers", "link_expiry_seconds": 604800, "require_password": true, "folder": "deliveries", "tags": ["customer"], "max_upload_bytes": 64}`
+	if code := put("client-a", "customer-delivery", preset); code != http.StatusForbidden {
+		t.Errorf("expected 403 for a client, got %d", code)
+	}
+	if code := put("admin", "Customer Delivery", preset); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid name, got %d", code)
+	}
+	if code := put("admin", "customer-delivery", preset); code != http.StatusOK {
+		t.Fatalf("saving preset failed with %d", code)
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/presets", nil)
+	router.ServeHTTP(w, req)
+	var presets []db.UploadPreset
+	json.Unmarshal(w.Body.Bytes(), &presets)
+	if len(presets) != 1 || presets[0].Folder != "deliveries" {
+		t.Fatalf("expected the preset to be listed, got %s", w.Body.String())
+	}
+
+	upload := func(presetName, password string, content []byte) *httptest.ResponseRecorder {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("file", "report.pdf")
+		part.Write(content)
+		writer.WriteField("preset", presetName)
+		writer.WriteField("tags", "q2")
+		if password != "" {
+			writer.WriteField("password", password)
+		}
+		writer.Close()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/upload", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		req.Header.Set("X-Client-ID", "client-a")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := upload("nonexistent", "", []byte("report")); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown preset, got %d", w.Code)
+	}
+	if w := upload("customer-delivery", "", []byte("report")); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without a password, got %d", w.Code)
+	}
+	if w := upload("customer-delivery", "s3cret", bytes.Repeat([]byte("x"), 65)); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 above the preset's limit, got %d", w.Code)
+	}
+	w = upload("customer-delivery", "s3cret", []byte("report"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("upload failed: %s", w.Body.String())
+	}
+	var record db.FileRecord
+	json.Unmarshal(w.Body.Bytes(), &record)
+	if record.Folder != "deliveries" || !record.HasTag("customer") || !record.HasTag("q2") || !record.PasswordProtected {
+		t.Errorf("expected the preset's folder, tags and password, got %+v", record)
+	}
+	if want := clock.Unix() + 604800; record.ExpiresAt != want {
+		t.Errorf("expected expiry %d, got %d", want, record.ExpiresAt)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/admin/presets/customer-delivery", nil)
+	req.Header.Set("X-Client-ID", "admin")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected the preset to be deleted, got %d", w.Code)
+	}
+}
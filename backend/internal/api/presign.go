@@ -0,0 +1,144 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	presignExpiry     = 1 * time.Hour
+	presignKeyPrefix  = "presign:"
+	downloadURLExpiry = 15 * time.Minute
+)
+
+// presignedUpload remembers what a client promised to upload directly to
+// object storage until it commits.
+type presignedUpload struct {
+	ID        string `json:"id"`
+	OwnerID   string `json:"owner_id"`
+	Name      string `json:"name"`
+	Size      int64  `json:"size"`
+	SHA256    string `json:"sha256"`
+	IsPublic  bool   `json:"is_public"`
+	Key       string `json:"key"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+func (h *Handler) PresignUpload(c *gin.Context) {
+	if h.S3 == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Direct uploads require the S3 backend"})
+		return
+	}
+	ownerID := c.GetHeader("X-Client-ID")
+	if ownerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
+		return
+	}
+
+	var input struct {
+		Name     string `json:"name" binding:"required"`
+		Size     int64  `json:"size" binding:"required"`
+		SHA256   string `json:"sha256" binding:"required,len=64,hexadecimal"`
+		IsPublic bool   `json:"is_public"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	id := uuid.New().String()
+	key := h.ObjectPrefix + "uploads/" + id
+	// The checksum is signed into the URL, so storage enforces it for us
+	putURL, headers, err := h.S3.PresignPut(key, input.SHA256, presignExpiry)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign upload URL"})
+		return
+	}
+
+	pending := presignedUpload{
+		ID:        id,
+		OwnerID:   ownerID,
+		Name:      input.Name,
+		Size:      input.Size,
+		SHA256:    strings.ToLower(input.SHA256),
+		IsPublic:  input.IsPublic,
+		Key:       key,
+		ExpiresAt: time.Now().Add(presignExpiry).Unix(),
+	}
+	if err := h.Store.Set(db.SystemPersona, db.AppID, presignKeyPrefix+id, pending); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save upload session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":         id,
+		"method":     http.MethodPut,
+		"url":        putURL,
+		"headers":    headers,
+		"expires_at": pending.ExpiresAt,
+	})
+}
+
+func (h *Handler) CommitPresignedUpload(c *gin.Context) {
+	if h.S3 == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Direct uploads require the S3 backend"})
+		return
+	}
+
+	id := c.Param("id")
+	pending, err := sdk.Get[presignedUpload](h.Store, db.SystemPersona, db.AppID, presignKeyPrefix+id)
+	if err != nil || pending.OwnerID != c.GetHeader("X-Client-ID") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload session not found"})
+		return
+	}
+
+	size, err := h.S3.Head(c.Request.Context(), pending.Key)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Object has not been uploaded yet"})
+		return
+	}
+	if size != pending.Size {
+		_ = h.deleteStored(h.S3.Path(pending.Key))
+		_ = h.Store.Delete(db.SystemPersona, db.AppID, presignKeyPrefix+id)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("Uploaded object is %d bytes, expected %d", size, pending.Size)})
+		return
+	}
+
+	record, err := h.ingest(c.Request.Context(), stagedFile{ID: pending.ID, Path: h.S3.Path(pending.Key), Size: size, SHA256: pending.SHA256}, ingestOptions{
+		OwnerID:    pending.OwnerID,
+		RemoteAddr: c.ClientIP(),
+		Name:       pending.Name,
+		IsPublic:   pending.IsPublic,
+		IsAdmin:    h.isAdmin(c),
+	})
+	if err != nil {
+		respondIngestError(c, err)
+		return
+	}
+	_ = h.Store.Delete(db.SystemPersona, db.AppID, presignKeyPrefix+id)
+
+	c.JSON(http.StatusOK, record)
+}
+
+func (h *Handler) redirectToS3(c *gin.Context, record *db.FileRecord) {
+	if h.S3 == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "S3 storage is not configured"})
+		return
+	}
+	q := url.Values{}
+	q.Set("response-content-disposition", contentDisposition("attachment", record.OriginalName))
+	target, err := h.S3.PresignGet(h.S3.KeyFromPath(record.StoredPath), q, downloadURLExpiry)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign download URL"})
+		return
+	}
+	c.Redirect(http.StatusFound, target)
+}
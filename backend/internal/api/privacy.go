@@ -0,0 +1,67 @@
+package api
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/netip"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+)
+
+// loggedAddress is what download analytics and audit entries keep of the
+// client's address under the current settings: a salted hash, the /24
+// (IPv4) or /48 (IPv6) network, or nothing.
+func (h *Handler) loggedAddress(ip string) string {
+	mode := db.GetSettings(h.Store).IPAddresses
+	if mode == "" || mode == db.IPNone {
+		return ""
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ""
+	}
+	addr = addr.Unmap()
+	switch mode {
+	case db.IPTruncated:
+		bits := 24
+		if addr.Is6() {
+			bits = 48
+		}
+		prefix, _ := addr.Prefix(bits)
+		return prefix.String()
+	case db.IPHashed:
+		salt, err := db.IPSalt(h.Store)
+		if err != nil {
+			log.Printf("[ERROR] Failed to load the address salt: %v", err)
+			return ""
+		}
+		mac := hmac.New(sha256.New, []byte(salt))
+		mac.Write(addr.AsSlice())
+		return hex.EncodeToString(mac.Sum(nil))[:16]
+	}
+	return ""
+}
+
+// PruneAccessLogs drops download analytics and audit entries older than
+// the retention the settings ask for.
+func (h *Handler) PruneAccessLogs(ctx context.Context) error {
+	settings := db.GetSettings(h.Store)
+	now := h.now()
+	var errs []error
+	if days := settings.AnalyticsRetentionDays; days > 0 {
+		if _, err := db.PruneAnalytics(h.Store, now.Add(-time.Duration(days)*24*time.Hour)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if days := settings.AuditRetentionDays; days > 0 {
+		if _, err := db.PruneAudit(h.Store, now.Add(-time.Duration(days)*24*time.Hour)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestLoggedAddress(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	if got := h.loggedAddress("192.0.2.10"); got != "" {
+		t.Errorf("expected no address by default, got %q", got)
+	}
+
+	db.SaveSettings(h.Store, db.Settings{IPAddresses: db.IPTruncated})
+	for ip, want := range map[string]string{
+		"192.0.2.10":          "192.0.2.0/24",
+		"::ffff:192.0.2.10":   "192.0.2.0/24",
+		"2001:db8:1:2:3::4":   "2001:db8:1::/48",
+		"not an address":      "",
+		"2001:db8:ffff::abcd": "2001:db8:ffff::/48",
+	} {
+		if got := h.loggedAddress(ip); got != want {
+			t.Errorf("truncating %q: expected %q, got %q", ip, want, got)
+		}
+	}
+
+	db.SaveSettings(h.Store, db.Settings{IPAddresses: db.IPHashed})
+	first, second := h.loggedAddress("192.0.2.10"), h.loggedAddress("192.0.2.11")
+	if len(first) != 16 || first == second || h.loggedAddress("192.0.2.10") != first {
+		t.Errorf("expected stable, distinct hashes, got %q and %q", first, second)
+	}
+}
+
+func TestAccessLogRetention(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	clock := time.Date(2026, 6, 1, 8, 0, 0, 0, time.UTC)
+	h.Now = func() time.Time { return clock }
+	db.UpsertClient(h.Store, "admin", "Admin", "CODEA", 0)
+	db.UpdateClientAdminStatus(h.Store, "admin", true)
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.GET("/download/:id", h.DownloadFile)
+	router.GET("/files/:id/analytics", h.GetFileAnalytics)
+	router.PUT("/admin/settings", h.UpdateSettings)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/admin/settings", strings.NewReader(`{"ip_addresses": "everything"}`))
+	req.Header.Set("X-Client-ID", "admin")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected an unknown address mode to be rejected, got %d", w.Code)
+	}
+	db.SaveSettings(h.Store, db.Settings{IPAddresses: db.IPTruncated, AnalyticsRetentionDays: 30, AuditRetentionDays: 90})
+
+	rec := uploadTestFile(t, router, "client-a", "data.csv", []byte("a,b\n"))
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/download/"+rec["download_link"].(string), nil)
+	req.RemoteAddr = "198.51.100.7:4321"
+	router.ServeHTTP(w, req)
+
+	id := rec["id"].(string)
+	db.RecordDownload(h.Store, db.DownloadEvent{FileID: id, Time: clock.AddDate(0, 0, -45), Country: "unknown", Agent: "cli"})
+	db.AddAudit(h.Store, db.AuditEntry{Event: "policy", Time: clock.AddDate(0, 0, -100).Unix()})
+	db.AddAudit(h.Store, db.AuditEntry{Event: "policy", Time: clock.AddDate(0, 0, -10).Unix()})
+
+	if err := h.PruneAccessLogs(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	buckets, _ := db.GetAnalytics(h.Store, id, clock.AddDate(-1, 0, 0))
+	if len(buckets) != 1 || buckets[0].Addresses["198.51.100.0/24"] != 1 {
+		t.Errorf("expected only the recent download with its truncated address, got %+v", buckets)
+	}
+	if entries, _ := db.ListAudit(h.Store, "", 0); len(entries) != 1 {
+		t.Errorf("expected one audit entry within the retention, got %d", len(entries))
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/files/"+id+"/analytics?days=366", nil)
+	req.Header.Set("X-Client-ID", "client-a")
+	router.ServeHTTP(w, req)
+	var resp analyticsResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Downloads != 1 || resp.Addresses["198.51.100.0/24"] != 1 {
+		t.Errorf("expected the address in the analytics, got %s", w.Body.String())
+	}
+}
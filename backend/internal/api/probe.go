@@ -0,0 +1,132 @@
+package api
+
+import (
+	"crypto/rand"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultProbeBytes = 4 << 20
+	maxProbeBytes     = 64 << 20
+)
+
+// probeBlock is what download probes repeat. It is random so compressing
+// proxies can't shrink it, and large enough that they can't find the
+// repetition in their window either.
+var probeBlock = sync.OnceValue(func() []byte {
+	b := make([]byte, 1<<20)
+	rand.Read(b)
+	return b
+})
+
+type probeResponse struct {
+	Bytes      int64 `json:"bytes"`
+	DurationMS int64 `json:"duration_ms"`
+	BPS        int64 `json:"bps"`
+}
+
+// throughput is bytes per second, at least 1 for a transfer that took no
+// measurable time.
+func throughput(n int64, elapsed time.Duration) int64 {
+	if elapsed <= 0 {
+		return max(n, 1)
+	}
+	return max(int64(float64(n)/elapsed.Seconds()), 1)
+}
+
+// GetProbe returns the caller's last measured throughput and the probe
+// size limits.
+func (h *Handler) GetProbe(c *gin.Context) {
+	clientID := c.GetHeader("X-Client-ID")
+	if clientID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"default_bytes": defaultProbeBytes,
+		"max_bytes":     maxProbeBytes,
+		"result":        db.GetProbeResult(h.Store, clientID),
+	})
+}
+
+// ProbeDownload streams ?size= bytes (default 4 MiB, at most 64 MiB) of
+// throwaway data, for the caller to time. The server's own timing is kept
+// as the caller's download throughput once all of it is sent.
+func (h *Handler) ProbeDownload(c *gin.Context) {
+	clientID := c.GetHeader("X-Client-ID")
+	if clientID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
+		return
+	}
+	size, err := strconv.ParseInt(c.DefaultQuery("size", strconv.Itoa(defaultProbeBytes)), 10, 64)
+	if err != nil || size < 1 || size > maxProbeBytes {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "size must be between 1 and " + strconv.Itoa(maxProbeBytes)})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Content-Length", strconv.FormatInt(size, 10))
+	c.Status(http.StatusOK)
+	block := probeBlock()
+	start := time.Now()
+	for sent := int64(0); sent < size; {
+		n := min(size-sent, int64(len(block)))
+		if _, err := c.Writer.Write(block[:n]); err != nil {
+			return
+		}
+		sent += n
+	}
+	c.Writer.Flush()
+	if _, err := db.RecordProbe(h.Store, clientID, false, throughput(size, time.Since(start)), h.now().Unix()); err != nil {
+		log.Printf("[ERROR] Failed to record download probe of %s: %v", clientID, err)
+	}
+}
+
+// ProbeUpload reads and discards the request body, at most 64 MiB, and
+// reports how fast it arrived. The result is kept as the caller's upload
+// throughput.
+func (h *Handler) ProbeUpload(c *gin.Context) {
+	clientID := c.GetHeader("X-Client-ID")
+	if clientID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
+		return
+	}
+	if c.Request.ContentLength > maxProbeBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Probes are limited to " + strconv.Itoa(maxProbeBytes) + " bytes"})
+		return
+	}
+
+	start := time.Now()
+	n, err := io.Copy(io.Discard, io.LimitReader(c.Request.Body, maxProbeBytes+1))
+	elapsed := time.Since(start)
+	if err != nil {
+		if timedOut(c, c.Request.Context().Err()) {
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read probe"})
+		return
+	}
+	if n > maxProbeBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Probes are limited to " + strconv.Itoa(maxProbeBytes) + " bytes"})
+		return
+	}
+	if n == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The probe has no body"})
+		return
+	}
+
+	resp := probeResponse{Bytes: n, DurationMS: elapsed.Milliseconds(), BPS: throughput(n, elapsed)}
+	if _, err := db.RecordProbe(h.Store, clientID, true, resp.BPS, h.now().Unix()); err != nil {
+		log.Printf("[ERROR] Failed to record upload probe of %s: %v", clientID, err)
+	}
+	c.JSON(http.StatusOK, resp)
+}
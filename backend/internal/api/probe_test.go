@@ -0,0 +1,64 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestProbe(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	router := gin.Default()
+	router.GET("/probe", h.GetProbe)
+	router.GET("/probe/download", h.ProbeDownload)
+	router.POST("/probe/upload", h.ProbeUpload)
+
+	request := func(method, path string, body []byte) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewReader(body))
+		req.Header.Set("X-Client-ID", "client-a")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("GET", "/probe/download?size=3000000", nil)
+	if w.Code != http.StatusOK || w.Body.Len() != 3000000 || w.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("download probe: %d with %d bytes", w.Code, w.Body.Len())
+	}
+	if bytes.Equal(w.Body.Bytes()[:1000], make([]byte, 1000)) {
+		t.Error("download probe sends zeros, which compress")
+	}
+	if w := request("GET", "/probe/download?size=999999999", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("oversized download probe: got %d, want 400", w.Code)
+	}
+
+	w = request("POST", "/probe/upload", make([]byte, 1<<20))
+	var resp probeResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || resp.Bytes != 1<<20 || resp.BPS <= 0 {
+		t.Fatalf("upload probe: %d %s", w.Code, w.Body.String())
+	}
+	if w := request("POST", "/probe/upload", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("empty upload probe: got %d, want 400", w.Code)
+	}
+
+	result := db.GetProbeResult(h.Store, "client-a")
+	if result.UploadBPS != resp.BPS || result.DownloadBPS <= 0 || result.DownloadedAt == 0 {
+		t.Errorf("probes were not kept: %+v", result)
+	}
+	w = request("GET", "/probe", nil)
+	var summary struct {
+		Result db.ProbeResult `json:"result"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &summary)
+	if summary.Result != result {
+		t.Errorf("GET /probe = %+v, want %+v", summary.Result, result)
+	}
+}
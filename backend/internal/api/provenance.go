@@ -0,0 +1,150 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/provenance"
+	"github.com/celerix/depot/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+var trustedKeyName = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,63}$`)
+
+// maxSignatureBytes is far more than any supported signature takes.
+const maxSignatureBytes = 64 << 10
+
+// formSignature reads the detached signature of a form upload, sent as the
+// signature field or as a file in it. It returns nil when there is none.
+func formSignature(c *gin.Context) []byte {
+	if sig := c.PostForm("signature"); sig != "" {
+		return []byte(sig)
+	}
+	file, _, err := c.Request.FormFile("signature")
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	sig, _ := io.ReadAll(io.LimitReader(file, maxSignatureBytes))
+	if len(sig) == 0 {
+		return nil
+	}
+	return sig
+}
+
+// headerSignature reads the X-Signature header of a raw upload: a base64
+// signature or, for minisign, the signature line of the .minisig file.
+func headerSignature(c *gin.Context) []byte {
+	if sig := c.GetHeader("X-Signature"); sig != "" {
+		return []byte(sig)
+	}
+	return nil
+}
+
+// verifySignature checks a detached signature of the staged content against
+// the trusted keys and returns the key it was made with.
+func (h *Handler) verifySignature(path string, sig []byte) (*db.TrustedKey, error) {
+	if storage.IsS3Path(path) {
+		return nil, &ingestError{Status: http.StatusUnprocessableEntity, Message: "Signed files can't be uploaded directly to storage"}
+	}
+	if len(sig) > maxSignatureBytes {
+		return nil, &ingestError{Status: http.StatusBadRequest, Message: "Signature is too large"}
+	}
+	keys, err := db.ListTrustedKeys(h.Store)
+	if err != nil {
+		return nil, err
+	}
+	for i := range keys {
+		key, err := provenance.ParseKey(keys[i].PublicKey)
+		if err != nil {
+			continue
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		err = key.Verify(f, sig)
+		f.Close()
+		if err == nil {
+			return &keys[i], nil
+		}
+	}
+	return nil, &ingestError{Status: http.StatusUnprocessableEntity, Message: "Signature does not match any trusted key"}
+}
+
+// ListTrustedKeys returns the keys uploads can be signed with, so anyone
+// can check a "signed by" claim themselves.
+func (h *Handler) ListTrustedKeys(c *gin.Context) {
+	keys, err := db.ListTrustedKeys(h.Store)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list trusted keys"})
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+// AdminPutTrustedKey adds or replaces a trusted key: PEM (as used by cosign
+// and openssl) or a minisign public key.
+func (h *Handler) AdminPutTrustedKey(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	name := c.Param("name")
+	if !trustedKeyName.MatchString(name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Key names use lowercase letters, digits, '.', '-' and '_'"})
+		return
+	}
+	var input struct {
+		PublicKey   string `json:"public_key" binding:"required"`
+		Description string `json:"description"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	key, err := provenance.ParseKey(input.PublicKey)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	trusted := db.TrustedKey{
+		Name:        name,
+		Description: input.Description,
+		PublicKey:   input.PublicKey,
+		Algorithm:   key.Algorithm,
+		KeyID:       key.ID,
+		UpdatedAt:   time.Now().Unix(),
+	}
+	if err := db.SaveTrustedKey(h.Store, trusted); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save trusted key"})
+		return
+	}
+	c.JSON(http.StatusOK, trusted)
+}
+
+// AdminDeleteTrustedKey stops trusting a key. Files it signed keep their
+// mark, since they were verified when uploaded.
+func (h *Handler) AdminDeleteTrustedKey(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	name := c.Param("name")
+	if _, err := db.GetTrustedKey(h.Store, name); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Trusted key not found"})
+		return
+	}
+	if err := db.DeleteTrustedKey(h.Store, name); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete trusted key"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestSignedUploads(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.PUT("/upload/raw", h.UploadRaw)
+	router.PUT("/persona/folder-policy", h.PutFolderPolicy)
+	router.GET("/trusted-keys", h.ListTrustedKeys)
+	router.PUT("/admin/trusted-keys/:name", h.AdminPutTrustedKey)
+	db.UpsertClient(h.Store, "admin", "Admin", "CODEA", 0)
+	db.UpdateClientAdminStatus(h.Store, "admin", true)
+
+	// A cosign-style key pair, as `cosign sign-blob --key` uses
+	ciKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, _ := x509.MarshalPKIXPublicKey(&ciKey.PublicKey)
+	publicKey := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	sign := func(content []byte) string {
+		digest := sha256.Sum256(content)
+		sig, _ := ecdsa.SignASN1(rand.Reader, ciKey, digest[:])
+		return base64.StdEncoding.EncodeToString(sig)
+	}
+
+	putKey := func(clientID, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/admin/trusted-keys/release-ci", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Client-ID", clientID)
+		router.ServeHTTP(w, req)
+		return w
+	}
+	keyBody, _ := json.Marshal(map[string]string{"public_key": publicKey, "description": "Release pipeline"})
+	if w := putKey("user1", string(keyBody)); w.Code != http.StatusForbidden {
+		t.Errorf("expected non-admins to be refused, got %d", w.Code)
+	}
+	if w := putKey("admin", `{"public_key": "not a key"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected an invalid key to be refused, got %d", w.Code)
+	}
+	if w := putKey("admin", string(keyBody)); w.Code != http.StatusOK {
+		t.Fatalf("failed to add key: %s", w.Body.String())
+	}
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/trusted-keys", nil)
+	router.ServeHTTP(w, req)
+	var keys []db.TrustedKey
+	json.Unmarshal(w.Body.Bytes(), &keys)
+	if len(keys) != 1 || keys[0].Algorithm != "ecdsa" || keys[0].Name != "release-ci" {
+		t.Errorf("expected the key to be listed, got %s", w.Body.String())
+	}
+
+	rawUpload := func(query string, content []byte, signature string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/upload/raw"+query, bytes.NewReader(content))
+		req.Header.Set("X-Client-ID", "user1")
+		req.Header.Set("X-Filename", "build.tar.gz")
+		if signature != "" {
+			req.Header.Set("X-Signature", signature)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	content := []byte("build output")
+	w = rawUpload("", content, sign(content))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("signed upload failed: %s", w.Body.String())
+	}
+	var record db.FileRecord
+	json.Unmarshal(w.Body.Bytes(), &record)
+	if record.SignedBy != "release-ci" {
+		t.Errorf("expected the file to be signed by release-ci, got %q", record.SignedBy)
+	}
+	if w := rawUpload("", []byte("tampered output"), sign(content)); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected a mismatched signature to be rejected, got %d", w.Code)
+	}
+	w = rawUpload("", content, "")
+	var unsigned db.FileRecord
+	json.Unmarshal(w.Body.Bytes(), &unsigned)
+	if w.Code != http.StatusCreated || unsigned.SignedBy != "" {
+		t.Errorf("expected an unsigned upload to be accepted unmarked, got %d %q", w.Code, unsigned.SignedBy)
+	}
+
+	// A folder can insist on signed uploads
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/persona/folder-policy", bytes.NewBufferString(`{"folder": "releases", "require_signature": true}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Client-ID", "user1")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("failed to set folder policy: %s", w.Body.String())
+	}
+	if w := rawUpload("?folder=releases/v1", content, ""); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected an unsigned upload into the folder to be rejected, got %d", w.Code)
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "build.tar.gz")
+	part.Write(content)
+	writer.WriteField("folder", "releases/v1")
+	writer.WriteField("signature", sign(content))
+	writer.Close()
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("X-Client-ID", "user1")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("signed form upload into the folder failed: %s", w.Body.String())
+	}
+	var formRecord db.FileRecord
+	json.Unmarshal(w.Body.Bytes(), &formRecord)
+	if formRecord.SignedBy != "release-ci" {
+		t.Errorf("expected the form upload to be signed by release-ci, got %q", formRecord.SignedBy)
+	}
+}
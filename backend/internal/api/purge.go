@@ -0,0 +1,154 @@
+package api
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminPurgeClient erases a client for a right-to-be-forgotten request,
+// beyond what deleting the client does: their files and any content only
+// they referenced, their settings, share links, sites and usage records,
+// the analytics of their files and their sync journal. Audit entries are
+// kept but no longer name them. The body must repeat the client ID in
+// "confirm". The answer is a report signed with the instance key (see
+// GET /api/signing-key), which is also kept for GET /api/admin/purges.
+func (h *Handler) AdminPurgeClient(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	id := c.Param("id")
+	adminID := c.GetHeader("X-Client-ID")
+	if id == adminID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot purge yourself"})
+		return
+	}
+	var input struct {
+		Confirm string `json:"confirm" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Confirm != id {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "confirm must repeat the client ID"})
+		return
+	}
+	if _, err := db.GetClient(h.Store, id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+		return
+	}
+
+	files, err := db.ListOwnerFiles(h.Store, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list the client's files"})
+		return
+	}
+	// Retention obligations win over erasure, so nothing is touched while
+	// a file is still locked
+	for i := range files {
+		if h.refuseRetained(c, &files[i]) {
+			return
+		}
+	}
+
+	report := db.PurgeReport{ClientID: id, PurgedAt: h.now().Unix(), PurgedBy: adminID}
+	for i := range files {
+		f := &files[i]
+		if buckets, err := db.GetAnalytics(h.Store, f.ID, time.Unix(0, 0)); err == nil {
+			report.AnalyticsBuckets += len(buckets)
+		}
+		if f.SHA256 != "" && !f.External {
+			if blob, err := db.GetBlob(h.Store, f.SHA256); err == nil && blob.RefCount > 1 {
+				report.BlobsShared++
+			} else {
+				report.BlobsDeleted++
+			}
+		}
+		if err := h.removeFile(f); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete file " + f.ID, "files_done": report.Files})
+			return
+		}
+		report.Files++
+		report.Bytes += f.Size
+	}
+
+	steps := []struct {
+		name string
+		run  func() error
+	}{
+		{"records", func() (err error) { report.Records, err = db.RemoveClientRecords(h.Store, id); return }},
+		{"audit", func() (err error) { report.AuditAnonymized, err = db.AnonymizeAudit(h.Store, id); return }},
+		{"journal", func() (err error) { report.ChangesRemoved, err = db.RemoveClientChanges(h.Store, id); return }},
+		{"client", func() error { return db.DeleteClient(h.Store, id) }},
+	}
+	for _, step := range steps {
+		if err := step.run(); err != nil {
+			log.Printf("[ERROR] Purge of %s failed at %s: %v", id, step.name, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to purge the client's " + step.name + "; run the purge again to finish"})
+			return
+		}
+	}
+
+	signed, err := h.signPurgeReport(report)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "The client was purged but the report could not be signed"})
+		return
+	}
+	if err := db.SavePurgeReport(h.Store, *signed); err != nil {
+		log.Printf("[ERROR] Failed to keep the purge report of %s: %v", id, err)
+	}
+	c.JSON(http.StatusOK, signed)
+}
+
+func (h *Handler) signPurgeReport(report db.PurgeReport) (*db.SignedPurgeReport, error) {
+	key, err := db.SigningKey(h.Store)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+	return &db.SignedPurgeReport{
+		Report:    report,
+		Payload:   base64.StdEncoding.EncodeToString(payload),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, payload)),
+		PublicKey: base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)),
+	}, nil
+}
+
+func (h *Handler) AdminListPurges(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+	reports, err := db.ListPurgeReports(h.Store)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list purge reports"})
+		return
+	}
+	c.JSON(http.StatusOK, reports)
+}
+
+// GetSigningKey publishes the public half of the key the depot signs its
+// reports with, so third parties can check them.
+func (h *Handler) GetSigningKey(c *gin.Context) {
+	key, err := db.SigningKey(h.Store)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load the signing key"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"algorithm":  "ed25519",
+		"public_key": base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)),
+	})
+}
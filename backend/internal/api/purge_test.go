@@ -0,0 +1,133 @@
+package api
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestPurgeClient(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.GET("/download/:id", h.DownloadFile)
+	router.POST("/admin/clients/:id/purge", h.AdminPurgeClient)
+	router.GET("/admin/purges", h.AdminListPurges)
+	router.GET("/signing-key", h.GetSigningKey)
+	db.UpsertClient(h.Store, "admin", "Admin", "CODEA", 0)
+	db.UpdateClientAdminStatus(h.Store, "admin", true)
+	db.UpsertClient(h.Store, "leaver", "Leaver", "CODEL", 0)
+	db.UpsertClient(h.Store, "stayer", "Stayer", "CODES", 0)
+
+	own := uploadTestFile(t, router, "leaver", "diary.txt", []byte("only mine"))
+	uploadTestFile(t, router, "leaver", "shared.txt", []byte("common content"))
+	kept := uploadTestFile(t, router, "stayer", "copy.txt", []byte("common content"))
+	ownRecord, _ := db.GetFileRecord(h.Store, own["id"].(string))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/download/"+own["download_link"].(string), nil)
+	router.ServeHTTP(w, req)
+	db.SaveNotificationPrefs(h.Store, db.NotificationPrefs{OwnerID: "leaver", Email: "leaver@example.com", Events: db.NotificationEvents})
+	db.SaveNotificationPrefs(h.Store, db.NotificationPrefs{OwnerID: "stayer", Email: "stayer@example.com", Events: db.NotificationEvents})
+	db.SaveFolderPolicy(h.Store, db.FolderPolicy{OwnerID: "leaver", Folder: "private"})
+	db.SaveGuestToken(h.Store, db.GuestToken{ID: "leaver-token", OwnerID: "leaver", Access: db.GuestRead, Folder: "private"})
+	db.SaveGuestToken(h.Store, db.GuestToken{ID: "stayer-token", OwnerID: "stayer", Access: db.GuestRead, Folder: "private"})
+	db.AddAudit(h.Store, db.AuditEntry{Event: "policy", Rule: "no-exe", OwnerID: "leaver", Name: "diary.exe", IP: "192.0.2.0/24"})
+
+	purge := func(id, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/admin/clients/"+id+"/purge", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Client-ID", "admin")
+		router.ServeHTTP(w, req)
+		return w
+	}
+	if w := purge("leaver", `{"confirm": "stayer"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected a mismatched confirmation to be refused, got %d", w.Code)
+	}
+	w = purge("leaver", `{"confirm": "leaver"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("purge failed: %d %s", w.Code, w.Body.String())
+	}
+	var signed db.SignedPurgeReport
+	json.Unmarshal(w.Body.Bytes(), &signed)
+	r := signed.Report
+	if r.Files != 2 || r.BlobsDeleted != 1 || r.BlobsShared != 1 || r.AnalyticsBuckets != 1 || r.AuditAnonymized != 1 {
+		t.Errorf("unexpected report: %+v", r)
+	}
+	if r.Records["notifications"] != 1 || r.Records["folderpolicy"] != 1 || r.Records["usage"] != 1 || r.Records["guest"] != 1 || r.ChangesRemoved == 0 {
+		t.Errorf("expected the client's records to be counted, got %+v", r)
+	}
+
+	// The report verifies against the published key
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/signing-key", nil)
+	router.ServeHTTP(w, req)
+	var published struct {
+		PublicKey string `json:"public_key"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &published)
+	pub, _ := base64.StdEncoding.DecodeString(published.PublicKey)
+	payload, _ := base64.StdEncoding.DecodeString(signed.Payload)
+	sig, _ := base64.StdEncoding.DecodeString(signed.Signature)
+	if published.PublicKey != signed.PublicKey || !ed25519.Verify(pub, payload, sig) {
+		t.Error("expected the report signature to verify")
+	}
+	var fromPayload db.PurgeReport
+	json.Unmarshal(payload, &fromPayload)
+	if fromPayload.ClientID != "leaver" || fromPayload.Files != 2 {
+		t.Errorf("expected the payload to be the report, got %+v", fromPayload)
+	}
+
+	if _, err := db.GetClient(h.Store, "leaver"); err == nil {
+		t.Error("expected the client to be gone")
+	}
+	if files, _ := db.ListOwnerFiles(h.Store, "leaver"); len(files) != 0 {
+		t.Errorf("expected no files left, got %d", len(files))
+	}
+	if _, err := os.Stat(ownRecord.StoredPath); !os.IsNotExist(err) {
+		t.Error("expected the unshared content to be deleted")
+	}
+	if _, err := db.GetNotificationPrefs(h.Store, "stayer"); err != nil {
+		t.Error("expected other clients' settings to stay")
+	}
+	if tokens, _ := db.ListGuestTokens(h.Store, ""); len(tokens) != 1 || tokens[0].ID != "stayer-token" {
+		t.Errorf("expected only the other client's guest token, got %+v", tokens)
+	}
+	if usage, _ := db.ListUsage(h.Store, db.UsageMonth(time.Now())); len(usage) != 1 || usage[0].ClientID != "stayer" {
+		t.Errorf("expected only the other client's usage, got %+v", usage)
+	}
+	entries, _ := db.ListAudit(h.Store, "", 0)
+	if len(entries) != 1 || entries[0].OwnerID != "" || entries[0].Name != "" || entries[0].IP != "" || entries[0].Rule != "no-exe" {
+		t.Errorf("expected the audit entry anonymized but kept, got %+v", entries)
+	}
+
+	// Content shared with another client survives
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/download/"+kept["download_link"].(string), nil)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != "common content" {
+		t.Errorf("expected the other client's copy to download, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/admin/purges", nil)
+	req.Header.Set("X-Client-ID", "admin")
+	router.ServeHTTP(w, req)
+	var reports []db.SignedPurgeReport
+	json.Unmarshal(w.Body.Bytes(), &reports)
+	if len(reports) != 1 || reports[0].Signature != signed.Signature {
+		t.Errorf("expected the report to be kept, got %s", w.Body.String())
+	}
+}
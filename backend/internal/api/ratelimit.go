@@ -0,0 +1,97 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rateWindow is the window RequestsPerMinute counts in. Windows start on
+// the minute, so replicas sharing counters agree on them.
+const rateWindow = time.Minute
+
+// RateCounters counts requests per key in fixed windows. Without one a
+// Handler counts in the process; a shared one (Redis) makes the limit hold
+// across replicas.
+type RateCounters interface {
+	// Incr counts one more for key and returns the count so far, which
+	// starts over after window
+	Incr(key string, window time.Duration) (int64, error)
+}
+
+// localCounters counts in memory. The zero value is ready to use.
+type localCounters struct {
+	mu     sync.Mutex
+	counts map[string]localCount
+	swept  time.Time
+}
+
+type localCount struct {
+	n       int64
+	expires time.Time
+}
+
+func (l *localCounters) Incr(key string, window time.Duration) (int64, error) {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.counts == nil {
+		l.counts = make(map[string]localCount)
+	}
+	// Drop the windows that ended, at most once per window
+	if now.Sub(l.swept) > window {
+		for k, c := range l.counts {
+			if now.After(c.expires) {
+				delete(l.counts, k)
+			}
+		}
+		l.swept = now
+	}
+	c, ok := l.counts[key]
+	if !ok || now.After(c.expires) {
+		c = localCount{expires: now.Add(window)}
+	}
+	c.n++
+	l.counts[key] = c
+	return c.n, nil
+}
+
+// RateLimit turns away clients that sent more than RequestsPerMinute API
+// requests this minute with 429, counted per X-Client-ID or, without
+// one, per address. Admins are never limited. A counter that fails lets
+// the request through.
+func (h *Handler) RateLimit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := int64(h.requestsPerMinute())
+		if limit <= 0 {
+			c.Next()
+			return
+		}
+		who := c.GetHeader("X-Client-ID")
+		if who == "" {
+			who = c.ClientIP()
+		}
+		now := h.now()
+		window := now.Truncate(rateWindow)
+
+		counters := h.RateCounters
+		if counters == nil {
+			counters = &h.rates
+		}
+		n, err := counters.Incr("rate:"+who+":"+strconv.FormatInt(window.Unix(), 10), rateWindow)
+		if err != nil {
+			log.Printf("[ERROR] Failed to count requests of %s: %v", who, err)
+		}
+		if n > limit && !h.isAdmin(c) {
+			retry := max(int(window.Add(rateWindow).Sub(now).Seconds()), 1)
+			c.Header("Retry-After", strconv.Itoa(retry))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests, try again later"})
+			return
+		}
+		c.Next()
+	}
+}
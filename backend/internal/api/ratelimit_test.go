@@ -0,0 +1,55 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateLimit(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+	h.RequestsPerMinute = 2
+	clock := time.Date(2026, 1, 1, 12, 0, 30, 0, time.UTC)
+	h.Now = func() time.Time { return clock }
+	db.UpsertClient(h.Store, "admin", "Admin", "", 0)
+	db.UpdateClientAdminStatus(h.Store, "admin", true)
+
+	router := gin.New()
+	router.Use(h.RateLimit())
+	router.GET("/api/files", func(c *gin.Context) { c.Status(http.StatusOK) })
+	request := func(clientID string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/files", nil)
+		req.Header.Set("X-Client-ID", clientID)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := request("client-a"); w.Code != http.StatusOK {
+			t.Fatalf("request %d: %d", i+1, w.Code)
+		}
+	}
+	w := request("client-a")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "30" {
+		t.Errorf("over the limit: %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w := request("client-b"); w.Code != http.StatusOK {
+		t.Errorf("another client was limited: %d", w.Code)
+	}
+	for i := 0; i < 3; i++ {
+		if w := request("admin"); w.Code != http.StatusOK {
+			t.Errorf("admin was limited: %d", w.Code)
+		}
+	}
+
+	clock = clock.Add(time.Minute)
+	if w := request("client-a"); w.Code != http.StatusOK {
+		t.Errorf("limited in the next minute: %d", w.Code)
+	}
+}
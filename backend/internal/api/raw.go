@@ -0,0 +1,138 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// preferredExtensions picks the usual extension where the mime package knows
+// several, e.g. image/jpeg.
+var preferredExtensions = map[string]string{
+	"image/jpeg":       ".jpg",
+	"image/png":        ".png",
+	"image/gif":        ".gif",
+	"image/webp":       ".webp",
+	"text/plain":       ".txt",
+	"application/json": ".json",
+	"application/pdf":  ".pdf",
+	"video/mp4":        ".mp4",
+}
+
+// rawUploadName works out a file name for a raw upload from the X-Filename
+// header, the Content-Disposition header or the name query parameter, and
+// adds an extension from the Content-Type when the name has none.
+func rawUploadName(c *gin.Context) string {
+	name := c.GetHeader("X-Filename")
+	if name == "" {
+		if _, params, err := mime.ParseMediaType(c.GetHeader("Content-Disposition")); err == nil {
+			name = params["filename"]
+		}
+	}
+	if name == "" {
+		name = c.Query("name")
+	}
+	name = filepath.Base(strings.ReplaceAll(name, `\`, "/"))
+	if name == "" || name == "." || name == "/" {
+		name = "paste-" + time.Now().UTC().Format("20060102-150405")
+	}
+
+	if filepath.Ext(name) == "" {
+		mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+		if ext, ok := preferredExtensions[mediaType]; ok {
+			name += ext
+		} else if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+			name += exts[0]
+		}
+	}
+	return name
+}
+
+// UploadRaw stores the request body as a file, so a single
+// `curl --data-binary` or screenshot tool request is enough.
+func (h *Handler) UploadRaw(c *gin.Context) {
+	ownerID := c.GetHeader("X-Client-ID")
+	if ownerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
+		return
+	}
+
+	publishAt, err := parseTimestamp("publish_at", c.Query("publish_at"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !validOverwrite(c.Query("overwrite")) {
+		respondIngestError(c, errInvalidOverwrite)
+		return
+	}
+
+	body := c.Request.Body
+	if limit := db.GetSettings(h.Store).MaxUploadBytes; limit > 0 {
+		body = http.MaxBytesReader(c.Writer, body, limit)
+	}
+
+	id := uuid.New().String()
+	storedPath, size, hash, err := storage.StoreFile(storage.ContextReader(c.Request.Context(), body), h.TempDir, h.StorageDir, id)
+	if err != nil {
+		if timedOut(c, err) {
+			return
+		}
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File exceeds the maximum upload size"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file: " + err.Error()})
+		return
+	}
+	if size == 0 {
+		storage.DeleteFile(storedPath)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request body is empty"})
+		return
+	}
+
+	isPublic := c.Query("is_public") == "true" || c.GetHeader("X-Public") == "true"
+	record, err := h.ingest(c.Request.Context(), stagedFile{ID: id, Path: storedPath, Size: size, SHA256: hash}, ingestOptions{
+		OwnerID:       ownerID,
+		RemoteAddr:    c.ClientIP(),
+		Name:          rawUploadName(c),
+		IsPublic:      isPublic,
+		IsAdmin:       h.isAdmin(c),
+		StripMetadata: h.stripMetadataQuery(c),
+		Folder:        db.NormalizeFolder(c.Query("folder")),
+		Tags:          db.ParseTags(c.Query("tags")),
+		PublishAt:     publishAt,
+		Password:      c.GetHeader("X-Link-Password"),
+		Preset:        c.Query("preset"),
+		Signature:     headerSignature(c),
+		Overwrite:     c.Query("overwrite"),
+	})
+	if err != nil {
+		respondIngestError(c, err)
+		return
+	}
+
+	c.Header("Location", fmt.Sprintf("%s/api/download/%s", h.baseURL(c), record.DownloadLink))
+	c.JSON(http.StatusCreated, record)
+}
+
+// stripMetadataQuery is stripMetadataOption for requests without a form.
+func (h *Handler) stripMetadataQuery(c *gin.Context) bool {
+	switch c.Query("strip_metadata") {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	return h.StripMetadata
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestUploadRaw(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	router := gin.Default()
+	router.PUT("/upload/raw", h.UploadRaw)
+
+	put := func(body []byte, headers map[string]string, query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/upload/raw"+query, bytes.NewReader(body))
+		req.Header.Set("X-Client-ID", "client-a")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		query   string
+		want    string
+	}{
+		{"header name", map[string]string{"X-Filename": "notes.md"}, "", "notes.md"},
+		{"content disposition", map[string]string{"Content-Disposition": `attachment; filename="report.pdf"`}, "", "report.pdf"},
+		{"query name with type", map[string]string{"Content-Type": "image/jpeg"}, "?name=shot", "shot.jpg"},
+		{"path is stripped", map[string]string{"X-Filename": "../../etc/passwd"}, "", "passwd"},
+		{"bidi override is dropped", nil, "?name=invoice%E2%80%AEfdp.exe", "invoicefdp.exe"},
+		{"name is normalized", nil, "?name=cafe%CC%81.txt", "caf\u00e9.txt"},
+	}
+	for _, tt := range tests {
+		w := put([]byte("raw bytes"), tt.headers, tt.query)
+		if w.Code != http.StatusCreated {
+			t.Errorf("%s: upload failed %d %s", tt.name, w.Code, w.Body.String())
+			continue
+		}
+		var record db.FileRecord
+		json.Unmarshal(w.Body.Bytes(), &record)
+		if record.OriginalName != tt.want {
+			t.Errorf("%s: got name %q, want %q", tt.name, record.OriginalName, tt.want)
+		}
+		if !strings.HasSuffix(w.Header().Get("Location"), "/api/download/"+record.DownloadLink) {
+			t.Errorf("%s: unexpected Location %q", tt.name, w.Header().Get("Location"))
+		}
+	}
+
+	// The name as sent is kept next to the sanitized one
+	sent := put([]byte("raw bytes"), nil, "?name=a%0Ab.txt")
+	var sanitized db.FileRecord
+	json.Unmarshal(sent.Body.Bytes(), &sanitized)
+	if sanitized.OriginalName != "ab.txt" || sanitized.RawName != "a\nb.txt" {
+		t.Errorf("expected sanitized name with raw name kept, got %q and %q", sanitized.OriginalName, sanitized.RawName)
+	}
+
+	// Screenshots without a name get a timestamped one
+	w := put([]byte("png"), map[string]string{"Content-Type": "image/png"}, "")
+	var record db.FileRecord
+	json.Unmarshal(w.Body.Bytes(), &record)
+	if !strings.HasPrefix(record.OriginalName, "paste-") || !strings.HasSuffix(record.OriginalName, ".png") {
+		t.Errorf("unexpected generated name %q", record.OriginalName)
+	}
+
+	if w := put(nil, nil, ""); w.Code != http.StatusBadRequest {
+		t.Errorf("expected empty body to be rejected, got %d", w.Code)
+	}
+
+	db.SaveSettings(h.Store, db.Settings{MaxUploadBytes: 4})
+	if w := put([]byte("too many bytes"), nil, ""); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected oversized body to be rejected, got %d", w.Code)
+	}
+}
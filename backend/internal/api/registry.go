@@ -0,0 +1,484 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// registryUploadDir holds blob uploads in progress, below the storage dir
+	registryUploadDir = ".oci-uploads"
+	maxManifestSize   = 4 << 20
+	defaultManifest   = "application/vnd.oci.image.manifest.v1+json"
+)
+
+var (
+	registryRepo   = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*$`)
+	registryTag    = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9._-]{0,127}$`)
+	registryDigest = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+)
+
+func registryError(c *gin.Context, status int, code, message string) {
+	c.JSON(status, gin.H{"errors": []gin.H{{"code": code, "message": message}}})
+}
+
+// Registry serves a minimal OCI distribution API under /v2/, so images and
+// ORAS artifacts can be pushed and pulled. Layers and manifests are stored
+// as ordinary deduplicated blobs. Pulls are anonymous; pushes authenticate
+// with HTTP basic auth using a client ID as the password.
+func (h *Handler) Registry(c *gin.Context) {
+	c.Header("Docker-Distribution-API-Version", "registry/2.0")
+	method := c.Request.Method
+
+	path := strings.Trim(c.Param("path"), "/")
+	if path == "" {
+		if method != http.MethodGet && method != http.MethodHead {
+			registryError(c, http.StatusMethodNotAllowed, "UNSUPPORTED", "Method not allowed")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{})
+		return
+	}
+
+	repo, kind, ref := splitRegistryPath(path)
+	if kind == "" {
+		registryError(c, http.StatusNotFound, "NAME_UNKNOWN", "Unknown registry endpoint")
+		return
+	}
+	if !registryRepo.MatchString(repo) {
+		registryError(c, http.StatusBadRequest, "NAME_INVALID", "Invalid repository name")
+		return
+	}
+
+	clientID := ""
+	if method != http.MethodGet && method != http.MethodHead {
+		var ok bool
+		if clientID, ok = h.registryClient(c); !ok {
+			return
+		}
+	}
+
+	switch {
+	case kind == "tags" && method == http.MethodGet:
+		h.registryTags(c, repo)
+	case kind == "blobs" && (method == http.MethodGet || method == http.MethodHead):
+		h.registryGetBlob(c, ref)
+	case kind == "uploads" && method == http.MethodPost && ref == "":
+		h.registryStartUpload(c, repo, clientID)
+	case kind == "uploads" && method == http.MethodGet && ref != "":
+		h.registryUploadStatus(c, repo, ref)
+	case kind == "uploads" && method == http.MethodPatch && ref != "":
+		h.registryPatchUpload(c, repo, ref)
+	case kind == "uploads" && method == http.MethodPut && ref != "":
+		h.registryFinishUpload(c, repo, ref, c.Query("digest"), clientID)
+	case kind == "uploads" && method == http.MethodDelete && ref != "":
+		h.registryCancelUpload(c, ref)
+	case kind == "manifests" && (method == http.MethodGet || method == http.MethodHead):
+		h.registryGetManifest(c, repo, ref)
+	case kind == "manifests" && method == http.MethodPut:
+		h.registryPutManifest(c, repo, ref, clientID)
+	default:
+		registryError(c, http.StatusMethodNotAllowed, "UNSUPPORTED", "Operation is not supported")
+	}
+}
+
+// splitRegistryPath splits a /v2/ path into repository, endpoint kind and
+// reference. Repository names may contain slashes, so the endpoint is found
+// from the end.
+func splitRegistryPath(path string) (repo, kind, ref string) {
+	if repo, ok := strings.CutSuffix(path, "/tags/list"); ok {
+		return repo, "tags", ""
+	}
+	if i := strings.LastIndex(path, "/blobs/uploads"); i > 0 {
+		rest := path[i+len("/blobs/uploads"):]
+		if rest == "" || rest[0] == '/' {
+			return path[:i], "uploads", strings.TrimPrefix(rest, "/")
+		}
+	}
+	if i := strings.LastIndex(path, "/blobs/"); i > 0 {
+		return path[:i], "blobs", path[i+len("/blobs/"):]
+	}
+	if i := strings.LastIndex(path, "/manifests/"); i > 0 {
+		return path[:i], "manifests", path[i+len("/manifests/"):]
+	}
+	return "", "", ""
+}
+
+// registryClient authenticates a push. Docker and ORAS only speak basic
+// auth, so the client ID is taken from the password; X-Client-ID works too.
+func (h *Handler) registryClient(c *gin.Context) (string, bool) {
+	clientID := c.GetHeader("X-Client-ID")
+	if _, password, ok := c.Request.BasicAuth(); ok {
+		clientID = password
+	}
+	if clientID != "" {
+		if _, err := db.GetClient(h.Store, clientID); err == nil {
+			return clientID, true
+		}
+	}
+	c.Header("WWW-Authenticate", `Basic realm="Celerix Depot"`)
+	registryError(c, http.StatusUnauthorized, "UNAUTHORIZED", "Push requires a client ID as password")
+	return "", false
+}
+
+func (h *Handler) registryTags(c *gin.Context, repo string) {
+	tags, err := db.ListRegistryTags(h.Store, repo)
+	if err != nil {
+		registryError(c, http.StatusInternalServerError, "UNKNOWN", "Failed to list tags")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"name": repo, "tags": tags})
+}
+
+func (h *Handler) registryGetBlob(c *gin.Context, digest string) {
+	if !registryDigest.MatchString(digest) {
+		registryError(c, http.StatusBadRequest, "DIGEST_INVALID", "Only sha256 digests are supported")
+		return
+	}
+	if _, err := db.GetRegistryBlob(h.Store, digest); err != nil {
+		registryError(c, http.StatusNotFound, "BLOB_UNKNOWN", "Blob not found")
+		return
+	}
+	h.serveRegistryContent(c, digest, "application/octet-stream")
+}
+
+func (h *Handler) serveRegistryContent(c *gin.Context, digest, contentType string) {
+	blob, err := db.GetBlob(h.Store, strings.TrimPrefix(digest, "sha256:"))
+	if err != nil {
+		registryError(c, http.StatusNotFound, "BLOB_UNKNOWN", "Blob content is missing")
+		return
+	}
+	c.Header("Docker-Content-Digest", digest)
+	c.Header("ETag", `"`+digest+`"`)
+	if storage.IsS3Path(blob.StoredPath) {
+		h.redirectToS3(c, &db.FileRecord{StoredPath: blob.StoredPath, OriginalName: digest})
+		return
+	}
+	c.Header("Content-Type", contentType)
+	c.File(blob.StoredPath)
+}
+
+func (h *Handler) registryUploadPath(id string) (string, bool) {
+	if _, err := uuid.Parse(id); err != nil {
+		return "", false
+	}
+	return filepath.Join(h.tempDir(), registryUploadDir, id), true
+}
+
+func registryUploadHeaders(c *gin.Context, repo, id string, size int64) {
+	c.Header("Location", fmt.Sprintf("/v2/%s/blobs/uploads/%s", repo, id))
+	c.Header("Docker-Upload-UUID", id)
+	if size > 0 {
+		c.Header("Range", fmt.Sprintf("0-%d", size-1))
+	} else {
+		c.Header("Range", "0-0")
+	}
+}
+
+func (h *Handler) registryStartUpload(c *gin.Context, repo, clientID string) {
+	// Blobs are shared by all repositories, so a cross-repository mount
+	// succeeds whenever the blob is known
+	if mount := c.Query("mount"); mount != "" {
+		if _, err := db.GetRegistryBlob(h.Store, mount); err == nil {
+			c.Header("Location", fmt.Sprintf("/v2/%s/blobs/%s", repo, mount))
+			c.Header("Docker-Content-Digest", mount)
+			c.Status(http.StatusCreated)
+			return
+		}
+	}
+
+	id := uuid.New().String()
+	path, _ := h.registryUploadPath(id)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		registryError(c, http.StatusInternalServerError, "UNKNOWN", "Failed to start upload")
+		return
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		registryError(c, http.StatusInternalServerError, "UNKNOWN", "Failed to start upload")
+		return
+	}
+	f.Close()
+
+	// Monolithic upload in a single request
+	if digest := c.Query("digest"); digest != "" {
+		h.registryFinishUpload(c, repo, id, digest, clientID)
+		return
+	}
+	registryUploadHeaders(c, repo, id, 0)
+	c.Status(http.StatusAccepted)
+}
+
+func (h *Handler) registryUploadStatus(c *gin.Context, repo, id string) {
+	path, ok := h.registryUploadPath(id)
+	info, err := os.Stat(path)
+	if !ok || err != nil {
+		registryError(c, http.StatusNotFound, "BLOB_UPLOAD_UNKNOWN", "Upload not found")
+		return
+	}
+	registryUploadHeaders(c, repo, id, info.Size())
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) registryPatchUpload(c *gin.Context, repo, id string) {
+	size, ok := h.appendRegistryUpload(c, id)
+	if !ok {
+		return
+	}
+	registryUploadHeaders(c, repo, id, size)
+	c.Status(http.StatusAccepted)
+}
+
+// appendRegistryUpload appends the request body to an upload in progress
+// and returns its new size.
+func (h *Handler) appendRegistryUpload(c *gin.Context, id string) (int64, bool) {
+	path, ok := h.registryUploadPath(id)
+	if !ok {
+		registryError(c, http.StatusNotFound, "BLOB_UPLOAD_UNKNOWN", "Upload not found")
+		return 0, false
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		registryError(c, http.StatusNotFound, "BLOB_UPLOAD_UNKNOWN", "Upload not found")
+		return 0, false
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		registryError(c, http.StatusInternalServerError, "UNKNOWN", "Failed to read upload")
+		return 0, false
+	}
+
+	body := c.Request.Body
+	if limit := db.GetSettings(h.Store).MaxUploadBytes; limit > 0 {
+		body = http.MaxBytesReader(c.Writer, body, max(limit-info.Size(), 0))
+	}
+	n, err := io.Copy(f, body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			f.Close()
+			os.Remove(path)
+			registryError(c, http.StatusRequestEntityTooLarge, "SIZE_INVALID", "Blob exceeds the maximum upload size")
+			return 0, false
+		}
+		registryError(c, http.StatusInternalServerError, "UNKNOWN", "Failed to write upload")
+		return 0, false
+	}
+	return info.Size() + n, true
+}
+
+func (h *Handler) registryFinishUpload(c *gin.Context, repo, id, digest, clientID string) {
+	if _, ok := h.appendRegistryUpload(c, id); !ok {
+		return
+	}
+	path, _ := h.registryUploadPath(id)
+	if !registryDigest.MatchString(digest) {
+		os.Remove(path)
+		registryError(c, http.StatusBadRequest, "DIGEST_INVALID", "Only sha256 digests are supported")
+		return
+	}
+
+	size, hash, err := storage.HashFile(path)
+	if err != nil {
+		registryError(c, http.StatusInternalServerError, "UNKNOWN", "Failed to hash upload")
+		return
+	}
+	if "sha256:"+hash != digest {
+		os.Remove(path)
+		registryError(c, http.StatusBadRequest, "DIGEST_INVALID", "Content does not match digest")
+		return
+	}
+	if err := h.enforceQuota(clientID, stagedFile{Size: size, SHA256: hash}, db.GetSettings(h.Store)); err != nil {
+		os.Remove(path)
+		registryError(c, http.StatusRequestEntityTooLarge, "DENIED", err.Error())
+		return
+	}
+
+	if err := h.storeRegistryBlob(path, size, hash); err != nil {
+		registryError(c, http.StatusInternalServerError, "UNKNOWN", "Failed to store blob: "+err.Error())
+		return
+	}
+
+	c.Header("Location", fmt.Sprintf("/v2/%s/blobs/%s", repo, digest))
+	c.Header("Docker-Content-Digest", digest)
+	c.Status(http.StatusCreated)
+}
+
+func (h *Handler) registryCancelUpload(c *gin.Context, id string) {
+	path, ok := h.registryUploadPath(id)
+	if !ok || os.Remove(path) != nil {
+		registryError(c, http.StatusNotFound, "BLOB_UPLOAD_UNKNOWN", "Upload not found")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// storeRegistryBlob moves verified content from the upload area into
+// storage. The registry keeps a single reference on each depot blob, however
+// many repositories use it.
+func (h *Handler) storeRegistryBlob(stagedPath string, size int64, hash string) error {
+	h.registryMu.Lock()
+	defer h.registryMu.Unlock()
+
+	if _, err := db.GetRegistryBlob(h.Store, "sha256:"+hash); err == nil {
+		return os.Remove(stagedPath)
+	}
+
+	storedPath := filepath.Join(h.StorageDir, uuid.New().String())
+	if err := storage.MoveFile(stagedPath, storedPath); err != nil {
+		return err
+	}
+	if _, err := h.dedupBlob(context.Background(), storedPath, size, hash); err != nil {
+		storage.DeleteFile(storedPath)
+		return err
+	}
+	return db.SaveRegistryBlob(h.Store, db.RegistryBlob{Digest: "sha256:" + hash, Size: size})
+}
+
+// resolveManifest turns a tag or digest reference into a digest.
+func (h *Handler) resolveManifest(repo, ref string) (string, bool) {
+	if registryDigest.MatchString(ref) {
+		return ref, true
+	}
+	tag, err := db.GetRegistryTag(h.Store, repo, ref)
+	if err != nil {
+		return "", false
+	}
+	return tag.Digest, true
+}
+
+func (h *Handler) registryGetManifest(c *gin.Context, repo, ref string) {
+	digest, ok := h.resolveManifest(repo, ref)
+	if !ok {
+		registryError(c, http.StatusNotFound, "MANIFEST_UNKNOWN", "Manifest not found")
+		return
+	}
+	manifest, err := db.GetRegistryManifest(h.Store, repo, digest)
+	if err != nil {
+		registryError(c, http.StatusNotFound, "MANIFEST_UNKNOWN", "Manifest not found")
+		return
+	}
+	h.serveRegistryContent(c, digest, manifest.MediaType)
+}
+
+func (h *Handler) registryPutManifest(c *gin.Context, repo, ref, clientID string) {
+	isDigest := registryDigest.MatchString(ref)
+	if !isDigest && !registryTag.MatchString(ref) {
+		registryError(c, http.StatusBadRequest, "TAG_INVALID", "Invalid tag")
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxManifestSize+1))
+	if err != nil {
+		registryError(c, http.StatusBadRequest, "MANIFEST_INVALID", "Failed to read manifest")
+		return
+	}
+	if len(body) > maxManifestSize {
+		registryError(c, http.StatusRequestEntityTooLarge, "MANIFEST_INVALID", "Manifest is too large")
+		return
+	}
+
+	var parsed struct {
+		MediaType string `json:"mediaType"`
+		Config    *struct {
+			Digest string `json:"digest"`
+		} `json:"config"`
+		Layers []struct {
+			Digest string `json:"digest"`
+		} `json:"layers"`
+		Manifests []struct {
+			Digest string `json:"digest"`
+		} `json:"manifests"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		registryError(c, http.StatusBadRequest, "MANIFEST_INVALID", "Manifest is not valid JSON")
+		return
+	}
+
+	// Everything the manifest points at must already be pushed
+	var blobs []string
+	if parsed.Config != nil {
+		blobs = append(blobs, parsed.Config.Digest)
+	}
+	for _, l := range parsed.Layers {
+		blobs = append(blobs, l.Digest)
+	}
+	for _, d := range blobs {
+		if _, err := db.GetRegistryBlob(h.Store, d); err != nil {
+			registryError(c, http.StatusBadRequest, "MANIFEST_BLOB_UNKNOWN", "Unknown blob "+d)
+			return
+		}
+	}
+	for _, m := range parsed.Manifests {
+		if _, err := db.GetRegistryManifest(h.Store, repo, m.Digest); err != nil {
+			registryError(c, http.StatusBadRequest, "MANIFEST_BLOB_UNKNOWN", "Unknown manifest "+m.Digest)
+			return
+		}
+	}
+
+	id := uuid.New().String()
+	stagedPath, size, hash, err := storage.StoreFile(bytes.NewReader(body), "", filepath.Join(h.tempDir(), registryUploadDir), id)
+	if err != nil {
+		registryError(c, http.StatusInternalServerError, "UNKNOWN", "Failed to store manifest")
+		return
+	}
+	digest := "sha256:" + hash
+	if isDigest && ref != digest {
+		os.Remove(stagedPath)
+		registryError(c, http.StatusBadRequest, "DIGEST_INVALID", "Manifest does not match digest")
+		return
+	}
+	if err := h.enforceQuota(clientID, stagedFile{Size: size, SHA256: hash}, db.GetSettings(h.Store)); err != nil {
+		os.Remove(stagedPath)
+		registryError(c, http.StatusRequestEntityTooLarge, "DENIED", err.Error())
+		return
+	}
+	if err := h.storeRegistryBlob(stagedPath, size, hash); err != nil {
+		registryError(c, http.StatusInternalServerError, "UNKNOWN", "Failed to store manifest: "+err.Error())
+		return
+	}
+
+	mediaType := c.ContentType()
+	if mediaType == "" {
+		mediaType = parsed.MediaType
+	}
+	if mediaType == "" {
+		mediaType = defaultManifest
+	}
+	err = db.SaveRegistryManifest(h.Store, db.RegistryManifest{
+		Repository: repo,
+		Digest:     digest,
+		MediaType:  mediaType,
+		Size:       size,
+		PushedBy:   clientID,
+		PushedAt:   time.Now().Unix(),
+	})
+	if err == nil && !isDigest {
+		err = db.SaveRegistryTag(h.Store, db.RegistryTag{Repository: repo, Tag: ref, Digest: digest})
+	}
+	if err != nil {
+		registryError(c, http.StatusInternalServerError, "UNKNOWN", "Failed to save manifest")
+		return
+	}
+
+	c.Header("Location", fmt.Sprintf("/v2/%s/manifests/%s", repo, digest))
+	c.Header("Docker-Content-Digest", digest)
+	c.Status(http.StatusCreated)
+}
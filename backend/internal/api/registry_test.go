@@ -0,0 +1,111 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func digestOf(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func TestRegistryPushPull(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+	db.UpsertClient(h.Store, "ci", "CI", "CICICICI", 0)
+
+	router := gin.Default()
+	for _, m := range []string{"GET", "HEAD", "POST", "PATCH", "PUT", "DELETE"} {
+		router.Handle(m, "/v2/*path", h.Registry)
+	}
+	do := func(method, url string, body []byte, auth bool) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, url, bytes.NewReader(body))
+		if auth {
+			req.SetBasicAuth("ci", "ci")
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("GET", "/v2/", nil, false); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 from base endpoint, got %d", w.Code)
+	}
+	if w := do("POST", "/v2/team/app/blobs/uploads/", nil, false); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected anonymous push to be refused, got %d", w.Code)
+	}
+
+	// Config blob in one request
+	config := []byte(`{"architecture":"amd64"}`)
+	w := do("POST", "/v2/team/app/blobs/uploads/?digest="+digestOf(config), config, true)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("monolithic upload failed: %d %s", w.Code, w.Body.String())
+	}
+
+	// Layer in two chunks
+	layer := []byte("layer content in two chunks")
+	w = do("POST", "/v2/team/app/blobs/uploads/", nil, true)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("start upload failed: %d %s", w.Code, w.Body.String())
+	}
+	location := w.Header().Get("Location")
+	if w = do("PATCH", location, layer[:10], true); w.Code != http.StatusAccepted || w.Header().Get("Range") != "0-9" {
+		t.Fatalf("patch failed: %d range %q", w.Code, w.Header().Get("Range"))
+	}
+	if w = do("PUT", location+"?digest="+digestOf([]byte("wrong")), layer[10:], true); w.Code != http.StatusBadRequest {
+		t.Errorf("expected digest mismatch to fail, got %d", w.Code)
+	}
+
+	w = do("POST", "/v2/team/app/blobs/uploads/", nil, true)
+	location = w.Header().Get("Location")
+	do("PATCH", location, layer[:10], true)
+	if w = do("PUT", location+"?digest="+digestOf(layer), layer[10:], true); w.Code != http.StatusCreated {
+		t.Fatalf("finish upload failed: %d %s", w.Code, w.Body.String())
+	}
+
+	manifest := []byte(fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json",`+
+		`"config":{"digest":"%s"},"layers":[{"digest":"%s"}]}`, digestOf(config), digestOf(layer)))
+	missing := []byte(fmt.Sprintf(`{"schemaVersion":2,"layers":[{"digest":"%s"}]}`, digestOf([]byte("nope"))))
+	if w = do("PUT", "/v2/team/app/manifests/v1", missing, true); w.Code != http.StatusBadRequest {
+		t.Errorf("expected manifest with unknown blob to fail, got %d", w.Code)
+	}
+	if w = do("PUT", "/v2/team/app/manifests/v1", manifest, true); w.Code != http.StatusCreated {
+		t.Fatalf("manifest push failed: %d %s", w.Code, w.Body.String())
+	}
+
+	w = do("GET", "/v2/team/app/manifests/v1", nil, false)
+	if w.Code != http.StatusOK || w.Body.String() != string(manifest) {
+		t.Fatalf("manifest pull failed: %d %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Docker-Content-Digest"); got != digestOf(manifest) {
+		t.Errorf("expected digest header %s, got %s", digestOf(manifest), got)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/vnd.oci.image.manifest.v1+json" {
+		t.Errorf("unexpected manifest content type %q", ct)
+	}
+
+	w = do("GET", "/v2/team/app/blobs/"+digestOf(layer), nil, false)
+	if w.Code != http.StatusOK || w.Body.String() != string(layer) {
+		t.Errorf("blob pull failed: %d %q", w.Code, w.Body.String())
+	}
+
+	w = do("GET", "/v2/team/app/tags/list", nil, false)
+	if w.Body.String() != `{"name":"team/app","tags":["v1"]}` {
+		t.Errorf("unexpected tag list: %s", w.Body.String())
+	}
+
+	// Layers are shared blobs; mounting into another repository is free
+	w = do("POST", "/v2/other/blobs/uploads/?mount="+digestOf(layer)+"&from=team/app", nil, true)
+	if w.Code != http.StatusCreated {
+		t.Errorf("expected cross-repository mount, got %d", w.Code)
+	}
+}
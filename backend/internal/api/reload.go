@@ -0,0 +1,80 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/celerix/depot/internal/policy"
+	"github.com/gin-gonic/gin"
+)
+
+// Reloadable is the part of a handler's configuration that can change
+// while it serves requests.
+type Reloadable struct {
+	Policy           *policy.Engine
+	AdminWebhookURL  string
+	RequestTimeout   time.Duration
+	TransferTimeout  time.Duration
+	EndpointTimeouts map[string]time.Duration
+	MaxBodyBytes     int64
+	// MaxTransfers and SmallTransferBytes resize Transfers, if set
+	RequestsPerMinute  int
+	MaxTransfers       int
+	SmallTransferBytes int64
+}
+
+// Reload swaps in new settings. Requests in flight keep the policy and
+// deadline they started with.
+func (h *Handler) Reload(r Reloadable) {
+	h.configMu.Lock()
+	defer h.configMu.Unlock()
+	h.Policy = r.Policy
+	h.AdminWebhookURL = r.AdminWebhookURL
+	h.RequestTimeout = r.RequestTimeout
+	h.TransferTimeout = r.TransferTimeout
+	h.EndpointTimeouts = r.EndpointTimeouts
+	h.MaxBodyBytes = r.MaxBodyBytes
+	h.RequestsPerMinute = r.RequestsPerMinute
+	if h.Transfers != nil {
+		h.Transfers.Resize(r.MaxTransfers, r.SmallTransferBytes)
+	}
+}
+
+func (h *Handler) policy() *policy.Engine {
+	h.configMu.RLock()
+	defer h.configMu.RUnlock()
+	return h.Policy
+}
+
+func (h *Handler) requestsPerMinute() int {
+	h.configMu.RLock()
+	defer h.configMu.RUnlock()
+	return h.RequestsPerMinute
+}
+
+func (h *Handler) adminWebhookURL() string {
+	h.configMu.RLock()
+	defer h.configMu.RUnlock()
+	return h.AdminWebhookURL
+}
+
+// AdminReloadConfig re-reads the configuration through ReloadConfig. A
+// configuration that fails to load leaves the running one in place.
+func (h *Handler) AdminReloadConfig(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+	if h.ReloadConfig == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Configuration reload is not enabled"})
+		return
+	}
+
+	if err := h.ReloadConfig(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to reload configuration: " + err.Error()})
+		return
+	}
+	log.Printf("Configuration reloaded by %s", c.GetHeader("X-Client-ID"))
+	c.JSON(http.StatusOK, gin.H{"reloaded_at": h.now().Unix()})
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestAdminReloadConfig(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	router := gin.Default()
+	router.POST("/admin/config/reload", h.AdminReloadConfig)
+	db.UpsertClient(h.Store, "admin", "Admin", "CODEA", 0)
+	db.UpdateClientAdminStatus(h.Store, "admin", true)
+
+	post := func(clientID string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/admin/config/reload", nil)
+		req.Header.Set("X-Client-ID", clientID)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := post("admin"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 without a reload function, got %d", w.Code)
+	}
+
+	var next error = errors.New("CONFIG_FILE: no such file")
+	h.ReloadConfig = func() error {
+		if next != nil {
+			return next
+		}
+		h.Reload(Reloadable{RequestTimeout: time.Minute, EndpointTimeouts: map[string]time.Duration{"GET /api/files": time.Second}})
+		return nil
+	}
+	if w := post("client-a"); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a client, got %d", w.Code)
+	}
+	if w := post("admin"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 when the configuration fails to load, got %d", w.Code)
+	}
+	if h.requestTimeout("GET", "/api/clients") != 0 {
+		t.Error("expected a failed reload to change nothing")
+	}
+
+	next = nil
+	if w := post("admin"); w.Code != http.StatusOK {
+		t.Fatalf("expected the reload to succeed, got %d %s", w.Code, w.Body.String())
+	}
+	if got := h.requestTimeout("GET", "/api/clients"); got != time.Minute {
+		t.Errorf("expected the new request timeout, got %s", got)
+	}
+	if got := h.requestTimeout("GET", "/api/files"); got != time.Second {
+		t.Errorf("expected the new endpoint timeout, got %s", got)
+	}
+}
@@ -0,0 +1,333 @@
+package api
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/notify"
+	"github.com/celerix/depot/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// fileRequestInfo is what recipients of a request link get to see.
+type fileRequestInfo struct {
+	ID          string `json:"id"`
+	Description string `json:"description"`
+	Requester   string `json:"requester"`
+	Deadline    int64  `json:"deadline,omitempty"`
+	Open        bool   `json:"open"`
+	UploadURL   string `json:"upload_url"`
+}
+
+var fileRequestPage = template.Must(template.New("request").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>File request from {{.Requester}}</title>
+<style>
+body { display: flex; min-height: 100vh; margin: 0; align-items: center; justify-content: center; font: 16px/1.5 system-ui, sans-serif; background: #f6f8fa; color: #1f2328; }
+main { background: #fff; border: 1px solid #d0d7de; border-radius: 8px; padding: 2rem; max-width: 32rem; width: 100%; }
+h1 { font-size: 1.25rem; margin: 0 0 1rem; }
+p.description { white-space: pre-wrap; }
+label { display: block; margin: 0 0 1rem; }
+input[type=text], textarea { display: block; width: 100%; box-sizing: border-box; margin-top: .25rem; }
+button { background: #1f883d; color: #fff; padding: .5rem 1.25rem; border: 0; border-radius: 6px; font: inherit; cursor: pointer; }
+.muted { color: #59636e; }
+.warning { color: #cf222e; }
+</style>
+</head>
+<body>
+<main>
+{{if .Done}}<h1>Thank you</h1>
+<p>{{.Done}} was sent to {{.Requester}}.</p>
+{{else}}<h1>{{.Requester}} requests a file</h1>
+<p class="description">{{.Description}}</p>
+{{if .Deadline}}<p class="muted">Due {{.DeadlineText}}</p>{{end}}
+{{if .Open}}<form method="post" action="{{.UploadURL}}" enctype="multipart/form-data">
+<label>File <input type="file" name="file" required></label>
+<label>Your name <input type="text" name="from" maxlength="100"></label>
+<label>Message <textarea name="message" rows="3" maxlength="1000"></textarea></label>
+<button type="submit">Upload</button>
+</form>
+{{else}}<p class="warning">This request no longer accepts uploads.</p>{{end}}
+{{end}}</main>
+</body>
+</html>
+`))
+
+func (h *Handler) fileRequestInfo(c *gin.Context, req *db.FileRequest) fileRequestInfo {
+	requester := "Admin"
+	if req.OwnerID != "" {
+		requester = "Unknown"
+		if client, err := db.GetClient(h.Store, req.OwnerID); err == nil {
+			requester = client.Name
+		}
+	}
+	return fileRequestInfo{
+		ID:          req.ID,
+		Description: req.Description,
+		Requester:   requester,
+		Deadline:    req.Deadline,
+		Open:        req.Open(h.now().Unix()),
+		UploadURL:   fmt.Sprintf("%s/api/requests/%s/upload", h.baseURL(c), req.ID),
+	}
+}
+
+// serveFileRequestPage renders the request's upload form, or the thank-you
+// page once done names the uploaded file.
+func (h *Handler) serveFileRequestPage(c *gin.Context, status int, info fileRequestInfo, done string) {
+	c.Header("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; form-action 'self'")
+	c.Status(status)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	err := fileRequestPage.Execute(c.Writer, struct {
+		fileRequestInfo
+		DeadlineText string
+		Done         string
+	}{info, formatDeadline(info.Deadline), done})
+	if err != nil {
+		c.Error(err)
+	}
+}
+
+func (h *Handler) ListFileRequests(c *gin.Context) {
+	ownerID := c.GetHeader("X-Client-ID")
+	if ownerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
+		return
+	}
+	if h.isAdmin(c) && c.Query("all") == "true" {
+		ownerID = ""
+	}
+
+	reqs, err := db.ListFileRequests(h.Store, ownerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list file requests"})
+		return
+	}
+	c.JSON(http.StatusOK, reqs)
+}
+
+// CreateFileRequest opens a request that anyone with its link can fulfill
+// by uploading a file into the requester's space.
+func (h *Handler) CreateFileRequest(c *gin.Context) {
+	ownerID := c.GetHeader("X-Client-ID")
+	if ownerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
+		return
+	}
+
+	var input struct {
+		Description string `json:"description" binding:"required"`
+		// Deadline is a Unix timestamp or an RFC 3339 time
+		Deadline   string `json:"deadline"`
+		Folder     string `json:"folder"`
+		WebhookURL string `json:"webhook_url"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	deadline, err := parseTimestamp("deadline", input.Deadline)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	now := h.now().Unix()
+	if deadline > 0 && deadline <= now {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "deadline must be in the future"})
+		return
+	}
+	if input.WebhookURL != "" {
+		u, err := url.Parse(input.WebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "webhook_url must be an http(s) URL"})
+			return
+		}
+	}
+
+	req := db.FileRequest{
+		ID:          uuid.New().String(),
+		OwnerID:     ownerID,
+		Description: strings.TrimSpace(input.Description),
+		Deadline:    deadline,
+		Folder:      db.NormalizeFolder(input.Folder),
+		WebhookURL:  input.WebhookURL,
+		CreatedAt:   now,
+		FileIDs:     []string{},
+	}
+	if err := db.SaveFileRequest(h.Store, req); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create file request"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"request": req, "url": h.baseURL(c) + "/api/requests/" + req.ID})
+}
+
+// GetFileRequest shows the request to whoever has the link: an upload form
+// for browsers and the public details as JSON otherwise. The requester also
+// gets the full record with the uploaded files.
+func (h *Handler) GetFileRequest(c *gin.Context) {
+	req, err := db.GetFileRequest(h.Store, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File request not found"})
+		return
+	}
+	info := h.fileRequestInfo(c, req)
+
+	if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML {
+		h.serveFileRequestPage(c, http.StatusOK, info, "")
+		return
+	}
+	if req.OwnerID != c.GetHeader("X-Client-ID") && !h.isAdmin(c) {
+		c.JSON(http.StatusOK, info)
+		return
+	}
+
+	files := []db.FileRecord{}
+	for _, id := range req.FileIDs {
+		if record, err := db.GetFileRecord(h.Store, id); err == nil {
+			files = append(files, *record)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"request": req, "files": files, "open": info.Open})
+}
+
+func (h *Handler) DeleteFileRequest(c *gin.Context) {
+	req, err := db.GetFileRequest(h.Store, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File request not found"})
+		return
+	}
+	if req.OwnerID != c.GetHeader("X-Client-ID") && !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to delete this file request"})
+		return
+	}
+
+	// Files uploaded for the request stay with the requester
+	if err := db.DeleteFileRequest(h.Store, req.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete file request"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// FulfillFileRequest accepts an upload for an open request. No persona is
+// needed; the file is owned by the requester, who is notified.
+func (h *Handler) FulfillFileRequest(c *gin.Context) {
+	req, err := db.GetFileRequest(h.Store, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File request not found"})
+		return
+	}
+	if !req.Open(h.now().Unix()) {
+		c.JSON(http.StatusGone, gin.H{"error": "File request is closed"})
+		return
+	}
+
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file is received"})
+		return
+	}
+	defer file.Close()
+
+	id := uuid.New().String()
+	storedPath, size, hash, err := storage.StoreFile(storage.ContextReader(c.Request.Context(), file), h.TempDir, h.StorageDir, id)
+	if err != nil {
+		if timedOut(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file: " + err.Error()})
+		return
+	}
+
+	metadata := map[string]string{"file_request": req.ID}
+	from := strings.TrimSpace(c.PostForm("from"))
+	if from != "" {
+		metadata["request_from"] = truncate(from, 100)
+	}
+	if message := strings.TrimSpace(c.PostForm("message")); message != "" {
+		metadata["request_message"] = truncate(message, 1000)
+	}
+
+	record, err := h.ingest(c.Request.Context(), stagedFile{ID: id, Path: storedPath, Size: size, SHA256: hash}, ingestOptions{
+		OwnerID:       req.OwnerID,
+		RemoteAddr:    c.ClientIP(),
+		Name:          header.Filename,
+		StripMetadata: h.StripMetadata,
+		Folder:        req.Folder,
+		Metadata:      metadata,
+	})
+	if err != nil {
+		respondIngestError(c, err)
+		return
+	}
+	if err := db.AddFileRequestUpload(h.Store, req.ID, record.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link upload to the request"})
+		return
+	}
+
+	h.runJob("file-request:"+record.ID, func() error {
+		return h.notifyFileRequest(req, record, from)
+	})
+
+	if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML {
+		h.serveFileRequestPage(c, http.StatusOK, h.fileRequestInfo(c, req), record.OriginalName)
+		return
+	}
+	// The uploader gets no link: the file is the requester's to share
+	c.JSON(http.StatusOK, gin.H{"status": "success", "name": record.OriginalName, "size": record.Size})
+}
+
+// notifyFileRequest tells the requester about an upload, through the
+// request's own webhook or else the requester's chat integration.
+func (h *Handler) notifyFileRequest(req *db.FileRequest, record *db.FileRecord, from string) error {
+	target := req.WebhookURL
+	if target == "" {
+		if ci, err := db.GetChatIntegration(h.Store, req.OwnerID); err == nil && ci.Enabled {
+			target = ci.WebhookURL
+		}
+	}
+	if target == "" {
+		return nil
+	}
+
+	if from == "" {
+		from = "Someone"
+	}
+	text := fmt.Sprintf("%s uploaded %s (%s) for your request %q", from, record.OriginalName, humanSize(record.Size), truncate(req.Description, 80))
+	return notify.Webhook(target, notify.Message{
+		Event: "file_request.fulfilled",
+		Text:  text,
+		Data: gin.H{
+			"request_id": req.ID,
+			"file_id":    record.ID,
+			"name":       record.OriginalName,
+			"size":       record.Size,
+			"from":       from,
+		},
+	})
+}
+
+func formatDeadline(ts int64) string {
+	if ts == 0 {
+		return ""
+	}
+	return time.Unix(ts, 0).UTC().Format("Jan 2, 2006 15:04 UTC")
+}
+
+// truncate cuts s to at most n runes.
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestFileRequests(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	clock := time.Date(2026, 5, 4, 9, 0, 0, 0, time.UTC)
+	h.Now = func() time.Time { return clock }
+	db.UpsertClient(h.Store, "client-a", "Alice", "code-a", 0)
+
+	var mu sync.Mutex
+	var posted []string
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg struct {
+			Event string `json:"event"`
+			Text  string `json:"text"`
+		}
+		json.NewDecoder(r.Body).Decode(&msg)
+		mu.Lock()
+		posted = append(posted, msg.Event+": "+msg.Text)
+		mu.Unlock()
+	}))
+	defer hook.Close()
+
+	router := gin.Default()
+	router.POST("/requests", h.CreateFileRequest)
+	router.GET("/requests/:id", h.GetFileRequest)
+	router.POST("/requests/:id/upload", h.FulfillFileRequest)
+
+	w := httptest.NewRecorder()
+	body := `{"description": "Signed contract", "deadline": "2026-05-05T09:00:00Z", "folder": "contracts", "webhook_url": "` + hook.URL + `"}`
+	req, _ := http.NewRequest("POST", "/requests", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Client-ID", "client-a")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("creating request failed: %s", w.Body.String())
+	}
+	var created struct {
+		Request db.FileRequest `json:"request"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	id := created.Request.ID
+
+	// Recipients see the request but not what others uploaded
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/requests/"+id, nil)
+	router.ServeHTTP(w, req)
+	var info fileRequestInfo
+	json.Unmarshal(w.Body.Bytes(), &info)
+	if info.Requester != "Alice" || info.Description != "Signed contract" || !info.Open {
+		t.Errorf("unexpected public info %+v", info)
+	}
+
+	fulfill := func() *httptest.ResponseRecorder {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("file", "contract.pdf")
+		part.Write([]byte("%PDF-1.4 signed"))
+		writer.WriteField("from", "Bob")
+		writer.Close()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/requests/"+id+"/upload", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := fulfill(); w.Code != http.StatusOK {
+		t.Fatalf("fulfilling request failed: %s", w.Body.String())
+	}
+
+	stored, _ := db.GetFileRequest(h.Store, id)
+	if len(stored.FileIDs) != 1 {
+		t.Fatalf("expected the upload to be linked, got %v", stored.FileIDs)
+	}
+	record, err := db.GetFileRecord(h.Store, stored.FileIDs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.OwnerID != "client-a" || record.Folder != "contracts" ||
+		record.Metadata["file_request"] != id || record.Metadata["request_from"] != "Bob" {
+		t.Errorf("unexpected record %+v", record)
+	}
+
+	mu.Lock()
+	if len(posted) != 1 || !strings.Contains(posted[0], "file_request.fulfilled: Bob uploaded contract.pdf") {
+		t.Errorf("unexpected notifications %v", posted)
+	}
+	mu.Unlock()
+
+	// The requester sees the uploads
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/requests/"+id, nil)
+	req.Header.Set("X-Client-ID", "client-a")
+	router.ServeHTTP(w, req)
+	var full struct {
+		Files []db.FileRecord `json:"files"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &full)
+	if len(full.Files) != 1 || full.Files[0].OriginalName != "contract.pdf" {
+		t.Errorf("expected the requester to see the upload, got %s", w.Body.String())
+	}
+
+	clock = clock.Add(48 * time.Hour)
+	if w := fulfill(); w.Code != http.StatusGone {
+		t.Errorf("expected 410 after the deadline, got %d", w.Code)
+	}
+}
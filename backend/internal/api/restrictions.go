@@ -0,0 +1,74 @@
+package api
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+// parseNetworks validates a link's allowed networks, taking plain addresses
+// as single-address prefixes.
+func parseNetworks(in []string) ([]string, error) {
+	networks := []string{}
+	for _, s := range in {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(s)
+			if addrErr != nil {
+				return nil, fmt.Errorf("%q is not an IP address or CIDR prefix", s)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		networks = append(networks, prefix.Masked().String())
+	}
+	slices.Sort(networks)
+	return slices.Compact(networks), nil
+}
+
+// parseCountries validates a link's allowed countries, two-letter ISO codes.
+func parseCountries(in []string) ([]string, error) {
+	countries := []string{}
+	for _, s := range in {
+		s = strings.ToUpper(strings.TrimSpace(s))
+		if s == "" {
+			continue
+		}
+		if len(s) != 2 || s[0] < 'A' || s[0] > 'Z' || s[1] < 'A' || s[1] > 'Z' {
+			return nil, fmt.Errorf("%q is not a two-letter country code", s)
+		}
+		countries = append(countries, s)
+	}
+	slices.Sort(countries)
+	return slices.Compact(countries), nil
+}
+
+// locationAllowed reports whether the client's address is in one of the
+// link's networks and its country among the link's countries. A client
+// whose country is unknown is refused by a country restriction.
+func (h *Handler) locationAllowed(c *gin.Context, record *db.FileRecord) bool {
+	if len(record.AllowedNetworks) > 0 {
+		addr, err := netip.ParseAddr(c.ClientIP())
+		if err != nil {
+			return false
+		}
+		addr = addr.Unmap()
+		if !slices.ContainsFunc(record.AllowedNetworks, func(n string) bool {
+			prefix, err := netip.ParsePrefix(n)
+			return err == nil && prefix.Contains(addr)
+		}) {
+			return false
+		}
+	}
+	if len(record.AllowedCountries) > 0 && !slices.Contains(record.AllowedCountries, h.clientCountry(c)) {
+		return false
+	}
+	return true
+}
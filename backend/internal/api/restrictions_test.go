@@ -0,0 +1,92 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/geoip"
+	"github.com/gin-gonic/gin"
+)
+
+func TestLinkRestrictions(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+	h.GeoIP, _ = geoip.Load(strings.NewReader("192.0.2.0,192.0.2.255,DE\n198.51.100.0,198.51.100.255,US\n"))
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.PUT("/files/:id", h.UpdateFile)
+	router.GET("/download/:id", h.DownloadFile)
+
+	build := uploadTestFile(t, router, "owner", "build-eu.zip", []byte("eu build"))
+	id, link := build["id"].(string), build["download_link"].(string)
+
+	update := func(body string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/files/"+id, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Client-ID", "owner")
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	download := func(addr, clientID string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/download/"+link, nil)
+		req.RemoteAddr = addr + ":4711"
+		if clientID != "" {
+			req.Header.Set("X-Client-ID", clientID)
+		}
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	const base = `{"original_name": "build-eu.zip", "owner_id": "owner"`
+
+	if code := update(base + `, "allowed_networks": ["10.0.0.0/33"]}`); code != http.StatusBadRequest {
+		t.Errorf("expected a bad prefix to be rejected, got %d", code)
+	}
+	if code := update(base + `, "allowed_countries": ["Germany"]}`); code != http.StatusBadRequest {
+		t.Errorf("expected a bad country to be rejected, got %d", code)
+	}
+
+	if code := update(base + `, "allowed_countries": ["de"]}`); code != http.StatusOK {
+		t.Fatalf("setting the countries failed: %d", code)
+	}
+	if code := download("192.0.2.10", ""); code != http.StatusOK {
+		t.Errorf("expected a download from Germany, got %d", code)
+	}
+	if code := download("198.51.100.7", ""); code != http.StatusForbidden {
+		t.Errorf("expected a download from the US to be refused, got %d", code)
+	}
+	if code := download("203.0.113.1", ""); code != http.StatusForbidden {
+		t.Errorf("expected an unknown country to be refused, got %d", code)
+	}
+	if code := download("198.51.100.7", "owner"); code != http.StatusOK {
+		t.Errorf("expected the owner to download from anywhere, got %d", code)
+	}
+
+	// Both restrictions have to match
+	if code := update(base + `, "allowed_networks": ["192.0.2.128/25", "2001:db8::1"]}`); code != http.StatusOK {
+		t.Fatalf("setting the networks failed: %d", code)
+	}
+	record, _ := db.GetFileRecord(h.Store, id)
+	if len(record.AllowedNetworks) != 2 || record.AllowedNetworks[1] != "2001:db8::1/128" || record.AllowedCountries[0] != "DE" {
+		t.Errorf("unexpected restrictions %v %v", record.AllowedNetworks, record.AllowedCountries)
+	}
+	if code := download("192.0.2.10", ""); code != http.StatusForbidden {
+		t.Errorf("expected an address outside the networks to be refused, got %d", code)
+	}
+	if code := download("192.0.2.200", ""); code != http.StatusOK {
+		t.Errorf("expected an address in the networks to download, got %d", code)
+	}
+
+	if code := update(base + `, "allowed_networks": [], "allowed_countries": []}`); code != http.StatusOK {
+		t.Fatalf("lifting the restrictions failed: %d", code)
+	}
+	if code := download("198.51.100.7", ""); code != http.StatusOK {
+		t.Errorf("expected an unrestricted link, got %d", code)
+	}
+}
@@ -0,0 +1,29 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+// errRetained keeps removeFile from deleting a file of a write-once folder
+// whatever path it was reached by.
+var errRetained = errors.New("file is under retention")
+
+func (h *Handler) retained(record *db.FileRecord) bool {
+	return record.Retained(h.now().Unix())
+}
+
+// refuseRetained answers 409 and reports true when the file is locked by the
+// retention of a write-once folder. Admins are bound by it too.
+func (h *Handler) refuseRetained(c *gin.Context, record *db.FileRecord) bool {
+	if !h.retained(record) {
+		return false
+	}
+	until := time.Unix(record.RetainUntil, 0).UTC().Format(time.RFC3339)
+	c.JSON(http.StatusConflict, gin.H{"error": "File is retained until " + until, "retain_until": record.RetainUntil})
+	return true
+}
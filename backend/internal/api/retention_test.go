@@ -0,0 +1,98 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestWriteOnceFolders(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	clock := time.Date(2026, 6, 1, 8, 0, 0, 0, time.UTC)
+	h.Now = func() time.Time { return clock }
+	db.UpsertClient(h.Store, "admin", "Admin", "CODEA", 0)
+	db.UpdateClientAdminStatus(h.Store, "admin", true)
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.PUT("/files/:id", h.UpdateFile)
+	router.DELETE("/files/:id", h.DeleteFile)
+	router.PUT("/persona/folder-policy", h.PutFolderPolicy)
+	router.DELETE("/persona/folder-policy", h.DeleteFolderPolicy)
+
+	do := func(method, path, clientID, body string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Client-ID", clientID)
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := do("PUT", "/persona/folder-policy", "client-a", `{"folder": "archive", "retention_seconds": 86400}`); code != http.StatusOK {
+		t.Fatalf("saving policy failed: %d", code)
+	}
+	// A subfolder can't shorten its parent's retention
+	do("PUT", "/persona/folder-policy", "client-a", `{"folder": "archive/2026", "retention_seconds": 60}`)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "ledger.csv")
+	part.Write([]byte("date,amount\n"))
+	writer.WriteField("folder", "archive/2026")
+	writer.Close()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("X-Client-ID", "client-a")
+	router.ServeHTTP(w, req)
+	var uploaded db.FileRecord
+	json.Unmarshal(w.Body.Bytes(), &uploaded)
+	record, err := db.GetFileRecord(h.Store, uploaded.ID)
+	if err != nil || record.RetainUntil != clock.Unix()+86400 {
+		t.Fatalf("expected the file to be retained for a day, got %+v", record)
+	}
+	path := "/files/" + record.ID
+
+	if code := do("DELETE", path, "client-a", ""); code != http.StatusConflict {
+		t.Errorf("expected the owner's delete to be refused, got %d", code)
+	}
+	if code := do("DELETE", path, "admin", ""); code != http.StatusConflict {
+		t.Errorf("expected the admin's delete to be refused, got %d", code)
+	}
+	if code := do("PUT", path, "client-a", `{"original_name": "edited.csv", "owner_id": "client-a"}`); code != http.StatusConflict {
+		t.Errorf("expected the rename to be refused, got %d", code)
+	}
+	if code := do("PUT", path, "client-a", `{"original_name": "ledger.csv", "owner_id": "client-a", "folder": "scratch"}`); code != http.StatusConflict {
+		t.Errorf("expected the move to be refused, got %d", code)
+	}
+	if code := do("PUT", path, "client-a", `{"original_name": "ledger.csv", "owner_id": "client-a", "folder": "archive/2026", "tags": ["audited"]}`); code != http.StatusOK {
+		t.Errorf("expected tags to stay editable, got %d", code)
+	}
+
+	// The folder stays write-once for its owner
+	if code := do("PUT", "/persona/folder-policy", "client-a", `{"folder": "archive"}`); code != http.StatusForbidden {
+		t.Errorf("expected lifting the retention to be refused, got %d", code)
+	}
+	if code := do("DELETE", "/persona/folder-policy?folder=archive", "client-a", ""); code != http.StatusForbidden {
+		t.Errorf("expected deleting the policy to be refused, got %d", code)
+	}
+	if code := do("PUT", "/persona/folder-policy", "client-a", `{"folder": "archive", "retention_seconds": 172800}`); code != http.StatusOK {
+		t.Errorf("expected lengthening the retention to work, got %d", code)
+	}
+
+	// Once the retention is over the file is an ordinary one
+	clock = clock.Add(25 * time.Hour)
+	if code := do("DELETE", path, "client-a", ""); code != http.StatusOK {
+		t.Errorf("expected the delete to work after the retention, got %d", code)
+	}
+}
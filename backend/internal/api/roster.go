@@ -0,0 +1,148 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const maxRosterRows = 10000
+
+var recoveryCodePattern = regexp.MustCompile(`^[A-Z0-9-]{6,64}$`)
+
+type rosterEntry struct {
+	Name         string `json:"name"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+type rosterResult struct {
+	Name         string `json:"name"`
+	ID           string `json:"id,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+	// Status is "created", "exists" or "error"
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// parseRoster reads a roster as JSON (an array of entries) or CSV (a name
+// column and an optional recovery_code column, with or without a header).
+func parseRoster(body io.Reader, contentType string) ([]rosterEntry, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "application/json" {
+		var entries []rosterEntry
+		if err := json.NewDecoder(body).Decode(&entries); err != nil {
+			return nil, errors.New("expected a JSON array of {name, recovery_code}: " + err.Error())
+		}
+		return entries, nil
+	}
+
+	r := csv.NewReader(body)
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, errors.New("invalid CSV: " + err.Error())
+	}
+	nameCol, codeCol := 0, 1
+	if len(rows) > 0 {
+		header := make(map[string]int)
+		for i, col := range rows[0] {
+			header[strings.ToLower(strings.TrimSpace(col))] = i
+		}
+		if i, ok := header["name"]; ok {
+			nameCol, codeCol = i, -1
+			if i, ok := header["recovery_code"]; ok {
+				codeCol = i
+			}
+			rows = rows[1:]
+		}
+	}
+	entries := make([]rosterEntry, 0, len(rows))
+	for _, row := range rows {
+		var e rosterEntry
+		if nameCol < len(row) {
+			e.Name = row[nameCol]
+		}
+		if codeCol >= 0 && codeCol < len(row) {
+			e.RecoveryCode = row[codeCol]
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// AdminImportClients provisions clients in bulk from a roster, so a class
+// or team can be handed their recovery codes before first use. Rows without
+// a recovery code get a generated one. A code that is already taken reports
+// the existing client instead of creating one.
+func (h *Handler) AdminImportClients(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	body, contentType := io.Reader(c.Request.Body), c.ContentType()
+	if strings.HasPrefix(contentType, "multipart/") {
+		file, header, err := c.Request.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+			return
+		}
+		defer file.Close()
+		body, contentType = file, header.Header.Get("Content-Type")
+		if strings.HasSuffix(strings.ToLower(header.Filename), ".json") {
+			contentType = "application/json"
+		}
+	}
+	entries, err := parseRoster(body, contentType)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(entries) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The roster is empty"})
+		return
+	}
+	if len(entries) > maxRosterRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Too many rows"})
+		return
+	}
+
+	results := make([]rosterResult, 0, len(entries))
+	created := 0
+	for _, e := range entries {
+		res := rosterResult{Name: strings.TrimSpace(e.Name)}
+		code := strings.ToUpper(strings.TrimSpace(e.RecoveryCode))
+		switch {
+		case res.Name == "":
+			res.Status, res.Error = "error", "name is required"
+		case code != "" && !recoveryCodePattern.MatchString(code):
+			res.Status, res.Error = "error", "recovery codes are 6 to 64 letters, digits or dashes"
+		default:
+			if code == "" {
+				code = strings.ToUpper(uuid.New().String()[:8])
+			}
+			res.ID = uuid.NewSHA1(h.CelerixNamespace, []byte(code)).String()
+			if existing, err := db.GetClient(h.Store, res.ID); err == nil {
+				// The code is the client's identity, so this is the same client
+				res.Name, res.ID, res.Status = existing.Name, existing.ID, "exists"
+			} else if err := db.UpsertClient(h.Store, res.ID, res.Name, code, 0); err != nil {
+				res.Status, res.Error = "error", "failed to save the client"
+			} else {
+				res.RecoveryCode, res.Status = code, "created"
+				created++
+			}
+		}
+		results = append(results, res)
+	}
+	c.JSON(http.StatusOK, gin.H{"created": created, "clients": results})
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestAdminImportClients(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	router := gin.Default()
+	router.POST("/admin/clients/import", h.AdminImportClients)
+	router.POST("/persona/recover", h.RecoverPersona)
+
+	db.UpsertClient(h.Store, "admin", "Admin", "CODEA", 0)
+	db.UpdateClientAdminStatus(h.Store, "admin", true)
+
+	type result struct {
+		Created int            `json:"created"`
+		Clients []rosterResult `json:"clients"`
+	}
+	post := func(clientID, contentType, body string) (int, result) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/admin/clients/import", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("X-Client-ID", clientID)
+		router.ServeHTTP(w, req)
+		var r result
+		json.Unmarshal(w.Body.Bytes(), &r)
+		return w.Code, r
+	}
+
+	if code, _ := post("someone", "text/csv", "name\nAda\n"); code != http.StatusForbidden {
+		t.Errorf("expected 403 for a client, got %d", code)
+	}
+
+	code, r := post("admin", "text/csv", "name,recovery_code\nAda Lovelace,\nAlan Turing,turing-01\n,\nGrace,bad code\n")
+	if code != http.StatusOK || r.Created != 2 || len(r.Clients) != 4 {
+		t.Fatalf("unexpected import result %d %+v", code, r)
+	}
+	ada, alan := r.Clients[0], r.Clients[1]
+	if ada.Status != "created" || len(ada.RecoveryCode) != 8 {
+		t.Errorf("expected a generated code, got %+v", ada)
+	}
+	if alan.RecoveryCode != "TURING-01" {
+		t.Errorf("expected the given code, uppercased, got %+v", alan)
+	}
+	if r.Clients[2].Status != "error" || r.Clients[3].Status != "error" {
+		t.Errorf("expected the blank and invalid rows to fail, got %+v", r.Clients[2:])
+	}
+
+	// The generated codes recover the provisioned personas
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/persona/recover", bytes.NewBufferString(`{"code": "`+ada.RecoveryCode+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	var recovered map[string]string
+	json.Unmarshal(w.Body.Bytes(), &recovered)
+	if recovered["id"] != ada.ID || recovered["name"] != "Ada Lovelace" {
+		t.Errorf("expected to recover Ada, got %v", recovered)
+	}
+
+	// JSON, including a code that is already taken
+	code, r = post("admin", "application/json", `[{"name": "Someone Else", "recovery_code": "TURING-01"}, {"name": "Linus"}]`)
+	if code != http.StatusOK || r.Created != 1 {
+		t.Fatalf("unexpected import result %d %+v", code, r)
+	}
+	if r.Clients[0].Status != "exists" || r.Clients[0].ID != alan.ID || r.Clients[0].Name != "Alan Turing" {
+		t.Errorf("expected the taken code to report the existing client, got %+v", r.Clients[0])
+	}
+
+	// CSV without a header
+	if _, r := post("admin", "text/csv", "Margaret\nKatherine,KJ-2024\n"); r.Created != 2 || r.Clients[1].RecoveryCode != "KJ-2024" {
+		t.Errorf("unexpected headerless import %+v", r)
+	}
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RotateLinks replaces the download links of public files in the folders
+// the settings mark as sensitive once the links are older than the
+// rotation period. The old link stops working; owners are told the new
+// one.
+func (h *Handler) RotateLinks(ctx context.Context) error {
+	settings := db.GetSettings(h.Store)
+	if settings.LinkRotationDays <= 0 || len(settings.LinkRotationFolders) == 0 {
+		return nil
+	}
+	files, err := db.GetAllFileRecords(h.Store)
+	if err != nil {
+		return err
+	}
+	now := h.now()
+	cutoff := now.Add(-time.Duration(settings.LinkRotationDays) * 24 * time.Hour).Unix()
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		issued := f.LinkRotatedAt
+		if issued == 0 {
+			issued = f.UploadTime
+		}
+		if !f.IsPublic || f.DownloadLink == "" || issued > cutoff || !inAnyFolder(&f, settings.LinkRotationFolders) {
+			continue
+		}
+
+		updated, err := db.RotateDownloadLink(h.Store, f.ID, uuid.New().String(), now.Unix())
+		if err != nil {
+			log.Printf("[ERROR] Failed to rotate the link of %s: %v", f.ID, err)
+			continue
+		}
+		if err := db.AddAudit(h.Store, db.AuditEntry{Time: now.Unix(), Event: "link.rotated", FileID: f.ID, OwnerID: f.OwnerID, Name: f.OriginalName}); err != nil {
+			log.Printf("[ERROR] Failed to audit the link rotation of %s: %v", f.ID, err)
+		}
+		downloadURL := fmt.Sprintf("%s/api/download/%s", h.PublicURL, updated.DownloadLink)
+		h.notifyClient(f.OwnerID, db.EventLinkRotated,
+			fmt.Sprintf("The link to %s was replaced, the old one no longer works. The new link is\n%s", f.OriginalName, downloadURL),
+			gin.H{"file_id": f.ID, "name": f.OriginalName, "download_link": updated.DownloadLink, "download_url": downloadURL})
+	}
+	return nil
+}
+
+func inAnyFolder(record *db.FileRecord, folders []string) bool {
+	for _, folder := range folders {
+		if record.InFolder(folder) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/notify"
+	"github.com/gin-gonic/gin"
+)
+
+func TestRotateLinks(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+	h.PublicURL = "https://depot.example"
+
+	var mu sync.Mutex
+	var messages []notify.Message
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg notify.Message
+		json.NewDecoder(r.Body).Decode(&msg)
+		mu.Lock()
+		messages = append(messages, msg)
+		mu.Unlock()
+	}))
+	defer hook.Close()
+	db.SaveNotificationPrefs(h.Store, db.NotificationPrefs{OwnerID: "owner", WebhookURL: hook.URL, Events: []string{db.EventLinkRotated}})
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	place := func(name, folder string, public bool) *db.FileRecord {
+		resp := uploadTestFile(t, router, "owner", name, []byte(name))
+		record, _ := db.GetFileRecord(h.Store, resp["id"].(string))
+		record.Folder, record.IsPublic = folder, public
+		db.SaveFileRecord(h.Store, *record)
+		return record
+	}
+	sensitive := place("payroll.csv", "hr/2026", true)
+	private := place("reviews.csv", "hr", false)
+	elsewhere := place("menu.pdf", "canteen", true)
+
+	settings := db.GetSettings(h.Store)
+	settings.LinkRotationDays = 30
+	settings.LinkRotationFolders = []string{"/hr/"}
+	db.SaveSettings(h.Store, db.NormalizeSettings(settings))
+
+	// Young links are left alone
+	if err := h.RotateLinks(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if record, _ := db.GetFileRecord(h.Store, sensitive.ID); record.DownloadLink != sensitive.DownloadLink {
+		t.Fatal("expected a new link to be kept")
+	}
+
+	later := time.Now().Add(31 * 24 * time.Hour)
+	h.Now = func() time.Time { return later }
+	if err := h.RotateLinks(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	rotated, _ := db.GetFileRecord(h.Store, sensitive.ID)
+	if rotated.DownloadLink == sensitive.DownloadLink || rotated.LinkRotatedAt != later.Unix() {
+		t.Fatalf("expected the sensitive link to be replaced, got %+v", rotated)
+	}
+	if _, err := h.findDownloadRecord(sensitive.DownloadLink); err == nil {
+		t.Error("expected the old link to stop working")
+	}
+	for _, f := range []*db.FileRecord{private, elsewhere} {
+		if record, _ := db.GetFileRecord(h.Store, f.ID); record.DownloadLink != f.DownloadLink {
+			t.Errorf("expected %s to keep its link", f.OriginalName)
+		}
+	}
+
+	// The new link starts a new period
+	if err := h.RotateLinks(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if record, _ := db.GetFileRecord(h.Store, sensitive.ID); record.DownloadLink != rotated.DownloadLink {
+		t.Error("expected the fresh link to be kept")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(messages) != 1 || messages[0].Event != db.EventLinkRotated {
+		t.Fatalf("expected one rotation notice, got %+v", messages)
+	}
+	if data, _ := messages[0].Data.(map[string]any); data["download_url"] != "https://depot.example/api/download/"+rotated.DownloadLink {
+		t.Errorf("expected the new link in the notice, got %+v", messages[0].Data)
+	}
+}
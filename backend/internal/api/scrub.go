@@ -0,0 +1,65 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) GetScrubStatus(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	blobs, err := db.ListBlobs(h.Store)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list blobs"})
+		return
+	}
+	corrupted := []db.BlobRecord{}
+	for _, b := range blobs {
+		if b.Corrupted {
+			corrupted = append(corrupted, b)
+		}
+	}
+
+	resp := gin.H{"blobs": len(blobs), "corrupted": corrupted}
+	if h.Scrubber != nil {
+		resp["last_run"] = h.Scrubber.Last()
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) RunScrub(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+	if h.Scrubber == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Scrubbing is not configured"})
+		return
+	}
+
+	h.runJob("scrub", func() error {
+		return h.Scrub(context.Background())
+	})
+	c.JSON(http.StatusAccepted, gin.H{"status": "started"})
+}
+
+// Scrub runs an integrity pass, keeping an alert open while passes fail.
+func (h *Handler) Scrub(ctx context.Context) error {
+	_, err := h.Scrubber.Run(ctx)
+	if err != nil && ctx.Err() == nil {
+		h.RaiseAlert(db.AlertRecord{
+			ID:      "scrub-failed",
+			Kind:    "scrub.failed",
+			Message: "Integrity scrub failed: " + err.Error(),
+		})
+	} else if err == nil {
+		h.ClearAlert("scrub-failed")
+	}
+	return err
+}
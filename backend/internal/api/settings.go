@@ -0,0 +1,67 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+// GetSettings serves the instance settings to the frontend. Nothing in there
+// is secret, so it needs no persona.
+func (h *Handler) GetSettings(c *gin.Context) {
+	c.JSON(http.StatusOK, db.GetSettings(h.Store))
+}
+
+func (h *Handler) AdminGetSettings(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	c.JSON(http.StatusOK, db.GetSettings(h.Store))
+}
+
+func (h *Handler) UpdateSettings(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	var input db.Settings
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateSettings(input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := db.SaveSettings(h.Store, input); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, db.GetSettings(h.Store))
+}
+
+func validateSettings(s db.Settings) error {
+	if s.QuotaBytes < 0 || s.MaxUploadBytes < 0 || s.LinkExpirySeconds < 0 ||
+		s.ExpiryReminderSeconds < 0 || s.ExtendSeconds < 0 || s.MaxExtensions < 0 {
+		return errors.New("Limits must not be negative")
+	}
+	if s.AnalyticsRetentionDays < 0 || s.AuditRetentionDays < 0 {
+		return errors.New("Retention must not be negative")
+	}
+	if s.LinkRotationDays < 0 {
+		return errors.New("link_rotation_days must not be negative")
+	}
+	switch s.IPAddresses {
+	case "", db.IPNone, db.IPHashed, db.IPTruncated:
+	default:
+		return errors.New("ip_addresses must be none, hashed or truncated")
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestSettingsEnforcedOnUpload(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.GET("/download/:id", h.DownloadFile)
+
+	if err := db.SaveSettings(h.Store, db.Settings{
+		Title:             "Team Files",
+		MaxUploadBytes:    16,
+		AllowedExtensions: []string{"TXT"},
+		LinkExpirySeconds: 60,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	upload := func(name string, content []byte) *httptest.ResponseRecorder {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("file", name)
+		part.Write(content)
+		writer.Close()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/upload", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		req.Header.Set("X-Client-ID", "client-a")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := upload("photo.jpg", []byte("jpeg")); w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("expected disallowed type to be rejected, got %d", w.Code)
+	}
+	if w := upload("big.txt", bytes.Repeat([]byte("x"), 17)); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected oversized upload to be rejected, got %d", w.Code)
+	}
+
+	resp := uploadTestFile(t, router, "client-a", "notes.txt", []byte("short"))
+	if resp["expires_at"] == nil {
+		t.Fatalf("expected link expiry to be applied, got %v", resp)
+	}
+
+	// Once expired, only the owner can still download
+	record, _ := db.GetFileRecord(h.Store, resp["id"].(string))
+	record.ExpiresAt = 1
+	db.SaveFileRecord(h.Store, *record)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/download/"+record.DownloadLink, nil)
+	req.Header.Set("X-Client-ID", "someone-else")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusGone {
+		t.Errorf("expected expired link to be gone, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/download/"+record.DownloadLink, nil)
+	req.Header.Set("X-Client-ID", "client-a")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected owner to still download, got %d", w.Code)
+	}
+}
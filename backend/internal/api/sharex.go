@@ -0,0 +1,100 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+// sharexConfig is a ShareX custom uploader (.sxcu) definition.
+type sharexConfig struct {
+	Version         string            `json:"Version"`
+	Name            string            `json:"Name"`
+	DestinationType string            `json:"DestinationType"`
+	RequestMethod   string            `json:"RequestMethod"`
+	RequestURL      string            `json:"RequestURL"`
+	Headers         map[string]string `json:"Headers"`
+	Body            string            `json:"Body"`
+	FileFormName    string            `json:"FileFormName"`
+	URL             string            `json:"URL"`
+	DeletionURL     string            `json:"DeletionURL"`
+	ErrorMessage    string            `json:"ErrorMessage"`
+}
+
+// GetShareXConfig returns a ready-to-import ShareX uploader for the calling
+// client. Browsers can pass the client ID as a query parameter.
+func (h *Handler) GetShareXConfig(c *gin.Context) {
+	clientID := c.GetHeader("X-Client-ID")
+	if clientID == "" {
+		clientID = c.Query("client_id")
+	}
+	if clientID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header or client_id parameter is required"})
+		return
+	}
+
+	name := db.GetSettings(h.Store).Title
+	if name == "" {
+		name = "Celerix Depot"
+	}
+
+	c.Header("Content-Disposition", contentDisposition("attachment", "celerix-depot.sxcu"))
+	c.JSON(http.StatusOK, sharexConfig{
+		Version:         "15.0.0",
+		Name:            name,
+		DestinationType: "ImageUploader, TextUploader, FileUploader",
+		RequestMethod:   http.MethodPost,
+		RequestURL:      h.baseURL(c) + "/api/integrations/sharex/upload",
+		Headers:         map[string]string{"X-Client-ID": clientID},
+		Body:            "MultipartFormData",
+		FileFormName:    "file",
+		URL:             "{json:url}",
+		DeletionURL:     "{json:deletion_url}",
+		ErrorMessage:    "{json:error}",
+	})
+}
+
+// ShareXUpload accepts a multipart upload and answers in the shape ShareX
+// and similar screenshot tools expect.
+func (h *Handler) ShareXUpload(c *gin.Context) {
+	record, ok := h.receiveFormUpload(c)
+	if !ok {
+		return
+	}
+
+	token, err := db.CreateDeletionToken(h.Store, record.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create deletion link"})
+		return
+	}
+
+	base := h.baseURL(c)
+	c.JSON(http.StatusOK, gin.H{
+		"id":           record.ID,
+		"name":         record.OriginalName,
+		"url":          fmt.Sprintf("%s/api/download/%s", base, record.DownloadLink),
+		"deletion_url": fmt.Sprintf("%s/api/integrations/sharex/delete/%s/%s", base, record.ID, token),
+	})
+}
+
+// ShareXDelete deletes a file by its deletion token. GET is accepted because
+// ShareX opens deletion URLs in the browser.
+func (h *Handler) ShareXDelete(c *gin.Context) {
+	record, err := db.GetFileRecord(h.Store, c.Param("id"))
+	if err != nil || !db.CheckDeletionToken(h.Store, c.Param("id"), c.Param("token")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	if h.refuseRetained(c, record) {
+		return
+	}
+
+	if err := h.removeFile(record); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete file record"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
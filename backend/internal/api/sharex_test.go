@@ -0,0 +1,73 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestShareXIntegration(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	router := gin.Default()
+	router.GET("/api/integrations/sharex", h.GetShareXConfig)
+	router.POST("/api/integrations/sharex/upload", h.ShareXUpload)
+	router.GET("/api/integrations/sharex/delete/:id/:token", h.ShareXDelete)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://depot.test/api/integrations/sharex?client_id=client-a", nil)
+	router.ServeHTTP(w, req)
+	var cfg sharexConfig
+	json.Unmarshal(w.Body.Bytes(), &cfg)
+	if cfg.RequestURL != "http://depot.test/api/integrations/sharex/upload" || cfg.Headers["X-Client-ID"] != "client-a" {
+		t.Fatalf("unexpected config %+v", cfg)
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile(cfg.FileFormName, "screenshot.png")
+	part.Write([]byte("pixels"))
+	writer.Close()
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/api/integrations/sharex/upload", body)
+	req.Host = "depot.test"
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	for k, v := range cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	router.ServeHTTP(w, req)
+	var resp map[string]string
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if !strings.HasPrefix(resp["url"], "http://depot.test/api/download/") {
+		t.Fatalf("unexpected upload response %s", w.Body.String())
+	}
+
+	deletePath := strings.TrimPrefix(resp["deletion_url"], "http://depot.test")
+
+	// A wrong token must not delete anything
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", deletePath[:strings.LastIndex(deletePath, "/")]+"/wrong", nil)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected wrong token to be refused, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", deletePath, nil)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("deletion failed: %d %s", w.Code, w.Body.String())
+	}
+	if _, err := db.GetFileRecord(h.Store, resp["id"]); err == nil {
+		t.Error("file should be gone after deletion")
+	}
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+type sheetCard struct {
+	Name         string
+	RecoveryCode string
+	URL          string
+	QR           template.HTML
+}
+
+var recoverySheet = template.Must(template.New("sheet").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<title>Recovery codes</title>
+<style>
+body { margin: 1rem; font: 14px/1.4 system-ui, sans-serif; color: #1f2328; }
+.sheet { display: grid; grid-template-columns: repeat(auto-fill, minmax(16rem, 1fr)); gap: 1rem; }
+.card { border: 1px dashed #8c959f; border-radius: 6px; padding: 1rem; text-align: center; break-inside: avoid; }
+.card h2 { font-size: 1rem; margin: 0 0 .5rem; word-break: break-word; }
+.card svg { width: 9rem; height: 9rem; }
+.code { font: 1.25rem monospace; letter-spacing: .1em; margin: .5rem 0 .25rem; }
+.url { color: #59636e; font-size: .75rem; word-break: break-all; }
+@media print {
+  body { margin: 0; }
+  .hint { display: none; }
+  .sheet { grid-template-columns: repeat(3, 1fr); }
+}
+</style>
+</head>
+<body>
+<p class="hint">Print this page and cut along the dashed lines. Scanning a code opens the recovery page with the code filled in.</p>
+<div class="sheet">
+{{range .}}<div class="card">
+<h2>{{.Name}}</h2>
+{{.QR}}
+<div class="code">{{.RecoveryCode}}</div>
+<div class="url">{{.URL}}</div>
+</div>
+{{else}}<p>No clients to print.</p>
+{{end}}</div>
+</body>
+</html>
+`))
+
+// qrSVG renders content as an inline SVG QR code, one path for all dark
+// modules so the sheet stays small for large rosters.
+func qrSVG(content string) (template.HTML, error) {
+	q, err := qrcode.New(content, qrcode.Medium)
+	if err != nil {
+		return "", err
+	}
+	bitmap := q.Bitmap()
+	var path strings.Builder
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&path, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+	return template.HTML(fmt.Sprintf(
+		`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges"><rect width="100%%" height="100%%" fill="#fff"/><path d="%s" fill="#000"/></svg>`,
+		len(bitmap), len(bitmap), path.String())), nil
+}
+
+// AdminRecoverySheet renders a printable page of client names, recovery
+// codes and QR codes linking to the recovery page, for handing out codes
+// after a bulk import. ?ids= picks the clients; by default the sheet covers
+// every client that has never signed in.
+func (h *Handler) AdminRecoverySheet(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	var clients []db.ClientRecord
+	if ids := c.Query("ids"); ids != "" {
+		for _, id := range strings.Split(ids, ",") {
+			client, err := db.GetClient(h.Store, strings.TrimSpace(id))
+			if err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Client not found", "id": id})
+				return
+			}
+			clients = append(clients, *client)
+		}
+	} else {
+		all, err := db.ListClients(h.Store)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list clients"})
+			return
+		}
+		for _, client := range all {
+			if client.LastActive == 0 && !client.IsAdmin {
+				clients = append(clients, client)
+			}
+		}
+		sort.Slice(clients, func(i, j int) bool { return clients[i].Name < clients[j].Name })
+	}
+
+	base := h.baseURL(c)
+	cards := make([]sheetCard, 0, len(clients))
+	for _, client := range clients {
+		link := base + "/?recover=" + url.QueryEscape(client.RecoveryCode)
+		qr, err := qrSVG(link)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render QR code"})
+			return
+		}
+		cards = append(cards, sheetCard{Name: client.Name, RecoveryCode: client.RecoveryCode, URL: link, QR: qr})
+	}
+
+	c.Header("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	if err := recoverySheet.Execute(c.Writer, cards); err != nil {
+		c.Error(err)
+	}
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestAdminRecoverySheet(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+	h.PublicURL = "https://depot.example"
+
+	router := gin.Default()
+	router.GET("/admin/clients/sheet", h.AdminRecoverySheet)
+
+	db.UpsertClient(h.Store, "admin", "Admin", "CODEA", 0)
+	db.UpdateClientAdminStatus(h.Store, "admin", true)
+	db.UpsertClient(h.Store, "new", "Ada <Lovelace>", "ADA-0001", 0)
+	db.UpsertClient(h.Store, "active", "Alan", "ALAN-0001", 1700000000)
+
+	get := func(clientID, query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/admin/clients/sheet"+query, nil)
+		req.Header.Set("X-Client-ID", clientID)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := get("new", ""); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a client, got %d", w.Code)
+	}
+
+	w := get("admin", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	body := w.Body.String()
+	for _, want := range []string{"Ada &lt;Lovelace&gt;", "ADA-0001", "https://depot.example/?recover=ADA-0001", "<svg"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected the sheet to contain %q", want)
+		}
+	}
+	// Clients that already signed in, and admins, are left off by default
+	if strings.Contains(body, "ALAN-0001") || strings.Contains(body, "CODEA") {
+		t.Error("expected only never-active clients on the default sheet")
+	}
+
+	w = get("admin", "?ids=active")
+	if !strings.Contains(w.Body.String(), "ALAN-0001") || strings.Contains(w.Body.String(), "ADA-0001") {
+		t.Error("expected ?ids= to pick the clients")
+	}
+	if w := get("admin", "?ids=missing"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown client, got %d", w.Code)
+	}
+}
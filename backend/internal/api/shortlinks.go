@@ -0,0 +1,211 @@
+package api
+
+import (
+	"crypto/rand"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	shortSlugAlphabet = "abcdefghijkmnopqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	shortSlugLength   = 4
+)
+
+var shortSlug = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// reservedShortSlugs are top-level paths the depot itself serves, which
+// would be shadowed on a short domain that also serves the API.
+var reservedShortSlugs = map[string]bool{"api": true, "sites": true, "t": true, "s": true, "v2": true, "raw": true}
+
+type shortLinkInfo struct {
+	db.ShortLinkRecord
+	URL string `json:"url"`
+}
+
+// newShortSlug picks a random slug from an alphabet without look-alike
+// characters, growing it when the short ones are taken.
+func (h *Handler) newShortSlug() (string, error) {
+	size := big.NewInt(int64(len(shortSlugAlphabet)))
+	for attempt := 0; ; attempt++ {
+		b := make([]byte, shortSlugLength+attempt/4)
+		for i := range b {
+			n, err := rand.Int(rand.Reader, size)
+			if err != nil {
+				return "", err
+			}
+			b[i] = shortSlugAlphabet[n.Int64()]
+		}
+		slug := string(b)
+		if _, err := db.GetShortLink(h.Store, slug); err != nil && !reservedShortSlugs[slug] {
+			return slug, nil
+		}
+	}
+}
+
+// shortLinkURL is where a short link is handed out: the root of the short
+// domain when one is configured, /s/<slug> on the depot otherwise.
+func (h *Handler) shortLinkURL(c *gin.Context, slug string) string {
+	if h.ShortURL != "" {
+		return h.ShortURL + "/" + slug
+	}
+	return h.baseURL(c) + "/s/" + slug
+}
+
+func (h *Handler) AdminListShortLinks(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	links, err := db.ListShortLinks(h.Store, c.Query("file_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list short links"})
+		return
+	}
+	infos := make([]shortLinkInfo, 0, len(links))
+	for _, link := range links {
+		infos = append(infos, shortLinkInfo{link, h.shortLinkURL(c, link.Slug)})
+	}
+	c.JSON(http.StatusOK, infos)
+}
+
+// AdminCreateShortLink maps a slug to a file. Without a slug a random one is
+// picked; a chosen slug must not be in use.
+func (h *Handler) AdminCreateShortLink(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	var input struct {
+		FileID string `json:"file_id" binding:"required"`
+		Slug   string `json:"slug"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Accept the download link too, as that is what gets copied around
+	record, err := h.findDownloadRecord(input.FileID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+
+	slug := input.Slug
+	if slug == "" {
+		if slug, err = h.newShortSlug(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create short link"})
+			return
+		}
+	} else {
+		if !shortSlug.MatchString(slug) || reservedShortSlugs[slug] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Slug must be letters, digits, dashes or underscores and not a reserved path"})
+			return
+		}
+		if _, err := db.GetShortLink(h.Store, slug); err == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Slug is already taken"})
+			return
+		}
+	}
+
+	link := db.ShortLinkRecord{
+		Slug:      slug,
+		FileID:    record.ID,
+		CreatedBy: c.GetHeader("X-Client-ID"),
+		CreatedAt: h.now().Unix(),
+	}
+	if err := db.SaveShortLink(h.Store, link); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save short link"})
+		return
+	}
+	c.JSON(http.StatusCreated, shortLinkInfo{link, h.shortLinkURL(c, slug)})
+}
+
+func (h *Handler) AdminDeleteShortLink(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	slug := c.Param("slug")
+	if _, err := db.GetShortLink(h.Store, slug); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Short link not found"})
+		return
+	}
+	if err := db.DeleteShortLink(h.Store, slug); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete short link"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
+}
+
+// ResolveShortLink redirects /s/<slug> to the file's download link, which
+// applies the usual link rules (expiry, passwords, landing page).
+func (h *Handler) ResolveShortLink(c *gin.Context) {
+	h.redirectShortLink(c, c.Param("slug"))
+}
+
+func (h *Handler) redirectShortLink(c *gin.Context, slug string) {
+	link, err := db.GetShortLink(h.Store, slug)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Link not found"})
+		return
+	}
+	record, err := db.GetFileRecord(h.Store, link.FileID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	// The short domain may serve nothing but redirects, so point at the API
+	// host when it is known
+	base := h.PublicURL
+	if base == "" {
+		base = h.baseURL(c)
+	}
+	target := base + "/api/download/" + url.PathEscape(record.DownloadLink)
+	if c.Request.URL.RawQuery != "" {
+		target += "?" + c.Request.URL.RawQuery
+	}
+	c.Redirect(http.StatusFound, target)
+}
+
+// ShortDomain serves short links from the root of the short domain, e.g.
+// https://dl.example.com/x7Kq. Requests for other hosts, and anything but
+// a single path segment, pass through.
+func (h *Handler) ShortDomain() gin.HandlerFunc {
+	u, err := url.Parse(h.ShortURL)
+	if h.ShortURL == "" || err != nil || u.Host == "" {
+		return func(c *gin.Context) { c.Next() }
+	}
+	shortHost := strings.ToLower(u.Hostname())
+	return func(c *gin.Context) {
+		host := c.Request.Host
+		if hn, _, err := net.SplitHostPort(host); err == nil {
+			host = hn
+		}
+		slug := strings.TrimPrefix(c.Request.URL.Path, "/")
+		if strings.ToLower(host) != shortHost || strings.Contains(slug, "/") || reservedShortSlugs[slug] {
+			c.Next()
+			return
+		}
+		if slug == "" {
+			// Nothing to resolve at the root; send visitors to the depot
+			if h.PublicURL == "" {
+				c.Next()
+				return
+			}
+			c.Redirect(http.StatusFound, h.PublicURL+"/")
+		} else {
+			h.redirectShortLink(c, slug)
+		}
+		c.Abort()
+	}
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestShortLinks(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+	h.PublicURL = "https://depot.example"
+	h.ShortURL = "https://dl.example"
+
+	router := gin.Default()
+	router.Use(h.ShortDomain())
+	router.POST("/upload", h.UploadFile)
+	router.GET("/admin/shortlinks", h.AdminListShortLinks)
+	router.POST("/admin/shortlinks", h.AdminCreateShortLink)
+	router.DELETE("/admin/shortlinks/:slug", h.AdminDeleteShortLink)
+	router.GET("/s/:slug", h.ResolveShortLink)
+
+	db.UpsertClient(h.Store, "admin", "Admin", "CODEA", 0)
+	db.UpdateClientAdminStatus(h.Store, "admin", true)
+	fileID := uploadTestFile(t, router, "client-a", "logo.png", []byte("png"))["id"].(string)
+	record, _ := db.GetFileRecord(h.Store, fileID)
+
+	create := func(clientID, body string) (int, shortLinkInfo) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/admin/shortlinks", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Client-ID", clientID)
+		router.ServeHTTP(w, req)
+		var info shortLinkInfo
+		json.Unmarshal(w.Body.Bytes(), &info)
+		return w.Code, info
+	}
+
+	if code, _ := create("client-a", `{"file_id":"`+fileID+`"}`); code != http.StatusForbidden {
+		t.Errorf("expected 403 for a client, got %d", code)
+	}
+	code, info := create("admin", `{"file_id":"`+fileID+`","slug":"x7Kq"}`)
+	if code != http.StatusCreated || info.URL != "https://dl.example/x7Kq" || info.FileID != fileID {
+		t.Fatalf("unexpected short link %d %+v", code, info)
+	}
+	if code, _ := create("admin", `{"file_id":"`+fileID+`","slug":"x7Kq"}`); code != http.StatusConflict {
+		t.Errorf("expected 409 for a taken slug, got %d", code)
+	}
+	if code, _ := create("admin", `{"file_id":"`+fileID+`","slug":"api"}`); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a reserved slug, got %d", code)
+	}
+	// Download links work as well as file IDs
+	code, random := create("admin", `{"file_id":"`+record.DownloadLink+`"}`)
+	if code != http.StatusCreated || len(random.Slug) != shortSlugLength {
+		t.Errorf("expected a generated slug, got %d %+v", code, random)
+	}
+
+	want := "https://depot.example/api/download/" + record.DownloadLink
+	get := func(host, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		req.Host = host
+		router.ServeHTTP(w, req)
+		return w
+	}
+	for _, tc := range []struct{ host, path string }{
+		{"dl.example", "/x7Kq"},
+		{"DL.example:443", "/x7Kq"},
+		{"depot.example", "/s/x7Kq"},
+	} {
+		w := get(tc.host, tc.path)
+		if w.Code != http.StatusFound || w.Header().Get("Location") != want {
+			t.Errorf("%s%s: expected a redirect to %s, got %d %q", tc.host, tc.path, want, w.Code, w.Header().Get("Location"))
+		}
+	}
+	if w := get("dl.example", "/x7Kq?confirm=1"); !strings.HasSuffix(w.Header().Get("Location"), "?confirm=1") {
+		t.Errorf("expected the query to be kept, got %q", w.Header().Get("Location"))
+	}
+	// Other hosts don't resolve slugs at the root
+	if w := get("depot.example", "/x7Kq"); w.Code != http.StatusNotFound || w.Header().Get("Location") != "" {
+		t.Errorf("expected the main host to pass through, got %d", w.Code)
+	}
+	if w := get("dl.example", "/nope"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown slug, got %d", w.Code)
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/admin/shortlinks/x7Kq", nil)
+	req.Header.Set("X-Client-ID", "admin")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected the delete to succeed, got %d", w.Code)
+	}
+	if w := get("dl.example", "/x7Kq"); w.Code != http.StatusNotFound {
+		t.Errorf("expected a deleted slug to stop resolving, got %d", w.Code)
+	}
+	links, _ := db.ListShortLinks(h.Store, fileID)
+	if len(links) != 1 || links[0].Slug != random.Slug {
+		t.Errorf("expected only the generated link left, got %+v", links)
+	}
+}
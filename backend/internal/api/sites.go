@@ -0,0 +1,200 @@
+package api
+
+import (
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+var siteSlug = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// siteSandbox runs published pages in an opaque origin, so their scripts
+// cannot read the persona stored by the depot frontend on the same host.
+const siteSandbox = "sandbox allow-scripts allow-forms allow-popups allow-downloads"
+
+func (h *Handler) ListSites(c *gin.Context) {
+	ownerID := c.GetHeader("X-Client-ID")
+	if ownerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
+		return
+	}
+	if h.isAdmin(c) && c.Query("all") == "true" {
+		ownerID = ""
+	}
+
+	sites, err := db.ListSites(h.Store, ownerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list sites"})
+		return
+	}
+	c.JSON(http.StatusOK, sites)
+}
+
+// PublishSite serves one of the client's folders at /sites/<slug>/.
+func (h *Handler) PublishSite(c *gin.Context) {
+	ownerID := c.GetHeader("X-Client-ID")
+	if ownerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
+		return
+	}
+	slug := c.Param("slug")
+	if !siteSlug.MatchString(slug) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Slug must be lowercase letters, digits or dashes"})
+		return
+	}
+
+	var input struct {
+		Folder string `json:"folder" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	folder := db.NormalizeFolder(input.Folder)
+	if folder == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A folder is required"})
+		return
+	}
+
+	if existing, err := db.GetSite(h.Store, slug); err == nil && existing.OwnerID != ownerID {
+		c.JSON(http.StatusConflict, gin.H{"error": "Slug is already taken"})
+		return
+	}
+
+	site := db.SiteRecord{
+		Slug:      slug,
+		OwnerID:   ownerID,
+		Folder:    folder,
+		CreatedAt: time.Now().Unix(),
+	}
+	if err := db.SaveSite(h.Store, site); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to publish site"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"site": site, "url": h.baseURL(c) + "/sites/" + slug + "/"})
+}
+
+func (h *Handler) UnpublishSite(c *gin.Context) {
+	site, err := db.GetSite(h.Store, c.Param("slug"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Site not found"})
+		return
+	}
+	if site.OwnerID != c.GetHeader("X-Client-ID") && !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to unpublish this site"})
+		return
+	}
+
+	if err := db.DeleteSite(h.Store, site.Slug); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unpublish site"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// ServeSite serves a file of a published folder. Directory paths serve their
+// index.html, and a 404.html in the site root is used for missing pages.
+func (h *Handler) ServeSite(c *gin.Context) {
+	site, err := db.GetSite(h.Store, c.Param("slug"))
+	if err != nil {
+		c.String(http.StatusNotFound, "Site not found")
+		return
+	}
+
+	raw := c.Param("path")
+	rel := strings.TrimPrefix(path.Clean("/"+raw), "/")
+	if rel == "" || strings.HasSuffix(raw, "/") {
+		rel = path.Join(rel, "index.html")
+	}
+
+	record, err := h.siteFile(site, rel)
+	if err != nil && path.Ext(rel) == "" {
+		// A directory requested without its trailing slash. The redirect is
+		// relative so it also works under a tenant prefix, which is why it
+		// bypasses http.Redirect.
+		if _, err := h.siteFile(site, rel+"/index.html"); err == nil {
+			c.Header("Location", path.Base(rel)+"/")
+			c.Status(http.StatusMovedPermanently)
+			return
+		}
+	}
+	status := http.StatusOK
+	if err != nil {
+		if record, err = h.siteFile(site, "404.html"); err != nil {
+			c.String(http.StatusNotFound, "Page not found")
+			return
+		}
+		status = http.StatusNotFound
+	}
+
+	contentType := mime.TypeByExtension(filepath.Ext(record.OriginalName))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	c.Header("Content-Security-Policy", siteSandbox)
+	c.Header("X-Content-Type-Options", "nosniff")
+
+	if storage.IsS3Path(record.StoredPath) {
+		if h.S3 == nil {
+			c.String(http.StatusServiceUnavailable, "S3 storage is not configured")
+			return
+		}
+		q := url.Values{}
+		q.Set("response-content-type", contentType)
+		q.Set("response-content-disposition", "inline")
+		target, err := h.S3.PresignGet(h.S3.KeyFromPath(record.StoredPath), q, downloadURLExpiry)
+		if err != nil {
+			c.String(http.StatusInternalServerError, "Failed to sign URL")
+			return
+		}
+		c.Redirect(http.StatusFound, target)
+		return
+	}
+
+	if status != http.StatusOK {
+		page, err := os.ReadFile(record.StoredPath)
+		if err != nil {
+			c.String(http.StatusNotFound, "Page not found")
+			return
+		}
+		c.Data(status, contentType, page)
+		return
+	}
+
+	f, err := os.Open(record.StoredPath)
+	if err != nil {
+		c.String(http.StatusNotFound, "Page not found")
+		return
+	}
+	defer f.Close()
+
+	c.Header("Content-Type", contentType)
+	if record.SHA256 != "" {
+		c.Header("ETag", `"`+record.SHA256+`"`)
+	}
+	http.ServeContent(c.Writer, c.Request, record.OriginalName, time.Unix(record.UploadTime, 0), storage.ContextReadSeeker(c.Request.Context(), f))
+}
+
+// siteFile finds a servable file of the site. Quarantined and expired files
+// are treated as missing.
+func (h *Handler) siteFile(site *db.SiteRecord, rel string) (*db.FileRecord, error) {
+	record, err := db.FindFolderFile(h.Store, site.OwnerID, site.Folder, rel)
+	if err != nil {
+		return nil, err
+	}
+	if record.Quarantined || (record.ExpiresAt > 0 && time.Now().Unix() > record.ExpiresAt) {
+		return nil, os.ErrNotExist
+	}
+	return record, nil
+}
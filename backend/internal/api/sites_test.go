@@ -0,0 +1,86 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPublishedSite(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	router := gin.Default()
+	router.PUT("/upload/raw", h.UploadRaw)
+	router.PUT("/persona/sites/:slug", h.PublishSite)
+	router.GET("/sites/:slug/*path", h.ServeSite)
+
+	upload := func(folder, name, content string) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/upload/raw?folder="+folder, strings.NewReader(content))
+		req.Header.Set("X-Client-ID", "client-a")
+		req.Header.Set("X-Filename", name)
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("upload failed: %s", w.Body.String())
+		}
+	}
+	upload("report", "index.html", "<h1>Report</h1>")
+	upload("report/css", "site.css", "h1 { color: red }")
+	upload("report/charts", "index.html", "<p>Charts</p>")
+	upload("other", "secret.txt", "not published")
+
+	publish := func(clientID, slug, folder string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/persona/sites/"+slug, bytes.NewBufferString(`{"folder": "`+folder+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Client-ID", clientID)
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	if code := publish("client-a", "q3-report", "report"); code != http.StatusOK {
+		t.Fatalf("expected 200 publishing site, got %d", code)
+	}
+	if code := publish("client-b", "q3-report", "mine"); code != http.StatusConflict {
+		t.Errorf("expected 409 for a taken slug, got %d", code)
+	}
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/sites/q3-report/")
+	if w.Code != http.StatusOK || w.Body.String() != "<h1>Report</h1>" {
+		t.Fatalf("expected index.html, got %d %q", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("expected text/html, got %q", ct)
+	}
+	if csp := w.Header().Get("Content-Security-Policy"); !strings.HasPrefix(csp, "sandbox") {
+		t.Errorf("expected sandboxing CSP, got %q", csp)
+	}
+
+	w = get("/sites/q3-report/css/site.css")
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/css") {
+		t.Errorf("expected text/css, got %q", ct)
+	}
+
+	w = get("/sites/q3-report/charts")
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "charts/" {
+		t.Errorf("expected redirect to charts/, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+
+	if w = get("/sites/q3-report/../other/secret.txt"); w.Code != http.StatusNotFound {
+		t.Errorf("expected files outside the folder to be hidden, got %d", w.Code)
+	}
+	if w = get("/sites/missing/"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown site, got %d", w.Code)
+	}
+}
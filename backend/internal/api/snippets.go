@@ -0,0 +1,229 @@
+package api
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	maxSnippetSize   = 1 << 20
+	snippetPassIters = 100_000
+)
+
+var snippetLanguage = regexp.MustCompile(`^[a-z0-9+#._-]{1,32}$`)
+
+type snippetResponse struct {
+	ID           string `json:"id"`
+	Title        string `json:"title"`
+	Language     string `json:"language"`
+	Content      string `json:"content,omitempty"`
+	Size         int64  `json:"size"`
+	OwnerID      string `json:"owner_id"`
+	DownloadLink string `json:"download_link"`
+	IsPublic     bool   `json:"is_public"`
+	HasPassword  bool   `json:"has_password"`
+	UploadTime   int64  `json:"upload_time"`
+	ExpiresAt    int64  `json:"expires_at,omitempty"`
+}
+
+func newSnippetResponse(record *db.FileRecord, snippet *db.SnippetRecord) snippetResponse {
+	return snippetResponse{
+		ID:           record.ID,
+		Title:        snippet.Title,
+		Language:     snippet.Language,
+		Size:         record.Size,
+		OwnerID:      record.OwnerID,
+		DownloadLink: record.DownloadLink,
+		IsPublic:     record.IsPublic,
+		HasPassword:  snippet.HasPassword(),
+		UploadTime:   record.UploadTime,
+		ExpiresAt:    record.ExpiresAt,
+	}
+}
+
+func hashPassword(password string, salt []byte) string {
+	key, _ := pbkdf2.Key(sha256.New, password, salt, snippetPassIters, 32)
+	return hex.EncodeToString(key)
+}
+
+func (h *Handler) CreateSnippet(c *gin.Context) {
+	ownerID := c.GetHeader("X-Client-ID")
+	if ownerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
+		return
+	}
+
+	var input struct {
+		Content   string `json:"content" binding:"required"`
+		Title     string `json:"title"`
+		Language  string `json:"language"`
+		ExpiresIn int64  `json:"expires_in"`
+		Password  string `json:"password"`
+		IsPublic  bool   `json:"is_public"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(input.Content) > maxSnippetSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Snippet is too large"})
+		return
+	}
+	if input.Language == "" {
+		input.Language = "text"
+	}
+	input.Language = strings.ToLower(input.Language)
+	if !snippetLanguage.MatchString(input.Language) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid language"})
+		return
+	}
+	if input.ExpiresIn < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in must not be negative"})
+		return
+	}
+
+	// Snippets go through the regular upload pipeline, so quotas, policies
+	// and hooks apply to them too
+	name := input.Title
+	if name == "" {
+		name = "snippet"
+	}
+	if filepath.Ext(name) == "" {
+		name += ".txt"
+	}
+
+	id := uuid.New().String()
+	storedPath, size, hash, err := storage.StoreFile(strings.NewReader(input.Content), h.TempDir, h.StorageDir, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store snippet: " + err.Error()})
+		return
+	}
+	record, err := h.ingest(c.Request.Context(), stagedFile{ID: id, Path: storedPath, Size: size, SHA256: hash}, ingestOptions{
+		OwnerID:    ownerID,
+		RemoteAddr: c.ClientIP(),
+		Name:       name,
+		IsPublic:   input.IsPublic,
+		IsAdmin:    h.isAdmin(c),
+	})
+	if err != nil {
+		respondIngestError(c, err)
+		return
+	}
+
+	if input.ExpiresIn > 0 {
+		record.ExpiresAt = time.Now().Unix() + input.ExpiresIn
+		if err := db.SaveFileRecord(h.Store, *record); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save snippet"})
+			return
+		}
+	}
+
+	snippet := db.SnippetRecord{FileID: record.ID, Title: input.Title, Language: input.Language}
+	if input.Password != "" {
+		salt := make([]byte, 16)
+		rand.Read(salt)
+		snippet.PasswordSalt = hex.EncodeToString(salt)
+		snippet.PasswordHash = hashPassword(input.Password, salt)
+	}
+	if err := db.SaveSnippet(h.Store, snippet); err != nil {
+		_ = db.DeleteFileRecord(h.Store, record.ID)
+		_ = h.releaseStoredFile(record)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save snippet"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, newSnippetResponse(record, &snippet))
+}
+
+// loadSnippet resolves a snippet by ID or download link and checks expiry and
+// password. It writes the error response itself and returns false on failure.
+func (h *Handler) loadSnippet(c *gin.Context) (*db.FileRecord, *db.SnippetRecord, bool) {
+	record, err := h.findDownloadRecord(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Snippet not found"})
+		return nil, nil, false
+	}
+	snippet, err := db.GetSnippet(h.Store, record.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Snippet not found"})
+		return nil, nil, false
+	}
+
+	privileged := record.OwnerID == c.GetHeader("X-Client-ID") || h.isAdmin(c)
+	if record.ExpiresAt > 0 && time.Now().Unix() > record.ExpiresAt && !privileged {
+		c.JSON(http.StatusGone, gin.H{"error": "Snippet has expired"})
+		return nil, nil, false
+	}
+	if !privileged && !h.snippetUnlocked(c, snippet) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Snippet password required"})
+		return nil, nil, false
+	}
+	return record, snippet, true
+}
+
+// snippetUnlocked checks the password sent in the X-Snippet-Password header
+// or the password query parameter.
+func (h *Handler) snippetUnlocked(c *gin.Context, snippet *db.SnippetRecord) bool {
+	if !snippet.HasPassword() {
+		return true
+	}
+	password := c.GetHeader("X-Snippet-Password")
+	if password == "" {
+		password = c.Query("password")
+	}
+	salt, err := hex.DecodeString(snippet.PasswordSalt)
+	if err != nil || password == "" {
+		return false
+	}
+	got := hashPassword(password, salt)
+	return subtle.ConstantTimeCompare([]byte(got), []byte(snippet.PasswordHash)) == 1
+}
+
+func (h *Handler) GetSnippet(c *gin.Context) {
+	record, snippet, ok := h.loadSnippet(c)
+	if !ok {
+		return
+	}
+
+	content, err := os.ReadFile(record.StoredPath)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read snippet"})
+		return
+	}
+
+	resp := newSnippetResponse(record, snippet)
+	resp.Content = string(content)
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetSnippetRaw serves the snippet text inline, for browsers and curl.
+func (h *Handler) GetSnippetRaw(c *gin.Context) {
+	record, _, ok := h.loadSnippet(c)
+	if !ok {
+		return
+	}
+
+	content, err := os.ReadFile(record.StoredPath)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read snippet"})
+		return
+	}
+
+	c.Header("Content-Disposition", "inline")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", content)
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestSnippetLifecycle(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	router := gin.Default()
+	router.POST("/snippets", h.CreateSnippet)
+	router.GET("/snippets/:id", h.GetSnippet)
+	router.GET("/snippets/:id/raw", h.GetSnippetRaw)
+	router.GET("/download/:id", h.DownloadFile)
+
+	get := func(path, clientID, password string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		req.Header.Set("X-Client-ID", clientID)
+		if password != "" {
+			req.Header.Set("X-Snippet-Password", password)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := httptest.NewRecorder()
+	body := `{"content": "fmt.Println(\"hi\")", "title": "hello.go", "language": "Go", "password": "hunter2"}`
+	req, _ := http.NewRequest("POST", "/snippets", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Client-ID", "client-a")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create failed: %d %s", w.Code, w.Body.String())
+	}
+	var created snippetResponse
+	json.Unmarshal(w.Body.Bytes(), &created)
+	if created.Language != "go" || !created.HasPassword || created.Content != "" {
+		t.Fatalf("unexpected snippet %+v", created)
+	}
+
+	link := "/snippets/" + created.DownloadLink
+	if w := get(link, "client-b", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected password to be required, got %d", w.Code)
+	}
+	if w := get(link, "client-b", "wrong"); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected wrong password to be refused, got %d", w.Code)
+	}
+	if w := get("/download/"+created.DownloadLink, "client-b", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected plain download to require the password, got %d", w.Code)
+	}
+
+	w = get(link, "client-b", "hunter2")
+	var got snippetResponse
+	json.Unmarshal(w.Body.Bytes(), &got)
+	if w.Code != http.StatusOK || got.Content != `fmt.Println("hi")` {
+		t.Errorf("expected snippet content, got %d %s", w.Code, w.Body.String())
+	}
+
+	w = get(link+"/raw?password=hunter2", "client-b", "")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/plain; charset=utf-8" ||
+		w.Header().Get("Content-Disposition") != "inline" {
+		t.Errorf("expected inline plain text, got %d %v", w.Code, w.Header())
+	}
+
+	// The owner needs no password, and still sees the snippet after expiry
+	record, _ := db.GetFileRecord(h.Store, created.ID)
+	record.ExpiresAt = 1
+	db.SaveFileRecord(h.Store, *record)
+	if w := get(link, "client-b", "hunter2"); w.Code != http.StatusGone {
+		t.Errorf("expected expired snippet to be gone, got %d", w.Code)
+	}
+	if w := get(link, "client-a", ""); w.Code != http.StatusOK {
+		t.Errorf("expected owner access, got %d", w.Code)
+	}
+}
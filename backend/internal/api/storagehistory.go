@@ -0,0 +1,99 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/tenant"
+	"github.com/gin-gonic/gin"
+)
+
+// SnapshotStorage records how much every client stores today, and the
+// depot as a whole, for the root depot and each tenant. Snapshots older
+// than the longest history that can be asked for are dropped.
+func (h *Handler) SnapshotStorage(ctx context.Context) error {
+	now := h.now()
+	if err := snapshotStorage(ctx, h.Store, now); err != nil {
+		return err
+	}
+	if h.Tenants != nil {
+		tenants, err := db.ListTenants(h.Store)
+		if err != nil {
+			return err
+		}
+		for _, t := range tenants {
+			if err := snapshotStorage(ctx, tenant.Scope(h.Store, t.ID), now); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// snapshotStorage snapshots one depot's store. Clients without files get a
+// zero snapshot so their trend reaches zero.
+func snapshotStorage(ctx context.Context, store db.CelerixStore, now time.Time) error {
+	files, err := db.GetAllFileRecords(store)
+	if err != nil {
+		return err
+	}
+	day := db.StorageDay(now)
+	snaps := map[string]*db.StorageSnapshot{}
+	if clients, err := db.ListClients(store); err == nil {
+		for _, client := range clients {
+			snaps[client.ID] = &db.StorageSnapshot{Day: day, ClientID: client.ID}
+		}
+	}
+	total := &db.StorageSnapshot{Day: day, Bytes: db.StorageUsed(store), Files: len(files)}
+	for _, f := range files {
+		owner := f.OwnerID
+		if owner == "" {
+			owner = db.SystemPersona
+		}
+		snap, ok := snaps[owner]
+		if !ok {
+			snap = &db.StorageSnapshot{Day: day, ClientID: owner}
+			snaps[owner] = snap
+		}
+		snap.Bytes += f.Size
+		snap.Files++
+	}
+	snaps[""] = total
+	for _, snap := range snaps {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := db.SaveStorageSnapshot(store, *snap); err != nil {
+			return err
+		}
+	}
+	_, err = db.PruneStorageSnapshots(store, db.StorageDay(now.AddDate(0, 0, -maxAnalyticsDays)))
+	return err
+}
+
+// GetUsageHistory returns the caller's daily storage snapshots over the
+// last ?days= (default 90), oldest first. Days the snapshot job didn't run
+// are missing.
+func (h *Handler) GetUsageHistory(c *gin.Context) {
+	clientID := c.GetHeader("X-Client-ID")
+	if clientID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
+		return
+	}
+	days, err := strconv.Atoi(c.DefaultQuery("days", "90"))
+	if err != nil || days < 1 || days > maxAnalyticsDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "days must be between 1 and 366"})
+		return
+	}
+
+	since := db.StorageDay(h.now().AddDate(0, 0, -days+1))
+	snaps, err := db.ListStorageSnapshots(h.Store, clientID, since)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list storage history"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"days": days, "snapshots": snaps})
+}
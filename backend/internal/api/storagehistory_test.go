@@ -0,0 +1,73 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestStorageHistory(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	h.Now = func() time.Time { return now }
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.GET("/persona/usage/history", h.GetUsageHistory)
+
+	db.UpsertClient(h.Store, "client-b", "Bea", "CODEB", 0)
+	uploadTestFile(t, router, "client-a", "first.txt", []byte("first"))
+	uploadTestFile(t, router, "client-a", "second.txt", []byte("second file"))
+	// Outside the default window, and due for pruning
+	db.SaveStorageSnapshot(h.Store, db.StorageSnapshot{Day: "2026-06-01", ClientID: "client-a", Bytes: 1})
+	db.SaveStorageSnapshot(h.Store, db.StorageSnapshot{Day: "2025-01-01", ClientID: "client-a", Bytes: 1})
+
+	if err := h.SnapshotStorage(context.Background()); err != nil {
+		t.Fatalf("snapshot failed: %v", err)
+	}
+	// A second run the same day replaces the first
+	if err := h.SnapshotStorage(context.Background()); err != nil {
+		t.Fatalf("snapshot failed: %v", err)
+	}
+
+	get := func(clientID, query string) (*httptest.ResponseRecorder, []db.StorageSnapshot) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/persona/usage/history"+query, nil)
+		if clientID != "" {
+			req.Header.Set("X-Client-ID", clientID)
+		}
+		router.ServeHTTP(w, req)
+		var resp struct {
+			Snapshots []db.StorageSnapshot `json:"snapshots"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w, resp.Snapshots
+	}
+
+	if w, _ := get("", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without a client, got %d", w.Code)
+	}
+	if w, _ := get("client-a", "?days=0"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for days=0, got %d", w.Code)
+	}
+
+	_, snaps := get("client-a", "")
+	if len(snaps) != 1 || snaps[0].Day != "2026-10-16" || snaps[0].Bytes != 16 || snaps[0].Files != 2 {
+		t.Errorf("expected today's snapshot only, got %+v", snaps)
+	}
+	_, snaps = get("client-a", "?days=366")
+	if len(snaps) != 2 || snaps[0].Day != "2026-06-01" {
+		t.Errorf("expected the June snapshot first and last year's pruned, got %+v", snaps)
+	}
+	_, snaps = get("client-b", "")
+	if len(snaps) != 1 || snaps[0].Bytes != 0 {
+		t.Errorf("expected a zero snapshot for a client without files, got %+v", snaps)
+	}
+}
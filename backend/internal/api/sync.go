@@ -0,0 +1,367 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// The sync API lets an agent mirror a local directory to a folder (the sync
+// root) of the client's files. Files are addressed by their path below the
+// root. Writes are conditional on the content the agent last saw, sent as
+// X-Base-SHA256 (empty meaning "no file"), so concurrent edits surface as
+// 409 conflicts instead of being overwritten.
+
+const (
+	syncPut    = "put"
+	syncDelete = "delete"
+
+	maxSyncChanges = 1000
+	maxSyncStat    = 1000
+)
+
+// syncEntry describes a file at a path below the sync root.
+type syncEntry struct {
+	Path         string `json:"path"`
+	FileID       string `json:"file_id"`
+	SHA256       string `json:"sha256"`
+	Size         int64  `json:"size"`
+	Modified     int64  `json:"modified"`
+	DownloadLink string `json:"download_link"`
+}
+
+type syncChange struct {
+	Seq int64  `json:"seq"`
+	Op  string `json:"op"`
+	syncEntry
+}
+
+// syncPath returns the record's path below root, and false when the record
+// is outside it.
+func syncPath(record *db.FileRecord, root string) (string, bool) {
+	if root != "" && !record.InFolder(root) {
+		return "", false
+	}
+	rel := strings.TrimPrefix(strings.TrimPrefix(record.Folder, root), "/")
+	return strings.TrimPrefix(rel+"/"+record.OriginalName, "/"), true
+}
+
+func newSyncEntry(record *db.FileRecord, rel string) syncEntry {
+	return syncEntry{
+		Path:         rel,
+		FileID:       record.ID,
+		SHA256:       record.SHA256,
+		Size:         record.Size,
+		Modified:     record.UploadTime,
+		DownloadLink: record.DownloadLink,
+	}
+}
+
+// syncRelPath cleans a path sent by an agent. It reports false for paths
+// that don't name a file.
+func syncRelPath(p string) (string, bool) {
+	rel := strings.Trim(path.Clean("/"+strings.ReplaceAll(p, `\`, "/")), "/")
+	return rel, rel != "" && rel != "."
+}
+
+// syncBase returns the X-Base-SHA256 header and whether it was sent.
+func syncBase(c *gin.Context) (string, bool) {
+	values := c.Request.Header.Values("X-Base-SHA256")
+	if len(values) == 0 {
+		return "", false
+	}
+	return strings.TrimSpace(values[0]), true
+}
+
+// syncConflict reports whether the agent's base doesn't match the current
+// file, and writes the 409 response if so.
+func syncConflict(c *gin.Context, current *db.FileRecord, rel string) bool {
+	base, ok := syncBase(c)
+	if !ok {
+		return false
+	}
+	have := ""
+	if current != nil {
+		have = current.SHA256
+	}
+	if base == have {
+		return false
+	}
+	var entry *syncEntry
+	if current != nil {
+		e := newSyncEntry(current, rel)
+		entry = &e
+	}
+	c.JSON(http.StatusConflict, gin.H{"error": "File was changed by someone else", "current": entry})
+	return true
+}
+
+// GetSyncChanges returns what changed below the sync root since the cursor.
+// Without a cursor it returns every file as a put, plus the cursor to
+// continue from. A file's changes are folded into its latest state; files
+// that left the root show up as deletes.
+func (h *Handler) GetSyncChanges(c *gin.Context) {
+	ownerID := c.GetHeader("X-Client-ID")
+	if ownerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
+		return
+	}
+	root := db.NormalizeFolder(c.Query("root"))
+	head, floor := db.ChangeCursor(h.Store)
+
+	if c.Query("cursor") == "" {
+		files, err := db.ListOwnerFiles(h.Store, ownerID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list files"})
+			return
+		}
+		changes := []syncChange{}
+		for i := range files {
+			if rel, ok := syncPath(&files[i], root); ok {
+				changes = append(changes, syncChange{Op: syncPut, syncEntry: newSyncEntry(&files[i], rel)})
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"snapshot": true, "changes": changes, "cursor": head, "has_more": false})
+		return
+	}
+
+	cursor, err := strconv.ParseInt(c.Query("cursor"), 10, 64)
+	if err != nil || cursor < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cursor must be a number"})
+		return
+	}
+	if cursor+1 < floor {
+		c.JSON(http.StatusGone, gin.H{"error": "Cursor is too old; start over without one"})
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(maxSyncChanges)))
+	if limit < 1 || limit > maxSyncChanges {
+		limit = maxSyncChanges
+	}
+
+	entries, err := db.ListChanges(h.Store, ownerID, cursor, limit+1)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list changes"})
+		return
+	}
+	hasMore := len(entries) > limit
+	if hasMore {
+		entries = entries[:limit]
+	}
+
+	last := make(map[string]int64, len(entries))
+	for _, e := range entries {
+		last[e.FileID] = e.Seq
+	}
+	changes := []syncChange{}
+	for _, e := range entries {
+		if last[e.FileID] != e.Seq {
+			continue
+		}
+		change := syncChange{Seq: e.Seq, Op: syncDelete, syncEntry: syncEntry{FileID: e.FileID, SHA256: e.SHA256, Size: e.Size}}
+		snapshot := db.FileRecord{ID: e.FileID, OriginalName: e.Name, Folder: e.Folder}
+		if rel, ok := syncPath(&snapshot, root); ok {
+			change.Path = rel
+		}
+		if e.Op != db.ChangeDelete && e.OwnerID == ownerID {
+			if record, err := db.GetFileRecord(h.Store, e.FileID); err == nil {
+				if rel, ok := syncPath(record, root); ok {
+					change.Op = syncPut
+					change.syncEntry = newSyncEntry(record, rel)
+				}
+			}
+		}
+		changes = append(changes, change)
+	}
+
+	next := head
+	if hasMore {
+		next = entries[len(entries)-1].Seq
+	} else if len(entries) > 0 {
+		next = max(head, entries[len(entries)-1].Seq)
+	}
+	c.JSON(http.StatusOK, gin.H{"snapshot": false, "changes": changes, "cursor": max(next, cursor), "has_more": hasMore})
+}
+
+// SyncStat looks up many paths below the sync root in one call. Missing
+// paths map to null.
+func (h *Handler) SyncStat(c *gin.Context) {
+	ownerID := c.GetHeader("X-Client-ID")
+	if ownerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
+		return
+	}
+	var input struct {
+		Root  string   `json:"root"`
+		Paths []string `json:"paths" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(input.Paths) > maxSyncStat {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Too many paths; send at most " + strconv.Itoa(maxSyncStat)})
+		return
+	}
+	root := db.NormalizeFolder(input.Root)
+
+	entries := make(map[string]*syncEntry, len(input.Paths))
+	for _, p := range input.Paths {
+		rel, ok := syncRelPath(p)
+		if !ok {
+			continue
+		}
+		entries[p] = nil
+		if record, err := db.FindFolderFile(h.Store, ownerID, root, rel); err == nil {
+			e := newSyncEntry(record, rel)
+			entries[p] = &e
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
+
+// SyncPut stores the request body at a path below the sync root, replacing
+// the file that was there.
+func (h *Handler) SyncPut(c *gin.Context) {
+	ownerID := c.GetHeader("X-Client-ID")
+	if ownerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
+		return
+	}
+	root := db.NormalizeFolder(c.Query("root"))
+	rel, ok := syncRelPath(c.Query("path"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A file path is required"})
+		return
+	}
+	dir, name := path.Split(rel)
+
+	body := c.Request.Body
+	if limit := db.GetSettings(h.Store).MaxUploadBytes; limit > 0 {
+		body = http.MaxBytesReader(c.Writer, body, limit)
+	}
+	id := uuid.New().String()
+	storedPath, size, hash, err := storage.StoreFile(storage.ContextReader(c.Request.Context(), body), h.TempDir, h.StorageDir, id)
+	if err != nil {
+		if timedOut(c, err) {
+			return
+		}
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File exceeds the maximum upload size"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file: " + err.Error()})
+		return
+	}
+
+	// Checking the base and replacing the file must not interleave with
+	// another agent writing the same path, on this replica or another
+	folder := db.NormalizeFolder(root + "/" + dir)
+	claim := nameClaim(ownerID, folder, name)
+	if !h.names.start(h.Store, claim) {
+		h.deleteStored(storedPath)
+		c.JSON(http.StatusConflict, gin.H{"error": "Another write of " + rel + " is in progress"})
+		return
+	}
+	defer h.names.done(claim)
+
+	current, err := db.FindFolderFile(h.Store, ownerID, root, rel)
+	if err != nil {
+		current = nil
+	}
+	if syncConflict(c, current, rel) {
+		h.deleteStored(storedPath)
+		return
+	}
+	if current != nil && current.SHA256 == hash {
+		h.deleteStored(storedPath)
+		c.JSON(http.StatusOK, newSyncEntry(current, rel))
+		return
+	}
+	if current != nil && current.Metadata["artifact"] != "" {
+		h.deleteStored(storedPath)
+		c.JSON(http.StatusConflict, gin.H{"error": "Published artifacts cannot be replaced"})
+		return
+	}
+	if current != nil && h.retained(current) {
+		h.deleteStored(storedPath)
+		h.refuseRetained(c, current)
+		return
+	}
+
+	record, err := h.ingest(c.Request.Context(), stagedFile{ID: id, Path: storedPath, Size: size, SHA256: hash}, ingestOptions{
+		OwnerID:    ownerID,
+		RemoteAddr: c.ClientIP(),
+		Name:       name,
+		// Agents find files by path, so the name has to come back as sent
+		KeepName: true,
+		IsAdmin:  h.isAdmin(c),
+		Folder:   folder,
+	})
+	if err != nil {
+		respondIngestError(c, err)
+		return
+	}
+	if current != nil {
+		if err := h.removeFile(current); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to replace the previous file"})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, newSyncEntry(record, rel))
+}
+
+// SyncDelete deletes the file at a path below the sync root.
+func (h *Handler) SyncDelete(c *gin.Context) {
+	ownerID := c.GetHeader("X-Client-ID")
+	if ownerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Client-ID header is required"})
+		return
+	}
+	root := db.NormalizeFolder(c.Query("root"))
+	rel, ok := syncRelPath(c.Query("path"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A file path is required"})
+		return
+	}
+
+	dir, name := path.Split(rel)
+	claim := nameClaim(ownerID, db.NormalizeFolder(root+"/"+dir), name)
+	if !h.names.start(h.Store, claim) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Another write of " + rel + " is in progress"})
+		return
+	}
+	defer h.names.done(claim)
+
+	current, err := db.FindFolderFile(h.Store, ownerID, root, rel)
+	if err != nil {
+		current = nil
+	}
+	if syncConflict(c, current, rel) {
+		return
+	}
+	if current == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	if current.Metadata["artifact"] != "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Published artifacts cannot be deleted"})
+		return
+	}
+	if h.refuseRetained(c, current) {
+		return
+	}
+	if err := h.removeFile(current); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete file record"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
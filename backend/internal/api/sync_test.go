@@ -0,0 +1,142 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestSyncAPI(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	router := gin.Default()
+	router.GET("/sync/changes", h.GetSyncChanges)
+	router.POST("/sync/stat", h.SyncStat)
+	router.PUT("/sync/file", h.SyncPut)
+	router.DELETE("/sync/file", h.SyncDelete)
+
+	do := func(method, url, body string, base *string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+		req.Header.Set("X-Client-ID", "client-a")
+		if base != nil {
+			req.Header.Set("X-Base-SHA256", *base)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+	none := ""
+
+	w := do("GET", "/sync/changes?root=sync", "", nil)
+	var snap struct {
+		Snapshot bool         `json:"snapshot"`
+		Changes  []syncChange `json:"changes"`
+		Cursor   int64        `json:"cursor"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &snap)
+	if !snap.Snapshot || len(snap.Changes) != 0 {
+		t.Fatalf("expected an empty snapshot, got %s", w.Body.String())
+	}
+
+	w = do("PUT", "/sync/file?root=sync&path=docs/a.txt", "one", &none)
+	if w.Code != http.StatusOK {
+		t.Fatalf("put failed: %s", w.Body.String())
+	}
+	var first syncEntry
+	json.Unmarshal(w.Body.Bytes(), &first)
+
+	// Creating a file that exists conflicts, and so does a stale base
+	if w := do("PUT", "/sync/file?root=sync&path=docs/a.txt", "two", &none); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 for a create over an existing file, got %d", w.Code)
+	}
+	stale := "0000"
+	if w := do("DELETE", "/sync/file?root=sync&path=docs/a.txt", "", &stale); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 for a stale delete, got %d", w.Code)
+	}
+	w = do("PUT", "/sync/file?root=sync&path=docs/a.txt", "two", &first.SHA256)
+	if w.Code != http.StatusOK {
+		t.Fatalf("conditional put failed: %s", w.Body.String())
+	}
+	var second syncEntry
+	json.Unmarshal(w.Body.Bytes(), &second)
+
+	// The replacement shows up as the new file at the path, and the old one
+	// as a delete
+	w = do("GET", "/sync/changes?root=sync&cursor="+strconv.FormatInt(snap.Cursor, 10), "", nil)
+	var feed struct {
+		Changes []syncChange `json:"changes"`
+		Cursor  int64        `json:"cursor"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &feed)
+	if len(feed.Changes) != 2 {
+		t.Fatalf("expected 2 changes, got %s", w.Body.String())
+	}
+	if c := feed.Changes[0]; c.Op != syncPut || c.FileID != second.FileID || c.Path != "docs/a.txt" {
+		t.Errorf("unexpected first change %+v", c)
+	}
+	if c := feed.Changes[1]; c.Op != syncDelete || c.FileID != first.FileID {
+		t.Errorf("unexpected second change %+v", c)
+	}
+
+	w = do("POST", "/sync/stat", `{"root": "sync", "paths": ["docs/a.txt", "missing.txt"]}`, nil)
+	var stat struct {
+		Entries map[string]*syncEntry `json:"entries"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &stat)
+	if e := stat.Entries["docs/a.txt"]; e == nil || e.SHA256 != second.SHA256 {
+		t.Errorf("unexpected stat for docs/a.txt: %s", w.Body.String())
+	}
+	if e, ok := stat.Entries["missing.txt"]; !ok || e != nil {
+		t.Errorf("expected null for a missing path: %s", w.Body.String())
+	}
+
+	// Once the journal is pruned past a cursor, the agent must start over
+	db.PruneChanges(h.Store, time.Now().Add(time.Hour))
+	if w := do("GET", "/sync/changes?root=sync&cursor="+strconv.FormatInt(snap.Cursor, 10), "", nil); w.Code != http.StatusGone {
+		t.Errorf("expected 410 for a pruned cursor, got %d", w.Code)
+	}
+	if w := do("GET", "/sync/changes?root=sync&cursor="+strconv.FormatInt(feed.Cursor, 10), "", nil); w.Code != http.StatusOK {
+		t.Errorf("expected the latest cursor to stay valid, got %d", w.Code)
+	}
+}
+
+// Writes of a path in progress turn away others of the same path only.
+func TestSyncWritesClaimTheirPath(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	router := gin.Default()
+	router.PUT("/sync/file", h.SyncPut)
+	router.DELETE("/sync/file", h.SyncDelete)
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+		req.Header.Set("X-Client-ID", "client-a")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	claim := nameClaim("client-a", "sync/docs", "a.txt")
+	h.names.start(h.Store, claim)
+	if w := do("PUT", "/sync/file?root=sync&path=docs/a.txt", "one"); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 while the path is being written, got %d", w.Code)
+	}
+	if w := do("DELETE", "/sync/file?root=sync&path=docs/a.txt", ""); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 for a delete while the path is being written, got %d", w.Code)
+	}
+	if w := do("PUT", "/sync/file?root=sync&path=docs/b.txt", "two"); w.Code != http.StatusOK {
+		t.Errorf("expected other paths to be written meanwhile, got %d %s", w.Code, w.Body.String())
+	}
+	h.names.done(claim)
+	if w := do("PUT", "/sync/file?root=sync&path=docs/a.txt", "one"); w.Code != http.StatusOK {
+		t.Errorf("expected the path to be written once released, got %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/tenant"
+	"github.com/gin-gonic/gin"
+)
+
+type tenantInput struct {
+	Name        string   `json:"name"`
+	Hosts       []string `json:"hosts"`
+	AdminSecret string   `json:"admin_secret"`
+	QuotaBytes  int64    `json:"quota_bytes"`
+}
+
+type tenantResponse struct {
+	db.TenantRecord
+	UsedBytes int64 `json:"used_bytes"`
+}
+
+// tenantAdmin checks that the request comes from an admin of the root depot,
+// the only place tenants can be managed from.
+func (h *Handler) tenantAdmin(c *gin.Context) bool {
+	if h.Tenants == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Multi-tenancy is not enabled"})
+		return false
+	}
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return false
+	}
+	return true
+}
+
+func (h *Handler) describeTenant(t db.TenantRecord) tenantResponse {
+	t.AdminSecret = ""
+	return tenantResponse{
+		TenantRecord: t,
+		UsedBytes:    db.StorageUsed(tenant.Scope(h.Store, t.ID)),
+	}
+}
+
+func (h *Handler) ListTenants(c *gin.Context) {
+	if !h.tenantAdmin(c) {
+		return
+	}
+
+	tenants, err := db.ListTenants(h.Store)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list tenants"})
+		return
+	}
+	resp := make([]tenantResponse, 0, len(tenants))
+	for _, t := range tenants {
+		resp = append(resp, h.describeTenant(t))
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) GetTenant(c *gin.Context) {
+	if !h.tenantAdmin(c) {
+		return
+	}
+
+	t, err := db.GetTenant(h.Store, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tenant not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.describeTenant(*t))
+}
+
+func (h *Handler) CreateTenant(c *gin.Context) {
+	if !h.tenantAdmin(c) {
+		return
+	}
+
+	var input struct {
+		ID string `json:"id" binding:"required"`
+		tenantInput
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !tenant.ValidID(input.ID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tenant ID must be lowercase letters, digits and dashes"})
+		return
+	}
+	if _, err := db.GetTenant(h.Store, input.ID); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Tenant already exists"})
+		return
+	}
+	if input.AdminSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "admin_secret is required"})
+		return
+	}
+
+	t := db.TenantRecord{
+		ID:          input.ID,
+		Name:        input.Name,
+		Hosts:       input.Hosts,
+		AdminSecret: input.AdminSecret,
+		QuotaBytes:  input.QuotaBytes,
+		CreatedAt:   time.Now().Unix(),
+	}
+	if err := db.SaveTenant(h.Store, t); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save tenant"})
+		return
+	}
+	h.Tenants.Invalidate(t.ID)
+
+	c.JSON(http.StatusCreated, h.describeTenant(t))
+}
+
+func (h *Handler) UpdateTenant(c *gin.Context) {
+	if !h.tenantAdmin(c) {
+		return
+	}
+
+	t, err := db.GetTenant(h.Store, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tenant not found"})
+		return
+	}
+
+	var input tenantInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	t.Name = input.Name
+	t.Hosts = input.Hosts
+	t.QuotaBytes = input.QuotaBytes
+	if input.AdminSecret != "" {
+		t.AdminSecret = input.AdminSecret
+	}
+
+	if err := db.SaveTenant(h.Store, *t); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save tenant"})
+		return
+	}
+	h.Tenants.Invalidate(t.ID)
+
+	c.JSON(http.StatusOK, h.describeTenant(*t))
+}
+
+// DeleteTenant stops serving the tenant. Its files and records are kept so
+// the tenant can be re-provisioned under the same ID.
+func (h *Handler) DeleteTenant(c *gin.Context) {
+	if !h.tenantAdmin(c) {
+		return
+	}
+
+	id := c.Param("id")
+	if _, err := db.GetTenant(h.Store, id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tenant not found"})
+		return
+	}
+	if err := db.DeleteTenant(h.Store, id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete tenant"})
+		return
+	}
+	h.Tenants.Invalidate(id)
+
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
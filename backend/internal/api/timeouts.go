@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// transferRoutes move file content and take as long as the connection
+// needs, so they get TransferTimeout rather than RequestTimeout.
+var transferRoutes = []string{
+	"POST /api/upload",
+	"PUT /api/upload/raw",
+	"POST /api/uploads/:id/hashes",
+	"PUT /api/uploads/:id/chunks/:index",
+	"POST /api/uploads/:id/complete",
+	"PUT /api/sync/file",
+	"POST /api/integrations/sharex/upload",
+	"POST /api/requests/:id/upload",
+	"POST /api/guest/upload",
+	"GET /api/guest/files/:id",
+	"GET /api/artifacts/:name/:version",
+	"PUT /api/artifacts/:name/:version",
+	"GET /api/download/:id",
+	"GET /api/download/:id/parts",
+	"POST /api/admin/import",
+	"GET /raw/:link/:filename",
+	"GET /api/probe/download",
+	"POST /api/probe/upload",
+}
+
+// routeMatches reports whether a "METHOD /route" key names the request's
+// route. Routes match by suffix so they work wherever the depot is mounted.
+func routeMatches(key, method, route string) bool {
+	m, p, ok := strings.Cut(key, " ")
+	return ok && m == method && strings.HasSuffix(route, p)
+}
+
+// requestTimeout is the time a request may take: its entry in
+// EndpointTimeouts, else TransferTimeout for transfers and RequestTimeout
+// for everything else. Zero means no limit.
+func (h *Handler) requestTimeout(method, route string) time.Duration {
+	h.configMu.RLock()
+	defer h.configMu.RUnlock()
+	for key, timeout := range h.EndpointTimeouts {
+		if routeMatches(key, method, route) {
+			return timeout
+		}
+	}
+	for _, key := range transferRoutes {
+		if routeMatches(key, method, route) {
+			return h.TransferTimeout
+		}
+	}
+	return h.RequestTimeout
+}
+
+// timedOut answers a request whose work was cut short by its timeout or
+// because the client went away. It reports false for any other error.
+func timedOut(c *gin.Context, err error) bool {
+	if !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, context.Canceled) {
+		return false
+	}
+	c.JSON(http.StatusRequestTimeout, gin.H{"error": "Request timed out"})
+	return true
+}
+
+// Deadline bounds each request by its timeout. Reading uploads, serving
+// files, S3 calls and hooks follow the request's context, so they stop
+// when the timeout passes or the client goes away.
+func (h *Handler) Deadline() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		timeout := h.requestTimeout(c.Request.Method, c.FullPath())
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
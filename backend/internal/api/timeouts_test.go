@@ -0,0 +1,60 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// slowReader hands out one byte at a time, like a client on a bad link.
+type slowReader struct{ n int }
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	time.Sleep(5 * time.Millisecond)
+	if r.n--; r.n < 0 {
+		return 0, nil
+	}
+	p[0] = 'x'
+	return 1, nil
+}
+
+func TestRequestTimeouts(t *testing.T) {
+	h, storageDir, cleanup := setupTestHandler(t)
+	defer cleanup()
+	h.RequestTimeout = 10 * time.Second
+	h.TransferTimeout = time.Hour
+	h.EndpointTimeouts = map[string]time.Duration{"PUT /api/upload/raw": 30 * time.Millisecond}
+
+	cases := []struct {
+		method, route string
+		want          time.Duration
+	}{
+		{"GET", "/api/files", 10 * time.Second},
+		{"GET", "/depot/api/download/:id", time.Hour},
+		{"GET", "/api/download/:id/info", 10 * time.Second},
+		{"PUT", "/api/upload/raw", 30 * time.Millisecond},
+	}
+	for _, tc := range cases {
+		if got := h.requestTimeout(tc.method, tc.route); got != tc.want {
+			t.Errorf("%s %s: expected %v, got %v", tc.method, tc.route, tc.want, got)
+		}
+	}
+
+	router := gin.New()
+	router.PUT("/api/upload/raw", h.Deadline(), h.UploadRaw)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/api/upload/raw?name=slow.txt", &slowReader{n: 1000})
+	req.Header.Set("X-Client-ID", "client-a")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestTimeout {
+		t.Fatalf("expected the slow upload to time out, got %d %s", w.Code, w.Body.String())
+	}
+	if entries, _ := os.ReadDir(storageDir); len(entries) != 0 {
+		t.Errorf("expected the partial upload to be removed, got %d entries", len(entries))
+	}
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/torrent"
+	"github.com/gin-gonic/gin"
+)
+
+// baseURL returns the externally visible origin of the depot, preferring the
+// configured PublicURL over what the request claims. X-Forwarded-Proto is
+// only taken from a trusted proxy.
+func (h *Handler) baseURL(c *gin.Context) string {
+	if h.PublicURL != "" {
+		return h.PublicURL
+	}
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); (proto == "http" || proto == "https") && h.fromTrustedProxy(c) {
+		scheme = proto
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+// fromTrustedProxy reports whether the request came straight from one of
+// the TrustedProxies.
+func (h *Handler) fromTrustedProxy(c *gin.Context) bool {
+	addr, err := netip.ParseAddr(c.RemoteIP())
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range h.TrustedProxies {
+		if prefix, err := netip.ParsePrefix(p); err == nil && prefix.Contains(addr) {
+			return true
+		}
+		if proxy, err := netip.ParseAddr(p); err == nil && proxy.Unmap() == addr {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *Handler) GetFileTorrent(c *gin.Context) {
+	if h.TorrentMinSize <= 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Torrent export is disabled"})
+		return
+	}
+
+	record, err := db.GetFileRecord(h.Store, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	if !h.contentAccessible(c, record) {
+		return
+	}
+	if record.IsLink() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Link files have no content"})
+		return
+	}
+	if !record.IsPublic {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only public files can be exported as torrents"})
+		return
+	}
+	if record.Size < h.TorrentMinSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Torrents are only generated for files of at least %d bytes", h.TorrentMinSize)})
+		return
+	}
+
+	pieceLength := torrent.PieceLength(record.Size)
+
+	// Piece hashes depend only on the content, so cache them per blob
+	var pieces []byte
+	cacheKey := ""
+	if record.SHA256 != "" {
+		cacheKey = fmt.Sprintf("%s%s:%d", db.TorrentKeyPrefix, record.SHA256, pieceLength)
+		pieces, _ = sdk.Get[[]byte](h.Store, db.SystemPersona, db.AppID, cacheKey)
+	}
+	if len(pieces) == 0 {
+		content, err := h.openStored(c.Request.Context(), record.StoredPath)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+			return
+		}
+		pieces, err = torrent.HashPieces(content, pieceLength)
+		content.Close()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash file"})
+			return
+		}
+		if cacheKey != "" {
+			_ = h.Store.Set(db.SystemPersona, db.AppID, cacheKey, pieces)
+		}
+	}
+
+	// Confirmed, so seeding clients get the content rather than the landing
+	// page or a link preview card
+	webSeed := h.baseURL(c) + "/api/download/" + record.DownloadLink + "?confirm=1"
+	data, err := torrent.Build(torrent.Meta{
+		Name:        record.OriginalName,
+		Size:        record.Size,
+		PieceLength: pieceLength,
+		Pieces:      pieces,
+		WebSeed:     webSeed,
+		Trackers:    h.TorrentTrackers,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build torrent"})
+		return
+	}
+
+	c.Header("Content-Disposition", contentDisposition("attachment", record.ID+".torrent"))
+	c.Data(http.StatusOK, "application/x-bittorrent", data)
+}
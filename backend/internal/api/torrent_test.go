@@ -0,0 +1,98 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetFileTorrent(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+	h.TorrentMinSize = 1
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.GET("/files/:id/torrent", h.GetFileTorrent)
+	router.DELETE("/files/:id", h.DeleteFile)
+
+	uploaded := uploadTestFile(t, router, "client-a", "seed.bin", bytes.Repeat([]byte("seed"), 1024))
+	id, link, hash := uploaded["id"].(string), uploaded["download_link"].(string), uploaded["sha256"].(string)
+	record, _ := db.GetFileRecord(h.Store, id)
+	record.IsPublic = true
+	db.SaveFileRecord(h.Store, *record)
+
+	get := func(proto string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/files/"+id+"/torrent", nil)
+		req.RemoteAddr = "203.0.113.5:40000"
+		req.Host = "depot.example"
+		if proto != "" {
+			req.Header.Set("X-Forwarded-Proto", proto)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// The web seed skips the landing page and link previews
+	w := get("")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GetFileTorrent: %d %s", w.Code, w.Body.String())
+	}
+	if seed := "http://depot.example/api/download/" + link + "?confirm=1"; !strings.Contains(w.Body.String(), seed) {
+		t.Errorf("expected web seed %s in %q", seed, w.Body.String())
+	}
+
+	// X-Forwarded-Proto counts from trusted proxies only
+	if w := get("https"); strings.Contains(w.Body.String(), "https://") {
+		t.Error("X-Forwarded-Proto of an untrusted client was believed")
+	}
+	h.TrustedProxies = []string{"203.0.113.0/24"}
+	if w := get("https"); !strings.Contains(w.Body.String(), "https://depot.example/") {
+		t.Errorf("X-Forwarded-Proto of a trusted proxy was ignored: %q", w.Body.String())
+	}
+
+	// Link files have no content to hash
+	db.SaveFileRecord(h.Store, db.FileRecord{ID: "release", OwnerID: "client-a", IsPublic: true, Size: 4096, TargetURL: "https://example.com/release.bin", DownloadLink: "release-link"})
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/files/release/torrent", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a link file, got %d", w.Code)
+	}
+
+	record.Quarantined = true
+	db.SaveFileRecord(h.Store, *record)
+	if w := get(""); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a quarantined file, got %d", w.Code)
+	}
+
+	// The cached piece hashes go with the content
+	appStore, _ := h.Store.GetAppStore(db.SystemPersona, db.AppID)
+	cached := 0
+	for key := range appStore {
+		if strings.HasPrefix(key, db.TorrentKeyPrefix+hash+":") {
+			cached++
+		}
+	}
+	if cached != 1 {
+		t.Fatalf("expected the piece hashes to be cached once, found %d", cached)
+	}
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/files/"+id, nil)
+	req.Header.Set("X-Client-ID", "client-a")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("DeleteFile: %d %s", w.Code, w.Body.String())
+	}
+	appStore, _ = h.Store.GetAppStore(db.SystemPersona, db.AppID)
+	for key := range appStore {
+		if strings.HasPrefix(key, db.TorrentKeyPrefix) {
+			t.Errorf("%s outlived the content", key)
+		}
+	}
+}
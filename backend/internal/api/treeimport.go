@@ -0,0 +1,184 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Ways of importing a directory tree.
+const (
+	// ImportReference registers files where they are, see
+	// db.FileRecord.External
+	ImportReference = "reference"
+	ImportCopy      = "copy"
+	// ImportMove copies files into storage and removes the originals
+	ImportMove = "move"
+)
+
+type ImportFailure struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+type ImportReport struct {
+	Imported int             `json:"imported"`
+	Bytes    int64           `json:"bytes"`
+	Failed   []ImportFailure `json:"failed"`
+}
+
+// ImportTree imports every regular file under root as a file of ownerID
+// (system files when empty), with its directory relative to root as folder.
+// Files the upload pipeline refuses are reported and the rest carry on; it
+// stops between files when ctx is done.
+func (h *Handler) ImportTree(ctx context.Context, root, ownerID, mode string) (*ImportReport, error) {
+	switch mode {
+	case ImportReference, ImportCopy, ImportMove:
+	default:
+		return nil, fmt.Errorf("mode must be %s, %s or %s", ImportReference, ImportCopy, ImportMove)
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(root); err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", root)
+	}
+	storageDir, _ := filepath.Abs(h.StorageDir)
+	tempDir, _ := filepath.Abs(h.tempDir())
+	if rel, err := filepath.Rel(storageDir, root); err == nil && !strings.HasPrefix(rel, "..") {
+		return nil, errors.New("the depot's own storage cannot be imported")
+	}
+	isAdmin := false
+	if ownerID != "" {
+		client, err := db.GetClient(h.Store, ownerID)
+		if err != nil {
+			return nil, fmt.Errorf("client %s not found", ownerID)
+		}
+		isAdmin = client.IsAdmin
+	}
+
+	report := &ImportReport{Failed: []ImportFailure{}}
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		rel, _ := filepath.Rel(root, p)
+		if err != nil {
+			report.Failed = append(report.Failed, ImportFailure{Path: rel, Error: err.Error()})
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() && (p == storageDir || p == tempDir) {
+			return filepath.SkipDir
+		}
+		// Symlinks are skipped so nothing outside the tree is imported
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		folder := path.Dir(filepath.ToSlash(rel))
+		if folder == "." {
+			folder = ""
+		}
+		record, err := h.importFile(ctx, p, ingestOptions{
+			OwnerID: ownerID,
+			Name:    d.Name(),
+			IsAdmin: isAdmin,
+			Folder:  db.NormalizeFolder(folder),
+		}, mode)
+		if err != nil {
+			report.Failed = append(report.Failed, ImportFailure{Path: rel, Error: err.Error()})
+			return nil
+		}
+		report.Imported++
+		report.Bytes += record.Size
+		return nil
+	})
+	return report, err
+}
+
+// importFile imports the file at p with opts, which name the file and its
+// owner.
+func (h *Handler) importFile(ctx context.Context, p string, opts ingestOptions, mode string) (*db.FileRecord, error) {
+	opts.Metadata = map[string]string{"imported_from": p}
+	opts.External = mode == ImportReference
+	id := uuid.New().String()
+	var staged stagedFile
+	if mode == ImportReference {
+		size, hash, err := storage.HashFile(p)
+		if err != nil {
+			return nil, err
+		}
+		staged = stagedFile{ID: id, Path: p, Size: size, SHA256: hash}
+	} else {
+		f, err := os.Open(p)
+		if err != nil {
+			return nil, err
+		}
+		storedPath, size, hash, err := storage.StoreFile(f, h.TempDir, h.StorageDir, id)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		staged = stagedFile{ID: id, Path: storedPath, Size: size, SHA256: hash}
+		opts.StripMetadata = h.StripMetadata
+	}
+
+	record, err := h.ingest(ctx, staged, opts)
+	if err != nil {
+		return nil, err
+	}
+	if mode == ImportMove {
+		if err := os.Remove(p); err != nil {
+			log.Printf("[WARN] Imported %s but could not remove it: %v", p, err)
+		}
+	}
+	return record, nil
+}
+
+// AdminImportTree imports a directory on the server's host, see ImportTree.
+// It runs to completion before answering, so large trees are better
+// imported with `depot import`.
+func (h *Handler) AdminImportTree(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	var input struct {
+		Path    string `json:"path" binding:"required"`
+		OwnerID string `json:"owner_id"`
+		Mode    string `json:"mode"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Mode == "" {
+		input.Mode = ImportReference
+	}
+	report, err := h.ImportTree(c.Request.Context(), input.Path, input.OwnerID, input.Mode)
+	if err != nil {
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Directory not found"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
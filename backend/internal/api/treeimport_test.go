@@ -0,0 +1,95 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestImportTree(t *testing.T) {
+	h, storageDir, cleanup := setupTestHandler(t)
+	defer cleanup()
+	db.UpsertClient(h.Store, "admin", "Admin", "CODEA", 0)
+	db.UpdateClientAdminStatus(h.Store, "admin", true)
+	db.UpsertClient(h.Store, "owner", "Owner", "CODEO", 0)
+
+	router := gin.Default()
+	router.POST("/admin/import", h.AdminImportTree)
+	router.DELETE("/files/:id", h.DeleteFile)
+
+	importTree := func(body string) (int, ImportReport) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/admin/import", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Client-ID", "admin")
+		router.ServeHTTP(w, req)
+		var report ImportReport
+		json.Unmarshal(w.Body.Bytes(), &report)
+		return w.Code, report
+	}
+
+	tree := t.TempDir()
+	os.MkdirAll(filepath.Join(tree, "2024", "invoices"), 0755)
+	os.WriteFile(filepath.Join(tree, "readme.txt"), []byte("top level"), 0644)
+	os.WriteFile(filepath.Join(tree, "2024", "invoices", "march.pdf"), []byte("%PDF march"), 0644)
+
+	// Referenced in place: nothing is copied into storage
+	code, report := importTree(`{"path": "` + tree + `", "owner_id": "owner"}`)
+	if code != http.StatusOK || report.Imported != 2 || len(report.Failed) != 0 {
+		t.Fatalf("expected two files to be imported, got %d %+v", code, report)
+	}
+	files, _ := db.GetFileRecordsByOwner(h.Store, "owner")
+	var march *db.FileRecord
+	for i := range files {
+		if files[i].OriginalName == "march.pdf" {
+			march = &files[i]
+		}
+	}
+	if march == nil || march.Folder != "2024/invoices" || !march.External {
+		t.Fatalf("expected march.pdf to be referenced in its folder, got %+v", files)
+	}
+	if march.StoredPath != filepath.Join(tree, "2024", "invoices", "march.pdf") {
+		t.Errorf("expected the record to point at the original, got %s", march.StoredPath)
+	}
+	if entries, _ := os.ReadDir(storageDir); len(entries) != 0 {
+		t.Errorf("expected nothing in storage, got %d entries", len(entries))
+	}
+
+	// Deleting the depot file leaves the original alone
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/files/"+march.ID, nil)
+	req.Header.Set("X-Client-ID", "owner")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Delete failed: %s", w.Body.String())
+	}
+	if _, err := os.Stat(march.StoredPath); err != nil {
+		t.Errorf("expected the original to survive deletion: %v", err)
+	}
+
+	// Moved into storage
+	code, report = importTree(`{"path": "` + filepath.Join(tree, "2024") + `", "mode": "move"}`)
+	if code != http.StatusOK || report.Imported != 1 {
+		t.Fatalf("expected one file to be moved, got %d %+v", code, report)
+	}
+	if _, err := os.Stat(filepath.Join(tree, "2024", "invoices", "march.pdf")); !os.IsNotExist(err) {
+		t.Errorf("expected the original to be removed after a move")
+	}
+	if entries, _ := os.ReadDir(storageDir); len(entries) != 1 {
+		t.Errorf("expected the moved file in storage, got %d entries", len(entries))
+	}
+
+	if code, _ := importTree(`{"path": "` + storageDir + `", "mode": "copy"}`); code != http.StatusBadRequest {
+		t.Errorf("expected the storage directory to be refused, got %d", code)
+	}
+	if code, _ := importTree(`{"path": "` + filepath.Join(tree, "missing") + `"}`); code != http.StatusNotFound {
+		t.Errorf("expected a missing directory to be reported, got %d", code)
+	}
+}
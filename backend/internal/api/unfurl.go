@@ -0,0 +1,108 @@
+package api
+
+import (
+	"html/template"
+	"mime"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+// unfurlAgents are the link preview fetchers of chat apps and social sites,
+// matched against the lowercased user agent.
+var unfurlAgents = []string{
+	"slackbot", "discordbot", "twitterbot", "facebookexternalhit", "linkedinbot",
+	"telegrambot", "whatsapp", "mattermost", "skypeuripreview", "microsoftpreview",
+	"redditbot", "iframely", "embedly", "mastodon", "bluesky",
+}
+
+// maxCardImageBytes is the largest image offered as the card's preview;
+// preview fetchers give up on bigger ones anyway.
+const maxCardImageBytes = 5 << 20
+
+func isUnfurlAgent(ua string) bool {
+	ua = strings.ToLower(ua)
+	for _, agent := range unfurlAgents {
+		if strings.Contains(ua, agent) {
+			return true
+		}
+	}
+	return false
+}
+
+var cardPage = template.Must(template.New("card").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<title>{{.Name}}</title>
+<meta property="og:type" content="website">
+<meta property="og:site_name" content="{{.SiteName}}">
+<meta property="og:title" content="{{.Name}}">
+<meta property="og:description" content="{{.Description}}">
+<meta property="og:url" content="{{.URL}}">
+{{if .Image}}<meta property="og:image" content="{{.Image}}">
+<meta name="twitter:card" content="summary_large_image">
+<meta name="twitter:image" content="{{.Image}}">
+{{else}}<meta name="twitter:card" content="summary">
+{{end}}<meta name="twitter:title" content="{{.Name}}">
+<meta name="twitter:description" content="{{.Description}}">
+</head>
+<body>
+<h1>{{.Name}}</h1>
+<p>{{.Description}}</p>
+</body>
+</html>
+`))
+
+// cardImage is the raw link of an image file a preview fetcher may load.
+// Images behind a password, limits or location restrictions get no
+// preview, since fetching it would need the password, use up the link or
+// fail from wherever the fetcher runs.
+func (h *Handler) cardImage(c *gin.Context, record *db.FileRecord) string {
+	if !strings.HasPrefix(mime.TypeByExtension(filepath.Ext(record.OriginalName)), "image/") ||
+		record.Size > maxCardImageBytes || record.Quarantined || record.PasswordProtected ||
+		record.MaxDownloads > 0 || record.MaxBytes > 0 || record.IsLink() || record.Restricted() {
+		return ""
+	}
+	return h.baseURL(c) + "/raw/" + record.DownloadLink + "/" + url.PathEscape(record.OriginalName)
+}
+
+// serveCard answers a link preview fetcher with OpenGraph and Twitter card
+// tags describing the file, so shared links unfurl with its name and size.
+// Nothing is downloaded, so it doesn't count as a download.
+func (h *Handler) serveCard(c *gin.Context, record *db.FileRecord) {
+	siteName := db.GetSettings(h.Store).Title
+	if siteName == "" {
+		siteName = "Celerix Depot"
+	}
+	description := humanSize(record.Size)
+	if record.IsLink() {
+		description = "Link to " + record.TargetURL
+	}
+	description += " · Shared by " + h.uploaderName(record)
+
+	link := record.DownloadLink
+	if link == "" {
+		link = record.ID
+	}
+	c.Header("Content-Security-Policy", "default-src 'none'")
+	c.Status(http.StatusOK)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	err := cardPage.Execute(c.Writer, struct {
+		Name, Description, SiteName, URL, Image string
+	}{
+		Name:        record.OriginalName,
+		Description: description,
+		SiteName:    siteName,
+		URL:         h.baseURL(c) + "/api/download/" + link,
+		Image:       h.cardImage(c, record),
+	})
+	if err != nil {
+		c.Error(err)
+	}
+}
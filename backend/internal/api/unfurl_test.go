@@ -0,0 +1,97 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestShareLinkCard(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+	db.UpsertClient(h.Store, "client-a", "Alice", "AAAA1111", 0)
+	h.PublicURL = "https://depot.example.com"
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.GET("/download/:id", h.DownloadFile)
+
+	get := func(link, ua string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/download/"+link, nil)
+		req.Header.Set("User-Agent", ua)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	shot := uploadTestFile(t, router, "client-a", "shot.png", []byte("png bytes"))["download_link"].(string)
+	w := get(shot, "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)")
+	page := w.Body.String()
+	for _, want := range []string{
+		`<meta property="og:title" content="shot.png">`,
+		`<meta property="og:description" content="9 B · Shared by Alice">`,
+		`<meta property="og:image" content="https://depot.example.com/raw/` + shot + `/shot.png">`,
+		`<meta name="twitter:card" content="summary_large_image">`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("expected %s in the card, got:\n%s", want, page)
+		}
+	}
+
+	// Files that aren't images get a card without a preview
+	zip := uploadTestFile(t, router, "client-a", "release.zip", []byte("zip bytes"))["download_link"].(string)
+	page = get(zip, "Mozilla/5.0 (compatible; Discordbot/2.0; +https://discordapp.com)").Body.String()
+	if strings.Contains(page, "og:image") || !strings.Contains(page, `content="summary"`) {
+		t.Errorf("expected a summary card without image, got:\n%s", page)
+	}
+
+	// Browsers still get the file
+	if w := get(zip, "Mozilla/5.0 (X11; Linux x86_64) Firefox/130.0"); w.Body.String() != "zip bytes" {
+		t.Errorf("expected the file for a browser, got %q", w.Body.String())
+	}
+}
+
+// Cards and landing pages tell about the file, so they are refused
+// whenever the download would be.
+func TestCardFollowsDownloadChecks(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.GET("/download/:id", h.DownloadFile)
+
+	get := func(link, ua string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/download/"+link, nil)
+		req.Header.Set("User-Agent", ua)
+		router.ServeHTTP(w, req)
+		return w
+	}
+	const bot = "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)"
+
+	uploaded := uploadTestFile(t, router, "client-a", "leak.png", []byte("png bytes"))
+	link := uploaded["download_link"].(string)
+	record, _ := db.GetFileRecord(h.Store, uploaded["id"].(string))
+
+	record.Quarantined = true
+	db.SaveFileRecord(h.Store, *record)
+	if w := get(link, bot); w.Code != http.StatusForbidden || strings.Contains(w.Body.String(), "leak.png") {
+		t.Errorf("expected 403 without a card for a quarantined file, got %d %s", w.Code, w.Body.String())
+	}
+	db.SaveSettings(h.Store, db.Settings{LandingPage: true})
+	if w := get(link, "Mozilla/5.0 Firefox/130.0"); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 instead of the landing page of a quarantined file, got %d", w.Code)
+	}
+
+	record.Quarantined = false
+	record.ExpiresAt = h.now().Unix() - 60
+	db.SaveFileRecord(h.Store, *record)
+	if w := get(link, bot); w.Code != http.StatusGone || strings.Contains(w.Body.String(), "leak.png") {
+		t.Errorf("expected 410 without a card for an expired link, got %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+type usageEntry struct {
+	db.UsageRecord
+	ClientName string `json:"client_name"`
+}
+
+var usageColumns = []string{"month", "client_id", "client_name", "upload_bytes", "download_bytes", "uploads", "downloads"}
+
+// AdminGetUsage reports per-client bandwidth for a month (?month=2026-10,
+// default the current one; "all" for every month kept) as JSON, or as CSV
+// for billing with ?format=csv.
+func (h *Handler) AdminGetUsage(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	month := c.DefaultQuery("month", db.UsageMonth(h.now()))
+	if month == "all" {
+		month = ""
+	} else if _, err := time.Parse("2006-01", month); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "month must look like 2006-01"})
+		return
+	}
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or csv"})
+		return
+	}
+
+	records, err := db.ListUsage(h.Store, month)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list usage"})
+		return
+	}
+	names := map[string]string{db.SystemPersona: "System"}
+	if clients, err := db.ListClients(h.Store); err == nil {
+		for _, client := range clients {
+			names[client.ID] = client.Name
+		}
+	}
+	entries := make([]usageEntry, 0, len(records))
+	var total db.UsageRecord
+	for _, r := range records {
+		entries = append(entries, usageEntry{r, names[r.ClientID]})
+		total.UploadBytes += r.UploadBytes
+		total.DownloadBytes += r.DownloadBytes
+		total.Uploads += r.Uploads
+		total.Downloads += r.Downloads
+	}
+
+	if format == "json" {
+		c.JSON(http.StatusOK, gin.H{"month": month, "clients": entries, "total": total})
+		return
+	}
+
+	filename := "usage.csv"
+	if month != "" {
+		filename = "usage-" + month + ".csv"
+	}
+	c.Header("Content-Disposition", contentDisposition("attachment", filename))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Status(http.StatusOK)
+	w := csv.NewWriter(c.Writer)
+	w.Write(usageColumns)
+	for _, e := range entries {
+		w.Write([]string{
+			e.Month,
+			e.ClientID,
+			e.ClientName,
+			strconv.FormatInt(e.UploadBytes, 10),
+			strconv.FormatInt(e.DownloadBytes, 10),
+			strconv.FormatInt(e.Uploads, 10),
+			strconv.FormatInt(e.Downloads, 10),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		c.Error(err)
+	}
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestAdminGetUsage(t *testing.T) {
+	h, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+	h.Now = func() time.Time { return time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC) }
+
+	router := gin.Default()
+	router.POST("/upload", h.UploadFile)
+	router.GET("/download/:id", h.DownloadFile)
+	router.GET("/admin/usage", h.AdminGetUsage)
+
+	db.UpsertClient(h.Store, "admin", "Admin", "CODEA", 0)
+	db.UpdateClientAdminStatus(h.Store, "admin", true)
+	db.UpsertClient(h.Store, "client-a", "Ada", "CODEB", 0)
+
+	file := uploadTestFile(t, router, "client-a", "report.txt", []byte("0123456789"))
+	download := func(rangeHeader string) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/download/"+file["download_link"].(string)+"?confirm=1", nil)
+		if rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+		router.ServeHTTP(w, req)
+	}
+	download("")
+	download("bytes=0-3")
+	download("bytes=4-")
+	// Last month's traffic stays in last month
+	db.RecordUsage(h.Store, "client-a", time.Date(2026, 9, 30, 23, 0, 0, 0, time.UTC), 100, 0)
+
+	get := func(clientID, query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/admin/usage"+query, nil)
+		req.Header.Set("X-Client-ID", clientID)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := get("client-a", ""); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a client, got %d", w.Code)
+	}
+	if w := get("admin", "?month=october"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a malformed month, got %d", w.Code)
+	}
+
+	var resp struct {
+		Month   string         `json:"month"`
+		Clients []usageEntry   `json:"clients"`
+		Total   db.UsageRecord `json:"total"`
+	}
+	w := get("admin", "")
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Month != "2026-10" || len(resp.Clients) != 1 {
+		t.Fatalf("unexpected usage %s", w.Body.String())
+	}
+	u := resp.Clients[0]
+	if u.ClientName != "Ada" || u.UploadBytes != 10 || u.Uploads != 1 || u.DownloadBytes != 20 || u.Downloads != 3 {
+		t.Errorf("unexpected totals %+v", u)
+	}
+
+	w = get("admin", "?month=all&format=csv")
+	rows, err := csv.NewReader(strings.NewReader(w.Body.String())).ReadAll()
+	if err != nil || len(rows) != 3 || rows[1][0] != "2026-09" || rows[2][3] != "10" {
+		t.Fatalf("unexpected CSV %q", w.Body.String())
+	}
+	if cd := w.Header().Get("Content-Disposition"); !strings.Contains(cd, "usage.csv") {
+		t.Errorf("expected a CSV attachment, got %q", cd)
+	}
+
+	if n, _ := db.PruneUsage(h.Store, "2026-10"); n != 1 {
+		t.Errorf("expected the older month to be pruned, got %d", n)
+	}
+}
@@ -0,0 +1,134 @@
+package classify
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const (
+	TagImage      = "image"
+	TagVideo      = "video"
+	TagAudio      = "audio"
+	TagDocument   = "document"
+	TagArchive    = "archive"
+	TagSourceCode = "source-code"
+	TagDataset    = "dataset"
+	TagExecutable = "executable"
+	TagText       = "text"
+)
+
+var extensionTags = map[string]string{
+	".go": TagSourceCode, ".py": TagSourceCode, ".js": TagSourceCode, ".ts": TagSourceCode,
+	".java": TagSourceCode, ".c": TagSourceCode, ".h": TagSourceCode, ".cpp": TagSourceCode,
+	".rs": TagSourceCode, ".rb": TagSourceCode, ".php": TagSourceCode, ".sh": TagSourceCode,
+	".cs": TagSourceCode, ".kt": TagSourceCode, ".swift": TagSourceCode, ".vue": TagSourceCode,
+	".csv": TagDataset, ".tsv": TagDataset, ".parquet": TagDataset, ".jsonl": TagDataset,
+	".ndjson": TagDataset, ".arrow": TagDataset, ".feather": TagDataset, ".sqlite": TagDataset,
+	".pdf": TagDocument, ".doc": TagDocument, ".docx": TagDocument, ".odt": TagDocument,
+	".xls": TagDocument, ".xlsx": TagDocument, ".ods": TagDocument, ".ppt": TagDocument,
+	".pptx": TagDocument, ".odp": TagDocument, ".md": TagDocument, ".rtf": TagDocument,
+	".zip": TagArchive, ".tar": TagArchive, ".gz": TagArchive, ".tgz": TagArchive,
+	".bz2": TagArchive, ".xz": TagArchive, ".7z": TagArchive, ".rar": TagArchive, ".zst": TagArchive,
+	".exe": TagExecutable, ".msi": TagExecutable, ".dmg": TagExecutable, ".apk": TagExecutable,
+	".deb": TagExecutable, ".rpm": TagExecutable, ".appimage": TagExecutable,
+}
+
+var magicTags = []struct {
+	prefix []byte
+	tag    string
+}{
+	{[]byte("MZ"), TagExecutable},
+	{[]byte("\x7fELF"), TagExecutable},
+	{[]byte("\xcf\xfa\xed\xfe"), TagExecutable},
+	{[]byte("PAR1"), TagDataset},
+	{[]byte("SQLite format 3\x00"), TagDataset},
+	{[]byte("7z\xbc\xaf\x27\x1c"), TagArchive},
+	{[]byte("Rar!\x1a\x07"), TagArchive},
+	{[]byte("\xfd7zXZ\x00"), TagArchive},
+	{[]byte("\x28\xb5\x2f\xfd"), TagArchive},
+	{[]byte("BZh"), TagArchive},
+	{[]byte("%PDF-"), TagDocument},
+}
+
+// Classify derives system tags from the file name and leading bytes.
+func Classify(name string, head []byte) []string {
+	tags := make(map[string]bool)
+
+	for _, m := range magicTags {
+		if bytes.HasPrefix(head, m.prefix) {
+			tags[m.tag] = true
+		}
+	}
+
+	sniffed := http.DetectContentType(head)
+	switch {
+	case strings.HasPrefix(sniffed, "image/"):
+		tags[TagImage] = true
+	case strings.HasPrefix(sniffed, "video/"):
+		tags[TagVideo] = true
+	case strings.HasPrefix(sniffed, "audio/"):
+		tags[TagAudio] = true
+	case sniffed == "application/zip", sniffed == "application/x-gzip", sniffed == "application/x-rar-compressed":
+		// Office documents are zip containers too; let the extension decide
+		if _, known := extensionTags[strings.ToLower(filepath.Ext(name))]; !known {
+			tags[TagArchive] = true
+		}
+	case strings.HasPrefix(sniffed, "text/"):
+		tags[TagText] = true
+	}
+
+	if tag, ok := extensionTags[strings.ToLower(filepath.Ext(name))]; ok {
+		tags[tag] = true
+		// Structured text that is really code or data shouldn't also be "text"
+		if tag == TagSourceCode || tag == TagDataset {
+			delete(tags, TagText)
+		}
+	}
+
+	result := make([]string, 0, len(tags))
+	for t := range tags {
+		result = append(result, t)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// groupOrder ranks the tags for Group, most specific first.
+var groupOrder = []string{
+	TagImage, TagVideo, TagAudio, TagDocument, TagArchive,
+	TagSourceCode, TagDataset, TagExecutable, TagText,
+}
+
+// GroupOther is the group of files that fit no other.
+const GroupOther = "other"
+
+// Group puts a file in a single type group: its most specific system tag,
+// or, before tags are assigned, the tag its name implies. Files that fit
+// none are GroupOther.
+func Group(name string, systemTags []string) string {
+	for _, tag := range groupOrder {
+		if slices.Contains(systemTags, tag) {
+			return tag
+		}
+	}
+	if tag, ok := extensionTags[strings.ToLower(filepath.Ext(name))]; ok {
+		return tag
+	}
+	mimeType := mime.TypeByExtension(filepath.Ext(name))
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return TagImage
+	case strings.HasPrefix(mimeType, "video/"):
+		return TagVideo
+	case strings.HasPrefix(mimeType, "audio/"):
+		return TagAudio
+	case strings.HasPrefix(mimeType, "text/"):
+		return TagText
+	}
+	return GroupOther
+}
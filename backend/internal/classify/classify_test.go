@@ -0,0 +1,46 @@
+package classify
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		head []byte
+		want []string
+	}{
+		{"photo.bin", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), []string{TagImage}},
+		{"main.go", []byte("package main\n"), []string{TagSourceCode}},
+		{"data.csv", []byte("a,b,c\n1,2,3\n"), []string{TagDataset}},
+		{"backup.zip", []byte("PK\x03\x04rest"), []string{TagArchive}},
+		{"report.docx", []byte("PK\x03\x04rest"), []string{TagDocument}},
+		{"tool", []byte("\x7fELF\x02\x01"), []string{TagExecutable}},
+		{"readme", []byte("just some words"), []string{TagText}},
+	}
+	for _, tt := range tests {
+		if got := Classify(tt.name, tt.head); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Classify(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGroup(t *testing.T) {
+	tests := []struct {
+		name string
+		tags []string
+		want string
+	}{
+		{"photo.bin", []string{TagImage}, TagImage},
+		{"notes.txt", []string{TagDocument, TagText}, TagDocument},
+		{"main.go", nil, TagSourceCode},
+		{"clip.mp4", nil, TagVideo},
+		{"blob", nil, GroupOther},
+	}
+	for _, tt := range tests {
+		if got := Group(tt.name, tt.tags); got != tt.want {
+			t.Errorf("Group(%s, %v) = %s, want %s", tt.name, tt.tags, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"sort"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+const AccessKeyPrefix = "access:"
+
+// maxAccessEntries bounds the files remembered per client; the least
+// recently opened ones are forgotten first.
+const maxAccessEntries = 200
+
+// FileAccess is how often and when a client last opened a file.
+type FileAccess struct {
+	Count int   `json:"count"`
+	Last  int64 `json:"last"`
+}
+
+// ClientAccess is a client's activity: the files they opened, by ID.
+type ClientAccess struct {
+	ClientID string                `json:"client_id"`
+	Files    map[string]FileAccess `json:"files"`
+}
+
+// Listing views built from a client's activity.
+const (
+	ViewRecent   = "recent"
+	ViewFrequent = "frequent"
+)
+
+// accessMu serializes updates so concurrent downloads aren't lost.
+var accessMu = sharedMutex{name: "access"}
+
+// GetClientAccess returns the client's activity, empty if they opened
+// nothing yet.
+func GetClientAccess(s CelerixStore, clientID string) ClientAccess {
+	a, err := sdk.Get[ClientAccess](s, SystemPersona, AppID, AccessKeyPrefix+clientID)
+	if err != nil || a.Files == nil {
+		return ClientAccess{ClientID: clientID, Files: map[string]FileAccess{}}
+	}
+	return a
+}
+
+// RecordAccess counts the client opening a file at the given Unix time.
+func RecordAccess(s CelerixStore, clientID, fileID string, at int64) error {
+	accessMu.Lock(s)
+	defer accessMu.Unlock()
+
+	a := GetClientAccess(s, clientID)
+	fa := a.Files[fileID]
+	fa.Count++
+	fa.Last = at
+	a.Files[fileID] = fa
+	for len(a.Files) > maxAccessEntries {
+		oldest := ""
+		for id, f := range a.Files {
+			if oldest == "" || f.Last < a.Files[oldest].Last {
+				oldest = id
+			}
+		}
+		delete(a.Files, oldest)
+	}
+	return s.Set(SystemPersona, AppID, AccessKeyPrefix+clientID, a)
+}
+
+// sortByAccess keeps the records the client opened, ordered for the view:
+// most recently opened first, or most often opened first with ties going
+// to the more recent.
+func sortByAccess(records []FileRecord, access map[string]FileAccess, view string) []FileRecord {
+	opened := records[:0]
+	for _, r := range records {
+		if _, ok := access[r.ID]; ok {
+			opened = append(opened, r)
+		}
+	}
+	sort.SliceStable(opened, func(i, j int) bool {
+		a, b := access[opened[i].ID], access[opened[j].ID]
+		if view == ViewFrequent && a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Last > b.Last
+	})
+	return opened
+}
@@ -0,0 +1,156 @@
+package db
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+const AlertKeyPrefix = "alert:"
+
+const (
+	AlertOpen         = "open"
+	AlertAcknowledged = "acknowledged"
+	AlertResolved     = "resolved"
+)
+
+// AlertRecord is a condition admins should look at. The ID names the
+// condition (e.g. "volume" or "scrub-<hash>"), so a condition that persists
+// is one alert rather than one per check.
+type AlertRecord struct {
+	ID      string `json:"id"`
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+	// Level is the threshold crossed, in percent, for threshold alerts
+	Level          int    `json:"level,omitempty"`
+	Status         string `json:"status"`
+	RaisedAt       int64  `json:"raised_at"`
+	UpdatedAt      int64  `json:"updated_at"`
+	AcknowledgedAt int64  `json:"acknowledged_at,omitempty"`
+	AcknowledgedBy string `json:"acknowledged_by,omitempty"`
+	ResolvedAt     int64  `json:"resolved_at,omitempty"`
+	// ResolvedBy is the admin who resolved the alert, or empty when the
+	// condition cleared by itself
+	ResolvedBy string `json:"resolved_by,omitempty"`
+}
+
+// alertMu keeps checks and admin actions on the same alert apart.
+var alertMu = sharedMutex{name: "alerts"}
+
+func GetAlert(s CelerixStore, id string) (*AlertRecord, error) {
+	a, err := sdk.Get[AlertRecord](s, SystemPersona, AppID, AlertKeyPrefix+id)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// RaiseAlert opens the alert, or escalates it when it is already active at
+// a lower level. It reports whether anything changed that admins should be
+// told about; re-raising an active alert at the same level does nothing.
+func RaiseAlert(s CelerixStore, alert AlertRecord, now time.Time) (*AlertRecord, bool, error) {
+	alertMu.Lock(s)
+	defer alertMu.Unlock()
+
+	existing, err := GetAlert(s, alert.ID)
+	if err == nil && existing.Status != AlertResolved {
+		if alert.Level <= existing.Level {
+			return existing, false, nil
+		}
+		alert.RaisedAt = existing.RaisedAt
+	} else {
+		alert.RaisedAt = now.Unix()
+	}
+	alert.Status = AlertOpen
+	alert.UpdatedAt = now.Unix()
+	if err := s.Set(SystemPersona, AppID, AlertKeyPrefix+alert.ID, alert); err != nil {
+		return nil, false, err
+	}
+	return &alert, true, nil
+}
+
+// AcknowledgeAlert marks an open alert as seen by an admin. It stays active
+// until resolved.
+func AcknowledgeAlert(s CelerixStore, id, by string, now time.Time) (*AlertRecord, error) {
+	alertMu.Lock(s)
+	defer alertMu.Unlock()
+
+	a, err := GetAlert(s, id)
+	if err != nil {
+		return nil, err
+	}
+	if a.Status != AlertOpen {
+		return a, nil
+	}
+	a.Status = AlertAcknowledged
+	a.AcknowledgedAt, a.AcknowledgedBy = now.Unix(), by
+	a.UpdatedAt = now.Unix()
+	return a, s.Set(SystemPersona, AppID, AlertKeyPrefix+id, *a)
+}
+
+// ResolveAlert closes an active alert. It reports false when there was no
+// active alert with the ID.
+func ResolveAlert(s CelerixStore, id, by string, now time.Time) (bool, error) {
+	alertMu.Lock(s)
+	defer alertMu.Unlock()
+
+	a, err := GetAlert(s, id)
+	if err != nil || a.Status == AlertResolved {
+		return false, nil
+	}
+	a.Status = AlertResolved
+	a.ResolvedAt, a.ResolvedBy = now.Unix(), by
+	a.UpdatedAt = now.Unix()
+	return true, s.Set(SystemPersona, AppID, AlertKeyPrefix+id, *a)
+}
+
+// ListAlerts returns the alerts with the given status, or the active ones
+// (open and acknowledged) when status is empty, newest first.
+func ListAlerts(s CelerixStore, status string) ([]AlertRecord, error) {
+	appStore, err := s.GetAppStore(SystemPersona, AppID)
+	if err != nil {
+		return []AlertRecord{}, nil
+	}
+	alerts := []AlertRecord{}
+	for key := range appStore {
+		if !strings.HasPrefix(key, AlertKeyPrefix) {
+			continue
+		}
+		a, err := sdk.Get[AlertRecord](s, SystemPersona, AppID, key)
+		if err != nil {
+			continue
+		}
+		if status == "" && a.Status == AlertResolved || status != "" && status != "all" && a.Status != status {
+			continue
+		}
+		alerts = append(alerts, a)
+	}
+	sort.Slice(alerts, func(i, j int) bool { return alerts[i].UpdatedAt > alerts[j].UpdatedAt })
+	return alerts, nil
+}
+
+// PruneAlerts drops alerts resolved before the given time.
+func PruneAlerts(s CelerixStore, before time.Time) (int, error) {
+	alerts, err := ListAlerts(s, AlertResolved)
+	if err != nil {
+		return 0, err
+	}
+	alertMu.Lock(s)
+	defer alertMu.Unlock()
+
+	removed := 0
+	for _, a := range alerts {
+		// Look again under the lock, the alert may have been raised again
+		current, err := GetAlert(s, a.ID)
+		if err != nil || current.Status != AlertResolved || current.ResolvedAt >= before.Unix() {
+			continue
+		}
+		if err := s.Delete(SystemPersona, AppID, AlertKeyPrefix+a.ID); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
@@ -0,0 +1,167 @@
+package db
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+const AnalyticsKeyPrefix = "analytics:"
+
+// DownloadEvent is an anonymized download. No full URL is kept, only the
+// country, the referring host and a coarse client class. Address is empty
+// unless admins chose to keep addresses hashed or truncated.
+type DownloadEvent struct {
+	FileID   string
+	Time     time.Time
+	Country  string
+	Referrer string
+	Agent    string
+	Address  string
+}
+
+// AnalyticsBucket aggregates the downloads of one file during one hour.
+type AnalyticsBucket struct {
+	Start     int64          `json:"start"`
+	Downloads int            `json:"downloads"`
+	Countries map[string]int `json:"countries"`
+	Referrers map[string]int `json:"referrers"`
+	Agents    map[string]int `json:"agents"`
+	Addresses map[string]int `json:"addresses,omitempty"`
+}
+
+// analyticsMu serializes bucket updates so concurrent downloads aren't lost.
+var analyticsMu = sharedMutex{name: "analytics"}
+
+func analyticsKey(fileID string, hour time.Time) string {
+	return AnalyticsKeyPrefix + fileID + ":" + hour.UTC().Format("2006010215")
+}
+
+func RecordDownload(s CelerixStore, e DownloadEvent) error {
+	analyticsMu.Lock(s)
+	defer analyticsMu.Unlock()
+
+	hour := e.Time.UTC().Truncate(time.Hour)
+	key := analyticsKey(e.FileID, hour)
+	b, err := sdk.Get[AnalyticsBucket](s, SystemPersona, AppID, key)
+	if err != nil {
+		b = AnalyticsBucket{Start: hour.Unix()}
+	}
+	if b.Countries == nil {
+		b.Countries = map[string]int{}
+		b.Referrers = map[string]int{}
+		b.Agents = map[string]int{}
+	}
+	b.Downloads++
+	b.Countries[e.Country]++
+	b.Referrers[e.Referrer]++
+	b.Agents[e.Agent]++
+	if e.Address != "" {
+		if b.Addresses == nil {
+			b.Addresses = map[string]int{}
+		}
+		b.Addresses[e.Address]++
+	}
+	return s.Set(SystemPersona, AppID, key, b)
+}
+
+// GetAnalytics returns the file's hourly buckets since the given time,
+// oldest first.
+func GetAnalytics(s CelerixStore, fileID string, since time.Time) ([]AnalyticsBucket, error) {
+	appStore, err := s.GetAppStore(SystemPersona, AppID)
+	if err != nil {
+		return []AnalyticsBucket{}, nil
+	}
+	prefix := AnalyticsKeyPrefix + fileID + ":"
+	from := analyticsKey(fileID, since.Truncate(time.Hour))
+
+	buckets := []AnalyticsBucket{}
+	for key := range appStore {
+		if !strings.HasPrefix(key, prefix) || key < from {
+			continue
+		}
+		b, err := sdk.Get[AnalyticsBucket](s, SystemPersona, AppID, key)
+		if err != nil {
+			continue
+		}
+		buckets = append(buckets, b)
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Start < buckets[j].Start })
+	return buckets, nil
+}
+
+func DeleteAnalytics(s CelerixStore, fileID string) error {
+	appStore, err := s.GetAppStore(SystemPersona, AppID)
+	if err != nil {
+		return nil
+	}
+	prefix := AnalyticsKeyPrefix + fileID + ":"
+	for key := range appStore {
+		if strings.HasPrefix(key, prefix) {
+			if err := s.Delete(SystemPersona, AppID, key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// PruneAnalytics drops the hourly buckets of every file from before the
+// given time.
+func PruneAnalytics(s CelerixStore, before time.Time) (int, error) {
+	analyticsMu.Lock(s)
+	defer analyticsMu.Unlock()
+
+	appStore, err := s.GetAppStore(SystemPersona, AppID)
+	if err != nil {
+		return 0, nil
+	}
+	cutoff := before.UTC().Truncate(time.Hour).Format("2006010215")
+	removed := 0
+	for key := range appStore {
+		rest, ok := strings.CutPrefix(key, AnalyticsKeyPrefix)
+		if !ok {
+			continue
+		}
+		i := strings.LastIndexByte(rest, ':')
+		if i < 0 || rest[i+1:] >= cutoff {
+			continue
+		}
+		if err := s.Delete(SystemPersona, AppID, key); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// DownloadTotals adds up the downloads of the given files since the given
+// time, per file and per UTC day (keyed by the day's Unix start), in one
+// pass over the buckets.
+func DownloadTotals(s CelerixStore, fileIDs map[string]bool, since time.Time) (map[string]int, map[int64]int, error) {
+	perFile, perDay := map[string]int{}, map[int64]int{}
+	appStore, err := s.GetAppStore(SystemPersona, AppID)
+	if err != nil {
+		return perFile, perDay, nil
+	}
+	from := since.UTC().Truncate(time.Hour).Format("2006010215")
+	for key := range appStore {
+		rest, ok := strings.CutPrefix(key, AnalyticsKeyPrefix)
+		if !ok {
+			continue
+		}
+		i := strings.LastIndexByte(rest, ':')
+		if i < 0 || !fileIDs[rest[:i]] || rest[i+1:] < from {
+			continue
+		}
+		b, err := sdk.Get[AnalyticsBucket](s, SystemPersona, AppID, key)
+		if err != nil {
+			continue
+		}
+		perFile[rest[:i]] += b.Downloads
+		perDay[time.Unix(b.Start, 0).UTC().Truncate(24*time.Hour).Unix()] += b.Downloads
+	}
+	return perFile, perDay, nil
+}
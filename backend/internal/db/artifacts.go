@@ -0,0 +1,82 @@
+package db
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+const ArtifactKeyPrefix = "artifact:"
+
+// ArtifactRecord is a published, immutable version of a named artifact. The
+// content is an ordinary file; Metadata["artifact"] on that file points back
+// here so the two are removed together.
+type ArtifactRecord struct {
+	Name        string `json:"name"`
+	Version     string `json:"version"`
+	FileID      string `json:"file_id"`
+	FileName    string `json:"file_name"`
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256"`
+	PublishedBy string `json:"published_by"`
+	PublishedAt int64  `json:"published_at"`
+}
+
+// ArtifactRef is the key suffix and file metadata value for an artifact.
+func ArtifactRef(name, version string) string {
+	return name + "/" + version
+}
+
+func SaveArtifact(s CelerixStore, a ArtifactRecord) error {
+	return s.Set(SystemPersona, AppID, ArtifactKeyPrefix+ArtifactRef(a.Name, a.Version), a)
+}
+
+func GetArtifact(s CelerixStore, name, version string) (*ArtifactRecord, error) {
+	a, err := sdk.Get[ArtifactRecord](s, SystemPersona, AppID, ArtifactKeyPrefix+ArtifactRef(name, version))
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// DeleteArtifact removes the artifact at ref if its content is fileID.
+func DeleteArtifact(s CelerixStore, ref, fileID string) error {
+	a, err := sdk.Get[ArtifactRecord](s, SystemPersona, AppID, ArtifactKeyPrefix+ref)
+	if err != nil || a.FileID != fileID {
+		return err
+	}
+	return s.Delete(SystemPersona, AppID, ArtifactKeyPrefix+ref)
+}
+
+// ListArtifacts returns the published versions, newest first, of one
+// artifact or of all of them when name is empty.
+func ListArtifacts(s CelerixStore, name string) ([]ArtifactRecord, error) {
+	appStore, err := s.GetAppStore(SystemPersona, AppID)
+	if err != nil {
+		return []ArtifactRecord{}, nil
+	}
+	prefix := ArtifactKeyPrefix
+	if name != "" {
+		prefix += name + "/"
+	}
+
+	artifacts := []ArtifactRecord{}
+	for key := range appStore {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		a, err := sdk.Get[ArtifactRecord](s, SystemPersona, AppID, key)
+		if err != nil {
+			continue
+		}
+		artifacts = append(artifacts, a)
+	}
+	sort.Slice(artifacts, func(i, j int) bool {
+		if artifacts[i].Name != artifacts[j].Name {
+			return artifacts[i].Name < artifacts[j].Name
+		}
+		return artifacts[i].PublishedAt > artifacts[j].PublishedAt
+	})
+	return artifacts, nil
+}
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+	"github.com/google/uuid"
+)
+
+type AuditEntry struct {
+	ID      string `json:"id"`
+	Time    int64  `json:"time"`
+	Event   string `json:"event"`
+	Rule    string `json:"rule,omitempty"`
+	Action  string `json:"action,omitempty"`
+	FileID  string `json:"file_id,omitempty"`
+	OwnerID string `json:"owner_id,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Detail  string `json:"detail,omitempty"`
+	// IP is the client's address, hashed or truncated as the settings say
+	IP string `json:"ip,omitempty"`
+}
+
+func AddAudit(s CelerixStore, entry AuditEntry) error {
+	if entry.ID == "" {
+		entry.ID = uuid.New().String()
+	}
+	if entry.Time == 0 {
+		entry.Time = time.Now().Unix()
+	}
+	// Prefix the key with the timestamp so entries sort chronologically
+	key := fmt.Sprintf("%s%020d:%s", AuditKeyPrefix, entry.Time, entry.ID)
+	return s.Set(SystemPersona, AppID, key, entry)
+}
+
+// ListAudit returns audit entries newest first, optionally filtered by event.
+func ListAudit(s CelerixStore, event string, limit int) ([]AuditEntry, error) {
+	appStore, err := s.GetAppStore(SystemPersona, AppID)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for k := range appStore {
+		if strings.HasPrefix(k, AuditKeyPrefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+
+	var entries []AuditEntry
+	for _, k := range keys {
+		e, err := sdk.Get[AuditEntry](s, SystemPersona, AppID, k)
+		if err != nil || (event != "" && e.Event != event) {
+			continue
+		}
+		entries = append(entries, e)
+		if limit > 0 && len(entries) >= limit {
+			break
+		}
+	}
+	return entries, nil
+}
+
+// PruneAudit drops the audit entries from before the given time.
+func PruneAudit(s CelerixStore, before time.Time) (int, error) {
+	appStore, err := s.GetAppStore(SystemPersona, AppID)
+	if err != nil {
+		return 0, nil
+	}
+	cutoff := fmt.Sprintf("%s%020d", AuditKeyPrefix, before.Unix())
+	removed := 0
+	for key := range appStore {
+		if !strings.HasPrefix(key, AuditKeyPrefix) || key >= cutoff {
+			continue
+		}
+		if err := s.Delete(SystemPersona, AppID, key); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
@@ -0,0 +1,181 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+const (
+	// PartsKeyPrefix caches the part checksums of a blob, keyed by
+	// <hash>:<part size>
+	PartsKeyPrefix = "parts:"
+	// TorrentKeyPrefix caches the piece hashes of a blob, keyed by
+	// <hash>:<piece length>
+	TorrentKeyPrefix = "torrent:"
+)
+
+// blobScopedPrefixes are the caches of derived data kept per blob, keyed
+// by the hash followed by ":". They go with the blob.
+var blobScopedPrefixes = []string{
+	PartsKeyPrefix,
+	TorrentKeyPrefix,
+}
+
+// BlobRecord tracks a piece of stored content by its SHA-256 so identical
+// uploads can share a single file on disk.
+type BlobRecord struct {
+	Hash       string `json:"hash"`
+	Size       int64  `json:"size"`
+	StoredPath string `json:"stored_path"`
+	RefCount   int    `json:"ref_count"`
+	CheckedAt  int64  `json:"checked_at"`
+	Corrupted  bool   `json:"corrupted"`
+}
+
+// blobMu serializes reference count updates so concurrent uploads of the
+// same content don't lose increments.
+var blobMu = sharedMutex{name: "blobs"}
+
+func GetBlob(s CelerixStore, hash string) (*BlobRecord, error) {
+	blob, err := sdk.Get[BlobRecord](s, SystemPersona, AppID, BlobKeyPrefix+hash)
+	if err != nil {
+		return nil, err
+	}
+	return &blob, nil
+}
+
+// AcquireBlob registers a reference to the content with the given hash. When
+// the content is already known the existing blob is returned with existed set
+// to true, and the caller should discard its own copy at storedPath. A known
+// blob of another size is an error, as replacing it would orphan the files
+// that reference it.
+func AcquireBlob(s CelerixStore, hash string, size int64, storedPath string) (*BlobRecord, bool, error) {
+	blobMu.Lock(s)
+	defer blobMu.Unlock()
+
+	blob, err := GetBlob(s, hash)
+	if err == nil && blob.Size != size {
+		return nil, false, fmt.Errorf("blob %s is stored with size %d, not %d", hash, blob.Size, size)
+	}
+	if err == nil {
+		blob.RefCount++
+		if err := s.Set(SystemPersona, AppID, BlobKeyPrefix+hash, *blob); err != nil {
+			return nil, false, err
+		}
+		return blob, true, nil
+	}
+
+	blob = &BlobRecord{
+		Hash:       hash,
+		Size:       size,
+		StoredPath: storedPath,
+		RefCount:   1,
+	}
+	if err := s.Set(SystemPersona, AppID, BlobKeyPrefix+hash, *blob); err != nil {
+		return nil, false, err
+	}
+	return blob, false, nil
+}
+
+// RefBlob adds a reference to an already stored blob. It fails if the blob is
+// unknown or its size doesn't match.
+func RefBlob(s CelerixStore, hash string, size int64) (*BlobRecord, error) {
+	blobMu.Lock(s)
+	defer blobMu.Unlock()
+
+	blob, err := GetBlob(s, hash)
+	if err != nil {
+		return nil, err
+	}
+	if blob.Size != size {
+		return nil, fmt.Errorf("blob size mismatch")
+	}
+	blob.RefCount++
+	if err := s.Set(SystemPersona, AppID, BlobKeyPrefix+hash, *blob); err != nil {
+		return nil, err
+	}
+	return blob, nil
+}
+
+// ReleaseBlob drops a reference to the blob. It reports whether that was the
+// last reference, in which case the blob record is removed and the caller is
+// responsible for deleting the file on disk.
+func ReleaseBlob(s CelerixStore, hash string) (*BlobRecord, bool, error) {
+	blobMu.Lock(s)
+	defer blobMu.Unlock()
+
+	blob, err := GetBlob(s, hash)
+	if err != nil {
+		return nil, false, err
+	}
+	blob.RefCount--
+	if blob.RefCount <= 0 {
+		forgetBlob(s, hash)
+		return blob, true, s.Delete(SystemPersona, AppID, BlobKeyPrefix+hash)
+	}
+	return blob, false, s.Set(SystemPersona, AppID, BlobKeyPrefix+hash, *blob)
+}
+
+func ListBlobs(s CelerixStore) ([]BlobRecord, error) {
+	appStore, err := s.GetAppStore(SystemPersona, AppID)
+	if err != nil {
+		return nil, err
+	}
+
+	var blobs []BlobRecord
+	for k := range appStore {
+		if strings.HasPrefix(k, BlobKeyPrefix) {
+			b, err := sdk.Get[BlobRecord](s, SystemPersona, AppID, k)
+			if err == nil {
+				blobs = append(blobs, b)
+			}
+		}
+	}
+	return blobs, nil
+}
+
+// MarkBlobChecked records the outcome of an integrity check.
+func MarkBlobChecked(s CelerixStore, hash string, checkedAt int64, corrupted bool) error {
+	blobMu.Lock(s)
+	defer blobMu.Unlock()
+
+	blob, err := GetBlob(s, hash)
+	if err != nil {
+		return err
+	}
+	blob.CheckedAt = checkedAt
+	blob.Corrupted = corrupted
+	return s.Set(SystemPersona, AppID, BlobKeyPrefix+hash, *blob)
+}
+
+// StorageUsed sums the size of all stored blobs, counting shared content once.
+func StorageUsed(s CelerixStore) int64 {
+	blobs, err := ListBlobs(s)
+	if err != nil {
+		return 0
+	}
+	var total int64
+	for _, b := range blobs {
+		total += b.Size
+	}
+	return total
+}
+
+// forgetBlob deletes what was cached for the blob. Failures only leave
+// records for Compact to remove.
+func forgetBlob(s CelerixStore, hash string) {
+	appStore, err := s.GetAppStore(SystemPersona, AppID)
+	if err != nil {
+		return
+	}
+	for key := range appStore {
+		for _, prefix := range blobScopedPrefixes {
+			if strings.HasPrefix(key, prefix+hash+":") {
+				s.Delete(SystemPersona, AppID, key)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package db
+
+import "time"
+
+// ownerNameTTL bounds how stale a cached owner name gets when the rename
+// couldn't clear it.
+const ownerNameTTL = time.Minute
+
+// Cache keeps hot lookups, such as owner names, where replicas share
+// them. Stores without one, see SharedStore, send every lookup to the
+// store.
+type Cache interface {
+	Get(key string) (string, bool)
+	Set(key, value string, ttl time.Duration)
+	Delete(key string)
+}
+
+func cacheOf(s CelerixStore) Cache {
+	if shared, ok := s.(*SharedStore); ok {
+		return shared.Cache
+	}
+	return nil
+}
+
+// ownerName is the name shown for the owner of a file: the client's or
+// group's name, "Admin" for none and "Unknown" for one that's gone.
+func ownerName(s CelerixStore, ownerID string) string {
+	if ownerID == "" {
+		return "Admin"
+	}
+	c := cacheOf(s)
+	if c != nil {
+		if name, ok := c.Get("owner-name:" + ownerID); ok {
+			return name
+		}
+	}
+	name := "Unknown"
+	if client, err := GetClient(s, ownerID); err == nil {
+		name = client.Name
+	} else if group, err := GetGroup(s, ownerID); err == nil {
+		name = group.Name
+	}
+	if c != nil {
+		c.Set("owner-name:"+ownerID, name, ownerNameTTL)
+	}
+	return name
+}
+
+// forgetOwnerName drops the cached name of a client or group that was
+// renamed or deleted.
+func forgetOwnerName(s CelerixStore, ownerID string) {
+	if c := cacheOf(s); c != nil {
+		c.Delete("owner-name:" + ownerID)
+	}
+}
@@ -0,0 +1,53 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/celerix/depot/internal/memstore"
+)
+
+type mapCache map[string]string
+
+func (c mapCache) Get(key string) (string, bool) {
+	v, ok := c[key]
+	return v, ok
+}
+
+func (c mapCache) Set(key, value string, ttl time.Duration) { c[key] = value }
+
+func (c mapCache) Delete(key string) { delete(c, key) }
+
+// Stores keep their own caches, so depots embedded in one process don't
+// see each other's owner names.
+func TestOwnerNamesCachedPerStore(t *testing.T) {
+	caches := []mapCache{{}, {}}
+	var stores []CelerixStore
+	for i, name := range []string{"Alice", "Alicia"} {
+		s := &SharedStore{CelerixStore: memstore.New(), Cache: caches[i]}
+		UpsertClient(s, "alice", name, "CODE", 0)
+		SaveFileRecord(s, FileRecord{ID: "f1", OwnerID: "alice"})
+		stores = append(stores, s)
+	}
+
+	for i, want := range []string{"Alice", "Alicia"} {
+		if record, err := GetFileRecord(stores[i], "f1"); err != nil || record.OwnerName != want {
+			t.Errorf("store %d: expected owner %s, got %+v %v", i, want, record, err)
+		}
+		if caches[i]["owner-name:alice"] != want {
+			t.Errorf("store %d: expected %s cached, got %v", i, want, caches[i])
+		}
+	}
+
+	// Renaming forgets the name in the store's own cache only
+	UpsertClient(stores[0], "alice", "Al", "CODE", 0)
+	if _, ok := caches[0]["owner-name:alice"]; ok {
+		t.Error("the renamed client's name stayed cached")
+	}
+	if caches[1]["owner-name:alice"] != "Alicia" {
+		t.Error("renaming in one store cleared the cache of another")
+	}
+	if record, _ := GetFileRecord(stores[0], "f1"); record.OwnerName != "Al" {
+		t.Errorf("expected the new name, got %s", record.OwnerName)
+	}
+}
@@ -0,0 +1,46 @@
+package db
+
+import (
+	"strconv"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+// ChunkSourceKeyPrefix is followed by <ownerID>:<chunk size>:<chunk SHA-256>.
+const ChunkSourceKeyPrefix = "chunksrc:"
+
+// ChunkSource locates a chunk of a client's earlier chunked upload within
+// stored content, so a later upload with the same chunk can copy it
+// instead of transferring it again. The content may since have been
+// deleted; users of the record check the blob and the chunk's hash.
+type ChunkSource struct {
+	OwnerID   string `json:"owner_id"`
+	ChunkSize int64  `json:"chunk_size"`
+	SHA256    string `json:"sha256"`
+	// BlobSHA256 is the hash of the content holding the chunk, at Offset
+	BlobSHA256 string `json:"blob_sha256"`
+	Offset     int64  `json:"offset"`
+	Size       int64  `json:"size"`
+}
+
+func chunkSourceKey(ownerID string, chunkSize int64, hash string) string {
+	return ChunkSourceKeyPrefix + ownerID + ":" + strconv.FormatInt(chunkSize, 10) + ":" + hash
+}
+
+func GetChunkSource(s CelerixStore, ownerID string, chunkSize int64, hash string) (*ChunkSource, error) {
+	src, err := sdk.Get[ChunkSource](s, SystemPersona, AppID, chunkSourceKey(ownerID, chunkSize, hash))
+	if err != nil {
+		return nil, err
+	}
+	return &src, nil
+}
+
+// SaveChunkSource records where the chunk can be found, replacing an
+// earlier location of the same chunk.
+func SaveChunkSource(s CelerixStore, src ChunkSource) error {
+	return s.Set(SystemPersona, AppID, chunkSourceKey(src.OwnerID, src.ChunkSize, src.SHA256), src)
+}
+
+func DeleteChunkSource(s CelerixStore, ownerID string, chunkSize int64, hash string) error {
+	return s.Delete(SystemPersona, AppID, chunkSourceKey(ownerID, chunkSize, hash))
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+const CommandKeyPrefix = "command:"
+
+const (
+	CommandDone   = "done"
+	CommandFailed = "failed"
+)
+
+// CommandRecord is the outcome of a command received from the command
+// queue, keyed by the command's idempotency key. Failed commands are the
+// dead letters, kept until an admin retries or dismisses them.
+type CommandRecord struct {
+	ID      string `json:"id"`
+	Command string `json:"command"`
+	// Payload is the message as received, for retries
+	Payload string `json:"payload"`
+	State   string `json:"state"`
+	Error   string `json:"error,omitempty"`
+	// Result is what the command answered, e.g. the registered file
+	Result      json.RawMessage `json:"result,omitempty"`
+	Attempts    int             `json:"attempts"`
+	ReceivedAt  int64           `json:"received_at"`
+	ProcessedAt int64           `json:"processed_at"`
+}
+
+func GetCommand(s CelerixStore, id string) (*CommandRecord, error) {
+	c, err := sdk.Get[CommandRecord](s, SystemPersona, AppID, CommandKeyPrefix+id)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func SaveCommand(s CelerixStore, c CommandRecord) error {
+	return s.Set(SystemPersona, AppID, CommandKeyPrefix+c.ID, c)
+}
+
+func DeleteCommand(s CelerixStore, id string) error {
+	return s.Delete(SystemPersona, AppID, CommandKeyPrefix+id)
+}
+
+// ListCommands returns the commands in the given state, or all of them when
+// state is empty, most recently processed first.
+func ListCommands(s CelerixStore, state string) ([]CommandRecord, error) {
+	appStore, err := s.GetAppStore(SystemPersona, AppID)
+	if err != nil {
+		return []CommandRecord{}, nil
+	}
+	commands := []CommandRecord{}
+	for key := range appStore {
+		if !strings.HasPrefix(key, CommandKeyPrefix) {
+			continue
+		}
+		c, err := sdk.Get[CommandRecord](s, SystemPersona, AppID, key)
+		if err != nil || state != "" && c.State != state {
+			continue
+		}
+		commands = append(commands, c)
+	}
+	sort.Slice(commands, func(i, j int) bool { return commands[i].ProcessedAt > commands[j].ProcessedAt })
+	return commands, nil
+}
+
+// PruneCommands forgets the commands carried out before the cutoff, which
+// ends their idempotency. Dead letters are kept.
+func PruneCommands(s CelerixStore, before time.Time) (int, error) {
+	commands, err := ListCommands(s, CommandDone)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, c := range commands {
+		if c.ProcessedAt >= before.Unix() {
+			continue
+		}
+		if err := DeleteCommand(s, c.ID); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
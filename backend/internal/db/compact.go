@@ -0,0 +1,156 @@
+package db
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+// CompactReport describes a compaction run. Sizes are of the app's data as
+// the store encodes it, so they track what the backend has to keep.
+type CompactReport struct {
+	Time        int64          `json:"time"`
+	Keys        int            `json:"keys"`
+	KeysRemoved int            `json:"keys_removed"`
+	Removed     map[string]int `json:"removed"`
+	BytesBefore int64          `json:"bytes_before"`
+	BytesAfter  int64          `json:"bytes_after"`
+	Reclaimed   int64          `json:"reclaimed_bytes"`
+}
+
+// fileScopedPrefixes are the system keys that only matter while the file
+// they belong to exists. Deletes that raced with background work (content
+// extraction, downloads) can leave them behind.
+var fileScopedPrefixes = []string{
+	ContentKeyPrefix,
+	SnippetKeyPrefix,
+	DeletionKeyPrefix,
+	LinkPasswordKeyPrefix,
+	ExtendKeyPrefix,
+	MirrorStatusKeyPrefix,
+	AnalyticsKeyPrefix,
+}
+
+// Compact removes the records that outlived the files they belong to.
+func Compact(s CelerixStore) (*CompactReport, error) {
+	report := &CompactReport{Time: time.Now().Unix(), Removed: make(map[string]int)}
+
+	before, err := s.DumpApp(AppID)
+	if err != nil {
+		return nil, err
+	}
+	report.BytesBefore = encodedSize(before)
+	for _, app := range before {
+		report.Keys += len(app)
+	}
+
+	// File IDs are read after the candidates, so a file saved in between
+	// keeps its records: they are always written after the file itself
+	current, err := s.DumpApp(AppID)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]bool)
+	for _, app := range current {
+		for key := range app {
+			if id, ok := strings.CutPrefix(key, FileKeyPrefix); ok {
+				files[id] = true
+			}
+		}
+	}
+
+	for key := range before[SystemPersona] {
+		// Short links are keyed by slug and name their file in the value
+		if strings.HasPrefix(key, ShortLinkKeyPrefix) {
+			link, err := sdk.Get[ShortLinkRecord](s, SystemPersona, AppID, key)
+			if err == nil && !files[link.FileID] {
+				if err := s.Delete(SystemPersona, AppID, key); err != nil {
+					return nil, err
+				}
+				report.Removed["shortlink"]++
+				report.KeysRemoved++
+			}
+			continue
+		}
+		// So is the index of download links
+		if strings.HasPrefix(key, LinkKeyPrefix) {
+			link, err := sdk.Get[linkRecord](s, SystemPersona, AppID, key)
+			if err == nil && !files[link.FileID] {
+				if err := s.Delete(SystemPersona, AppID, key); err != nil {
+					return nil, err
+				}
+				report.Removed["link"]++
+				report.KeysRemoved++
+			}
+			continue
+		}
+		if prefix, hash, ok := blobScoped(key); ok {
+			if _, ok := current[SystemPersona][BlobKeyPrefix+hash]; !ok {
+				if err := s.Delete(SystemPersona, AppID, key); err != nil {
+					return nil, err
+				}
+				report.Removed[strings.TrimSuffix(prefix, ":")]++
+				report.KeysRemoved++
+			}
+			continue
+		}
+		// Chunks recorded while their session was being deleted
+		if rest, ok := strings.CutPrefix(key, UploadChunkKeyPrefix); ok {
+			id, _, _ := strings.Cut(rest, ":")
+			if _, ok := current[SystemPersona][UploadSessionKeyPrefix+id]; !ok {
+				if err := s.Delete(SystemPersona, AppID, key); err != nil {
+					return nil, err
+				}
+				report.Removed["uploadchunk"]++
+				report.KeysRemoved++
+			}
+			continue
+		}
+		for _, prefix := range fileScopedPrefixes {
+			rest, ok := strings.CutPrefix(key, prefix)
+			if !ok {
+				continue
+			}
+			// Analytics keys carry the hour after the file ID
+			id, _, _ := strings.Cut(rest, ":")
+			if !files[id] {
+				if err := s.Delete(SystemPersona, AppID, key); err != nil {
+					return nil, err
+				}
+				report.Removed[strings.TrimSuffix(prefix, ":")]++
+				report.KeysRemoved++
+			}
+			break
+		}
+	}
+
+	after, err := s.DumpApp(AppID)
+	if err != nil {
+		return nil, err
+	}
+	report.BytesAfter = encodedSize(after)
+	report.Reclaimed = max(report.BytesBefore-report.BytesAfter, 0)
+	return report, nil
+}
+
+func encodedSize(data map[string]map[string]any) int64 {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return 0
+	}
+	return int64(len(b))
+}
+
+// blobScoped reports whether key is one of the caches of a blob, and of
+// which.
+func blobScoped(key string) (prefix, hash string, ok bool) {
+	for _, prefix := range blobScopedPrefixes {
+		if rest, ok := strings.CutPrefix(key, prefix); ok {
+			hash, _, _ = strings.Cut(rest, ":")
+			return prefix, hash, true
+		}
+	}
+	return "", "", false
+}
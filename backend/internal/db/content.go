@@ -0,0 +1,49 @@
+package db
+
+import (
+	"strings"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+// ContentRecord holds text extracted from a file for content search.
+type ContentRecord struct {
+	FileID string `json:"file_id"`
+	Text   string `json:"text"`
+}
+
+func SaveFileText(s CelerixStore, fileID, text string) error {
+	return s.Set(SystemPersona, AppID, ContentKeyPrefix+fileID, ContentRecord{FileID: fileID, Text: strings.ToLower(text)})
+}
+
+func DeleteFileText(s CelerixStore, fileID string) error {
+	return s.Delete(SystemPersona, AppID, ContentKeyPrefix+fileID)
+}
+
+// loadContentIndex returns the extracted text of every indexed file keyed by
+// file ID.
+func loadContentIndex(s CelerixStore) map[string]string {
+	index := make(map[string]string)
+	appStore, err := s.GetAppStore(SystemPersona, AppID)
+	if err != nil {
+		return index
+	}
+	for k := range appStore {
+		if strings.HasPrefix(k, ContentKeyPrefix) {
+			rec, err := sdk.Get[ContentRecord](s, SystemPersona, AppID, k)
+			if err == nil {
+				index[rec.FileID] = rec.Text
+			}
+		}
+	}
+	return index
+}
+
+func SetClientContentIndexOptOut(s CelerixStore, id string, optOut bool) error {
+	client, err := GetClient(s, id)
+	if err != nil {
+		return err
+	}
+	client.ContentIndexOptOut = optOut
+	return s.Set(SystemPersona, AppID, ClientKeyPrefix+id, client)
+}
@@ -6,32 +6,129 @@ import (
 	"strings"
 
 	"github.com/celerix-dev/celerix-store/pkg/sdk"
+	"github.com/celerix/depot/internal/classify"
 )
 
 type CelerixStore = sdk.CelerixStore
 
 type FileRecord struct {
-	ID           string `json:"id"`
-	OriginalName string `json:"original_name"`
-	StoredPath   string `json:"stored_path"`
-	Size         int64  `json:"size"`
-	UploadTime   int64  `json:"upload_time"`
-	OwnerID      string `json:"owner_id"`
-	OwnerName    string `json:"owner_name"`
-	DownloadLink string `json:"download_link"`
-	IsPublic     bool   `json:"is_public"`
+	ID           string            `json:"id"`
+	OriginalName string            `json:"original_name"`
+	StoredPath   string            `json:"stored_path"`
+	Size         int64             `json:"size"`
+	UploadTime   int64             `json:"upload_time"`
+	OwnerID      string            `json:"owner_id"`
+	OwnerName    string            `json:"owner_name"`
+	DownloadLink string            `json:"download_link"`
+	IsPublic     bool              `json:"is_public"`
+	SHA256       string            `json:"sha256"`
+	Quarantined  bool              `json:"quarantined"`
+	Sanitized    []string          `json:"sanitized"`
+	SystemTags   []string          `json:"system_tags"`
+	ExpiresAt    int64             `json:"expires_at,omitempty"`
+	Folder       string            `json:"folder,omitempty"`
+	Tags         []string          `json:"tags"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	// LandingPage overrides the instance default for showing a landing page
+	LandingPage *bool `json:"landing_page,omitempty"`
+	// ScannedAt is when pre-upload hooks accepted the file
+	ScannedAt int64 `json:"scanned_at,omitempty"`
+	// PublishAt embargoes the download link until the given time
+	PublishAt int64 `json:"publish_at,omitempty"`
+	// MaxDownloads and MaxBytes cap the link; zero means unlimited
+	MaxDownloads int64 `json:"max_downloads,omitempty"`
+	MaxBytes     int64 `json:"max_bytes,omitempty"`
+	Downloads    int64 `json:"downloads"`
+	BytesServed  int64 `json:"bytes_served"`
+	// AllowedNetworks (CIDR prefixes) and AllowedCountries (ISO codes)
+	// restrict where the link can be used from; empty means anywhere
+	AllowedNetworks  []string `json:"allowed_networks,omitempty"`
+	AllowedCountries []string `json:"allowed_countries,omitempty"`
+	// PasswordProtected links need the password kept in LinkPassword
+	PasswordProtected bool `json:"password_protected,omitempty"`
+	// External content lives outside the depot's storage, either imported
+	// in place or, for link files, at TargetURL; the depot never deletes or
+	// moves it
+	External bool `json:"external,omitempty"`
+	// TargetURL makes the record a link file: it has no content and its
+	// download link redirects here
+	TargetURL string `json:"target_url,omitempty"`
+	// RawName is the name as uploaded when sanitizing changed it
+	RawName string `json:"raw_name,omitempty"`
+	// ExpiryNotifiedAt is when the owner was told the link is about to
+	// expire
+	ExpiryNotifiedAt int64 `json:"expiry_notified_at,omitempty"`
+	// Extensions counts how often the link's expiry was pushed back;
+	// ExtendDisabled rules out extending it
+	Extensions     int  `json:"extensions,omitempty"`
+	ExtendDisabled bool `json:"extend_disabled,omitempty"`
+	// RetainUntil locks a file uploaded into a write-once folder: until
+	// then it can't be renamed, moved or deleted
+	RetainUntil int64 `json:"retain_until,omitempty"`
+	// SignedBy names the trusted key whose signature the upload carried
+	SignedBy string `json:"signed_by,omitempty"`
+	// LinkRotatedAt is when the download link was last replaced; until
+	// then the link is as old as the upload
+	LinkRotatedAt int64 `json:"link_rotated_at,omitempty"`
 }
 
 type ListFilesOptions struct {
 	Search  string
 	OwnerID string
-	Limit   int
-	Offset  int
+	// GroupID also lists the files of the client group OwnerID is in
+	GroupID string
+	Tag     string
+	Folder  string
+	// Type is a type group (see classify.Group), Owner a file owner's ID
+	// and Size a size bucket (see SizeBuckets)
+	Type   string
+	Owner  string
+	Size   string
+	Limit  int
+	Offset int
+	// Facets adds the facet counts of the matching files to the response
+	Facets bool
+	// View (ViewRecent or ViewFrequent) lists only the files ViewerID
+	// opened, in the view's order
+	View     string
+	ViewerID string
+}
+
+// visible reports whether the file is listed at all: admins (no OwnerID)
+// see everything, clients their own files, their group's and the public
+// ones. Quarantined files are never shared publicly.
+func (opts ListFilesOptions) visible(r *FileRecord) bool {
+	return opts.OwnerID == "" || r.OwnerID == opts.OwnerID || (opts.GroupID != "" && r.OwnerID == opts.GroupID) || (r.IsPublic && !r.Quarantined)
+}
+
+// matches applies the search and the filters. text is the file's
+// extracted content, which the search also looks in.
+func (opts ListFilesOptions) matches(r *FileRecord, text string) bool {
+	if search := strings.ToLower(opts.Search); search != "" && !strings.Contains(strings.ToLower(r.OriginalName), search) && !strings.Contains(text, search) {
+		return false
+	}
+	if opts.Tag != "" && !r.HasTag(opts.Tag) {
+		return false
+	}
+	if opts.Folder != "" && !r.InFolder(opts.Folder) {
+		return false
+	}
+	if opts.Type != "" && classify.Group(r.OriginalName, r.SystemTags) != opts.Type {
+		return false
+	}
+	if opts.Owner != "" && r.OwnerID != opts.Owner {
+		return false
+	}
+	if opts.Size != "" && SizeBucket(r.Size) != opts.Size {
+		return false
+	}
+	return true
 }
 
 type FileListResponse struct {
-	Files []FileRecord `json:"files"`
-	Total int          `json:"total"`
+	Files  []FileRecord `json:"files"`
+	Total  int          `json:"total"`
+	Facets *FileFacets  `json:"facets,omitempty"`
 }
 
 type ClientRecord struct {
@@ -40,50 +137,295 @@ type ClientRecord struct {
 	RecoveryCode string `json:"recovery_code"`
 	LastActive   int64  `json:"last_active"`
 	IsAdmin      bool   `json:"is_admin"`
+	// ContentIndexOptOut disables text extraction for the client's uploads
+	ContentIndexOptOut bool `json:"content_index_opt_out"`
+	// EmailAlias is the mailbox name whose attachments become the client's files
+	EmailAlias string `json:"email_alias,omitempty"`
 }
 
 const (
-	AppID           = "depot"
-	FileKeyPrefix   = "file:"
-	ClientKeyPrefix = "client:"
-	SystemPersona   = sdk.SystemPersona
+	AppID            = "depot"
+	FileKeyPrefix    = "file:"
+	ClientKeyPrefix  = "client:"
+	BlobKeyPrefix    = "blob:"
+	AuditKeyPrefix   = "audit:"
+	ContentKeyPrefix = "text:"
+	SystemPersona    = sdk.SystemPersona
 )
 
 func SaveFileRecord(s CelerixStore, record FileRecord) error {
-	persona := record.OwnerID
-	if persona == "" {
-		persona = SystemPersona
+	limitMu.Lock(s)
+	op := ChangeUpdate
+	previous := keepCounters(s, filePersona(record), &record)
+	if previous == nil {
+		op = ChangeCreate
+	}
+	err := putFileRecord(s, record)
+	if err == nil {
+		err = reindexLink(s, record, previous)
+	}
+	limitMu.Unlock()
+	if err != nil {
+		return err
+	}
+	appendChange(s, op, record, record.OwnerID)
+	return nil
+}
+
+// saveFileQuietly stores a record whose change is nothing sync clients
+// need to hear about, so it gets no journal entry.
+func saveFileQuietly(s CelerixStore, record FileRecord) error {
+	limitMu.Lock(s)
+	defer limitMu.Unlock()
+	previous := keepCounters(s, filePersona(record), &record)
+	if err := putFileRecord(s, record); err != nil {
+		return err
+	}
+	return reindexLink(s, record, previous)
+}
+
+// keepCounters copies the download counters of the record stored under
+// persona onto record, and returns the stored one, if any. Only
+// ChargeDownload and SetFileLimits change the counters; everyone else
+// writes the record back as they read it, and would otherwise undo the
+// downloads counted in between. The caller holds limitMu.
+func keepCounters(s CelerixStore, persona string, record *FileRecord) *FileRecord {
+	stored, err := sdk.Get[FileRecord](s, persona, AppID, FileKeyPrefix+record.ID)
+	if err != nil {
+		return nil
 	}
-	return s.Set(persona, AppID, FileKeyPrefix+record.ID, record)
+	record.Downloads = stored.Downloads
+	record.BytesServed = stored.BytesServed
+	return &stored
+}
+
+// putFileRecord stores record as it is under its owner's persona. The
+// caller holds limitMu.
+func putFileRecord(s CelerixStore, record FileRecord) error {
+	return s.Set(filePersona(record), AppID, FileKeyPrefix+record.ID, record)
+}
+
+// filePersona is the persona a file record is stored under.
+func filePersona(record FileRecord) string {
+	if record.OwnerID == "" {
+		return SystemPersona
+	}
+	return record.OwnerID
 }
 
 func UpdateFileRecord(s CelerixStore, id string, name string, ownerID string, isPublic bool) error {
+	transferMu.Lock(s)
+	defer transferMu.Unlock()
+
 	record, err := GetFileRecord(s, id)
 	if err != nil {
 		return err
 	}
-	oldPersona := record.OwnerID
-	if oldPersona == "" {
-		oldPersona = SystemPersona
-	}
-
+	previous := *record
 	record.OriginalName = name
 	record.OwnerID = ownerID
 	record.IsPublic = isPublic
+	if err := moveFileRecord(s, *record, previous); err != nil {
+		return err
+	}
+	appendChange(s, ChangeUpdate, *record, previous.OwnerID)
+	return nil
+}
+
+// transferMu keeps owner changes of the same file from interleaving.
+var transferMu = sharedMutex{name: "transfers"}
 
-	newPersona := ownerID
-	if newPersona == "" {
-		newPersona = SystemPersona
+// TransferFile hands a file to another owner. The caller checks that the
+// owner exists.
+func TransferFile(s CelerixStore, id string, ownerID string) (*FileRecord, error) {
+	transferMu.Lock(s)
+	defer transferMu.Unlock()
+
+	record, err := GetFileRecord(s, id)
+	if err != nil {
+		return nil, err
+	}
+	if record.OwnerID == ownerID {
+		return record, nil
+	}
+	previous := *record
+	record.OwnerID = ownerID
+	if err := moveFileRecord(s, *record, previous); err != nil {
+		return nil, err
+	}
+	appendChange(s, ChangeUpdate, *record, previous.OwnerID)
+	return record, nil
+}
+
+// moveFileRecord saves record, which previous was, under its owner's persona.
+// The store has no transactions, so the new content is written in place and
+// then moved in one step; the move is the commit point. If it fails the
+// previous record is put back, so a file is never half-transferred.
+// Downloads are not counted until the record has arrived.
+func moveFileRecord(s CelerixStore, record FileRecord, previous FileRecord) error {
+	limitMu.Lock(s)
+	defer limitMu.Unlock()
+
+	oldPersona := filePersona(previous)
+	newPersona := filePersona(record)
+	keepCounters(s, oldPersona, &record)
+	keepCounters(s, oldPersona, &previous)
+	if err := s.Set(oldPersona, AppID, FileKeyPrefix+record.ID, record); err != nil {
+		return err
+	}
+	if oldPersona == newPersona {
+		return nil
+	}
+	if err := s.Move(oldPersona, newPersona, AppID, FileKeyPrefix+record.ID); err != nil {
+		_ = s.Set(oldPersona, AppID, FileKeyPrefix+record.ID, previous)
+		return err
+	}
+	return nil
+}
+
+// Retained reports whether the file is still locked by its retention at
+// the given Unix time.
+func (r *FileRecord) Retained(now int64) bool {
+	return r.RetainUntil > now
+}
+
+// HasTag matches both the tags set by clients and those set by the system.
+// IsLink reports whether the record is a link file.
+func (r *FileRecord) IsLink() bool {
+	return r.TargetURL != ""
+}
+
+func (r *FileRecord) HasTag(tag string) bool {
+	tag = strings.TrimPrefix(tag, "#")
+	for _, t := range r.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	for _, t := range r.SystemTags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
 	}
+	return false
+}
 
-	if oldPersona != newPersona {
-		if err := s.Move(oldPersona, newPersona, AppID, FileKeyPrefix+id); err != nil {
-			return err
+// InFolder reports whether the file is in folder or one of its subfolders.
+func (r *FileRecord) InFolder(folder string) bool {
+	return r.Folder == folder || strings.HasPrefix(r.Folder, folder+"/")
+}
+
+// NormalizeFolder turns user input into a clean, slash-separated folder path
+// without leading or trailing slashes. Parent references are dropped.
+func NormalizeFolder(folder string) string {
+	var parts []string
+	for _, p := range strings.Split(strings.ReplaceAll(folder, "\\", "/"), "/") {
+		p = strings.TrimSpace(p)
+		if p == "" || p == "." || p == ".." {
+			continue
 		}
+		parts = append(parts, p)
 	}
+	return strings.Join(parts, "/")
+}
+
+// ParseTags splits a comma or space separated list, accepting "#tag" as well
+// as "tag". Tags are lowercased and deduplicated.
+func ParseTags(s string) []string {
+	seen := make(map[string]bool)
+	var tags []string
+	for _, t := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' }) {
+		t = strings.ToLower(strings.TrimPrefix(t, "#"))
+		if t != "" && !seen[t] {
+			seen[t] = true
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
 
-	// Always update the record content
-	return s.Set(newPersona, AppID, FileKeyPrefix+record.ID, record)
+func SetFileTagsAndFolder(s CelerixStore, id string, tags []string, folder string) error {
+	record, err := GetFileRecord(s, id)
+	if err != nil {
+		return err
+	}
+	record.Tags = tags
+	record.Folder = folder
+	return SaveFileRecord(s, *record)
+}
+
+func SetFileLandingPage(s CelerixStore, id string, landing *bool) error {
+	record, err := GetFileRecord(s, id)
+	if err != nil {
+		return err
+	}
+	record.LandingPage = landing
+	return SaveFileRecord(s, *record)
+}
+
+func SetFilePublishAt(s CelerixStore, id string, publishAt int64) error {
+	record, err := GetFileRecord(s, id)
+	if err != nil {
+		return err
+	}
+	record.PublishAt = publishAt
+	return SaveFileRecord(s, *record)
+}
+
+// QuarantineFile withholds the file from everyone but admins.
+func QuarantineFile(s CelerixStore, id string) error {
+	record, err := GetFileRecord(s, id)
+	if err != nil {
+		return err
+	}
+	record.Quarantined = true
+	return SaveFileRecord(s, *record)
+}
+
+// SetFileExpiresAt sets when the link expires; 0 keeps it forever.
+func SetFileExpiresAt(s CelerixStore, id string, expiresAt int64) (*FileRecord, error) {
+	record, err := GetFileRecord(s, id)
+	if err != nil {
+		return nil, err
+	}
+	record.ExpiresAt = expiresAt
+	if err := SaveFileRecord(s, *record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// RotateDownloadLink replaces the file's download link, so the old one
+// stops working.
+func RotateDownloadLink(s CelerixStore, id, link string, at int64) (*FileRecord, error) {
+	record, err := GetFileRecord(s, id)
+	if err != nil {
+		return nil, err
+	}
+	record.DownloadLink = link
+	record.LinkRotatedAt = at
+	if err := SaveFileRecord(s, *record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func SetFileTargetURL(s CelerixStore, id string, target string) error {
+	record, err := GetFileRecord(s, id)
+	if err != nil {
+		return err
+	}
+	record.TargetURL = target
+	return SaveFileRecord(s, *record)
+}
+
+func SetFileSystemTags(s CelerixStore, id string, tags []string) error {
+	record, err := GetFileRecord(s, id)
+	if err != nil {
+		return err
+	}
+	record.SystemTags = tags
+	return SaveFileRecord(s, *record)
 }
 
 func DeleteFileRecord(s CelerixStore, id string) error {
@@ -95,7 +437,22 @@ func DeleteFileRecord(s CelerixStore, id string) error {
 	if persona == "" {
 		persona = SystemPersona
 	}
-	return s.Delete(persona, AppID, FileKeyPrefix+id)
+	_ = DeleteFileText(s, id)
+	_ = DeleteSnippet(s, id)
+	_ = DeleteDeletionToken(s, id)
+	_ = DeleteAnalytics(s, id)
+	_ = DeleteLinkPassword(s, id)
+	_ = DeleteExtendToken(s, id)
+	_ = DeleteMirrorStatus(s, id)
+	_ = unindexLink(s, record.DownloadLink, id)
+	if ref := record.Metadata["artifact"]; ref != "" {
+		_ = DeleteArtifact(s, ref, id)
+	}
+	if err := s.Delete(persona, AppID, FileKeyPrefix+id); err != nil {
+		return err
+	}
+	appendChange(s, ChangeDelete, *record, record.OwnerID)
+	return nil
 }
 
 func GetFileRecord(s CelerixStore, id string) (*FileRecord, error) {
@@ -109,18 +466,7 @@ func GetFileRecord(s CelerixStore, id string) (*FileRecord, error) {
 		return nil, err
 	}
 
-	// Fetch owner name
-	if record.OwnerID != "" {
-		client, err := GetClient(s, record.OwnerID)
-		if err == nil {
-			record.OwnerName = client.Name
-		} else {
-			record.OwnerName = "Unknown"
-		}
-	} else {
-		record.OwnerName = "Admin"
-	}
-
+	record.OwnerName = ownerName(s, record.OwnerID)
 	return &record, nil
 }
 
@@ -141,10 +487,7 @@ func ListFiles(s CelerixStore, opts ListFilesOptions) (*FileListResponse, error)
 			if strings.HasPrefix(k, FileKeyPrefix) {
 				r, err := sdk.Get[FileRecord](s, personaID, AppID, k)
 				if err == nil {
-					// Logic for inclusion:
-					// 1. If it's admin (opts.OwnerID == ""), include everything.
-					// 2. If it's a specific owner, include if r.OwnerID == opts.OwnerID OR r.IsPublic is true.
-					if opts.OwnerID == "" || r.OwnerID == opts.OwnerID || r.IsPublic {
+					if opts.visible(&r) {
 						allRecords = append(allRecords, r)
 					}
 				}
@@ -152,24 +495,20 @@ func ListFiles(s CelerixStore, opts ListFilesOptions) (*FileListResponse, error)
 		}
 	}
 
+	// Search matches file names as well as extracted content
+	search := strings.ToLower(opts.Search)
+	var contentIndex map[string]string
+	if search != "" {
+		contentIndex = loadContentIndex(s)
+	}
+
 	var filtered []FileRecord
 	for _, r := range allRecords {
-		// Filter by search
-		if opts.Search != "" && !strings.Contains(strings.ToLower(r.OriginalName), strings.ToLower(opts.Search)) {
+		if !opts.matches(&r, contentIndex[r.ID]) {
 			continue
 		}
 
-		// Fetch owner name
-		if r.OwnerID != "" {
-			client, err := GetClient(s, r.OwnerID)
-			if err == nil {
-				r.OwnerName = client.Name
-			} else {
-				r.OwnerName = "Unknown"
-			}
-		} else {
-			r.OwnerName = "Admin"
-		}
+		r.OwnerName = ownerName(s, r.OwnerID)
 		filtered = append(filtered, r)
 	}
 
@@ -177,6 +516,9 @@ func ListFiles(s CelerixStore, opts ListFilesOptions) (*FileListResponse, error)
 	sort.Slice(filtered, func(i, j int) bool {
 		return filtered[i].UploadTime > filtered[j].UploadTime
 	})
+	if opts.View != "" {
+		filtered = sortByAccess(filtered, GetClientAccess(s, opts.ViewerID).Files, opts.View)
+	}
 
 	total := len(filtered)
 
@@ -190,10 +532,14 @@ func ListFiles(s CelerixStore, opts ListFilesOptions) (*FileListResponse, error)
 		end = total
 	}
 
-	return &FileListResponse{
+	resp := &FileListResponse{
 		Files: filtered[start:end],
 		Total: total,
-	}, nil
+	}
+	if opts.Facets {
+		resp.Facets = countFacets(filtered)
+	}
+	return resp, nil
 }
 
 func GetAllFileRecords(s CelerixStore) ([]FileRecord, error) {
@@ -228,6 +574,7 @@ func UpsertClient(s CelerixStore, id, name, recoveryCode string, lastActive int6
 		client.RecoveryCode = recoveryCode
 		client.LastActive = lastActive
 	}
+	defer forgetOwnerName(s, id)
 	return s.Set(SystemPersona, AppID, ClientKeyPrefix+id, client)
 }
 
@@ -240,7 +587,12 @@ func UpdateClientLastActive(s CelerixStore, id string, lastActive int64) error {
 	return s.Set(SystemPersona, AppID, ClientKeyPrefix+id, client)
 }
 
+// DeleteClient removes the client and takes them out of their group.
 func DeleteClient(s CelerixStore, id string) error {
+	if err := RemoveGroupMember(s, id); err != nil {
+		return err
+	}
+	defer forgetOwnerName(s, id)
 	return s.Delete(SystemPersona, AppID, ClientKeyPrefix+id)
 }
 
@@ -309,5 +661,31 @@ func UpdateClientFull(s CelerixStore, id string, name string, recoveryCode strin
 	client.Name = name
 	client.RecoveryCode = recoveryCode
 	client.IsAdmin = isAdmin
+	defer forgetOwnerName(s, id)
 	return s.Set(SystemPersona, AppID, ClientKeyPrefix+id, client)
 }
+
+// ListOwnerFiles returns every file record owned by the client, including
+// quarantined ones, in no particular order.
+func ListOwnerFiles(s CelerixStore, ownerID string) ([]FileRecord, error) {
+	persona := ownerID
+	if persona == "" {
+		persona = SystemPersona
+	}
+	appStore, err := s.GetAppStore(persona, AppID)
+	if err != nil {
+		return []FileRecord{}, nil
+	}
+	files := []FileRecord{}
+	for key := range appStore {
+		if !strings.HasPrefix(key, FileKeyPrefix) {
+			continue
+		}
+		r, err := sdk.Get[FileRecord](s, persona, AppID, key)
+		if err != nil {
+			continue
+		}
+		files = append(files, r)
+	}
+	return files, nil
+}
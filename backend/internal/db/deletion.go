@@ -0,0 +1,42 @@
+package db
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+const DeletionKeyPrefix = "deltoken:"
+
+// DeletionToken lets whoever holds it delete a single file without a
+// persona, for tools that hand out deletion URLs.
+type DeletionToken struct {
+	FileID string `json:"file_id"`
+	Token  string `json:"token"`
+}
+
+func CreateDeletionToken(s CelerixStore, fileID string) (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+	if err := s.Set(SystemPersona, AppID, DeletionKeyPrefix+fileID, DeletionToken{FileID: fileID, Token: token}); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func CheckDeletionToken(s CelerixStore, fileID, token string) bool {
+	t, err := sdk.Get[DeletionToken](s, SystemPersona, AppID, DeletionKeyPrefix+fileID)
+	if err != nil || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(t.Token), []byte(token)) == 1
+}
+
+func DeleteDeletionToken(s CelerixStore, fileID string) error {
+	return s.Delete(SystemPersona, AppID, DeletionKeyPrefix+fileID)
+}
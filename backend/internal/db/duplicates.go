@@ -0,0 +1,133 @@
+package db
+
+import (
+	"log"
+	"sort"
+)
+
+type DuplicateGroup struct {
+	SHA256           string       `json:"sha256"`
+	Size             int64        `json:"size"`
+	Count            int          `json:"count"`
+	DuplicateBytes   int64        `json:"duplicate_bytes"`
+	ReclaimableBytes int64        `json:"reclaimable_bytes"`
+	Files            []FileRecord `json:"files"`
+}
+
+type DuplicateReport struct {
+	Groups           []DuplicateGroup `json:"groups"`
+	DuplicateBytes   int64            `json:"duplicate_bytes"`
+	ReclaimableBytes int64            `json:"reclaimable_bytes"`
+	Unhashed         int              `json:"unhashed"`
+}
+
+// FindDuplicates groups files by content hash. DuplicateBytes counts every
+// extra copy, ReclaimableBytes only the copies that occupy their own space
+// on disk. An empty ownerID covers every file.
+func FindDuplicates(s CelerixStore, ownerID string) (*DuplicateReport, error) {
+	resp, err := ListFiles(s, ListFilesOptions{OwnerID: ownerID})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DuplicateReport{}
+	byHash := make(map[string][]FileRecord)
+	for _, r := range resp.Files {
+		if ownerID != "" && r.OwnerID != ownerID {
+			continue
+		}
+		// External files take no depot space and can't be merged away
+		if r.External {
+			continue
+		}
+		if r.SHA256 == "" {
+			report.Unhashed++
+			continue
+		}
+		byHash[r.SHA256] = append(byHash[r.SHA256], r)
+	}
+
+	for hash, files := range byHash {
+		if len(files) < 2 {
+			continue
+		}
+		paths := make(map[string]bool)
+		for _, f := range files {
+			paths[f.StoredPath] = true
+		}
+		size := files[0].Size
+		group := DuplicateGroup{
+			SHA256:           hash,
+			Size:             size,
+			Count:            len(files),
+			DuplicateBytes:   size * int64(len(files)-1),
+			ReclaimableBytes: size * int64(len(paths)-1),
+			Files:            files,
+		}
+		report.Groups = append(report.Groups, group)
+		report.DuplicateBytes += group.DuplicateBytes
+		report.ReclaimableBytes += group.ReclaimableBytes
+	}
+
+	sort.Slice(report.Groups, func(i, j int) bool {
+		return report.Groups[i].DuplicateBytes > report.Groups[j].DuplicateBytes
+	})
+	return report, nil
+}
+
+// RepointFile changes where a record's content lives without touching
+// anything else about it.
+func RepointFile(s CelerixStore, id, storedPath string) error {
+	record, err := GetFileRecord(s, id)
+	if err != nil {
+		return err
+	}
+	record.StoredPath = storedPath
+	return SaveFileRecord(s, *record)
+}
+
+// RepointBlob makes keepPath the content of every file with the hash, of
+// every owner, and rebuilds the blob from them: it points at keepPath and
+// counts each of the files. It returns the IDs of the files it repointed
+// and the paths that no file points at anymore, for the caller to delete.
+func RepointBlob(s CelerixStore, hash, keepPath string) (repointed, obsolete []string, err error) {
+	blobMu.Lock(s)
+	defer blobMu.Unlock()
+
+	resp, err := ListFiles(s, ListFilesOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	previous := make(map[string]bool)
+	if blob, err := GetBlob(s, hash); err == nil {
+		previous[blob.StoredPath] = true
+	}
+	used := map[string]bool{keepPath: true}
+	blob := BlobRecord{Hash: hash, StoredPath: keepPath}
+	for _, r := range resp.Files {
+		if r.SHA256 != hash || r.External {
+			continue
+		}
+		blob.Size = r.Size
+		blob.RefCount++
+		if r.StoredPath == keepPath {
+			continue
+		}
+		previous[r.StoredPath] = true
+		if err := RepointFile(s, r.ID, keepPath); err != nil {
+			log.Printf("[ERROR] Failed to repoint %s: %v", r.ID, err)
+			used[r.StoredPath] = true
+			continue
+		}
+		repointed = append(repointed, r.ID)
+	}
+	if err := s.Set(SystemPersona, AppID, BlobKeyPrefix+hash, blob); err != nil {
+		return repointed, nil, err
+	}
+	for path := range previous {
+		if !used[path] {
+			obsolete = append(obsolete, path)
+		}
+	}
+	return repointed, obsolete, nil
+}
@@ -0,0 +1,50 @@
+package db
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/celerix/depot/internal/memstore"
+)
+
+func TestRepointBlob(t *testing.T) {
+	s := memstore.New()
+	for _, r := range []FileRecord{
+		{ID: "a", OwnerID: "alice", StoredPath: "/old", SHA256: "h", Size: 5},
+		{ID: "b", OwnerID: "bob", StoredPath: "/kept", SHA256: "h", Size: 5},
+		{ID: "c", OwnerID: "alice", StoredPath: "/copy", SHA256: "h", Size: 5},
+		{ID: "d", OwnerID: "bob", StoredPath: "/mnt/share/h", SHA256: "h", Size: 5, External: true},
+		{ID: "e", OwnerID: "bob", StoredPath: "/other", SHA256: "other", Size: 9},
+	} {
+		if err := SaveFileRecord(s, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Set(SystemPersona, AppID, BlobKeyPrefix+"h", BlobRecord{Hash: "h", Size: 5, StoredPath: "/old", RefCount: 1})
+
+	repointed, obsolete, err := RepointBlob(s, "h", "/kept")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(repointed)
+	sort.Strings(obsolete)
+	if len(repointed) != 2 || repointed[0] != "a" || repointed[1] != "c" {
+		t.Errorf("expected a and c to be repointed, got %v", repointed)
+	}
+	// The blob's old path goes too, not only those of the repointed files
+	if len(obsolete) != 2 || obsolete[0] != "/copy" || obsolete[1] != "/old" {
+		t.Errorf("expected /copy and /old to be obsolete, got %v", obsolete)
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		if r, _ := GetFileRecord(s, id); r.StoredPath != "/kept" {
+			t.Errorf("%s still points at %s", id, r.StoredPath)
+		}
+	}
+	if r, _ := GetFileRecord(s, "d"); r.StoredPath != "/mnt/share/h" {
+		t.Errorf("the external file was repointed to %s", r.StoredPath)
+	}
+	blob, err := GetBlob(s, "h")
+	if err != nil || blob.StoredPath != "/kept" || blob.RefCount != 3 || blob.Size != 5 {
+		t.Errorf("expected the blob at /kept with 3 references, got %+v", blob)
+	}
+}
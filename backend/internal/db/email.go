@@ -0,0 +1,39 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+// GetClientByEmailAlias finds the client owning the mailbox alias.
+func GetClientByEmailAlias(s CelerixStore, alias string) (*ClientRecord, error) {
+	alias = strings.ToLower(alias)
+	if alias == "" {
+		return nil, fmt.Errorf("client not found")
+	}
+	appStore, err := s.GetAppStore(SystemPersona, AppID)
+	if err != nil {
+		return nil, err
+	}
+
+	for k := range appStore {
+		if strings.HasPrefix(k, ClientKeyPrefix) {
+			c, err := sdk.Get[ClientRecord](s, SystemPersona, AppID, k)
+			if err == nil && c.EmailAlias == alias {
+				return &c, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("client not found")
+}
+
+func SetClientEmailAlias(s CelerixStore, id, alias string) error {
+	client, err := GetClient(s, id)
+	if err != nil {
+		return err
+	}
+	client.EmailAlias = strings.ToLower(alias)
+	return s.Set(SystemPersona, AppID, ClientKeyPrefix+id, client)
+}
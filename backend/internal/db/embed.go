@@ -0,0 +1,26 @@
+package db
+
+import (
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+const EmbedKeyPrefix = "embed:"
+
+// EmbedPolicy restricts which sites may show a client's files in an embed
+// widget. Origins are scheme://host[:port]; none allows every site.
+type EmbedPolicy struct {
+	OwnerID        string   `json:"owner_id"`
+	AllowedOrigins []string `json:"allowed_origins"`
+}
+
+func GetEmbedPolicy(s CelerixStore, ownerID string) (*EmbedPolicy, error) {
+	p, err := sdk.Get[EmbedPolicy](s, SystemPersona, AppID, EmbedKeyPrefix+ownerID)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func SaveEmbedPolicy(s CelerixStore, p EmbedPolicy) error {
+	return s.Set(SystemPersona, AppID, EmbedKeyPrefix+p.OwnerID, p)
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"strings"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+// EachFile calls fn for every file ListFiles would return for opts, in no
+// particular order. Personas are read one at a time, so the whole listing is
+// never held at once. Limit and Offset are ignored; an error from fn stops
+// the walk and is returned.
+func EachFile(s CelerixStore, opts ListFilesOptions, fn func(FileRecord) error) error {
+	personas, err := s.GetPersonas()
+	if err != nil {
+		return err
+	}
+	owners := make(map[string]string)
+	for _, persona := range personas {
+		appStore, err := s.GetAppStore(persona, AppID)
+		if err != nil {
+			continue
+		}
+		for k := range appStore {
+			if !strings.HasPrefix(k, FileKeyPrefix) {
+				continue
+			}
+			r, err := sdk.Get[FileRecord](s, persona, AppID, k)
+			if err != nil || !opts.visible(&r) {
+				continue
+			}
+			text := ""
+			if opts.Search != "" {
+				if rec, err := sdk.Get[ContentRecord](s, SystemPersona, AppID, ContentKeyPrefix+r.ID); err == nil {
+					text = rec.Text
+				}
+			}
+			if !opts.matches(&r, text) {
+				continue
+			}
+
+			name, ok := owners[r.OwnerID]
+			if !ok {
+				name = "Admin"
+				if r.OwnerID != "" {
+					name = "Unknown"
+					if client, err := GetClient(s, r.OwnerID); err == nil {
+						name = client.Name
+					}
+				}
+				owners[r.OwnerID] = name
+			}
+			r.OwnerName = name
+			if err := fn(r); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
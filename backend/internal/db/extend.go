@@ -0,0 +1,69 @@
+package db
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+const ExtendKeyPrefix = "extendtoken:"
+
+// ExtendToken lets whoever holds it extend a file's link once, for the
+// one-click link in expiry reminders.
+type ExtendToken struct {
+	FileID string `json:"file_id"`
+	Token  string `json:"token"`
+}
+
+// CreateExtendToken replaces the file's extend token with a new one.
+func CreateExtendToken(s CelerixStore, fileID string) (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+	if err := s.Set(SystemPersona, AppID, ExtendKeyPrefix+fileID, ExtendToken{FileID: fileID, Token: token}); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func CheckExtendToken(s CelerixStore, fileID, token string) bool {
+	t, err := sdk.Get[ExtendToken](s, SystemPersona, AppID, ExtendKeyPrefix+fileID)
+	if err != nil || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(t.Token), []byte(token)) == 1
+}
+
+func DeleteExtendToken(s CelerixStore, fileID string) error {
+	return s.Delete(SystemPersona, AppID, ExtendKeyPrefix+fileID)
+}
+
+// ExtendFileExpiry moves the link's expiry to expiresAt and counts the
+// extension. The owner is reminded again before the new expiry.
+func ExtendFileExpiry(s CelerixStore, id string, expiresAt int64) (*FileRecord, error) {
+	record, err := GetFileRecord(s, id)
+	if err != nil {
+		return nil, err
+	}
+	record.ExpiresAt = expiresAt
+	record.Extensions++
+	record.ExpiryNotifiedAt = 0
+	if err := SaveFileRecord(s, *record); err != nil {
+		return nil, err
+	}
+	_ = DeleteExtendToken(s, id)
+	return record, nil
+}
+
+func SetFileExtendDisabled(s CelerixStore, id string, disabled bool) error {
+	record, err := GetFileRecord(s, id)
+	if err != nil {
+		return err
+	}
+	record.ExtendDisabled = disabled
+	return SaveFileRecord(s, *record)
+}
@@ -0,0 +1,97 @@
+package db
+
+import (
+	"sort"
+
+	"github.com/celerix/depot/internal/classify"
+)
+
+// maxTagFacets bounds the tags counted in facets to the most used ones.
+const maxTagFacets = 50
+
+// SizeBuckets are the size ranges of the size facet and filter, in order.
+// A file falls in the first bucket whose Max it doesn't exceed.
+var SizeBuckets = []struct {
+	Name string
+	Max  int64
+}{
+	{"small", 1 << 20},
+	{"medium", 100 << 20},
+	{"large", 1 << 30},
+	{"huge", 1<<63 - 1},
+}
+
+// SizeBucket names the size range a file of size bytes falls in.
+func SizeBucket(size int64) string {
+	for _, b := range SizeBuckets {
+		if size <= b.Max {
+			return b.Name
+		}
+	}
+	return SizeBuckets[len(SizeBuckets)-1].Name
+}
+
+// FacetCount is how many listed files have a value.
+type FacetCount struct {
+	Value string `json:"value"`
+	// Name is the owner's name on owner facets
+	Name  string `json:"name,omitempty"`
+	Count int    `json:"count"`
+}
+
+// FileFacets count the files matching a listing by type group, owner, tag
+// and size, over all pages. Types, owners and tags are the most frequent
+// first; sizes are every bucket, smallest first.
+type FileFacets struct {
+	Types  []FacetCount `json:"types"`
+	Owners []FacetCount `json:"owners"`
+	Tags   []FacetCount `json:"tags"`
+	Sizes  []FacetCount `json:"sizes"`
+}
+
+// countFacets counts the facets of records, whose owner names are filled
+// in.
+func countFacets(records []FileRecord) *FileFacets {
+	types := make(map[string]int)
+	owners := make(map[string]int)
+	ownerNames := make(map[string]string)
+	tags := make(map[string]int)
+	sizes := make(map[string]int)
+	for _, r := range records {
+		types[classify.Group(r.OriginalName, r.SystemTags)]++
+		owners[r.OwnerID]++
+		ownerNames[r.OwnerID] = r.OwnerName
+		for _, t := range r.Tags {
+			tags[t]++
+		}
+		sizes[SizeBucket(r.Size)]++
+	}
+
+	f := &FileFacets{
+		Types:  byCount(types, nil),
+		Owners: byCount(owners, ownerNames),
+		Tags:   byCount(tags, nil),
+		Sizes:  make([]FacetCount, 0, len(SizeBuckets)),
+	}
+	if len(f.Tags) > maxTagFacets {
+		f.Tags = f.Tags[:maxTagFacets]
+	}
+	for _, b := range SizeBuckets {
+		f.Sizes = append(f.Sizes, FacetCount{Value: b.Name, Count: sizes[b.Name]})
+	}
+	return f
+}
+
+func byCount(counts map[string]int, names map[string]string) []FacetCount {
+	facets := make([]FacetCount, 0, len(counts))
+	for value, n := range counts {
+		facets = append(facets, FacetCount{Value: value, Name: names[value], Count: n})
+	}
+	sort.Slice(facets, func(i, j int) bool {
+		if facets[i].Count != facets[j].Count {
+			return facets[i].Count > facets[j].Count
+		}
+		return facets[i].Value < facets[j].Value
+	})
+	return facets
+}
@@ -0,0 +1,156 @@
+package db
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+const FolderPolicyKeyPrefix = "folderpolicy:"
+
+// FolderPolicy holds defaults for files uploaded into a client's folder and
+// its subfolders. Unset fields are inherited from the nearest parent folder
+// with a policy, and then from the instance settings.
+type FolderPolicy struct {
+	OwnerID string `json:"owner_id"`
+	Folder  string `json:"folder"`
+	// LinkExpirySeconds replaces the instance link expiry; 0 means links
+	// don't expire
+	LinkExpirySeconds *int64 `json:"link_expiry_seconds,omitempty"`
+	// RequirePassword rejects uploads that don't set a download password
+	RequirePassword *bool `json:"require_password,omitempty"`
+	// AllowedExtensions narrows the instance list; it can't widen it
+	AllowedExtensions []string `json:"allowed_extensions,omitempty"`
+	// Tags are added to uploads, together with those of parent folders
+	Tags []string `json:"tags,omitempty"`
+	// RetentionSeconds makes the folder write-once: files uploaded into it
+	// can't be renamed, moved or deleted for this long. Subfolders can only
+	// lengthen it.
+	RetentionSeconds *int64 `json:"retention_seconds,omitempty"`
+	// RequireSignature rejects uploads without a signature from a trusted
+	// key
+	RequireSignature *bool `json:"require_signature,omitempty"`
+	UpdatedAt        int64 `json:"updated_at"`
+}
+
+func folderPolicyKey(ownerID, folder string) string {
+	return FolderPolicyKeyPrefix + ownerID + ":" + folder
+}
+
+func SaveFolderPolicy(s CelerixStore, p FolderPolicy) error {
+	p = NormalizeFolderPolicy(p)
+	return s.Set(SystemPersona, AppID, folderPolicyKey(p.OwnerID, p.Folder), p)
+}
+
+// NormalizeFolderPolicy returns the policy as it is saved.
+func NormalizeFolderPolicy(p FolderPolicy) FolderPolicy {
+	p.AllowedExtensions = normalizeExtensions(p.AllowedExtensions)
+	p.Tags = ParseTags(strings.Join(p.Tags, ","))
+	return p
+}
+
+func GetFolderPolicy(s CelerixStore, ownerID, folder string) (*FolderPolicy, error) {
+	p, err := sdk.Get[FolderPolicy](s, SystemPersona, AppID, folderPolicyKey(ownerID, folder))
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func DeleteFolderPolicy(s CelerixStore, ownerID, folder string) error {
+	return s.Delete(SystemPersona, AppID, folderPolicyKey(ownerID, folder))
+}
+
+// ListFolderPolicies returns the client's folder policies ordered by folder.
+func ListFolderPolicies(s CelerixStore, ownerID string) ([]FolderPolicy, error) {
+	appStore, err := s.GetAppStore(SystemPersona, AppID)
+	if err != nil {
+		return []FolderPolicy{}, nil
+	}
+	prefix := FolderPolicyKeyPrefix + ownerID + ":"
+	policies := []FolderPolicy{}
+	for key := range appStore {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		p, err := sdk.Get[FolderPolicy](s, SystemPersona, AppID, key)
+		if err != nil {
+			continue
+		}
+		policies = append(policies, p)
+	}
+	sort.Slice(policies, func(i, j int) bool { return policies[i].Folder < policies[j].Folder })
+	return policies, nil
+}
+
+// ResolveFolderPolicy merges the policies of the folder and its parents into
+// the one that applies to uploads into folder. A subfolder's fields override
+// its parent's, except tags, which add up, and the retention, of which the
+// longest applies.
+func ResolveFolderPolicy(s CelerixStore, ownerID, folder string) FolderPolicy {
+	effective := FolderPolicy{OwnerID: ownerID, Folder: folder}
+	if folder == "" {
+		return effective
+	}
+	parts := strings.Split(folder, "/")
+	for i := range parts {
+		p, err := GetFolderPolicy(s, ownerID, strings.Join(parts[:i+1], "/"))
+		if err != nil {
+			continue
+		}
+		if p.LinkExpirySeconds != nil {
+			effective.LinkExpirySeconds = p.LinkExpirySeconds
+		}
+		if p.RequirePassword != nil {
+			effective.RequirePassword = p.RequirePassword
+		}
+		if p.RequireSignature != nil {
+			effective.RequireSignature = p.RequireSignature
+		}
+		if len(p.AllowedExtensions) > 0 {
+			effective.AllowedExtensions = p.AllowedExtensions
+		}
+		effective.Tags = append(effective.Tags, p.Tags...)
+		if p.RetentionSeconds != nil && (effective.RetentionSeconds == nil || *p.RetentionSeconds > *effective.RetentionSeconds) {
+			effective.RetentionSeconds = p.RetentionSeconds
+		}
+	}
+	effective.Tags = ParseTags(strings.Join(effective.Tags, ","))
+	return effective
+}
+
+// PasswordRequired reports whether uploads must set a download password.
+func (p *FolderPolicy) PasswordRequired() bool {
+	return p.RequirePassword != nil && *p.RequirePassword
+}
+
+// SignatureRequired reports whether uploads must be signed by a trusted key.
+func (p *FolderPolicy) SignatureRequired() bool {
+	return p.RequireSignature != nil && *p.RequireSignature
+}
+
+// Retention is how long files uploaded into the folder are locked, or zero
+// when the folder isn't write-once.
+func (p *FolderPolicy) Retention() int64 {
+	if p.RetentionSeconds == nil {
+		return 0
+	}
+	return *p.RetentionSeconds
+}
+
+// AllowsName reports whether the file name passes the folder's allowed
+// extensions list.
+func (p *FolderPolicy) AllowsName(name string) bool {
+	if len(p.AllowedExtensions) == 0 {
+		return true
+	}
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, allowed := range p.AllowedExtensions {
+		if ext == allowed {
+			return true
+		}
+	}
+	return false
+}
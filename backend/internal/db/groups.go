@@ -0,0 +1,105 @@
+package db
+
+import (
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+const GroupKeyPrefix = "clientgroup:"
+
+// ClientGroup is a team of clients sharing one storage pool. A client is
+// in at most one group. The group's ID is also an owner: files transferred
+// to it belong to the team and count towards the pool like the members'
+// own files.
+type ClientGroup struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Members []string `json:"members"`
+	// QuotaBytes caps what the members and the group store together; zero
+	// means only the depot's quota applies
+	QuotaBytes int64 `json:"quota_bytes"`
+	UpdatedAt  int64 `json:"updated_at"`
+}
+
+func SaveGroup(s CelerixStore, g ClientGroup) error {
+	slices.Sort(g.Members)
+	g.Members = slices.Compact(g.Members)
+	defer forgetOwnerName(s, g.ID)
+	return s.Set(SystemPersona, AppID, GroupKeyPrefix+g.ID, g)
+}
+
+func GetGroup(s CelerixStore, id string) (*ClientGroup, error) {
+	g, err := sdk.Get[ClientGroup](s, SystemPersona, AppID, GroupKeyPrefix+id)
+	if err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+func DeleteGroup(s CelerixStore, id string) error {
+	defer forgetOwnerName(s, id)
+	return s.Delete(SystemPersona, AppID, GroupKeyPrefix+id)
+}
+
+// ListGroups returns the client groups ordered by ID.
+func ListGroups(s CelerixStore) ([]ClientGroup, error) {
+	appStore, err := s.GetAppStore(SystemPersona, AppID)
+	if err != nil {
+		return []ClientGroup{}, nil
+	}
+	groups := []ClientGroup{}
+	for key := range appStore {
+		if !strings.HasPrefix(key, GroupKeyPrefix) {
+			continue
+		}
+		g, err := sdk.Get[ClientGroup](s, SystemPersona, AppID, key)
+		if err != nil {
+			continue
+		}
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].ID < groups[j].ID })
+	return groups, nil
+}
+
+// GroupOf returns the group the client is a member of, or nil.
+func GroupOf(s CelerixStore, clientID string) *ClientGroup {
+	groups, _ := ListGroups(s)
+	for _, g := range groups {
+		if slices.Contains(g.Members, clientID) {
+			return &g
+		}
+	}
+	return nil
+}
+
+// RemoveGroupMember takes the client out of their group, if any.
+func RemoveGroupMember(s CelerixStore, clientID string) error {
+	g := GroupOf(s, clientID)
+	if g == nil {
+		return nil
+	}
+	g.Members = slices.DeleteFunc(g.Members, func(m string) bool { return m == clientID })
+	return SaveGroup(s, *g)
+}
+
+// GroupUsage adds up the size of the files the group and its members own,
+// and how many there are.
+func GroupUsage(s CelerixStore, g ClientGroup) (int64, int) {
+	var bytes int64
+	files := 0
+	for _, owner := range append([]string{g.ID}, g.Members...) {
+		records, err := ListOwnerFiles(s, owner)
+		if err != nil {
+			continue
+		}
+		for _, r := range records {
+			bytes += r.Size
+			files++
+		}
+	}
+	return bytes, files
+}
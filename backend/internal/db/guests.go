@@ -0,0 +1,106 @@
+package db
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+const GuestTokenKeyPrefix = "guest:"
+
+// Guest token access.
+const (
+	GuestRead   = "read"
+	GuestUpload = "upload"
+)
+
+// GuestToken lets someone without a persona use part of a client's space
+// until it expires: read the listed files or a folder, or upload into a
+// folder. The ID is the token itself.
+type GuestToken struct {
+	ID      string `json:"id"`
+	OwnerID string `json:"owner_id"`
+	// Name says who the token was given to
+	Name   string `json:"name"`
+	Access string `json:"access"`
+	// FileIDs or Folder is the scope; exactly one is set
+	FileIDs   []string `json:"file_ids,omitempty"`
+	Folder    string   `json:"folder,omitempty"`
+	ExpiresAt int64    `json:"expires_at"`
+	CreatedAt int64    `json:"created_at"`
+}
+
+// Covers reports whether the file is within the token's scope.
+func (g *GuestToken) Covers(record *FileRecord) bool {
+	if record.OwnerID != g.OwnerID {
+		return false
+	}
+	if g.Folder != "" {
+		return record.InFolder(g.Folder)
+	}
+	for _, id := range g.FileIDs {
+		if id == record.ID {
+			return true
+		}
+	}
+	return false
+}
+
+func GetGuestToken(s CelerixStore, id string) (*GuestToken, error) {
+	g, err := sdk.Get[GuestToken](s, SystemPersona, AppID, GuestTokenKeyPrefix+id)
+	if err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+func SaveGuestToken(s CelerixStore, g GuestToken) error {
+	return s.Set(SystemPersona, AppID, GuestTokenKeyPrefix+g.ID, g)
+}
+
+func DeleteGuestToken(s CelerixStore, id string) error {
+	return s.Delete(SystemPersona, AppID, GuestTokenKeyPrefix+id)
+}
+
+// ListGuestTokens returns the tokens of one owner, or all of them when
+// ownerID is empty, newest first.
+func ListGuestTokens(s CelerixStore, ownerID string) ([]GuestToken, error) {
+	appStore, err := s.GetAppStore(SystemPersona, AppID)
+	if err != nil {
+		return []GuestToken{}, nil
+	}
+	tokens := []GuestToken{}
+	for key := range appStore {
+		if !strings.HasPrefix(key, GuestTokenKeyPrefix) {
+			continue
+		}
+		g, err := sdk.Get[GuestToken](s, SystemPersona, AppID, key)
+		if err != nil || (ownerID != "" && g.OwnerID != ownerID) {
+			continue
+		}
+		tokens = append(tokens, g)
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].CreatedAt > tokens[j].CreatedAt })
+	return tokens, nil
+}
+
+// PruneGuestTokens removes the tokens that expired before the cutoff.
+func PruneGuestTokens(s CelerixStore, before time.Time) (int, error) {
+	tokens, err := ListGuestTokens(s, "")
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, g := range tokens {
+		if g.ExpiresAt >= before.Unix() {
+			continue
+		}
+		if err := DeleteGuestToken(s, g.ID); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
@@ -0,0 +1,65 @@
+package db
+
+import (
+	"strings"
+	"time"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+// IdempotencyKeyPrefix is followed by <clientID>:<Idempotency-Key>.
+const IdempotencyKeyPrefix = "idem:"
+
+// IdempotencyRecord is the response to a request sent with an
+// Idempotency-Key, replayed when the client retries it.
+type IdempotencyRecord struct {
+	ClientID string `json:"client_id"`
+	Key      string `json:"key"`
+	// Request is "METHOD /path <sha256>" of the request the key was first
+	// used for, the SHA-256 covering its query and body
+	Request     string `json:"request"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+	CreatedAt   int64  `json:"created_at"`
+}
+
+func idempotencyKey(clientID, key string) string {
+	return IdempotencyKeyPrefix + clientID + ":" + key
+}
+
+func GetIdempotency(s CelerixStore, clientID, key string) (*IdempotencyRecord, error) {
+	r, err := sdk.Get[IdempotencyRecord](s, SystemPersona, AppID, idempotencyKey(clientID, key))
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func SaveIdempotency(s CelerixStore, r IdempotencyRecord) error {
+	return s.Set(SystemPersona, AppID, idempotencyKey(r.ClientID, r.Key), r)
+}
+
+// PruneIdempotency forgets the responses recorded before the cutoff, after
+// which their keys can be used again.
+func PruneIdempotency(s CelerixStore, before time.Time) (int, error) {
+	appStore, err := s.GetAppStore(SystemPersona, AppID)
+	if err != nil {
+		return 0, nil
+	}
+	removed := 0
+	for key := range appStore {
+		if !strings.HasPrefix(key, IdempotencyKeyPrefix) {
+			continue
+		}
+		r, err := sdk.Get[IdempotencyRecord](s, SystemPersona, AppID, key)
+		if err == nil && r.CreatedAt >= before.Unix() {
+			continue
+		}
+		if err := s.Delete(SystemPersona, AppID, key); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
@@ -0,0 +1,73 @@
+package db
+
+import (
+	"strings"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+const (
+	ChatKeyPrefix = "chat:"
+	// chatGlobalKey holds the admin's instance-wide integration
+	chatGlobalKey = ChatKeyPrefix + "*"
+)
+
+// DefaultAnnounceTag triggers an announcement when no tags are configured.
+const DefaultAnnounceTag = "announce"
+
+// ChatIntegration posts share links to a Slack or Mattermost incoming
+// webhook for uploads that land in one of Folders or carry one of Tags.
+type ChatIntegration struct {
+	OwnerID    string   `json:"owner_id,omitempty"`
+	Enabled    bool     `json:"enabled"`
+	WebhookURL string   `json:"webhook_url"`
+	Folders    []string `json:"folders"`
+	Tags       []string `json:"tags"`
+}
+
+// Matches reports whether an upload should be announced.
+func (ci *ChatIntegration) Matches(record *FileRecord) bool {
+	if !ci.Enabled || ci.WebhookURL == "" {
+		return false
+	}
+	tags := ci.Tags
+	if len(tags) == 0 {
+		tags = []string{DefaultAnnounceTag}
+	}
+	for _, t := range tags {
+		if record.HasTag(t) {
+			return true
+		}
+	}
+	for _, f := range ci.Folders {
+		if f != "" && record.InFolder(f) {
+			return true
+		}
+	}
+	return false
+}
+
+func chatKey(ownerID string) string {
+	if ownerID == "" {
+		return chatGlobalKey
+	}
+	return ChatKeyPrefix + ownerID
+}
+
+// GetChatIntegration returns the client's integration, or the instance-wide
+// one for an empty ownerID.
+func GetChatIntegration(s CelerixStore, ownerID string) (*ChatIntegration, error) {
+	ci, err := sdk.Get[ChatIntegration](s, SystemPersona, AppID, chatKey(ownerID))
+	if err != nil {
+		return nil, err
+	}
+	return &ci, nil
+}
+
+func SaveChatIntegration(s CelerixStore, ci ChatIntegration) error {
+	for i, f := range ci.Folders {
+		ci.Folders[i] = NormalizeFolder(f)
+	}
+	ci.Tags = ParseTags(strings.Join(ci.Tags, ","))
+	return s.Set(SystemPersona, AppID, chatKey(ci.OwnerID), ci)
+}
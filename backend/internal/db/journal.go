@@ -0,0 +1,156 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+const (
+	ChangeKeyPrefix = "change:"
+	// changeSeqKey holds the last sequence number handed out
+	changeSeqKey = "changeseq"
+	// changeFloorKey holds the first sequence number still in the journal
+	changeFloorKey = "changefloor"
+)
+
+const (
+	ChangeCreate = "create"
+	ChangeUpdate = "update"
+	ChangeDelete = "delete"
+)
+
+// ChangeEntry records one change of a file record. It keeps enough of the
+// file's state to act on a delete without the record.
+type ChangeEntry struct {
+	Seq     int64  `json:"seq"`
+	Time    int64  `json:"time"`
+	Op      string `json:"op"`
+	FileID  string `json:"file_id"`
+	OwnerID string `json:"owner_id"`
+	// PrevOwnerID is set when the change moved the file to another owner
+	PrevOwnerID string `json:"prev_owner_id,omitempty"`
+	Name        string `json:"name"`
+	Folder      string `json:"folder,omitempty"`
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256"`
+}
+
+// journalMu hands out sequence numbers in order.
+var journalMu = sharedMutex{name: "journal"}
+
+func changeKey(seq int64) string {
+	return fmt.Sprintf("%s%020d", ChangeKeyPrefix, seq)
+}
+
+// appendChange writes a journal entry for the record. Journal failures are
+// not fatal to the change itself, which has already been stored.
+func appendChange(s CelerixStore, op string, record FileRecord, prevOwner string) {
+	journalMu.Lock(s)
+	defer journalMu.Unlock()
+
+	seq, _ := sdk.Get[int64](s, SystemPersona, AppID, changeSeqKey)
+	seq++
+	if err := s.Set(SystemPersona, AppID, changeSeqKey, seq); err != nil {
+		return
+	}
+	entry := ChangeEntry{
+		Seq:     seq,
+		Time:    time.Now().Unix(),
+		Op:      op,
+		FileID:  record.ID,
+		OwnerID: record.OwnerID,
+		Name:    record.OriginalName,
+		Folder:  record.Folder,
+		Size:    record.Size,
+		SHA256:  record.SHA256,
+	}
+	if prevOwner != record.OwnerID {
+		entry.PrevOwnerID = prevOwner
+	}
+	_ = s.Set(SystemPersona, AppID, changeKey(seq), entry)
+}
+
+// ChangeCursor returns the newest sequence number, and the oldest one still
+// in the journal. Cursors older than the floor have missed pruned changes.
+func ChangeCursor(s CelerixStore) (head, floor int64) {
+	// Hold the lock so head never points at an entry still being written
+	journalMu.Lock(s)
+	defer journalMu.Unlock()
+	head, _ = sdk.Get[int64](s, SystemPersona, AppID, changeSeqKey)
+	floor, _ = sdk.Get[int64](s, SystemPersona, AppID, changeFloorKey)
+	return head, floor
+}
+
+// ListChanges returns up to limit journal entries after the cursor that
+// concern the owner, in order. An empty ownerID returns all entries.
+func ListChanges(s CelerixStore, ownerID string, since int64, limit int) ([]ChangeEntry, error) {
+	appStore, err := s.GetAppStore(SystemPersona, AppID)
+	if err != nil {
+		return []ChangeEntry{}, nil
+	}
+	after := changeKey(since)
+	var keys []string
+	for key := range appStore {
+		if strings.HasPrefix(key, ChangeKeyPrefix) && key > after {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	changes := []ChangeEntry{}
+	for _, key := range keys {
+		entry, err := sdk.Get[ChangeEntry](s, SystemPersona, AppID, key)
+		if err != nil {
+			continue
+		}
+		if ownerID != "" && entry.OwnerID != ownerID && entry.PrevOwnerID != ownerID {
+			continue
+		}
+		changes = append(changes, entry)
+		if limit > 0 && len(changes) == limit {
+			break
+		}
+	}
+	return changes, nil
+}
+
+// PruneChanges drops journal entries older than before and returns how many
+// were removed.
+func PruneChanges(s CelerixStore, before time.Time) (int, error) {
+	journalMu.Lock(s)
+	defer journalMu.Unlock()
+
+	appStore, err := s.GetAppStore(SystemPersona, AppID)
+	if err != nil {
+		return 0, nil
+	}
+	var keys []string
+	for key := range appStore {
+		if strings.HasPrefix(key, ChangeKeyPrefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	removed := 0
+	for _, key := range keys {
+		entry, getErr := sdk.Get[ChangeEntry](s, SystemPersona, AppID, key)
+		if getErr == nil && entry.Time >= before.Unix() {
+			break
+		}
+		if err := s.Delete(SystemPersona, AppID, key); err != nil {
+			return removed, err
+		}
+		if getErr == nil {
+			if err := s.Set(SystemPersona, AppID, changeFloorKey, entry.Seq+1); err != nil {
+				return removed, err
+			}
+		}
+		removed++
+	}
+	return removed, nil
+}
@@ -0,0 +1,76 @@
+package db
+
+// limitMu serializes updates of download counters so concurrent downloads
+// can't both pass a cap or lose an increment. Every write of a file record
+// takes it too, see keepCounters.
+var limitMu = sharedMutex{name: "limits"}
+
+// ChargeDownload counts n bytes served from the file's link, and one
+// download when countDownload is set. It reports false without counting
+// anything when that would exceed one of the link's caps.
+func ChargeDownload(s CelerixStore, id string, n int64, countDownload bool) (bool, error) {
+	limitMu.Lock(s)
+	defer limitMu.Unlock()
+
+	record, err := GetFileRecord(s, id)
+	if err != nil {
+		return false, err
+	}
+	if record.LimitReached() ||
+		(countDownload && record.MaxDownloads > 0 && record.Downloads >= record.MaxDownloads) ||
+		(record.MaxBytes > 0 && record.BytesServed+n > record.MaxBytes) {
+		return false, nil
+	}
+	if countDownload {
+		record.Downloads++
+	}
+	record.BytesServed += n
+	// Counting is not worth a journal entry
+	return true, putFileRecord(s, *record)
+}
+
+// SetFileLimits changes a link's caps; zero means unlimited. Resetting
+// clears the counters, re-enabling a link that ran out.
+func SetFileLimits(s CelerixStore, id string, maxDownloads, maxBytes int64, reset bool) error {
+	limitMu.Lock(s)
+	record, err := GetFileRecord(s, id)
+	if err != nil {
+		limitMu.Unlock()
+		return err
+	}
+	record.MaxDownloads = maxDownloads
+	record.MaxBytes = maxBytes
+	if reset {
+		record.Downloads = 0
+		record.BytesServed = 0
+	}
+	err = putFileRecord(s, *record)
+	limitMu.Unlock()
+	if err != nil {
+		return err
+	}
+	appendChange(s, ChangeUpdate, *record, record.OwnerID)
+	return nil
+}
+
+// SetFileRestrictions changes where the link can be used from.
+func SetFileRestrictions(s CelerixStore, id string, networks, countries []string) error {
+	record, err := GetFileRecord(s, id)
+	if err != nil {
+		return err
+	}
+	record.AllowedNetworks = networks
+	record.AllowedCountries = countries
+	return SaveFileRecord(s, *record)
+}
+
+// Restricted reports whether the link can only be used from some places.
+func (r *FileRecord) Restricted() bool {
+	return len(r.AllowedNetworks) > 0 || len(r.AllowedCountries) > 0
+}
+
+// LimitReached reports whether the link has used up one of its caps.
+func (r *FileRecord) LimitReached() bool {
+	return (r.MaxDownloads > 0 && r.Downloads >= r.MaxDownloads) ||
+		(r.MaxBytes > 0 && r.BytesServed >= r.MaxBytes)
+}
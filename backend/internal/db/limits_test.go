@@ -0,0 +1,39 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/celerix/depot/internal/memstore"
+)
+
+// Writers that read the record before a download was counted don't undo
+// the count when they save it.
+func TestRecordWritesKeepCounters(t *testing.T) {
+	s := memstore.New()
+	UpsertClient(s, "alice", "Alice", "CODE", 0)
+	SaveFileRecord(s, FileRecord{ID: "f1", OwnerID: "alice", Size: 10, MaxDownloads: 5})
+
+	stale, _ := GetFileRecord(s, "f1")
+	if ok, err := ChargeDownload(s, "f1", 10, true); !ok || err != nil {
+		t.Fatalf("expected the download to be counted, got %v %v", ok, err)
+	}
+	stale.Tags = []string{"report"}
+	if err := SaveFileRecord(s, *stale); err != nil {
+		t.Fatal(err)
+	}
+	UpsertClient(s, "bob", "Bob", "CODE2", 0)
+	if _, err := TransferFile(s, "f1", "bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	record, _ := GetFileRecord(s, "f1")
+	if record.Downloads != 1 || record.BytesServed != 10 || len(record.Tags) != 1 {
+		t.Errorf("expected the counters and the tags, got %+v", record)
+	}
+
+	// Resetting the limits is how counters go back to zero
+	SetFileLimits(s, "f1", 5, 0, true)
+	if record, _ := GetFileRecord(s, "f1"); record.Downloads != 0 || record.BytesServed != 0 {
+		t.Errorf("expected the counters reset, got %d and %d", record.Downloads, record.BytesServed)
+	}
+}
@@ -0,0 +1,105 @@
+package db
+
+import (
+	"errors"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+// LinkKeyPrefix is followed by a download link, and names the file that
+// has it, so links are found without reading every file record.
+const LinkKeyPrefix = "link:"
+
+// LinksIndexedKey is set once the files stored before links were indexed
+// have been added to the index.
+const LinksIndexedKey = "links-indexed"
+
+var errUnknownLink = errors.New("no file has this download link")
+
+type linkRecord struct {
+	FileID string `json:"file_id"`
+}
+
+// linkIndexMu keeps replicas from indexing the old files at once.
+var linkIndexMu = sharedMutex{name: "linkindex"}
+
+// reindexLink points the download link of record at it, and forgets the
+// link it had before. previous is the stored record, nil for a new file.
+func reindexLink(s CelerixStore, record FileRecord, previous *FileRecord) error {
+	if previous != nil && previous.DownloadLink == record.DownloadLink {
+		return nil
+	}
+	if previous != nil {
+		if err := unindexLink(s, previous.DownloadLink, record.ID); err != nil {
+			return err
+		}
+	}
+	if record.DownloadLink == "" {
+		return nil
+	}
+	return s.Set(SystemPersona, AppID, LinkKeyPrefix+record.DownloadLink, linkRecord{FileID: record.ID})
+}
+
+// unindexLink forgets link if it still names the file.
+func unindexLink(s CelerixStore, link, id string) error {
+	if link == "" {
+		return nil
+	}
+	r, err := sdk.Get[linkRecord](s, SystemPersona, AppID, LinkKeyPrefix+link)
+	if err != nil || r.FileID != id {
+		return nil
+	}
+	return s.Delete(SystemPersona, AppID, LinkKeyPrefix+link)
+}
+
+// GetFileRecordByLink returns the file whose download link is link.
+func GetFileRecordByLink(s CelerixStore, link string) (*FileRecord, error) {
+	if err := indexLinks(s); err != nil {
+		return nil, err
+	}
+	r, err := sdk.Get[linkRecord](s, SystemPersona, AppID, LinkKeyPrefix+link)
+	if err != nil {
+		return nil, err
+	}
+	record, err := GetFileRecord(s, r.FileID)
+	if err != nil {
+		return nil, err
+	}
+	// Indexing the old files may race with a link being rotated, and
+	// leave the old link in the index
+	if record.DownloadLink != link {
+		return nil, errUnknownLink
+	}
+	return record, nil
+}
+
+// indexLinks adds the files stored before links were indexed to the
+// index, the first time a store is asked for a link.
+func indexLinks(s CelerixStore) error {
+	if _, err := s.Get(SystemPersona, AppID, LinksIndexedKey); err == nil {
+		return nil
+	}
+	linkIndexMu.Lock(s)
+	defer linkIndexMu.Unlock()
+	if _, err := s.Get(SystemPersona, AppID, LinksIndexedKey); err == nil {
+		return nil
+	}
+
+	records, err := GetAllFileRecords(s)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		if record.DownloadLink == "" {
+			continue
+		}
+		// Files saved since are indexed already
+		if _, err := s.Get(SystemPersona, AppID, LinkKeyPrefix+record.DownloadLink); err == nil {
+			continue
+		}
+		if err := s.Set(SystemPersona, AppID, LinkKeyPrefix+record.DownloadLink, linkRecord{FileID: record.ID}); err != nil {
+			return err
+		}
+	}
+	return s.Set(SystemPersona, AppID, LinksIndexedKey, true)
+}
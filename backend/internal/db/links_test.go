@@ -0,0 +1,39 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/celerix/depot/internal/memstore"
+)
+
+func TestFileRecordByLink(t *testing.T) {
+	s := memstore.New()
+	UpsertClient(s, "alice", "Alice", "CODE", 0)
+	// A file from before links were indexed
+	s.Set("alice", AppID, FileKeyPrefix+"old", FileRecord{ID: "old", OwnerID: "alice", DownloadLink: "link-old"})
+	SaveFileRecord(s, FileRecord{ID: "f1", OwnerID: "alice", DownloadLink: "link-1"})
+
+	for link, want := range map[string]string{"link-old": "old", "link-1": "f1"} {
+		if record, err := GetFileRecordByLink(s, link); err != nil || record.ID != want {
+			t.Errorf("expected %s for %s, got %+v %v", want, link, record, err)
+		}
+	}
+
+	if _, err := RotateDownloadLink(s, "f1", "link-2", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetFileRecordByLink(s, "link-1"); err == nil {
+		t.Error("the rotated link still finds the file")
+	}
+	if record, err := GetFileRecordByLink(s, "link-2"); err != nil || record.ID != "f1" {
+		t.Errorf("expected the new link to find the file, got %+v %v", record, err)
+	}
+
+	DeleteFileRecord(s, "f1")
+	if _, err := GetFileRecordByLink(s, "link-2"); err == nil {
+		t.Error("the link of a deleted file still finds it")
+	}
+	if _, err := s.Get(SystemPersona, AppID, LinkKeyPrefix+"link-2"); err == nil {
+		t.Error("the link of a deleted file stayed in the index")
+	}
+}
@@ -0,0 +1,177 @@
+package db
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+	"github.com/google/uuid"
+)
+
+const LockKeyPrefix = "lock:"
+
+const (
+	// lockTTL bounds how long a replica that died holding a record lock
+	// keeps the others waiting
+	lockTTL = time.Minute
+	// lockWait is how long to wait for a record lock before logging that
+	// it is still held
+	lockWait = 30 * time.Second
+	// defaultLockSettle is how long a StoreLocker waits before reading a
+	// lease back
+	defaultLockSettle = 50 * time.Millisecond
+)
+
+// Locker hands out named leases that every replica sharing the store
+// respects. Without one, locks only hold within the process, which is all
+// a single server needs.
+type Locker interface {
+	// TryLock takes the named lease for at most ttl, and reports false if
+	// someone else holds it.
+	TryLock(name string, ttl time.Duration) (unlock func(), ok bool, err error)
+}
+
+// SharedStore is a store that several replicas share, with the Locker
+// their leases go through and the Cache of their hot lookups. The
+// package's locks and TryLock hold across replicas for records of a
+// SharedStore; those of any other store lock within the process only.
+// Each server wraps its own store, so servers embedded in one process
+// don't share their locks or caches.
+type SharedStore struct {
+	CelerixStore
+	Locker Locker
+	Cache  Cache
+}
+
+// ShareWith gives s the Locker and Cache of base, if base is a
+// SharedStore, for stores that are views of it.
+func ShareWith(s, base CelerixStore) CelerixStore {
+	if shared, ok := base.(*SharedStore); ok {
+		copied := *shared
+		copied.CelerixStore = s
+		return &copied
+	}
+	return s
+}
+
+func lockerOf(s CelerixStore) Locker {
+	if shared, ok := s.(*SharedStore); ok {
+		return shared.Locker
+	}
+	return nil
+}
+
+// TryLock takes a named lease through the Locker of s. Without one it
+// always succeeds, leaving mutual exclusion within the process to the
+// caller.
+func TryLock(s CelerixStore, name string, ttl time.Duration) (unlock func(), ok bool, err error) {
+	l := lockerOf(s)
+	if l == nil {
+		return func() {}, true, nil
+	}
+	return l.TryLock(name, ttl)
+}
+
+// sharedMutex guards read-modify-write updates of store records. It is a
+// plain mutex on a single server, and also takes the lease of the same
+// name from the Locker of a SharedStore, so replicas don't lose each
+// other's updates.
+type sharedMutex struct {
+	name   string
+	mu     sync.Mutex
+	unlock func()
+}
+
+// Lock locks for updates of records in s.
+func (m *sharedMutex) Lock(s CelerixStore) {
+	m.mu.Lock()
+	m.unlock = acquire(s, m.name)
+}
+
+func (m *sharedMutex) Unlock() {
+	unlock := m.unlock
+	m.unlock = nil
+	unlock()
+	m.mu.Unlock()
+}
+
+// acquire waits for a lease through the Locker of s, for as long as it
+// takes: going ahead without it could lose another replica's update, and
+// a replica that died holding it loses it after lockTTL. Every lockWait
+// spent waiting is logged, so a store that stays unreachable shows.
+func acquire(s CelerixStore, name string) func() {
+	l := lockerOf(s)
+	if l == nil {
+		return func() {}
+	}
+	report := time.Now().Add(lockWait)
+	for backoff := 5 * time.Millisecond; ; backoff = min(backoff*2, time.Second) {
+		unlock, ok, err := l.TryLock(name, lockTTL)
+		if ok {
+			return unlock
+		}
+		if time.Now().After(report) {
+			log.Printf("[WARN] Still waiting for lock %s (last error: %v)", name, err)
+			report = time.Now().Add(lockWait)
+		}
+		time.Sleep(backoff)
+	}
+}
+
+// lease is a held lock as a StoreLocker keeps it.
+type lease struct {
+	Holder string `json:"holder"`
+	// Expires is in Unix milliseconds
+	Expires int64 `json:"expires"`
+}
+
+// StoreLocker keeps leases in the store, for replicas that share nothing
+// else. The store has no compare-and-swap, so it follows Fischer's
+// timing-based algorithm: a free lease is written, then read back after
+// Settle, and it belongs to whoever's write landed last. That holds as
+// long as a store write lands within Settle of the read before it.
+// Releasing is a compare-and-delete on the same terms, see TryLock.
+type StoreLocker struct {
+	Store CelerixStore
+	// Settle is 50ms when zero
+	Settle time.Duration
+}
+
+func NewStoreLocker(s CelerixStore) *StoreLocker {
+	return &StoreLocker{Store: s}
+}
+
+func (l *StoreLocker) TryLock(name string, ttl time.Duration) (func(), bool, error) {
+	key := LockKeyPrefix + name
+	if held, err := sdk.Get[lease](l.Store, SystemPersona, AppID, key); err == nil && held.Expires > time.Now().UnixMilli() {
+		return nil, false, nil
+	}
+	token := uuid.New().String()
+	if err := l.Store.Set(SystemPersona, AppID, key, lease{Holder: token, Expires: time.Now().Add(ttl).UnixMilli()}); err != nil {
+		return nil, false, err
+	}
+
+	settle := l.Settle
+	if settle <= 0 {
+		settle = defaultLockSettle
+	}
+	time.Sleep(settle)
+	held, err := sdk.Get[lease](l.Store, SystemPersona, AppID, key)
+	if err != nil || held.Holder != token {
+		return nil, false, err
+	}
+	// Others only write the lease once it has expired, so it is deleted
+	// only while it is still ours with more than Settle to go: the delete
+	// then lands before anyone can take it over. A lease closer to its end
+	// is left to expire.
+	return func() {
+		held, err := sdk.Get[lease](l.Store, SystemPersona, AppID, key)
+		if err != nil || held.Holder != token || held.Expires <= time.Now().Add(settle).UnixMilli() {
+			return
+		}
+		if err := l.Store.Delete(SystemPersona, AppID, key); err != nil {
+			log.Printf("[ERROR] Failed to release lock %s: %v", name, err)
+		}
+	}, true, nil
+}
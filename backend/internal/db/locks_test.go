@@ -0,0 +1,58 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+	"github.com/celerix/depot/internal/memstore"
+)
+
+// Waiting for a held lock ends when it is released, never by going ahead
+// without it.
+func TestAcquireWaitsForTheLease(t *testing.T) {
+	base := memstore.New()
+	s := &SharedStore{CelerixStore: base, Locker: &StoreLocker{Store: base, Settle: time.Millisecond}}
+
+	unlock, ok, err := TryLock(s, "records", time.Minute)
+	if !ok || err != nil {
+		t.Fatalf("expected the lease, got %v %v", ok, err)
+	}
+	acquired := make(chan func())
+	go func() { acquired <- acquire(s, "records") }()
+	select {
+	case <-acquired:
+		t.Fatal("acquired a lease that is still held")
+	case <-time.After(100 * time.Millisecond):
+	}
+	unlock()
+	select {
+	case release := <-acquired:
+		release()
+	case <-time.After(5 * time.Second):
+		t.Fatal("the released lease was not acquired")
+	}
+}
+
+// Releasing a lease that ran out doesn't delete the one someone else took
+// over.
+func TestStoreLockerReleasesOnlyItsLease(t *testing.T) {
+	s := memstore.New()
+	l := &StoreLocker{Store: s, Settle: time.Millisecond}
+
+	unlock, ok, _ := l.TryLock("records", time.Millisecond)
+	if !ok {
+		t.Fatal("expected the lease")
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, ok, _ := l.TryLock("records", time.Minute); !ok {
+		t.Fatal("expected the expired lease to be taken over")
+	}
+	unlock()
+	if _, err := sdk.Get[lease](s, SystemPersona, AppID, LockKeyPrefix+"records"); err != nil {
+		t.Error("the old holder released the new holder's lease")
+	}
+	if _, ok, _ := l.TryLock("records", time.Minute); ok {
+		t.Error("the new holder's lease was free to take")
+	}
+}
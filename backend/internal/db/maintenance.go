@@ -0,0 +1,32 @@
+package db
+
+import "github.com/celerix-dev/celerix-store/pkg/sdk"
+
+const MaintenanceKey = "maintenance"
+
+const (
+	MaintenanceOff      = "off"
+	MaintenanceReadOnly = "read_only"
+	MaintenanceClosed   = "closed"
+)
+
+// Maintenance is the server's maintenance mode. It is kept in the store so
+// it survives restarts and is shared with gateways.
+type Maintenance struct {
+	Mode    string `json:"mode"`
+	Message string `json:"message,omitempty"`
+	Since   int64  `json:"since,omitempty"`
+	By      string `json:"by,omitempty"`
+}
+
+func GetMaintenance(s CelerixStore) Maintenance {
+	m, err := sdk.Get[Maintenance](s, SystemPersona, AppID, MaintenanceKey)
+	if err != nil || m.Mode == "" {
+		return Maintenance{Mode: MaintenanceOff}
+	}
+	return m
+}
+
+func SaveMaintenance(s CelerixStore, m Maintenance) error {
+	return s.Set(SystemPersona, AppID, MaintenanceKey, m)
+}
@@ -0,0 +1,120 @@
+package db
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+const (
+	mirrorKey             = "mirror"
+	MirrorStatusKeyPrefix = "mirrorstatus:"
+)
+
+const (
+	MirrorPending  = "pending"
+	MirrorMirrored = "mirrored"
+	MirrorFailed   = "failed"
+)
+
+// MirrorTarget is another depot that uploads landing in one of Folders or
+// carrying one of Tags are copied to, for off-site copies. APIKey is the
+// client ID this depot uses on the remote.
+type MirrorTarget struct {
+	Enabled   bool     `json:"enabled"`
+	URL       string   `json:"url"`
+	APIKey    string   `json:"api_key"`
+	Folders   []string `json:"folders"`
+	Tags      []string `json:"tags"`
+	UpdatedAt int64    `json:"updated_at"`
+}
+
+// Matches reports whether an upload should be mirrored. Unlike chat
+// announcements there is no default: a target without folders and tags
+// mirrors nothing.
+func (m *MirrorTarget) Matches(record *FileRecord) bool {
+	if !m.Enabled || m.URL == "" || record.IsLink() {
+		return false
+	}
+	for _, t := range m.Tags {
+		if record.HasTag(t) {
+			return true
+		}
+	}
+	for _, f := range m.Folders {
+		if f != "" && record.InFolder(f) {
+			return true
+		}
+	}
+	return false
+}
+
+func GetMirrorTarget(s CelerixStore) (*MirrorTarget, error) {
+	m, err := sdk.Get[MirrorTarget](s, SystemPersona, AppID, mirrorKey)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func SaveMirrorTarget(s CelerixStore, m MirrorTarget) error {
+	for i, f := range m.Folders {
+		m.Folders[i] = NormalizeFolder(f)
+	}
+	m.Tags = ParseTags(strings.Join(m.Tags, ","))
+	return s.Set(SystemPersona, AppID, mirrorKey, m)
+}
+
+// MirrorStatus tracks the copy of one file on the mirror.
+type MirrorStatus struct {
+	FileID   string `json:"file_id"`
+	FileName string `json:"file_name"`
+	State    string `json:"state"`
+	Attempts int    `json:"attempts"`
+	// Error is the reason the last attempt failed
+	Error string `json:"error,omitempty"`
+	// RemoteID and RemoteLink identify the copy on the mirror
+	RemoteID   string `json:"remote_id,omitempty"`
+	RemoteLink string `json:"remote_link,omitempty"`
+	UpdatedAt  int64  `json:"updated_at"`
+	MirroredAt int64  `json:"mirrored_at,omitempty"`
+}
+
+func GetMirrorStatus(s CelerixStore, fileID string) (*MirrorStatus, error) {
+	m, err := sdk.Get[MirrorStatus](s, SystemPersona, AppID, MirrorStatusKeyPrefix+fileID)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func SaveMirrorStatus(s CelerixStore, m MirrorStatus) error {
+	return s.Set(SystemPersona, AppID, MirrorStatusKeyPrefix+m.FileID, m)
+}
+
+func DeleteMirrorStatus(s CelerixStore, fileID string) error {
+	return s.Delete(SystemPersona, AppID, MirrorStatusKeyPrefix+fileID)
+}
+
+// ListMirrorStatus returns the files with the given state, or all tracked
+// files when state is empty, most recently updated first.
+func ListMirrorStatus(s CelerixStore, state string) ([]MirrorStatus, error) {
+	appStore, err := s.GetAppStore(SystemPersona, AppID)
+	if err != nil {
+		return []MirrorStatus{}, nil
+	}
+	statuses := []MirrorStatus{}
+	for key := range appStore {
+		if !strings.HasPrefix(key, MirrorStatusKeyPrefix) {
+			continue
+		}
+		m, err := sdk.Get[MirrorStatus](s, SystemPersona, AppID, key)
+		if err != nil || state != "" && m.State != state {
+			continue
+		}
+		statuses = append(statuses, m)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].UpdatedAt > statuses[j].UpdatedAt })
+	return statuses, nil
+}
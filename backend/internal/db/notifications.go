@@ -0,0 +1,86 @@
+package db
+
+import (
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+const NotificationKeyPrefix = "notifications:"
+
+// Events a client can subscribe to.
+const (
+	EventShareDownloaded = "share.downloaded"
+	EventFileExpiring    = "file.expiring"
+	EventQuotaWarning    = "quota.warning"
+	EventLinkRotated     = "link.rotated"
+)
+
+// NotificationEvents lists the events clients can subscribe to.
+var NotificationEvents = []string{EventShareDownloaded, EventFileExpiring, EventQuotaWarning, EventLinkRotated}
+
+// NotificationPrefs says where a client wants to hear about events on
+// their files, and which events. Either channel may be empty.
+type NotificationPrefs struct {
+	OwnerID    string   `json:"owner_id"`
+	Email      string   `json:"email,omitempty"`
+	WebhookURL string   `json:"webhook_url,omitempty"`
+	Events     []string `json:"events"`
+	UpdatedAt  int64    `json:"updated_at,omitempty"`
+}
+
+// Wants reports whether the event should be delivered somewhere.
+func (p *NotificationPrefs) Wants(event string) bool {
+	return (p.Email != "" || p.WebhookURL != "") && slices.Contains(p.Events, event)
+}
+
+func GetNotificationPrefs(s CelerixStore, ownerID string) (*NotificationPrefs, error) {
+	p, err := sdk.Get[NotificationPrefs](s, SystemPersona, AppID, NotificationKeyPrefix+ownerID)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func SaveNotificationPrefs(s CelerixStore, p NotificationPrefs) error {
+	return s.Set(SystemPersona, AppID, NotificationKeyPrefix+p.OwnerID, p)
+}
+
+func DeleteNotificationPrefs(s CelerixStore, ownerID string) error {
+	return s.Delete(SystemPersona, AppID, NotificationKeyPrefix+ownerID)
+}
+
+// ListNotificationSubscribers returns the preferences of every client that
+// wants the event, ordered by client.
+func ListNotificationSubscribers(s CelerixStore, event string) ([]NotificationPrefs, error) {
+	appStore, err := s.GetAppStore(SystemPersona, AppID)
+	if err != nil {
+		return []NotificationPrefs{}, nil
+	}
+	subscribers := []NotificationPrefs{}
+	for key := range appStore {
+		if !strings.HasPrefix(key, NotificationKeyPrefix) {
+			continue
+		}
+		p, err := sdk.Get[NotificationPrefs](s, SystemPersona, AppID, key)
+		if err != nil || !p.Wants(event) {
+			continue
+		}
+		subscribers = append(subscribers, p)
+	}
+	sort.Slice(subscribers, func(i, j int) bool { return subscribers[i].OwnerID < subscribers[j].OwnerID })
+	return subscribers, nil
+}
+
+// SetFileExpiryNotified records when the owner was told the file's link is
+// about to expire, so they are told once.
+func SetFileExpiryNotified(s CelerixStore, id string, at int64) error {
+	record, err := GetFileRecord(s, id)
+	if err != nil {
+		return err
+	}
+	record.ExpiryNotifiedAt = at
+	return SaveFileRecord(s, *record)
+}
@@ -0,0 +1,28 @@
+package db
+
+// ListOrphanedFiles returns the files whose owner is no longer a client,
+// for example after the client was deleted. System files are never
+// orphaned.
+func ListOrphanedFiles(s CelerixStore) ([]FileRecord, error) {
+	files, err := GetAllFileRecords(s)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool)
+	orphans := []FileRecord{}
+	for _, f := range files {
+		if f.OwnerID == "" {
+			continue
+		}
+		exists, ok := known[f.OwnerID]
+		if !ok {
+			_, err := GetClient(s, f.OwnerID)
+			exists = err == nil
+			known[f.OwnerID] = exists
+		}
+		if !exists {
+			orphans = append(orphans, f)
+		}
+	}
+	return orphans, nil
+}
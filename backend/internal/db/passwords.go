@@ -0,0 +1,31 @@
+package db
+
+import (
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+const LinkPasswordKeyPrefix = "linkpass:"
+
+// LinkPassword protects a file's download link. It is kept apart from the
+// file record so the hash never ends up in file listings.
+type LinkPassword struct {
+	FileID       string `json:"file_id"`
+	PasswordSalt string `json:"password_salt"`
+	PasswordHash string `json:"password_hash"`
+}
+
+func SaveLinkPassword(s CelerixStore, p LinkPassword) error {
+	return s.Set(SystemPersona, AppID, LinkPasswordKeyPrefix+p.FileID, p)
+}
+
+func GetLinkPassword(s CelerixStore, fileID string) (*LinkPassword, error) {
+	p, err := sdk.Get[LinkPassword](s, SystemPersona, AppID, LinkPasswordKeyPrefix+fileID)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func DeleteLinkPassword(s CelerixStore, fileID string) error {
+	return s.Delete(SystemPersona, AppID, LinkPasswordKeyPrefix+fileID)
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+const PresetKeyPrefix = "preset:"
+
+// UploadPreset is a named set of upload settings admins define for a common
+// flow, e.g. "customer-delivery" or "scratch". Clients pick one with the
+// preset upload field instead of setting each option themselves.
+type UploadPreset struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// LinkExpirySeconds replaces the instance and folder link expiry; 0
+	// means links don't expire
+	LinkExpirySeconds *int64 `json:"link_expiry_seconds,omitempty"`
+	// RequirePassword rejects uploads that don't set a download password
+	RequirePassword bool `json:"require_password,omitempty"`
+	// Folder receives uploads that don't name a folder themselves
+	Folder string   `json:"folder,omitempty"`
+	Tags   []string `json:"tags,omitempty"`
+	// MaxUploadBytes caps uploads below the instance maximum; zero means
+	// only the instance maximum applies
+	MaxUploadBytes int64 `json:"max_upload_bytes,omitempty"`
+	UpdatedAt      int64 `json:"updated_at"`
+}
+
+func SavePreset(s CelerixStore, p UploadPreset) error {
+	p.Folder = NormalizeFolder(p.Folder)
+	p.Tags = ParseTags(strings.Join(p.Tags, ","))
+	return s.Set(SystemPersona, AppID, PresetKeyPrefix+p.Name, p)
+}
+
+func GetPreset(s CelerixStore, name string) (*UploadPreset, error) {
+	p, err := sdk.Get[UploadPreset](s, SystemPersona, AppID, PresetKeyPrefix+name)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func DeletePreset(s CelerixStore, name string) error {
+	return s.Delete(SystemPersona, AppID, PresetKeyPrefix+name)
+}
+
+// ListPresets returns the upload presets ordered by name.
+func ListPresets(s CelerixStore) ([]UploadPreset, error) {
+	appStore, err := s.GetAppStore(SystemPersona, AppID)
+	if err != nil {
+		return []UploadPreset{}, nil
+	}
+	presets := []UploadPreset{}
+	for key := range appStore {
+		if !strings.HasPrefix(key, PresetKeyPrefix) {
+			continue
+		}
+		p, err := sdk.Get[UploadPreset](s, SystemPersona, AppID, key)
+		if err != nil {
+			continue
+		}
+		presets = append(presets, p)
+	}
+	sort.Slice(presets, func(i, j int) bool { return presets[i].Name < presets[j].Name })
+	return presets, nil
+}
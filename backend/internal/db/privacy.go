@@ -0,0 +1,33 @@
+package db
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+const ipSaltKey = "ipsalt"
+
+var ipSaltMu = sharedMutex{name: "ip-salt"}
+
+// IPSalt returns the instance's secret for hashing client addresses,
+// creating it on first use. Without it, the few billion IPv4 addresses
+// could simply be hashed and looked up.
+func IPSalt(s CelerixStore) (string, error) {
+	ipSaltMu.Lock(s)
+	defer ipSaltMu.Unlock()
+
+	if salt, err := sdk.Get[string](s, SystemPersona, AppID, ipSaltKey); err == nil && salt != "" {
+		return salt, nil
+	}
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	salt := hex.EncodeToString(buf)
+	if err := s.Set(SystemPersona, AppID, ipSaltKey, salt); err != nil {
+		return "", err
+	}
+	return salt, nil
+}
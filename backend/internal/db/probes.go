@@ -0,0 +1,47 @@
+package db
+
+import (
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+const ProbeKeyPrefix = "probe:"
+
+// ProbeResult is the throughput a client last measured to the depot, in
+// bytes per second, with when each direction was measured. Zero means not
+// measured yet.
+type ProbeResult struct {
+	ClientID     string `json:"client_id"`
+	UploadBPS    int64  `json:"upload_bps"`
+	UploadedAt   int64  `json:"uploaded_at,omitempty"`
+	DownloadBPS  int64  `json:"download_bps"`
+	DownloadedAt int64  `json:"downloaded_at,omitempty"`
+}
+
+// probeMu keeps an upload and a download probe of one client from
+// overwriting each other's result.
+var probeMu = sharedMutex{name: "probes"}
+
+// GetProbeResult returns the client's last measurements, empty if they
+// never probed.
+func GetProbeResult(s CelerixStore, clientID string) ProbeResult {
+	r, err := sdk.Get[ProbeResult](s, SystemPersona, AppID, ProbeKeyPrefix+clientID)
+	if err != nil {
+		return ProbeResult{ClientID: clientID}
+	}
+	return r
+}
+
+// RecordProbe keeps a measured throughput for one direction, replacing
+// the last one.
+func RecordProbe(s CelerixStore, clientID string, upload bool, bps, at int64) (ProbeResult, error) {
+	probeMu.Lock(s)
+	defer probeMu.Unlock()
+
+	r := GetProbeResult(s, clientID)
+	if upload {
+		r.UploadBPS, r.UploadedAt = bps, at
+	} else {
+		r.DownloadBPS, r.DownloadedAt = bps, at
+	}
+	return r, s.Set(SystemPersona, AppID, ProbeKeyPrefix+clientID, r)
+}
@@ -0,0 +1,168 @@
+package db
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+const PurgeKeyPrefix = "purge:"
+
+// clientScopedRecords are the system records that belong to a client, with
+// the JSON field that names the client.
+var clientScopedRecords = []struct{ prefix, field string }{
+	{FolderPolicyKeyPrefix, "owner_id"},
+	{ChatKeyPrefix, "owner_id"},
+	{NotificationKeyPrefix, "owner_id"},
+	{FileRequestKeyPrefix, "owner_id"},
+	{SiteKeyPrefix, "owner_id"},
+	{ShortLinkKeyPrefix, "created_by"},
+	{UsageKeyPrefix, "client_id"},
+	{EmbedKeyPrefix, "owner_id"},
+	{StorageSnapshotKeyPrefix, "client_id"},
+	{IdempotencyKeyPrefix, "client_id"},
+	{ChunkSourceKeyPrefix, "owner_id"},
+	{AccessKeyPrefix, "client_id"},
+	{ProbeKeyPrefix, "client_id"},
+	{UploadSessionKeyPrefix, "owner_id"},
+	{UploadChunkKeyPrefix, "owner_id"},
+	{GuestTokenKeyPrefix, "owner_id"},
+}
+
+// PurgeReport records what purging a client removed. It is kept after the
+// client is gone as evidence of the erasure, so it holds counts only.
+type PurgeReport struct {
+	ClientID string `json:"client_id"`
+	PurgedAt int64  `json:"purged_at"`
+	PurgedBy string `json:"purged_by"`
+	Files    int    `json:"files"`
+	Bytes    int64  `json:"bytes"`
+	// BlobsDeleted is content that only the client referenced; BlobsShared
+	// is content still referenced by other files and kept
+	BlobsDeleted     int `json:"blobs_deleted"`
+	BlobsShared      int `json:"blobs_shared"`
+	AnalyticsBuckets int `json:"analytics_buckets"`
+	AuditAnonymized  int `json:"audit_anonymized"`
+	ChangesRemoved   int `json:"changes_removed"`
+	// Records counts the other removed records by kind (key prefix)
+	Records map[string]int `json:"records"`
+}
+
+// SignedPurgeReport is a report with the instance's Ed25519 signature over
+// Payload, the report's exact JSON encoding in base64.
+type SignedPurgeReport struct {
+	Report    PurgeReport `json:"report"`
+	Payload   string      `json:"payload"`
+	Signature string      `json:"signature"`
+	PublicKey string      `json:"public_key"`
+}
+
+// RemoveClientRecords deletes the system records that belong to the client
+// and reports how many of each kind went.
+func RemoveClientRecords(s CelerixStore, clientID string) (map[string]int, error) {
+	removed := map[string]int{}
+	appStore, err := s.GetAppStore(SystemPersona, AppID)
+	if err != nil {
+		return removed, nil
+	}
+	for key := range appStore {
+		for _, kind := range clientScopedRecords {
+			if !strings.HasPrefix(key, kind.prefix) {
+				continue
+			}
+			fields, err := sdk.Get[map[string]json.RawMessage](s, SystemPersona, AppID, key)
+			if err != nil {
+				break
+			}
+			var owner string
+			if json.Unmarshal(fields[kind.field], &owner) != nil || owner != clientID {
+				break
+			}
+			if err := s.Delete(SystemPersona, AppID, key); err != nil {
+				return removed, err
+			}
+			removed[strings.TrimSuffix(kind.prefix, ":")]++
+			break
+		}
+	}
+	return removed, nil
+}
+
+// AnonymizeAudit strips the client from their audit entries. The entries
+// stay, so the log still shows what happened, but not to whom.
+func AnonymizeAudit(s CelerixStore, clientID string) (int, error) {
+	appStore, err := s.GetAppStore(SystemPersona, AppID)
+	if err != nil {
+		return 0, nil
+	}
+	n := 0
+	for key := range appStore {
+		if !strings.HasPrefix(key, AuditKeyPrefix) {
+			continue
+		}
+		e, err := sdk.Get[AuditEntry](s, SystemPersona, AppID, key)
+		if err != nil || e.OwnerID != clientID {
+			continue
+		}
+		e.OwnerID, e.Name, e.Detail, e.IP = "", "", "", ""
+		if err := s.Set(SystemPersona, AppID, key, e); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// RemoveClientChanges drops the change journal entries of the client's
+// files; only the client's own sync agents read them.
+func RemoveClientChanges(s CelerixStore, clientID string) (int, error) {
+	journalMu.Lock(s)
+	defer journalMu.Unlock()
+
+	appStore, err := s.GetAppStore(SystemPersona, AppID)
+	if err != nil {
+		return 0, nil
+	}
+	n := 0
+	for key := range appStore {
+		if !strings.HasPrefix(key, ChangeKeyPrefix) {
+			continue
+		}
+		e, err := sdk.Get[ChangeEntry](s, SystemPersona, AppID, key)
+		if err != nil || (e.OwnerID != clientID && e.PrevOwnerID != clientID) {
+			continue
+		}
+		if err := s.Delete(SystemPersona, AppID, key); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+func SavePurgeReport(s CelerixStore, r SignedPurgeReport) error {
+	return s.Set(SystemPersona, AppID, PurgeKeyPrefix+r.Report.ClientID, r)
+}
+
+// ListPurgeReports returns the purge reports, newest first.
+func ListPurgeReports(s CelerixStore) ([]SignedPurgeReport, error) {
+	appStore, err := s.GetAppStore(SystemPersona, AppID)
+	if err != nil {
+		return []SignedPurgeReport{}, nil
+	}
+	reports := []SignedPurgeReport{}
+	for key := range appStore {
+		if !strings.HasPrefix(key, PurgeKeyPrefix) {
+			continue
+		}
+		r, err := sdk.Get[SignedPurgeReport](s, SystemPersona, AppID, key)
+		if err != nil {
+			continue
+		}
+		reports = append(reports, r)
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Report.PurgedAt > reports[j].Report.PurgedAt })
+	return reports, nil
+}
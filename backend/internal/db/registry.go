@@ -0,0 +1,91 @@
+package db
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+const (
+	RegistryBlobKeyPrefix     = "oci:blob:"
+	RegistryManifestKeyPrefix = "oci:manifest:"
+	RegistryTagKeyPrefix      = "oci:tag:"
+)
+
+// RegistryBlob records that the OCI registry holds a reference on a depot
+// blob. Blobs are shared by all repositories, so there is one per digest.
+type RegistryBlob struct {
+	Digest string `json:"digest"`
+	Size   int64  `json:"size"`
+}
+
+// RegistryManifest is a manifest pushed to a repository. Its bytes are
+// stored as a blob under the same digest.
+type RegistryManifest struct {
+	Repository string `json:"repository"`
+	Digest     string `json:"digest"`
+	MediaType  string `json:"media_type"`
+	Size       int64  `json:"size"`
+	PushedBy   string `json:"pushed_by"`
+	PushedAt   int64  `json:"pushed_at"`
+}
+
+type RegistryTag struct {
+	Repository string `json:"repository"`
+	Tag        string `json:"tag"`
+	Digest     string `json:"digest"`
+}
+
+func GetRegistryBlob(s CelerixStore, digest string) (*RegistryBlob, error) {
+	b, err := sdk.Get[RegistryBlob](s, SystemPersona, AppID, RegistryBlobKeyPrefix+digest)
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func SaveRegistryBlob(s CelerixStore, b RegistryBlob) error {
+	return s.Set(SystemPersona, AppID, RegistryBlobKeyPrefix+b.Digest, b)
+}
+
+func GetRegistryManifest(s CelerixStore, repo, digest string) (*RegistryManifest, error) {
+	m, err := sdk.Get[RegistryManifest](s, SystemPersona, AppID, RegistryManifestKeyPrefix+repo+"@"+digest)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func SaveRegistryManifest(s CelerixStore, m RegistryManifest) error {
+	return s.Set(SystemPersona, AppID, RegistryManifestKeyPrefix+m.Repository+"@"+m.Digest, m)
+}
+
+func GetRegistryTag(s CelerixStore, repo, tag string) (*RegistryTag, error) {
+	t, err := sdk.Get[RegistryTag](s, SystemPersona, AppID, RegistryTagKeyPrefix+repo+":"+tag)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func SaveRegistryTag(s CelerixStore, t RegistryTag) error {
+	return s.Set(SystemPersona, AppID, RegistryTagKeyPrefix+t.Repository+":"+t.Tag, t)
+}
+
+// ListRegistryTags returns the tag names of a repository in lexical order.
+func ListRegistryTags(s CelerixStore, repo string) ([]string, error) {
+	appStore, err := s.GetAppStore(SystemPersona, AppID)
+	if err != nil {
+		return []string{}, nil
+	}
+	prefix := RegistryTagKeyPrefix + repo + ":"
+	tags := []string{}
+	for key := range appStore {
+		if strings.HasPrefix(key, prefix) {
+			tags = append(tags, strings.TrimPrefix(key, prefix))
+		}
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
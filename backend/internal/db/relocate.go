@@ -0,0 +1,34 @@
+package db
+
+// RelocateContent points the files in fileIDs stored at from, and the blob
+// with the given hash, at a copy of the same content at to. Files whose
+// stored path changed in the meantime are left alone. It returns the number
+// of files moved.
+func RelocateContent(s CelerixStore, hash, from, to string, fileIDs []string) (int, error) {
+	blobMu.Lock(s)
+	defer blobMu.Unlock()
+
+	moved := 0
+	for _, id := range fileIDs {
+		record, err := GetFileRecord(s, id)
+		if err != nil || record.StoredPath != from {
+			continue
+		}
+		record.StoredPath = to
+		// Where the content lives is nothing sync clients need to hear about
+		if err := saveFileQuietly(s, *record); err != nil {
+			return moved, err
+		}
+		moved++
+	}
+
+	if hash == "" {
+		return moved, nil
+	}
+	blob, err := GetBlob(s, hash)
+	if err != nil || blob.StoredPath != from {
+		return moved, nil
+	}
+	blob.StoredPath = to
+	return moved, s.Set(SystemPersona, AppID, BlobKeyPrefix+hash, *blob)
+}
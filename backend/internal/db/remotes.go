@@ -0,0 +1,56 @@
+package db
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+const RemoteDepotKeyPrefix = "remotedepot:"
+
+// RemoteDepot is another depot whose public files clients can browse and
+// download through this one.
+type RemoteDepot struct {
+	Name        string `json:"name"`
+	URL         string `json:"url"`
+	Description string `json:"description,omitempty"`
+	UpdatedAt   int64  `json:"updated_at"`
+}
+
+func SaveRemoteDepot(s CelerixStore, r RemoteDepot) error {
+	return s.Set(SystemPersona, AppID, RemoteDepotKeyPrefix+r.Name, r)
+}
+
+func GetRemoteDepot(s CelerixStore, name string) (*RemoteDepot, error) {
+	r, err := sdk.Get[RemoteDepot](s, SystemPersona, AppID, RemoteDepotKeyPrefix+name)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func DeleteRemoteDepot(s CelerixStore, name string) error {
+	return s.Delete(SystemPersona, AppID, RemoteDepotKeyPrefix+name)
+}
+
+// ListRemoteDepots returns the remote depots ordered by name.
+func ListRemoteDepots(s CelerixStore) ([]RemoteDepot, error) {
+	appStore, err := s.GetAppStore(SystemPersona, AppID)
+	if err != nil {
+		return []RemoteDepot{}, nil
+	}
+	remotes := []RemoteDepot{}
+	for key := range appStore {
+		if !strings.HasPrefix(key, RemoteDepotKeyPrefix) {
+			continue
+		}
+		r, err := sdk.Get[RemoteDepot](s, SystemPersona, AppID, key)
+		if err != nil {
+			continue
+		}
+		remotes = append(remotes, r)
+	}
+	sort.Slice(remotes, func(i, j int) bool { return remotes[i].Name < remotes[j].Name })
+	return remotes, nil
+}
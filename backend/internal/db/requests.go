@@ -0,0 +1,86 @@
+package db
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+const FileRequestKeyPrefix = "filerequest:"
+
+// FileRequest asks others for files. Anyone with its ID can upload until
+// the deadline; the uploads belong to the requester.
+type FileRequest struct {
+	ID          string `json:"id"`
+	OwnerID     string `json:"owner_id"`
+	Description string `json:"description"`
+	// Deadline is a Unix timestamp; zero means open until closed
+	Deadline int64  `json:"deadline,omitempty"`
+	Folder   string `json:"folder,omitempty"`
+	// WebhookURL is notified of uploads; empty falls back to the owner's
+	// chat integration
+	WebhookURL string   `json:"webhook_url,omitempty"`
+	Closed     bool     `json:"closed"`
+	CreatedAt  int64    `json:"created_at"`
+	FileIDs    []string `json:"file_ids"`
+}
+
+// Open reports whether the request still accepts uploads at time now.
+func (r *FileRequest) Open(now int64) bool {
+	return !r.Closed && (r.Deadline == 0 || now <= r.Deadline)
+}
+
+// fileRequestMu keeps concurrent uploads from dropping each other's IDs.
+var fileRequestMu = sharedMutex{name: "file-requests"}
+
+func SaveFileRequest(s CelerixStore, req FileRequest) error {
+	return s.Set(SystemPersona, AppID, FileRequestKeyPrefix+req.ID, req)
+}
+
+func GetFileRequest(s CelerixStore, id string) (*FileRequest, error) {
+	req, err := sdk.Get[FileRequest](s, SystemPersona, AppID, FileRequestKeyPrefix+id)
+	if err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+func DeleteFileRequest(s CelerixStore, id string) error {
+	return s.Delete(SystemPersona, AppID, FileRequestKeyPrefix+id)
+}
+
+// AddFileRequestUpload links an uploaded file to its request.
+func AddFileRequestUpload(s CelerixStore, id, fileID string) error {
+	fileRequestMu.Lock(s)
+	defer fileRequestMu.Unlock()
+
+	req, err := GetFileRequest(s, id)
+	if err != nil {
+		return err
+	}
+	req.FileIDs = append(req.FileIDs, fileID)
+	return SaveFileRequest(s, *req)
+}
+
+// ListFileRequests returns the requests of one owner, or all requests when
+// ownerID is empty, newest first.
+func ListFileRequests(s CelerixStore, ownerID string) ([]FileRequest, error) {
+	appStore, err := s.GetAppStore(SystemPersona, AppID)
+	if err != nil {
+		return []FileRequest{}, nil
+	}
+	reqs := []FileRequest{}
+	for key := range appStore {
+		if !strings.HasPrefix(key, FileRequestKeyPrefix) {
+			continue
+		}
+		req, err := sdk.Get[FileRequest](s, SystemPersona, AppID, key)
+		if err != nil || (ownerID != "" && req.OwnerID != ownerID) {
+			continue
+		}
+		reqs = append(reqs, req)
+	}
+	sort.Slice(reqs, func(i, j int) bool { return reqs[i].CreatedAt > reqs[j].CreatedAt })
+	return reqs, nil
+}
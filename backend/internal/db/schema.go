@@ -0,0 +1,27 @@
+package db
+
+import "github.com/celerix-dev/celerix-store/pkg/sdk"
+
+const SchemaKey = "schema"
+
+// SchemaVersion is the layout of the records this build reads and writes.
+// It only goes up when an older build would misread the store.
+const SchemaVersion = 1
+
+type schemaRecord struct {
+	Version int `json:"version"`
+}
+
+// GetSchemaVersion returns the schema the store was last opened with, or 0
+// for a new store or one from before the schema was recorded.
+func GetSchemaVersion(s CelerixStore) int {
+	r, err := sdk.Get[schemaRecord](s, SystemPersona, AppID, SchemaKey)
+	if err != nil {
+		return 0
+	}
+	return r.Version
+}
+
+func SaveSchemaVersion(s CelerixStore, version int) error {
+	return s.Set(SystemPersona, AppID, SchemaKey, schemaRecord{Version: version})
+}
@@ -0,0 +1,103 @@
+package db
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+const SettingsKey = "settings"
+
+// How client addresses are kept in download analytics and the audit log.
+const (
+	IPNone      = "none"
+	IPHashed    = "hashed"
+	IPTruncated = "truncated"
+)
+
+// Settings are instance options managed at runtime by admins. Zero values
+// mean "not configured" and fall back to the built-in behaviour.
+type Settings struct {
+	Title             string   `json:"title"`
+	LogoURL           string   `json:"logo_url"`
+	QuotaBytes        int64    `json:"quota_bytes"`
+	MaxUploadBytes    int64    `json:"max_upload_bytes"`
+	AllowedExtensions []string `json:"allowed_extensions"`
+	LinkExpirySeconds int64    `json:"link_expiry_seconds"`
+	// LandingPage shows an interstitial page before downloads by default
+	LandingPage bool `json:"landing_page"`
+	// ExpiryReminderSeconds is how long before a link expires its owner is
+	// reminded; ExtendSeconds is how much an extension adds (the link
+	// expiry by default); MaxExtensions caps extensions per link
+	ExpiryReminderSeconds int64 `json:"expiry_reminder_seconds"`
+	ExtendSeconds         int64 `json:"extend_seconds"`
+	MaxExtensions         int   `json:"max_extensions"`
+	// IPAddresses decides whether download analytics and audit entries
+	// keep the client's address: IPHashed, IPTruncated or, by default,
+	// not at all
+	IPAddresses string `json:"ip_addresses"`
+	// AnalyticsRetentionDays and AuditRetentionDays drop older download
+	// analytics and audit entries; 0 keeps them
+	AnalyticsRetentionDays int `json:"analytics_retention_days"`
+	AuditRetentionDays     int `json:"audit_retention_days"`
+	// Federation lets other depots list the public files, so their clients
+	// can browse them
+	Federation bool `json:"federation"`
+	// LinkRotationDays replaces the download links of public files in
+	// LinkRotationFolders once they are that old; 0 turns rotation off
+	LinkRotationDays    int      `json:"link_rotation_days"`
+	LinkRotationFolders []string `json:"link_rotation_folders"`
+}
+
+func GetSettings(s CelerixStore) Settings {
+	settings, err := sdk.Get[Settings](s, SystemPersona, AppID, SettingsKey)
+	if err != nil {
+		return Settings{}
+	}
+	return settings
+}
+
+func SaveSettings(s CelerixStore, settings Settings) error {
+	return s.Set(SystemPersona, AppID, SettingsKey, NormalizeSettings(settings))
+}
+
+// NormalizeSettings returns the settings as they are saved.
+func NormalizeSettings(settings Settings) Settings {
+	settings.AllowedExtensions = normalizeExtensions(settings.AllowedExtensions)
+	if settings.LinkRotationFolders != nil {
+		folders := []string{}
+		for _, f := range settings.LinkRotationFolders {
+			if f = NormalizeFolder(f); f != "" {
+				folders = append(folders, f)
+			}
+		}
+		settings.LinkRotationFolders = folders
+	}
+	return settings
+}
+
+func normalizeExtensions(exts []string) []string {
+	if exts == nil {
+		return nil
+	}
+	normalized := make([]string, len(exts))
+	for i, ext := range exts {
+		normalized[i] = "." + strings.TrimPrefix(strings.ToLower(strings.TrimSpace(ext)), ".")
+	}
+	return normalized
+}
+
+// AllowsName reports whether the file name passes the allowed extensions list.
+func (s Settings) AllowsName(name string) bool {
+	if len(s.AllowedExtensions) == 0 {
+		return true
+	}
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, allowed := range s.AllowedExtensions {
+		if ext == allowed {
+			return true
+		}
+	}
+	return false
+}
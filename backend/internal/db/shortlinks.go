@@ -0,0 +1,56 @@
+package db
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+const ShortLinkKeyPrefix = "shortlink:"
+
+// ShortLinkRecord maps a short slug, served on the short domain, to a file.
+type ShortLinkRecord struct {
+	Slug      string `json:"slug"`
+	FileID    string `json:"file_id"`
+	CreatedBy string `json:"created_by"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+func SaveShortLink(s CelerixStore, link ShortLinkRecord) error {
+	return s.Set(SystemPersona, AppID, ShortLinkKeyPrefix+link.Slug, link)
+}
+
+func GetShortLink(s CelerixStore, slug string) (*ShortLinkRecord, error) {
+	link, err := sdk.Get[ShortLinkRecord](s, SystemPersona, AppID, ShortLinkKeyPrefix+slug)
+	if err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+func DeleteShortLink(s CelerixStore, slug string) error {
+	return s.Delete(SystemPersona, AppID, ShortLinkKeyPrefix+slug)
+}
+
+// ListShortLinks returns the short links to one file, or all of them when
+// fileID is empty, ordered by slug.
+func ListShortLinks(s CelerixStore, fileID string) ([]ShortLinkRecord, error) {
+	appStore, err := s.GetAppStore(SystemPersona, AppID)
+	if err != nil {
+		return []ShortLinkRecord{}, nil
+	}
+	links := []ShortLinkRecord{}
+	for key := range appStore {
+		if !strings.HasPrefix(key, ShortLinkKeyPrefix) {
+			continue
+		}
+		link, err := sdk.Get[ShortLinkRecord](s, SystemPersona, AppID, key)
+		if err != nil || (fileID != "" && link.FileID != fileID) {
+			continue
+		}
+		links = append(links, link)
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i].Slug < links[j].Slug })
+	return links, nil
+}
@@ -0,0 +1,37 @@
+package db
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+const signingKeyKey = "signingkey"
+
+var signingKeyMu = sharedMutex{name: "signing-key"}
+
+// SigningKey returns the instance's Ed25519 key for documents it vouches
+// for, creating it on first use. Only the seed is stored.
+func SigningKey(s CelerixStore) (ed25519.PrivateKey, error) {
+	signingKeyMu.Lock(s)
+	defer signingKeyMu.Unlock()
+
+	if seed, err := sdk.Get[string](s, SystemPersona, AppID, signingKeyKey); err == nil {
+		raw, err := hex.DecodeString(seed)
+		if err != nil || len(raw) != ed25519.SeedSize {
+			return nil, errors.New("stored signing key is invalid")
+		}
+		return ed25519.NewKeyFromSeed(raw), nil
+	}
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Set(SystemPersona, AppID, signingKeyKey, hex.EncodeToString(key.Seed())); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
@@ -0,0 +1,93 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+const SiteKeyPrefix = "site:"
+
+// SiteRecord publishes one of a client's folders as a static site.
+type SiteRecord struct {
+	Slug      string `json:"slug"`
+	OwnerID   string `json:"owner_id"`
+	Folder    string `json:"folder"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+func SaveSite(s CelerixStore, site SiteRecord) error {
+	return s.Set(SystemPersona, AppID, SiteKeyPrefix+site.Slug, site)
+}
+
+func GetSite(s CelerixStore, slug string) (*SiteRecord, error) {
+	site, err := sdk.Get[SiteRecord](s, SystemPersona, AppID, SiteKeyPrefix+slug)
+	if err != nil {
+		return nil, err
+	}
+	return &site, nil
+}
+
+func DeleteSite(s CelerixStore, slug string) error {
+	return s.Delete(SystemPersona, AppID, SiteKeyPrefix+slug)
+}
+
+// ListSites returns the sites of one owner, or all sites when ownerID is
+// empty, ordered by slug.
+func ListSites(s CelerixStore, ownerID string) ([]SiteRecord, error) {
+	appStore, err := s.GetAppStore(SystemPersona, AppID)
+	if err != nil {
+		return []SiteRecord{}, nil
+	}
+	sites := []SiteRecord{}
+	for key := range appStore {
+		if !strings.HasPrefix(key, SiteKeyPrefix) {
+			continue
+		}
+		site, err := sdk.Get[SiteRecord](s, SystemPersona, AppID, key)
+		if err != nil || (ownerID != "" && site.OwnerID != ownerID) {
+			continue
+		}
+		sites = append(sites, site)
+	}
+	sort.Slice(sites, func(i, j int) bool { return sites[i].Slug < sites[j].Slug })
+	return sites, nil
+}
+
+// FindFolderFile looks up the file at a slash-separated path relative to
+// one of the owner's folders, e.g. "css/site.css" in folder "report".
+// When several files share the path the newest wins.
+func FindFolderFile(s CelerixStore, ownerID, folder, path string) (*FileRecord, error) {
+	dir, name := "", path
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		dir, name = path[:i], path[i+1:]
+	}
+	want := folder
+	if dir != "" {
+		want = strings.TrimPrefix(folder+"/"+dir, "/")
+	}
+
+	appStore, err := s.GetAppStore(ownerID, AppID)
+	if err != nil {
+		return nil, err
+	}
+	var found *FileRecord
+	for key := range appStore {
+		if !strings.HasPrefix(key, FileKeyPrefix) {
+			continue
+		}
+		r, err := sdk.Get[FileRecord](s, ownerID, AppID, key)
+		if err != nil || r.Folder != want || r.OriginalName != name {
+			continue
+		}
+		if found == nil || r.UploadTime > found.UploadTime {
+			found = &r
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("file not found")
+	}
+	return found, nil
+}
@@ -0,0 +1,37 @@
+package db
+
+import (
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+const SnippetKeyPrefix = "snippet:"
+
+// SnippetRecord marks a file as a text snippet. The text itself is stored
+// like any other file; this only adds what's needed to render it inline.
+type SnippetRecord struct {
+	FileID       string `json:"file_id"`
+	Title        string `json:"title"`
+	Language     string `json:"language"`
+	PasswordSalt string `json:"password_salt,omitempty"`
+	PasswordHash string `json:"password_hash,omitempty"`
+}
+
+func (r *SnippetRecord) HasPassword() bool {
+	return r.PasswordHash != ""
+}
+
+func SaveSnippet(s CelerixStore, snippet SnippetRecord) error {
+	return s.Set(SystemPersona, AppID, SnippetKeyPrefix+snippet.FileID, snippet)
+}
+
+func GetSnippet(s CelerixStore, fileID string) (*SnippetRecord, error) {
+	snippet, err := sdk.Get[SnippetRecord](s, SystemPersona, AppID, SnippetKeyPrefix+fileID)
+	if err != nil {
+		return nil, err
+	}
+	return &snippet, nil
+}
+
+func DeleteSnippet(s CelerixStore, fileID string) error {
+	return s.Delete(SystemPersona, AppID, SnippetKeyPrefix+fileID)
+}
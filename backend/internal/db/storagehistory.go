@@ -0,0 +1,84 @@
+package db
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+const StorageSnapshotKeyPrefix = "storagesnap:"
+
+// StorageSnapshot is how much a client stored at the end of one day (UTC),
+// so growth can be plotted over time. A snapshot without ClientID is the
+// whole depot, with shared content counted once.
+type StorageSnapshot struct {
+	Day      string `json:"day"`
+	ClientID string `json:"client_id"`
+	Bytes    int64  `json:"bytes"`
+	Files    int    `json:"files"`
+}
+
+// StorageDay is the day a snapshot taken at t belongs to, e.g. "2026-10-16".
+func StorageDay(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+// Keys group a client's snapshots under one prefix; days compare as strings.
+func storageSnapshotKey(clientID, day string) string {
+	return StorageSnapshotKeyPrefix + clientID + ":" + day
+}
+
+// SaveStorageSnapshot stores a snapshot, replacing one taken earlier the
+// same day.
+func SaveStorageSnapshot(s CelerixStore, snap StorageSnapshot) error {
+	return s.Set(SystemPersona, AppID, storageSnapshotKey(snap.ClientID, snap.Day), snap)
+}
+
+// ListStorageSnapshots returns the client's snapshots from the given day
+// on, oldest first.
+func ListStorageSnapshots(s CelerixStore, clientID, since string) ([]StorageSnapshot, error) {
+	appStore, err := s.GetAppStore(SystemPersona, AppID)
+	if err != nil {
+		return []StorageSnapshot{}, nil
+	}
+	prefix := storageSnapshotKey(clientID, "")
+	snaps := []StorageSnapshot{}
+	for key := range appStore {
+		day, ok := strings.CutPrefix(key, prefix)
+		if !ok || day < since {
+			continue
+		}
+		snap, err := sdk.Get[StorageSnapshot](s, SystemPersona, AppID, key)
+		if err != nil {
+			continue
+		}
+		snaps = append(snaps, snap)
+	}
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].Day < snaps[j].Day })
+	return snaps, nil
+}
+
+// PruneStorageSnapshots drops the snapshots of days before the given one.
+func PruneStorageSnapshots(s CelerixStore, before string) (int, error) {
+	appStore, err := s.GetAppStore(SystemPersona, AppID)
+	if err != nil {
+		return 0, nil
+	}
+	removed := 0
+	for key := range appStore {
+		rest, ok := strings.CutPrefix(key, StorageSnapshotKeyPrefix)
+		if !ok {
+			continue
+		}
+		if i := strings.LastIndexByte(rest, ':'); i < 0 || rest[i+1:] >= before {
+			continue
+		}
+		if err := s.Delete(SystemPersona, AppID, key); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+const TenantKeyPrefix = "tenant:"
+
+// TenantRecord describes an isolated depot served by the same instance. It
+// lives in the root store; the tenant's own data lives in a scoped store.
+type TenantRecord struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Hosts       []string `json:"hosts"`
+	AdminSecret string   `json:"admin_secret,omitempty"`
+	QuotaBytes  int64    `json:"quota_bytes"`
+	CreatedAt   int64    `json:"created_at"`
+}
+
+func SaveTenant(s CelerixStore, t TenantRecord) error {
+	for i, host := range t.Hosts {
+		t.Hosts[i] = strings.ToLower(strings.TrimSpace(host))
+	}
+	return s.Set(SystemPersona, AppID, TenantKeyPrefix+t.ID, t)
+}
+
+func GetTenant(s CelerixStore, id string) (*TenantRecord, error) {
+	t, err := sdk.Get[TenantRecord](s, SystemPersona, AppID, TenantKeyPrefix+id)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func ListTenants(s CelerixStore) ([]TenantRecord, error) {
+	appStore, err := s.GetAppStore(SystemPersona, AppID)
+	if err != nil {
+		return []TenantRecord{}, nil
+	}
+	tenants := []TenantRecord{}
+	for key := range appStore {
+		if !strings.HasPrefix(key, TenantKeyPrefix) {
+			continue
+		}
+		t, err := sdk.Get[TenantRecord](s, SystemPersona, AppID, key)
+		if err != nil {
+			continue
+		}
+		tenants = append(tenants, t)
+	}
+	sort.Slice(tenants, func(i, j int) bool { return tenants[i].ID < tenants[j].ID })
+	return tenants, nil
+}
+
+func DeleteTenant(s CelerixStore, id string) error {
+	return s.Delete(SystemPersona, AppID, TenantKeyPrefix+id)
+}
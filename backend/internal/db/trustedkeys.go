@@ -0,0 +1,61 @@
+package db
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+const TrustedKeyPrefix = "trustedkey:"
+
+// TrustedKey is a public key admins trust to sign uploads, usually that of
+// a CI pipeline. Files whose signature verifies against it are marked as
+// signed by its name.
+type TrustedKey struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// PublicKey is the key as given: PEM or a minisign public key
+	PublicKey string `json:"public_key"`
+	Algorithm string `json:"algorithm"`
+	// KeyID is the minisign key ID
+	KeyID     string `json:"key_id,omitempty"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+func SaveTrustedKey(s CelerixStore, k TrustedKey) error {
+	return s.Set(SystemPersona, AppID, TrustedKeyPrefix+k.Name, k)
+}
+
+func GetTrustedKey(s CelerixStore, name string) (*TrustedKey, error) {
+	k, err := sdk.Get[TrustedKey](s, SystemPersona, AppID, TrustedKeyPrefix+name)
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+func DeleteTrustedKey(s CelerixStore, name string) error {
+	return s.Delete(SystemPersona, AppID, TrustedKeyPrefix+name)
+}
+
+// ListTrustedKeys returns the trusted keys ordered by name.
+func ListTrustedKeys(s CelerixStore) ([]TrustedKey, error) {
+	appStore, err := s.GetAppStore(SystemPersona, AppID)
+	if err != nil {
+		return []TrustedKey{}, nil
+	}
+	keys := []TrustedKey{}
+	for key := range appStore {
+		if !strings.HasPrefix(key, TrustedKeyPrefix) {
+			continue
+		}
+		k, err := sdk.Get[TrustedKey](s, SystemPersona, AppID, key)
+		if err != nil {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Name < keys[j].Name })
+	return keys, nil
+}
@@ -0,0 +1,164 @@
+package db
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+const (
+	UploadSessionKeyPrefix = "uploadsession:"
+	// UploadChunkKeyPrefix keys a received chunk as <session>:<index>, so
+	// chunks arriving in parallel never write the same record
+	UploadChunkKeyPrefix = "uploadchunk:"
+)
+
+// UploadSession is a resumable upload in progress. It is kept in the store,
+// with the chunks received so far on disk under ChunkDir, so uploads
+// survive a restart of the server.
+type UploadSession struct {
+	ID          string `json:"id"`
+	OwnerID     string `json:"owner_id"`
+	Name        string `json:"name"`
+	Size        int64  `json:"size"`
+	ChunkSize   int64  `json:"chunk_size"`
+	TotalChunks int    `json:"total_chunks"`
+	IsPublic    bool   `json:"is_public"`
+	StripMeta   bool   `json:"strip_metadata"`
+	Preset      string `json:"preset,omitempty"`
+	Overwrite   string `json:"overwrite,omitempty"`
+	// Parallelism is how many chunks the client should send at once
+	Parallelism int `json:"parallelism"`
+	// Negotiated is set when the server picked the chunk size
+	Negotiated bool  `json:"negotiated,omitempty"`
+	CreatedAt  int64 `json:"created_at"`
+	// ChunkDir holds the received chunks, so they are found again even if
+	// the temp directory setting changes
+	ChunkDir string `json:"-"`
+	// Chunks maps the index of each chunk received to its SHA-256. They
+	// are stored apart from the session, see SetUploadChunk.
+	Chunks map[int]string `json:"chunks"`
+}
+
+// uploadChunk is a received chunk as it is stored.
+type uploadChunk struct {
+	OwnerID string `json:"owner_id"`
+	Hash    string `json:"sha256"`
+}
+
+// uploadSessionRecord is how a session is stored; ChunkDir is left out of
+// API responses but must be kept.
+type uploadSessionRecord struct {
+	UploadSession
+	ChunkDir string `json:"chunk_dir"`
+}
+
+func (s *UploadSession) ChunkLength(index int) int64 {
+	if index == s.TotalChunks-1 {
+		return s.Size - int64(index)*s.ChunkSize
+	}
+	return s.ChunkSize
+}
+
+// Received returns the indices of the chunks received, in order.
+func (s *UploadSession) Received() []int {
+	indices := make([]int, 0, len(s.Chunks))
+	for i := range s.Chunks {
+		indices = append(indices, i)
+	}
+	sort.Ints(indices)
+	return indices
+}
+
+func SaveUploadSession(s CelerixStore, session UploadSession) error {
+	session.Chunks = map[int]string{}
+	return s.Set(SystemPersona, AppID, UploadSessionKeyPrefix+session.ID, uploadSessionRecord{session, session.ChunkDir})
+}
+
+func GetUploadSession(s CelerixStore, id string) (*UploadSession, error) {
+	r, err := sdk.Get[uploadSessionRecord](s, SystemPersona, AppID, UploadSessionKeyPrefix+id)
+	if err != nil {
+		return nil, err
+	}
+	session := r.UploadSession
+	session.ChunkDir = r.ChunkDir
+	session.Chunks = map[int]string{}
+	for key := range uploadChunkKeys(s, id) {
+		index, err := strconv.Atoi(key[strings.LastIndexByte(key, ':')+1:])
+		if err != nil {
+			continue
+		}
+		if chunk, err := sdk.Get[uploadChunk](s, SystemPersona, AppID, key); err == nil {
+			session.Chunks[index] = chunk.Hash
+		}
+	}
+	return &session, nil
+}
+
+// uploadChunkKeys returns the keys of the chunks received for the session.
+func uploadChunkKeys(s CelerixStore, id string) map[string]bool {
+	keys := map[string]bool{}
+	appStore, err := s.GetAppStore(SystemPersona, AppID)
+	if err != nil {
+		return keys
+	}
+	prefix := UploadChunkKeyPrefix + id + ":"
+	for key := range appStore {
+		if strings.HasPrefix(key, prefix) {
+			keys[key] = true
+		}
+	}
+	return keys
+}
+
+// DeleteUploadSession deletes the session and its chunk records.
+func DeleteUploadSession(s CelerixStore, id string) error {
+	for key := range uploadChunkKeys(s, id) {
+		if err := s.Delete(SystemPersona, AppID, key); err != nil {
+			return err
+		}
+	}
+	return s.Delete(SystemPersona, AppID, UploadSessionKeyPrefix+id)
+}
+
+// SetUploadChunk records a received chunk of the session. Each chunk has a
+// record of its own, so no lock is needed and the session isn't rewritten
+// for every chunk.
+func SetUploadChunk(s CelerixStore, id string, index int, hash string) error {
+	session, err := sdk.Get[uploadSessionRecord](s, SystemPersona, AppID, UploadSessionKeyPrefix+id)
+	if err != nil {
+		return err
+	}
+	key := UploadChunkKeyPrefix + id + ":" + strconv.Itoa(index)
+	return s.Set(SystemPersona, AppID, key, uploadChunk{OwnerID: session.OwnerID, Hash: hash})
+}
+
+// DeleteUploadChunk forgets a chunk of the session, which has to be sent
+// again.
+func DeleteUploadChunk(s CelerixStore, id string, index int) error {
+	return s.Delete(SystemPersona, AppID, UploadChunkKeyPrefix+id+":"+strconv.Itoa(index))
+}
+
+// ListUploadSessions returns the sessions in progress, oldest first.
+func ListUploadSessions(s CelerixStore) ([]UploadSession, error) {
+	appStore, err := s.GetAppStore(SystemPersona, AppID)
+	if err != nil {
+		return []UploadSession{}, nil
+	}
+	sessions := []UploadSession{}
+	for key := range appStore {
+		id, ok := strings.CutPrefix(key, UploadSessionKeyPrefix)
+		if !ok {
+			continue
+		}
+		session, err := GetUploadSession(s, id)
+		if err != nil {
+			continue
+		}
+		sessions = append(sessions, *session)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].CreatedAt < sessions[j].CreatedAt })
+	return sessions, nil
+}
@@ -0,0 +1,119 @@
+package db
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+const UsageKeyPrefix = "usage:"
+
+// UsageRecord totals one client's transfer volume for one calendar month
+// (UTC), for chargeback. Uploads count against the uploader, downloads
+// against the file's owner. System files are accounted to SystemPersona.
+// Downloads counts requests, so a transfer split into ranges counts several
+// times while its bytes add up to the file size.
+type UsageRecord struct {
+	Month         string `json:"month"`
+	ClientID      string `json:"client_id"`
+	UploadBytes   int64  `json:"upload_bytes"`
+	DownloadBytes int64  `json:"download_bytes"`
+	Uploads       int64  `json:"uploads"`
+	Downloads     int64  `json:"downloads"`
+}
+
+// usageMu serializes counter updates so concurrent transfers aren't lost.
+var usageMu = sharedMutex{name: "usage"}
+
+// UsageMonth is the month a transfer at t is billed to, e.g. "2026-10".
+func UsageMonth(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
+
+// Keys start with the month, so a month's records share a prefix and compare
+// against other months as strings.
+func usageKey(month, clientID string) string {
+	return UsageKeyPrefix + month + ":" + clientID
+}
+
+// RecordUsage adds a transfer to the client's totals for the month of t.
+// Each month gets its own record, so counters roll over by themselves.
+func RecordUsage(s CelerixStore, clientID string, t time.Time, uploadBytes, downloadBytes int64) error {
+	if clientID == "" {
+		clientID = SystemPersona
+	}
+	usageMu.Lock(s)
+	defer usageMu.Unlock()
+
+	month := UsageMonth(t)
+	key := usageKey(month, clientID)
+	u, err := sdk.Get[UsageRecord](s, SystemPersona, AppID, key)
+	if err != nil {
+		u = UsageRecord{Month: month, ClientID: clientID}
+	}
+	if uploadBytes > 0 {
+		u.UploadBytes += uploadBytes
+		u.Uploads++
+	}
+	if downloadBytes > 0 {
+		u.DownloadBytes += downloadBytes
+		u.Downloads++
+	}
+	return s.Set(SystemPersona, AppID, key, u)
+}
+
+// ListUsage returns the records of one month, or of every month kept when
+// month is empty, ordered by month and client.
+func ListUsage(s CelerixStore, month string) ([]UsageRecord, error) {
+	appStore, err := s.GetAppStore(SystemPersona, AppID)
+	if err != nil {
+		return []UsageRecord{}, nil
+	}
+	prefix := UsageKeyPrefix
+	if month != "" {
+		prefix = usageKey(month, "")
+	}
+	records := []UsageRecord{}
+	for key := range appStore {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		u, err := sdk.Get[UsageRecord](s, SystemPersona, AppID, key)
+		if err != nil {
+			continue
+		}
+		records = append(records, u)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Month != records[j].Month {
+			return records[i].Month < records[j].Month
+		}
+		return records[i].ClientID < records[j].ClientID
+	})
+	return records, nil
+}
+
+// PruneUsage drops the records of months before the given one.
+func PruneUsage(s CelerixStore, before string) (int, error) {
+	usageMu.Lock(s)
+	defer usageMu.Unlock()
+
+	appStore, err := s.GetAppStore(SystemPersona, AppID)
+	if err != nil {
+		return 0, nil
+	}
+	removed := 0
+	for key := range appStore {
+		month, ok := strings.CutPrefix(key, UsageKeyPrefix)
+		if !ok || month >= before {
+			continue
+		}
+		if err := s.Delete(SystemPersona, AppID, key); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
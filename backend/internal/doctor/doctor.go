@@ -0,0 +1,219 @@
+// Package doctor checks that the environment a depot runs in is fit for
+// it: directories it writes to, free space, the store's schema, the clock
+// and the services it is configured to talk to. Each check returns a
+// Result that says what is wrong and what to do about it.
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+)
+
+type Status string
+
+const (
+	OK   Status = "ok"
+	Warn Status = "warn"
+	Fail Status = "fail"
+)
+
+type Result struct {
+	Name   string `json:"name"`
+	Status Status `json:"status"`
+	Detail string `json:"detail"`
+	// Fix says what to do about a warning or failure
+	Fix string `json:"fix,omitempty"`
+}
+
+func ok(name, format string, args ...any) Result {
+	return Result{Name: name, Status: OK, Detail: fmt.Sprintf(format, args...)}
+}
+
+// Free space below these fractions of the volume is warned about or fails.
+const (
+	warnFreePercent = 10
+	failFreePercent = 2
+)
+
+// Clock skew beyond these is warned about or fails. S3 refuses requests
+// signed more than 15 minutes off, and link expiry drifts with the clock.
+const (
+	warnSkew = time.Minute
+	failSkew = 15 * time.Minute
+)
+
+// Writable checks that files can be created in dir, by creating and
+// removing one.
+func Writable(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	f, err := os.CreateTemp(dir, ".depot-probe-*")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	_, err = f.Write([]byte("probe"))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if rerr := os.Remove(name); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// existingParent returns dir, or its closest ancestor that exists.
+func existingParent(dir string) string {
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
+
+// CheckDir checks that the depot can write to dir, or create it.
+func CheckDir(name, dir string) Result {
+	if parent := existingParent(dir); parent != dir {
+		if err := Writable(parent); err != nil {
+			return Result{Name: name, Status: Fail, Detail: fmt.Sprintf("%s does not exist and cannot be created: %v", dir, err),
+				Fix: fmt.Sprintf("create %s and give the user the depot runs as write access to it", dir)}
+		}
+		return ok(name, "%s will be created", dir)
+	}
+	if err := Writable(dir); err != nil {
+		return Result{Name: name, Status: Fail, Detail: err.Error(),
+			Fix: fmt.Sprintf("create %s and give the user the depot runs as write access to it", dir)}
+	}
+	return ok(name, "%s is writable", dir)
+}
+
+// CheckDiskSpace checks the free space on the volume holding dir; usage is
+// storage.VolumeUsage outside tests.
+func CheckDiskSpace(name, dir string, usage func(dir string) (total, free uint64, err error)) Result {
+	total, free, err := usage(existingParent(dir))
+	if err != nil {
+		return Result{Name: name, Status: Warn, Detail: "cannot read volume usage: " + err.Error()}
+	}
+	if total == 0 {
+		return Result{Name: name, Status: Warn, Detail: "the volume reports no size"}
+	}
+	percent := free * 100 / total
+	detail := fmt.Sprintf("%d of %d bytes free (%d%%)", free, total, percent)
+	switch {
+	case percent < failFreePercent:
+		return Result{Name: name, Status: Fail, Detail: detail, Fix: "free up space or grow the volume; uploads will start failing"}
+	case percent < warnFreePercent:
+		return Result{Name: name, Status: Warn, Detail: detail, Fix: "plan to free up space or grow the volume"}
+	}
+	return ok(name, "%s", detail)
+}
+
+// CheckSchema checks that this build understands the store.
+func CheckSchema(store db.CelerixStore) Result {
+	v := db.GetSchemaVersion(store)
+	switch {
+	case v > db.SchemaVersion:
+		return Result{Name: "store schema", Status: Fail,
+			Detail: fmt.Sprintf("the store has schema %d, this build supports up to %d", v, db.SchemaVersion),
+			Fix:    "upgrade the depot to the version that last wrote the store, or newer"}
+	case v == 0:
+		return ok("store schema", "not recorded yet, it will be set to %d on start", db.SchemaVersion)
+	}
+	return ok("store schema", "version %d", v)
+}
+
+// CheckClock compares the local clock with the Date header of an HTTP
+// server, such as the S3 endpoint.
+func CheckClock(ctx context.Context, client *http.Client, rawURL string) Result {
+	const name = "clock skew"
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, rawURL, nil)
+	if err != nil {
+		return Result{Name: name, Status: Warn, Detail: err.Error()}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return Result{Name: name, Status: Warn, Detail: "cannot reach " + req.URL.Host + " to compare clocks: " + err.Error()}
+	}
+	resp.Body.Close()
+	remote, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return Result{Name: name, Status: Warn, Detail: req.URL.Host + " sent no usable Date header"}
+	}
+	skew := time.Since(remote).Round(time.Second)
+	if skew < 0 {
+		skew = -skew
+	}
+	detail := fmt.Sprintf("%s off from %s", skew, req.URL.Host)
+	switch {
+	case skew >= failSkew:
+		return Result{Name: name, Status: Fail, Detail: detail, Fix: "synchronize the clock with NTP; S3 rejects requests this far off"}
+	case skew >= warnSkew:
+		return Result{Name: name, Status: Warn, Detail: detail, Fix: "synchronize the clock with NTP"}
+	}
+	return ok(name, "%s", detail)
+}
+
+// defaultPorts are used for URLs that don't name a port.
+var defaultPorts = map[string]string{
+	"http":        "80",
+	"https":       "443",
+	"nats":        "4222",
+	"tls":         "4222",
+	"kafka+http":  "80",
+	"kafka+https": "443",
+}
+
+// CheckReachable checks that a TCP connection can be opened to addr, which
+// is either a URL or host:port. Credentials in URLs are not shown.
+func CheckReachable(ctx context.Context, name, addr string) Result {
+	hostPort := addr
+	if u, err := url.Parse(addr); err == nil && u.Scheme != "" && u.Host != "" {
+		hostPort = u.Host
+		if u.Port() == "" {
+			port, known := defaultPorts[u.Scheme]
+			if !known {
+				return Result{Name: name, Status: Fail, Detail: "unsupported URL scheme " + u.Scheme, Fix: "check the configured URL"}
+			}
+			hostPort = net.JoinHostPort(u.Hostname(), port)
+		}
+	}
+	if _, _, err := net.SplitHostPort(hostPort); err != nil {
+		return Result{Name: name, Status: Fail, Detail: err.Error(), Fix: "configure it as host:port or a URL"}
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", hostPort)
+	if err != nil {
+		return Result{Name: name, Status: Fail, Detail: err.Error(),
+			Fix: fmt.Sprintf("check that %s is up and that firewalls let the depot reach it", hostPort)}
+	}
+	conn.Close()
+	return ok(name, "%s is reachable", hostPort)
+}
+
+// Failed reports whether any of the results failed.
+func Failed(results []Result) bool {
+	for _, r := range results {
+		if r.Status == Fail {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/memstore"
+)
+
+func TestCheckDir(t *testing.T) {
+	dir := t.TempDir()
+	if r := CheckDir("storage", dir); r.Status != OK {
+		t.Errorf("expected a temp dir to be writable, got %+v", r)
+	}
+	if r := CheckDir("storage", filepath.Join(dir, "a", "b")); r.Status != OK {
+		t.Errorf("expected a missing dir under a writable one to be creatable, got %+v", r)
+	}
+	file := filepath.Join(dir, "file")
+	os.WriteFile(file, []byte("x"), 0644)
+	if r := CheckDir("storage", file); r.Status != Fail || r.Fix == "" {
+		t.Errorf("expected a file to fail with a fix, got %+v", r)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("expected the probes to be removed, found %d entries", len(entries))
+	}
+}
+
+func TestCheckDiskSpace(t *testing.T) {
+	usage := func(free uint64, err error) func(string) (uint64, uint64, error) {
+		return func(string) (uint64, uint64, error) { return 1000, free, err }
+	}
+	cases := []struct {
+		free uint64
+		err  error
+		want Status
+	}{
+		{500, nil, OK},
+		{50, nil, Warn},
+		{10, nil, Fail},
+		{0, errors.New("no volume"), Warn},
+	}
+	for _, c := range cases {
+		if r := CheckDiskSpace("volume", t.TempDir(), usage(c.free, c.err)); r.Status != c.want {
+			t.Errorf("free %d: expected %s, got %+v", c.free, c.want, r)
+		}
+	}
+}
+
+func TestCheckSchema(t *testing.T) {
+	store := memstore.New()
+	if r := CheckSchema(store); r.Status != OK {
+		t.Errorf("expected a new store to pass, got %+v", r)
+	}
+	db.SaveSchemaVersion(store, db.SchemaVersion+1)
+	if r := CheckSchema(store); r.Status != Fail {
+		t.Errorf("expected a newer schema to fail, got %+v", r)
+	}
+}
+
+func TestCheckClock(t *testing.T) {
+	var offset time.Duration
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", time.Now().Add(offset).UTC().Format(http.TimeFormat))
+	}))
+	defer srv.Close()
+
+	for _, c := range []struct {
+		offset time.Duration
+		want   Status
+	}{
+		{0, OK},
+		{-5 * time.Minute, Warn},
+		{time.Hour, Fail},
+	} {
+		offset = c.offset
+		if r := CheckClock(context.Background(), srv.Client(), srv.URL); r.Status != c.want {
+			t.Errorf("offset %s: expected %s, got %+v", c.offset, c.want, r)
+		}
+	}
+}
+
+func TestCheckReachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	ctx := context.Background()
+	if r := CheckReachable(ctx, "relay", addr); r.Status != OK {
+		t.Errorf("expected host:port to be reachable, got %+v", r)
+	}
+	if r := CheckReachable(ctx, "bus", "nats://token@"+addr); r.Status != OK || r.Detail != addr+" is reachable" {
+		t.Errorf("expected a URL to be reachable without showing credentials, got %+v", r)
+	}
+	if r := CheckReachable(ctx, "bus", "gopher://example.com"); r.Status != Fail {
+		t.Errorf("expected an unknown scheme to fail, got %+v", r)
+	}
+	ln.Close()
+	if r := CheckReachable(ctx, "relay", addr); r.Status != Fail || r.Fix == "" {
+		t.Errorf("expected a closed port to fail with a fix, got %+v", r)
+	}
+	if !Failed([]Result{{Status: OK}, {Status: Fail}}) || Failed([]Result{{Status: Warn}}) {
+		t.Error("Failed should only report failures")
+	}
+}
@@ -0,0 +1,179 @@
+// Package events publishes the depot's file lifecycle events to a message
+// bus, NATS or Kafka, so other systems can react to them without polling
+// the API. It also receives messages from NATS, for commands sent to the
+// depot.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SchemaVersion is the version of the Event payload. It only changes when
+// a field is removed or changes meaning; new fields may appear at any time.
+const SchemaVersion = 1
+
+// Event types.
+const (
+	FileUploaded = "file.uploaded"
+	FileDeleted  = "file.deleted"
+	// FileShared is a file made public after its upload
+	FileShared = "file.shared"
+	// FileScanned carries the outcome of the checks on upload in Scan
+	FileScanned = "file.scanned"
+)
+
+// Scan outcomes.
+const (
+	ScanPassed      = "passed"
+	ScanQuarantined = "quarantined"
+)
+
+// File describes the file an event is about.
+type File struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	OwnerID  string   `json:"owner_id"`
+	Size     int64    `json:"size"`
+	SHA256   string   `json:"sha256"`
+	Folder   string   `json:"folder"`
+	Tags     []string `json:"tags"`
+	IsPublic bool     `json:"is_public"`
+}
+
+// Event is the payload published to the bus, one JSON object per message.
+type Event struct {
+	Schema int `json:"schema"`
+	// ID is unique per event, for consumers that need to drop duplicates
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Time int64  `json:"time"`
+	// Source is the public URL of the depot, Tenant the tenant within it
+	Source string `json:"source,omitempty"`
+	Tenant string `json:"tenant,omitempty"`
+	File   File   `json:"file"`
+	Scan   string `json:"scan,omitempty"`
+}
+
+// Publisher delivers a message to a topic of a bus. Key groups related
+// messages, e.g. onto one Kafka partition.
+type Publisher interface {
+	Publish(ctx context.Context, topic, key string, body []byte) error
+	Close() error
+}
+
+// Open returns the publisher for a bus URL: nats://[user:pass@]host:port
+// or tls://… for NATS, kafka+http(s)://[user:pass@]host:port/… for a Kafka
+// REST proxy.
+func Open(rawURL string) (Publisher, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("events: %w", err)
+	}
+	switch u.Scheme {
+	case "nats", "tls":
+		return newNATS(u), nil
+	case "kafka+http", "kafka+https":
+		return newKafkaREST(u), nil
+	}
+	return nil, fmt.Errorf("events: unsupported bus %q, use nats://, tls://, kafka+http:// or kafka+https://", u.Scheme)
+}
+
+// publishTimeout bounds the delivery of one event.
+const publishTimeout = 10 * time.Second
+
+type queue struct {
+	pub    Publisher
+	topic  string
+	events chan Event
+	done   chan struct{}
+	once   sync.Once
+}
+
+// Bus publishes events in the background, in the order they happen. The
+// nil Bus drops everything, so callers don't need to check for it.
+type Bus struct {
+	q      *queue
+	source string
+	tenant string
+}
+
+// NewBus starts publishing to topic. Up to size events wait for delivery;
+// more are dropped.
+func NewBus(pub Publisher, topic, source string, size int) *Bus {
+	q := &queue{
+		pub:    pub,
+		topic:  topic,
+		events: make(chan Event, size),
+		done:   make(chan struct{}),
+	}
+	go q.run()
+	return &Bus{q: q, source: source}
+}
+
+// ForTenant returns a view of the bus that stamps events with the tenant.
+func (b *Bus) ForTenant(id string) *Bus {
+	if b == nil {
+		return nil
+	}
+	return &Bus{q: b.q, source: b.source, tenant: id}
+}
+
+// Publish queues the event without blocking, filling in the envelope.
+func (b *Bus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	e.Schema = SchemaVersion
+	e.ID = uuid.New().String()
+	if e.Time == 0 {
+		e.Time = time.Now().Unix()
+	}
+	e.Source, e.Tenant = b.source, b.tenant
+	select {
+	case b.q.events <- e:
+	default:
+		log.Printf("[ERROR] Event queue full, dropping %s for %s", e.Type, e.File.ID)
+	}
+}
+
+// Close stops accepting events, delivers the queued ones and closes the
+// publisher.
+func (b *Bus) Close() error {
+	if b == nil {
+		return nil
+	}
+	b.q.once.Do(func() { close(b.q.events) })
+	<-b.q.done
+	return b.q.pub.Close()
+}
+
+func (q *queue) run() {
+	defer close(q.done)
+	for e := range q.events {
+		body, err := json.Marshal(e)
+		if err != nil {
+			log.Printf("[ERROR] Failed to encode event %s: %v", e.Type, err)
+			continue
+		}
+		// One retry covers a connection the bus dropped while idle
+		for attempt := 0; attempt < 2; attempt++ {
+			ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+			err = q.pub.Publish(ctx, q.topic, e.File.ID, body)
+			cancel()
+			if err == nil {
+				break
+			}
+		}
+		if err != nil {
+			log.Printf("[ERROR] Failed to publish %s for %s: %v", e.Type, e.File.ID, err)
+		}
+	}
+}
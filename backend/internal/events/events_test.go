@@ -0,0 +1,217 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeNATS is a NATS server that reports what its clients publish.
+func fakeNATS(t *testing.T, token string) (string, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	published := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				fmt.Fprint(conn, "INFO {\"server_id\":\"test\"}\r\n")
+				r := bufio.NewReader(conn)
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					fields := strings.Fields(line)
+					switch {
+					case len(fields) == 0:
+					case fields[0] == "CONNECT":
+						var opts struct {
+							Token string `json:"auth_token"`
+						}
+						json.Unmarshal([]byte(strings.TrimPrefix(strings.TrimSpace(line), "CONNECT ")), &opts)
+						if opts.Token != token {
+							fmt.Fprint(conn, "-ERR 'Authorization Violation'\r\n")
+							return
+						}
+					case fields[0] == "PING":
+						fmt.Fprint(conn, "PONG\r\n")
+					case fields[0] == "PUB" && len(fields) == 3:
+						n, _ := strconv.Atoi(fields[2])
+						body := make([]byte, n+2)
+						if _, err := io.ReadFull(r, body); err != nil {
+							return
+						}
+						published <- fields[1] + " " + string(body[:n])
+					}
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String(), published
+}
+
+func TestNATSPublish(t *testing.T) {
+	addr, published := fakeNATS(t, "s3cret")
+
+	pub, err := Open("nats://s3cret@" + addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bus := NewBus(pub, "depot.events", "https://depot.example.com", 10)
+	bus.Publish(Event{Type: FileUploaded, File: File{ID: "f1", Name: "a.txt"}})
+	bus.ForTenant("acme").Publish(Event{Type: FileDeleted, File: File{ID: "f2"}})
+	if err := bus.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []struct{ typ, tenant string }{{FileUploaded, ""}, {FileDeleted, "acme"}} {
+		msg := <-published
+		subject, body, _ := strings.Cut(msg, " ")
+		var e Event
+		if err := json.Unmarshal([]byte(body), &e); err != nil {
+			t.Fatalf("bad payload %q: %v", body, err)
+		}
+		if subject != "depot.events" || e.Type != want.typ || e.Tenant != want.tenant || e.Schema != SchemaVersion ||
+			e.ID == "" || e.Source != "https://depot.example.com" {
+			t.Errorf("unexpected event on %s: %+v", subject, e)
+		}
+	}
+
+	// A refused login fails the publish
+	bad, _ := Open("nats://wrong@" + addr)
+	defer bad.Close()
+	if err := bad.Publish(context.Background(), "depot.events", "f1", []byte("{}")); err == nil || !strings.Contains(err.Error(), "Authorization Violation") {
+		t.Errorf("expected the server's error, got %v", err)
+	}
+}
+
+func TestKafkaRESTPublish(t *testing.T) {
+	var got struct {
+		Records []struct {
+			Key   string `json:"key"`
+			Value Event  `json:"value"`
+		} `json:"records"`
+	}
+	var path, contentType, user string
+	fail := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, contentType = r.URL.Path, r.Header.Get("Content-Type")
+		user, _, _ = r.BasicAuth()
+		json.NewDecoder(r.Body).Decode(&got)
+		if fail {
+			fmt.Fprint(w, `{"offsets": [{"partition": null, "offset": null, "error_code": 40403, "error": "Topic not found"}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"offsets": [{"partition": 0, "offset": 12}]}`)
+	}))
+	defer srv.Close()
+
+	pub, err := Open(strings.Replace(srv.URL, "http://", "kafka+http://depot:pw@", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pub.Close()
+	body, _ := json.Marshal(Event{Type: FileShared, File: File{ID: "f1"}})
+	if err := pub.Publish(context.Background(), "depot-events", "f1", body); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/topics/depot-events" || contentType != "application/vnd.kafka.json.v2+json" || user != "depot" {
+		t.Errorf("unexpected request to %s (%s) as %q", path, contentType, user)
+	}
+	if len(got.Records) != 1 || got.Records[0].Key != "f1" || got.Records[0].Value.Type != FileShared {
+		t.Errorf("unexpected records %+v", got.Records)
+	}
+
+	fail = true
+	if err := pub.Publish(context.Background(), "missing", "f1", body); err == nil || !strings.Contains(err.Error(), "Topic not found") {
+		t.Errorf("expected the broker's error, got %v", err)
+	}
+}
+
+func TestOpenRejectsUnknownBus(t *testing.T) {
+	if _, err := Open("amqp://localhost"); err == nil {
+		t.Error("expected an unsupported scheme to be refused")
+	}
+	var nilBus *Bus
+	nilBus.Publish(Event{Type: FileUploaded})
+	if err := nilBus.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestConsumerRepliesToMessages(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	replies := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprint(conn, "INFO {}\r\n")
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			fields := strings.Fields(line)
+			switch {
+			case len(fields) == 0:
+			case fields[0] == "PING":
+				fmt.Fprint(conn, "PONG\r\n")
+			case fields[0] == "SUB" && len(fields) == 4 && fields[1] == "depot.commands" && fields[2] == "depot":
+				// A ping from the server must not disturb the message
+				fmt.Fprint(conn, "PING\r\nMSG depot.commands 1 _INBOX.42 5\r\nhello\r\n")
+			case fields[0] == "PUB" && len(fields) == 3:
+				n, _ := strconv.Atoi(fields[2])
+				body := make([]byte, n+2)
+				io.ReadFull(r, body)
+				replies <- fields[1] + " " + string(body[:n])
+			}
+		}
+	}()
+
+	if _, err := NewConsumer("kafka+http://localhost:8082", "depot.commands", "depot"); err == nil {
+		t.Error("expected consuming from Kafka to be refused")
+	}
+	consumer, err := NewConsumer("nats://"+ln.Addr().String(), "depot.commands", "depot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		consumer.Run(ctx, func(m Message) []byte {
+			return []byte(strings.ToUpper(string(m.Body)))
+		})
+	}()
+
+	if reply := <-replies; reply != "_INBOX.42 HELLO" {
+		t.Errorf("unexpected reply %q", reply)
+	}
+	cancel()
+	<-done
+}
@@ -0,0 +1,84 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// kafkaREST produces to Kafka through a REST proxy speaking the v2 API
+// (Confluent REST Proxy, Redpanda's HTTP proxy), which spares the depot a
+// native Kafka client.
+type kafkaREST struct {
+	base     string
+	user     string
+	password string
+	client   *http.Client
+}
+
+func newKafkaREST(u *url.URL) *kafkaREST {
+	k := &kafkaREST{client: &http.Client{Timeout: publishTimeout}}
+	if u.User != nil {
+		k.user = u.User.Username()
+		k.password, _ = u.User.Password()
+	}
+	base := *u
+	base.Scheme = strings.TrimPrefix(u.Scheme, "kafka+")
+	base.User = nil
+	k.base = strings.TrimRight(base.String(), "/")
+	return k
+}
+
+func (k *kafkaREST) Publish(ctx context.Context, topic, key string, body []byte) error {
+	payload, err := json.Marshal(map[string]any{
+		"records": []map[string]any{{"key": key, "value": json.RawMessage(body)}},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, k.base+"/topics/"+url.PathEscape(topic), bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	if k.user != "" {
+		req.SetBasicAuth(k.user, k.password)
+	}
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("kafka: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Offsets []struct {
+			Error string `json:"error"`
+		} `json:"offsets"`
+		Message string `json:"message"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&result)
+	if resp.StatusCode != http.StatusOK {
+		if result.Message != "" {
+			return fmt.Errorf("kafka: %s: %s", resp.Status, result.Message)
+		}
+		return fmt.Errorf("kafka: %s", resp.Status)
+	}
+	// The proxy answers 200 even when the broker refused the record
+	for _, o := range result.Offsets {
+		if o.Error != "" {
+			return fmt.Errorf("kafka: %s", o.Error)
+		}
+	}
+	return nil
+}
+
+func (k *kafkaREST) Close() error {
+	k.client.CloseIdleConnections()
+	return nil
+}
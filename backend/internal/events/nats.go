@@ -0,0 +1,308 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// natsClient speaks the NATS client protocol on one connection. Publish
+// opens it on first use and again after an error, and follows each message
+// with a PING, so it returns once the server has the message.
+type natsClient struct {
+	addr     string
+	tls      bool
+	user     string
+	password string
+	token    string
+
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func newNATS(u *url.URL) *natsClient {
+	p := &natsClient{addr: u.Host, tls: u.Scheme == "tls"}
+	if u.Port() == "" {
+		p.addr = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	if u.User != nil {
+		if pass, ok := u.User.Password(); ok {
+			p.user, p.password = u.User.Username(), pass
+		} else {
+			p.token = u.User.Username()
+		}
+	}
+	return p
+}
+
+func (p *natsClient) Publish(ctx context.Context, topic, key string, body []byte) error {
+	if topic == "" || strings.ContainsAny(topic, " \t\r\n") {
+		return fmt.Errorf("nats: invalid subject %q", topic)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.conn == nil {
+		if err := p.connect(ctx); err != nil {
+			return err
+		}
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(publishTimeout)
+	}
+	p.conn.SetDeadline(deadline)
+
+	msg := fmt.Sprintf("PUB %s %d\r\n%s\r\nPING\r\n", topic, len(body), body)
+	if _, err := p.conn.Write([]byte(msg)); err != nil {
+		p.reset()
+		return fmt.Errorf("nats: %w", err)
+	}
+	if err := p.awaitPong(); err != nil {
+		p.reset()
+		return err
+	}
+	return nil
+}
+
+func (p *natsClient) connect(ctx context.Context) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", p.addr)
+	if err != nil {
+		return fmt.Errorf("nats: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	r := bufio.NewReader(conn)
+	// The server introduces itself before anything else
+	line, err := r.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "INFO ") {
+		conn.Close()
+		return fmt.Errorf("nats: unexpected greeting %q: %v", strings.TrimSpace(line), err)
+	}
+	if p.tls {
+		host, _, _ := net.SplitHostPort(p.addr)
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: host})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return fmt.Errorf("nats: %w", err)
+		}
+		conn, r = tlsConn, bufio.NewReader(tlsConn)
+	}
+
+	options, _ := json.Marshal(map[string]any{
+		"verbose":    false,
+		"pedantic":   false,
+		"name":       "celerix-depot",
+		"lang":       "go",
+		"version":    "1",
+		"user":       p.user,
+		"pass":       p.password,
+		"auth_token": p.token,
+	})
+	if _, err := conn.Write([]byte("CONNECT " + string(options) + "\r\nPING\r\n")); err != nil {
+		conn.Close()
+		return fmt.Errorf("nats: %w", err)
+	}
+	p.conn, p.r = conn, r
+	if err := p.awaitPong(); err != nil {
+		p.reset()
+		return err
+	}
+	return nil
+}
+
+// awaitPong reads until the server answers our PING, answering its own.
+func (p *natsClient) awaitPong() error {
+	for {
+		line, err := p.r.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("nats: %w", err)
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := p.conn.Write([]byte("PONG\r\n")); err != nil {
+				return fmt.Errorf("nats: %w", err)
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return errors.New("nats: " + strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "-ERR")), "'"))
+		}
+		// +OK and INFO updates need no answer
+	}
+}
+
+func (p *natsClient) reset() {
+	if p.conn != nil {
+		p.conn.Close()
+	}
+	p.conn, p.r = nil, nil
+}
+
+func (p *natsClient) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.reset()
+	return nil
+}
+
+// Message is a message received on a subscription. Reply is the subject
+// the sender waits on for an answer, empty when it doesn't.
+type Message struct {
+	Subject string
+	Reply   string
+	Body    []byte
+}
+
+// natsIdleTimeout drops a subscription that heard nothing, not even the
+// server's pings, for this long.
+const natsIdleTimeout = 5 * time.Minute
+
+// Consumer receives messages from a NATS subject. Consumers in the same
+// queue group share the messages, each going to one of them.
+type Consumer struct {
+	url     *url.URL
+	subject string
+	queue   string
+}
+
+// NewConsumer checks that messages can be consumed from the bus at rawURL,
+// which has to be a NATS server.
+func NewConsumer(rawURL, subject, queue string) (*Consumer, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("events: %w", err)
+	}
+	if u.Scheme != "nats" && u.Scheme != "tls" {
+		return nil, fmt.Errorf("events: messages can only be consumed from NATS, not %q", u.Scheme)
+	}
+	if subject == "" || strings.ContainsAny(subject, " \t\r\n") || strings.ContainsAny(queue, " \t\r\n") {
+		return nil, fmt.Errorf("events: invalid subject %q", subject)
+	}
+	return &Consumer{url: u, subject: subject, queue: queue}, nil
+}
+
+// Run hands each message to handle, one at a time and in order, until ctx
+// is done, subscribing again whenever the connection is lost. What handle
+// returns is sent to the message's reply subject. Messages published while
+// no connection is up are missed, as NATS doesn't keep them.
+func (c *Consumer) Run(ctx context.Context, handle func(Message) []byte) {
+	backoff := time.Second
+	for {
+		started := time.Now()
+		err := newNATS(c.url).consume(ctx, c.subject, c.queue, handle)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("[ERROR] Subscription to %s lost: %v", c.subject, err)
+		if time.Since(started) > time.Minute {
+			backoff = time.Second
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, time.Minute)
+	}
+}
+
+func (p *natsClient) consume(ctx context.Context, subject, queue string, handle func(Message) []byte) error {
+	dialCtx, cancel := context.WithTimeout(ctx, publishTimeout)
+	err := p.connect(dialCtx)
+	cancel()
+	if err != nil {
+		return err
+	}
+	conn := p.conn
+	defer p.Close()
+	conn.SetDeadline(time.Time{})
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	sub := "SUB " + subject
+	if queue != "" {
+		sub += " " + queue
+	}
+	if err := p.write(sub + " 1\r\n"); err != nil {
+		return err
+	}
+
+	// Reading goes on while a message is handled, so pings are answered
+	msgs := make(chan Message, 256)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- p.read(msgs)
+		close(msgs)
+	}()
+	for m := range msgs {
+		reply := handle(m)
+		if m.Reply != "" && reply != nil {
+			if err := p.write(fmt.Sprintf("PUB %s %d\r\n%s\r\n", m.Reply, len(reply), reply)); err != nil {
+				log.Printf("[ERROR] Failed to reply on %s: %v", m.Reply, err)
+			}
+		}
+	}
+	return <-errc
+}
+
+// read delivers the messages of the subscription until the connection
+// fails.
+func (p *natsClient) read(msgs chan<- Message) error {
+	for {
+		p.conn.SetReadDeadline(time.Now().Add(natsIdleTimeout))
+		line, err := p.r.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("nats: %w", err)
+		}
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+		case fields[0] == "PING":
+			if err := p.write("PONG\r\n"); err != nil {
+				return err
+			}
+		case fields[0] == "-ERR":
+			return errors.New("nats: " + strings.Trim(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "-ERR")), "'"))
+		case fields[0] == "MSG" && (len(fields) == 4 || len(fields) == 5):
+			// MSG <subject> <sid> [reply-to] <#bytes>
+			n, err := strconv.Atoi(fields[len(fields)-1])
+			if err != nil || n < 0 {
+				return fmt.Errorf("nats: malformed %q", strings.TrimSpace(line))
+			}
+			body := make([]byte, n+2)
+			if _, err := io.ReadFull(p.r, body); err != nil {
+				return fmt.Errorf("nats: %w", err)
+			}
+			m := Message{Subject: fields[1], Body: body[:n]}
+			if len(fields) == 5 {
+				m.Reply = fields[3]
+			}
+			msgs <- m
+		}
+	}
+}
+
+func (p *natsClient) write(s string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, err := p.conn.Write([]byte(s)); err != nil {
+		return fmt.Errorf("nats: %w", err)
+	}
+	return nil
+}
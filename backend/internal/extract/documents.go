@@ -0,0 +1,131 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"encoding/xml"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// zipXMLText concatenates the character data of the XML parts selected by
+// match, which covers OOXML and OpenDocument formats.
+func zipXMLText(path string, match func(string) bool) (string, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return "", err
+	}
+	defer zr.Close()
+
+	var sb strings.Builder
+	for _, f := range zr.File {
+		if !match(f.Name) {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return "", err
+		}
+		err = xmlText(io.LimitReader(rc, 8*MaxTextSize), &sb)
+		rc.Close()
+		if err != nil {
+			return "", err
+		}
+		if sb.Len() >= MaxTextSize {
+			break
+		}
+	}
+	return sb.String(), nil
+}
+
+func xmlText(r io.Reader, sb *strings.Builder) error {
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.CharData:
+			sb.Write(t)
+		case xml.EndElement:
+			// Paragraphs, table cells and shared strings end a run of text
+			switch t.Name.Local {
+			case "p", "si", "tc", "h":
+				sb.WriteByte('\n')
+			}
+		}
+	}
+}
+
+var (
+	pdfStream   = regexp.MustCompile(`(?s)stream\r?\n(.*?)\r?\nendstream`)
+	pdfTextShow = regexp.MustCompile(`\((?:\\.|[^\\)])*\)\s*Tj|\[(?:[^\]]*)\]\s*TJ`)
+	pdfLiteral  = regexp.MustCompile(`\((?:\\.|[^\\)])*\)`)
+)
+
+// maxPDFSize is how much of a PDF pdfText reads; the text of larger ones
+// comes from their start.
+const maxPDFSize = 64 << 20
+
+// pdfText is a best-effort extractor that pulls literal strings out of text
+// showing operators in (optionally Flate compressed) content streams. It
+// doesn't handle custom font encodings, but covers most generated PDFs.
+func pdfText(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxPDFSize))
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	for _, m := range pdfStream.FindAllSubmatch(data, -1) {
+		content := m[1]
+		if zr, err := zlib.NewReader(bytes.NewReader(content)); err == nil {
+			if inflated, err := io.ReadAll(io.LimitReader(zr, 8*MaxTextSize)); err == nil {
+				content = inflated
+			}
+			zr.Close()
+		}
+		for _, op := range pdfTextShow.FindAll(content, -1) {
+			for _, lit := range pdfLiteral.FindAll(op, -1) {
+				sb.WriteString(unescapePDF(lit[1 : len(lit)-1]))
+			}
+			sb.WriteByte(' ')
+		}
+		if sb.Len() >= MaxTextSize {
+			break
+		}
+	}
+	return sb.String(), nil
+}
+
+func unescapePDF(b []byte) string {
+	var sb strings.Builder
+	for i := 0; i < len(b); i++ {
+		if b[i] != '\\' || i+1 >= len(b) {
+			sb.WriteByte(b[i])
+			continue
+		}
+		i++
+		switch b[i] {
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r', 't':
+			sb.WriteByte(' ')
+		default:
+			sb.WriteByte(b[i])
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,81 @@
+package extract
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxTextSize caps how much text is kept per file.
+const MaxTextSize = 1 << 20
+
+var textExtensions = map[string]bool{
+	".txt": true, ".md": true, ".csv": true, ".tsv": true, ".json": true, ".xml": true,
+	".yaml": true, ".yml": true, ".toml": true, ".ini": true, ".log": true, ".html": true,
+	".go": true, ".py": true, ".js": true, ".ts": true, ".java": true, ".c": true, ".h": true,
+	".cpp": true, ".rs": true, ".rb": true, ".php": true, ".sh": true, ".sql": true, ".css": true,
+}
+
+// Text returns searchable text for the file, or an empty string when the
+// format isn't supported.
+func Text(path, name string) (string, error) {
+	ext := strings.ToLower(filepath.Ext(name))
+
+	var text string
+	var err error
+	switch ext {
+	case ".pdf":
+		text, err = pdfText(path)
+	case ".docx", ".docm":
+		text, err = zipXMLText(path, func(n string) bool { return n == "word/document.xml" })
+	case ".xlsx", ".xlsm":
+		text, err = zipXMLText(path, func(n string) bool { return n == "xl/sharedStrings.xml" })
+	case ".pptx", ".pptm":
+		text, err = zipXMLText(path, func(n string) bool {
+			return strings.HasPrefix(n, "ppt/slides/slide") && strings.HasSuffix(n, ".xml")
+		})
+	case ".odt", ".ods", ".odp":
+		text, err = zipXMLText(path, func(n string) bool { return n == "content.xml" })
+	default:
+		text, err = plainText(path, ext)
+	}
+	if err != nil {
+		return "", err
+	}
+	return truncate(strings.TrimSpace(text)), nil
+}
+
+func plainText(path, ext string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, MaxTextSize))
+	if err != nil {
+		return "", err
+	}
+	if !textExtensions[ext] && !strings.HasPrefix(http.DetectContentType(data), "text/") {
+		return "", nil
+	}
+	if !utf8.Valid(data) {
+		data = bytes.ToValidUTF8(data, []byte(" "))
+	}
+	return string(data), nil
+}
+
+func truncate(s string) string {
+	if len(s) <= MaxTextSize {
+		return s
+	}
+	s = s[:MaxTextSize]
+	for !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,51 @@
+package extract
+
+import (
+	"bytes"
+	"compress/zlib"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPDFText(t *testing.T) {
+	var content bytes.Buffer
+	zw := zlib.NewWriter(&content)
+	zw.Write([]byte(`BT /F1 12 Tf (Quarterly \(draft\)) Tj [(num) -20 (bers)] TJ ET`))
+	zw.Close()
+
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.4\n1 0 obj << /Filter /FlateDecode >>\nstream\n")
+	pdf.Write(content.Bytes())
+	pdf.WriteString("\nendstream\nendobj\n2 0 obj << >>\nstream\nBT (plain text) Tj ET\nendstream\nendobj\n%%EOF\n")
+	path := filepath.Join(t.TempDir(), "report")
+	if err := os.WriteFile(path, pdf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	text, err := Text(path, "report.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Quarterly (draft)", "numbers", "plain text"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected %q in %q", want, text)
+		}
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	dir := t.TempDir()
+	notes := filepath.Join(dir, "notes")
+	os.WriteFile(notes, []byte("  meeting notes\n"), 0644)
+	if text, err := Text(notes, "notes.md"); err != nil || text != "meeting notes" {
+		t.Errorf("expected the trimmed text, got %q, %v", text, err)
+	}
+
+	binary := filepath.Join(dir, "binary")
+	os.WriteFile(binary, []byte{0x00, 0x01, 0x02, 0xFF}, 0644)
+	if text, err := Text(binary, "blob.bin"); err != nil || text != "" {
+		t.Errorf("expected no text from binary content, got %q, %v", text, err)
+	}
+}
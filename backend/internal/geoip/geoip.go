@@ -0,0 +1,102 @@
+// Package geoip maps IP addresses to country codes using a range database in
+// CSV form, one "start_ip,end_ip,country_code" row per range. This is the
+// layout of the free DB-IP "IP to Country Lite" download, and both IPv4 and
+// IPv6 rows are accepted.
+package geoip
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+)
+
+type ipRange struct {
+	start, end netip.Addr
+	country    string
+}
+
+// DB is an immutable, sorted set of ranges. A nil DB knows no addresses.
+type DB struct {
+	ranges []ipRange
+}
+
+// Open loads a database from a CSV file.
+func Open(path string) (*DB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Load(f)
+}
+
+// Load reads a database in CSV form. Malformed rows are an error so that a
+// wrong file is noticed at startup rather than silently matching nothing.
+func Load(r io.Reader) (*DB, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+
+	db := &DB{}
+	for line := 1; ; line++ {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rec) < 3 {
+			return nil, fmt.Errorf("geoip: line %d: expected start, end and country", line)
+		}
+		start, err1 := netip.ParseAddr(strings.TrimSpace(rec[0]))
+		end, err2 := netip.ParseAddr(strings.TrimSpace(rec[1]))
+		if err1 != nil || err2 != nil || start.Is4() != end.Is4() || end.Less(start) {
+			// Allow a header row
+			if line == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("geoip: line %d: invalid range", line)
+		}
+		db.ranges = append(db.ranges, ipRange{start: start, end: end, country: strings.ToUpper(strings.TrimSpace(rec[2]))})
+	}
+
+	sort.Slice(db.ranges, func(i, j int) bool { return db.ranges[i].start.Less(db.ranges[j].start) })
+	return db, nil
+}
+
+// Len returns the number of ranges.
+func (db *DB) Len() int {
+	if db == nil {
+		return 0
+	}
+	return len(db.ranges)
+}
+
+// Country returns the ISO country code of ip, or "" when it is unknown.
+func (db *DB) Country(ip string) string {
+	if db == nil {
+		return ""
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ""
+	}
+	addr = addr.Unmap()
+
+	// The last range starting at or before addr is the only candidate
+	i := sort.Search(len(db.ranges), func(i int) bool { return addr.Less(db.ranges[i].start) }) - 1
+	if i < 0 {
+		return ""
+	}
+	r := db.ranges[i]
+	if r.start.Is4() != addr.Is4() || r.end.Less(addr) {
+		return ""
+	}
+	return r.country
+}
@@ -0,0 +1,40 @@
+package geoip
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCountry(t *testing.T) {
+	db, err := Load(strings.NewReader(`ip_start,ip_end,country
+1.0.0.0,1.0.0.255,au
+8.8.8.0,8.8.8.255,US
+2001:db8::,2001:db8::ffff,NL
+`))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	cases := map[string]string{
+		"1.0.0.7":        "AU",
+		"8.8.8.8":        "US",
+		"::ffff:8.8.8.8": "US",
+		"8.8.9.1":        "",
+		"2001:db8::42":   "NL",
+		"2001:db9::1":    "",
+		"not an ip":      "",
+		"0.0.0.1":        "",
+	}
+	for ip, want := range cases {
+		if got := db.Country(ip); got != want {
+			t.Errorf("Country(%q) = %q, want %q", ip, got, want)
+		}
+	}
+
+	if _, err := Load(strings.NewReader("1.0.0.0,1.0.0.255,AU\nbroken,row,XX\n")); err == nil {
+		t.Error("expected malformed row to fail")
+	}
+	var nilDB *DB
+	if nilDB.Country("8.8.8.8") != "" {
+		t.Error("expected nil DB to know nothing")
+	}
+}
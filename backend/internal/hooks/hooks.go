@@ -0,0 +1,157 @@
+// Package hooks lets operators extend the upload and download paths without
+// changing the handlers. Hooks are either Go values registered by an
+// embedding program or executables loaded from a directory at startup.
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// Upload describes a file on its way into the depot. Path points at the
+// staged content on the local disk, or at an s3:// object.
+type Upload struct {
+	FileID   string `json:"file_id"`
+	OwnerID  string `json:"owner_id"`
+	Name     string `json:"name"`
+	Size     int64  `json:"size"`
+	SHA256   string `json:"sha256"`
+	Path     string `json:"path"`
+	IsPublic bool   `json:"is_public"`
+	IsAdmin  bool   `json:"is_admin"`
+}
+
+// Download describes a request to fetch a stored file.
+type Download struct {
+	FileID     string `json:"file_id"`
+	OwnerID    string `json:"owner_id"`
+	Name       string `json:"name"`
+	ClientID   string `json:"client_id"`
+	RemoteAddr string `json:"remote_addr"`
+	IsAdmin    bool   `json:"is_admin"`
+}
+
+// PreUploader validates an upload before it is recorded. Returning an error
+// rejects the upload.
+type PreUploader interface {
+	PreUpload(ctx context.Context, u Upload) error
+}
+
+// PostUploader processes an upload after it has been recorded. It runs in the
+// background, so errors are only logged.
+type PostUploader interface {
+	PostUpload(ctx context.Context, u Upload) error
+}
+
+// DownloadAuthorizer decides whether a download may proceed. Returning an
+// error denies it.
+type DownloadAuthorizer interface {
+	PreDownload(ctx context.Context, d Download) error
+}
+
+// Rejection is returned when a hook refuses an operation. Reason is safe to
+// show to the client.
+type Rejection struct {
+	Hook   string
+	Reason string
+}
+
+func (r *Rejection) Error() string {
+	return fmt.Sprintf("rejected by hook %s: %s", r.Hook, r.Reason)
+}
+
+// Registry holds the hooks in registration order. The nil Registry has no
+// hooks, so callers don't need to check for it.
+type Registry struct {
+	mu         sync.RWMutex
+	preUpload  []PreUploader
+	postUpload []PostUploader
+	download   []DownloadAuthorizer
+}
+
+// Register adds h for every hook interface it implements and reports
+// whether it implements any.
+func (r *Registry) Register(h any) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ok := false
+	if p, is := h.(PreUploader); is {
+		r.preUpload = append(r.preUpload, p)
+		ok = true
+	}
+	if p, is := h.(PostUploader); is {
+		r.postUpload = append(r.postUpload, p)
+		ok = true
+	}
+	if d, is := h.(DownloadAuthorizer); is {
+		r.download = append(r.download, d)
+		ok = true
+	}
+	return ok
+}
+
+// PreUpload runs the pre-upload hooks and stops at the first rejection.
+func (r *Registry) PreUpload(ctx context.Context, u Upload) error {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, h := range r.preUpload {
+		if err := h.PreUpload(ctx, u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HasPostUpload reports whether any post-upload hooks are registered.
+func (r *Registry) HasPreUpload() bool {
+	if r == nil {
+		return false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.preUpload) > 0
+}
+
+func (r *Registry) HasPostUpload() bool {
+	if r == nil {
+		return false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.postUpload) > 0
+}
+
+// PostUpload runs every post-upload hook, logging failures.
+func (r *Registry) PostUpload(ctx context.Context, u Upload) {
+	if r == nil {
+		return
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, h := range r.postUpload {
+		if err := h.PostUpload(ctx, u); err != nil {
+			log.Printf("[ERROR] Post-upload hook failed for %s: %v", u.FileID, err)
+		}
+	}
+}
+
+// PreDownload runs the download authorizers and stops at the first denial.
+func (r *Registry) PreDownload(ctx context.Context, d Download) error {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, h := range r.download {
+		if err := h.PreDownload(ctx, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package hooks
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type denyNames struct{ name string }
+
+func (d denyNames) PreUpload(ctx context.Context, u Upload) error {
+	if u.Name == d.name {
+		return &Rejection{Hook: "deny", Reason: "name is blocked"}
+	}
+	return nil
+}
+
+func TestRegistryRunsGoHooks(t *testing.T) {
+	var nilRegistry *Registry
+	if err := nilRegistry.PreUpload(context.Background(), Upload{}); err != nil {
+		t.Fatalf("nil registry should allow everything, got %v", err)
+	}
+
+	r := &Registry{}
+	if !r.Register(denyNames{name: "evil.exe"}) {
+		t.Fatal("expected hook to be registered")
+	}
+	if r.Register(struct{}{}) {
+		t.Fatal("value without hook methods should not be registered")
+	}
+
+	if err := r.PreUpload(context.Background(), Upload{Name: "fine.txt"}); err != nil {
+		t.Errorf("expected allowed upload, got %v", err)
+	}
+	var rej *Rejection
+	if err := r.PreUpload(context.Background(), Upload{Name: "evil.exe"}); !errors.As(err, &rej) {
+		t.Errorf("expected rejection, got %v", err)
+	}
+}
+
+func TestScriptHooks(t *testing.T) {
+	dir := t.TempDir()
+	script := "#!/bin/sh\n" +
+		"read payload\n" +
+		"case \"$payload\" in *secret*) echo \"no secrets please\"; exit 1;; esac\n"
+	if err := os.WriteFile(filepath.Join(dir, EventPreUpload), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, EventPreDownload), []byte("#!/bin/sh\nexec sleep 5\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &Registry{}
+	n, err := r.LoadDir(dir, 200*time.Millisecond)
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 hooks, got %d (%v)", n, err)
+	}
+
+	if err := r.PreUpload(context.Background(), Upload{Name: "report.pdf"}); err != nil {
+		t.Errorf("expected upload to be allowed, got %v", err)
+	}
+
+	var rej *Rejection
+	err = r.PreUpload(context.Background(), Upload{Name: "secret.pdf"})
+	if !errors.As(err, &rej) || rej.Reason != "no secrets please" {
+		t.Errorf("expected rejection with reason, got %v", err)
+	}
+
+	// A hung hook fails closed with a plain error rather than a rejection
+	err = r.PreDownload(context.Background(), Download{FileID: "f1"})
+	if err == nil || errors.As(err, &rej) {
+		t.Errorf("expected timeout error, got %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Script event names double as the file names LoadDir looks for.
+const (
+	EventPreUpload   = "pre-upload"
+	EventPostUpload  = "post-upload"
+	EventPreDownload = "pre-download"
+)
+
+// Script runs an external program for a hook event. The event payload is
+// written to stdin as JSON. Exit status 0 allows the operation; any other
+// status rejects it, using the first line of stdout as the reason.
+type Script struct {
+	Path    string
+	Event   string
+	Timeout time.Duration
+}
+
+func (s *Script) run(ctx context.Context, payload any) error {
+	body, err := json.Marshal(map[string]any{"event": s.Event, "data": payload})
+	if err != nil {
+		return err
+	}
+
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, s.Path)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.Env = append(os.Environ(), "DEPOT_HOOK_EVENT="+s.Event)
+	// Don't wait forever on children of the hook that keep stdout open
+	cmd.WaitDelay = time.Second
+
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && ctx.Err() == nil {
+		reason, _, _ := strings.Cut(strings.TrimSpace(stdout.String()), "\n")
+		if reason == "" {
+			reason = "denied"
+		}
+		return &Rejection{Hook: filepath.Base(s.Path), Reason: reason}
+	}
+	if err != nil {
+		return fmt.Errorf("hook %s: %w (%s)", s.Path, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+type preUploadScript struct{ *Script }
+
+func (s preUploadScript) PreUpload(ctx context.Context, u Upload) error { return s.run(ctx, u) }
+
+type postUploadScript struct{ *Script }
+
+func (s postUploadScript) PostUpload(ctx context.Context, u Upload) error { return s.run(ctx, u) }
+
+type preDownloadScript struct{ *Script }
+
+func (s preDownloadScript) PreDownload(ctx context.Context, d Download) error { return s.run(ctx, d) }
+
+// LoadDir registers the executables in dir named after a hook event, in the
+// style of git hooks. Missing events are skipped.
+func (r *Registry) LoadDir(dir string, timeout time.Duration) (int, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return 0, err
+	}
+
+	loaded := 0
+	for _, event := range []string{EventPreUpload, EventPostUpload, EventPreDownload} {
+		path := filepath.Join(dir, event)
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if info.IsDir() || info.Mode()&0111 == 0 {
+			return loaded, fmt.Errorf("hook %s is not executable", path)
+		}
+
+		s := &Script{Path: path, Event: event, Timeout: timeout}
+		switch event {
+		case EventPreUpload:
+			r.Register(preUploadScript{s})
+		case EventPostUpload:
+			r.Register(postUploadScript{s})
+		case EventPreDownload:
+			r.Register(preDownloadScript{s})
+		}
+		loaded++
+	}
+	return loaded, nil
+}
@@ -0,0 +1,118 @@
+// Package inbox imports files dropped into a directory, for scanners and
+// other programs that can only write to a folder.
+package inbox
+
+import (
+	"context"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FailedDir is where files the depot refused are moved, inside the inbox.
+const FailedDir = ".failed"
+
+// incomplete are suffixes of files that are still being written.
+var incomplete = []string{".part", ".partial", ".tmp", ".crdownload", ".download"}
+
+// Watcher polls an inbox directory. A file is imported once its size and
+// modification time stayed the same for a whole poll, so a slow writer is
+// never read halfway through.
+type Watcher struct {
+	Dir string
+	// Deliver imports the file at path. folder is its directory relative
+	// to the inbox, empty for the root. The watcher deletes the file when
+	// Deliver succeeds and moves it to FailedDir when it fails.
+	Deliver func(path, name, folder string) error
+
+	mu   sync.Mutex
+	seen map[string]fileState
+}
+
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// Poll imports the files that settled since the last poll.
+func (w *Watcher) Poll(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	current := make(map[string]fileState)
+	err := filepath.WalkDir(w.Dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if strings.HasPrefix(d.Name(), ".") && p != w.Dir {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || isIncomplete(d.Name()) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			// Gone since the directory was read
+			return nil
+		}
+		state := fileState{size: info.Size(), modTime: info.ModTime()}
+		if prev, ok := w.seen[p]; !ok || prev != state {
+			current[p] = state
+			return nil
+		}
+		w.deliver(p)
+		return nil
+	})
+	w.seen = current
+	return err
+}
+
+func (w *Watcher) deliver(p string) {
+	rel, _ := filepath.Rel(w.Dir, p)
+	folder := path.Dir(filepath.ToSlash(rel))
+	if folder == "." {
+		folder = ""
+	}
+	if err := w.Deliver(p, filepath.Base(p), folder); err != nil {
+		log.Printf("[WARN] Inbox could not import %s: %v", rel, err)
+		w.fail(p, rel)
+		return
+	}
+	if err := os.Remove(p); err != nil {
+		log.Printf("[ERROR] Inbox imported %s but could not remove it: %v", rel, err)
+	}
+}
+
+// fail moves a refused file aside, keeping its place in the inbox tree, so
+// it isn't imported again on every poll.
+func (w *Watcher) fail(p, rel string) {
+	target := filepath.Join(w.Dir, FailedDir, rel)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err == nil {
+		err = os.Rename(p, target)
+		if err == nil {
+			return
+		}
+	}
+	log.Printf("[ERROR] Inbox could not move %s to %s", rel, FailedDir)
+}
+
+func isIncomplete(name string) bool {
+	lower := strings.ToLower(name)
+	for _, suffix := range incomplete {
+		if strings.HasSuffix(lower, suffix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package inbox
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWatcherImportsSettledFiles(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "scans"), 0755)
+	os.WriteFile(filepath.Join(dir, "scans", "page1.pdf"), []byte("%PDF-1.4"), 0644)
+	os.WriteFile(filepath.Join(dir, "bad.exe"), []byte("MZ"), 0644)
+	os.WriteFile(filepath.Join(dir, "still-writing.pdf.part"), []byte("%PD"), 0644)
+
+	type delivery struct{ name, folder, content string }
+	var delivered []delivery
+	w := &Watcher{Dir: dir, Deliver: func(path, name, folder string) error {
+		if name == "bad.exe" {
+			return errors.New("rejected")
+		}
+		content, _ := os.ReadFile(path)
+		delivered = append(delivered, delivery{name, folder, string(content)})
+		return nil
+	}}
+
+	// The first poll only notes the files
+	if err := w.Poll(context.Background()); err != nil {
+		t.Fatalf("Poll failed: %v", err)
+	}
+	if len(delivered) != 0 {
+		t.Fatalf("expected nothing to be imported before files settle, got %+v", delivered)
+	}
+
+	if err := w.Poll(context.Background()); err != nil {
+		t.Fatalf("Poll failed: %v", err)
+	}
+	if len(delivered) != 1 || delivered[0] != (delivery{"page1.pdf", "scans", "%PDF-1.4"}) {
+		t.Fatalf("expected the settled file to be imported into its folder, got %+v", delivered)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "scans", "page1.pdf")); !os.IsNotExist(err) {
+		t.Errorf("expected the imported file to be removed from the inbox")
+	}
+	if _, err := os.Stat(filepath.Join(dir, FailedDir, "bad.exe")); err != nil {
+		t.Errorf("expected the refused file to be moved aside: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "still-writing.pdf.part")); err != nil {
+		t.Errorf("expected the partial file to be left alone: %v", err)
+	}
+
+	// Refused files aren't tried again
+	w.Poll(context.Background())
+	w.Poll(context.Background())
+	if len(delivered) != 1 {
+		t.Errorf("expected no further imports, got %+v", delivered)
+	}
+}
@@ -0,0 +1,79 @@
+package jobs
+
+import (
+	"errors"
+	"log"
+	"sync"
+)
+
+var (
+	// ErrQueueFull is returned by Submit when the task was dropped
+	ErrQueueFull = errors.New("jobs: queue full")
+	// ErrClosed is returned by Submit after Close
+	ErrClosed = errors.New("jobs: queue closed")
+)
+
+type task struct {
+	name string
+	fn   func() error
+}
+
+// Queue runs background tasks on a fixed pool of workers.
+type Queue struct {
+	tasks chan task
+	wg    sync.WaitGroup
+
+	// mu keeps Submit from sending on the channel Close closes
+	mu     sync.RWMutex
+	closed bool
+}
+
+func NewQueue(workers, size int) *Queue {
+	if workers < 1 {
+		workers = 1
+	}
+	q := &Queue{tasks: make(chan task, size)}
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+	return q
+}
+
+func (q *Queue) work() {
+	defer q.wg.Done()
+	for t := range q.tasks {
+		if err := t.fn(); err != nil {
+			log.Printf("[ERROR] Job %s failed: %v", t.name, err)
+		}
+	}
+}
+
+// Submit enqueues a task without blocking. The task is dropped with
+// ErrQueueFull when the queue is full, and with ErrClosed after Close.
+func (q *Queue) Submit(name string, fn func() error) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		log.Printf("[ERROR] Job queue closed, dropping %s", name)
+		return ErrClosed
+	}
+	select {
+	case q.tasks <- task{name: name, fn: fn}:
+		return nil
+	default:
+		log.Printf("[ERROR] Job queue full, dropping %s", name)
+		return ErrQueueFull
+	}
+}
+
+// Close stops accepting work and waits for queued tasks to finish.
+func (q *Queue) Close() {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.tasks)
+	}
+	q.mu.Unlock()
+	q.wg.Wait()
+}
@@ -0,0 +1,41 @@
+package jobs
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+func TestQueue(t *testing.T) {
+	q := NewQueue(2, 4)
+	var ran atomic.Int32
+	for i := 0; i < 3; i++ {
+		if err := q.Submit("count", func() error { ran.Add(1); return nil }); err != nil {
+			t.Fatalf("Submit: %v", err)
+		}
+	}
+	q.Close()
+	if ran.Load() != 3 {
+		t.Errorf("expected the queued tasks to run before Close returns, ran %d", ran.Load())
+	}
+
+	// Late work is refused instead of panicking on the closed channel
+	if err := q.Submit("late", func() error { return nil }); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed after Close, got %v", err)
+	}
+	q.Close()
+}
+
+func TestQueueFull(t *testing.T) {
+	q := NewQueue(1, 1)
+	block := make(chan struct{})
+	started := make(chan struct{})
+	q.Submit("busy", func() error { close(started); <-block; return nil })
+	<-started
+	q.Submit("queued", func() error { return nil })
+	if err := q.Submit("dropped", func() error { return nil }); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("expected ErrQueueFull, got %v", err)
+	}
+	close(block)
+	q.Close()
+}
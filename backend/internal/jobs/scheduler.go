@@ -0,0 +1,58 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Scheduler runs named tasks periodically until it is stopped.
+type Scheduler struct {
+	// Guard, when set, is asked before each run, which is skipped when it
+	// returns false. Replicas use it so only one of them runs a task per
+	// interval.
+	Guard func(name string, interval time.Duration) bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewScheduler() *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{ctx: ctx, cancel: cancel}
+}
+
+// Every runs fn once per interval. A task never overlaps with itself; the
+// context is cancelled when the scheduler stops.
+func (s *Scheduler) Every(name string, interval time.Duration, fn func(ctx context.Context) error) {
+	if interval <= 0 {
+		return
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+				if s.Guard != nil && !s.Guard(name, interval) {
+					continue
+				}
+				if err := fn(s.ctx); err != nil && s.ctx.Err() == nil {
+					log.Printf("[ERROR] Scheduled job %s failed: %v", name, err)
+				}
+			}
+		}
+	}()
+}
+
+// Stop cancels running tasks and waits for them to return.
+func (s *Scheduler) Stop() {
+	s.cancel()
+	s.wg.Wait()
+}
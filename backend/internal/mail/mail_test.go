@@ -0,0 +1,96 @@
+package mail
+
+import (
+	"net"
+	"net/smtp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const testMessage = "From: Alice <alice@example.com>\r\n" +
+	"To: reports@depot.test\r\n" +
+	"Subject: =?UTF-8?Q?Q3_r=C3=A9sum=C3=A9?=\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/mixed; boundary=outer\r\n" +
+	"\r\n" +
+	"--outer\r\n" +
+	"Content-Type: multipart/alternative; boundary=inner\r\n" +
+	"\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"\r\n" +
+	"See attached.\r\n" +
+	"--inner--\r\n" +
+	"--outer\r\n" +
+	"Content-Type: text/csv; name=\"q3.csv\"\r\n" +
+	"Content-Disposition: attachment; filename=\"q3.csv\"\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"YSxiCjEs\r\n" +
+	"Mgo=\r\n" +
+	"--outer\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"Content-Disposition: attachment; filename=\"../../notes.txt\"\r\n" +
+	"Content-Transfer-Encoding: quoted-printable\r\n" +
+	"\r\n" +
+	"caf=C3=A9\r\n" +
+	"--outer--\r\n"
+
+func TestParseCollectsAttachments(t *testing.T) {
+	msg, err := Parse(strings.NewReader(testMessage))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if msg.From != "alice@example.com" || msg.Subject != "Q3 résumé" {
+		t.Errorf("unexpected headers: %q %q", msg.From, msg.Subject)
+	}
+	if len(msg.Attachments) != 2 {
+		t.Fatalf("expected 2 attachments, got %d", len(msg.Attachments))
+	}
+	if a := msg.Attachments[0]; a.Name != "q3.csv" || string(a.Data) != "a,b\n1,2\n" {
+		t.Errorf("unexpected first attachment %q %q", a.Name, a.Data)
+	}
+	if a := msg.Attachments[1]; a.Name != "notes.txt" || string(a.Data) != "café" {
+		t.Errorf("unexpected second attachment %q %q", a.Name, a.Data)
+	}
+}
+
+func TestServerDelivers(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var got []*Message
+	s := &Server{
+		Domain: "depot.test",
+		Accept: func(rcpt string) bool { return LocalPart(rcpt) == "reports" },
+		Deliver: func(msg *Message) error {
+			mu.Lock()
+			got = append(got, msg)
+			mu.Unlock()
+			return nil
+		},
+	}
+	go s.Serve(ln)
+	defer s.Close()
+
+	addr := ln.Addr().String()
+	if err := smtp.SendMail(addr, nil, "alice@example.com", []string{"reports@depot.test"}, []byte(testMessage)); err != nil {
+		t.Fatalf("SendMail failed: %v", err)
+	}
+	if err := smtp.SendMail(addr, nil, "alice@example.com", []string{"nobody@depot.test"}, []byte(testMessage)); err == nil {
+		t.Error("expected unknown mailbox to be refused")
+	}
+	if err := smtp.SendMail(addr, nil, "alice@example.com", []string{"reports@elsewhere.test"}, []byte(testMessage)); err == nil {
+		t.Error("expected relaying to be refused")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 1 || len(got[0].Attachments) != 2 || got[0].To[0] != "reports@depot.test" {
+		t.Fatalf("unexpected deliveries %+v", got)
+	}
+}
@@ -0,0 +1,127 @@
+// Package mail receives email over SMTP and extracts its attachments.
+package mail
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"path/filepath"
+	"strings"
+)
+
+// Attachment is a file carried by a message.
+type Attachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// Message is a received email reduced to what the depot needs.
+type Message struct {
+	From        string
+	To          []string
+	Subject     string
+	Attachments []Attachment
+}
+
+var wordDecoder = new(mime.WordDecoder)
+
+// Parse reads an RFC 5322 message and collects its attachments, descending
+// into nested multipart bodies.
+func Parse(r io.Reader) (*Message, error) {
+	m, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &Message{}
+	if from, err := mail.ParseAddress(m.Header.Get("From")); err == nil {
+		msg.From = from.Address
+	} else {
+		msg.From = m.Header.Get("From")
+	}
+	msg.Subject, err = wordDecoder.DecodeHeader(m.Header.Get("Subject"))
+	if err != nil {
+		msg.Subject = m.Header.Get("Subject")
+	}
+
+	header := map[string][]string(m.Header)
+	if err := collect(msg, header, m.Body); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func collect(msg *Message, header map[string][]string, body io.Reader) error {
+	get := func(k string) string {
+		if v := header[k]; len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+
+	mediaType, params, err := mime.ParseMediaType(get("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := collect(msg, part.Header, part); err != nil {
+				return err
+			}
+		}
+	}
+
+	name := attachmentName(get("Content-Disposition"), params)
+	if name == "" {
+		// Inline text and HTML bodies are not files
+		return nil
+	}
+
+	data, err := io.ReadAll(decode(get("Content-Transfer-Encoding"), body))
+	if err != nil {
+		return fmt.Errorf("decode attachment %s: %w", name, err)
+	}
+	msg.Attachments = append(msg.Attachments, Attachment{Name: name, ContentType: mediaType, Data: data})
+	return nil
+}
+
+func attachmentName(disposition string, typeParams map[string]string) string {
+	name := ""
+	if _, params, err := mime.ParseMediaType(disposition); err == nil {
+		name = params["filename"]
+	}
+	if name == "" {
+		name = typeParams["name"]
+	}
+	if decoded, err := wordDecoder.DecodeHeader(name); err == nil {
+		name = decoded
+	}
+	if name == "" {
+		return ""
+	}
+	return filepath.Base(strings.ReplaceAll(name, `\`, "/"))
+}
+
+func decode(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, r)
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	}
+	return r
+}
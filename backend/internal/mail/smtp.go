@@ -0,0 +1,222 @@
+package mail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server is a minimal receive-only SMTP server. It accepts mail for Domain
+// and hands every parsed message to Deliver. It does no relaying, no
+// authentication and no TLS, so it belongs behind the MTA that faces the
+// internet.
+type Server struct {
+	Addr   string
+	Domain string
+	// MaxSize caps the message size in bytes; defaults to 32 MiB
+	MaxSize int64
+	// Accept is asked whether a recipient exists before the message is sent
+	Accept  func(rcpt string) bool
+	Deliver func(msg *Message) error
+
+	mu       sync.Mutex
+	listener net.Listener
+	wg       sync.WaitGroup
+}
+
+const (
+	defaultMaxSize = 32 << 20
+	commandTimeout = 5 * time.Minute
+)
+
+// ListenAndServe accepts connections until Close is called.
+func (s *Server) ListenAndServe() error {
+	ln, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ln)
+}
+
+func (s *Server) Serve(ln net.Listener) error {
+	s.mu.Lock()
+	s.listener = ln
+	s.mu.Unlock()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+// Close stops accepting connections and waits for open sessions.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	ln := s.listener
+	s.mu.Unlock()
+	if ln == nil {
+		return nil
+	}
+	err := ln.Close()
+	s.wg.Wait()
+	return err
+}
+
+type session struct {
+	from string
+	rcpt []string
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	reply := func(code int, msg string) {
+		tp.PrintfLine("%d %s", code, msg)
+	}
+
+	maxSize := s.MaxSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxSize
+	}
+
+	reply(220, s.Domain+" depot ESMTP ready")
+	var sess session
+	for {
+		conn.SetDeadline(time.Now().Add(commandTimeout))
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "HELO":
+			reply(250, s.Domain)
+		case "EHLO":
+			tp.PrintfLine("250-%s", s.Domain)
+			tp.PrintfLine("250-SIZE %d", maxSize)
+			tp.PrintfLine("250 8BITMIME")
+		case "MAIL":
+			addr, ok := pathArg(arg, "FROM:")
+			if !ok {
+				reply(501, "Syntax: MAIL FROM:<address>")
+				continue
+			}
+			sess = session{from: addr}
+			reply(250, "OK")
+		case "RCPT":
+			addr, ok := pathArg(arg, "TO:")
+			if !ok {
+				reply(501, "Syntax: RCPT TO:<address>")
+				continue
+			}
+			if !s.acceptsDomain(addr) {
+				reply(550, "Relay not permitted")
+				continue
+			}
+			if s.Accept != nil && !s.Accept(addr) {
+				reply(550, "No such mailbox")
+				continue
+			}
+			sess.rcpt = append(sess.rcpt, addr)
+			reply(250, "OK")
+		case "DATA":
+			if len(sess.rcpt) == 0 {
+				reply(503, "Need RCPT first")
+				continue
+			}
+			reply(354, "End data with <CR><LF>.<CR><LF>")
+			dot := tp.DotReader()
+			data, err := io.ReadAll(io.LimitReader(dot, maxSize+1))
+			if err != nil {
+				return
+			}
+			if int64(len(data)) > maxSize {
+				// Drain the rest so the session stays in sync
+				io.Copy(io.Discard, dot)
+				reply(552, "Message exceeds maximum size")
+				sess = session{}
+				continue
+			}
+			if err := s.deliver(sess, data); err != nil {
+				log.Printf("[ERROR] Mail delivery failed: %v", err)
+				reply(451, "Delivery failed, try again later")
+			} else {
+				reply(250, "OK")
+			}
+			sess = session{}
+		case "RSET":
+			sess = session{}
+			reply(250, "OK")
+		case "NOOP":
+			reply(250, "OK")
+		case "QUIT":
+			reply(221, "Bye")
+			return
+		default:
+			reply(502, "Command not implemented")
+		}
+	}
+}
+
+func (s *Server) deliver(sess session, data []byte) error {
+	msg, err := Parse(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("parse message: %w", err)
+	}
+	// The envelope is authoritative for where the message goes
+	msg.To = sess.rcpt
+	if msg.From == "" {
+		msg.From = sess.from
+	}
+	if s.Deliver == nil {
+		return nil
+	}
+	return s.Deliver(msg)
+}
+
+func (s *Server) acceptsDomain(addr string) bool {
+	if s.Domain == "" {
+		return true
+	}
+	_, domain, ok := strings.Cut(addr, "@")
+	return ok && strings.EqualFold(domain, s.Domain)
+}
+
+// pathArg extracts the address from "FROM:<addr> SIZE=..." style arguments.
+func pathArg(arg, prefix string) (string, bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", false
+	}
+	rest := strings.TrimSpace(arg[len(prefix):])
+	if !strings.HasPrefix(rest, "<") {
+		return "", false
+	}
+	end := strings.Index(rest, ">")
+	if end < 0 {
+		return "", false
+	}
+	return rest[1:end], true
+}
+
+// LocalPart returns the part of an address before the @.
+func LocalPart(addr string) string {
+	local, _, _ := strings.Cut(addr, "@")
+	return strings.ToLower(local)
+}
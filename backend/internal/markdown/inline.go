@@ -0,0 +1,183 @@
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"strings"
+)
+
+// renderInline renders spans within a block: code, links, images, autolinks,
+// emphasis and backslash escapes. Everything else is escaped.
+func renderInline(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte("\\`*_{}[]()#+-.!|~<>", s[i+1]) >= 0:
+			sb.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+			continue
+
+		case c == '`':
+			n := runLength(s[i:], '`')
+			fence := s[i : i+n]
+			if end := strings.Index(s[i+n:], fence); end >= 0 {
+				code := strings.TrimSpace(strings.ReplaceAll(s[i+n:i+n+end], "\n", " "))
+				sb.WriteString("<code>" + html.EscapeString(code) + "</code>")
+				i += n + end + n
+				continue
+			}
+			sb.WriteString(fence)
+			i += n
+			continue
+
+		case c == '!' && strings.HasPrefix(s[i+1:], "["):
+			if text, target, n, ok := parseLink(s[i+1:]); ok {
+				sb.WriteString(`<img src="` + html.EscapeString(safeURL(target)) + `" alt="` + html.EscapeString(text) + `">`)
+				i += 1 + n
+				continue
+			}
+
+		case c == '[':
+			if text, target, n, ok := parseLink(s[i:]); ok {
+				sb.WriteString(`<a href="` + html.EscapeString(safeURL(target)) + `" rel="nofollow noopener">` + renderInline(text) + "</a>")
+				i += n
+				continue
+			}
+
+		case c == '<':
+			if end := strings.IndexByte(s[i:], '>'); end > 0 {
+				target := s[i+1 : i+end]
+				if !strings.ContainsAny(target, " \n<") && (strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://")) {
+					sb.WriteString(`<a href="` + html.EscapeString(safeURL(target)) + `" rel="nofollow noopener">` + html.EscapeString(target) + "</a>")
+					i += end + 1
+					continue
+				}
+			}
+
+		case c == '*' || c == '_' || c == '~':
+			if out, n, ok := emphasis(s, i); ok {
+				sb.WriteString(out)
+				i += n
+				continue
+			}
+
+		}
+		sb.WriteString(html.EscapeString(s[i : i+1]))
+		i++
+	}
+	return sb.String()
+}
+
+func runLength(s string, c byte) int {
+	n := 0
+	for n < len(s) && s[n] == c {
+		n++
+	}
+	return n
+}
+
+// parseLink parses "[text](target)" at the start of s and returns the number
+// of bytes consumed. A title after the target is ignored.
+func parseLink(s string) (text, target string, n int, ok bool) {
+	depth := 0
+	closeText := -1
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				closeText = i
+			}
+		}
+		if closeText >= 0 {
+			break
+		}
+	}
+	if closeText < 0 || closeText+1 >= len(s) || s[closeText+1] != '(' {
+		return "", "", 0, false
+	}
+	end := strings.IndexByte(s[closeText+2:], ')')
+	if end < 0 {
+		return "", "", 0, false
+	}
+	dest := strings.TrimSpace(s[closeText+2 : closeText+2+end])
+	if sp := strings.IndexAny(dest, " \n"); sp >= 0 {
+		dest = dest[:sp]
+	}
+	dest = strings.TrimSuffix(strings.TrimPrefix(dest, "<"), ">")
+	return s[1:closeText], dest, closeText + 2 + end + 1, true
+}
+
+// safeURL allows relative URLs and the http, https and mailto schemes, and
+// neutralizes everything else, e.g. javascript: links.
+func safeURL(target string) string {
+	u, err := url.Parse(strings.TrimSpace(target))
+	if err != nil {
+		return "#"
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "", "http", "https", "mailto":
+		return u.String()
+	}
+	return "#"
+}
+
+// emphasis renders a *em*, **strong** or ~~del~~ span starting at s[i].
+func emphasis(s string, i int) (string, int, bool) {
+	c := s[i]
+	n := runLength(s[i:], c)
+	if c == '~' && n != 2 {
+		return "", 0, false
+	}
+	if n > 3 {
+		return "", 0, false
+	}
+	// Intraword underscores, as in snake_case, are literal
+	if c == '_' && i > 0 && isWordByte(s[i-1]) {
+		return "", 0, false
+	}
+	open := i + n
+	if open >= len(s) || s[open] == ' ' || s[open] == '\n' {
+		return "", 0, false
+	}
+
+	delim := s[i:open]
+	for j := open; j < len(s); {
+		k := strings.Index(s[j:], delim)
+		if k < 0 {
+			return "", 0, false
+		}
+		end := j + k
+		after := end + n
+		valid := s[end-1] != ' ' && s[end-1] != '\n' && end > open &&
+			(after >= len(s) || s[after] != c) &&
+			(c != '_' || after >= len(s) || !isWordByte(s[after]))
+		if !valid {
+			j = end + 1
+			continue
+		}
+		inner := renderInline(s[open:end])
+		var out string
+		switch {
+		case c == '~':
+			out = "<del>" + inner + "</del>"
+		case n == 1:
+			out = "<em>" + inner + "</em>"
+		case n == 2:
+			out = "<strong>" + inner + "</strong>"
+		default:
+			out = "<em><strong>" + inner + "</strong></em>"
+		}
+		return out, after - i, true
+	}
+	return "", 0, false
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
@@ -0,0 +1,268 @@
+// Package markdown renders the common subset of Markdown used in READMEs and
+// design docs: headings, paragraphs, emphasis, code, links, images, lists,
+// block quotes, rules and tables.
+//
+// The output is safe to serve from the depot's own origin. Raw HTML in the
+// input is escaped rather than passed through, and link and image targets
+// are limited to relative URLs and the http, https and mailto schemes.
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	headingLine   = regexp.MustCompile(`^ {0,3}(#{1,6})(?:\s+(.*?))?(?:\s+#+)?\s*$`)
+	ruleLine      = regexp.MustCompile(`^ {0,3}([-*_])(?:\s*([-*_])){2,}\s*$`)
+	fenceLine     = regexp.MustCompile("^ {0,3}(```+|~~~+)\\s*([^`\\s]*)")
+	listItemLine  = regexp.MustCompile(`^( {0,3})([-*+]|\d{1,9}[.)])(\s+|$)(.*)$`)
+	tableDelimRow = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+	languageName  = regexp.MustCompile(`^[A-Za-z0-9_+#.-]+$`)
+)
+
+// Render converts Markdown source to an HTML fragment.
+func Render(src []byte) string {
+	text := strings.ReplaceAll(string(src), "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\t", "    ")
+	var sb strings.Builder
+	renderBlocks(&sb, strings.Split(text, "\n"))
+	return sb.String()
+}
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+// startsBlock reports whether a line interrupts a paragraph.
+func startsBlock(line string) bool {
+	return headingLine.MatchString(line) || ruleLine.MatchString(line) ||
+		fenceLine.MatchString(line) || strings.HasPrefix(strings.TrimLeft(line, " "), ">") ||
+		listItemLine.MatchString(line)
+}
+
+func renderBlocks(sb *strings.Builder, lines []string) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case isBlank(line):
+			i++
+
+		case fenceLine.MatchString(line):
+			m := fenceLine.FindStringSubmatch(line)
+			fence := m[1]
+			var code []string
+			i++
+			for i < len(lines) && !strings.HasPrefix(strings.TrimLeft(lines[i], " "), fence) {
+				code = append(code, lines[i])
+				i++
+			}
+			i++ // closing fence
+			writeCode(sb, code, m[2])
+
+		case headingLine.MatchString(line):
+			m := headingLine.FindStringSubmatch(line)
+			level := string(rune('0' + len(m[1])))
+			sb.WriteString("<h" + level + ">" + renderInline(m[2]) + "</h" + level + ">\n")
+			i++
+
+		case ruleLine.MatchString(line):
+			sb.WriteString("<hr>\n")
+			i++
+
+		case strings.HasPrefix(strings.TrimLeft(line, " "), ">"):
+			var quote []string
+			for i < len(lines) && !isBlank(lines[i]) {
+				l := strings.TrimLeft(lines[i], " ")
+				if strings.HasPrefix(l, ">") {
+					l = strings.TrimPrefix(strings.TrimPrefix(l, ">"), " ")
+				}
+				quote = append(quote, l)
+				i++
+			}
+			sb.WriteString("<blockquote>\n")
+			renderBlocks(sb, quote)
+			sb.WriteString("</blockquote>\n")
+
+		case listItemLine.MatchString(line):
+			i = renderList(sb, lines, i)
+
+		case strings.HasPrefix(line, "    "):
+			var code []string
+			for i < len(lines) && (strings.HasPrefix(lines[i], "    ") || isBlank(lines[i])) {
+				code = append(code, strings.TrimPrefix(lines[i], "    "))
+				i++
+			}
+			for len(code) > 0 && isBlank(code[len(code)-1]) {
+				code = code[:len(code)-1]
+			}
+			writeCode(sb, code, "")
+
+		case i+1 < len(lines) && strings.Contains(line, "|") && tableDelimRow.MatchString(lines[i+1]):
+			i = renderTable(sb, lines, i)
+
+		default:
+			var para []string
+			for i < len(lines) && !isBlank(lines[i]) && (len(para) == 0 || !startsBlock(lines[i])) {
+				para = append(para, strings.TrimSpace(lines[i]))
+				i++
+			}
+			sb.WriteString("<p>" + renderInline(strings.Join(para, "\n")) + "</p>\n")
+		}
+	}
+}
+
+func writeCode(sb *strings.Builder, code []string, lang string) {
+	sb.WriteString("<pre><code")
+	if languageName.MatchString(lang) {
+		sb.WriteString(` class="language-` + html.EscapeString(lang) + `"`)
+	}
+	sb.WriteString(">")
+	for _, l := range code {
+		sb.WriteString(html.EscapeString(l) + "\n")
+	}
+	sb.WriteString("</code></pre>\n")
+}
+
+// renderList renders the list starting at lines[start] and returns the index
+// of the first line after it. Item content is rendered as blocks, which
+// gives nested lists for free.
+func renderList(sb *strings.Builder, lines []string, start int) int {
+	first := listItemLine.FindStringSubmatch(lines[start])
+	ordered := first[2][0] >= '0' && first[2][0] <= '9'
+	tag := "ul"
+	if ordered {
+		tag = "ol"
+	}
+	sb.WriteString("<" + tag + ">\n")
+
+	var items [][]string
+	loose := false
+	i := start
+	for i < len(lines) {
+		line := lines[i]
+		if m := listItemLine.FindStringSubmatch(line); m != nil && len(m[1]) == len(first[1]) {
+			if (m[2][0] >= '0' && m[2][0] <= '9') != ordered {
+				break
+			}
+			items = append(items, []string{m[4]})
+			i++
+			continue
+		}
+		if isBlank(line) {
+			// A blank line ends the list unless the next line continues it
+			if i+1 < len(lines) && (strings.HasPrefix(lines[i+1], "  ") || listItemLine.MatchString(lines[i+1])) {
+				items[len(items)-1] = append(items[len(items)-1], "")
+				loose = true
+				i++
+				continue
+			}
+			break
+		}
+		if strings.HasPrefix(line, "  ") {
+			items[len(items)-1] = append(items[len(items)-1], dedent(line, len(first[1])+2))
+			i++
+			continue
+		}
+		if startsBlock(line) {
+			break
+		}
+		// Lazy continuation of the item's paragraph
+		items[len(items)-1] = append(items[len(items)-1], strings.TrimSpace(line))
+		i++
+	}
+
+	for _, item := range items {
+		var body strings.Builder
+		renderBlocks(&body, item)
+		content := body.String()
+		if !loose {
+			content = unwrapParagraphs(content)
+		}
+		sb.WriteString("<li>" + strings.TrimSuffix(content, "\n") + "</li>\n")
+	}
+	sb.WriteString("</" + tag + ">\n")
+	return i
+}
+
+func dedent(line string, n int) string {
+	for n > 0 && strings.HasPrefix(line, " ") {
+		line = line[1:]
+		n--
+	}
+	return line
+}
+
+// unwrapParagraphs drops the <p> wrappers of a tight list item.
+func unwrapParagraphs(s string) string {
+	s = strings.ReplaceAll(s, "<p>", "")
+	return strings.ReplaceAll(s, "</p>\n", "\n")
+}
+
+func renderTable(sb *strings.Builder, lines []string, start int) int {
+	header := splitRow(lines[start])
+	var aligns []string
+	for _, cell := range splitRow(lines[start+1]) {
+		left, right := strings.HasPrefix(cell, ":"), strings.HasSuffix(cell, ":")
+		switch {
+		case left && right:
+			aligns = append(aligns, "center")
+		case right:
+			aligns = append(aligns, "right")
+		case left:
+			aligns = append(aligns, "left")
+		default:
+			aligns = append(aligns, "")
+		}
+	}
+
+	writeRow := func(cells []string, cellTag string) {
+		sb.WriteString("<tr>")
+		for j := range header {
+			cell := ""
+			if j < len(cells) {
+				cell = cells[j]
+			}
+			sb.WriteString("<" + cellTag)
+			if j < len(aligns) && aligns[j] != "" {
+				sb.WriteString(` style="text-align:` + aligns[j] + `"`)
+			}
+			sb.WriteString(">" + renderInline(cell) + "</" + cellTag + ">")
+		}
+		sb.WriteString("</tr>\n")
+	}
+
+	sb.WriteString("<table>\n<thead>\n")
+	writeRow(header, "th")
+	sb.WriteString("</thead>\n<tbody>\n")
+	i := start + 2
+	for i < len(lines) && !isBlank(lines[i]) && strings.Contains(lines[i], "|") {
+		writeRow(splitRow(lines[i]), "td")
+		i++
+	}
+	sb.WriteString("</tbody>\n</table>\n")
+	return i
+}
+
+func splitRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	line = strings.TrimSuffix(line, "|")
+
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
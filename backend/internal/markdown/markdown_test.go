@@ -0,0 +1,51 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	cases := []struct {
+		name, in, want string
+	}{
+		{"heading", "# Design *doc*", "<h1>Design <em>doc</em></h1>\n"},
+		{"heading keeps C#", "## Notes on C#", "<h2>Notes on C#</h2>\n"},
+		{"paragraph", "one\ntwo\n\nthree", "<p>one\ntwo</p>\n<p>three</p>\n"},
+		{"strong and code", "**bold** and `a < b`", "<p><strong>bold</strong> and <code>a &lt; b</code></p>\n"},
+		{"snake_case", "use my_var_name here", "<p>use my_var_name here</p>\n"},
+		{"link", "[docs](https://example.com/a?b=1&c=2)", `<p><a href="https://example.com/a?b=1&amp;c=2" rel="nofollow noopener">docs</a></p>` + "\n"},
+		{"image", "![chart](img/chart.png)", `<p><img src="img/chart.png" alt="chart"></p>` + "\n"},
+		{"fence", "```go\nfmt.Println(\"<hi>\")\n```", "<pre><code class=\"language-go\">fmt.Println(&#34;&lt;hi&gt;&#34;)\n</code></pre>\n"},
+		{"list", "- a\n- b\n  - c", "<ul>\n<li>a</li>\n<li>b\n<ul>\n<li>c</li>\n</ul></li>\n</ul>\n"},
+		{"ordered", "1. first\n2. second", "<ol>\n<li>first</li>\n<li>second</li>\n</ol>\n"},
+		{"quote", "> quoted", "<blockquote>\n<p>quoted</p>\n</blockquote>\n"},
+		{"rule", "---", "<hr>\n"},
+		{"table", "| a | b |\n|:--|--:|\n| 1 | 2 |", "<table>\n<thead>\n<tr><th style=\"text-align:left\">a</th><th style=\"text-align:right\">b</th></tr>\n</thead>\n<tbody>\n<tr><td style=\"text-align:left\">1</td><td style=\"text-align:right\">2</td></tr>\n</tbody>\n</table>\n"},
+	}
+	for _, tc := range cases {
+		if got := Render([]byte(tc.in)); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestRenderIsSanitized(t *testing.T) {
+	inputs := []string{
+		"<script>alert(1)</script>",
+		"<img src=x onerror=alert(1)>",
+		"[click](javascript:alert(1))",
+		"![x](javascript:alert(1))",
+		"[x](JaVaScRiPt:alert(1))",
+		`[x](https://example.com" onmouseover="alert(1))`,
+		"```\"><script>\n```",
+	}
+	for _, in := range inputs {
+		out := Render([]byte(in))
+		lower := strings.ToLower(out)
+		if strings.Contains(lower, "<script") || strings.Contains(lower, "javascript:") ||
+			strings.Contains(lower, "<img src=x") || strings.Contains(out, `" onmouseover`) {
+			t.Errorf("unsafe output for %q: %q", in, out)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+// Package memstore is an in-memory CelerixStore for tests and demos. Nothing
+// is persisted.
+//
+// Values are stored as their JSON encoding and decoded on every read, the
+// way a remote store or a restarted embedded one hands them back, so code
+// that only works while it holds on to the Go values it stored shows up in
+// tests.
+package memstore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+// Store is a thread-safe in-memory store.
+type Store struct {
+	mu sync.RWMutex
+	// data is [personaID][appID][key] -> JSON encoding of the value
+	data map[string]map[string]map[string][]byte
+}
+
+var _ sdk.CelerixStore = (*Store)(nil)
+
+// New returns an empty store.
+func New() *Store {
+	return &Store{data: make(map[string]map[string]map[string][]byte)}
+}
+
+func decode(b []byte) any {
+	var v any
+	json.Unmarshal(b, &v)
+	return v
+}
+
+func (s *Store) Get(personaID, appID, key string) (any, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	persona, ok := s.data[personaID]
+	if !ok {
+		return nil, sdk.ErrPersonaNotFound
+	}
+	app, ok := persona[appID]
+	if !ok {
+		return nil, sdk.ErrAppNotFound
+	}
+	b, ok := app[key]
+	if !ok {
+		return nil, sdk.ErrKeyNotFound
+	}
+	return decode(b), nil
+}
+
+func (s *Store) Set(personaID, appID, key string, val any) error {
+	b, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.data[personaID] == nil {
+		s.data[personaID] = make(map[string]map[string][]byte)
+	}
+	if s.data[personaID][appID] == nil {
+		s.data[personaID][appID] = make(map[string][]byte)
+	}
+	s.data[personaID][appID][key] = b
+	return nil
+}
+
+func (s *Store) Delete(personaID, appID, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if app, ok := s.data[personaID][appID]; ok {
+		delete(app, key)
+	}
+	return nil
+}
+
+func (s *Store) GetPersonas() ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var list []string
+	for id := range s.data {
+		list = append(list, id)
+	}
+	return list, nil
+}
+
+func (s *Store) GetApps(personaID string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var list []string
+	for id := range s.data[personaID] {
+		list = append(list, id)
+	}
+	return list, nil
+}
+
+func (s *Store) GetAppStore(personaID, appID string) (map[string]any, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	app, ok := s.data[personaID][appID]
+	if !ok {
+		return nil, sdk.ErrAppNotFound
+	}
+	values := make(map[string]any, len(app))
+	for k, b := range app {
+		values[k] = decode(b)
+	}
+	return values, nil
+}
+
+func (s *Store) DumpApp(appID string) (map[string]map[string]any, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[string]map[string]any)
+	for personaID, apps := range s.data {
+		app, ok := apps[appID]
+		if !ok {
+			continue
+		}
+		values := make(map[string]any, len(app))
+		for k, b := range app {
+			values[k] = decode(b)
+		}
+		result[personaID] = values
+	}
+	return result, nil
+}
+
+func (s *Store) GetGlobal(appID, key string) (any, string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for personaID, apps := range s.data {
+		if b, ok := apps[appID][key]; ok {
+			return decode(b), personaID, nil
+		}
+	}
+	return nil, "", sdk.ErrKeyNotFound
+}
+
+// Move re-homes a key in one step, so no reader sees it in both personas
+// or in neither.
+func (s *Store) Move(srcPersona, dstPersona, appID, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	src, ok := s.data[srcPersona]
+	if !ok {
+		return sdk.ErrPersonaNotFound
+	}
+	app, ok := src[appID]
+	if !ok {
+		return sdk.ErrAppNotFound
+	}
+	b, ok := app[key]
+	if !ok {
+		return sdk.ErrKeyNotFound
+	}
+	delete(app, key)
+	if s.data[dstPersona] == nil {
+		s.data[dstPersona] = make(map[string]map[string][]byte)
+	}
+	if s.data[dstPersona][appID] == nil {
+		s.data[dstPersona][appID] = make(map[string][]byte)
+	}
+	s.data[dstPersona][appID][key] = b
+	return nil
+}
+
+func (s *Store) App(personaID, appID string) sdk.AppScope {
+	return &appScope{store: s, persona: personaID, app: appID}
+}
+
+type appScope struct {
+	store   *Store
+	persona string
+	app     string
+}
+
+func (a *appScope) Get(key string) (any, error) {
+	return a.store.Get(a.persona, a.app, key)
+}
+
+func (a *appScope) Set(key string, val any) error {
+	return a.store.Set(a.persona, a.app, key, val)
+}
+
+func (a *appScope) Delete(key string) error {
+	return a.store.Delete(a.persona, a.app, key)
+}
+
+func (a *appScope) Vault(masterKey []byte) any {
+	return &vaultScope{app: a, key: masterKey}
+}
+
+// vaultScope stores values AES-GCM encrypted and hex encoded, with the
+// nonce in front.
+type vaultScope struct {
+	app *appScope
+	key []byte
+}
+
+var _ sdk.VaultScope = (*vaultScope)(nil)
+
+func (v *vaultScope) Set(key string, plaintext string) error {
+	gcm, err := v.gcm()
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	return v.app.Set(key, hex.EncodeToString(gcm.Seal(nonce, nonce, []byte(plaintext), nil)))
+}
+
+func (v *vaultScope) Get(key string) (string, error) {
+	val, err := v.app.Get(key)
+	if err != nil {
+		return "", err
+	}
+	s, ok := val.(string)
+	if !ok {
+		return "", errors.New("stored value is not a string")
+	}
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := v.gcm()
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", errors.New("ciphertext too short")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+func (v *vaultScope) gcm() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(v.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
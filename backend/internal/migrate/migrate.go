@@ -0,0 +1,219 @@
+// Package migrate moves stored content between the local storage directory
+// and S3 while the server keeps running. The server reads either kind of
+// stored path, so each file is served from its old copy until its record is
+// flipped to the new one.
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/storage"
+)
+
+// Migrator copies content to the other backend, verifying each copy against
+// the recorded SHA-256 before pointing records at it.
+type Migrator struct {
+	Store db.CelerixStore
+	S3    *storage.S3Client
+	// StorageDir receives content moved off S3.
+	StorageDir string
+	// ToS3 moves local content to S3; otherwise S3 content moves to
+	// StorageDir.
+	ToS3 bool
+	// DeleteSource removes old copies nothing refers to any more once the
+	// run is done.
+	DeleteSource bool
+	// DryRun only reports what would be copied.
+	DryRun bool
+	// Logf, when set, is told about every copy.
+	Logf func(format string, args ...any)
+}
+
+type Failure struct {
+	StoredPath string `json:"stored_path"`
+	Error      string `json:"error"`
+}
+
+type Report struct {
+	// Copied counts stored copies, Files the records pointing at them.
+	Copied  int       `json:"copied"`
+	Files   int       `json:"files"`
+	Bytes   int64     `json:"bytes"`
+	Deleted int       `json:"deleted"`
+	Failed  []Failure `json:"failed"`
+}
+
+// Run copies every stored copy still on the source backend. Copies that
+// fail are reported and left in place, so a later run picks them up again.
+func (m *Migrator) Run(ctx context.Context) (*Report, error) {
+	if m.S3 == nil {
+		return nil, fmt.Errorf("migrate: S3 is not configured")
+	}
+	records, err := db.GetAllFileRecords(m.Store)
+	if err != nil {
+		return nil, err
+	}
+
+	// Deduplicated files share a stored copy, which moves once
+	groups := make(map[string][]db.FileRecord)
+	for _, r := range records {
+		if r.StoredPath != "" && !r.External && storage.IsS3Path(r.StoredPath) != m.ToS3 {
+			groups[r.StoredPath] = append(groups[r.StoredPath], r)
+		}
+	}
+	paths := make([]string, 0, len(groups))
+	for p := range groups {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	report := &Report{Failed: []Failure{}}
+	var moved []string
+	for _, from := range paths {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		group := groups[from]
+		hash := ""
+		ids := make([]string, 0, len(group))
+		for _, r := range group {
+			if hash == "" {
+				hash = r.SHA256
+			}
+			ids = append(ids, r.ID)
+		}
+
+		if m.DryRun {
+			report.Copied++
+			report.Files += len(group)
+			report.Bytes += group[0].Size
+			continue
+		}
+
+		var to string
+		var size int64
+		if m.ToS3 {
+			to, size, hash, err = m.upload(ctx, from, hash)
+		} else {
+			to, size, hash, err = m.download(ctx, from, hash)
+		}
+		if err != nil {
+			report.Failed = append(report.Failed, Failure{StoredPath: from, Error: err.Error()})
+			m.logf("Failed to copy %s: %v", from, err)
+			continue
+		}
+		n, err := db.RelocateContent(m.Store, hash, from, to, ids)
+		if err != nil {
+			return report, err
+		}
+		report.Copied++
+		report.Files += n
+		report.Bytes += size
+		moved = append(moved, from)
+		m.logf("Copied %s to %s (%d files)", from, to, n)
+	}
+
+	if m.DeleteSource && len(moved) > 0 {
+		report.Deleted, err = m.deleteUnreferenced(ctx, moved)
+	}
+	return report, err
+}
+
+// upload copies a local file to S3 under its hash. The file is checked
+// before it leaves, and S3 checks what arrives against the signed hash.
+func (m *Migrator) upload(ctx context.Context, from, hash string) (string, int64, string, error) {
+	size, actual, err := storage.HashFile(from)
+	if err != nil {
+		return "", 0, "", err
+	}
+	if hash != "" && actual != hash {
+		return "", 0, "", fmt.Errorf("content doesn't match its recorded checksum")
+	}
+	f, err := os.Open(from)
+	if err != nil {
+		return "", 0, "", err
+	}
+	defer f.Close()
+
+	key := "blobs/" + actual
+	if err := m.S3.Put(ctx, key, f, size, actual); err != nil {
+		return "", 0, "", err
+	}
+	return m.S3.Path(key), size, actual, nil
+}
+
+// download copies an S3 object into StorageDir under its hash. It is written
+// next to its final name first, so a bad copy never replaces a good one.
+func (m *Migrator) download(ctx context.Context, from, hash string) (string, int64, string, error) {
+	body, err := m.S3.Get(ctx, m.S3.KeyFromPath(from))
+	if err != nil {
+		return "", 0, "", err
+	}
+	defer body.Close()
+
+	name := hash
+	if name == "" {
+		name = filepath.Base(m.S3.KeyFromPath(from))
+	}
+	tmp, size, actual, err := storage.StoreFile(body, "", m.StorageDir, name+".migrating")
+	if err != nil {
+		return "", 0, "", err
+	}
+	if hash != "" && actual != hash {
+		os.Remove(tmp)
+		return "", 0, "", fmt.Errorf("content doesn't match its recorded checksum")
+	}
+	to := filepath.Join(m.StorageDir, name)
+	if err := os.Rename(tmp, to); err != nil {
+		os.Remove(tmp)
+		return "", 0, "", err
+	}
+	return to, size, actual, nil
+}
+
+// deleteUnreferenced removes the old copies no file or blob points at any
+// more. A copy a record still uses, e.g. one uploaded during the run, stays.
+func (m *Migrator) deleteUnreferenced(ctx context.Context, paths []string) (int, error) {
+	records, err := db.GetAllFileRecords(m.Store)
+	if err != nil {
+		return 0, err
+	}
+	inUse := make(map[string]bool)
+	for _, r := range records {
+		inUse[r.StoredPath] = true
+	}
+	if blobs, err := db.ListBlobs(m.Store); err == nil {
+		for _, b := range blobs {
+			inUse[b.StoredPath] = true
+		}
+	}
+
+	deleted := 0
+	for _, p := range paths {
+		if inUse[p] {
+			continue
+		}
+		if storage.IsS3Path(p) {
+			err = m.S3.Delete(ctx, m.S3.KeyFromPath(p))
+		} else {
+			err = storage.DeleteFile(p)
+		}
+		if err != nil {
+			m.logf("Failed to delete %s: %v", p, err)
+			continue
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+func (m *Migrator) logf(format string, args ...any) {
+	if m.Logf != nil {
+		m.Logf(format, args...)
+	}
+}
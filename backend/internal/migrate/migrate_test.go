@@ -0,0 +1,122 @@
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/celerix-dev/celerix-store/pkg/engine"
+	"github.com/celerix/depot/internal/db"
+	"github.com/celerix/depot/internal/storage"
+)
+
+// fakeS3 keeps objects in memory and, like S3, rejects uploads whose content
+// doesn't match the signed payload hash.
+func fakeS3(t *testing.T) (*storage.S3Client, map[string][]byte) {
+	var mu sync.Mutex
+	objects := map[string][]byte{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			sum := sha256.Sum256(body)
+			if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			objects[r.URL.Path] = body
+		case http.MethodGet:
+			body, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(body)
+		case http.MethodDelete:
+			delete(objects, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	client, err := storage.NewS3Client(storage.S3Config{Endpoint: srv.URL, Bucket: "depot", PathStyle: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, objects
+}
+
+func TestMigrateRoundTrip(t *testing.T) {
+	store := engine.NewMemStore(nil, nil)
+	s3, objects := fakeS3(t)
+	dir := t.TempDir()
+
+	content := []byte("shared content")
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+	shared := filepath.Join(dir, "file-1")
+	os.WriteFile(shared, content, 0644)
+	db.AcquireBlob(store, hash, int64(len(content)), shared)
+	db.RefBlob(store, hash, int64(len(content)))
+	db.SaveFileRecord(store, db.FileRecord{ID: "file-1", OriginalName: "a.txt", StoredPath: shared, Size: int64(len(content)), SHA256: hash})
+	db.SaveFileRecord(store, db.FileRecord{ID: "file-2", OriginalName: "b.txt", StoredPath: shared, Size: int64(len(content)), SHA256: hash})
+
+	rotten := filepath.Join(dir, "file-3")
+	os.WriteFile(rotten, []byte("bit rot"), 0644)
+	db.SaveFileRecord(store, db.FileRecord{ID: "file-3", OriginalName: "c.txt", StoredPath: rotten, Size: 7, SHA256: hash})
+
+	m := &Migrator{Store: store, S3: s3, StorageDir: dir, ToS3: true, DeleteSource: true}
+	report, err := m.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if report.Copied != 1 || report.Files != 2 || report.Deleted != 1 {
+		t.Errorf("expected one copy for two files, got %+v", report)
+	}
+	if len(report.Failed) != 1 || report.Failed[0].StoredPath != rotten {
+		t.Errorf("expected the corrupted file to fail verification, got %+v", report.Failed)
+	}
+
+	for _, id := range []string{"file-1", "file-2"} {
+		r, _ := db.GetFileRecord(store, id)
+		if !storage.IsS3Path(r.StoredPath) {
+			t.Errorf("expected %s to be flipped to S3, got %s", id, r.StoredPath)
+		}
+	}
+	if r, _ := db.GetFileRecord(store, "file-3"); r.StoredPath != rotten {
+		t.Errorf("expected the failed file to keep its local copy, got %s", r.StoredPath)
+	}
+	if blob, _ := db.GetBlob(store, hash); !storage.IsS3Path(blob.StoredPath) {
+		t.Errorf("expected the blob to be flipped to S3, got %s", blob.StoredPath)
+	}
+	if _, err := os.Stat(shared); !os.IsNotExist(err) {
+		t.Errorf("expected the migrated local copy to be deleted")
+	}
+	if string(objects["/depot/blobs/"+hash]) != string(content) {
+		t.Errorf("expected the content in the bucket under its hash")
+	}
+
+	// And back again
+	os.Remove(rotten)
+	db.DeleteFileRecord(store, "file-3")
+	m = &Migrator{Store: store, S3: s3, StorageDir: dir}
+	report, err = m.Run(context.Background())
+	if err != nil || report.Copied != 1 || report.Files != 2 || len(report.Failed) != 0 {
+		t.Fatalf("expected the copy to come back, got %+v, %v", report, err)
+	}
+	r, _ := db.GetFileRecord(store, "file-1")
+	if got, err := os.ReadFile(r.StoredPath); err != nil || string(got) != string(content) {
+		t.Errorf("expected the local copy to hold the content, got %q, %v", got, err)
+	}
+	if len(objects) != 1 {
+		t.Errorf("expected the bucket copy to stay without --delete-source")
+	}
+}
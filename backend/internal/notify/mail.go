@@ -0,0 +1,51 @@
+package notify
+
+import (
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Mailer sends plain text mail through an SMTP relay.
+type Mailer struct {
+	// Addr is the relay as host:port
+	Addr string
+	From string
+	// Username and Password authenticate with PLAIN auth when set, which
+	// net/smtp only allows over TLS or to localhost
+	Username string
+	Password string
+}
+
+// Send mails the message to one recipient. The subject is the message
+// text's first line.
+func (m *Mailer) Send(to string, msg Message) error {
+	if m == nil || m.Addr == "" || to == "" {
+		return nil
+	}
+	if msg.Time == 0 {
+		msg.Time = time.Now().Unix()
+	}
+	subject, _, _ := strings.Cut(msg.Text, "\n")
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", m.From)
+	fmt.Fprintf(&b, "To: %s\r\n", to)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Unix(msg.Time, 0).Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "X-Depot-Event: %s\r\n", msg.Event)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Text, "\n", "\r\n"))
+	b.WriteString("\r\n")
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		host, _, _ := net.SplitHostPort(m.Addr)
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+	return smtp.SendMail(m.Addr, auth, m.From, []string{to}, []byte(b.String()))
+}
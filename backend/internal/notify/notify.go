@@ -0,0 +1,43 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Message is the payload delivered to webhook receivers.
+type Message struct {
+	Event string `json:"event"`
+	Text  string `json:"text"`
+	Data  any    `json:"data,omitempty"`
+	Time  int64  `json:"time"`
+}
+
+var client = &http.Client{Timeout: 10 * time.Second}
+
+// Webhook posts the message as JSON. The "text" field makes the payload
+// readable by Slack and Mattermost incoming webhooks as-is.
+func Webhook(url string, msg Message) error {
+	if url == "" {
+		return nil
+	}
+	if msg.Time == 0 {
+		msg.Time = time.Now().Unix()
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package policy
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	ActionAllow      = "allow"
+	ActionReject     = "reject"
+	ActionQuarantine = "quarantine"
+	ActionRename     = "rename"
+	ActionStrip      = "strip"
+)
+
+const defaultRenameSuffix = ".txt"
+
+// Rule matches uploads on extension, leading magic bytes, size and the role
+// of the uploader. All configured conditions must hold for a rule to match;
+// empty conditions match anything.
+type Rule struct {
+	Name         string   `json:"name"`
+	Extensions   []string `json:"extensions"`
+	Magic        []string `json:"magic"`
+	MinSize      int64    `json:"min_size"`
+	MaxSize      int64    `json:"max_size"`
+	Roles        []string `json:"roles"`
+	Action       string   `json:"action"`
+	RenameSuffix string   `json:"rename_suffix"`
+
+	magic [][]byte
+}
+
+// Subject describes an upload being evaluated.
+type Subject struct {
+	Name string
+	Size int64
+	Head []byte
+	Role string
+}
+
+// Decision is the outcome of the first matching rule.
+type Decision struct {
+	Rule   string `json:"rule"`
+	Action string `json:"action"`
+	Name   string `json:"name"`
+}
+
+type Engine struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Load reads a JSON rule file of the form {"rules": [...]}.
+func Load(path string) (*Engine, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var e Engine
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, err
+	}
+	if err := e.compile(); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (e *Engine) compile() error {
+	for i := range e.Rules {
+		r := &e.Rules[i]
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule-%d", i+1)
+		}
+		switch r.Action {
+		case ActionAllow, ActionReject, ActionQuarantine, ActionRename, ActionStrip:
+		default:
+			return fmt.Errorf("policy rule %s: unknown action %q", r.Name, r.Action)
+		}
+		for j, ext := range r.Extensions {
+			r.Extensions[j] = strings.ToLower(strings.TrimPrefix(ext, "."))
+		}
+		r.magic = nil
+		for _, m := range r.Magic {
+			b, err := hex.DecodeString(strings.ReplaceAll(m, " ", ""))
+			if err != nil {
+				return fmt.Errorf("policy rule %s: invalid magic %q: %w", r.Name, m, err)
+			}
+			r.magic = append(r.magic, b)
+		}
+	}
+	return nil
+}
+
+// Evaluate returns the decision of the first rule matching the subject, or
+// nil if no rule applies.
+func (e *Engine) Evaluate(s Subject) *Decision {
+	if e == nil {
+		return nil
+	}
+	for _, r := range e.Rules {
+		if !r.matches(s) {
+			continue
+		}
+		d := &Decision{Rule: r.Name, Action: r.Action, Name: s.Name}
+		switch r.Action {
+		case ActionRename:
+			suffix := r.RenameSuffix
+			if suffix == "" {
+				suffix = defaultRenameSuffix
+			}
+			d.Name = s.Name + suffix
+		case ActionStrip:
+			d.Name = strings.TrimSuffix(s.Name, filepath.Ext(s.Name))
+		}
+		return d
+	}
+	return nil
+}
+
+func (r *Rule) matches(s Subject) bool {
+	if len(r.Extensions) > 0 {
+		ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(s.Name), "."))
+		if !contains(r.Extensions, ext) {
+			return false
+		}
+	}
+	if len(r.magic) > 0 {
+		found := false
+		for _, m := range r.magic {
+			if len(s.Head) >= len(m) && string(s.Head[:len(m)]) == string(m) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if r.MinSize > 0 && s.Size < r.MinSize {
+		return false
+	}
+	if r.MaxSize > 0 && s.Size > r.MaxSize {
+		return false
+	}
+	if len(r.Roles) > 0 && !contains(r.Roles, s.Role) {
+		return false
+	}
+	return true
+}
+
+func contains(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
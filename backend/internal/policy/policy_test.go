@@ -0,0 +1,39 @@
+package policy
+
+import "testing"
+
+func TestEvaluate(t *testing.T) {
+	e := &Engine{Rules: []Rule{
+		{Name: "admins-anything", Roles: []string{"admin"}, Action: ActionAllow},
+		{Name: "no-exe", Extensions: []string{".EXE"}, Action: ActionRename},
+		{Name: "pe-binaries", Magic: []string{"4d5a"}, Action: ActionQuarantine},
+		{Name: "too-big", MinSize: 100, Action: ActionReject},
+	}}
+	if err := e.compile(); err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+
+	tests := []struct {
+		subject Subject
+		action  string
+		name    string
+	}{
+		{Subject{Name: "setup.exe", Role: "admin"}, ActionAllow, "setup.exe"},
+		{Subject{Name: "setup.exe", Role: "client"}, ActionRename, "setup.exe.txt"},
+		{Subject{Name: "innocent.bin", Head: []byte("MZ\x90\x00"), Role: "client"}, ActionQuarantine, "innocent.bin"},
+		{Subject{Name: "huge.txt", Size: 200, Role: "client"}, ActionReject, "huge.txt"},
+	}
+	for _, tt := range tests {
+		d := e.Evaluate(tt.subject)
+		if d == nil {
+			t.Fatalf("expected a decision for %s", tt.subject.Name)
+		}
+		if d.Action != tt.action || d.Name != tt.name {
+			t.Errorf("%s: expected %s/%s, got %s/%s", tt.subject.Name, tt.action, tt.name, d.Action, d.Name)
+		}
+	}
+
+	if d := e.Evaluate(Subject{Name: "notes.txt", Size: 10, Role: "client"}); d != nil {
+		t.Errorf("expected no rule to match, got %s", d.Rule)
+	}
+}
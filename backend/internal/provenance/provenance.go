@@ -0,0 +1,206 @@
+// Package provenance verifies the detached signatures CI systems attach to
+// uploads: minisign signatures, and the signatures `cosign sign-blob --key`
+// and `openssl dgst -sha256 -sign` make with a key whose public half is PEM.
+package provenance
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+const (
+	Minisign = "minisign"
+	ECDSA    = "ecdsa"
+	Ed25519  = "ed25519"
+	RSA      = "rsa"
+)
+
+// maxUnhashed caps the content of signatures made over the content itself
+// rather than a digest (plain Ed25519, legacy minisign), which has to be
+// held in memory to verify.
+const maxUnhashed = 64 << 20
+
+// ErrMismatch means the signature wasn't made with the key over the content.
+var ErrMismatch = errors.New("signature does not match")
+
+// Key is a public key uploads can be signed with.
+type Key struct {
+	Algorithm string
+	// ID is the minisign key ID as minisign prints it; other keys have none
+	ID     string
+	public crypto.PublicKey
+}
+
+// ParseKey reads a PEM public key (ECDSA, Ed25519 or RSA) or a minisign
+// public key, with or without its comment line.
+func ParseKey(text string) (*Key, error) {
+	text = strings.TrimSpace(text)
+	if block, _ := pem.Decode([]byte(text)); block != nil {
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key: %w", err)
+		}
+		switch pub.(type) {
+		case *ecdsa.PublicKey:
+			return &Key{Algorithm: ECDSA, public: pub}, nil
+		case ed25519.PublicKey:
+			return &Key{Algorithm: Ed25519, public: pub}, nil
+		case *rsa.PublicKey:
+			return &Key{Algorithm: RSA, public: pub}, nil
+		}
+		return nil, errors.New("unsupported public key type")
+	}
+
+	lines := contentLines(text)
+	if len(lines) == 0 {
+		return nil, errors.New("public key is empty")
+	}
+	raw, err := base64.StdEncoding.DecodeString(lines[len(lines)-1])
+	if err != nil || len(raw) != 2+8+ed25519.PublicKeySize || string(raw[:2]) != "Ed" {
+		return nil, errors.New("public key is neither PEM nor minisign")
+	}
+	return &Key{Algorithm: Minisign, ID: minisignKeyID(raw[2:10]), public: ed25519.PublicKey(raw[10:])}, nil
+}
+
+// Verify checks sig, a detached signature of the content read from r. A
+// minisign signature is the .minisig file or just its signature line;
+// other signatures are base64 or raw bytes.
+func (k *Key) Verify(r io.Reader, sig []byte) error {
+	if k.Algorithm == Minisign {
+		return k.verifyMinisign(r, sig)
+	}
+	if decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(sig))); err == nil {
+		sig = decoded
+	}
+	switch pub := k.public.(type) {
+	case ed25519.PublicKey:
+		content, err := readUnhashed(r)
+		if err != nil {
+			return err
+		}
+		if !ed25519.Verify(pub, content, sig) {
+			return ErrMismatch
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		digest, err := sha256Sum(r)
+		if err != nil {
+			return err
+		}
+		if !ecdsa.VerifyASN1(pub, digest, sig) {
+			return ErrMismatch
+		}
+		return nil
+	case *rsa.PublicKey:
+		digest, err := sha256Sum(r)
+		if err != nil {
+			return err
+		}
+		if rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest, sig) != nil {
+			return ErrMismatch
+		}
+		return nil
+	}
+	return errors.New("unsupported public key type")
+}
+
+// verifyMinisign checks the signature line and, when the trusted comment
+// came along, the global signature that covers it.
+func (k *Key) verifyMinisign(r io.Reader, sig []byte) error {
+	lines := contentLines(string(sig))
+	if len(lines) == 0 {
+		return errors.New("signature is empty")
+	}
+	raw, err := base64.StdEncoding.DecodeString(lines[0])
+	if err != nil || len(raw) != 2+8+ed25519.SignatureSize {
+		return errors.New("invalid minisign signature")
+	}
+	if minisignKeyID(raw[2:10]) != k.ID {
+		return ErrMismatch
+	}
+	pub := k.public.(ed25519.PublicKey)
+	signature := raw[10:]
+
+	var message []byte
+	switch string(raw[:2]) {
+	case "ED":
+		h, _ := blake2b.New512(nil)
+		if _, err := io.Copy(h, r); err != nil {
+			return err
+		}
+		message = h.Sum(nil)
+	case "Ed":
+		if message, err = readUnhashed(r); err != nil {
+			return err
+		}
+	default:
+		return errors.New("unsupported minisign signature algorithm")
+	}
+	if !ed25519.Verify(pub, message, signature) {
+		return ErrMismatch
+	}
+
+	if len(lines) < 3 {
+		return nil
+	}
+	comment, ok := strings.CutPrefix(lines[1], "trusted comment: ")
+	global, err := base64.StdEncoding.DecodeString(lines[2])
+	if !ok || err != nil || !ed25519.Verify(pub, append(append([]byte{}, signature...), comment...), global) {
+		return ErrMismatch
+	}
+	return nil
+}
+
+// contentLines returns the non-empty lines of a key or signature file
+// without the untrusted comment minisign puts first.
+func contentLines(text string) []string {
+	var lines []string
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" || strings.HasPrefix(line, "untrusted comment:") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// minisignKeyID formats a key ID the way minisign prints it.
+func minisignKeyID(raw []byte) string {
+	return fmt.Sprintf("%016X", binary.LittleEndian.Uint64(raw))
+}
+
+func readUnhashed(r io.Reader) ([]byte, error) {
+	content, err := io.ReadAll(io.LimitReader(r, maxUnhashed+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > maxUnhashed {
+		return nil, errors.New("content is too large for a signature without prehashing")
+	}
+	return content, nil
+}
+
+func sha256Sum(r io.Reader) ([]byte, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,124 @@
+package provenance
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+var content = []byte("release artifact")
+
+func pemKey(t *testing.T, pub crypto.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+// minisignFiles makes a public key and a prehashed signature the way the
+// minisign tool writes them.
+func minisignFiles(t *testing.T, message []byte) (string, string) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	keyID := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	key := "untrusted comment: minisign public key 0807060504030201\n" +
+		base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), keyID...), pub...)) + "\n"
+
+	digest := blake2b.Sum512(message)
+	signature := ed25519.Sign(priv, digest[:])
+	comment := "timestamp:1760000000\tfile:artifact.tar.gz"
+	global := ed25519.Sign(priv, append(append([]byte{}, signature...), comment...))
+	sig := "untrusted comment: signature from minisign secret key\n" +
+		base64.StdEncoding.EncodeToString(append(append([]byte("ED"), keyID...), signature...)) + "\n" +
+		"trusted comment: " + comment + "\n" +
+		base64.StdEncoding.EncodeToString(global) + "\n"
+	return key, sig
+}
+
+func TestMinisign(t *testing.T) {
+	keyText, sig := minisignFiles(t, content)
+	key, err := ParseKey(keyText)
+	if err != nil {
+		t.Fatalf("ParseKey failed: %v", err)
+	}
+	if key.Algorithm != Minisign || key.ID != "0807060504030201" {
+		t.Errorf("unexpected key %s %s", key.Algorithm, key.ID)
+	}
+	if err := key.Verify(bytes.NewReader(content), []byte(sig)); err != nil {
+		t.Errorf("expected the signature file to verify: %v", err)
+	}
+	line := strings.Split(sig, "\n")[1]
+	if err := key.Verify(bytes.NewReader(content), []byte(line)); err != nil {
+		t.Errorf("expected the bare signature line to verify: %v", err)
+	}
+	if err := key.Verify(bytes.NewReader([]byte("tampered")), []byte(sig)); !errors.Is(err, ErrMismatch) {
+		t.Errorf("expected tampered content to fail, got %v", err)
+	}
+	forged := strings.Replace(sig, "file:artifact", "file:other", 1)
+	if err := key.Verify(bytes.NewReader(content), []byte(forged)); !errors.Is(err, ErrMismatch) {
+		t.Errorf("expected an altered trusted comment to fail, got %v", err)
+	}
+
+	otherKey, _ := minisignFiles(t, content)
+	other, _ := ParseKey(otherKey)
+	if err := other.Verify(bytes.NewReader(content), []byte(sig)); !errors.Is(err, ErrMismatch) {
+		t.Errorf("expected another key to fail, got %v", err)
+	}
+}
+
+func TestPEMKeys(t *testing.T) {
+	digest := sha256.Sum256(content)
+
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ecSig, _ := ecdsa.SignASN1(rand.Reader, ecKey, digest[:])
+	edPub, edPriv, _ := ed25519.GenerateKey(rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	rsaSig, _ := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+
+	tests := []struct {
+		algorithm string
+		pub       crypto.PublicKey
+		sig       []byte
+	}{
+		// cosign writes base64, openssl raw bytes
+		{ECDSA, &ecKey.PublicKey, []byte(base64.StdEncoding.EncodeToString(ecSig) + "\n")},
+		{Ed25519, edPub, ed25519.Sign(edPriv, content)},
+		{RSA, &rsaKey.PublicKey, rsaSig},
+	}
+	for _, tt := range tests {
+		key, err := ParseKey(pemKey(t, tt.pub))
+		if err != nil {
+			t.Fatalf("ParseKey failed for %s: %v", tt.algorithm, err)
+		}
+		if key.Algorithm != tt.algorithm {
+			t.Errorf("expected %s, got %s", tt.algorithm, key.Algorithm)
+		}
+		if err := key.Verify(bytes.NewReader(content), tt.sig); err != nil {
+			t.Errorf("expected the %s signature to verify: %v", tt.algorithm, err)
+		}
+		if err := key.Verify(bytes.NewReader([]byte("tampered")), tt.sig); !errors.Is(err, ErrMismatch) {
+			t.Errorf("expected tampered content to fail for %s, got %v", tt.algorithm, err)
+		}
+	}
+}
+
+func TestParseKeyRejectsGarbage(t *testing.T) {
+	for _, text := range []string{"", "not a key", base64.StdEncoding.EncodeToString([]byte("Ed short"))} {
+		if _, err := ParseKey(text); err == nil {
+			t.Errorf("expected %q to be rejected", text)
+		}
+	}
+}
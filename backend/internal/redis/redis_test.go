@@ -0,0 +1,178 @@
+package redis
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis answers the commands the depot sends, keeping values in a map.
+func fakeRedis(t *testing.T, password string) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	var mu sync.Mutex
+	values := map[string]string{}
+	expires := map[string]time.Time{}
+	get := func(key string) (string, bool) {
+		if e, ok := expires[key]; ok && time.Now().After(e) {
+			delete(values, key)
+			delete(expires, key)
+		}
+		v, ok := values[key]
+		return v, ok
+	}
+
+	serve := func(conn net.Conn) {
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		authed := password == ""
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+			args := make([]string, n)
+			for i := range args {
+				line, _ := r.ReadString('\n')
+				size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+				buf := make([]byte, size+2)
+				io.ReadFull(r, buf)
+				args[i] = string(buf[:size])
+			}
+
+			mu.Lock()
+			var reply string
+			switch cmd := strings.ToUpper(args[0]); {
+			case cmd == "AUTH":
+				authed = args[len(args)-1] == password
+				reply = "+OK\r\n"
+				if !authed {
+					reply = "-WRONGPASS invalid password\r\n"
+				}
+			case !authed:
+				reply = "-NOAUTH Authentication required.\r\n"
+			case cmd == "PING":
+				reply = "+PONG\r\n"
+			case cmd == "GET":
+				if v, ok := get(args[1]); ok {
+					reply = "$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n"
+				} else {
+					reply = "$-1\r\n"
+				}
+			case cmd == "SET":
+				_, exists := get(args[1])
+				if len(args) > 3 && args[3] == "NX" && exists {
+					reply = "$-1\r\n"
+					break
+				}
+				values[args[1]] = args[2]
+				ms, _ := strconv.Atoi(args[len(args)-1])
+				expires[args[1]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+				reply = "+OK\r\n"
+			case cmd == "DEL":
+				delete(values, args[1])
+				reply = ":1\r\n"
+			case cmd == "EVAL" && args[1] == unlockScript:
+				if v, _ := get(args[3]); v == args[4] {
+					delete(values, args[3])
+				}
+				reply = ":1\r\n"
+			case cmd == "EVAL" && args[1] == incrScript:
+				v, ok := get(args[3])
+				n, _ := strconv.Atoi(v)
+				n++
+				values[args[3]] = strconv.Itoa(n)
+				if !ok {
+					ms, _ := strconv.Atoi(args[4])
+					expires[args[3]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+				}
+				reply = ":" + strconv.Itoa(n) + "\r\n"
+			default:
+				reply = "-ERR unknown command\r\n"
+			}
+			mu.Unlock()
+			conn.Write([]byte(reply))
+		}
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestClient(t *testing.T) {
+	addr := fakeRedis(t, "secret")
+
+	if _, err := New("http://" + addr); err == nil {
+		t.Error("accepted a URL that isn't redis://")
+	}
+	wrong, _ := New("redis://:nope@" + addr)
+	if err := wrong.Ping(t.Context()); err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Errorf("ping with a wrong password: %v", err)
+	}
+
+	c, err := New("redis://:secret@" + addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.Ping(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Do(t.Context(), "NOPE"); err == nil {
+		t.Error("an error reply was not returned")
+	}
+	// The connection is still usable after an error reply
+	if err := c.Ping(t.Context()); err != nil {
+		t.Errorf("ping after an error reply: %v", err)
+	}
+
+	locker := Locker{Client: c}
+	unlock, ok, err := locker.TryLock("blobs", time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("TryLock: %v %v", ok, err)
+	}
+	if _, ok, _ := locker.TryLock("blobs", time.Minute); ok {
+		t.Error("a held lock was taken again")
+	}
+	unlock()
+	if _, ok, _ := locker.TryLock("blobs", time.Minute); !ok {
+		t.Error("a released lock could not be taken")
+	}
+
+	cache := Cache{Client: c}
+	if _, ok := cache.Get("owner-name:a"); ok {
+		t.Error("hit on an empty cache")
+	}
+	cache.Set("owner-name:a", "Alice", time.Minute)
+	if v, ok := cache.Get("owner-name:a"); !ok || v != "Alice" {
+		t.Errorf("cache: %q %v", v, ok)
+	}
+	cache.Delete("owner-name:a")
+	if _, ok := cache.Get("owner-name:a"); ok {
+		t.Error("hit after delete")
+	}
+
+	counters := Counters{Client: c}
+	for want := int64(1); want <= 3; want++ {
+		if n, err := counters.Incr("rate:a", time.Minute); err != nil || n != want {
+			t.Errorf("Incr = %d %v, want %d", n, err, want)
+		}
+	}
+}
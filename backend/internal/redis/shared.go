@@ -0,0 +1,82 @@
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"strconv"
+	"time"
+)
+
+// unlockScript deletes a lease only while it is still ours, so a lease
+// that expired and went to someone else isn't released from under them.
+const unlockScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) end return 0`
+
+// incrScript counts one more in a window that starts with the first count.
+const incrScript = `local n = redis.call("incr", KEYS[1]) if n == 1 then redis.call("pexpire", KEYS[1], ARGV[1]) end return n`
+
+// millis is d in whole milliseconds, at least one, as Redis expects.
+func millis(d time.Duration) string {
+	return strconv.FormatInt(max(d.Milliseconds(), 1), 10)
+}
+
+// Locker hands out leases with SET NX, which unlike the store's leases
+// need no timing assumptions.
+type Locker struct {
+	Client *Client
+}
+
+func (l Locker) TryLock(name string, ttl time.Duration) (func(), bool, error) {
+	b := make([]byte, 16)
+	rand.Read(b)
+	token := hex.EncodeToString(b)
+	key := keyPrefix + "lock:" + name
+
+	reply, err := l.Client.Do(context.Background(), "SET", key, token, "NX", "PX", millis(ttl))
+	if err != nil || reply == nil {
+		return nil, false, err
+	}
+	return func() {
+		if _, err := l.Client.Do(context.Background(), "EVAL", unlockScript, "1", key, token); err != nil {
+			log.Printf("[ERROR] Failed to release lock %s: %v", name, err)
+		}
+	}, true, nil
+}
+
+// Cache keeps short-lived strings. A server that can't be reached is a
+// miss, since whatever is cached can be found in the store.
+type Cache struct {
+	Client *Client
+}
+
+func (c Cache) Get(key string) (string, bool) {
+	reply, err := c.Client.Do(context.Background(), "GET", keyPrefix+"cache:"+key)
+	value, ok := reply.(string)
+	return value, err == nil && ok
+}
+
+func (c Cache) Set(key, value string, ttl time.Duration) {
+	c.Client.Do(context.Background(), "SET", keyPrefix+"cache:"+key, value, "PX", millis(ttl))
+}
+
+func (c Cache) Delete(key string) {
+	c.Client.Do(context.Background(), "DEL", keyPrefix+"cache:"+key)
+}
+
+// Counters counts events in fixed windows, for rate limits that hold
+// across replicas.
+type Counters struct {
+	Client *Client
+}
+
+// Incr counts one more for key and returns the count so far in the window
+// that started with the first.
+func (c Counters) Incr(key string, window time.Duration) (int64, error) {
+	reply, err := c.Client.Do(context.Background(), "EVAL", incrScript, "1", keyPrefix+"count:"+key, millis(window))
+	if err != nil {
+		return 0, err
+	}
+	n, _ := reply.(int64)
+	return n, nil
+}
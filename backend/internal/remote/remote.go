@@ -0,0 +1,160 @@
+// Package remote talks to the API of another depot instance, for mirroring
+// files to it and browsing the files it shares with federated depots.
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout bounds a request when the client has no HTTP client of its
+// own. Pushes move whole files, so it is generous.
+const DefaultTimeout = 30 * time.Minute
+
+// Client calls a depot as the client with ClientID, which is the remote's
+// API key for this depot, or anonymously without one.
+type Client struct {
+	BaseURL  string
+	ClientID string
+	HTTP     *http.Client
+}
+
+// File is what the remote reports about a file.
+type File struct {
+	ID           string `json:"id"`
+	OriginalName string `json:"original_name"`
+	Size         int64  `json:"size"`
+	SHA256       string `json:"sha256"`
+	DownloadLink string `json:"download_link"`
+	Folder       string `json:"folder,omitempty"`
+}
+
+// Upload is a file to push.
+type Upload struct {
+	Name   string
+	Folder string
+	Tags   []string
+	Size   int64
+	// SHA256 is compared with what the remote stored
+	SHA256 string
+}
+
+func (c *Client) client() *http.Client {
+	if c.HTTP != nil {
+		return c.HTTP
+	}
+	return &http.Client{Timeout: DefaultTimeout}
+}
+
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body io.Reader, header http.Header) (*http.Response, error) {
+	target := strings.TrimRight(c.BaseURL, "/") + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if c.ClientID != "" {
+		req.Header.Set("X-Client-ID", c.ClientID)
+	}
+	return c.client().Do(req)
+}
+
+// apiError turns an error response into an error carrying the remote's
+// message.
+func apiError(resp *http.Response) error {
+	var body struct {
+		Error string `json:"error"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body)
+	if body.Error == "" {
+		return fmt.Errorf("remote depot: %s", resp.Status)
+	}
+	return fmt.Errorf("remote depot: %s: %s", resp.Status, body.Error)
+}
+
+// Push uploads content through the remote's raw upload endpoint.
+func (c *Client) Push(ctx context.Context, u Upload, content io.Reader) (*File, error) {
+	// The name goes in the query, which carries any name a header can't
+	q := url.Values{"name": {u.Name}}
+	if u.Folder != "" {
+		q.Set("folder", u.Folder)
+	}
+	if len(u.Tags) > 0 {
+		q.Set("tags", strings.Join(u.Tags, ","))
+	}
+	req := io.Reader(content)
+	if u.Size > 0 {
+		req = io.LimitReader(content, u.Size)
+	}
+	resp, err := c.do(ctx, http.MethodPut, "/api/upload/raw", q, req, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+	var f File
+	if err := json.NewDecoder(resp.Body).Decode(&f); err != nil {
+		return nil, err
+	}
+	if u.SHA256 != "" && f.SHA256 != "" && f.SHA256 != u.SHA256 {
+		return nil, fmt.Errorf("remote depot stored content with checksum %s, expected %s", f.SHA256, u.SHA256)
+	}
+	return &f, nil
+}
+
+// PublicFile is a file another depot lists for federation.
+type PublicFile struct {
+	Name         string   `json:"name"`
+	Size         int64    `json:"size"`
+	SHA256       string   `json:"sha256"`
+	Folder       string   `json:"folder,omitempty"`
+	Tags         []string `json:"tags"`
+	UploadTime   int64    `json:"upload_time"`
+	ExpiresAt    int64    `json:"expires_at,omitempty"`
+	DownloadLink string   `json:"download_link"`
+	SignedBy     string   `json:"signed_by,omitempty"`
+}
+
+// Listing is a page of a depot's public files.
+type Listing struct {
+	Files []PublicFile `json:"files"`
+	Total int          `json:"total"`
+}
+
+// ListPublic fetches a page of the remote's public files. The query takes
+// the remote's filters: folder, tag, search, page and limit.
+func (c *Client) ListPublic(ctx context.Context, query url.Values) (*Listing, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/api/federation/files", query, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+	var l Listing
+	if err := json.NewDecoder(resp.Body).Decode(&l); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// Download requests the content behind a download link of the remote,
+// skipping its landing page. The header is sent along, e.g. for ranges.
+// The caller closes the response body.
+func (c *Client) Download(ctx context.Context, link string, header http.Header) (*http.Response, error) {
+	return c.do(ctx, http.MethodGet, "/api/download/"+url.PathEscape(link), url.Values{"confirm": {"1"}}, nil, header)
+}
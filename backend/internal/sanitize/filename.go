@@ -0,0 +1,60 @@
+package sanitize
+
+import (
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxFilenameBytes caps filenames at what common file systems accept.
+const MaxFilenameBytes = 255
+
+// FilenameFunc turns the name a client sent into the name a file is stored
+// and served under.
+type FilenameFunc func(name string) string
+
+// bidiControls reorder text when displayed, so a right-to-left override
+// makes "invoice<RLO>fdp.exe" read as "invoiceexe.pdf".
+var bidiControls = map[rune]bool{
+	'\u061c': true, '\u200e': true, '\u200f': true,
+	'\u202a': true, '\u202b': true, '\u202c': true, '\u202d': true, '\u202e': true,
+	'\u2066': true, '\u2067': true, '\u2068': true, '\u2069': true,
+}
+
+// Filename is the default FilenameFunc. It normalizes the name to NFC,
+// drops control and bidirectional formatting characters, replaces path
+// separators and trims surrounding space. Names longer than
+// MaxFilenameBytes are shortened before the extension. A name with nothing
+// left becomes "file".
+func Filename(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r == utf8.RuneError, unicode.IsControl(r), bidiControls[r]:
+			return -1
+		case r == '/', r == '\\':
+			return '_'
+		}
+		return r
+	}, norm.NFC.String(name))
+	name = strings.TrimSpace(name)
+	if strings.Trim(name, ".") == "" {
+		return "file"
+	}
+	if len(name) <= MaxFilenameBytes {
+		return name
+	}
+
+	ext := path.Ext(name)
+	if len(ext) > MaxFilenameBytes/2 {
+		ext = ""
+	}
+	stem := strings.TrimSuffix(name, ext)
+	cut := MaxFilenameBytes - len(ext)
+	for cut > 0 && !utf8.RuneStart(stem[cut]) {
+		cut--
+	}
+	return stem[:cut] + ext
+}
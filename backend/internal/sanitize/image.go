@@ -0,0 +1,136 @@
+package sanitize
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+var errMalformed = errors.New("malformed file")
+
+// JPEG drops EXIF/XMP (APP1), Photoshop/IPTC (APP13) and comment segments.
+type JPEG struct{}
+
+func (JPEG) Name() string { return "jpeg-exif" }
+
+func (JPEG) Match(name string, head []byte) bool {
+	return len(head) >= 3 && head[0] == 0xFF && head[1] == 0xD8 && head[2] == 0xFF
+}
+
+func (JPEG) Sanitize(src io.ReaderAt, size int64, dst io.Writer) error {
+	r := io.NewSectionReader(src, 0, size)
+
+	soi := make([]byte, 2)
+	if _, err := io.ReadFull(r, soi); err != nil {
+		return err
+	}
+	if _, err := dst.Write(soi); err != nil {
+		return err
+	}
+
+	marker := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(r, marker[:2]); err != nil {
+			return err
+		}
+		if marker[0] != 0xFF {
+			return errMalformed
+		}
+		// Any number of 0xFF fill bytes may precede the marker; they carry
+		// nothing and are dropped
+		for marker[1] == 0xFF {
+			if _, err := io.ReadFull(r, marker[1:2]); err != nil {
+				return err
+			}
+		}
+		// Start of scan: the rest is entropy coded image data
+		if marker[1] == 0xDA {
+			if _, err := dst.Write(marker[:2]); err != nil {
+				return err
+			}
+			_, err := io.Copy(dst, r)
+			return err
+		}
+		if marker[1] == 0xD9 {
+			_, err := dst.Write(marker[:2])
+			return err
+		}
+
+		if _, err := io.ReadFull(r, marker[2:4]); err != nil {
+			return err
+		}
+		length := int64(binary.BigEndian.Uint16(marker[2:4]))
+		if length < 2 {
+			return errMalformed
+		}
+		payload := make([]byte, length-2)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return err
+		}
+
+		switch marker[1] {
+		case 0xE1, 0xED, 0xFE: // APP1, APP13, COM
+			continue
+		}
+		if _, err := dst.Write(marker); err != nil {
+			return err
+		}
+		if _, err := dst.Write(payload); err != nil {
+			return err
+		}
+	}
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// PNG drops textual, EXIF and timestamp chunks.
+type PNG struct{}
+
+func (PNG) Name() string { return "png-text" }
+
+func (PNG) Match(name string, head []byte) bool {
+	return bytes.HasPrefix(head, pngSignature)
+}
+
+func (PNG) Sanitize(src io.ReaderAt, size int64, dst io.Writer) error {
+	r := io.NewSectionReader(src, 0, size)
+
+	sig := make([]byte, len(pngSignature))
+	if _, err := io.ReadFull(r, sig); err != nil {
+		return err
+	}
+	if _, err := dst.Write(sig); err != nil {
+		return err
+	}
+
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		length := int64(binary.BigEndian.Uint32(header[:4]))
+		chunkType := string(header[4:8])
+
+		switch chunkType {
+		case "tEXt", "zTXt", "iTXt", "eXIf", "tIME":
+			if _, err := r.Seek(length+4, io.SeekCurrent); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if _, err := dst.Write(header); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(dst, r, length+4); err != nil {
+			return err
+		}
+		if chunkType == "IEND" {
+			return nil
+		}
+	}
+}
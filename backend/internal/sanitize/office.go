@@ -0,0 +1,61 @@
+package sanitize
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+const emptyCoreProperties = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:dcterms="http://purl.org/dc/terms/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"/>`
+
+// OOXML blanks the core properties (author, last modified by, dates) of
+// Word, Excel and PowerPoint documents.
+type OOXML struct{}
+
+func (OOXML) Name() string { return "ooxml-core" }
+
+func (OOXML) Match(name string, head []byte) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".docx", ".xlsx", ".pptx", ".docm", ".xlsm", ".pptm":
+		return bytes.HasPrefix(head, []byte("PK\x03\x04"))
+	}
+	return false
+}
+
+func (OOXML) Sanitize(src io.ReaderAt, size int64, dst io.Writer) error {
+	zr, err := zip.NewReader(src, size)
+	if err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(dst)
+	for _, f := range zr.File {
+		if f.Name == "docProps/core.xml" {
+			w, err := zw.CreateHeader(&zip.FileHeader{Name: f.Name, Method: zip.Deflate})
+			if err != nil {
+				return err
+			}
+			if _, err := io.WriteString(w, emptyCoreProperties); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Copy everything else without recompressing
+		raw, err := f.OpenRaw()
+		if err != nil {
+			return err
+		}
+		w, err := zw.CreateRaw(&f.FileHeader)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(w, raw); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
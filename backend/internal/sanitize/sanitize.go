@@ -0,0 +1,87 @@
+package sanitize
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Sanitizer removes privacy sensitive metadata from a specific file format.
+type Sanitizer interface {
+	Name() string
+	Match(name string, head []byte) bool
+	Sanitize(src io.ReaderAt, size int64, dst io.Writer) error
+}
+
+// Pipeline applies every matching sanitizer in order.
+type Pipeline []Sanitizer
+
+// Default returns the built-in sanitizers for images and office documents.
+func Default() Pipeline {
+	return Pipeline{JPEG{}, PNG{}, OOXML{}}
+}
+
+// Apply rewrites the file at path in place and returns the names of the
+// sanitizers that ran.
+func (p Pipeline) Apply(path, name string) ([]string, error) {
+	var applied []string
+	for _, s := range p {
+		head, err := readHead(path)
+		if err != nil {
+			return applied, err
+		}
+		if !s.Match(name, head) {
+			continue
+		}
+		if err := rewrite(path, s); err != nil {
+			return applied, fmt.Errorf("%s: %w", s.Name(), err)
+		}
+		applied = append(applied, s.Name())
+	}
+	return applied, nil
+}
+
+func rewrite(path string, s Sanitizer) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".sanitize-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := s.Sanitize(src, info.Size(), tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	src.Close()
+	return os.Rename(tmp.Name(), path)
+}
+
+func readHead(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	return head[:n], nil
+}
@@ -0,0 +1,133 @@
+package sanitize
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func testImage() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	img.Set(1, 1, color.RGBA{R: 255, A: 255})
+	return img
+}
+
+func TestStripJPEGExif(t *testing.T) {
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, testImage(), nil)
+	encoded := buf.Bytes()
+
+	// Splice an EXIF segment in right after SOI
+	payload := append([]byte("Exif\x00\x00"), []byte("GPS secret location")...)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	withExif := append(append(append([]byte{}, encoded[:2]...), append(segment, payload...)...), encoded[2:]...)
+
+	out := applyTo(t, "photo.jpg", withExif)
+	if bytes.Contains(out, []byte("secret")) {
+		t.Errorf("expected EXIF segment to be removed")
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(out)); err != nil {
+		t.Errorf("sanitized JPEG no longer decodes: %v", err)
+	}
+}
+
+func TestStripJPEGExifAfterFillBytes(t *testing.T) {
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, testImage(), nil)
+	encoded := buf.Bytes()
+
+	// Fill bytes before the EXIF marker and before the next one
+	payload := []byte("Exif\x00\x00GPS secret location")
+	segment := []byte{0xFF, 0xFF, 0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[4:], uint16(len(payload)+2))
+	withExif := append(append([]byte{}, encoded[:2]...), segment...)
+	withExif = append(withExif, payload...)
+	withExif = append(withExif, 0xFF, 0xFF)
+	withExif = append(withExif, encoded[2:]...)
+
+	out := applyTo(t, "photo.jpg", withExif)
+	if bytes.Contains(out, []byte("secret")) {
+		t.Errorf("expected EXIF segment behind fill bytes to be removed")
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(out)); err != nil {
+		t.Errorf("sanitized JPEG no longer decodes: %v", err)
+	}
+}
+
+func TestStripPNGText(t *testing.T) {
+	var buf bytes.Buffer
+	png.Encode(&buf, testImage())
+	encoded := buf.Bytes()
+
+	// Insert a tEXt chunk after the IHDR chunk (8 byte signature + 25 bytes)
+	data := []byte("Author\x00secret person")
+	chunk := make([]byte, 8)
+	binary.BigEndian.PutUint32(chunk, uint32(len(data)))
+	copy(chunk[4:], "tEXt")
+	chunk = append(chunk, data...)
+	crc := make([]byte, 4)
+	binary.BigEndian.PutUint32(crc, crc32.ChecksumIEEE(append([]byte("tEXt"), data...)))
+	chunk = append(chunk, crc...)
+	withText := append(append(append([]byte{}, encoded[:33]...), chunk...), encoded[33:]...)
+
+	out := applyTo(t, "shot.png", withText)
+	if bytes.Contains(out, []byte("secret")) {
+		t.Errorf("expected tEXt chunk to be removed")
+	}
+	if _, err := png.Decode(bytes.NewReader(out)); err != nil {
+		t.Errorf("sanitized PNG no longer decodes: %v", err)
+	}
+}
+
+func applyTo(t *testing.T, name string, content []byte) []byte {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "blob")
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	applied, err := Default().Apply(path, name)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if len(applied) != 1 {
+		t.Fatalf("expected one sanitizer to run, got %v", applied)
+	}
+	out, _ := os.ReadFile(path)
+	return out
+}
+
+func TestFilename(t *testing.T) {
+	long := strings.Repeat("\u00e9", 200) + ".tar.gz"
+	tests := []struct {
+		in, want string
+	}{
+		{"report.pdf", "report.pdf"},
+		{"cafe\u0301.txt", "caf\u00e9.txt"},
+		{"invoice\u202efdp.exe", "invoicefdp.exe"},
+		{"a\r\nContent-Type: text/html.txt", "aContent-Type: text_html.txt"},
+		{"../etc/passwd", ".._etc_passwd"},
+		{"  notes.md ", "notes.md"},
+		{"..", "file"},
+		{"\x00\x01", "file"},
+	}
+	for _, tt := range tests {
+		if got := Filename(tt.in); got != tt.want {
+			t.Errorf("Filename(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	got := Filename(long)
+	if len(got) > MaxFilenameBytes || !strings.HasSuffix(got, ".gz") || !utf8.ValidString(got) {
+		t.Errorf("expected a valid name of at most %d bytes keeping the extension, got %d bytes %q", MaxFilenameBytes, len(got), got)
+	}
+}
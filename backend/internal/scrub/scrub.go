@@ -0,0 +1,214 @@
+package scrub
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/celerix/depot/internal/db"
+)
+
+// Scrubber re-verifies stored blobs against their recorded SHA-256.
+type Scrubber struct {
+	Store db.CelerixStore
+	// BytesPerSecond paces reads so scrubbing doesn't starve transfers;
+	// zero means unlimited.
+	BytesPerSecond int64
+	// ReplicaDir, when set, is searched for a good copy of corrupted blobs,
+	// either under the blob's stored file name or its hash.
+	ReplicaDir string
+	// Notify is called for every corrupted blob.
+	Notify func(Result)
+
+	mu      sync.Mutex
+	running bool
+	last    *Summary
+}
+
+type Result struct {
+	Hash       string `json:"hash"`
+	StoredPath string `json:"stored_path"`
+	Error      string `json:"error,omitempty"`
+	Recovered  bool   `json:"recovered"`
+}
+
+type Summary struct {
+	Checked   int      `json:"checked"`
+	Bytes     int64    `json:"bytes"`
+	Corrupted []Result `json:"corrupted"`
+}
+
+// Last returns the summary of the most recent completed pass.
+func (s *Scrubber) Last() *Summary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last
+}
+
+// Run checks every blob once, least recently checked first. Concurrent
+// calls return immediately while a pass is in progress.
+func (s *Scrubber) Run(ctx context.Context) (*Summary, error) {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return nil, nil
+	}
+	s.running = true
+	s.mu.Unlock()
+
+	summary, err := s.run(ctx)
+
+	s.mu.Lock()
+	s.running = false
+	if err == nil {
+		s.last = summary
+	}
+	s.mu.Unlock()
+	return summary, err
+}
+
+func (s *Scrubber) run(ctx context.Context) (*Summary, error) {
+	blobs, err := db.ListBlobs(s.Store)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(blobs, func(i, j int) bool { return blobs[i].CheckedAt < blobs[j].CheckedAt })
+
+	summary := &Summary{}
+	for _, b := range blobs {
+		if err := ctx.Err(); err != nil {
+			return summary, err
+		}
+
+		n, err := s.verify(ctx, b.StoredPath, b.Hash)
+		summary.Checked++
+		summary.Bytes += n
+		if err == nil {
+			_ = db.MarkBlobChecked(s.Store, b.Hash, time.Now().Unix(), false)
+			continue
+		}
+		if ctx.Err() != nil {
+			return summary, ctx.Err()
+		}
+
+		result := Result{Hash: b.Hash, StoredPath: b.StoredPath, Error: err.Error()}
+		if s.recover(ctx, b) {
+			result.Recovered = true
+		}
+		_ = db.MarkBlobChecked(s.Store, b.Hash, time.Now().Unix(), !result.Recovered)
+		_ = db.AddAudit(s.Store, db.AuditEntry{
+			Event:  "scrub",
+			Action: map[bool]string{true: "recovered", false: "corrupted"}[result.Recovered],
+			Name:   b.StoredPath,
+			Detail: b.Hash + ": " + result.Error,
+		})
+		log.Printf("[ERROR] Scrub found corrupted blob %s at %s: %s (recovered=%v)", b.Hash, b.StoredPath, result.Error, result.Recovered)
+		summary.Corrupted = append(summary.Corrupted, result)
+		if s.Notify != nil {
+			s.Notify(result)
+		}
+	}
+	return summary, nil
+}
+
+func (s *Scrubber) verify(ctx context.Context, path, want string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	n, err := io.Copy(hasher, &pacedReader{ctx: ctx, r: f, rate: s.BytesPerSecond, start: time.Now()})
+	if err != nil {
+		return n, err
+	}
+	if got := hex.EncodeToString(hasher.Sum(nil)); got != want {
+		return n, fmt.Errorf("checksum mismatch: got %s", got)
+	}
+	return n, nil
+}
+
+func (s *Scrubber) recover(ctx context.Context, b db.BlobRecord) bool {
+	if s.ReplicaDir == "" {
+		return false
+	}
+	for _, candidate := range []string{
+		filepath.Join(s.ReplicaDir, filepath.Base(b.StoredPath)),
+		filepath.Join(s.ReplicaDir, b.Hash),
+	} {
+		if _, err := s.verify(ctx, candidate, b.Hash); err != nil {
+			continue
+		}
+		if err := copyFile(candidate, b.StoredPath); err != nil {
+			log.Printf("[ERROR] Failed to restore %s from replica: %v", b.StoredPath, err)
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".restore-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+// pacedReader sleeps as needed to keep the average read rate under rate
+// bytes per second.
+type pacedReader struct {
+	ctx   context.Context
+	r     io.Reader
+	rate  int64
+	start time.Time
+	read  int64
+}
+
+func (p *pacedReader) Read(b []byte) (int, error) {
+	if err := p.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := p.r.Read(b)
+	p.read += int64(n)
+	if p.rate > 0 {
+		expected := time.Duration(float64(p.read) / float64(p.rate) * float64(time.Second))
+		if wait := expected - time.Since(p.start); wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-p.ctx.Done():
+				return n, p.ctx.Err()
+			}
+		}
+	}
+	return n, err
+}
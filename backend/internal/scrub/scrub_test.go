@@ -0,0 +1,58 @@
+package scrub
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/celerix-dev/celerix-store/pkg/engine"
+	"github.com/celerix/depot/internal/db"
+)
+
+func TestScrubDetectsAndRecovers(t *testing.T) {
+	store := engine.NewMemStore(nil, nil)
+	dir := t.TempDir()
+	replica := t.TempDir()
+
+	content := []byte("precious bytes")
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+	path := filepath.Join(dir, "blob-1")
+
+	os.WriteFile(path, []byte("bit rotted!!!!"), 0644)
+	os.WriteFile(filepath.Join(replica, "blob-1"), content, 0644)
+	if _, _, err := db.AcquireBlob(store, hash, int64(len(content)), path); err != nil {
+		t.Fatal(err)
+	}
+
+	var notified []Result
+	s := &Scrubber{Store: store, Notify: func(r Result) { notified = append(notified, r) }}
+
+	// Without a replica the blob is flagged as corrupted
+	summary, err := s.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(summary.Corrupted) != 1 || len(notified) != 1 {
+		t.Fatalf("expected one corrupted blob to be reported, got %+v", summary)
+	}
+	if blob, _ := db.GetBlob(store, hash); !blob.Corrupted {
+		t.Errorf("expected blob to be flagged corrupted")
+	}
+
+	// With a replica it is restored
+	s.ReplicaDir = replica
+	summary, _ = s.Run(context.Background())
+	if len(summary.Corrupted) != 1 || !summary.Corrupted[0].Recovered {
+		t.Fatalf("expected blob to be recovered, got %+v", summary)
+	}
+	if got, _ := os.ReadFile(path); string(got) != string(content) {
+		t.Errorf("expected content to be restored from replica")
+	}
+	if blob, _ := db.GetBlob(store, hash); blob.Corrupted {
+		t.Errorf("expected corrupted flag to be cleared after recovery")
+	}
+}
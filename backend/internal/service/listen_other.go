@@ -0,0 +1,27 @@
+//go:build !(linux || darwin || freebsd)
+
+package service
+
+import (
+	"net"
+	"os"
+)
+
+// ReopenSignals is empty: this platform has no signal to ask for the log
+// file to be reopened.
+var ReopenSignals []os.Signal
+
+// Listeners returns nil: socket activation is not available on this
+// platform.
+func Listeners() ([]net.Listener, error) {
+	return nil, nil
+}
+
+func processRunning(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
@@ -0,0 +1,64 @@
+//go:build linux || darwin || freebsd
+
+package service
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// listenFDsStart is the first descriptor systemd passes.
+const listenFDsStart = 3
+
+// ReopenSignals are the signals that ask for the log file to be reopened.
+var ReopenSignals = []os.Signal{syscall.SIGUSR1}
+
+// Listeners returns the sockets passed by systemd socket activation
+// (LISTEN_PID and LISTEN_FDS), or nil when the process was started without
+// them. The variables are removed so child processes don't take them too.
+func Listeners() ([]net.Listener, error) {
+	return listeners(listenFDsStart)
+}
+
+func listeners(first int) ([]net.Listener, error) {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil, nil
+	}
+
+	lns := make([]net.Listener, 0, n)
+	for fd := first; fd < first+n; fd++ {
+		syscall.CloseOnExec(fd)
+		file := os.NewFile(uintptr(fd), "listen-fd-"+strconv.Itoa(fd))
+		ln, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			for _, ln := range lns {
+				ln.Close()
+			}
+			return nil, fmt.Errorf("passed socket %d: %w", fd, err)
+		}
+		if _, ok := ln.(*net.UnixListener); ok {
+			ln = localListener{ln}
+		}
+		lns = append(lns, ln)
+	}
+	return lns, nil
+}
+
+func processRunning(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,53 @@
+//go:build linux || darwin || freebsd
+
+package service
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+func TestListeners(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	file, err := ln.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// listeners takes over the descriptor, as it would systemd's
+	fd, err := syscall.Dup(int(file.Fd()))
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lns, err := listeners(fd); err != nil || lns != nil {
+		t.Fatalf("expected no sockets without LISTEN_PID, got %v, %v", lns, err)
+	}
+
+	t.Setenv("LISTEN_PID", "1")
+	t.Setenv("LISTEN_FDS", "1")
+	if lns, _ := listeners(fd); lns != nil {
+		t.Error("expected sockets meant for another process to be ignored")
+	}
+
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "1")
+	lns, err := listeners(fd)
+	if err != nil || len(lns) != 1 {
+		t.Fatalf("expected the passed socket, got %v, %v", lns, err)
+	}
+	defer lns[0].Close()
+	if lns[0].Addr().String() != ln.Addr().String() {
+		t.Errorf("expected %s, got %s", ln.Addr(), lns[0].Addr())
+	}
+	if os.Getenv("LISTEN_FDS") != "" {
+		t.Error("expected the variables to be cleared")
+	}
+}
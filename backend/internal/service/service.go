@@ -0,0 +1,82 @@
+// Package service runs the depot as a system service: it takes the sockets
+// passed by systemd socket activation, keeps a PID file, writes the log to
+// a file that can be reopened after rotation, and installs the depot with
+// systemd or the Windows service manager.
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// WritePIDFile records the process ID at path. It refuses when the file
+// names a process that is still running, so two depots don't share a data
+// directory by accident; a file left behind by a crash is replaced.
+func WritePIDFile(path string) error {
+	if data, err := os.ReadFile(path); err == nil {
+		pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err == nil && pid != os.Getpid() && processRunning(pid) {
+			return fmt.Errorf("%s: depot is already running as process %d", path, pid)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644)
+}
+
+// RemovePIDFile removes path if it still holds this process's ID.
+func RemovePIDFile(path string) {
+	data, err := os.ReadFile(path)
+	if err == nil && strings.TrimSpace(string(data)) == strconv.Itoa(os.Getpid()) {
+		os.Remove(path)
+	}
+}
+
+// LogFile appends to a file and can reopen it, for log rotation that
+// moves the file away.
+type LogFile struct {
+	path string
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+func OpenLog(path string) (*LogFile, error) {
+	l := &LogFile{path: path}
+	if err := l.Reopen(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *LogFile) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Write(p)
+}
+
+// Reopen switches to a new file at the path. On failure the log stays with
+// the old file.
+func (l *LogFile) Reopen() error {
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file != nil {
+		l.file.Close()
+	}
+	l.file = file
+	return nil
+}
+
+func (l *LogFile) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
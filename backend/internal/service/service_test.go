@@ -0,0 +1,90 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestPIDFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "depot.pid")
+	if err := WritePIDFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); strings.TrimSpace(string(data)) != strconv.Itoa(os.Getpid()) {
+		t.Errorf("expected our PID, got %q", data)
+	}
+	// Starting again from the same process is not a conflict
+	if err := WritePIDFile(path); err != nil {
+		t.Errorf("expected our own PID file to be rewritten, got %v", err)
+	}
+
+	// Process 1 always runs
+	os.WriteFile(path, []byte("1\n"), 0644)
+	if err := WritePIDFile(path); err == nil {
+		t.Error("expected a running process to block the PID file")
+	}
+	RemovePIDFile(path)
+	if _, err := os.Stat(path); err != nil {
+		t.Error("expected another process's PID file to be kept")
+	}
+
+	os.WriteFile(path, []byte("not a pid"), 0644)
+	if err := WritePIDFile(path); err != nil {
+		t.Errorf("expected a stale PID file to be replaced, got %v", err)
+	}
+	RemovePIDFile(path)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("expected the PID file to be removed")
+	}
+}
+
+func TestLogFileReopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "depot.log")
+	l, err := OpenLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	l.Write([]byte("before\n"))
+	os.Rename(path, path+".1")
+	l.Write([]byte("rotating\n"))
+	if err := l.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	l.Write([]byte("after\n"))
+
+	if old, _ := os.ReadFile(path + ".1"); string(old) != "before\nrotating\n" {
+		t.Errorf("rotated file = %q", old)
+	}
+	if current, _ := os.ReadFile(path); string(current) != "after\n" {
+		t.Errorf("reopened file = %q", current)
+	}
+}
+
+func TestUnit(t *testing.T) {
+	u := Unit{Name: "depot", Exec: "/opt/my depot/depot", Env: []string{"CONFIG_FILE=/etc/depot.env"}, User: "depot", Listen: "8080"}
+	service := u.Service()
+	for _, want := range []string{
+		`ExecStart="/opt/my depot/depot"`,
+		"Environment=CONFIG_FILE=/etc/depot.env",
+		"User=depot",
+		"Requires=depot.socket",
+		"ExecReload=/bin/kill -HUP $MAINPID",
+	} {
+		if !strings.Contains(service, want) {
+			t.Errorf("expected %q in the service unit:\n%s", want, service)
+		}
+	}
+	if !strings.Contains(u.Socket(), "ListenStream=8080") {
+		t.Errorf("unexpected socket unit:\n%s", u.Socket())
+	}
+	u.Listen = ""
+	if u.Socket() != "" || strings.Contains(u.Service(), "Requires=") {
+		t.Error("expected no socket without Listen")
+	}
+}
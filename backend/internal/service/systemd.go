@@ -0,0 +1,65 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Unit describes the depot as a systemd service.
+type Unit struct {
+	// Name names the unit files, <Name>.service and <Name>.socket
+	Name string
+	// Exec is the absolute path of the depot binary
+	Exec string
+	// Env holds KEY=VALUE settings for the service, such as CONFIG_FILE
+	Env []string
+	// User runs the service; empty runs it as root
+	User       string
+	WorkingDir string
+	// Listen adds a socket unit listening there (e.g. 8080 or
+	// 127.0.0.1:8080), so systemd holds the port and starts the depot on
+	// the first connection
+	Listen string
+}
+
+// quoteArg quotes a value for a systemd unit line when it has spaces.
+func quoteArg(s string) string {
+	if strings.ContainsAny(s, " \t\"\\") {
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+	}
+	return s
+}
+
+// Service returns the service unit. Reloading sends SIGHUP, which re-reads
+// the configuration.
+func (u Unit) Service() string {
+	var b strings.Builder
+	b.WriteString("[Unit]\nDescription=Celerix Depot\n")
+	b.WriteString("After=network-online.target\nWants=network-online.target\n")
+	if u.Listen != "" {
+		fmt.Fprintf(&b, "Requires=%s.socket\n", u.Name)
+	}
+	b.WriteString("\n[Service]\nType=simple\n")
+	fmt.Fprintf(&b, "ExecStart=%s\n", quoteArg(u.Exec))
+	b.WriteString("ExecReload=/bin/kill -HUP $MAINPID\n")
+	for _, kv := range u.Env {
+		fmt.Fprintf(&b, "Environment=%s\n", quoteArg(kv))
+	}
+	if u.User != "" {
+		fmt.Fprintf(&b, "User=%s\n", u.User)
+	}
+	if u.WorkingDir != "" {
+		fmt.Fprintf(&b, "WorkingDirectory=%s\n", quoteArg(u.WorkingDir))
+	}
+	b.WriteString("Restart=on-failure\nTimeoutStopSec=60\n")
+	b.WriteString("\n[Install]\nWantedBy=multi-user.target\n")
+	return b.String()
+}
+
+// Socket returns the socket unit, or "" without Listen.
+func (u Unit) Socket() string {
+	if u.Listen == "" {
+		return ""
+	}
+	return fmt.Sprintf("[Unit]\nDescription=Celerix Depot socket\n\n[Socket]\nListenStream=%s\n\n[Install]\nWantedBy=sockets.target\n", u.Listen)
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+// loopback is the remote address of connections on a Unix socket.
+var loopback = &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}
+
+// localListener reports its connections as coming from 127.0.0.1. A Unix
+// socket has no peer address, so this lets a proxy on one count as a local
+// proxy, whose forwarded headers are trusted by default.
+type localListener struct {
+	net.Listener
+}
+
+func (l localListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return localConn{conn}, nil
+}
+
+type localConn struct {
+	net.Conn
+}
+
+func (localConn) RemoteAddr() net.Addr {
+	return loopback
+}
+
+// ListenUnix listens on a Unix socket at path with the given permissions.
+// A socket file left behind by a depot that is gone is replaced, one that
+// still answers is not. Connections count as from 127.0.0.1.
+func ListenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is in use", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return localListener{ln}, nil
+}
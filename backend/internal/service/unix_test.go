@@ -0,0 +1,57 @@
+//go:build linux || darwin || freebsd
+
+package service
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListenUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "depot.sock")
+	ln, err := ListenUnix(path, 0660)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi, _ := os.Stat(path); fi.Mode().Perm() != 0660 {
+		t.Errorf("expected mode 0660, got %v", fi.Mode().Perm())
+	}
+	if _, err := ListenUnix(path, 0660); err == nil {
+		t.Error("expected a socket in use to be refused")
+	}
+
+	var remote string
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { remote = r.RemoteAddr })}
+	go srv.Serve(ln)
+	client := &http.Client{Transport: &http.Transport{Dial: func(string, string) (net.Conn, error) { return net.Dial("unix", path) }}}
+	resp, err := client.Get("http://depot/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if host, _, _ := net.SplitHostPort(remote); host != "127.0.0.1" {
+		t.Errorf("expected connections to come from loopback, got %q", remote)
+	}
+	srv.Close()
+
+	// A socket file left by a crash is taken over
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+	ln, err = ListenUnix(path, 0600)
+	if err != nil {
+		t.Fatalf("expected a stale socket to be replaced, got %v", err)
+	}
+	ln.Close()
+
+	os.WriteFile(path, []byte("x"), 0644)
+	if _, err := ListenUnix(path, 0600); err == nil {
+		t.Error("expected a regular file to be left alone")
+	}
+}
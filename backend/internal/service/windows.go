@@ -0,0 +1,85 @@
+//go:build windows
+
+package service
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows/registry"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// IsWindowsService reports whether the service manager started the
+// process.
+func IsWindowsService() bool {
+	ok, err := svc.IsWindowsService()
+	return err == nil && ok
+}
+
+type handler struct {
+	run, stop func()
+}
+
+func (h *handler) Execute(_ []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.run()
+	}()
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for {
+		select {
+		case <-done:
+			return false, 0
+		case r := <-requests:
+			switch r.Cmd {
+			case svc.Interrogate:
+				status <- r.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending}
+				h.stop()
+				<-done
+				return false, 0
+			}
+		}
+	}
+}
+
+// RunWindowsService runs run as the named service, calling stop when the
+// service manager asks it to stop, and returns once run does.
+func RunWindowsService(name string, run, stop func()) error {
+	return svc.Run(name, &handler{run: run, stop: stop})
+}
+
+// InstallWindowsService registers exe as a service that starts with
+// Windows, with env ("KEY=VALUE") in its environment.
+func InstallWindowsService(name, exe string, env []string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	if s, err := m.OpenService(name); err == nil {
+		s.Close()
+		return fmt.Errorf("service %s already exists", name)
+	}
+	s, err := m.CreateService(name, exe, mgr.Config{
+		DisplayName: "Celerix Depot",
+		StartType:   mgr.StartAutomatic,
+	})
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	if len(env) == 0 {
+		return nil
+	}
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, `SYSTEM\CurrentControlSet\Services\`+name, registry.SET_VALUE)
+	if err != nil {
+		return err
+	}
+	defer key.Close()
+	return key.SetStringsValue("Environment", env)
+}
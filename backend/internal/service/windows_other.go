@@ -0,0 +1,18 @@
+//go:build !windows
+
+package service
+
+import "errors"
+
+// IsWindowsService reports false: there is no Windows service manager.
+func IsWindowsService() bool {
+	return false
+}
+
+func RunWindowsService(name string, run, stop func()) error {
+	return errors.ErrUnsupported
+}
+
+func InstallWindowsService(name, exe string, env []string) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,203 @@
+// Package static serves the embedded web frontend with cache headers that
+// suit a fingerprinted build, and compressed when the browser accepts it.
+package static
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// hashedDir holds the build output whose names carry a content hash,
+	// so a changed file always gets a new name
+	hashedDir = "assets/"
+	// minCompressSize is the smallest file worth compressing at startup
+	minCompressSize = 1024
+
+	immutable   = "public, max-age=31536000, immutable"
+	revalidated = "no-cache"
+)
+
+// encodings lists the content encodings served, most preferred first,
+// with the suffix of their precompressed files.
+var encodings = []struct{ name, ext string }{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+type variant struct {
+	encoding string
+	data     []byte
+	etag     string
+}
+
+type asset struct {
+	contentType  string
+	cacheControl string
+	// variants are the encoded forms, most preferred first, then the
+	// identity one
+	variants []variant
+}
+
+// Handler serves the files of a frontend build.
+type Handler struct {
+	assets map[string]*asset
+	index  *asset
+}
+
+// New loads the files of fsys. A file with a .br or .gz sibling is served
+// from that sibling to browsers accepting the encoding; compressible files
+// without a .gz one are gzipped here once.
+func New(fsys fs.FS) (*Handler, error) {
+	h := &Handler{assets: make(map[string]*asset)}
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || precompressed(fsys, name) {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		a := &asset{contentType: contentType(name, data), cacheControl: revalidated}
+		if strings.HasPrefix(name, hashedDir) {
+			a.cacheControl = immutable
+		}
+		for _, enc := range encodings {
+			encoded, err := fs.ReadFile(fsys, name+enc.ext)
+			if err != nil && enc.name == "gzip" && compressible(a.contentType, data) {
+				encoded, err = gzipped(data)
+			}
+			if err == nil && len(encoded) < len(data) {
+				a.variants = append(a.variants, newVariant(enc.name, encoded))
+			}
+		}
+		a.variants = append(a.variants, newVariant("", data))
+		h.assets[name] = a
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	h.index = h.assets["index.html"]
+	return h, nil
+}
+
+// precompressed reports whether name is the encoded form of another file.
+func precompressed(fsys fs.FS, name string) bool {
+	for _, enc := range encodings {
+		if base, ok := strings.CutSuffix(name, enc.ext); ok {
+			if _, err := fs.Stat(fsys, base); err == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func contentType(name string, data []byte) string {
+	if ct := mime.TypeByExtension(path.Ext(name)); ct != "" {
+		return ct
+	}
+	return http.DetectContentType(data)
+}
+
+func compressible(contentType string, data []byte) bool {
+	if len(data) < minCompressSize {
+		return false
+	}
+	ct, _, _ := strings.Cut(contentType, ";")
+	switch {
+	case strings.HasPrefix(ct, "text/"), strings.HasSuffix(ct, "+xml"), strings.HasSuffix(ct, "+json"):
+		return true
+	}
+	switch ct {
+	case "application/javascript", "application/json", "application/wasm", "application/xml", "image/svg+xml", "font/ttf", "font/otf", "image/x-icon", "image/vnd.microsoft.icon":
+		return true
+	}
+	return false
+}
+
+func gzipped(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func newVariant(encoding string, data []byte) variant {
+	sum := sha256.Sum256(data)
+	return variant{encoding: encoding, data: data, etag: `"` + hex.EncodeToString(sum[:16]) + `"`}
+}
+
+// ServeHTTP serves the file at the request path. Paths that match no file
+// get index.html, so the frontend can route them, except under the hashed
+// directory, where a missing file is a stale reference and gets 404.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	a, ok := h.assets[name]
+	if name == "" || name == "index.html" {
+		a, ok = h.index, h.index != nil
+	}
+	if !ok {
+		if strings.HasPrefix(name, hashedDir) || h.index == nil {
+			http.NotFound(w, r)
+			return
+		}
+		a = h.index
+	}
+
+	v := a.variants[len(a.variants)-1]
+	if len(a.variants) > 1 {
+		w.Header().Add("Vary", "Accept-Encoding")
+		accepted := acceptedEncodings(r.Header.Get("Accept-Encoding"))
+		for _, candidate := range a.variants {
+			if accepted[candidate.encoding] {
+				v = candidate
+				break
+			}
+		}
+	}
+	w.Header().Set("Content-Type", a.contentType)
+	w.Header().Set("Cache-Control", a.cacheControl)
+	w.Header().Set("ETag", v.etag)
+	if v.encoding != "" {
+		w.Header().Set("Content-Encoding", v.encoding)
+	}
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(v.data))
+}
+
+// acceptedEncodings reads an Accept-Encoding header, leaving out the
+// encodings refused with q=0.
+func acceptedEncodings(header string) map[string]bool {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if name != "" && q > 0 {
+			accepted[strings.ToLower(name)] = true
+		}
+	}
+	return accepted
+}
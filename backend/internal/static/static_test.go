@@ -0,0 +1,109 @@
+package static
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+var script = strings.Repeat("console.log('depot');\n", 100)
+
+func testHandler(t *testing.T) *Handler {
+	t.Helper()
+	h, err := New(fstest.MapFS{
+		"index.html":               {Data: []byte("<html>depot</html>")},
+		"favicon.ico":              {Data: []byte("icon")},
+		"assets/app-1a2b3c.js":     {Data: []byte(script)},
+		"assets/app-1a2b3c.css":    {Data: []byte(strings.Repeat("body{}", 300))},
+		"assets/app-1a2b3c.css.br": {Data: []byte("brotli")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func get(h *Handler, path string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestCacheHeaders(t *testing.T) {
+	h := testHandler(t)
+	cases := []struct{ path, cache string }{
+		{"/", revalidated},
+		{"/index.html", revalidated},
+		{"/files/shared", revalidated},
+		{"/favicon.ico", revalidated},
+		{"/assets/app-1a2b3c.js", immutable},
+	}
+	for _, tc := range cases {
+		w := get(h, tc.path)
+		if w.Code != http.StatusOK || w.Header().Get("Cache-Control") != tc.cache {
+			t.Errorf("%s: %d with Cache-Control %q, want %q", tc.path, w.Code, w.Header().Get("Cache-Control"), tc.cache)
+		}
+	}
+	if w := get(h, "/files/shared"); w.Body.String() != "<html>depot</html>" {
+		t.Errorf("SPA route served %q, want index.html", w.Body.String())
+	}
+	if w := get(h, "/assets/app-old.js"); w.Code != http.StatusNotFound {
+		t.Errorf("missing hashed asset: %d, want 404", w.Code)
+	}
+}
+
+func TestCompression(t *testing.T) {
+	h := testHandler(t)
+
+	w := get(h, "/assets/app-1a2b3c.js", "Accept-Encoding", "gzip, deflate")
+	if w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("headers = %v, want gzip varying on Accept-Encoding", w.Header())
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(zr); string(body) != script {
+		t.Errorf("gunzipped body differs from the file")
+	}
+
+	w = get(h, "/assets/app-1a2b3c.css", "Accept-Encoding", "gzip, br")
+	if w.Header().Get("Content-Encoding") != "br" || w.Body.String() != "brotli" {
+		t.Errorf("br: %q %q, want the precompressed file", w.Header().Get("Content-Encoding"), w.Body.String())
+	}
+	w = get(h, "/assets/app-1a2b3c.css", "Accept-Encoding", "br;q=0, gzip")
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Errorf("br refused with q=0 but served %q", w.Header().Get("Content-Encoding"))
+	}
+	w = get(h, "/assets/app-1a2b3c.js")
+	if w.Header().Get("Content-Encoding") != "" || w.Body.String() != script {
+		t.Errorf("no Accept-Encoding: got %q encoding", w.Header().Get("Content-Encoding"))
+	}
+	if w := get(h, "/assets/app-1a2b3c.css.br"); w.Code != http.StatusNotFound {
+		t.Errorf("precompressed file served directly: %d", w.Code)
+	}
+	if w := get(h, "/favicon.ico", "Accept-Encoding", "gzip"); w.Header().Get("Content-Encoding") != "" {
+		t.Errorf("small file compressed")
+	}
+}
+
+func TestETag(t *testing.T) {
+	h := testHandler(t)
+	plain := get(h, "/assets/app-1a2b3c.js").Header().Get("ETag")
+	gz := get(h, "/assets/app-1a2b3c.js", "Accept-Encoding", "gzip").Header().Get("ETag")
+	if plain == "" || plain == gz {
+		t.Fatalf("ETags %q and %q, want distinct per encoding", plain, gz)
+	}
+	w := get(h, "/", "If-None-Match", get(h, "/").Header().Get("ETag"))
+	if w.Code != http.StatusNotModified {
+		t.Errorf("revalidating index.html: %d, want 304", w.Code)
+	}
+}
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrChunkSize is returned for a chunk of another size than expected
+	ErrChunkSize = errors.New("chunk size mismatch")
+	// ErrChunkChecksum is returned for a chunk whose SHA-256 isn't the
+	// expected one
+	ErrChunkChecksum = errors.New("chunk checksum mismatch")
+)
+
+// ChunkDir returns the staging directory used for the chunks of an upload
+// session, below tempDir.
+func ChunkDir(tempDir, sessionID string) string {
+	return filepath.Join(tempDir, ".chunks", sessionID)
+}
+
+// WriteChunk stores a single chunk of a resumable upload. Chunks are written
+// to their own files so they can arrive in any order and in parallel. A
+// chunk that isn't size bytes long, or doesn't have the SHA-256 sum when
+// one is given, fails with ErrChunkSize or ErrChunkChecksum and leaves a
+// chunk received before in place. It returns the SHA-256 of the chunk.
+func WriteChunk(reader io.Reader, chunkDir string, index int, size int64, sum string) (string, error) {
+	if err := os.MkdirAll(chunkDir, 0755); err != nil {
+		return "", err
+	}
+
+	finalPath := filepath.Join(chunkDir, strconv.Itoa(index))
+	tmp, err := os.CreateTemp(chunkDir, strconv.Itoa(index)+".part-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	hasher := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, hasher), reader)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	hash := hex.EncodeToString(hasher.Sum(nil))
+	if n != size {
+		return "", ErrChunkSize
+	}
+	if sum != "" && !strings.EqualFold(sum, hash) {
+		return "", ErrChunkChecksum
+	}
+
+	if err := os.Rename(tmp.Name(), finalPath); err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+// ChunkError is a chunk that no longer has the checksum it was received
+// with.
+type ChunkError struct {
+	Index int
+}
+
+func (e *ChunkError) Error() string {
+	return fmt.Sprintf("chunk %d: %v", e.Index, ErrChunkChecksum)
+}
+
+func (e *ChunkError) Unwrap() error { return ErrChunkChecksum }
+
+// AssembleChunks concatenates the chunks from chunkDir into
+// storageDir/fileName and returns the path, size and SHA-256 of the result.
+// sums holds the SHA-256 each chunk was received with; a chunk that no
+// longer matches fails with a *ChunkError. The result is assembled next to
+// the chunks and moved into storage when complete.
+func AssembleChunks(chunkDir string, sums []string, storageDir, fileName string) (string, int64, string, error) {
+	if err := os.MkdirAll(storageDir, 0755); err != nil {
+		return "", 0, "", err
+	}
+
+	out, err := os.CreateTemp(chunkDir, "assembled-*")
+	if err != nil {
+		return "", 0, "", err
+	}
+	defer os.Remove(out.Name())
+	defer out.Close()
+
+	hasher := sha256.New()
+	w := io.MultiWriter(out, hasher)
+	var total int64
+	for i, sum := range sums {
+		chunk, err := os.Open(filepath.Join(chunkDir, strconv.Itoa(i)))
+		if err != nil {
+			return "", 0, "", fmt.Errorf("missing chunk %d: %w", i, err)
+		}
+		chunkHasher := sha256.New()
+		n, err := io.Copy(io.MultiWriter(w, chunkHasher), chunk)
+		chunk.Close()
+		if err != nil {
+			return "", 0, "", err
+		}
+		if !strings.EqualFold(sum, hex.EncodeToString(chunkHasher.Sum(nil))) {
+			return "", 0, "", &ChunkError{Index: i}
+		}
+		total += n
+	}
+	if err := out.Sync(); err != nil {
+		return "", 0, "", err
+	}
+	if err := out.Close(); err != nil {
+		return "", 0, "", err
+	}
+
+	filePath := filepath.Join(storageDir, fileName)
+	if err := MoveFile(out.Name(), filePath); err != nil {
+		return "", 0, "", err
+	}
+	return filePath, total, hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// RemoveChunk discards one chunk, so it can be sent again.
+func RemoveChunk(chunkDir string, index int) error {
+	return os.Remove(filepath.Join(chunkDir, strconv.Itoa(index)))
+}
+
+func RemoveChunks(chunkDir string) error {
+	return os.RemoveAll(chunkDir)
+}
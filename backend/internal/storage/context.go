@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"context"
+	"io"
+)
+
+// ContextReader stops reading once ctx is done, so copying a request body
+// into storage ends when the client goes away or the request times out.
+func ContextReader(ctx context.Context, r io.Reader) io.Reader {
+	return &contextReader{ctx: ctx, r: r}
+}
+
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// ContextReadSeeker is ContextReader for content served with
+// http.ServeContent.
+func ContextReadSeeker(ctx context.Context, rs io.ReadSeeker) io.ReadSeeker {
+	return &contextReadSeeker{contextReader{ctx: ctx, r: rs}, rs}
+}
+
+type contextReadSeeker struct {
+	contextReader
+	s io.Seeker
+}
+
+func (r *contextReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	return r.s.Seek(offset, whence)
+}
@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// MoveFile moves src to dst, which may be on another file system (a staging
+// directory on local disk, storage on NFS). Across devices the content is
+// copied next to dst, synced and renamed into place, so dst is never seen
+// half written; src is removed once dst is complete.
+func MoveFile(src, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil || !crossDevice(err) {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".move-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, in)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return err
+	}
+	in.Close()
+	return os.Remove(src)
+}
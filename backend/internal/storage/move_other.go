@@ -0,0 +1,20 @@
+//go:build !(linux || darwin || freebsd)
+
+package storage
+
+import (
+	"errors"
+	"os"
+)
+
+// crossDevice can't tell why a rename failed here, so any failure with the
+// source still in place falls back to the copy, which reports its own error
+// if the files can't be moved at all.
+func crossDevice(err error) bool {
+	var linkErr *os.LinkError
+	if !errors.As(err, &linkErr) {
+		return false
+	}
+	_, statErr := os.Stat(linkErr.Old)
+	return statErr == nil
+}
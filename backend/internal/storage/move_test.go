@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestStoreFileStagesInTempDir(t *testing.T) {
+	tempDir, storageDir := t.TempDir(), t.TempDir()
+
+	path, size, _, err := StoreFile(strings.NewReader("hello"), tempDir, storageDir, "file-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != filepath.Join(storageDir, "file-1") || size != 5 {
+		t.Errorf("unexpected result %s, %d", path, size)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "hello" {
+		t.Errorf("expected the content in storage, got %q", data)
+	}
+
+	failing := io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(errors.New("connection reset")))
+	if _, _, _, err := StoreFile(failing, tempDir, storageDir, "file-2"); err == nil {
+		t.Fatal("expected the failed read to be reported")
+	}
+	if _, err := os.Stat(filepath.Join(storageDir, "file-2")); !os.IsNotExist(err) {
+		t.Error("expected no partial file in storage")
+	}
+	if entries, _ := os.ReadDir(tempDir); len(entries) != 0 {
+		t.Errorf("expected the temp dir to be cleaned up, got %d entries", len(entries))
+	}
+}
+
+func TestMoveFileAcrossDevices(t *testing.T) {
+	other, err := os.MkdirTemp("/dev/shm", "depot-move-*")
+	if err != nil {
+		t.Skip("no second file system to move across")
+	}
+	defer os.RemoveAll(other)
+
+	src := filepath.Join(other, "src")
+	if err := os.WriteFile(src, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(t.TempDir(), "dst")
+	if err := os.Rename(src, dst); err == nil || !crossDevice(err) {
+		t.Skip("/dev/shm is on the same device")
+	}
+
+	if err := MoveFile(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "content" {
+		t.Errorf("expected the content at the destination, got %q", data)
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Error("expected the source to be removed")
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(dst)); len(entries) != 1 {
+		t.Errorf("expected only the destination to be left, got %d entries", len(entries))
+	}
+}
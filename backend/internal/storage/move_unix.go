@@ -0,0 +1,12 @@
+//go:build linux || darwin || freebsd
+
+package storage
+
+import (
+	"errors"
+	"syscall"
+)
+
+func crossDevice(err error) bool {
+	return errors.Is(err, syscall.EXDEV)
+}
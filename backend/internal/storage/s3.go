@@ -0,0 +1,294 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	s3Scheme        = "s3://"
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// S3Config describes an S3 compatible bucket (AWS, MinIO, Garage, ...).
+type S3Config struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// PathStyle addresses the bucket as endpoint/bucket/key instead of
+	// bucket.endpoint/key, which most self-hosted servers need.
+	PathStyle bool
+}
+
+// S3Client signs requests with AWS Signature Version 4.
+type S3Client struct {
+	cfg    S3Config
+	base   *url.URL
+	client *http.Client
+	now    func() time.Time
+}
+
+func NewS3Client(cfg S3Config) (*S3Client, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, fmt.Errorf("s3: endpoint and bucket are required")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	base, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return &S3Client{cfg: cfg, base: base, client: &http.Client{}, now: time.Now}, nil
+}
+
+// IsS3Path reports whether a stored path refers to an object in S3.
+func IsS3Path(p string) bool {
+	return strings.HasPrefix(p, s3Scheme)
+}
+
+// Path returns the stored path representation of an object key.
+func (s *S3Client) Path(key string) string {
+	return s3Scheme + s.cfg.Bucket + "/" + key
+}
+
+// KeyFromPath extracts the object key from a stored path.
+func (s *S3Client) KeyFromPath(p string) string {
+	return strings.TrimPrefix(p, s3Scheme+s.cfg.Bucket+"/")
+}
+
+func (s *S3Client) objectURL(key string) *url.URL {
+	u := *s.base
+	if s.cfg.PathStyle {
+		u.Path = "/" + s.cfg.Bucket + "/" + key
+	} else {
+		u.Host = s.cfg.Bucket + "." + u.Host
+		u.Path = "/" + key
+	}
+	return &u
+}
+
+// PresignPut returns a URL that lets the holder PUT the object directly. When
+// sha256Hex is given the checksum header is part of the signature, so the
+// server rejects content that doesn't match. The returned headers must be
+// sent with the upload.
+func (s *S3Client) PresignPut(key, sha256Hex string, expires time.Duration) (string, map[string]string, error) {
+	headers := map[string]string{}
+	if sha256Hex != "" {
+		raw, err := hex.DecodeString(sha256Hex)
+		if err != nil {
+			return "", nil, fmt.Errorf("s3: invalid sha256: %w", err)
+		}
+		headers["x-amz-checksum-sha256"] = base64Std(raw)
+	}
+	u, err := s.presign(http.MethodPut, key, headers, nil, expires)
+	return u, headers, err
+}
+
+// PresignGet returns a time limited download URL. Extra query parameters such
+// as response-content-disposition are signed as well.
+func (s *S3Client) PresignGet(key string, query url.Values, expires time.Duration) (string, error) {
+	return s.presign(http.MethodGet, key, nil, query, expires)
+}
+
+func (s *S3Client) presign(method, key string, headers map[string]string, extra url.Values, expires time.Duration) (string, error) {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	scope := now.Format("20060102") + "/" + s.cfg.Region + "/s3/aws4_request"
+
+	u := s.objectURL(key)
+	signed := map[string]string{"host": u.Host}
+	for k, v := range headers {
+		signed[strings.ToLower(k)] = v
+	}
+	signedHeaders := sortedKeys(signed)
+
+	q := url.Values{}
+	for k, v := range extra {
+		q[k] = v
+	}
+	q.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	q.Set("X-Amz-Credential", s.cfg.AccessKey+"/"+scope)
+	q.Set("X-Amz-Date", amzDate)
+	q.Set("X-Amz-Expires", strconv.Itoa(int(expires.Seconds())))
+	q.Set("X-Amz-SignedHeaders", strings.Join(signedHeaders, ";"))
+
+	canonical := canonicalRequest(method, u.EscapedPath(), q, signed, signedHeaders, unsignedPayload)
+	q.Set("X-Amz-Signature", s.signature(amzDate, scope, now, canonical))
+
+	u.RawQuery = canonicalQuery(q)
+	return u.String(), nil
+}
+
+// do sends a header-signed request for key. It is abandoned when ctx is
+// done.
+func (s *S3Client) do(ctx context.Context, method, key string, body io.Reader, size int64, payloadHash string) (*http.Response, error) {
+	u := s.objectURL(key)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	if payloadHash == "" {
+		payloadHash = unsignedPayload
+	}
+
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	scope := now.Format("20060102") + "/" + s.cfg.Region + "/s3/aws4_request"
+
+	signed := map[string]string{
+		"host":                 u.Host,
+		"x-amz-date":           amzDate,
+		"x-amz-content-sha256": payloadHash,
+	}
+	signedHeaders := sortedKeys(signed)
+	canonical := canonicalRequest(method, u.EscapedPath(), url.Values{}, signed, signedHeaders, payloadHash)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKey, scope, strings.Join(signedHeaders, ";"), s.signature(amzDate, scope, now, canonical)))
+	return s.client.Do(req)
+}
+
+// Head returns the size of the object, or an error if it doesn't exist.
+func (s *S3Client) Head(ctx context.Context, key string) (int64, error) {
+	resp, err := s.do(ctx, http.MethodHead, key, nil, 0, "")
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("s3: head %s: %s", key, resp.Status)
+	}
+	return resp.ContentLength, nil
+}
+
+// Put uploads an object. The payload is signed with its SHA-256, so the
+// server rejects the upload if the content doesn't match sha256Hex.
+func (s *S3Client) Put(ctx context.Context, key string, body io.Reader, size int64, sha256Hex string) error {
+	resp, err := s.do(ctx, http.MethodPut, key, body, size, sha256Hex)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("s3: put %s: %s", key, resp.Status)
+	}
+	return nil
+}
+
+// Get opens an object for reading. The caller closes it.
+func (s *S3Client) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("s3: get %s: %s", key, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+func (s *S3Client) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, 0, "")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("s3: delete %s: %s", key, resp.Status)
+	}
+	return nil
+}
+
+func (s *S3Client) signature(amzDate, scope string, now time.Time, canonical string) string {
+	sum := sha256.Sum256([]byte(canonical))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretKey), now.Format("20060102"))
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func canonicalRequest(method, path string, q url.Values, headers map[string]string, signedHeaders []string, payloadHash string) string {
+	var sb strings.Builder
+	sb.WriteString(method + "\n")
+	sb.WriteString(path + "\n")
+	sb.WriteString(canonicalQuery(q) + "\n")
+	for _, k := range signedHeaders {
+		sb.WriteString(k + ":" + strings.TrimSpace(headers[k]) + "\n")
+	}
+	sb.WriteString("\n")
+	sb.WriteString(strings.Join(signedHeaders, ";") + "\n")
+	sb.WriteString(payloadHash)
+	return sb.String()
+}
+
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		vals := append([]string(nil), q[k]...)
+		sort.Strings(vals)
+		for _, v := range vals {
+			parts = append(parts, awsEscape(k)+"="+awsEscape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// awsEscape percent-encodes everything but the RFC 3986 unreserved set.
+func awsEscape(s string) string {
+	var sb strings.Builder
+	for _, b := range []byte(s) {
+		if ('A' <= b && b <= 'Z') || ('a' <= b && b <= 'z') || ('0' <= b && b <= '9') || b == '-' || b == '_' || b == '.' || b == '~' {
+			sb.WriteByte(b)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", b)
+		}
+	}
+	return sb.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func base64Std(b []byte) string {
+	return base64.StdEncoding.EncodeToString(b)
+}
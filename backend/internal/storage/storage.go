@@ -1,32 +1,57 @@
 package storage
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"io"
 	"os"
 	"path/filepath"
 )
 
-func StoreFile(reader io.Reader, storageDir, fileName string) (string, int64, error) {
-	if _, err := os.Stat(storageDir); os.IsNotExist(err) {
-		err := os.MkdirAll(storageDir, 0755)
-		if err != nil {
-			return "", 0, err
+// StoreFile writes the reader to storageDir/fileName and returns the path,
+// the number of bytes written and the hex encoded SHA-256 of the content.
+// The content is written to tempDir first and only moved into storageDir
+// once complete and synced, so storage never holds a partial file. An empty
+// tempDir stages in storageDir itself.
+func StoreFile(reader io.Reader, tempDir, storageDir, fileName string) (string, int64, string, error) {
+	if tempDir == "" {
+		tempDir = storageDir
+	}
+	for _, dir := range []string{tempDir, storageDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", 0, "", err
 		}
 	}
 
-	filePath := filepath.Join(storageDir, fileName)
-	out, err := os.Create(filePath)
+	tmp, err := os.CreateTemp(tempDir, "."+fileName+".part-*")
 	if err != nil {
-		return "", 0, err
+		return "", 0, "", err
 	}
-	defer out.Close()
+	// Don't leave a partial file behind
+	defer os.Remove(tmp.Name())
 
-	size, err := io.Copy(out, reader)
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hasher), reader)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
 	if err != nil {
-		return "", 0, err
+		return "", 0, "", err
 	}
 
-	return filePath, size, nil
+	filePath := filepath.Join(storageDir, fileName)
+	if err := MoveFile(tmp.Name(), filePath); err != nil {
+		return "", 0, "", err
+	}
+	return filePath, size, hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+func Exists(filePath string) bool {
+	_, err := os.Stat(filePath)
+	return err == nil
 }
 
 func GetFile(filePath string) (io.ReadCloser, error) {
@@ -36,3 +61,52 @@ func GetFile(filePath string) (io.ReadCloser, error) {
 func DeleteFile(filePath string) error {
 	return os.Remove(filePath)
 }
+
+type PartChecksum struct {
+	Index  int    `json:"index"`
+	Offset int64  `json:"offset"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// HashParts splits the content into consecutive parts of partSize bytes
+// and returns the SHA-256 of each part.
+func HashParts(f io.Reader, partSize int64) ([]PartChecksum, error) {
+	var parts []PartChecksum
+	var offset int64
+	for i := 0; ; i++ {
+		hasher := sha256.New()
+		n, err := io.CopyN(hasher, f, partSize)
+		if n > 0 {
+			parts = append(parts, PartChecksum{
+				Index:  i,
+				Offset: offset,
+				Size:   n,
+				SHA256: hex.EncodeToString(hasher.Sum(nil)),
+			})
+			offset += n
+		}
+		if err == io.EOF {
+			return parts, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// HashFile returns the size and hex encoded SHA-256 of the file.
+func HashFile(filePath string) (int64, string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	size, err := io.Copy(hasher, f)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(hasher.Sum(nil)), nil
+}
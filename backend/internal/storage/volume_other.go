@@ -0,0 +1,10 @@
+//go:build !(linux || darwin || freebsd)
+
+package storage
+
+import "errors"
+
+// VolumeUsage is not available on this platform.
+func VolumeUsage(dir string) (total, free uint64, err error) {
+	return 0, 0, errors.ErrUnsupported
+}
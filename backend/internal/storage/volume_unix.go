@@ -0,0 +1,15 @@
+//go:build linux || darwin || freebsd
+
+package storage
+
+import "syscall"
+
+// VolumeUsage reports the size of the file system holding dir and the space
+// left on it for unprivileged users.
+func VolumeUsage(dir string) (total, free uint64, err error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, 0, err
+	}
+	return uint64(st.Blocks) * uint64(st.Bsize), uint64(st.Bavail) * uint64(st.Bsize), nil
+}
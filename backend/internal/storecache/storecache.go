@@ -0,0 +1,125 @@
+// Package storecache speeds up GetGlobal, which stores answer by scanning
+// every persona. The depot calls it for every file lookup, downloads
+// included.
+package storecache
+
+import (
+	"sync"
+	"time"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+// Wrap returns base with an index of the personas GetGlobal found keys in.
+// Writes through the returned store keep the index current. Entries are
+// only hints and are checked with a plain Get before use, so writes made
+// elsewhere (another process sharing a remote store, or an App scope) cost
+// a fallback scan rather than a wrong answer.
+func Wrap(base sdk.CelerixStore) sdk.CelerixStore {
+	return &cachedStore{CelerixStore: base, personas: make(map[indexKey]string)}
+}
+
+// Shared is an index other processes see too, such as a Redis cache.
+type Shared interface {
+	Get(key string) (string, bool)
+	Set(key, value string, ttl time.Duration)
+}
+
+// sharedTTL is how long a hint stays in the shared index.
+const sharedTTL = 24 * time.Hour
+
+// WrapShared is Wrap with the hints kept in shared as well, so replicas
+// learn where keys are from each other's scans.
+func WrapShared(base sdk.CelerixStore, shared Shared) sdk.CelerixStore {
+	return &cachedStore{CelerixStore: base, personas: make(map[indexKey]string), shared: shared}
+}
+
+type indexKey struct {
+	app, key string
+}
+
+func (k indexKey) String() string {
+	return "persona:" + k.app + ":" + k.key
+}
+
+type cachedStore struct {
+	sdk.CelerixStore
+
+	mu       sync.RWMutex
+	personas map[indexKey]string
+	shared   Shared
+}
+
+func (s *cachedStore) GetGlobal(appID, key string) (any, string, error) {
+	k := indexKey{appID, key}
+	s.mu.RLock()
+	persona, ok := s.personas[k]
+	s.mu.RUnlock()
+	if ok {
+		if val, err := s.CelerixStore.Get(persona, appID, key); err == nil {
+			return val, persona, nil
+		}
+	}
+	if s.shared != nil {
+		if shared, ok := s.shared.Get(k.String()); ok && shared != persona {
+			if val, err := s.CelerixStore.Get(shared, appID, key); err == nil {
+				s.remember(k, shared)
+				return val, shared, nil
+			}
+		}
+	}
+
+	val, persona, err := s.CelerixStore.GetGlobal(appID, key)
+	if err != nil {
+		s.mu.Lock()
+		delete(s.personas, k)
+		s.mu.Unlock()
+		return val, persona, err
+	}
+	s.remember(k, persona)
+	if s.shared != nil {
+		s.shared.Set(k.String(), persona, sharedTTL)
+	}
+	return val, persona, nil
+}
+
+func (s *cachedStore) remember(k indexKey, persona string) {
+	s.mu.Lock()
+	s.personas[k] = persona
+	s.mu.Unlock()
+}
+
+func (s *cachedStore) Set(personaID, appID, key string, val any) error {
+	if err := s.CelerixStore.Set(personaID, appID, key, val); err != nil {
+		return err
+	}
+	k := indexKey{appID, key}
+	s.mu.Lock()
+	if _, ok := s.personas[k]; ok {
+		s.personas[k] = personaID
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *cachedStore) Delete(personaID, appID, key string) error {
+	k := indexKey{appID, key}
+	s.mu.Lock()
+	if s.personas[k] == personaID {
+		delete(s.personas, k)
+	}
+	s.mu.Unlock()
+	return s.CelerixStore.Delete(personaID, appID, key)
+}
+
+func (s *cachedStore) Move(srcPersona, dstPersona, appID, key string) error {
+	if err := s.CelerixStore.Move(srcPersona, dstPersona, appID, key); err != nil {
+		return err
+	}
+	k := indexKey{appID, key}
+	s.remember(k, dstPersona)
+	if s.shared != nil {
+		s.shared.Set(k.String(), dstPersona, sharedTTL)
+	}
+	return nil
+}
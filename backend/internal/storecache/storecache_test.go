@@ -0,0 +1,64 @@
+package storecache
+
+import (
+	"testing"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+	"github.com/celerix/depot/internal/memstore"
+	"github.com/celerix/depot/storetest"
+)
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) sdk.CelerixStore {
+		return Wrap(memstore.New())
+	})
+}
+
+// countingStore counts the scans that reach the wrapped store.
+type countingStore struct {
+	sdk.CelerixStore
+	scans int
+}
+
+func (s *countingStore) GetGlobal(appID, key string) (any, string, error) {
+	s.scans++
+	return s.CelerixStore.GetGlobal(appID, key)
+}
+
+func TestGetGlobalIsCached(t *testing.T) {
+	base := &countingStore{CelerixStore: memstore.New()}
+	s := Wrap(base)
+
+	lookup := func(want string) {
+		t.Helper()
+		_, persona, err := s.GetGlobal("app", "k")
+		if err != nil || persona != want {
+			t.Fatalf("GetGlobal = %q, %v; want %q", persona, err, want)
+		}
+	}
+
+	s.Set("a", "app", "k", "v")
+	lookup("a")
+	lookup("a")
+	if base.scans != 1 {
+		t.Errorf("expected one scan, got %d", base.scans)
+	}
+
+	s.Move("a", "b", "app", "k")
+	lookup("b")
+	if base.scans != 1 {
+		t.Errorf("expected Move to keep the index current, got %d scans", base.scans)
+	}
+
+	// A move the wrapper didn't see is found by scanning again
+	base.Move("b", "c", "app", "k")
+	lookup("c")
+	if base.scans != 2 {
+		t.Errorf("expected a fallback scan, got %d scans", base.scans)
+	}
+
+	s.Delete("c", "app", "k")
+	if _, _, err := s.GetGlobal("app", "k"); err == nil {
+		t.Error("expected a deleted key to be gone")
+	}
+}
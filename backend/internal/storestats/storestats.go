@@ -0,0 +1,125 @@
+// Package storestats counts the operations a depot sends to its store and
+// how long they take, to tell a slow store apart from a slow depot.
+package storestats
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+// Op totals one kind of store operation. Errors include lookups of keys
+// that don't exist.
+type Op struct {
+	Name   string        `json:"name"`
+	Calls  int64         `json:"calls"`
+	Errors int64         `json:"errors"`
+	Total  time.Duration `json:"total_ns"`
+	Max    time.Duration `json:"max_ns"`
+}
+
+// Store wraps a store and records every call that passes through it.
+type Store struct {
+	sdk.CelerixStore
+
+	mu  sync.Mutex
+	ops map[string]*Op
+}
+
+func Wrap(base sdk.CelerixStore) *Store {
+	return &Store{CelerixStore: base, ops: make(map[string]*Op)}
+}
+
+func (s *Store) record(name string, start time.Time, err error) {
+	elapsed := time.Since(start)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	op, ok := s.ops[name]
+	if !ok {
+		op = &Op{Name: name}
+		s.ops[name] = op
+	}
+	op.Calls++
+	if err != nil {
+		op.Errors++
+	}
+	op.Total += elapsed
+	op.Max = max(op.Max, elapsed)
+}
+
+// Stats returns the totals so far, by operation name.
+func (s *Store) Stats() []Op {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ops := make([]Op, 0, len(s.ops))
+	for _, op := range s.ops {
+		ops = append(ops, *op)
+	}
+	sort.Slice(ops, func(i, j int) bool { return ops[i].Name < ops[j].Name })
+	return ops
+}
+
+func (s *Store) Get(personaID, appID, key string) (any, error) {
+	start := time.Now()
+	val, err := s.CelerixStore.Get(personaID, appID, key)
+	s.record("get", start, err)
+	return val, err
+}
+
+func (s *Store) Set(personaID, appID, key string, val any) error {
+	start := time.Now()
+	err := s.CelerixStore.Set(personaID, appID, key, val)
+	s.record("set", start, err)
+	return err
+}
+
+func (s *Store) Delete(personaID, appID, key string) error {
+	start := time.Now()
+	err := s.CelerixStore.Delete(personaID, appID, key)
+	s.record("delete", start, err)
+	return err
+}
+
+func (s *Store) GetPersonas() ([]string, error) {
+	start := time.Now()
+	personas, err := s.CelerixStore.GetPersonas()
+	s.record("get_personas", start, err)
+	return personas, err
+}
+
+func (s *Store) GetApps(personaID string) ([]string, error) {
+	start := time.Now()
+	apps, err := s.CelerixStore.GetApps(personaID)
+	s.record("get_apps", start, err)
+	return apps, err
+}
+
+func (s *Store) GetAppStore(personaID, appID string) (map[string]any, error) {
+	start := time.Now()
+	store, err := s.CelerixStore.GetAppStore(personaID, appID)
+	s.record("get_app_store", start, err)
+	return store, err
+}
+
+func (s *Store) DumpApp(appID string) (map[string]map[string]any, error) {
+	start := time.Now()
+	dump, err := s.CelerixStore.DumpApp(appID)
+	s.record("dump_app", start, err)
+	return dump, err
+}
+
+func (s *Store) GetGlobal(appID, key string) (any, string, error) {
+	start := time.Now()
+	val, persona, err := s.CelerixStore.GetGlobal(appID, key)
+	s.record("get_global", start, err)
+	return val, persona, err
+}
+
+func (s *Store) Move(srcPersona, dstPersona, appID, key string) error {
+	start := time.Now()
+	err := s.CelerixStore.Move(srcPersona, dstPersona, appID, key)
+	s.record("move", start, err)
+	return err
+}
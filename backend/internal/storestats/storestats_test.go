@@ -0,0 +1,33 @@
+package storestats
+
+import (
+	"testing"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+	"github.com/celerix/depot/internal/memstore"
+	"github.com/celerix/depot/storetest"
+)
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) sdk.CelerixStore {
+		return Wrap(memstore.New())
+	})
+}
+
+func TestStats(t *testing.T) {
+	s := Wrap(memstore.New())
+	s.Set("a", "app", "k", "v")
+	s.Get("a", "app", "k")
+	s.Get("a", "app", "missing")
+
+	stats := s.Stats()
+	if len(stats) != 2 || stats[0].Name != "get" || stats[1].Name != "set" {
+		t.Fatalf("expected get and set, got %+v", stats)
+	}
+	if stats[0].Calls != 2 || stats[0].Errors != 1 {
+		t.Errorf("expected 2 gets with 1 miss, got %+v", stats[0])
+	}
+	if stats[1].Calls != 1 || stats[1].Errors != 0 || stats[1].Max > stats[1].Total {
+		t.Errorf("unexpected set totals %+v", stats[1])
+	}
+}
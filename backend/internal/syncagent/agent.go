@@ -0,0 +1,454 @@
+// Package syncagent keeps a local directory and a folder of depot files in
+// sync, using the depot's sync API. It is the reference client behind
+// `depotctl sync`.
+//
+// Each run pulls the server's changes since the last run, scans the
+// directory for local changes against the state saved after that run, and
+// reconciles the two. When both sides changed a file differently, the
+// server's version keeps the name and the local one is saved and uploaded as
+// a conflict copy, so nothing is lost.
+package syncagent
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// StateFile is kept in the synced directory and never synced itself.
+const StateFile = ".depot-sync.json"
+
+// fileState is what both sides agreed a file looked like after the last run.
+type fileState struct {
+	FileID  string `json:"file_id"`
+	SHA256  string `json:"sha256"`
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mod_time"`
+}
+
+type state struct {
+	Server string `json:"server"`
+	Root   string `json:"root"`
+	// Cursor is nil until the first run completes
+	Cursor *int64                `json:"cursor"`
+	Files  map[string]*fileState `json:"files"`
+}
+
+type localFile struct {
+	SHA256  string
+	Size    int64
+	ModTime int64
+}
+
+// Agent syncs Dir with the client's root folder.
+type Agent struct {
+	Client *Client
+	Dir    string
+	Logf   func(format string, args ...any)
+	// Now names conflict copies; nil means time.Now
+	Now func() time.Time
+}
+
+func (a *Agent) logf(format string, args ...any) {
+	if a.Logf != nil {
+		a.Logf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+func (a *Agent) now() time.Time {
+	if a.Now != nil {
+		return a.Now()
+	}
+	return time.Now()
+}
+
+func (a *Agent) local(rel string) string {
+	return filepath.Join(a.Dir, filepath.FromSlash(rel))
+}
+
+func (a *Agent) loadState() (*state, error) {
+	st := &state{Server: a.Client.BaseURL, Root: a.Client.Root, Files: map[string]*fileState{}}
+	data, err := os.ReadFile(filepath.Join(a.Dir, StateFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, fmt.Errorf("read %s: %w", StateFile, err)
+	}
+	if st.Server != a.Client.BaseURL || st.Root != a.Client.Root {
+		return nil, fmt.Errorf("%s is synced with %s folder %q; remove %s to change that", a.Dir, st.Server, st.Root, StateFile)
+	}
+	if st.Files == nil {
+		st.Files = map[string]*fileState{}
+	}
+	return st, nil
+}
+
+func (a *Agent) saveState(st *state) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(a.Dir, StateFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(a.Dir, StateFile))
+}
+
+// scan hashes the directory's files. Files whose size and modification time
+// match the saved state are not read again.
+func (a *Agent) scan(st *state) (map[string]localFile, error) {
+	files := make(map[string]localFile)
+	err := filepath.WalkDir(a.Dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(a.Dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == StateFile || rel == StateFile+".tmp" || strings.HasSuffix(rel, ".depot-partial") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		lf := localFile{Size: info.Size(), ModTime: info.ModTime().UnixNano()}
+		if known := st.Files[rel]; known != nil && known.Size == lf.Size && known.ModTime == lf.ModTime {
+			lf.SHA256 = known.SHA256
+		} else if lf.SHA256, err = hashFile(p); err != nil {
+			return err
+		}
+		files[rel] = lf
+		return nil
+	})
+	return files, err
+}
+
+func hashFile(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// remoteChanges turns the server's changes into the new state of each
+// affected path; nil means the path is gone on the server.
+func remoteChanges(st *state, changes []Change, snapshot bool) map[string]*Entry {
+	remote := make(map[string]*Entry)
+	byID := make(map[string]string)
+	for p, fsState := range st.Files {
+		byID[fsState.FileID] = p
+	}
+
+	if snapshot {
+		seen := make(map[string]bool)
+		for _, ch := range changes {
+			e := ch.Entry
+			seen[e.Path] = true
+			if known := st.Files[e.Path]; known == nil || known.SHA256 != e.SHA256 {
+				remote[e.Path] = &e
+			} else {
+				known.FileID = e.FileID
+			}
+		}
+		for p := range st.Files {
+			if !seen[p] {
+				remote[p] = nil
+			}
+		}
+		return remote
+	}
+
+	for _, ch := range changes {
+		e := ch.Entry
+		// The file's old path, if it was moved or deleted
+		if old, ok := byID[e.FileID]; ok && (ch.Op != "put" || old != e.Path) {
+			if cur, pending := remote[old]; !pending || (cur != nil && cur.FileID == e.FileID) {
+				remote[old] = nil
+			}
+			delete(byID, e.FileID)
+		}
+		if ch.Op != "put" {
+			continue
+		}
+		byID[e.FileID] = e.Path
+		if known := st.Files[e.Path]; known != nil && known.SHA256 == e.SHA256 {
+			// Nothing new here, e.g. our own upload from the last run
+			known.FileID = e.FileID
+			delete(remote, e.Path)
+		} else {
+			remote[e.Path] = &e
+		}
+	}
+	return remote
+}
+
+// Run does one sync pass.
+func (a *Agent) Run() error {
+	st, err := a.loadState()
+	if err != nil {
+		return err
+	}
+
+	changes, cursor, snapshot, err := a.Client.Changes(st.Cursor)
+	if errors.Is(err, errCursorExpired) {
+		a.logf("Change feed expired, comparing against a full listing")
+		changes, cursor, snapshot, err = a.Client.Changes(nil)
+	}
+	if err != nil {
+		return err
+	}
+	remote := remoteChanges(st, changes, snapshot)
+
+	local, err := a.scan(st)
+	if err != nil {
+		return err
+	}
+
+	paths := make(map[string]bool)
+	for p := range remote {
+		paths[p] = true
+	}
+	var added []string
+	for p, lf := range local {
+		known := st.Files[p]
+		if known == nil {
+			added = append(added, p)
+		}
+		if known == nil || known.SHA256 != lf.SHA256 {
+			paths[p] = true
+		}
+	}
+	for p := range st.Files {
+		if _, ok := local[p]; !ok {
+			paths[p] = true
+		}
+	}
+
+	// New local files may already be on the server, e.g. on the first run
+	// of a directory that was copied by hand
+	existing, err := a.Client.Stat(added)
+	if err != nil {
+		return err
+	}
+	for p, e := range existing {
+		if _, ok := remote[p]; !ok {
+			remote[p] = &e
+		}
+	}
+
+	sorted := make([]string, 0, len(paths))
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+
+	var failed int
+	for _, p := range sorted {
+		r, remoteChanged := remote[p]
+		lf, hasLocal := local[p]
+		if err := a.reconcile(st, p, r, remoteChanged, lf, hasLocal); err != nil {
+			a.logf("%s: %v", p, err)
+			failed++
+		}
+	}
+
+	// Replaying the changes is harmless, so a failed file keeps the cursor
+	// where it was and is retried next time
+	if failed == 0 {
+		st.Cursor = &cursor
+	}
+	if err := a.saveState(st); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d files failed to sync", failed)
+	}
+	return nil
+}
+
+func (a *Agent) reconcile(st *state, p string, r *Entry, remoteChanged bool, lf localFile, hasLocal bool) error {
+	known := st.Files[p]
+	var localChanged bool
+	switch {
+	case known == nil:
+		localChanged = hasLocal
+	case !hasLocal:
+		localChanged = true
+	default:
+		localChanged = known.SHA256 != lf.SHA256
+	}
+
+	switch {
+	case !localChanged && !remoteChanged:
+		return nil
+
+	case !localChanged:
+		if r == nil {
+			return a.removeLocal(st, p)
+		}
+		return a.download(st, p, *r)
+
+	case !remoteChanged:
+		if !hasLocal {
+			return a.deleteRemote(st, p, known.SHA256)
+		}
+		base := ""
+		if known != nil {
+			base = known.SHA256
+		}
+		return a.upload(st, p, lf, base)
+	}
+
+	// Both sides changed
+	switch {
+	case !hasLocal && r == nil:
+		delete(st.Files, p)
+		return nil
+	case !hasLocal:
+		return a.download(st, p, *r)
+	case r == nil:
+		// A local edit wins over a remote delete
+		return a.upload(st, p, lf, "")
+	case r.SHA256 == lf.SHA256:
+		st.Files[p] = &fileState{FileID: r.FileID, SHA256: r.SHA256, Size: lf.Size, ModTime: lf.ModTime}
+		return nil
+	}
+	return a.keepBoth(st, p, *r, lf)
+}
+
+func (a *Agent) upload(st *state, p string, lf localFile, base string) error {
+	e, err := a.Client.Put(p, a.local(p), base)
+	var conflict *ErrConflict
+	if errors.As(err, &conflict) {
+		if conflict.Current == nil {
+			return a.upload(st, p, lf, "")
+		}
+		if conflict.Current.SHA256 == lf.SHA256 {
+			st.Files[p] = &fileState{FileID: conflict.Current.FileID, SHA256: lf.SHA256, Size: lf.Size, ModTime: lf.ModTime}
+			return nil
+		}
+		return a.keepBoth(st, p, *conflict.Current, lf)
+	}
+	if err != nil {
+		return err
+	}
+	a.logf("Uploaded %s", p)
+	st.Files[p] = &fileState{FileID: e.FileID, SHA256: e.SHA256, Size: lf.Size, ModTime: lf.ModTime}
+	return nil
+}
+
+func (a *Agent) deleteRemote(st *state, p, base string) error {
+	err := a.Client.Delete(p, base)
+	var conflict *ErrConflict
+	if errors.As(err, &conflict) {
+		// Someone changed the file after we last saw it; keep their version
+		return a.download(st, p, *conflict.Current)
+	}
+	if err != nil {
+		return err
+	}
+	a.logf("Deleted %s on the server", p)
+	delete(st.Files, p)
+	return nil
+}
+
+func (a *Agent) removeLocal(st *state, p string) error {
+	if err := os.Remove(a.local(p)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	a.logf("Deleted %s", p)
+	delete(st.Files, p)
+	return nil
+}
+
+// download replaces the local file with the server's version. The content is
+// written next to it first and checked against the hash.
+func (a *Agent) download(st *state, p string, e Entry) error {
+	dst := a.local(p)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	tmp := dst + ".depot-partial"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	err = a.Client.Download(e, io.MultiWriter(f, h))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && hex.EncodeToString(h.Sum(nil)) != e.SHA256 {
+		err = errors.New("downloaded content doesn't match its checksum")
+	}
+	if err == nil {
+		err = os.Rename(tmp, dst)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	info, err := os.Stat(dst)
+	if err != nil {
+		return err
+	}
+	a.logf("Downloaded %s", p)
+	st.Files[p] = &fileState{FileID: e.FileID, SHA256: e.SHA256, Size: info.Size(), ModTime: info.ModTime().UnixNano()}
+	return nil
+}
+
+// keepBoth resolves a conflict: the local version moves to a conflict copy,
+// which is uploaded, and the server's version takes the path.
+func (a *Agent) keepBoth(st *state, p string, r Entry, lf localFile) error {
+	copyPath := conflictName(p, a.now())
+	if err := os.Rename(a.local(p), a.local(copyPath)); err != nil {
+		return err
+	}
+	a.logf("Conflict on %s, keeping local changes as %s", p, copyPath)
+	if err := a.download(st, p, r); err != nil {
+		return err
+	}
+	info, err := os.Stat(a.local(copyPath))
+	if err != nil {
+		return err
+	}
+	lf.ModTime = info.ModTime().UnixNano()
+	return a.upload(st, copyPath, lf, "")
+}
+
+func conflictName(p string, t time.Time) string {
+	dir, name := path.Split(p)
+	ext := path.Ext(name)
+	return dir + strings.TrimSuffix(name, ext) + " (conflict " + t.Format("2006-01-02 150405") + ")" + ext
+}
@@ -0,0 +1,136 @@
+package syncagent
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/celerix-dev/celerix-store/pkg/engine"
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+	"github.com/celerix/depot/internal/api"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	store, err := sdk.New(filepath.Join(dir, "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &api.Handler{
+		Store:            store,
+		StorageDir:       filepath.Join(dir, "uploads"),
+		CelerixNamespace: uuid.New(),
+	}
+	os.MkdirAll(h.StorageDir, 0755)
+
+	r := gin.New()
+	r.GET("/api/sync/changes", h.GetSyncChanges)
+	r.POST("/api/sync/stat", h.SyncStat)
+	r.PUT("/api/sync/file", h.SyncPut)
+	r.DELETE("/api/sync/file", h.SyncDelete)
+	r.GET("/api/download/:id", h.DownloadFile)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newAgent(t *testing.T, srv *httptest.Server) *Agent {
+	return &Agent{
+		Client: &Client{BaseURL: srv.URL, ClientID: "client-a", Root: "sync"},
+		Dir:    t.TempDir(),
+		Logf:   t.Logf,
+		Now:    func() time.Time { return time.Date(2026, 7, 1, 12, 0, 0, 0, time.UTC) },
+	}
+}
+
+func write(t *testing.T, a *Agent, rel, content string) {
+	t.Helper()
+	p := a.local(rel)
+	os.MkdirAll(filepath.Dir(p), 0755)
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func read(a *Agent, rel string) string {
+	data, err := os.ReadFile(a.local(rel))
+	if err != nil {
+		return "<missing>"
+	}
+	return string(data)
+}
+
+func run(t *testing.T, agents ...*Agent) {
+	t.Helper()
+	for _, a := range agents {
+		if err := a.Run(); err != nil {
+			t.Fatalf("sync failed: %v", err)
+		}
+	}
+}
+
+func TestSyncTwoDirectories(t *testing.T) {
+	srv := newTestServer(t)
+	a, b := newAgent(t, srv), newAgent(t, srv)
+
+	write(t, a, "notes.txt", "v1")
+	write(t, a, "docs/plan.md", "# Plan")
+	write(t, a, "old.txt", "obsolete")
+	run(t, a, b)
+
+	if got := read(b, "docs/plan.md"); got != "# Plan" {
+		t.Fatalf("expected b to get docs/plan.md, got %q", got)
+	}
+
+	// Edits and deletes travel both ways
+	write(t, b, "notes.txt", "v2")
+	os.Remove(a.local("old.txt"))
+	run(t, a, b, a)
+	if got := read(a, "notes.txt"); got != "v2" {
+		t.Errorf("expected a to get b's edit, got %q", got)
+	}
+	if got := read(b, "old.txt"); got != "<missing>" {
+		t.Errorf("expected the delete to reach b, got %q", got)
+	}
+
+	// Conflicting edits keep both versions
+	write(t, a, "notes.txt", "from a")
+	write(t, b, "notes.txt", "from b")
+	run(t, a, b, a)
+	copyName := "notes (conflict 2026-07-01 120000).txt"
+	for _, ag := range []*Agent{a, b} {
+		if got := read(ag, "notes.txt"); got != "from a" {
+			t.Errorf("expected the first upload to keep the name, got %q", got)
+		}
+		if got := read(ag, copyName); got != "from b" {
+			t.Errorf("expected the conflict copy, got %q", got)
+		}
+	}
+
+	// A quiet run changes nothing
+	run(t, a, b)
+	if got := read(b, "docs/plan.md"); got != "# Plan" {
+		t.Errorf("unexpected content after a quiet run: %q", got)
+	}
+}
+
+func TestSyncAdoptsExistingFiles(t *testing.T) {
+	srv := newTestServer(t)
+	a, b := newAgent(t, srv), newAgent(t, srv)
+
+	write(t, a, "same.txt", "identical")
+	run(t, a)
+
+	// A directory copied by hand is recognized instead of conflicting
+	write(t, b, "same.txt", "identical")
+	run(t, b)
+	entries, _ := os.ReadDir(b.Dir)
+	if len(entries) != 2 {
+		t.Errorf("expected only same.txt and the state file, got %d entries", len(entries))
+	}
+}
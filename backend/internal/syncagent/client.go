@@ -0,0 +1,238 @@
+package syncagent
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+)
+
+// Entry is a file at a path below the sync root, as the server reports it.
+type Entry struct {
+	Path         string `json:"path"`
+	FileID       string `json:"file_id"`
+	SHA256       string `json:"sha256"`
+	Size         int64  `json:"size"`
+	DownloadLink string `json:"download_link"`
+}
+
+// Change is one entry of the server's change feed.
+type Change struct {
+	Seq int64  `json:"seq"`
+	Op  string `json:"op"`
+	Entry
+}
+
+type changesPage struct {
+	Snapshot bool     `json:"snapshot"`
+	Changes  []Change `json:"changes"`
+	Cursor   int64    `json:"cursor"`
+	HasMore  bool     `json:"has_more"`
+}
+
+// ErrConflict is returned by conditional writes when the server's file is
+// not the one the agent based its change on. Current is nil when the file
+// is gone.
+type ErrConflict struct {
+	Current *Entry
+}
+
+func (e *ErrConflict) Error() string {
+	return "conflict with a change on the server"
+}
+
+// errCursorExpired means the change feed must be restarted from a snapshot.
+var errCursorExpired = errors.New("sync cursor expired")
+
+// Client talks to the sync API of a depot.
+type Client struct {
+	BaseURL  string
+	ClientID string
+	Root     string
+	HTTP     *http.Client
+}
+
+func (c *Client) do(method, path string, query url.Values, body io.Reader, header http.Header) (*http.Response, error) {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("root", c.Root)
+	req, err := http.NewRequest(method, c.BaseURL+path+"?"+query.Encode(), body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("X-Client-ID", c.ClientID)
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
+
+func apiError(resp *http.Response) error {
+	var body struct {
+		Error string `json:"error"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	if body.Error == "" {
+		body.Error = resp.Status
+	}
+	return fmt.Errorf("server: %s", body.Error)
+}
+
+// Changes returns the changes since the cursor, following pages to the end.
+// A nil cursor asks for a snapshot of the whole root.
+func (c *Client) Changes(cursor *int64) (changes []Change, next int64, snapshot bool, err error) {
+	for {
+		q := url.Values{}
+		if cursor != nil {
+			q.Set("cursor", strconv.FormatInt(*cursor, 10))
+		}
+		resp, err := c.do(http.MethodGet, "/api/sync/changes", q, nil, nil)
+		if err != nil {
+			return nil, 0, false, err
+		}
+		var page changesPage
+		switch resp.StatusCode {
+		case http.StatusOK:
+			err = json.NewDecoder(resp.Body).Decode(&page)
+		case http.StatusGone:
+			err = errCursorExpired
+		default:
+			err = apiError(resp)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return nil, 0, false, err
+		}
+
+		changes = append(changes, page.Changes...)
+		snapshot = snapshot || page.Snapshot
+		if !page.HasMore {
+			return changes, page.Cursor, snapshot, nil
+		}
+		cursor = &page.Cursor
+	}
+}
+
+// Stat looks up paths in batches. Missing paths are left out of the result.
+func (c *Client) Stat(paths []string) (map[string]Entry, error) {
+	const batch = 1000
+	found := make(map[string]Entry)
+	for len(paths) > 0 {
+		n := min(batch, len(paths))
+		body, _ := json.Marshal(map[string]any{"root": c.Root, "paths": paths[:n]})
+		paths = paths[n:]
+
+		resp, err := c.do(http.MethodPost, "/api/sync/stat", nil, bytes.NewReader(body), http.Header{"Content-Type": {"application/json"}})
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Entries map[string]*Entry `json:"entries"`
+		}
+		if resp.StatusCode != http.StatusOK {
+			err = apiError(resp)
+		} else {
+			err = json.NewDecoder(resp.Body).Decode(&result)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for p, e := range result.Entries {
+			if e != nil {
+				found[p] = *e
+			}
+		}
+	}
+	return found, nil
+}
+
+func baseHeader(base string) http.Header {
+	return http.Header{"X-Base-Sha256": {base}}
+}
+
+func conflict(resp *http.Response) error {
+	var body struct {
+		Current *Entry `json:"current"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	return &ErrConflict{Current: body.Current}
+}
+
+// Put uploads the local file to path, provided the server still has the
+// content with hash base there (empty meaning no file).
+func (c *Client) Put(path, localPath, base string) (*Entry, error) {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	resp, err := c.do(http.MethodPut, "/api/sync/file", url.Values{"path": {path}}, f, baseHeader(base))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var e Entry
+		return &e, json.NewDecoder(resp.Body).Decode(&e)
+	case http.StatusConflict:
+		return nil, conflict(resp)
+	}
+	return nil, apiError(resp)
+}
+
+// Delete removes the file at path, provided it still has the content with
+// hash base. Deleting a file that is already gone is not an error.
+func (c *Client) Delete(path, base string) error {
+	resp, err := c.do(http.MethodDelete, "/api/sync/file", url.Values{"path": {path}}, nil, baseHeader(base))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNotFound:
+		return nil
+	case http.StatusConflict:
+		err := conflict(resp)
+		if err.(*ErrConflict).Current == nil {
+			return nil
+		}
+		return err
+	}
+	return apiError(resp)
+}
+
+// Download writes the file's content to w.
+func (c *Client) Download(e Entry, w io.Writer) error {
+	req, err := http.NewRequest(http.MethodGet, c.BaseURL+"/api/download/"+url.PathEscape(e.DownloadLink)+"?confirm=1", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Client-ID", c.ClientID)
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return apiError(resp)
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
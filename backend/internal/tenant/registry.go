@@ -0,0 +1,131 @@
+package tenant
+
+import (
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+// PathPrefix is where tenants are reachable when no host is mapped to them.
+const PathPrefix = "/t/"
+
+var validID = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// ValidID reports whether id can be used as a tenant ID.
+func ValidID(id string) bool {
+	return validID.MatchString(id)
+}
+
+// Registry maps requests to per-tenant handlers, which are built on first
+// use and cached until the tenant changes.
+type Registry struct {
+	// Store is the root store holding the tenant records.
+	Store db.CelerixStore
+	// Build creates the API handler for a tenant.
+	Build func(t db.TenantRecord) (http.Handler, error)
+
+	mu       sync.Mutex
+	handlers map[string]http.Handler
+	hosts    map[string]string
+}
+
+// Handler returns the API handler for the tenant, or false if it doesn't exist.
+func (r *Registry) Handler(id string) (http.Handler, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if h, ok := r.handlers[id]; ok {
+		return h, true
+	}
+	t, err := db.GetTenant(r.Store, id)
+	if err != nil {
+		return nil, false
+	}
+	h, err := r.Build(*t)
+	if err != nil {
+		return nil, false
+	}
+	if r.handlers == nil {
+		r.handlers = make(map[string]http.Handler)
+	}
+	r.handlers[id] = h
+	return h, true
+}
+
+// ForHost returns the tenant mapped to the host, ignoring any port.
+func (r *Registry) ForHost(host string) (string, bool) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.hosts == nil {
+		r.hosts = make(map[string]string)
+		tenants, _ := db.ListTenants(r.Store)
+		for _, t := range tenants {
+			for _, h := range t.Hosts {
+				r.hosts[h] = t.ID
+			}
+		}
+	}
+	id, ok := r.hosts[host]
+	return id, ok
+}
+
+// Invalidate drops cached state after a tenant is created, changed or removed.
+func (r *Registry) Invalidate(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.handlers, id)
+	r.hosts = nil
+}
+
+// Middleware routes API and site requests to a tenant either by host name or by the
+// /t/<tenant> path prefix. Anything else falls through to the root depot.
+func (r *Registry) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+
+		if rest, ok := strings.CutPrefix(path, PathPrefix); ok {
+			id, sub, _ := strings.Cut(rest, "/")
+			h, found := r.Handler(id)
+			if !found {
+				c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Tenant not found"})
+				return
+			}
+			c.Request.URL.Path = "/" + sub
+			c.Request.URL.RawPath = ""
+			r.serve(c, h)
+			c.Abort()
+			return
+		}
+
+		if !strings.HasPrefix(path, "/api") && !strings.HasPrefix(path, "/sites/") {
+			c.Next()
+			return
+		}
+		if id, ok := r.ForHost(c.Request.Host); ok {
+			if h, found := r.Handler(id); found {
+				r.serve(c, h)
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+func (r *Registry) serve(c *gin.Context, h http.Handler) {
+	// gin presets 404 for paths the root router doesn't know, which would
+	// leak into tenant responses that don't set a status explicitly
+	c.Status(http.StatusOK)
+	h.ServeHTTP(c.Writer, c.Request)
+}
@@ -0,0 +1,78 @@
+// Package tenant lets one depot instance serve several isolated depots.
+// Each tenant gets its own view of the store, its own storage directory and
+// its own admin secret.
+package tenant
+
+import (
+	"strings"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+	"github.com/celerix/depot/internal/db"
+)
+
+// appSeparator joins an app ID and a tenant ID. It can't appear in tenant IDs.
+const appSeparator = "@"
+
+// Scope returns a view of base where every app is namespaced to the tenant,
+// so code written against a single depot works unchanged per tenant. The
+// view locks through the same Locker as base.
+func Scope(base sdk.CelerixStore, tenantID string) sdk.CelerixStore {
+	return db.ShareWith(&scopedStore{base: base, suffix: appSeparator + tenantID}, base)
+}
+
+type scopedStore struct {
+	base   sdk.CelerixStore
+	suffix string
+}
+
+func (s *scopedStore) app(appID string) string { return appID + s.suffix }
+
+func (s *scopedStore) Get(personaID, appID, key string) (any, error) {
+	return s.base.Get(personaID, s.app(appID), key)
+}
+
+func (s *scopedStore) Set(personaID, appID, key string, val any) error {
+	return s.base.Set(personaID, s.app(appID), key, val)
+}
+
+func (s *scopedStore) Delete(personaID, appID, key string) error {
+	return s.base.Delete(personaID, s.app(appID), key)
+}
+
+func (s *scopedStore) GetPersonas() ([]string, error) {
+	return s.base.GetPersonas()
+}
+
+func (s *scopedStore) GetApps(personaID string) ([]string, error) {
+	apps, err := s.base.GetApps(personaID)
+	if err != nil {
+		return nil, err
+	}
+	var scoped []string
+	for _, a := range apps {
+		if strings.HasSuffix(a, s.suffix) {
+			scoped = append(scoped, strings.TrimSuffix(a, s.suffix))
+		}
+	}
+	return scoped, nil
+}
+
+func (s *scopedStore) GetAppStore(personaID, appID string) (map[string]any, error) {
+	return s.base.GetAppStore(personaID, s.app(appID))
+}
+
+func (s *scopedStore) DumpApp(appID string) (map[string]map[string]any, error) {
+	return s.base.DumpApp(s.app(appID))
+}
+
+func (s *scopedStore) GetGlobal(appID, key string) (any, string, error) {
+	return s.base.GetGlobal(s.app(appID), key)
+}
+
+func (s *scopedStore) Move(srcPersona, dstPersona, appID, key string) error {
+	return s.base.Move(srcPersona, dstPersona, s.app(appID), key)
+}
+
+func (s *scopedStore) App(personaID, appID string) sdk.AppScope {
+	return s.base.App(personaID, s.app(appID))
+}
@@ -0,0 +1,91 @@
+package tenant
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/celerix-dev/celerix-store/pkg/engine"
+	"github.com/celerix/depot/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestScopeIsolatesTenants(t *testing.T) {
+	base := engine.NewMemStore(nil, nil)
+	acme := Scope(base, "acme")
+	globex := Scope(base, "globex")
+
+	if err := db.SaveFileRecord(acme, db.FileRecord{ID: "f1", OwnerID: "c1", OriginalName: "a.txt"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.GetFileRecord(acme, "f1"); err != nil {
+		t.Errorf("tenant should see its own file: %v", err)
+	}
+	if _, err := db.GetFileRecord(globex, "f1"); err == nil {
+		t.Error("file leaked into another tenant")
+	}
+	if _, err := db.GetFileRecord(base, "f1"); err == nil {
+		t.Error("file leaked into the root depot")
+	}
+
+	apps, _ := acme.GetApps("c1")
+	if len(apps) != 1 || apps[0] != db.AppID {
+		t.Errorf("expected scoped app list [%s], got %v", db.AppID, apps)
+	}
+
+	// Tenants lock like the depot they are part of
+	locker := db.NewStoreLocker(base)
+	shared, ok := Scope(&db.SharedStore{CelerixStore: base, Locker: locker}, "acme").(*db.SharedStore)
+	if !ok || shared.Locker != locker {
+		t.Error("expected the tenant store to keep the depot's Locker")
+	}
+	if err := db.SaveFileRecord(shared, db.FileRecord{ID: "f2", OwnerID: "c1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetFileRecord(acme, "f2"); err != nil {
+		t.Errorf("expected the shared view to stay scoped to the tenant: %v", err)
+	}
+}
+
+func TestMiddlewareRoutesByPathAndHost(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	base := engine.NewMemStore(nil, nil)
+	db.SaveTenant(base, db.TenantRecord{ID: "acme", Hosts: []string{"Files.Acme.test"}})
+
+	reg := &Registry{
+		Store: base,
+		Build: func(rec db.TenantRecord) (http.Handler, error) {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(rec.ID + " " + r.URL.Path))
+			}), nil
+		},
+	}
+
+	r := gin.New()
+	r.Use(reg.Middleware())
+	r.GET("/api/version", func(c *gin.Context) { c.String(http.StatusOK, "root") })
+
+	tests := []struct {
+		host, path, want string
+		status           int
+	}{
+		{"depot.test", "/api/version", "root", http.StatusOK},
+		{"depot.test", "/t/acme/api/version", "acme /api/version", http.StatusOK},
+		{"files.acme.test:8080", "/api/version", "acme /api/version", http.StatusOK},
+		{"depot.test", "/t/missing/api/version", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.Host = tt.host
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.status {
+			t.Errorf("%s%s: status %d, want %d", tt.host, tt.path, w.Code, tt.status)
+			continue
+		}
+		if tt.want != "" && w.Body.String() != tt.want {
+			t.Errorf("%s%s: got %q, want %q", tt.host, tt.path, w.Body.String(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package torrent
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// Encode bencodes strings, byte slices, integers, lists and string keyed
+// maps, which is all a .torrent file needs.
+func Encode(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encode(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encode(buf *bytes.Buffer, v any) error {
+	switch val := v.(type) {
+	case string:
+		fmt.Fprintf(buf, "%d:%s", len(val), val)
+	case []byte:
+		fmt.Fprintf(buf, "%d:", len(val))
+		buf.Write(val)
+	case int:
+		fmt.Fprintf(buf, "i%de", val)
+	case int64:
+		fmt.Fprintf(buf, "i%de", val)
+	case []string:
+		buf.WriteByte('l')
+		for _, item := range val {
+			if err := encode(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('e')
+	case []any:
+		buf.WriteByte('l')
+		for _, item := range val {
+			if err := encode(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('e')
+	case map[string]any:
+		// Keys must appear in sorted order for the info hash to be stable
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		buf.WriteByte('d')
+		for _, k := range keys {
+			encode(buf, k)
+			if err := encode(buf, val[k]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('e')
+	default:
+		return fmt.Errorf("bencode: unsupported type %T", v)
+	}
+	return nil
+}
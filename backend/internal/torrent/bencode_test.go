@@ -0,0 +1,28 @@
+package torrent
+
+import "testing"
+
+func TestEncode(t *testing.T) {
+	got, err := Encode(map[string]any{
+		"name":   "file.iso",
+		"length": int64(42),
+		"list":   []string{"a", "bc"},
+	})
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	want := "d6:lengthi42e4:listl1:a2:bce4:name8:file.isoe"
+	if string(got) != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestPieceLength(t *testing.T) {
+	if got := PieceLength(1 << 20); got != minPieceLength {
+		t.Errorf("expected minimum piece length for small files, got %d", got)
+	}
+	if got := PieceLength(1 << 40); got != maxPieceLength {
+		t.Errorf("expected maximum piece length for huge files, got %d", got)
+	}
+}
@@ -0,0 +1,83 @@
+package torrent
+
+import (
+	"crypto/sha1"
+	"io"
+	"time"
+)
+
+const (
+	minPieceLength = 256 << 10
+	maxPieceLength = 16 << 20
+	targetPieces   = 1500
+)
+
+// PieceLength picks a power of two piece size that keeps the piece count
+// for a file of the given size near a sensible target.
+func PieceLength(size int64) int64 {
+	length := int64(minPieceLength)
+	for length < maxPieceLength && size/length > targetPieces {
+		length *= 2
+	}
+	return length
+}
+
+// HashPieces returns the concatenated SHA-1 digests of each piece of the
+// content.
+func HashPieces(r io.Reader, pieceLength int64) ([]byte, error) {
+	var pieces []byte
+	for {
+		hasher := sha1.New()
+		n, err := io.CopyN(hasher, r, pieceLength)
+		if n > 0 {
+			pieces = hasher.Sum(pieces)
+		}
+		if err == io.EOF {
+			return pieces, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+type Meta struct {
+	Name        string
+	Size        int64
+	PieceLength int64
+	Pieces      []byte
+	WebSeed     string
+	Trackers    []string
+	Comment     string
+}
+
+// Build produces a single-file .torrent using the depot download URL as a
+// BEP 19 web seed, so peers can always fall back to fetching from the depot.
+func Build(m Meta) ([]byte, error) {
+	info := map[string]any{
+		"name":         m.Name,
+		"length":       m.Size,
+		"piece length": m.PieceLength,
+		"pieces":       m.Pieces,
+	}
+
+	root := map[string]any{
+		"info":          info,
+		"url-list":      []string{m.WebSeed},
+		"creation date": time.Now().Unix(),
+		"created by":    "celerix-depot",
+	}
+	if m.Comment != "" {
+		root["comment"] = m.Comment
+	}
+	if len(m.Trackers) > 0 {
+		root["announce"] = m.Trackers[0]
+		tiers := make([]any, 0, len(m.Trackers))
+		for _, t := range m.Trackers {
+			tiers = append(tiers, []string{t})
+		}
+		root["announce-list"] = tiers
+	}
+
+	return Encode(root)
+}
@@ -0,0 +1,194 @@
+package depot
+
+import (
+	"github.com/celerix/depot/internal/api"
+	"github.com/gin-gonic/gin"
+)
+
+func registerRoutes(apiGroup *gin.RouterGroup, h *api.Handler) {
+	apiGroup.Use(h.RateLimit(), h.BodyLimit(), h.Deadline(), h.MaintenanceGate(), h.TransferLanes(), h.Idempotency())
+	apiGroup.GET("/maintenance", h.GetMaintenance)
+	apiGroup.GET("/version", h.GetVersion)
+	apiGroup.GET("/signing-key", h.GetSigningKey)
+	apiGroup.GET("/settings", h.GetSettings)
+	apiGroup.GET("/persona", h.GetPersona)
+	apiGroup.GET("/persona/activity", h.GetActivity)
+	apiGroup.GET("/persona/usage/history", h.GetUsageHistory)
+	apiGroup.GET("/persona/group", h.GetClientGroup)
+	apiGroup.GET("/persona/notifications", h.GetNotifications)
+	apiGroup.PUT("/persona/notifications", h.PutNotifications)
+	apiGroup.POST("/persona/name", h.UpdateClientName)
+	apiGroup.POST("/persona/recover", h.RecoverPersona)
+	apiGroup.POST("/persona/admin", h.ActivateAdmin)
+	apiGroup.PUT("/persona/content-index", h.UpdateContentIndexPreference)
+	apiGroup.PUT("/persona/email-alias", h.UpdateEmailAlias)
+	apiGroup.POST("/upload", h.UploadFile)
+	apiGroup.POST("/upload/check", h.UploadCheck)
+	apiGroup.PUT("/upload/raw", h.UploadRaw)
+	apiGroup.POST("/upload/presign", h.PresignUpload)
+	apiGroup.POST("/upload/presign/:id/commit", h.CommitPresignedUpload)
+	apiGroup.GET("/probe", h.GetProbe)
+	apiGroup.GET("/probe/download", h.ProbeDownload)
+	apiGroup.POST("/probe/upload", h.ProbeUpload)
+	apiGroup.POST("/uploads", h.CreateUploadSession)
+	apiGroup.GET("/uploads/:id", h.GetUploadSession)
+	apiGroup.POST("/uploads/:id/hashes", h.MatchUploadChunks)
+	apiGroup.PUT("/uploads/:id/chunks/:index", h.UploadChunk)
+	apiGroup.POST("/uploads/:id/complete", h.CompleteUploadSession)
+	apiGroup.DELETE("/uploads/:id", h.AbortUploadSession)
+	apiGroup.GET("/persona/folder-policies", h.ListFolderPolicies)
+	apiGroup.GET("/persona/folder-policy", h.GetFolderPolicy)
+	apiGroup.PUT("/persona/folder-policy", h.PutFolderPolicy)
+	apiGroup.DELETE("/persona/folder-policy", h.DeleteFolderPolicy)
+	apiGroup.GET("/folders/checksums", h.GetFolderChecksums)
+	apiGroup.GET("/changes", h.ListChanges)
+	apiGroup.GET("/sync/changes", h.GetSyncChanges)
+	apiGroup.POST("/sync/stat", h.SyncStat)
+	apiGroup.PUT("/sync/file", h.SyncPut)
+	apiGroup.DELETE("/sync/file", h.SyncDelete)
+	apiGroup.GET("/persona/sites", h.ListSites)
+	apiGroup.PUT("/persona/sites/:slug", h.PublishSite)
+	apiGroup.DELETE("/persona/sites/:slug", h.UnpublishSite)
+	apiGroup.GET("/persona/integrations/chat", h.GetClientChatIntegration)
+	apiGroup.PUT("/persona/integrations/chat", h.UpdateClientChatIntegration)
+	apiGroup.GET("/persona/embed", h.GetEmbedPolicy)
+	apiGroup.GET("/persona/guest-tokens", h.ListGuestTokens)
+	apiGroup.POST("/persona/guest-tokens", h.CreateGuestToken)
+	apiGroup.DELETE("/persona/guest-tokens/:id", h.DeleteGuestToken)
+	apiGroup.PUT("/persona/embed", h.UpdateEmbedPolicy)
+	apiGroup.GET("/integrations/sharex", h.GetShareXConfig)
+	apiGroup.POST("/integrations/sharex/upload", h.ShareXUpload)
+	apiGroup.GET("/integrations/sharex/delete/:id/:token", h.ShareXDelete)
+	apiGroup.DELETE("/integrations/sharex/delete/:id/:token", h.ShareXDelete)
+	apiGroup.GET("/requests", h.ListFileRequests)
+	apiGroup.POST("/requests", h.CreateFileRequest)
+	apiGroup.GET("/requests/:id", h.GetFileRequest)
+	apiGroup.DELETE("/requests/:id", h.DeleteFileRequest)
+	apiGroup.POST("/requests/:id/upload", h.FulfillFileRequest)
+	guest := apiGroup.Group("/guest", h.GuestAuth())
+	guest.GET("", h.GetGuestScope)
+	guest.GET("/files/:id", h.GuestDownload)
+	guest.POST("/upload", h.GuestUpload)
+	apiGroup.POST("/snippets", h.CreateSnippet)
+	apiGroup.GET("/snippets/:id", h.GetSnippet)
+	apiGroup.GET("/snippets/:id/raw", h.GetSnippetRaw)
+	apiGroup.GET("/artifacts", h.ListArtifacts)
+	apiGroup.GET("/artifacts/:name", h.ListArtifactVersions)
+	apiGroup.GET("/artifacts/:name/:version", h.GetArtifact)
+	apiGroup.PUT("/artifacts/:name/:version", h.PublishArtifact)
+	apiGroup.GET("/files", h.ListFiles)
+	apiGroup.GET("/files/export", h.ExportFiles)
+	apiGroup.GET("/files/checksums", h.GetSelectionChecksums)
+	apiGroup.GET("/files/duplicates", h.ListDuplicates)
+	apiGroup.POST("/files/duplicates/collapse", h.CollapseDuplicates)
+	apiGroup.POST("/files/link", h.CreateLinkFile)
+	apiGroup.POST("/files/save-copy", h.SaveSharedCopy)
+	apiGroup.GET("/files/:id", h.GetFileMetadata)
+	apiGroup.PUT("/files/:id", h.UpdateFile)
+	apiGroup.DELETE("/files/:id", h.DeleteFile)
+	apiGroup.POST("/files/:id/transfer", h.TransferFile)
+	apiGroup.POST("/files/:id/clone-to/:clientId", h.AdminCloneFile)
+	apiGroup.GET("/files/:id/extend", h.ExtendPage)
+	apiGroup.POST("/files/:id/extend", h.ExtendFile)
+	apiGroup.GET("/files/:id/torrent", h.GetFileTorrent)
+	apiGroup.GET("/files/:id/analytics", h.GetFileAnalytics)
+	apiGroup.GET("/admin/audit", h.ListAudit)
+	apiGroup.GET("/admin/integrations/chat", h.AdminGetChatIntegration)
+	apiGroup.PUT("/admin/integrations/chat", h.AdminUpdateChatIntegration)
+	apiGroup.GET("/admin/settings", h.AdminGetSettings)
+	apiGroup.GET("/presets", h.ListPresets)
+	apiGroup.PUT("/admin/presets/:name", h.AdminPutPreset)
+	apiGroup.DELETE("/admin/presets/:name", h.AdminDeletePreset)
+	apiGroup.GET("/admin/groups", h.AdminListGroups)
+	apiGroup.PUT("/admin/groups/:id", h.AdminPutGroup)
+	apiGroup.DELETE("/admin/groups/:id", h.AdminDeleteGroup)
+	apiGroup.GET("/trusted-keys", h.ListTrustedKeys)
+	apiGroup.GET("/federation/files", h.FederationFiles)
+	apiGroup.GET("/remotes", h.ListRemotes)
+	apiGroup.GET("/remotes/:name/files", h.BrowseRemote)
+	apiGroup.GET("/remotes/:name/download/:link", h.DownloadRemote)
+	apiGroup.PUT("/admin/remotes/:name", h.AdminPutRemote)
+	apiGroup.DELETE("/admin/remotes/:name", h.AdminDeleteRemote)
+	apiGroup.PUT("/admin/trusted-keys/:name", h.AdminPutTrustedKey)
+	apiGroup.DELETE("/admin/trusted-keys/:name", h.AdminDeleteTrustedKey)
+	apiGroup.PUT("/admin/settings", h.UpdateSettings)
+	apiGroup.POST("/admin/config/reload", h.AdminReloadConfig)
+	apiGroup.PUT("/admin/bootstrap", h.AdminBootstrap)
+	apiGroup.GET("/admin/scrub", h.GetScrubStatus)
+	apiGroup.POST("/admin/scrub", h.RunScrub)
+	apiGroup.GET("/admin/duplicates", h.AdminListDuplicates)
+	apiGroup.POST("/admin/duplicates/collapse", h.AdminCollapseDuplicates)
+	apiGroup.GET("/admin/orphans", h.AdminListOrphans)
+	apiGroup.POST("/admin/orphans/reassign", h.AdminReassignOrphans)
+	apiGroup.GET("/admin/maintenance", h.AdminGetMaintenance)
+	apiGroup.POST("/admin/maintenance", h.AdminSetMaintenance)
+	apiGroup.POST("/admin/maintenance/compact", h.AdminCompact)
+	apiGroup.POST("/admin/import", h.AdminImportTree)
+	apiGroup.GET("/admin/usage", h.AdminGetUsage)
+	apiGroup.GET("/admin/capacity", h.AdminGetCapacity)
+	apiGroup.GET("/admin/mirror", h.AdminGetMirror)
+	apiGroup.PUT("/admin/mirror", h.AdminPutMirror)
+	apiGroup.GET("/admin/mirror/files", h.AdminListMirrorStatus)
+	apiGroup.POST("/admin/mirror/files/:id", h.AdminMirrorFile)
+	apiGroup.GET("/admin/commands/dead-letters", h.AdminListDeadLetters)
+	apiGroup.POST("/admin/commands/dead-letters/:id/retry", h.AdminRetryDeadLetter)
+	apiGroup.DELETE("/admin/commands/dead-letters/:id", h.AdminDismissDeadLetter)
+	apiGroup.GET("/admin/alerts", h.AdminListAlerts)
+	apiGroup.POST("/admin/alerts/:id/acknowledge", h.AdminAcknowledgeAlert)
+	apiGroup.POST("/admin/alerts/:id/resolve", h.AdminResolveAlert)
+	apiGroup.GET("/clients", h.ListClients)
+	apiGroup.POST("/admin/clients/import", h.AdminImportClients)
+	apiGroup.GET("/admin/clients/sheet", h.AdminRecoverySheet)
+	apiGroup.GET("/admin/shortlinks", h.AdminListShortLinks)
+	apiGroup.POST("/admin/shortlinks", h.AdminCreateShortLink)
+	apiGroup.DELETE("/admin/shortlinks/:slug", h.AdminDeleteShortLink)
+	apiGroup.PUT("/clients/:id", h.UpdateClient)
+	apiGroup.DELETE("/clients/:id", h.DeleteClient)
+	apiGroup.POST("/admin/clients/:id/purge", h.AdminPurgeClient)
+	apiGroup.GET("/admin/purges", h.AdminListPurges)
+	apiGroup.GET("/download/:id", h.DownloadFile)
+	apiGroup.HEAD("/download/:id", h.HeadDownload)
+	apiGroup.GET("/download/:id/parts", h.GetDownloadParts)
+	apiGroup.GET("/download/:id/info", h.GetDownloadInfo)
+}
+
+func registerGatewayRoutes(apiGroup *gin.RouterGroup, h *api.Handler) {
+	apiGroup.Use(h.RateLimit(), h.BodyLimit(), h.Deadline(), h.MaintenanceGate(), h.TransferLanes())
+	apiGroup.GET("/maintenance", h.GetMaintenance)
+	apiGroup.GET("/version", h.GetVersion)
+	apiGroup.GET("/settings", h.GetSettings)
+	apiGroup.GET("/download/:id", h.DownloadFile)
+	apiGroup.HEAD("/download/:id", h.HeadDownload)
+	apiGroup.GET("/download/:id/parts", h.GetDownloadParts)
+	apiGroup.GET("/download/:id/info", h.GetDownloadInfo)
+	apiGroup.GET("/files/checksums", h.GetSelectionChecksums)
+	apiGroup.GET("/signing-key", h.GetSigningKey)
+	apiGroup.GET("/snippets/:id", h.GetSnippet)
+	apiGroup.GET("/snippets/:id/raw", h.GetSnippetRaw)
+	apiGroup.GET("/artifacts", h.ListArtifacts)
+	apiGroup.GET("/artifacts/:name", h.ListArtifactVersions)
+	apiGroup.GET("/artifacts/:name/:version", h.GetArtifact)
+}
+
+// registerSiteRoutes serves published folders, short links, raw links and
+// embed cards. They live beside /api rather than under it so relative links
+// inside the sites resolve naturally, short links stay short and raw links
+// end in the file name.
+func registerSiteRoutes(r gin.IRouter, h *api.Handler) {
+	deadline, gate, lanes := h.Deadline(), h.MaintenanceGate(), h.TransferLanes()
+	r.GET("/sites/:slug/*path", deadline, gate, h.ServeSite)
+	r.HEAD("/sites/:slug/*path", deadline, gate, h.ServeSite)
+	r.GET("/s/:slug", deadline, gate, h.ResolveShortLink)
+	r.GET("/raw/:link/:filename", deadline, gate, lanes, h.ServeRawFile)
+	r.HEAD("/raw/:link/:filename", deadline, gate, h.ServeRawFile)
+	r.GET("/embed/:link", deadline, gate, h.EmbedFile)
+}
+
+// registerDiagnosticsRoutes serves the profiles and runtime statistics,
+// to admins only: profiles reveal the command line and stack contents.
+func registerDiagnosticsRoutes(r *gin.RouterGroup, h *api.Handler) {
+	r.Use(h.AdminOnly())
+	r.GET("/runtime", h.AdminGetRuntime)
+	r.GET("/pprof/*profile", h.AdminPprof)
+	r.POST("/pprof/*profile", h.AdminPprof)
+}
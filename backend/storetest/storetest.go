@@ -0,0 +1,374 @@
+// Package storetest checks that a CelerixStore implementation behaves the
+// way the depot relies on. Backends call Run from a test:
+//
+//	func TestConformance(t *testing.T) {
+//		storetest.Run(t, func(t *testing.T) sdk.CelerixStore {
+//			return mybackend.New(t.TempDir())
+//		})
+//	}
+//
+// Misses only need to return an error, not a particular one; the depot
+// never tells them apart. Nor does it change a value after storing it, so
+// stores may keep the caller's value as is.
+package storetest
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+)
+
+// Factory returns a new, empty store for one test.
+type Factory func(t *testing.T) sdk.CelerixStore
+
+const app = "storetest"
+
+// record is a value with the shapes the depot stores: nested maps, slices
+// and pointers.
+type record struct {
+	ID       string            `json:"id"`
+	Size     int64             `json:"size"`
+	Public   bool              `json:"public"`
+	Tags     []string          `json:"tags"`
+	Metadata map[string]string `json:"metadata"`
+	Limit    *int64            `json:"limit"`
+}
+
+func sample(id string) record {
+	limit := int64(42)
+	return record{
+		ID:       id,
+		Size:     1 << 40,
+		Public:   true,
+		Tags:     []string{"a", "b"},
+		Metadata: map[string]string{"k": "v"},
+		Limit:    &limit,
+	}
+}
+
+// Run runs the conformance tests against stores made by factory.
+func Run(t *testing.T, factory Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s sdk.CelerixStore)
+	}{
+		{"GetMissing", testGetMissing},
+		{"SetGet", testSetGet},
+		{"Overwrite", testOverwrite},
+		{"Delete", testDelete},
+		{"PersonaIsolation", testPersonaIsolation},
+		{"Enumeration", testEnumeration},
+		{"GetAppStore", testGetAppStore},
+		{"DumpApp", testDumpApp},
+		{"GetGlobal", testGetGlobal},
+		{"Move", testMove},
+		{"MoveMissing", testMoveMissing},
+		{"AppScope", testAppScope},
+		{"Vault", testVault},
+		{"ConcurrentWrites", testConcurrentWrites},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, factory(t))
+		})
+	}
+}
+
+func mustSet(t *testing.T, s sdk.CelerixStore, persona, key string, val any) {
+	t.Helper()
+	if err := s.Set(persona, app, key, val); err != nil {
+		t.Fatalf("Set(%s, %s): %v", persona, key, err)
+	}
+}
+
+func mustGet(t *testing.T, s sdk.CelerixStore, persona, key string) record {
+	t.Helper()
+	r, err := sdk.Get[record](s, persona, app, key)
+	if err != nil {
+		t.Fatalf("Get(%s, %s): %v", persona, key, err)
+	}
+	return r
+}
+
+func testGetMissing(t *testing.T, s sdk.CelerixStore) {
+	if _, err := s.Get("nobody", app, "k"); err == nil {
+		t.Error("expected an error for a missing persona")
+	}
+	mustSet(t, s, "p", "k", sample("1"))
+	if _, err := s.Get("p", "other-app", "k"); err == nil {
+		t.Error("expected an error for a missing app")
+	}
+	if _, err := s.Get("p", app, "missing"); err == nil {
+		t.Error("expected an error for a missing key")
+	}
+}
+
+func testSetGet(t *testing.T, s sdk.CelerixStore) {
+	want := sample("1")
+	mustSet(t, s, "p", "k", want)
+	if got := mustGet(t, s, "p", "k"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func testOverwrite(t *testing.T, s sdk.CelerixStore) {
+	mustSet(t, s, "p", "k", sample("1"))
+	mustSet(t, s, "p", "k", sample("2"))
+	if got := mustGet(t, s, "p", "k"); got.ID != "2" {
+		t.Errorf("expected the second value, got %+v", got)
+	}
+}
+
+func testDelete(t *testing.T, s sdk.CelerixStore) {
+	mustSet(t, s, "p", "k", sample("1"))
+	mustSet(t, s, "p", "other", sample("2"))
+	if err := s.Delete("p", app, "k"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Get("p", app, "k"); err == nil {
+		t.Error("expected the key to be gone")
+	}
+	mustGet(t, s, "p", "other")
+
+	if err := s.Delete("p", app, "k"); err != nil {
+		t.Errorf("deleting a missing key should succeed, got %v", err)
+	}
+	if err := s.Delete("nobody", app, "k"); err != nil {
+		t.Errorf("deleting from a missing persona should succeed, got %v", err)
+	}
+}
+
+func testPersonaIsolation(t *testing.T, s sdk.CelerixStore) {
+	mustSet(t, s, "a", "k", sample("a"))
+	mustSet(t, s, "b", "k", sample("b"))
+	mustSet(t, s, "a", "k2", sample("a2"))
+	if err := s.Set("a", "other-app", "k", sample("other")); err != nil {
+		t.Fatal(err)
+	}
+	if got := mustGet(t, s, "a", "k"); got.ID != "a" {
+		t.Errorf("persona a: got %+v", got)
+	}
+	if got := mustGet(t, s, "b", "k"); got.ID != "b" {
+		t.Errorf("persona b: got %+v", got)
+	}
+	s.Delete("a", app, "k")
+	if got := mustGet(t, s, "b", "k"); got.ID != "b" {
+		t.Errorf("deleting from persona a changed persona b: %+v", got)
+	}
+}
+
+func testEnumeration(t *testing.T, s sdk.CelerixStore) {
+	mustSet(t, s, "a", "k", sample("1"))
+	mustSet(t, s, "b", "k", sample("2"))
+	if err := s.Set("a", "other-app", "k", sample("3")); err != nil {
+		t.Fatal(err)
+	}
+
+	personas, err := s.GetPersonas()
+	if err != nil {
+		t.Fatalf("GetPersonas: %v", err)
+	}
+	for _, p := range []string{"a", "b"} {
+		if !slices.Contains(personas, p) {
+			t.Errorf("GetPersonas = %v, missing %s", personas, p)
+		}
+	}
+	apps, err := s.GetApps("a")
+	if err != nil {
+		t.Fatalf("GetApps: %v", err)
+	}
+	slices.Sort(apps)
+	if !reflect.DeepEqual(apps, []string{"other-app", app}) {
+		t.Errorf("GetApps = %v", apps)
+	}
+}
+
+func testGetAppStore(t *testing.T, s sdk.CelerixStore) {
+	if _, err := s.GetAppStore("nobody", app); err == nil {
+		t.Error("expected an error for a missing app")
+	}
+	for i := range 3 {
+		mustSet(t, s, "p", fmt.Sprint("k", i), sample(fmt.Sprint(i)))
+	}
+	mustSet(t, s, "q", "k9", sample("9"))
+
+	values, err := s.GetAppStore("p", app)
+	if err != nil {
+		t.Fatalf("GetAppStore: %v", err)
+	}
+	if len(values) != 3 {
+		t.Fatalf("expected 3 keys, got %d", len(values))
+	}
+	for i := range 3 {
+		if _, ok := values[fmt.Sprint("k", i)]; !ok {
+			t.Errorf("missing k%d", i)
+		}
+	}
+
+	// The result is a copy
+	delete(values, "k0")
+	values["new"] = "x"
+	again, _ := s.GetAppStore("p", app)
+	if _, ok := again["k0"]; !ok || len(again) != 3 {
+		t.Errorf("changing the result changed the store: %v", again)
+	}
+}
+
+func testDumpApp(t *testing.T, s sdk.CelerixStore) {
+	mustSet(t, s, "a", "k", sample("1"))
+	mustSet(t, s, "b", "k", sample("2"))
+	if err := s.Set("c", "other-app", "k", sample("3")); err != nil {
+		t.Fatal(err)
+	}
+
+	dump, err := s.DumpApp(app)
+	if err != nil {
+		t.Fatalf("DumpApp: %v", err)
+	}
+	if len(dump["a"]) != 1 || len(dump["b"]) != 1 {
+		t.Errorf("expected one key for a and b, got %v", dump)
+	}
+	if len(dump["c"]) != 0 {
+		t.Errorf("expected nothing for a persona without the app, got %v", dump["c"])
+	}
+}
+
+func testGetGlobal(t *testing.T, s sdk.CelerixStore) {
+	if _, _, err := s.GetGlobal(app, "k"); err == nil {
+		t.Error("expected an error for a missing key")
+	}
+	mustSet(t, s, "a", "other", sample("1"))
+	mustSet(t, s, "b", "k", sample("2"))
+
+	val, persona, err := s.GetGlobal(app, "k")
+	if err != nil {
+		t.Fatalf("GetGlobal: %v", err)
+	}
+	if persona != "b" {
+		t.Errorf("expected persona b, got %q", persona)
+	}
+	if val == nil {
+		t.Error("expected a value")
+	}
+}
+
+func testMove(t *testing.T, s sdk.CelerixStore) {
+	want := sample("1")
+	mustSet(t, s, "a", "k", want)
+	mustSet(t, s, "a", "stays", sample("2"))
+
+	if err := s.Move("a", "new", app, "k"); err != nil {
+		t.Fatalf("Move: %v", err)
+	}
+	if _, err := s.Get("a", app, "k"); err == nil {
+		t.Error("expected the key to be gone from the source")
+	}
+	if got := mustGet(t, s, "new", "k"); !reflect.DeepEqual(got, want) {
+		t.Errorf("moved value: got %+v, want %+v", got, want)
+	}
+	if _, persona, err := s.GetGlobal(app, "k"); err != nil || persona != "new" {
+		t.Errorf("GetGlobal after Move: %q, %v", persona, err)
+	}
+	mustGet(t, s, "a", "stays")
+
+	// And back onto an existing persona
+	mustSet(t, s, "a", "k", sample("replaced"))
+	if err := s.Move("new", "a", app, "k"); err != nil {
+		t.Fatalf("Move back: %v", err)
+	}
+	if got := mustGet(t, s, "a", "k"); got.ID != "1" {
+		t.Errorf("expected the moved value to replace the target's, got %+v", got)
+	}
+}
+
+func testMoveMissing(t *testing.T, s sdk.CelerixStore) {
+	if err := s.Move("nobody", "b", app, "k"); err == nil {
+		t.Error("expected an error moving from a missing persona")
+	}
+	mustSet(t, s, "a", "other", sample("1"))
+	if err := s.Move("a", "b", app, "k"); err == nil {
+		t.Error("expected an error moving a missing key")
+	}
+	if _, err := s.Get("b", app, "k"); err == nil {
+		t.Error("a failed Move must not create the key")
+	}
+}
+
+func testAppScope(t *testing.T, s sdk.CelerixStore) {
+	scope := s.App("p", app)
+	if err := scope.Set("k", sample("1")); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if got := mustGet(t, s, "p", "k"); got.ID != "1" {
+		t.Errorf("scoped Set: got %+v", got)
+	}
+	if _, err := scope.Get("k"); err != nil {
+		t.Errorf("scoped Get: %v", err)
+	}
+	if err := scope.Delete("k"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Get("p", app, "k"); err == nil {
+		t.Error("expected the scoped Delete to remove the key")
+	}
+}
+
+func testVault(t *testing.T, s sdk.CelerixStore) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	vault, ok := s.App("p", app).Vault(key).(sdk.VaultScope)
+	if !ok {
+		t.Fatal("Vault does not return a VaultScope")
+	}
+	if err := vault.Set("secret", "hunter2"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	got, err := vault.Get("secret")
+	if err != nil || got != "hunter2" {
+		t.Errorf("Get = %q, %v", got, err)
+	}
+	raw, err := s.Get("p", app, "secret")
+	if err != nil {
+		t.Fatalf("raw Get: %v", err)
+	}
+	if raw == "hunter2" {
+		t.Error("vault values must not be stored in plain text")
+	}
+
+	other, _ := s.App("p", app).Vault([]byte("fedcba9876543210fedcba9876543210")).(sdk.VaultScope)
+	if got, err := other.Get("secret"); err == nil && got == "hunter2" {
+		t.Error("a different key must not decrypt the value")
+	}
+}
+
+func testConcurrentWrites(t *testing.T, s sdk.CelerixStore) {
+	const writers, each = 8, 25
+	var wg sync.WaitGroup
+	for w := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range each {
+				key := fmt.Sprintf("k%d-%d", w, i)
+				if err := s.Set("p", app, key, sample(key)); err != nil {
+					t.Errorf("Set: %v", err)
+				}
+				if _, err := s.Get("p", app, key); err != nil {
+					t.Errorf("Get: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	values, err := s.GetAppStore("p", app)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != writers*each {
+		t.Errorf("expected %d keys, got %d", writers*each, len(values))
+	}
+}
@@ -0,0 +1,22 @@
+package storetest_test
+
+import (
+	"testing"
+
+	"github.com/celerix-dev/celerix-store/pkg/engine"
+	"github.com/celerix-dev/celerix-store/pkg/sdk"
+	"github.com/celerix/depot/storetest"
+)
+
+// The embedded engine the depot runs on by default.
+func TestEngine(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) sdk.CelerixStore {
+		store, err := sdk.New(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Let background persistence finish before the directory goes
+		t.Cleanup(store.(*engine.MemStore).Wait)
+		return store
+	})
+}
@@ -0,0 +1,38 @@
+export interface DepotSettings {
+  title: string;
+  logo_url: string;
+  quota_bytes: number;
+  max_upload_bytes: number;
+  allowed_extensions: string[] | null;
+  link_expiry_seconds: number;
+}
+
+export const fetchSettings = async (): Promise<DepotSettings | null> => {
+  try {
+    const response = await fetch('/api/settings');
+    if (response.ok) {
+      return await response.json();
+    }
+  } catch (error) {
+    console.error('Error fetching settings:', error);
+  }
+  return null;
+};
+
+export interface MaintenanceStatus {
+  mode: 'off' | 'read_only' | 'closed';
+  message: string;
+  since: number;
+}
+
+export const fetchMaintenance = async (): Promise<MaintenanceStatus | null> => {
+  try {
+    const response = await fetch('/api/maintenance');
+    if (response.ok) {
+      return await response.json();
+    }
+  } catch (error) {
+    console.error('Error fetching maintenance status:', error);
+  }
+  return null;
+};
@@ -1,10 +1,43 @@
 import { fileURLToPath, URL } from 'node:url'
-import {defineConfig} from 'vite'
+import { readdirSync, readFileSync, statSync, writeFileSync } from 'node:fs'
+import { join } from 'node:path'
+import { brotliCompressSync, gzipSync, constants } from 'node:zlib'
+import {defineConfig, type Plugin} from 'vite'
 import vue from '@vitejs/plugin-vue'
 
+// precompress writes .br and .gz copies of the compressible build output,
+// which the depot serves to browsers that accept them.
+function precompress(): Plugin {
+  const compressible = /\.(js|mjs|css|html|json|svg|txt|xml|map|ico|ttf|otf|wasm)$/
+  let outDir = 'dist'
+  const walk = (dir: string): string[] =>
+    readdirSync(dir).flatMap((name) => {
+      const path = join(dir, name)
+      return statSync(path).isDirectory() ? walk(path) : [path]
+    })
+  return {
+    name: 'precompress',
+    apply: 'build',
+    configResolved(config) {
+      outDir = config.build.outDir
+    },
+    closeBundle() {
+      for (const path of walk(outDir)) {
+        if (!compressible.test(path)) continue
+        const data = readFileSync(path)
+        if (data.length < 1024) continue
+        const br = brotliCompressSync(data, { params: { [constants.BROTLI_PARAM_QUALITY]: constants.BROTLI_MAX_QUALITY } })
+        if (br.length < data.length) writeFileSync(path + '.br', br)
+        const gz = gzipSync(data, { level: 9 })
+        if (gz.length < data.length) writeFileSync(path + '.gz', gz)
+      }
+    },
+  }
+}
+
 // https://vitejs.dev/config/
 export default defineConfig({
-  plugins: [vue()],
+  plugins: [vue(), precompress()],
   resolve: {
     alias: {
       '@': fileURLToPath(new URL('./src', import.meta.url))